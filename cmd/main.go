@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"cortex/database"
 	"cortex/logging"
+	"cortex/middleware"
 	"cortex/repository"
 	"cortex/service"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -25,17 +29,104 @@ type AppConfig struct {
 	Environment              string     `env:"CORTEX_ENVIRONMENT"`
 	CORSOrigin               string     `env:"CORTEX_CORS_ALLOWED_ORIGIN"`
 	PostgresConnectionString string     `env:"CORTEX_POSTGRES_CONNECTION_STRING"`
-	// format should be id.secret with id being a 4 byte hex string and secret being a 16 byte hex string
+	// maximum time to wait for a free database connection before responding with 503; zero waits for the request
+	PostgresAcquireTimeout time.Duration `env:"CORTEX_POSTGRES_ACQUIRE_TIMEOUT"`
+	// size of the database connection pool and lifetime of its connections; zero uses the pgxpool defaults
+	DBMaxConns        int32         `env:"CORTEX_DB_MAX_CONNS"`
+	DBMinConns        int32         `env:"CORTEX_DB_MIN_CONNS"`
+	DBMaxConnLifetime time.Duration `env:"CORTEX_DB_MAX_CONN_LIFETIME"`
+	// time between two health checks of idle connections; zero uses the pgxpool default
+	DBHealthCheckPeriod time.Duration `env:"CORTEX_DB_HEALTH_CHECK_PERIOD"`
+	// apply pending database migrations on startup
+	AutoMigrate bool `env:"CORTEX_AUTO_MIGRATE"`
+	// minimum time between two writes of a scan's progress; status transitions are always written immediately
+	ScanUpdateInterval time.Duration `env:"CORTEX_SCAN_UPDATE_INTERVAL"`
+	// time during which findings of a scan are aggregated into a single event for subscribers
+	FindingEventWindow time.Duration `env:"CORTEX_FINDING_EVENT_WINDOW"`
+	// time between two evaluations of the scan schedules; zero disables running scheduled scans on this instance
+	SchedulerInterval time.Duration `env:"CORTEX_SCHEDULER_INTERVAL"`
+	// time after which a running scan is considered stuck and failed; zero never fails running scans
+	ScanMaxRunDuration time.Duration `env:"CORTEX_SCAN_MAX_RUN_DURATION"`
+	// time between two checks for scans running longer than ScanMaxRunDuration
+	OverdueScanInterval time.Duration `env:"CORTEX_OVERDUE_SCAN_INTERVAL"`
+	// time without changes after which queued and running scans are recovered on startup; zero disables the recovery
+	StaleScanAge time.Duration `env:"CORTEX_STALE_SCAN_AGE"`
+	// requeue stale running scans instead of failing them
+	RequeueStaleScans bool `env:"CORTEX_REQUEUE_STALE_SCANS"`
+	// time since the last request of an agent during which it is reported as online
+	AgentOnlineWindow time.Duration `env:"CORTEX_AGENT_ONLINE_WINDOW"`
+	// maximum number of login attempts per source IP within LoginRateWindow
+	LoginRateLimit  int           `env:"CORTEX_LOGIN_RATE_LIMIT"`
+	LoginRateWindow time.Duration `env:"CORTEX_LOGIN_RATE_WINDOW"`
+	// maximum duration of a request, its database queries are canceled once it is exceeded; zero disables the limit
+	RequestTimeout time.Duration `env:"CORTEX_REQUEST_TIMEOUT"`
+	// time responses of requests with an Idempotency-Key are replayed for retries; zero disables idempotency keys
+	IdempotencyTTL time.Duration `env:"CORTEX_IDEMPOTENCY_TTL"`
+	// lifetime of session tokens, and of those created with remember me at login
+	SessionTTL    time.Duration `env:"CORTEX_SESSION_TTL"`
+	RememberMeTTL time.Duration `env:"CORTEX_REMEMBER_ME_TTL"`
+	// header carrying the request id; the id sent by clients is only used if TrustRequestID is set, e.g. behind a
+	// gateway that sets its own request ids
+	RequestIDHeader string `env:"CORTEX_REQUEST_ID_HEADER"`
+	TrustRequestID  bool   `env:"CORTEX_TRUST_REQUEST_ID"`
+	// comma separated networks agents may send requests from; empty allows all addresses
+	AgentIPAllowlist []string `env:"CORTEX_AGENT_IP_ALLOWLIST"`
+	// comma separated networks of proxies whose X-Forwarded-For header is used to determine the client IP for the
+	// agent IP allowlist and the login rate limit
+	TrustedProxies []string `env:"CORTEX_TRUSTED_PROXIES"`
+	// OpenID Connect provider users can log in with in addition to local users; disabled if no issuer is set
+	OIDCIssuer       string `env:"CORTEX_OIDC_ISSUER"`
+	OIDCClientID     string `env:"CORTEX_OIDC_CLIENT_ID"`
+	OIDCClientSecret string `env:"CORTEX_OIDC_CLIENT_SECRET"`
+	// callback URL registered at the provider, <public url>/auth/oidc/callback
+	OIDCRedirectURL string `env:"CORTEX_OIDC_REDIRECT_URL"`
+	// maximum number of assets, scan configurations and agents; zero means unlimited
+	MaxAssets      int `env:"CORTEX_MAX_ASSETS"`
+	MaxScanConfigs int `env:"CORTEX_MAX_SCAN_CONFIGS"`
+	MaxAgents      int `env:"CORTEX_MAX_AGENTS"`
+	// maximum number of scans running at the same time, further scans stay queued; zero means unlimited
+	MaxConcurrentScans int `env:"CORTEX_MAX_CONCURRENT_SCANS"`
+	// format should be id.secret with id being a 4 byte hex string and secret being a 16 byte hex string, or larger
+	// hex strings up to the configured agent token sizes
 	AgentToken string `env:"CORTEX_AGENT_TOKEN"`
+	// number of random bytes of the id and secret of generated agent tokens; id at most 8, secret 16 to 64 bytes
+	AgentTokenIDBytes     int `env:"CORTEX_AGENT_TOKEN_ID_BYTES"`
+	AgentTokenSecretBytes int `env:"CORTEX_AGENT_TOKEN_SECRET_BYTES"`
 }
 
 func main() {
 	// load environment variables
 	var appConfig = AppConfig{
-		ListenAddress: ":3001",
-		LogLevel:      slog.LevelDebug,
-		Environment:   EnvProd,
-		CORSOrigin:    "*",
+		ListenAddress:   ":3001",
+		LogLevel:        slog.LevelDebug,
+		Environment:     EnvProd,
+		CORSOrigin:      "*",
+		RequestIDHeader: "X-Request-ID",
+		//nolint:mnd // default
+		PostgresAcquireTimeout: 5 * time.Second,
+		//nolint:mnd // default
+		ScanUpdateInterval: 5 * time.Second,
+		//nolint:mnd // default
+		SchedulerInterval: 30 * time.Second,
+		//nolint:mnd // default
+		ScanMaxRunDuration: 24 * time.Hour,
+		//nolint:mnd // default
+		OverdueScanInterval: time.Minute,
+		//nolint:mnd // default
+		AgentOnlineWindow: 2 * time.Minute,
+		//nolint:mnd // default
+		FindingEventWindow: 5 * time.Second,
+		//nolint:mnd // default
+		LoginRateLimit: 10,
+		//nolint:mnd // default
+		LoginRateWindow: time.Minute,
+		RequestTimeout:  middleware.DefaultRequestTimeout,
+		//nolint:mnd // default
+		IdempotencyTTL:        24 * time.Hour,
+		SessionTTL:            service.DefaultSessionTTL,
+		RememberMeTTL:         service.DefaultRememberMeTTL,
+		AgentTokenIDBytes:     service.DefaultTokenFormat.IDBytes,
+		AgentTokenSecretBytes: service.DefaultTokenFormat.SecretBytes,
 	}
 	if err := env.Parse(&appConfig); err != nil {
 		fmt.Println(err)
@@ -65,16 +156,65 @@ func main() {
 
 	// connect to database
 	pool := setupDatabase(appConfig, logger)
+	if appConfig.AutoMigrate {
+		applied, err := database.Migrate(context.Background(), pool)
+		if err != nil {
+			logger.Error("failed to migrate database", logging.FieldError, err)
+			os.Exit(1)
+		}
+		logger.Info(fmt.Sprintf("applied %d database migrations", applied))
+	}
 
 	// setup services
+	servicePool := service.NewPool(pool, service.PoolOptions{AcquireTimeout: appConfig.PostgresAcquireTimeout})
 	scanRepo := repository.NewPostgresScanRepository()
 	authRepo := repository.NewPostgresAuthRepository()
 	agentRepo := repository.NewPostgresAgentRepository()
+	scheduleRepo := repository.NewPostgresScanScheduleRepository()
+	auditRepo := repository.NewPostgresAuditRepository()
+
+	auditService := service.NewAuditService(auditRepo, servicePool)
+	scanService := service.NewScanService(scanRepo, servicePool, service.ScanServiceOptions{
+		UpdateInterval:     appConfig.ScanUpdateInterval,
+		FindingEventWindow: appConfig.FindingEventWindow,
+		MaxAssets:          appConfig.MaxAssets,
+		MaxScanConfigs:     appConfig.MaxScanConfigs,
+		MaxConcurrentScans: appConfig.MaxConcurrentScans,
+		MaxRunDuration:     appConfig.ScanMaxRunDuration,
+		StaleScanAge:       appConfig.StaleScanAge,
+		RequeueStaleScans:  appConfig.RequeueStaleScans,
+		Audit:              auditService,
+	})
+	agentTokenFormat := service.TokenFormat{
+		IDBytes:     appConfig.AgentTokenIDBytes,
+		SecretBytes: appConfig.AgentTokenSecretBytes,
+	}
+	if err := agentTokenFormat.Validate(); err != nil {
+		logger.Error("invalid agent token size", logging.FieldError, err)
+		os.Exit(1)
+	}
 
-	scanService := service.NewScanService(scanRepo, pool)
-	authService := service.NewAuthService(authRepo, agentRepo, pool)
-	agentService := service.NewAgentService(agentRepo, pool)
-	findingService := service.NewFindingService(scanRepo, pool)
+	authService := service.NewAuthService(authRepo, agentRepo, servicePool, service.AuthServiceOptions{
+		AgentTokenFormat: agentTokenFormat,
+		SessionTTL:       appConfig.SessionTTL,
+		RememberMeTTL:    appConfig.RememberMeTTL,
+		Audit:            auditService,
+	})
+	agentService := service.NewAgentService(agentRepo, servicePool, service.AgentServiceOptions{
+		OnlineWindow: appConfig.AgentOnlineWindow,
+		MaxAgents:    appConfig.MaxAgents,
+		TokenFormat:  agentTokenFormat,
+		Audit:        auditService,
+	})
+	findingService := service.NewFindingService(scanRepo, servicePool, scanService)
+	scheduleService := service.NewScheduleService(scheduleRepo, scanRepo, scanService, servicePool)
+
+	// scans abandoned by a crashed instance would otherwise stay queued or running
+	if recovered, err := scanService.RecoverStaleScans(context.Background()); err != nil {
+		logger.Error("failed to recover stale scans", logging.FieldError, err)
+	} else if recovered > 0 {
+		logger.Info(fmt.Sprintf("recovered %d stale scans", recovered))
+	}
 
 	// create initial agent if specified
 	if appConfig.AgentToken != "" {
@@ -85,14 +225,54 @@ func main() {
 		}
 	}
 
+	agentIPAllowlist, err := middleware.NewIPAllowlist(appConfig.AgentIPAllowlist)
+	if err != nil {
+		logger.Error("invalid agent IP allowlist", logging.FieldError, err)
+		os.Exit(1)
+	}
+	trustedProxies, err := middleware.ParseNetworks(appConfig.TrustedProxies)
+	if err != nil {
+		logger.Error("invalid trusted proxies", logging.FieldError, err)
+		os.Exit(1)
+	}
+	agentIPAllowlist.TrustedProxies = trustedProxies
+
+	var oidcProvider service.OIDCProvider
+	if appConfig.OIDCIssuer != "" {
+		oidcProvider, err = service.NewOIDCProvider(context.Background(), service.OIDCOptions{
+			Issuer:       appConfig.OIDCIssuer,
+			ClientID:     appConfig.OIDCClientID,
+			ClientSecret: appConfig.OIDCClientSecret,
+			RedirectURL:  appConfig.OIDCRedirectURL,
+		})
+		if err != nil {
+			logger.Error("failed to set up OIDC login", logging.FieldError, err)
+			os.Exit(1)
+		}
+	}
+
 	// start api server
 	serverOptions := ServerOptions{
-		ListenAddress:  appConfig.ListenAddress,
-		CorsOrigin:     appConfig.CORSOrigin,
-		ScanService:    scanService,
-		AuthService:    authService,
-		AgentService:   agentService,
-		FindingService: findingService,
+		ListenAddress:       appConfig.ListenAddress,
+		CorsOrigin:          appConfig.CORSOrigin,
+		Pool:                pool,
+		ScanService:         scanService,
+		AuthService:         authService,
+		AgentService:        agentService,
+		FindingService:      findingService,
+		AuditService:        auditService,
+		ScheduleService:     scheduleService,
+		SchedulerInterval:   appConfig.SchedulerInterval,
+		OverdueScanInterval: appConfig.OverdueScanInterval,
+		LoginRateLimit:      appConfig.LoginRateLimit,
+		LoginRateWindow:     appConfig.LoginRateWindow,
+		TrustedProxies:      trustedProxies,
+		RequestTimeout:      appConfig.RequestTimeout,
+		IdempotencyTTL:      appConfig.IdempotencyTTL,
+		RequestIDHeader:     appConfig.RequestIDHeader,
+		TrustRequestID:      appConfig.TrustRequestID,
+		AgentIPAllowlist:    agentIPAllowlist,
+		OIDCProvider:        oidcProvider,
 	}
 
 	logger.Debug("allowed CORS origin: " + appConfig.CORSOrigin)
@@ -102,11 +282,26 @@ func main() {
 }
 
 func setupDatabase(appConfig AppConfig, logger *slog.Logger) *pgxpool.Pool {
-	pool, err := pgxpool.New(context.Background(), appConfig.PostgresConnectionString)
+	config, err := pgxpool.ParseConfig(appConfig.PostgresConnectionString)
 	if err != nil {
 		logger.Error("failed to parse database connection string", logging.FieldError, err)
 		os.Exit(1)
 	}
+	if err = applyPoolSettings(config, appConfig); err != nil {
+		logger.Error("invalid database pool settings", logging.FieldError, err)
+		os.Exit(1)
+	}
+	logger.Info("database pool settings",
+		"maxConns", config.MaxConns,
+		"minConns", config.MinConns,
+		"maxConnLifetime", config.MaxConnLifetime.String(),
+		"healthCheckPeriod", config.HealthCheckPeriod.String())
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		logger.Error("failed to create database pool", logging.FieldError, err)
+		os.Exit(1)
+	}
 
 	// try database connection
 	var test string
@@ -119,3 +314,30 @@ func setupDatabase(appConfig AppConfig, logger *slog.Logger) *pgxpool.Pool {
 
 	return pool
 }
+
+// applyPoolSettings overrides the pool settings of config with those set in appConfig.
+func applyPoolSettings(config *pgxpool.Config, appConfig AppConfig) error {
+	if appConfig.DBMaxConns < 0 || appConfig.DBMinConns < 0 {
+		return errors.New("number of connections must not be negative")
+	}
+	if appConfig.DBMaxConnLifetime < 0 || appConfig.DBHealthCheckPeriod < 0 {
+		return errors.New("connection lifetime and health check period must not be negative")
+	}
+
+	if appConfig.DBMaxConns > 0 {
+		config.MaxConns = appConfig.DBMaxConns
+	}
+	if appConfig.DBMinConns > 0 {
+		config.MinConns = appConfig.DBMinConns
+	}
+	if config.MinConns > config.MaxConns {
+		return fmt.Errorf("minimum of %d connections exceeds maximum of %d", config.MinConns, config.MaxConns)
+	}
+	if appConfig.DBMaxConnLifetime > 0 {
+		config.MaxConnLifetime = appConfig.DBMaxConnLifetime
+	}
+	if appConfig.DBHealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = appConfig.DBHealthCheckPeriod
+	}
+	return nil
+}
@@ -5,10 +5,12 @@ import (
 	"cortex/handler"
 	"cortex/logging"
 	"cortex/middleware"
+	"cortex/repository"
 	"cortex/service"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"syscall"
@@ -16,58 +18,126 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/cors"
 )
 
 type ServerOptions struct {
-	ListenAddress  string
-	CorsOrigin     string
+	ListenAddress string
+	CorsOrigin    string
+	// Pool is queried by the readiness probe
+	Pool           *pgxpool.Pool
 	ScanService    service.ScanService
 	AuthService    service.AuthService
 	AgentService   service.AgentService
 	FindingService service.FindingService
+	AuditService   service.AuditService
+	// ScheduleService runs due scan schedules every SchedulerInterval, zero disables running schedules
+	ScheduleService   service.ScheduleService
+	SchedulerInterval time.Duration
+	// OverdueScanInterval is the time between two checks for overdue scans by the ScanService, zero disables them
+	OverdueScanInterval time.Duration
+	// LoginRateLimit is the number of login attempts allowed per source IP within LoginRateWindow
+	LoginRateLimit  int
+	LoginRateWindow time.Duration
+	// TrustedProxies are the networks of proxies whose X-Forwarded-For header is used to determine the client IP
+	TrustedProxies []netip.Prefix
+	// RequestTimeout cancels requests taking longer, except for event streams and exports, zero disables it
+	RequestTimeout time.Duration
+	// IdempotencyTTL is the time responses of creating requests are replayed for retries with the same
+	// Idempotency-Key, zero disables idempotency keys
+	IdempotencyTTL time.Duration
+	// RequestIDHeader is the header carrying the request id, TrustRequestID uses the id sent by the client
+	RequestIDHeader string
+	TrustRequestID  bool
+	// AgentIPAllowlist restricts the addresses agents can send requests from, nil allows all addresses
+	AgentIPAllowlist *middleware.IPAllowlist
+	// OIDCProvider enables the login with an OpenID Connect provider, nil disables it
+	OIDCProvider service.OIDCProvider
 }
 
 type Server struct {
-	ListenAddress  string
-	router         chi.Router
-	corsOrigin     string
-	scanService    service.ScanService
-	authService    service.AuthService
-	agentService   service.AgentService
-	findingService service.FindingService
+	ListenAddress       string
+	router              chi.Router
+	corsOrigin          string
+	pool                *pgxpool.Pool
+	scanService         service.ScanService
+	authService         service.AuthService
+	agentService        service.AgentService
+	findingService      service.FindingService
+	auditService        service.AuditService
+	scheduleService     service.ScheduleService
+	schedulerInterval   time.Duration
+	overdueScanInterval time.Duration
+	loginRateLimit      int
+	loginRateWindow     time.Duration
+	trustedProxies      []netip.Prefix
+	requestTimeout      time.Duration
+	idempotencyTTL      time.Duration
+	requestIDHeader     string
+	trustRequestID      bool
+	agentIPAllowlist    *middleware.IPAllowlist
+	oidcProvider        service.OIDCProvider
 }
 
 func NewServer(opts ServerOptions) *Server {
 	return &Server{
-		ListenAddress:  opts.ListenAddress,
-		router:         chi.NewRouter(),
-		corsOrigin:     opts.CorsOrigin,
-		scanService:    opts.ScanService,
-		authService:    opts.AuthService,
-		agentService:   opts.AgentService,
-		findingService: opts.FindingService,
+		ListenAddress:       opts.ListenAddress,
+		router:              chi.NewRouter(),
+		corsOrigin:          opts.CorsOrigin,
+		pool:                opts.Pool,
+		scanService:         opts.ScanService,
+		authService:         opts.AuthService,
+		agentService:        opts.AgentService,
+		findingService:      opts.FindingService,
+		auditService:        opts.AuditService,
+		scheduleService:     opts.ScheduleService,
+		schedulerInterval:   opts.SchedulerInterval,
+		overdueScanInterval: opts.OverdueScanInterval,
+		loginRateLimit:      opts.LoginRateLimit,
+		loginRateWindow:     opts.LoginRateWindow,
+		trustedProxies:      opts.TrustedProxies,
+		requestTimeout:      opts.RequestTimeout,
+		idempotencyTTL:      opts.IdempotencyTTL,
+		requestIDHeader:     opts.RequestIDHeader,
+		trustRequestID:      opts.TrustRequestID,
+		agentIPAllowlist:    opts.AgentIPAllowlist,
+		oidcProvider:        opts.OIDCProvider,
 	}
 }
 
-func (s *Server) Start() {
-	logger := logging.GetLogger(logging.API)
-
+// registerRoutes sets up the middleware and the routes of the router.
+func (s *Server) registerRoutes() {
 	corsOptions := cors.Options{
 		AllowedOrigins: []string{s.corsOrigin},
 		AllowedMethods: []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "If-None-Match", "If-Modified-Since",
+			middleware.IdempotencyKeyHeader},
+		ExposedHeaders: []string{"ETag", "Last-Modified", middleware.IdempotentReplayedHeader,
+			middleware.RateLimitLimitHeader, middleware.RateLimitRemainingHeader, middleware.RateLimitResetHeader},
 	}
 
 	// register middleware
 	requestIDMiddleware := middleware.NewUUIDv4RequestIDMiddleWare()
+	if s.requestIDHeader != "" {
+		requestIDMiddleware.RequestIDHeader = s.requestIDHeader
+	}
+	requestIDMiddleware.TrustIncoming = s.trustRequestID
 	requestLoggerMiddleware := middleware.NewRequestLoggerMiddleware()
 	authNMiddleware := middleware.NewAuthenticationMiddleware(s.authService)
+	loginRateLimitMiddleware := middleware.NewRateLimitMiddleware(s.loginRateLimit, s.loginRateWindow)
+	loginRateLimitMiddleware.TrustedProxies = s.trustedProxies
+	idempotencyMiddleware := middleware.NewIdempotencyMiddleware(s.idempotencyTTL)
+	// streams and exports run as long as the client reads them
+	requestTimeoutMiddleware := middleware.NewRequestTimeoutMiddleware(s.requestTimeout,
+		"/scans/*/events", "/findings/export", "/assets?format=csv")
 
 	s.router.Use(cors.New(corsOptions).Handler)
 	s.router.Use(middleware.SecurityHeaders())
 	s.router.Use(requestIDMiddleware.OnRequest)
 	s.router.Use(requestLoggerMiddleware.OnRequest)
+	s.router.Use(middleware.NewCompressionMiddleware(middleware.DefaultCompressionMinSize).OnRequest)
+	s.router.Use(requestTimeoutMiddleware.OnRequest)
 
 	s.router.Use(chiMiddleware.AllowContentType("application/json"))
 	s.router.Use(chiMiddleware.Recoverer)
@@ -79,24 +149,45 @@ func (s *Server) Start() {
 	userHandler := handler.NewUserHandler(s.authService)
 	authHandler := handler.NewAuthHandler(s.authService)
 	agentHandler := handler.NewAgentHandler(s.agentService)
-	findingHandler := handler.NewFindingHandler(s.findingService)
+	findingHandler := handler.NewFindingHandler(s.findingService, s.scanService)
+	readinessHandler := handler.NewReadinessHandler(s.pool, s.agentService)
+	scheduleHandler := handler.NewScanScheduleHandler(s.scheduleService)
+	auditHandler := handler.NewAuditHandler(s.auditService)
 
 	// register public routes
 	s.router.Get("/health", handler.Make(handler.HandleHealth))
-	s.router.Post("/auth", handler.Make(authHandler.HandleUsernamePasswordLogin))
+	s.router.Get("/ready", handler.Make(readinessHandler.HandleReady))
+	s.router.Get("/openapi.json", handler.Make(handler.HandleOpenAPI))
+	s.router.With(loginRateLimitMiddleware.OnRequest).
+		Post("/auth", handler.Make(authHandler.HandleUsernamePasswordLogin))
+	s.router.Get("/auth/check", handler.Make(authHandler.HandleCheckToken))
+	if s.oidcProvider != nil {
+		oidcHandler := handler.NewOIDCHandler(s.oidcProvider, s.authService)
+		s.router.Get("/auth/oidc/login", handler.Make(oidcHandler.HandleLogin))
+		s.router.With(loginRateLimitMiddleware.OnRequest).
+			Get("/auth/oidc/callback", handler.Make(oidcHandler.HandleCallback))
+	}
 
 	// authenticated routes
+	requireAdmin := middleware.RequireRole(string(repository.UserRoleAdmin))
 	s.router.Group(func(r chi.Router) {
 		r.Use(authNMiddleware.OnRequest)
+		if s.agentIPAllowlist != nil {
+			r.Use(s.agentIPAllowlist.OnRequest)
+		}
 
 		// asset routes
 		r.Get("/assets", handler.Make(assetHandler.HandleList))
 		r.Get("/assets/{id}", handler.Make(assetHandler.HandleGet))
-		r.Post("/assets", handler.Make(assetHandler.HandleCreate))
+		r.With(idempotencyMiddleware.OnRequest).Post("/assets", handler.Make(assetHandler.HandleCreate))
+		r.Post("/assets/bulk", handler.Make(assetHandler.HandleBulkCreate))
 		r.Put("/assets/{id}", handler.Make(assetHandler.HandleUpdate))
+		r.Patch("/assets/{id}/tags", handler.Make(assetHandler.HandleUpdateTags))
 		r.Delete("/assets/{id}", handler.Make(assetHandler.HandleDelete))
+		r.Post("/assets/{id}/restore", handler.Make(assetHandler.HandleRestore))
 		r.Get("/assets/{id}/findings", handler.Make(assetHandler.HandleListAssetFindings))
 		r.Post("/assets/{id}/findings", handler.Make(assetHandler.HandleCreateFinding))
+		r.Patch("/assets/{id}/findings/{findingId}", handler.Make(assetHandler.HandleUpdateFinding))
 		r.Get("/assets/{id}/history", handler.Make(assetHandler.HandleListAssetHistory))
 
 		// scan config routes
@@ -105,29 +196,58 @@ func (s *Server) Start() {
 		r.Post("/scan-configs", handler.Make(scanConfigHandler.HandleCreate))
 		r.Put("/scan-configs/{id}", handler.Make(scanConfigHandler.HandleUpdate))
 		r.Delete("/scan-configs/{id}", handler.Make(scanConfigHandler.HandleDelete))
+		r.Patch("/scan-configs/{id}/assets", handler.Make(scanConfigHandler.HandleUpdateAssets))
+		r.Get("/scan-configs/{id}/scans", handler.Make(scanConfigHandler.HandleListScans))
+		r.Get("/scan-engines", handler.Make(scanConfigHandler.HandleListEngines))
 
 		// scan routes
 		r.Get("/scans", handler.Make(scanHandler.HandleList))
 		r.Get("/scans/{id}", handler.Make(scanHandler.HandleGet))
-		r.Post("/scans", handler.Make(scanHandler.HandleRun))
+		r.Get("/scans/{id}/assets", handler.Make(scanHandler.HandleListAssets))
+		r.Get("/scans/{id}/events", handler.Make(scanHandler.HandleEvents))
+		r.With(idempotencyMiddleware.OnRequest).Post("/scans", handler.Make(scanHandler.HandleRun))
+		r.Post("/scans/preview-targets", handler.Make(scanHandler.HandlePreviewTargets))
+		r.With(requireAdmin).Post("/scans/bulk-status", handler.Make(scanHandler.HandleBulkStatus))
 		r.Patch("/scans/{id}", handler.Make(scanHandler.HandleUpdate))
+		r.Delete("/scans/{id}", handler.Make(scanHandler.HandleDelete))
+
+		// scan schedule routes
+		r.Get("/scan-schedules", handler.Make(scheduleHandler.HandleList))
+		r.Get("/scan-schedules/{id}", handler.Make(scheduleHandler.HandleGet))
+		r.Post("/scan-schedules", handler.Make(scheduleHandler.HandleCreate))
+		r.Put("/scan-schedules/{id}", handler.Make(scheduleHandler.HandleUpdate))
+		r.Delete("/scan-schedules/{id}", handler.Make(scheduleHandler.HandleDelete))
 
 		// users
-		r.Get("/users", handler.Make(userHandler.HandleListUsers))
-		r.Get("/users/{id}", handler.Make(userHandler.HandleGetUser))
+		r.Post("/users/{id}/password", handler.Make(userHandler.HandleChangePassword))
+		r.Get("/users/{id}/tokens", handler.Make(userHandler.HandleListTokens))
+		r.Delete("/users/{id}/tokens/{tokenId}", handler.Make(userHandler.HandleRevokeToken))
+		r.With(requireAdmin).Get("/users", handler.Make(userHandler.HandleListUsers))
+		r.With(requireAdmin).Get("/users/{id}", handler.Make(userHandler.HandleGetUser))
+		r.With(requireAdmin).Post("/users", handler.Make(userHandler.HandleCreateUser))
+		r.With(requireAdmin).Patch("/users/{id}", handler.Make(userHandler.HandleUpdateUser))
 
 		// agents
 		r.Get("/agents", handler.Make(agentHandler.HandleListAgents))
 		r.Get("/agents/{id}", handler.Make(agentHandler.HandleGetAgent))
-		r.Post("/agents", handler.Make(agentHandler.HandleCreateAgent))
-		r.Patch("/agents/{id}", handler.Make(agentHandler.HandleUpdateAgent))
-		r.Delete("/agents/{id}", handler.Make(agentHandler.HandleDeleteAgent))
+		r.Post("/agents/heartbeat", handler.Make(agentHandler.HandleHeartbeat))
+		r.With(requireAdmin).Post("/agents", handler.Make(agentHandler.HandleCreateAgent))
+		r.With(requireAdmin).Patch("/agents/{id}", handler.Make(agentHandler.HandleUpdateAgent))
+		r.With(requireAdmin).Delete("/agents/{id}", handler.Make(agentHandler.HandleDeleteAgent))
+		r.With(requireAdmin).Post("/agents/{id}/rotate-token", handler.Make(agentHandler.HandleRotateToken))
 
 		// findings
+		r.Get("/findings", handler.Make(findingHandler.HandleList))
+		r.Get("/findings/export", handler.Make(findingHandler.HandleExport))
 		r.Get("/findings/{id}", handler.Make(findingHandler.HandleGet))
+		r.With(requireAdmin).Post("/findings/rehash", handler.Make(findingHandler.HandleRecomputeHashes))
+
+		// audit log
+		r.With(requireAdmin).Get("/audit", handler.Make(auditHandler.HandleList))
 
 		// auth
 		r.Get("/auth", handler.Make(authHandler.HandleValidateToken))
+		r.Post("/auth/refresh", handler.Make(authHandler.HandleRefreshToken))
 	})
 
 	// setup default handlers
@@ -138,6 +258,12 @@ func (s *Server) Start() {
 		handler.RespondError(w, r, http.StatusMethodNotAllowed,
 			fmt.Errorf("method %s not allowed on %s", r.Method, r.URL.Path))
 	})
+}
+
+func (s *Server) Start() {
+	logger := logging.GetLogger(logging.API)
+
+	s.registerRoutes()
 
 	// setup graceful shutdown
 	server := &http.Server{
@@ -147,6 +273,17 @@ func (s *Server) Start() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 	serverCtx, serverStopCtx := context.WithCancel(context.Background())
+
+	// run due scan schedules until the server has shut down
+	if s.scheduleService != nil && s.schedulerInterval > 0 {
+		go s.scheduleService.Run(serverCtx, s.schedulerInterval)
+	}
+
+	// fail scans stuck in running until the server has shut down
+	if s.overdueScanInterval > 0 {
+		go s.scanService.WatchOverdueScans(serverCtx, s.overdueScanInterval)
+	}
+
 	// Listen for syscall signals for the process to interrupt/quit
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
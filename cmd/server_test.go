@@ -0,0 +1,52 @@
+package main
+
+import (
+	"cortex/handler"
+	"cortex/service"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOIDCProvider enables the OIDC routes. Its methods are not called.
+type fakeOIDCProvider struct {
+	service.OIDCProvider
+}
+
+func TestRoutesAreDocumented(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handler.Make(handler.HandleOpenAPI).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var document struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &document))
+
+	s := NewServer(ServerOptions{OIDCProvider: fakeOIDCProvider{}})
+	s.registerRoutes()
+
+	routes := make(map[string]map[string]bool)
+	err := chi.Walk(s.router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		method = strings.ToLower(method)
+		if routes[route] == nil {
+			routes[route] = make(map[string]bool)
+		}
+		routes[route][method] = true
+		assert.Contains(t, document.Paths[route], method, "%s %s is not documented", method, route)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the document does not describe routes the server does not have
+	for path, methods := range document.Paths {
+		for method := range methods {
+			assert.True(t, routes[path][method], "%s %s is documented but not routed", method, path)
+		}
+	}
+}
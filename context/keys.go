@@ -17,6 +17,7 @@ type UserInfoData struct {
 	UserID   string
 	Username string
 	TokenID  string
+	Role     string
 }
 
 type AgentInfoData struct {
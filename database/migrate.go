@@ -0,0 +1,155 @@
+// Package database embeds the database migrations and applies them on startup.
+//
+// Migrations follow the layout of golang-migrate, <version>_<name>.up.sql and <version>_<name>.down.sql, and the
+// applied version is recorded in the same schema_migrations table. Databases migrated with the migrate CLI can
+// therefore be migrated on startup and vice versa.
+package database
+
+import (
+	"context"
+	"cortex/logging"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID identifies the advisory lock held while migrating, so instances starting at the same time do not
+// apply migrations concurrently.
+const migrationLockID = 7261483920
+
+const upSuffix = ".up.sql"
+
+// ErrDirty is returned if a previous migration failed half-way, which has to be resolved manually.
+var ErrDirty = errors.New("database is in a dirty migration state")
+
+// Migration is a single up migration.
+type Migration struct {
+	Version uint64
+	Name    string
+	SQL     string
+}
+
+// Migrations returns the embedded up migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles, "migrations")
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[uint64]string)
+	var migrations []Migration
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), upSuffix) {
+			continue
+		}
+
+		rawVersion, name, found := strings.Cut(strings.TrimSuffix(entry.Name(), upSuffix), "_")
+		version, err := strconv.ParseUint(rawVersion, 10, 64)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, entry.Name())
+		}
+		versions[version] = entry.Name()
+
+		sql, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(sql)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrate applies the embedded migrations newer than the version recorded in schema_migrations and returns the
+// number of applied migrations. Each migration is applied in its own transaction together with its version.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	logger := logging.GetLogger(logging.DataAccess)
+
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return 0, err
+	}
+	defer func() {
+		// the lock is released with the session at the latest, do not fail the migration because of it
+		_, _ = conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockID)
+	}()
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`)
+	if err != nil {
+		return 0, err
+	}
+
+	var current uint64
+	var dirty bool
+	err = conn.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&current, &dirty)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d", ErrDirty, current)
+	}
+
+	applied := 0
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+
+		if err = applyMigration(ctx, conn, migration); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		applied++
+		logger.InfoContext(ctx, "applied migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	return applied, nil
+}
+
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		// without arguments the statements are sent with the simple protocol, which allows several per migration
+		if _, err := tx.Exec(ctx, migration.SQL); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM schema_migrations"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", migration.Version)
+		return err
+	})
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	assert.Equal(t, uint64(20251101075421), migrations[0].Version)
+	assert.Equal(t, "init", migrations[0].Name)
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+		assert.NotEmpty(t, migrations[i].SQL, migrations[i].Name)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/2_second.up.sql":   {Data: []byte("create table b ();")},
+		"migrations/2_second.down.sql": {Data: []byte("drop table b;")},
+		"migrations/1_first.up.sql":    {Data: []byte("create table a ();")},
+		"migrations/1_first.down.sql":  {Data: []byte("drop table a;")},
+	}
+
+	migrations, err := loadMigrations(fsys, "migrations")
+	require.NoError(t, err)
+	assert.Equal(t, []Migration{
+		{Version: 1, Name: "first", SQL: "create table a ();"},
+		{Version: 2, Name: "second", SQL: "create table b ();"},
+	}, migrations)
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{name: "no version", files: fstest.MapFS{"migrations/first.up.sql": {}}},
+		{name: "invalid version", files: fstest.MapFS{"migrations/v1_first.up.sql": {}}},
+		{name: "duplicate version", files: fstest.MapFS{
+			"migrations/1_first.up.sql": {},
+			"migrations/1_other.up.sql": {},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files, "migrations")
+			assert.Error(t, err)
+		})
+	}
+}
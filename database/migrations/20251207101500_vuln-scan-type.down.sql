@@ -0,0 +1 @@
+update scan_configs set type = 'vulnerability' where type = 'vuln';
\ No newline at end of file
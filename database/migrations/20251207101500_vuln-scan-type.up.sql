@@ -0,0 +1 @@
+update scan_configs set type = 'vuln' where type = 'vulnerability';
\ No newline at end of file
@@ -0,0 +1 @@
+alter table scan_configs drop column options;
\ No newline at end of file
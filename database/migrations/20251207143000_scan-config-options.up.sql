@@ -0,0 +1 @@
+alter table scan_configs add column options jsonb not null default '{}';
\ No newline at end of file
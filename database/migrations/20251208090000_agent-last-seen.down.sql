@@ -0,0 +1 @@
+alter table agents drop column last_seen_at;
\ No newline at end of file
@@ -0,0 +1 @@
+alter table agents add column last_seen_at timestamptz;
\ No newline at end of file
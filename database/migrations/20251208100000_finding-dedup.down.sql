@@ -0,0 +1 @@
+drop index if exists asset_findings_asset_id_finding_hash_key;
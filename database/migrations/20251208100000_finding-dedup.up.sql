@@ -0,0 +1,8 @@
+-- keep only the most recent finding of each duplicate group before enforcing uniqueness
+delete from asset_findings a
+    using asset_findings b
+where a.asset_id = b.asset_id
+  and a.finding_hash = b.finding_hash
+  and (a.created_at, a.id) < (b.created_at, b.id);
+
+create unique index asset_findings_asset_id_finding_hash_key on asset_findings (asset_id, finding_hash);
@@ -0,0 +1,2 @@
+alter table asset_findings drop column last_seen;
+alter table asset_findings drop column first_seen;
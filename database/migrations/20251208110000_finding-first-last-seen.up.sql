@@ -0,0 +1,5 @@
+alter table asset_findings add column first_seen timestamptz;
+alter table asset_findings add column last_seen timestamptz;
+update asset_findings set first_seen = created_at, last_seen = created_at;
+alter table asset_findings alter column first_seen set not null;
+alter table asset_findings alter column last_seen set not null;
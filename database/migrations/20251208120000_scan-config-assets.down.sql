@@ -0,0 +1 @@
+drop table if exists scan_config_asset_map;
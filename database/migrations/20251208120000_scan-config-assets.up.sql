@@ -0,0 +1,5 @@
+create table if not exists scan_config_asset_map (
+    scan_config_id uuid not null references scan_configs(id) on delete cascade,
+    asset_id uuid not null references assets(id) on delete cascade,
+    primary key (scan_config_id, asset_id)
+);
@@ -0,0 +1 @@
+drop index if exists users_username_key;
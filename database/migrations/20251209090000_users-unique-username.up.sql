@@ -0,0 +1 @@
+create unique index if not exists users_username_key on users (username);
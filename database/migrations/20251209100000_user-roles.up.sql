@@ -0,0 +1,2 @@
+alter table users add column role varchar(32) not null default 'user';
+update users set role = 'admin' where username = 'admin' and provider = 'local';
@@ -0,0 +1 @@
+alter table scans drop column error;
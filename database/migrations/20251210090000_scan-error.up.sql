@@ -0,0 +1 @@
+alter table scans add column error text not null default '';
@@ -0,0 +1 @@
+alter table asset_findings drop column triage_status;
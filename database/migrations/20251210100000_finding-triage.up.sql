@@ -0,0 +1 @@
+alter table asset_findings add column triage_status varchar(32) not null default 'open';
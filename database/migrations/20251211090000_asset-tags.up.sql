@@ -0,0 +1,8 @@
+create table if not exists asset_tags (
+    asset_id uuid not null references assets(id) on delete cascade,
+    key varchar(50) not null,
+    value varchar(200) not null,
+    primary key (asset_id, key)
+);
+
+create index if not exists asset_tags_key_value_idx on asset_tags(key, value);
@@ -0,0 +1 @@
+drop table if exists scan_schedules;
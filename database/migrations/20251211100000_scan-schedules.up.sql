@@ -0,0 +1,11 @@
+create table if not exists scan_schedules (
+    id uuid primary key,
+    scan_config_id uuid not null references scan_configs(id) on delete cascade,
+    asset_ids uuid[] not null default '{}',
+    cron varchar(100) not null,
+    next_run_at timestamptz not null,
+    last_run_at timestamptz,
+    created_at timestamptz not null
+);
+
+create index if not exists scan_schedules_next_run_at_idx on scan_schedules(next_run_at);
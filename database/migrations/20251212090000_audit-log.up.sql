@@ -0,0 +1,15 @@
+create table if not exists audit_log (
+    id uuid primary key,
+    -- no foreign keys, entries outlive the users and resources they refer to
+    actor_id uuid,
+    action varchar(20) not null,
+    target_type varchar(50) not null,
+    -- tokens and agents are identified by hex ids rather than uuids
+    target_id varchar(100) not null default '',
+    request_id varchar(100) not null default '',
+    created_at timestamptz not null
+);
+
+create index if not exists audit_log_created_at_idx on audit_log(created_at);
+create index if not exists audit_log_actor_id_idx on audit_log(actor_id, created_at);
+create index if not exists audit_log_target_idx on audit_log(target_type, target_id);
@@ -0,0 +1,6 @@
+delete from assets where deleted_at is not null;
+
+drop index if exists assets_endpoint_key;
+alter table assets add constraint assets_endpoint_key unique (endpoint);
+
+alter table assets drop column deleted_at;
@@ -0,0 +1,5 @@
+alter table assets add column deleted_at timestamptz;
+
+-- endpoints of deleted assets can be added again
+alter table assets drop constraint if exists assets_endpoint_key;
+create unique index if not exists assets_endpoint_key on assets(endpoint) where deleted_at is null;
@@ -0,0 +1,4 @@
+alter table scans add column updated_at timestamptz not null default now();
+
+-- existing scans were last changed when they started or finished
+update scans set updated_at = coalesce(scan_end_time, scan_start_time, updated_at);
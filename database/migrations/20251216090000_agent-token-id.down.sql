@@ -0,0 +1,15 @@
+-- agents are identified by their token id again
+alter table asset_findings add column agent_token_id varchar(16);
+update asset_findings f set agent_token_id = a.token_id from agents a where f.agent_id = a.id;
+alter table asset_findings drop column agent_id;
+alter table asset_findings rename column agent_token_id to agent_id;
+
+update audit_log l set target_id = a.token_id from agents a where l.target_type = 'agent' and l.target_id = a.id::text;
+
+alter table agents drop constraint agents_pkey;
+alter table agents drop column id;
+alter table agents rename column token_id to id;
+alter table agents drop constraint agents_token_id_key;
+alter table agents add primary key (id);
+
+alter table asset_findings add constraint asset_findings_agent_id_fkey foreign key (agent_id) references agents(id) on delete cascade;
@@ -0,0 +1,22 @@
+-- agents get a stable uuid, the previous id stays the id of their token
+alter table agents add column token_id varchar(16);
+update agents set token_id = id;
+alter table agents alter column token_id set not null;
+alter table agents add constraint agents_token_id_key unique (token_id);
+
+alter table agents add column uuid uuid not null default gen_random_uuid();
+
+alter table asset_findings add column agent_uuid uuid;
+update asset_findings f set agent_uuid = a.uuid from agents a where f.agent_id = a.id;
+alter table asset_findings drop column agent_id;
+alter table asset_findings rename column agent_uuid to agent_id;
+
+update audit_log l set target_id = a.uuid::text from agents a where l.target_type = 'agent' and l.target_id = a.id;
+
+alter table agents drop constraint agents_pkey;
+alter table agents drop column id;
+alter table agents rename column uuid to id;
+alter table agents alter column id drop default;
+alter table agents add primary key (id);
+
+alter table asset_findings add constraint asset_findings_agent_id_fkey foreign key (agent_id) references agents(id) on delete cascade;
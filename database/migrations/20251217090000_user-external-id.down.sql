@@ -0,0 +1,2 @@
+drop index if exists users_provider_external_id_key;
+alter table users drop column if exists external_id;
@@ -0,0 +1,4 @@
+-- subject of users authenticated by an external identity provider
+alter table users add column if not exists external_id varchar(255);
+create unique index if not exists users_provider_external_id_key on users (provider, external_id)
+    where external_id is not null;
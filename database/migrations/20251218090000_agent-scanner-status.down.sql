@@ -0,0 +1,2 @@
+alter table agents drop column scanner_error;
+alter table agents drop column scanner_capability;
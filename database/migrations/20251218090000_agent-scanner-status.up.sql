@@ -0,0 +1,2 @@
+alter table agents add column scanner_capability text not null default '';
+alter table agents add column scanner_error text not null default '';
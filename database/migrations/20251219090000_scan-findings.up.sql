@@ -0,0 +1,18 @@
+create table if not exists scan_findings (
+    scan_id uuid not null references scans(id) on delete cascade,
+    finding_id uuid not null references asset_findings(id) on delete cascade,
+    primary key (scan_id, finding_id)
+);
+
+create index if not exists scan_findings_finding_id_idx on scan_findings(finding_id);
+
+-- earlier sightings are not recorded, attribute findings to the scan during which they have last been seen
+insert into scan_findings (scan_id, finding_id)
+select s.id, f.id
+from scans s
+inner join scan_asset_map sam on s.id = sam.scan_id
+inner join asset_findings f on f.asset_id = sam.asset_id
+where s.scan_start_time is not null
+  and f.last_seen >= s.scan_start_time
+  and f.last_seen <= coalesce(s.scan_end_time, now())
+on conflict do nothing;
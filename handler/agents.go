@@ -1,8 +1,10 @@
 package handler
 
 import (
+	cortexContext "cortex/context"
 	"cortex/repository"
 	"cortex/service"
+	"errors"
 	"net/http"
 )
 
@@ -14,11 +16,32 @@ type updateAgentRequestBody struct {
 	Name string `json:"name"`
 }
 
-type createAgentResponse struct {
+// agentTokenResponse returns the token of an agent, which is only available when it is created or rotated.
+type agentTokenResponse struct {
 	Agent *repository.Agent `json:"agent"`
 	Token string            `json:"token"`
 }
 
+// heartbeatRequestBody reports the result of the scanner self-check of an agent. Agents that leave both fields empty or
+// send no body keep their stored scanner status.
+type heartbeatRequestBody struct {
+	ScannerCapability string `json:"scannerCapability"`
+	// ScannerError is the reason the scan runner failed to initialize, empty if it is functional.
+	ScannerError string `json:"scannerError"`
+}
+
+type heartbeatResponse struct {
+	ServerTime int64 `json:"serverTime"`
+}
+
+func scannerCapabilityValues() []string {
+	values := make([]string, 0, len(repository.ScannerCapabilities))
+	for _, capability := range repository.ScannerCapabilities {
+		values = append(values, string(capability))
+	}
+	return values
+}
+
 type AgentHandler struct {
 	agentService service.AgentService
 }
@@ -30,12 +53,45 @@ func NewAgentHandler(agentService service.AgentService) *AgentHandler {
 }
 
 func (h AgentHandler) HandleListAgents(w http.ResponseWriter, r *http.Request) error {
-	agents, err := h.agentService.ListAgents(r.Context())
+	query := r.URL.Query()
+
+	search, err := ValidateString(query.Get("search"), MaxLength(MaxNameLength)).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	active, err := ValidateString(query.Get("active"), In("", "true", "false")).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	limit, err := ValidateQueryInt(r, "limit", 0, Range(1, maxPageSize))
 	if err != nil {
 		return WrapError(err)
 	}
 
-	if err = RespondMany(w, r, agents); err != nil {
+	offset, err := ValidateQueryInt(r, "offset", 0, Min(0))
+	if err != nil {
+		return WrapError(err)
+	}
+
+	page := Page{Limit: limit, Offset: offset}
+	opts := service.ListAgentsOptions{
+		Search: search,
+		Limit:  page.fetchLimit(),
+		Offset: offset,
+	}
+	if active != "" {
+		isActive := active == "true"
+		opts.Active = &isActive
+	}
+
+	agents, err := h.agentService.ListAgents(r.Context(), opts)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondPage(w, r, agents, page); err != nil {
 		return WrapError(err)
 	}
 	return nil
@@ -61,7 +117,7 @@ func (h AgentHandler) HandleGetAgent(w http.ResponseWriter, r *http.Request) err
 func (h AgentHandler) HandleCreateAgent(w http.ResponseWriter, r *http.Request) error {
 	var requestBody createAgentRequestBody
 	err := ValidateRequestBody(r, &requestBody,
-		Field(&requestBody.Name, Required(), Length(1, 255)),
+		Field(&requestBody.Name, NameRules()...),
 	)
 	if err != nil {
 		return WrapError(err)
@@ -69,10 +125,13 @@ func (h AgentHandler) HandleCreateAgent(w http.ResponseWriter, r *http.Request)
 
 	agent, token, err := h.agentService.CreateAgent(r.Context(), requestBody.Name)
 	if err != nil {
+		if errors.Is(err, service.ErrLimitExceeded) {
+			return Forbidden(err.Error())
+		}
 		return WrapError(err)
 	}
 
-	response := createAgentResponse{
+	response := agentTokenResponse{
 		Agent: agent,
 		Token: token,
 	}
@@ -91,7 +150,7 @@ func (h AgentHandler) HandleUpdateAgent(w http.ResponseWriter, r *http.Request)
 
 	var requestBody updateAgentRequestBody
 	err = ValidateRequestBody(r, &requestBody,
-		Field(&requestBody.Name, Required(), Length(1, 255)),
+		Field(&requestBody.Name, NameRules()...),
 	)
 	if err != nil {
 		return WrapError(err)
@@ -124,3 +183,56 @@ func (h AgentHandler) HandleDeleteAgent(w http.ResponseWriter, r *http.Request)
 	}
 	return nil
 }
+
+// HandleRotateToken replaces the token of an agent. The previous token is rejected from now on.
+func (h AgentHandler) HandleRotateToken(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	agent, token, err := h.agentService.RotateToken(r.Context(), id)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, agentTokenResponse{Agent: agent, Token: token}); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h AgentHandler) HandleHeartbeat(w http.ResponseWriter, r *http.Request) error {
+	agentInfo, err := cortexContext.AgentInfo(r.Context())
+	if err != nil {
+		return Forbidden("heartbeats require agent authentication")
+	}
+
+	// the body is optional, agents without a scan runner status send none
+	var requestBody heartbeatRequestBody
+	err = ValidateOptionalRequestBody(r, &requestBody,
+		Field(&requestBody.ScannerCapability, In(append(scannerCapabilityValues(), "")...)),
+		Field(&requestBody.ScannerError, MaxLength(service.MaxScanErrorLength)),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	var scanner *repository.ScannerStatus
+	if requestBody.ScannerCapability != "" || requestBody.ScannerError != "" {
+		scanner = &repository.ScannerStatus{
+			Capability: repository.ScannerCapability(requestBody.ScannerCapability),
+			Error:      requestBody.ScannerError,
+		}
+	}
+
+	serverTime, err := h.agentService.Heartbeat(r.Context(), agentInfo.AgentID, scanner)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, heartbeatResponse{ServerTime: serverTime.Unix()}); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
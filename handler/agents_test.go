@@ -0,0 +1,279 @@
+package handler_test
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"cortex/test"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAgentService struct {
+	mock.Mock
+}
+
+func (m *MockAgentService) ListAgents(ctx context.Context, opts service.ListAgentsOptions) ([]repository.Agent, error) {
+	args := m.Called(ctx, opts)
+	return args.Get(0).([]repository.Agent), args.Error(1)
+}
+
+func (m *MockAgentService) GetAgent(ctx context.Context, id string) (*repository.Agent, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*repository.Agent), args.Error(1)
+}
+
+func (m *MockAgentService) CreateAgent(ctx context.Context, name string) (*repository.Agent, string, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(*repository.Agent), args.String(1), args.Error(2)
+}
+
+func (m *MockAgentService) CreateAgentWithToken(ctx context.Context, tokenPlain string, name string) (*repository.Agent, error) {
+	args := m.Called(ctx, tokenPlain, name)
+	return args.Get(0).(*repository.Agent), args.Error(1)
+}
+
+func (m *MockAgentService) UpdateAgent(ctx context.Context, id string, name string) (*repository.Agent, error) {
+	args := m.Called(ctx, id, name)
+	return args.Get(0).(*repository.Agent), args.Error(1)
+}
+
+func (m *MockAgentService) DeleteAgent(ctx context.Context, id string) (*repository.Agent, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*repository.Agent), args.Error(1)
+}
+
+func (m *MockAgentService) RotateToken(ctx context.Context, id string) (*repository.Agent, string, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*repository.Agent), args.String(1), args.Error(2)
+}
+
+func (m *MockAgentService) Heartbeat(ctx context.Context, id string, scanner *repository.ScannerStatus) (time.Time, error) {
+	args := m.Called(ctx, id, scanner)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockAgentService) ScannerHealth(ctx context.Context) (*service.ScannerHealth, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*service.ScannerHealth), args.Error(1)
+}
+
+func TestHeartbeat_Agent(t *testing.T) {
+	mockService := new(MockAgentService)
+	h := handler.NewAgentHandler(mockService)
+
+	mockService.On("Heartbeat", mock.Anything, "a1b2c3d4", (*repository.ScannerStatus)(nil)).
+		Return(time.Unix(1700000000, 0), nil)
+
+	runner := test.NewTestRunner(h.HandleHeartbeat)
+	runner.WithContextValue(cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "a1b2c3d4"}).
+		Run(t).
+		ExpectNoError().
+		ExpectStatusCode(http.StatusOK)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestHeartbeat_ScannerStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *repository.ScannerStatus
+	}{
+		{name: "syn", body: `{"scannerCapability":"syn"}`,
+			want: &repository.ScannerStatus{Capability: repository.ScannerCapabilitySYN}},
+		{name: "runner failed", body: `{"scannerCapability":"connect","scannerError":"permission denied"}`,
+			want: &repository.ScannerStatus{Capability: repository.ScannerCapabilityConnect, Error: "permission denied"}},
+		{name: "not reported", body: `{}`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAgentService)
+			h := handler.NewAgentHandler(mockService)
+
+			mockService.On("Heartbeat", mock.Anything, "a1b2c3d4", tt.want).Return(time.Unix(1700000000, 0), nil)
+
+			runner := test.NewTestRunner(h.HandleHeartbeat).WithBodyString(tt.body)
+			runner.WithContextValue(cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "a1b2c3d4"}).
+				Run(t).
+				ExpectNoError()
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHeartbeat_Chunked(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *repository.ScannerStatus
+	}{
+		{name: "empty", body: "", want: nil},
+		{name: "scanner status", body: `{"scannerCapability":"syn"}`,
+			want: &repository.ScannerStatus{Capability: repository.ScannerCapabilitySYN}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAgentService)
+			h := handler.NewAgentHandler(mockService)
+
+			mockService.On("Heartbeat", mock.Anything, "a1b2c3d4", tt.want).Return(time.Unix(1700000000, 0), nil)
+
+			runner := test.NewTestRunner(h.HandleHeartbeat).WithChunkedBodyString(tt.body)
+			runner.WithContextValue(cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "a1b2c3d4"}).
+				Run(t).
+				ExpectNoError().
+				ExpectStatusCode(http.StatusOK)
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHeartbeat_InvalidScannerCapability(t *testing.T) {
+	mockService := new(MockAgentService)
+	h := handler.NewAgentHandler(mockService)
+
+	runner := test.NewTestRunner(h.HandleHeartbeat).WithBodyString(`{"scannerCapability":"udp"}`)
+	runner.WithContextValue(cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "a1b2c3d4"}).
+		Run(t).
+		ExpectAPIError(http.StatusBadRequest)
+
+	mockService.AssertNotCalled(t, "Heartbeat", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHeartbeat_User(t *testing.T) {
+	mockService := new(MockAgentService)
+	h := handler.NewAgentHandler(mockService)
+
+	runner := test.NewTestRunner(h.HandleHeartbeat)
+	runner.WithContextValue(cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"}).
+		Run(t).
+		ExpectAPIError(http.StatusForbidden)
+
+	mockService.AssertNotCalled(t, "Heartbeat", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListAgents_Online(t *testing.T) {
+	mockService := new(MockAgentService)
+	h := handler.NewAgentHandler(mockService)
+
+	agents := []repository.Agent{
+		{ID: "a1b2c3d4", Name: "online", CreatedAt: time.Unix(1700000000, 0),
+			LastSeenAt: pgtype.Timestamp{Time: time.Unix(1700000100, 0), Valid: true}, Online: true},
+		{ID: "e5f6a7b8", Name: "never seen", CreatedAt: time.Unix(1700000000, 0)},
+	}
+	mockService.On("ListAgents", mock.Anything, service.ListAgentsOptions{}).Return(agents, nil)
+
+	runner := test.NewTestRunner(h.HandleListAgents)
+	res := runner.Run(t).ExpectNoError()
+
+	body := res.RR.Body.String()
+	assert.Contains(t, body, `"lastSeenAt":1700000100,"online":true`)
+	assert.Contains(t, body, `"lastSeenAt":0,"online":false`)
+}
+
+func TestListAgents_Search(t *testing.T) {
+	mockService := new(MockAgentService)
+	h := handler.NewAgentHandler(mockService)
+
+	agents := []repository.Agent{{ID: "a1b2c3d4", Name: "scanner-eu", CreatedAt: time.Unix(1700000000, 0)}}
+	// one agent more than the page holds is fetched to tell whether there is a next page
+	mockService.On("ListAgents", mock.Anything, service.ListAgentsOptions{Search: "eu", Limit: 11, Offset: 20}).
+		Return(agents, nil)
+
+	runner := test.NewTestRunner(h.HandleListAgents).
+		WithQuery("search", "eu").
+		WithQuery("limit", "10").
+		WithQuery("offset", "20")
+	res := runner.Run(t).ExpectNoError()
+
+	assert.Contains(t, res.RR.Body.String(), `"name":"scanner-eu"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestListAgents_Active(t *testing.T) {
+	tests := []struct {
+		name   string
+		active string
+		want   bool
+	}{
+		{name: "active", active: "true", want: true},
+		{name: "inactive", active: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAgentService)
+			h := handler.NewAgentHandler(mockService)
+
+			mockService.On("ListAgents", mock.Anything, mock.MatchedBy(func(opts service.ListAgentsOptions) bool {
+				return opts.Active != nil && *opts.Active == tt.want
+			})).Return([]repository.Agent{}, nil)
+
+			runner := test.NewTestRunner(h.HandleListAgents).WithQuery("active", tt.active)
+			runner.Run(t).ExpectNoError()
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListAgents_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		param string
+		value string
+	}{
+		{name: "active", param: "active", value: "yes"},
+		{name: "limit not a number", param: "limit", value: "ten"},
+		{name: "limit too small", param: "limit", value: "0"},
+		{name: "limit too large", param: "limit", value: "1001"},
+		{name: "negative offset", param: "offset", value: "-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAgentService)
+			h := handler.NewAgentHandler(mockService)
+
+			runner := test.NewTestRunner(h.HandleListAgents).WithQuery(tt.param, tt.value)
+			runner.Run(t).ExpectAPIError(http.StatusBadRequest)
+
+			mockService.AssertNotCalled(t, "ListAgents", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRotateAgentToken(t *testing.T) {
+	const agentID = "4f2a8c1e-7b3d-4e9a-a5c6-1d2e3f4a5b6c"
+	mockService := new(MockAgentService)
+	h := handler.NewAgentHandler(mockService)
+	mockService.On("RotateToken", mock.Anything, agentID).
+		Return(&repository.Agent{ID: agentID, Name: "scanner-1"}, "a1b2c3d4.00112233445566778899aabbccddeeff", nil)
+
+	res := test.NewTestRunner(h.HandleRotateToken).WithPath("id", agentID).Run(t).ExpectNoError()
+
+	assert.Equal(t, http.StatusOK, res.RR.Code)
+	assert.Contains(t, res.RR.Body.String(), `"token":"a1b2c3d4.00112233445566778899aabbccddeeff"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestRotateAgentToken_NotFound(t *testing.T) {
+	const agentID = "4f2a8c1e-7b3d-4e9a-a5c6-1d2e3f4a5b6c"
+	mockService := new(MockAgentService)
+	h := handler.NewAgentHandler(mockService)
+	mockService.On("RotateToken", mock.Anything, agentID).Return((*repository.Agent)(nil), "", repository.ErrNotFound)
+
+	test.NewTestRunner(h.HandleRotateToken).WithPath("id", agentID).Run(t).ExpectAPIError(http.StatusNotFound)
+}
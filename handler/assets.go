@@ -1,25 +1,110 @@
 package handler
 
 import (
+	cortexContext "cortex/context"
 	"cortex/repository"
 	"cortex/service"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type createAssetRequestBody struct {
 	Endpoint string `json:"endpoint"`
 }
 
+// maxBulkAssets is the maximum number of assets created by a single bulk request.
+const maxBulkAssets = 1000
+
+type createAssetsRequestBody struct {
+	Endpoints []string `json:"endpoints"`
+}
+
 type updateAssetRequestBody struct {
 	ID       string `json:"id"`
 	Endpoint string `json:"endpoint"`
 }
 
+// maxAssetTags is the maximum number of tags of an asset.
+const maxAssetTags = 50
+
+type updateAssetTagsRequestBody struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// tagKeyRules returns the rules of asset tag keys. Keys cannot contain colons, which separate key and value in the
+// tag filter of HandleList.
+func tagKeyRules() []ValidationRule {
+	return []ValidationRule{Length(1, 50), Regex("^[^:]*$")}
+}
+
+// tagValueRules returns the rules of asset tag values.
+func tagValueRules() []ValidationRule {
+	return []ValidationRule{Length(1, 200)}
+}
+
+// parseTagFilter parses tag query parameters of the form key:value. An asset has to match all of them.
+func parseTagFilter(params []string) (map[string]string, error) {
+	tags := make(map[string]string, len(params))
+	for _, param := range params {
+		key, value, found := strings.Cut(param, ":")
+		if !found {
+			return nil, NewValidationError(fmt.Sprintf("tag '%s': must be of the form key:value", param))
+		}
+		if _, err := ValidateString(key, tagKeyRules()...).Validate(); err != nil {
+			return nil, NewValidationError(fmt.Sprintf("tag '%s': key %s", param, err.(ValidationError).Message))
+		}
+		if _, err := ValidateString(value, tagValueRules()...).Validate(); err != nil {
+			return nil, NewValidationError(fmt.Sprintf("tag '%s': value %s", param, err.(ValidationError).Message))
+		}
+		if _, ok := tags[key]; ok {
+			return nil, NewValidationError(fmt.Sprintf("tag '%s': key given more than once", key))
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
 type createAssetFindingBody struct {
 	Type string                 `json:"type"`
 	Data map[string]interface{} `json:"data"`
 }
 
+type updateAssetFindingBody struct {
+	TriageStatus string `json:"triageStatus"`
+}
+
+// triageStatusAll selects findings of any triage status when listing findings.
+const triageStatusAll = "all"
+
+func triageStatusValues() []string {
+	values := make([]string, 0, len(repository.TriageStatuses))
+	for _, status := range repository.TriageStatuses {
+		values = append(values, string(status))
+	}
+	return values
+}
+
+// parseTriageStatusFilter parses the ?triageStatus= filter of finding lists. Without a status, nil is returned and
+// findings that need no attention are left out.
+func parseTriageStatusFilter(value string) ([]repository.TriageStatus, error) {
+	status, err := ValidateString(value, In(append(triageStatusValues(), "", triageStatusAll)...)).Validate()
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case "":
+		return nil, nil
+	case triageStatusAll:
+		return repository.TriageStatuses, nil
+	default:
+		return []repository.TriageStatus{repository.TriageStatus(status)}, nil
+	}
+}
+
 type AssetHandler struct {
 	scanService    service.ScanService
 	findingService service.FindingService
@@ -32,13 +117,48 @@ func NewAssetHandler(scanService service.ScanService, findingService service.Fin
 	}
 }
 
+// assetCSVColumns are the columns of the CSV export of assets, the last discovery is empty for assets that have never
+// been scanned.
+var assetCSVColumns = []csvColumn[repository.ScanAssetWithStats]{
+	{name: "endpoint", value: func(a repository.ScanAssetWithStats) string { return a.Endpoint }},
+	{name: "discoveredPortsCount", value: func(a repository.ScanAssetWithStats) string {
+		return strconv.Itoa(a.Stats.DiscoveredPortsCount)
+	}},
+	{name: "lastDiscovery", value: func(a repository.ScanAssetWithStats) string {
+		if a.Stats.LastDiscovery.IsZero() {
+			return ""
+		}
+		return a.Stats.LastDiscovery.UTC().Format(time.RFC3339)
+	}},
+}
+
+// HandleList responds with the assets. With format=csv, the assets and their stats are exported as CSV attachment.
 func (h AssetHandler) HandleList(w http.ResponseWriter, r *http.Request) error {
 	// TODO: schema validation for query
 	statsRequested := r.URL.Query().Get("stats") == "true"
 
-	if statsRequested {
+	format, err := requestedFormat(r)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	tags, err := parseTagFilter(r.URL.Query()["tag"])
+	if err != nil {
+		return WrapError(err)
+	}
+	opts := service.ListAssetsOptions{Tags: tags}
+
+	if format == formatCSV {
+		header, row, err := requestedColumns(r, assetCSVColumns)
+		if err != nil {
+			return WrapError(err)
+		}
+
+		// the export always contains the stats columns
+		return h.exportCSV(w, r, opts, header, row)
+	} else if statsRequested {
 		// respond with stats
-		assets, err := h.scanService.ListAssetsWithStats(r.Context())
+		assets, err := h.scanService.ListAssetsWithStats(r.Context(), opts)
 		if err != nil {
 			return WrapError(err)
 		}
@@ -49,7 +169,7 @@ func (h AssetHandler) HandleList(w http.ResponseWriter, r *http.Request) error {
 
 	} else {
 		// plain asset
-		assets, err := h.scanService.ListAssets(r.Context())
+		assets, err := h.scanService.ListAssets(r.Context(), opts)
 		if err != nil {
 			return WrapError(err)
 		}
@@ -62,6 +182,36 @@ func (h AssetHandler) HandleList(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// exportCSV writes the assets as CSV attachment as they are read from the database.
+func (h AssetHandler) exportCSV(w http.ResponseWriter, r *http.Request, opts service.ListAssetsOptions, header []string,
+	row func(repository.ScanAssetWithStats) []string) error {
+	var response *CSVResponse
+	err := h.scanService.EachAssetWithStats(r.Context(), opts, func(asset repository.ScanAssetWithStats) error {
+		if response == nil {
+			var headerErr error
+			if response, headerErr = NewCSVResponse(w, "assets.csv", header); headerErr != nil {
+				return headerErr
+			}
+		}
+		return response.Write(row(asset))
+	})
+	if err != nil {
+		if response == nil {
+			return WrapError(err)
+		}
+		// the attachment has been started, abort the response so the client does not take the truncated export for a
+		// complete one
+		panic(http.ErrAbortHandler)
+	}
+
+	if response == nil {
+		if response, err = NewCSVResponse(w, "assets.csv", header); err != nil {
+			return err
+		}
+	}
+	return response.Close()
+}
+
 func (h AssetHandler) HandleGet(w http.ResponseWriter, r *http.Request) error {
 	id, err := ValidateParam(r, "id")
 	if err != nil {
@@ -71,6 +221,17 @@ func (h AssetHandler) HandleGet(w http.ResponseWriter, r *http.Request) error {
 	// TODO: schema validation for query
 	statsRequested := r.URL.Query().Get("stats") == "true"
 
+	withDeleted, err := includeDeleted(r)
+	if err != nil {
+		return WrapError(err)
+	}
+	// deleted assets are only resolved without stats
+	if withDeleted && statsRequested {
+		return WrapError(NewStructValidationError(map[string]error{
+			includeDeletedQueryParam: NewValidationError("cannot be combined with stats"),
+		}))
+	}
+
 	if statsRequested {
 		// respond with stats
 		asset, err := h.scanService.GetAssetWithStats(r.Context(), id)
@@ -83,7 +244,7 @@ func (h AssetHandler) HandleGet(w http.ResponseWriter, r *http.Request) error {
 		}
 	} else {
 		// plain asset
-		asset, err := h.scanService.GetAsset(r.Context(), id)
+		asset, err := h.scanService.GetAsset(r.Context(), id, service.GetAssetOptions{IncludeDeleted: withDeleted})
 		if err != nil {
 			return WrapError(err)
 		}
@@ -96,10 +257,27 @@ func (h AssetHandler) HandleGet(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// endpointRules returns the rules of asset endpoints: not blank, without surrounding whitespace, at most
+// MaxEndpointLength characters long and a valid endpoint.
+func endpointRules() []ValidationRule {
+	return []ValidationRule{NotBlank(), Trimmed(), MaxLength(MaxEndpointLength), endpoint()}
+}
+
+// endpoint validates that an asset endpoint is a target the scanners accept: a hostname, an IP address or a CIDR range.
+func endpoint() ValidationRule {
+	host, cidr := Host(), CIDR()
+	return func(value any) error {
+		if host(value) == nil || cidr(value) == nil {
+			return nil
+		}
+		return NewValidationError("must be a hostname, IP address or CIDR range")
+	}
+}
+
 func (h AssetHandler) HandleCreate(w http.ResponseWriter, r *http.Request) error {
 	var requestBody createAssetRequestBody
 	err := ValidateRequestBody(r, &requestBody,
-		Field(&requestBody.Endpoint, Required(), Length(1, 2048)),
+		Field(&requestBody.Endpoint, endpointRules()...),
 	)
 	if err != nil {
 		return WrapError(err)
@@ -107,6 +285,9 @@ func (h AssetHandler) HandleCreate(w http.ResponseWriter, r *http.Request) error
 
 	asset, err := h.scanService.CreateAsset(r.Context(), requestBody.Endpoint)
 	if err != nil {
+		if errors.Is(err, service.ErrLimitExceeded) {
+			return Forbidden(err.Error())
+		}
 		return WrapError(err)
 	}
 
@@ -116,6 +297,31 @@ func (h AssetHandler) HandleCreate(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
+// HandleBulkCreate creates multiple assets and responds with the created assets and the skipped endpoints.
+func (h AssetHandler) HandleBulkCreate(w http.ResponseWriter, r *http.Request) error {
+	var requestBody createAssetsRequestBody
+	err := ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.Endpoints, MinItems(1), MaxItems(maxBulkAssets),
+			Each(endpointRules()...)),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	result, err := h.scanService.CreateAssets(r.Context(), requestBody.Endpoints)
+	if err != nil {
+		if errors.Is(err, service.ErrLimitExceeded) {
+			return Forbidden(err.Error())
+		}
+		return WrapError(err)
+	}
+
+	if err = RespondOneCreated(w, r, result); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
 func (h AssetHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) error {
 	id, err := ValidateParam(r, "id")
 	if err != nil {
@@ -125,7 +331,7 @@ func (h AssetHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) error
 	var requestBody updateAssetRequestBody
 	err = ValidateRequestBody(r, &requestBody,
 		Field(&requestBody.ID, UUID()),
-		Field(&requestBody.Endpoint, Required(), Length(1, 2048)),
+		Field(&requestBody.Endpoint, endpointRules()...),
 	)
 	if err != nil {
 		return WrapError(err)
@@ -142,16 +348,103 @@ func (h AssetHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
+// HandleUpdateTags replaces the tags of an asset. An empty map removes all tags.
+func (h AssetHandler) HandleUpdateTags(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	var requestBody updateAssetTagsRequestBody
+	err = ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.Tags, MaxItems(maxAssetTags),
+			Keys(tagKeyRules()...), Values(tagValueRules()...)),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	asset, err := h.scanService.SetAssetTags(r.Context(), id, requestBody.Tags)
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound("asset", id)
+	}
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, asset); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+// includeDeletedQueryParam makes views of an asset or finding resolve deleted assets, e.g. ?includeDeleted=true.
+const includeDeletedQueryParam = "includeDeleted"
+
+// includeDeleted reports whether deleted assets are requested with includeDeletedQueryParam. Only admins may request
+// them.
+func includeDeleted(r *http.Request) (bool, error) {
+	value, err := ValidateString(r.URL.Query().Get(includeDeletedQueryParam), In("", "true", "false")).Validate()
+	if err != nil || value != "true" {
+		return false, err
+	}
+	userInfo, err := cortexContext.UserInfo(r.Context())
+	if err != nil || userInfo.Role != string(repository.UserRoleAdmin) {
+		return false, Forbidden(fmt.Sprintf("role %s required to include deleted assets", repository.UserRoleAdmin))
+	}
+	return true, nil
+}
+
 func (h AssetHandler) HandleDelete(w http.ResponseWriter, r *http.Request) error {
 	id, err := ValidateParam(r, "id")
 	if err != nil {
 		return WrapError(err)
 	}
 
-	asset, err := h.scanService.DeleteAsset(r.Context(), id)
+	hard, err := ValidateString(r.URL.Query().Get("hard"), In("", "true", "false")).Validate()
 	if err != nil {
 		return WrapError(err)
 	}
+	// deleted assets can be restored, only admins may remove them with their findings and history
+	permanent := hard == "true"
+	if permanent {
+		userInfo, infoErr := cortexContext.UserInfo(r.Context())
+		if infoErr != nil || userInfo.Role != string(repository.UserRoleAdmin) {
+			return Forbidden(fmt.Sprintf("role %s required for permanent deletion", repository.UserRoleAdmin))
+		}
+	}
+
+	asset, err := h.scanService.DeleteAsset(r.Context(), id, service.DeleteAssetOptions{Permanent: permanent})
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, asset); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+// HandleRestore restores a deleted asset.
+func (h AssetHandler) HandleRestore(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	asset, err := h.scanService.RestoreAsset(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFound("deleted asset", id)
+		}
+		if errors.Is(err, repository.ErrUniqueViolation) {
+			return Conflict(fmt.Sprintf("endpoint of asset %s is used by another asset", id))
+		}
+		if errors.Is(err, service.ErrLimitExceeded) {
+			return Forbidden(err.Error())
+		}
+		return WrapError(err)
+	}
 
 	if err = RespondOne(w, r, asset); err != nil {
 		return WrapError(err)
@@ -165,7 +458,13 @@ func (h AssetHandler) HandleListAssetFindings(w http.ResponseWriter, r *http.Req
 		return WrapError(err)
 	}
 
-	results, err := h.scanService.ListAssetFindings(r.Context(), assetId)
+	triageStatuses, err := parseTriageStatusFilter(r.URL.Query().Get("triageStatus"))
+	if err != nil {
+		return WrapError(err)
+	}
+
+	results, err := h.scanService.ListAssetFindings(r.Context(), assetId,
+		service.ListFindingsOptions{TriageStatuses: triageStatuses})
 	if err != nil {
 		return WrapError(err)
 	}
@@ -184,7 +483,7 @@ func (h AssetHandler) HandleCreateFinding(w http.ResponseWriter, r *http.Request
 
 	var requestBody createAssetFindingBody
 	err = ValidateRequestBody(r, &requestBody,
-		Field(&requestBody.Type, Required(), Length(1, AnyLength)),
+		Field(&requestBody.Type, Required(), In(service.FindingTypeNames()...)),
 		Field(&requestBody.Data, Required()),
 	)
 	if err != nil {
@@ -192,7 +491,7 @@ func (h AssetHandler) HandleCreateFinding(w http.ResponseWriter, r *http.Request
 	}
 
 	// check if asset exists
-	_, err = h.scanService.GetAsset(r.Context(), assetId)
+	_, err = h.scanService.GetAsset(r.Context(), assetId, service.GetAssetOptions{})
 	if err != nil {
 		return WrapError(err)
 	}
@@ -203,6 +502,9 @@ func (h AssetHandler) HandleCreateFinding(w http.ResponseWriter, r *http.Request
 		Data:    requestBody.Data,
 	})
 
+	if errors.Is(err, service.ErrInvalidFinding) {
+		return invalidFindingError(err)
+	}
 	if err != nil {
 		return WrapError(err)
 	}
@@ -214,12 +516,82 @@ func (h AssetHandler) HandleCreateFinding(w http.ResponseWriter, r *http.Request
 	return nil
 }
 
+// invalidFindingError responds with 400 for findings rejected by the finding service, listing the invalid data fields
+// by their path if known.
+func invalidFindingError(err error) APIError {
+	fieldErrs := service.FindingFieldErrors(err)
+	if len(fieldErrs) == 0 {
+		return APIError{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+	}
+
+	fields := make(map[string]error, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		fields[fieldErr.Path] = NewValidationError(fieldErr.Message)
+	}
+	return APIError{
+		StatusCode: http.StatusBadRequest,
+		Message:    err.Error(),
+		Err:        NewStructValidationError(fields),
+	}
+}
+
+// HandleUpdateFinding sets the triage status of a finding of an asset.
+func (h AssetHandler) HandleUpdateFinding(w http.ResponseWriter, r *http.Request) error {
+	assetId, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+	findingId, err := ValidateParam(r, "findingId")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	var requestBody updateAssetFindingBody
+	err = ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.TriageStatus, Required(), In(triageStatusValues()...)),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	finding, err := h.findingService.UpdateFindingTriage(r.Context(), assetId, findingId,
+		repository.TriageStatus(requestBody.TriageStatus))
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound("finding", findingId)
+	}
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, finding); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
 func (h AssetHandler) HandleListAssetHistory(w http.ResponseWriter, r *http.Request) error {
 	assetId, err := ValidateParam(r, "id")
 	if err != nil {
 		return WrapError(err)
 	}
 
+	withDeleted, err := includeDeleted(r)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	// check if asset exists, an existing asset without history responds with an empty list
+	_, err = h.scanService.GetAsset(r.Context(), assetId, service.GetAssetOptions{IncludeDeleted: withDeleted})
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound("asset", assetId)
+	}
+	if err != nil {
+		return WrapError(err)
+	}
+
 	results, err := h.scanService.ListAssetHistory(r.Context(), assetId)
 	if err != nil {
 		return WrapError(err)
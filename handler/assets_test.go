@@ -0,0 +1,587 @@
+package handler_test
+
+import (
+	cortexContext "cortex/context"
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"cortex/test"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateFinding_Vulnerability(t *testing.T) {
+	scanService := new(MockScanService)
+	findingService := new(MockFindingService)
+	h := handler.NewAssetHandler(scanService, findingService)
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	data := map[string]any{"template-id": "CVE-2021-44228", "severity": "critical", "port": float64(8080)}
+
+	scanService.On("GetAsset", mock.Anything, assetID, service.GetAssetOptions{}).Return(&repository.ScanAsset{ID: assetID}, nil)
+	findingService.On("CreateFinding", mock.Anything, service.CreateFindingOptions{
+		AssetID: assetID,
+		Type:    repository.FindingTypeVulnerability,
+		Data:    data,
+	}).Return(&repository.AssetFinding{ID: "5a7bdb69-d7d6-482f-a653-2ab01480999f"}, nil)
+
+	runner := test.NewTestRunner(h.HandleCreateFinding)
+	runner.WithPath("id", assetID).
+		WithBody(map[string]any{"type": "vulnerability", "data": data}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+}
+
+func TestCreateFinding_InvalidData(t *testing.T) {
+	scanService := new(MockScanService)
+	findingService := new(MockFindingService)
+	h := handler.NewAssetHandler(scanService, findingService)
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+
+	scanService.On("GetAsset", mock.Anything, assetID, service.GetAssetOptions{}).Return(&repository.ScanAsset{ID: assetID}, nil)
+	findingService.On("CreateFinding", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("%w: missing field", service.ErrInvalidFinding))
+
+	runner := test.NewTestRunner(h.HandleCreateFinding)
+	runner.WithPath("id", assetID).
+		WithBody(map[string]any{"type": "vulnerability", "data": map[string]any{"port": 8080}}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestCreateFinding_InvalidDataFields(t *testing.T) {
+	scanService := new(MockScanService)
+	findingService := new(MockFindingService)
+	h := handler.NewAssetHandler(scanService, findingService)
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+
+	scanService.On("GetAsset", mock.Anything, assetID, service.GetAssetOptions{}).Return(&repository.ScanAsset{ID: assetID}, nil)
+	findingService.On("CreateFinding", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("%w: %w", service.ErrInvalidFinding, service.FindingDataErrors{
+			{Path: "data.protocol", Message: "is required"},
+			{Path: "data.port", Message: "must be an integer"},
+		}))
+
+	res := test.NewTestRunner(h.HandleCreateFinding).
+		WithPath("id", assetID).
+		WithBody(map[string]any{"type": "port", "data": map[string]any{"port": "443"}}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+
+	rr := httptest.NewRecorder()
+	handler.RespondError(rr, httptest.NewRequest(http.MethodPost, "/", nil), http.StatusBadRequest, res.Error)
+	var response handler.ErrorResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, []handler.ErrorResponseStack{
+		{Field: "data.port", Message: "must be an integer", Reason: "validation"},
+		{Field: "data.protocol", Message: "is required", Reason: "validation"},
+	}, response.Error.Errors)
+}
+
+func TestCreateFinding_RegisteredType(t *testing.T) {
+	err := service.RegisterFindingType(service.FindingTypeDefinition{
+		Type:           "misconfiguration",
+		IdentityFields: []string{"check"},
+	})
+	if !errors.Is(err, service.ErrFindingTypeExists) {
+		require.NoError(t, err)
+	}
+
+	scanService := new(MockScanService)
+	findingService := new(MockFindingService)
+	h := handler.NewAssetHandler(scanService, findingService)
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	data := map[string]any{"check": "tls-1.0-enabled"}
+
+	scanService.On("GetAsset", mock.Anything, assetID, service.GetAssetOptions{}).Return(&repository.ScanAsset{ID: assetID}, nil)
+	findingService.On("CreateFinding", mock.Anything, service.CreateFindingOptions{
+		AssetID: assetID,
+		Type:    "misconfiguration",
+		Data:    data,
+	}).Return(&repository.AssetFinding{ID: "5a7bdb69-d7d6-482f-a653-2ab01480999f"}, nil)
+
+	runner := test.NewTestRunner(h.HandleCreateFinding)
+	runner.WithPath("id", assetID).
+		WithBody(map[string]any{"type": "misconfiguration", "data": data}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+}
+
+func TestCreateFinding_UnknownType(t *testing.T) {
+	h := handler.NewAssetHandler(new(MockScanService), new(MockFindingService))
+
+	runner := test.NewTestRunner(h.HandleCreateFinding)
+	runner.WithPath("id", "7761259c-e6dd-4930-946b-ee9975fde3e4").
+		WithBody(map[string]any{"type": "secret", "data": map[string]any{"path": ".env"}}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestListAssetHistory_UnknownAsset(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	scanService.On("GetAsset", mock.Anything, assetID, service.GetAssetOptions{}).Return(nil, repository.ErrNotFound)
+
+	runner := test.NewTestRunner(h.HandleListAssetHistory)
+	runner.WithPath("id", assetID).Run(t).ExpectAPIError(http.StatusNotFound)
+
+	scanService.AssertNotCalled(t, "ListAssetHistory", mock.Anything, mock.Anything)
+}
+
+func TestListAssetHistory_NoHistory(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	scanService.On("GetAsset", mock.Anything, assetID, service.GetAssetOptions{}).Return(&repository.ScanAsset{ID: assetID}, nil)
+	scanService.On("ListAssetHistory", mock.Anything, assetID).Return([]repository.AssetHistoryEntry{}, nil)
+
+	runner := test.NewTestRunner(h.HandleListAssetHistory)
+	res := runner.WithPath("id", assetID).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+
+	assert.Contains(t, res.RR.Body.String(), `"items":[]`)
+}
+
+func TestListAssetHistory_IncludeDeleted(t *testing.T) {
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	admin := cortexContext.UserInfoData{UserID: "admin", Role: string(repository.UserRoleAdmin)}
+
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	scanService.On("GetAsset", mock.Anything, assetID, service.GetAssetOptions{IncludeDeleted: true}).
+		Return(&repository.ScanAsset{ID: assetID, Deleted: true}, nil)
+	scanService.On("ListAssetHistory", mock.Anything, assetID).Return([]repository.AssetHistoryEntry{
+		{ID: "e", AssetID: assetID, Type: repository.ScanAssetEventTypeDeleted},
+	}, nil)
+
+	res := test.NewTestRunner(h.HandleListAssetHistory).WithPath("id", assetID).
+		WithQuery("includeDeleted", "true").WithContextValue(cortexContext.KeyUserInfo, admin).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"eventType":"deleted"`)
+
+	// only admins may see deleted assets
+	test.NewTestRunner(h.HandleListAssetHistory).WithPath("id", assetID).
+		WithQuery("includeDeleted", "true").WithContextValue(cortexContext.KeyUserInfo,
+		cortexContext.UserInfoData{UserID: "user"}).
+		Run(t).ExpectAPIError(http.StatusForbidden)
+}
+
+func TestGetAsset_IncludeDeleted(t *testing.T) {
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	admin := cortexContext.UserInfoData{UserID: "admin", Role: string(repository.UserRoleAdmin)}
+
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	scanService.On("GetAsset", mock.Anything, assetID, service.GetAssetOptions{IncludeDeleted: true}).
+		Return(&repository.ScanAsset{ID: assetID, Endpoint: "example.com", Deleted: true}, nil)
+
+	res := test.NewTestRunner(h.HandleGet).WithPath("id", assetID).WithQuery("includeDeleted", "true").
+		WithContextValue(cortexContext.KeyUserInfo, admin).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"deleted":true`)
+
+	test.NewTestRunner(h.HandleGet).WithPath("id", assetID).WithQuery("includeDeleted", "true").
+		WithQuery("stats", "true").WithContextValue(cortexContext.KeyUserInfo, admin).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+	test.NewTestRunner(h.HandleGet).WithPath("id", assetID).WithQuery("includeDeleted", "true").
+		Run(t).ExpectAPIError(http.StatusForbidden)
+}
+
+func TestCreateAsset_LimitExceeded(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+
+	scanService.On("CreateAsset", mock.Anything, "example.com").
+		Return(&repository.ScanAsset{ID: "7761259c-e6dd-4930-946b-ee9975fde3e4", Endpoint: "example.com"}, nil).Once()
+	scanService.On("CreateAsset", mock.Anything, "example.org").
+		Return(nil, fmt.Errorf("%w: at most 1 assets are allowed", service.ErrLimitExceeded)).Once()
+
+	test.NewTestRunner(h.HandleCreate).WithBody(map[string]any{"endpoint": "example.com"}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+	test.NewTestRunner(h.HandleCreate).WithBody(map[string]any{"endpoint": "example.org"}).
+		Run(t).ExpectAPIError(http.StatusForbidden)
+	scanService.AssertExpectations(t)
+}
+
+func TestCreateAsset_Duplicate(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	scanService.On("CreateAsset", mock.Anything, "example.com").
+		Return(nil, fmt.Errorf("create asset: %w", repository.ErrUniqueViolation))
+
+	res := test.NewTestRunner(h.HandleCreate).WithBody(map[string]any{"endpoint": "example.com"}).
+		Run(t).ExpectAPIError(http.StatusConflict)
+	assert.ErrorIs(t, res.Error, repository.ErrUniqueViolation)
+}
+
+func TestCreateAsset_Endpoints(t *testing.T) {
+	valid := []string{"example.com", "192.168.0.1", "[2001:db8::1]", "10.0.0.0/24"}
+	for _, endpoint := range valid {
+		scanService := new(MockScanService)
+		h := handler.NewAssetHandler(scanService, new(MockFindingService))
+		scanService.On("CreateAsset", mock.Anything, endpoint).
+			Return(&repository.ScanAsset{ID: "7761259c-e6dd-4930-946b-ee9975fde3e4", Endpoint: endpoint}, nil)
+
+		runner := test.NewTestRunner(h.HandleCreate)
+		runner.WithBody(map[string]any{"endpoint": endpoint}).Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+	}
+
+	invalid := []string{"not a host", "example.com:443", "10.0..1", "http://example.com"}
+	for _, endpoint := range invalid {
+		scanService := new(MockScanService)
+		h := handler.NewAssetHandler(scanService, new(MockFindingService))
+
+		runner := test.NewTestRunner(h.HandleCreate)
+		runner.WithBody(map[string]any{"endpoint": endpoint}).Run(t).ExpectAPIError(http.StatusBadRequest)
+		scanService.AssertNotCalled(t, "CreateAsset", mock.Anything, mock.Anything)
+	}
+}
+
+func TestBulkCreateAssets(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+
+	endpoints := []string{"example.com", "10.0.0.0/24", "example.com"}
+	scanService.On("CreateAssets", mock.Anything, endpoints).Return(&service.CreateAssetsResult{
+		Created: []repository.ScanAsset{
+			{ID: "7761259c-e6dd-4930-946b-ee9975fde3e4", Endpoint: "example.com"},
+			{ID: "5a7bdb69-d7d6-482f-a653-2ab01480999f", Endpoint: "10.0.0.0/24"},
+		},
+		Skipped: []string{"example.com"},
+	}, nil)
+
+	res := test.NewTestRunner(h.HandleBulkCreate).WithBody(map[string]any{"endpoints": endpoints}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+	assert.Contains(t, res.RR.Body.String(), `"skipped":["example.com"]`)
+}
+
+func TestBulkCreateAssets_Invalid(t *testing.T) {
+	tooMany := make([]string, 1001)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("host%d.example.com", i)
+	}
+
+	for _, endpoints := range [][]string{{}, {"example.com", "not a host"}, {" example.com"}, tooMany} {
+		scanService := new(MockScanService)
+		h := handler.NewAssetHandler(scanService, new(MockFindingService))
+
+		runner := test.NewTestRunner(h.HandleBulkCreate)
+		runner.WithBody(map[string]any{"endpoints": endpoints}).Run(t).ExpectAPIError(http.StatusBadRequest)
+		scanService.AssertNotCalled(t, "CreateAssets", mock.Anything, mock.Anything)
+	}
+}
+
+func TestUpdateFinding_TriageStatus(t *testing.T) {
+	scanService := new(MockScanService)
+	findingService := new(MockFindingService)
+	h := handler.NewAssetHandler(scanService, findingService)
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	findingID := "5a7bdb69-d7d6-482f-a653-2ab01480999f"
+	findingService.On("UpdateFindingTriage", mock.Anything, assetID, findingID, repository.TriageStatusFalsePositive).
+		Return(&repository.AssetFinding{ID: findingID, AssetID: assetID, TriageStatus: repository.TriageStatusFalsePositive}, nil)
+
+	res := test.NewTestRunner(h.HandleUpdateFinding).WithPath("id", assetID).WithPath("findingId", findingID).
+		WithBody(map[string]any{"triageStatus": "false-positive"}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"triageStatus":"false-positive"`)
+	findingService.AssertExpectations(t)
+}
+
+func TestUpdateFinding_InvalidTriageStatus(t *testing.T) {
+	scanService := new(MockScanService)
+	findingService := new(MockFindingService)
+	h := handler.NewAssetHandler(scanService, findingService)
+
+	test.NewTestRunner(h.HandleUpdateFinding).WithPath("id", "7761259c-e6dd-4930-946b-ee9975fde3e4").
+		WithPath("findingId", "5a7bdb69-d7d6-482f-a653-2ab01480999f").
+		WithBody(map[string]any{"triageStatus": "ignored"}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+	findingService.AssertNotCalled(t, "UpdateFindingTriage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateFinding_NotFound(t *testing.T) {
+	scanService := new(MockScanService)
+	findingService := new(MockFindingService)
+	h := handler.NewAssetHandler(scanService, findingService)
+
+	findingService.On("UpdateFindingTriage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, repository.ErrNotFound)
+
+	test.NewTestRunner(h.HandleUpdateFinding).WithPath("id", "7761259c-e6dd-4930-946b-ee9975fde3e4").
+		WithPath("findingId", "5a7bdb69-d7d6-482f-a653-2ab01480999f").
+		WithBody(map[string]any{"triageStatus": "acknowledged"}).
+		Run(t).ExpectAPIError(http.StatusNotFound)
+}
+
+func TestListAssetFindings_TriageFilter(t *testing.T) {
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+
+	tests := []struct {
+		name     string
+		query    string
+		statuses []repository.TriageStatus
+	}{
+		{"default", "", nil},
+		{"single status", "acknowledged", []repository.TriageStatus{repository.TriageStatusAcknowledged}},
+		{"all", "all", repository.TriageStatuses},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanService := new(MockScanService)
+			h := handler.NewAssetHandler(scanService, new(MockFindingService))
+			scanService.On("ListAssetFindings", mock.Anything, assetID,
+				service.ListFindingsOptions{TriageStatuses: tt.statuses}).Return([]repository.AssetFinding{}, nil)
+
+			runner := test.NewTestRunner(h.HandleListAssetFindings).WithPath("id", assetID)
+			if tt.query != "" {
+				runner = runner.WithQuery("triageStatus", tt.query)
+			}
+			runner.Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+			scanService.AssertExpectations(t)
+		})
+	}
+
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	test.NewTestRunner(h.HandleListAssetFindings).WithPath("id", assetID).WithQuery("triageStatus", "closed").
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestListAssets_TagFilter(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	scanService.On("ListAssets", mock.Anything, service.ListAssetsOptions{Tags: map[string]string{"env": "prod:eu"}}).
+		Return([]repository.ScanAsset{{ID: "a", Endpoint: "example.com", Tags: map[string]string{"env": "prod:eu"}}}, nil)
+
+	res := test.NewTestRunner(h.HandleList).WithQuery("tag", "env:prod:eu").
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"tags":{"env":"prod:eu"}`)
+	scanService.AssertExpectations(t)
+
+	for _, tag := range []string{"env", ":prod", "env:", strings.Repeat("k", 51) + ":prod"} {
+		scanService := new(MockScanService)
+		h := handler.NewAssetHandler(scanService, new(MockFindingService))
+		test.NewTestRunner(h.HandleList).WithQuery("tag", tag).Run(t).ExpectAPIError(http.StatusBadRequest)
+		scanService.AssertNotCalled(t, "ListAssets", mock.Anything, mock.Anything)
+	}
+}
+
+func TestUpdateAssetTags(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	tags := map[string]string{"env": "prod", "team": "platform"}
+	scanService.On("SetAssetTags", mock.Anything, assetID, tags).
+		Return(&repository.ScanAsset{ID: assetID, Endpoint: "example.com", Tags: tags}, nil)
+
+	res := test.NewTestRunner(h.HandleUpdateTags).WithPath("id", assetID).
+		WithBody(map[string]any{"tags": tags}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"tags":{"env":"prod","team":"platform"}`)
+	scanService.AssertExpectations(t)
+}
+
+func TestUpdateAssetTags_Invalid(t *testing.T) {
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+
+	for name, tags := range map[string]map[string]string{
+		"empty key":    {"": "prod"},
+		"colon in key": {"env:region": "eu"},
+		"long key":     {strings.Repeat("k", 51): "prod"},
+		"empty value":  {"env": ""},
+		"long value":   {"env": strings.Repeat("v", 201)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			scanService := new(MockScanService)
+			h := handler.NewAssetHandler(scanService, new(MockFindingService))
+			test.NewTestRunner(h.HandleUpdateTags).WithPath("id", assetID).
+				WithBody(map[string]any{"tags": tags}).
+				Run(t).ExpectAPIError(http.StatusBadRequest)
+			scanService.AssertNotCalled(t, "SetAssetTags", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateAssetTags_UnknownAsset(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	scanService.On("SetAssetTags", mock.Anything, mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+
+	test.NewTestRunner(h.HandleUpdateTags).WithPath("id", "7761259c-e6dd-4930-946b-ee9975fde3e4").
+		WithBody(map[string]any{"tags": map[string]string{}}).
+		Run(t).ExpectAPIError(http.StatusNotFound)
+}
+
+func TestListAssets_CSV(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	scanService.On("EachAssetWithStats", mock.Anything, service.ListAssetsOptions{Tags: map[string]string{}}).
+		Return([]repository.ScanAssetWithStats{
+			{ID: "a", Endpoint: "a.example.com", Stats: repository.ScanAssetStats{
+				DiscoveredPortsCount: 3,
+				LastDiscovery:        time.Date(2025, 12, 10, 10, 0, 0, 0, time.UTC),
+			}},
+			{ID: "b", Endpoint: "10.0.0.0/24"},
+		}, nil)
+
+	res := test.NewTestRunner(h.HandleList).WithQuery("stats", "true").WithQuery("format", "csv").
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Equal(t, "text/csv; charset=utf-8", res.RR.Header().Get("Content-Type"))
+
+	records, err := csv.NewReader(res.RR.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"endpoint", "discoveredPortsCount", "lastDiscovery"},
+		{"a.example.com", "3", "2025-12-10T10:00:00Z"},
+		{"10.0.0.0/24", "0", ""},
+	}, records)
+}
+
+func TestListAssets_CSVColumns(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	scanService.On("EachAssetWithStats", mock.Anything, service.ListAssetsOptions{Tags: map[string]string{}}).
+		Return([]repository.ScanAssetWithStats{
+			{ID: "a", Endpoint: "a.example.com", Stats: repository.ScanAssetStats{DiscoveredPortsCount: 3}},
+		}, nil)
+
+	res := test.NewTestRunner(h.HandleList).WithQuery("format", "csv").
+		WithQuery("columns", "discoveredPortsCount:Open Ports,endpoint").
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+
+	records, err := csv.NewReader(res.RR.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Open Ports", "endpoint"},
+		{"3", "a.example.com"},
+	}, records)
+
+	test.NewTestRunner(h.HandleList).WithQuery("format", "csv").WithQuery("columns", "id").
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestListAssets_CSVEmpty(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	scanService.On("EachAssetWithStats", mock.Anything, service.ListAssetsOptions{Tags: map[string]string{}}).
+		Return([]repository.ScanAssetWithStats{}, nil)
+
+	res := test.NewTestRunner(h.HandleList).WithQuery("format", "csv").Run(t).ExpectNoError()
+	assert.Equal(t, `attachment; filename="assets.csv"`, res.RR.Header().Get("Content-Disposition"))
+	assert.Equal(t, "endpoint,discoveredPortsCount,lastDiscovery\n", res.RR.Body.String())
+}
+
+func TestListAssets_CSVErrors(t *testing.T) {
+	t.Run("before the first row", func(t *testing.T) {
+		scanService := new(MockScanService)
+		h := handler.NewAssetHandler(scanService, new(MockFindingService))
+		scanService.On("EachAssetWithStats", mock.Anything, mock.Anything).Return(nil, service.ErrDatabaseUnavailable)
+
+		res := test.NewTestRunner(h.HandleList).WithQuery("format", "csv").Run(t)
+		assert.Error(t, res.Error)
+		assert.Empty(t, res.RR.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("after the first row", func(t *testing.T) {
+		scanService := new(MockScanService)
+		h := handler.NewAssetHandler(scanService, new(MockFindingService))
+		scanService.On("EachAssetWithStats", mock.Anything, mock.Anything).Return([]repository.ScanAssetWithStats{
+			{ID: "a", Endpoint: "a.example.com"},
+		}, errors.New("connection reset"))
+
+		// the export is aborted rather than ended like a complete one
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			test.NewTestRunner(h.HandleList).WithQuery("format", "csv").Run(t)
+		})
+	})
+}
+
+func TestListAssets_InvalidFormat(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+	test.NewTestRunner(h.HandleList).WithQuery("format", "xml").Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestDeleteAsset(t *testing.T) {
+	id := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	admin := cortexContext.UserInfoData{UserID: "admin", Role: string(repository.UserRoleAdmin)}
+	tests := []struct {
+		name     string
+		hard     string
+		user     cortexContext.UserInfoData
+		expected service.DeleteAssetOptions
+	}{
+		{name: "soft", user: cortexContext.UserInfoData{UserID: "user"}},
+		{name: "explicitly soft", hard: "false", user: cortexContext.UserInfoData{UserID: "user"}},
+		{name: "permanent", hard: "true", user: admin, expected: service.DeleteAssetOptions{Permanent: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanService := new(MockScanService)
+			h := handler.NewAssetHandler(scanService, new(MockFindingService))
+			scanService.On("DeleteAsset", mock.Anything, id, tt.expected).
+				Return(&repository.ScanAsset{ID: id, Endpoint: "example.com"}, nil)
+
+			test.NewTestRunner(h.HandleDelete).WithPath("id", id).WithQuery("hard", tt.hard).
+				WithContextValue(cortexContext.KeyUserInfo, tt.user).
+				Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+			scanService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteAsset_Errors(t *testing.T) {
+	id := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	scanService := new(MockScanService)
+	h := handler.NewAssetHandler(scanService, new(MockFindingService))
+
+	test.NewTestRunner(h.HandleDelete).WithPath("id", id).WithQuery("hard", "yes").
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+	test.NewTestRunner(h.HandleDelete).WithPath("id", id).WithQuery("hard", "true").
+		WithContextValue(cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user", Role: string(repository.UserRoleUser)}).
+		Run(t).ExpectAPIError(http.StatusForbidden)
+	scanService.AssertNotCalled(t, "DeleteAsset", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRestoreAsset(t *testing.T) {
+	id := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "restored", status: http.StatusOK},
+		{name: "not deleted", err: repository.ErrNotFound, status: http.StatusNotFound},
+		{name: "endpoint taken", err: repository.ErrUniqueViolation, status: http.StatusConflict},
+		{name: "limit exceeded", err: service.ErrLimitExceeded, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanService := new(MockScanService)
+			h := handler.NewAssetHandler(scanService, new(MockFindingService))
+			if tt.err != nil {
+				scanService.On("RestoreAsset", mock.Anything, id).Return(nil, tt.err)
+				test.NewTestRunner(h.HandleRestore).WithPath("id", id).Run(t).ExpectAPIError(tt.status)
+				return
+			}
+			scanService.On("RestoreAsset", mock.Anything, id).
+				Return(&repository.ScanAsset{ID: id, Endpoint: "example.com"}, nil)
+			test.NewTestRunner(h.HandleRestore).WithPath("id", id).
+				Run(t).ExpectNoError().ExpectStatusCode(tt.status)
+		})
+	}
+}
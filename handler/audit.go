@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"cortex/repository"
+	"cortex/service"
+	"net/http"
+	"time"
+)
+
+// maxAuditTargetIDLength is the maximum length of the target id filter, the length of the stored ids.
+const maxAuditTargetIDLength = 100
+
+var auditActions = []string{
+	string(repository.AuditActionCreate), string(repository.AuditActionUpdate), string(repository.AuditActionDelete),
+	string(repository.AuditActionRestore),
+}
+
+var auditTargetTypes = []string{
+	string(repository.AuditTargetScanConfig), string(repository.AuditTargetAsset), string(repository.AuditTargetAgent),
+	string(repository.AuditTargetUser), string(repository.AuditTargetToken),
+}
+
+type AuditHandler struct {
+	auditService service.AuditService
+}
+
+func NewAuditHandler(auditService service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// validateQueryTime parses the query parameter param as Unix time in seconds, the format timestamps are returned in.
+// If the parameter is not set, the zero time is returned.
+func validateQueryTime(r *http.Request, param string) (time.Time, error) {
+	seconds, err := ValidateQueryInt(r, param, 0, Min(0))
+	if err != nil || seconds == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(seconds), 0), nil
+}
+
+func (h AuditHandler) HandleList(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	actorID, err := validateQueryUUID(r, "actor")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	// tokens and agents are not identified by UUIDs
+	targetID, err := ValidateString(query.Get("targetId"), MaxLength(maxAuditTargetIDLength)).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	action, err := ValidateString(query.Get("action"), In(append(auditActions, "")...)).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	targetType, err := ValidateString(query.Get("targetType"), In(append(auditTargetTypes, "")...)).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	from, err := validateQueryTime(r, "from")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	to, err := validateQueryTime(r, "to")
+	if err != nil {
+		return WrapError(err)
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return WrapError(NewStructValidationError(map[string]error{"to": NewValidationError("must not be before from")}))
+	}
+
+	limit, err := ValidateQueryInt(r, "limit", 0, Range(1, maxPageSize))
+	if err != nil {
+		return WrapError(err)
+	}
+
+	offset, err := ValidateQueryInt(r, "offset", 0, Min(0))
+	if err != nil {
+		return WrapError(err)
+	}
+
+	page := Page{Limit: limit, Offset: offset}
+	entries, err := h.auditService.ListAuditEntries(r.Context(), service.ListAuditEntriesOptions{
+		ActorID:    actorID,
+		Action:     repository.AuditAction(action),
+		TargetType: repository.AuditTargetType(targetType),
+		TargetID:   targetID,
+		From:       from,
+		To:         to,
+		Limit:      page.fetchLimit(),
+		Offset:     offset,
+	})
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondPage(w, r, entries, page); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package handler_test
+
+import (
+	"context"
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"cortex/test"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) ListAuditEntries(ctx context.Context, opts service.ListAuditEntriesOptions) ([]repository.AuditEntry, error) {
+	args := m.Called(ctx, opts)
+	return args.Get(0).([]repository.AuditEntry), args.Error(1)
+}
+
+func (m *MockAuditService) Record(ctx context.Context, tx pgx.Tx, record service.AuditRecord) error {
+	args := m.Called(ctx, tx, record)
+	return args.Error(0)
+}
+
+func TestListAudit_Actor(t *testing.T) {
+	auditService := new(MockAuditService)
+	h := handler.NewAuditHandler(auditService)
+
+	actorID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	var actor pgtype.UUID
+	require.NoError(t, actor.Scan(actorID))
+	entries := []repository.AuditEntry{{
+		ID:         "5a7bdb69-d7d6-482f-a653-2ab01480999f",
+		ActorID:    actor,
+		Action:     repository.AuditActionDelete,
+		TargetType: repository.AuditTargetAsset,
+		CreatedAt:  time.Unix(1700000000, 0),
+	}}
+	auditService.On("ListAuditEntries", mock.Anything, service.ListAuditEntriesOptions{
+		ActorID: actorID,
+		Action:  repository.AuditActionDelete,
+		Limit:   51,
+	}).Return(entries, nil)
+
+	res := test.NewTestRunner(h.HandleList).
+		WithQuery("actor", actorID).
+		WithQuery("action", "delete").
+		WithQuery("limit", "50").
+		Run(t).ExpectNoError()
+
+	body := res.RR.Body.String()
+	assert.Contains(t, body, `"actorId":"`+actorID+`"`)
+	assert.Contains(t, body, `"createdAt":1700000000`)
+	assert.NotContains(t, body, "targetId")
+	auditService.AssertExpectations(t)
+}
+
+func TestListAudit_TokenTarget(t *testing.T) {
+	auditService := new(MockAuditService)
+	h := handler.NewAuditHandler(auditService)
+
+	auditService.On("ListAuditEntries", mock.Anything, service.ListAuditEntriesOptions{
+		TargetType: repository.AuditTargetToken,
+		TargetID:   "a1b2c3d4",
+	}).Return([]repository.AuditEntry{{
+		ID:         "5a7bdb69-d7d6-482f-a653-2ab01480999f",
+		Action:     repository.AuditActionDelete,
+		TargetType: repository.AuditTargetToken,
+		TargetID:   "a1b2c3d4",
+		RequestID:  "req-1",
+		CreatedAt:  time.Unix(1700000000, 0),
+	}}, nil)
+
+	res := test.NewTestRunner(h.HandleList).
+		WithQuery("targetType", "token").
+		WithQuery("targetId", "a1b2c3d4").
+		Run(t).ExpectNoError()
+
+	body := res.RR.Body.String()
+	assert.Contains(t, body, `"targetId":"a1b2c3d4"`)
+	assert.Contains(t, body, `"requestId":"req-1"`)
+	assert.NotContains(t, body, "actorId")
+	auditService.AssertExpectations(t)
+}
+
+func TestListAudit_TimeWindow(t *testing.T) {
+	auditService := new(MockAuditService)
+	h := handler.NewAuditHandler(auditService)
+
+	auditService.On("ListAuditEntries", mock.Anything, service.ListAuditEntriesOptions{
+		From: time.Unix(1700000000, 0),
+		To:   time.Unix(1700086400, 0),
+	}).Return([]repository.AuditEntry{}, nil)
+
+	test.NewTestRunner(h.HandleList).
+		WithQuery("from", "1700000000").
+		WithQuery("to", "1700086400").
+		Run(t).ExpectNoError()
+
+	auditService.AssertExpectations(t)
+}
+
+func TestListAudit_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]string
+	}{
+		{name: "actor", params: map[string]string{"actor": "admin"}},
+		{name: "target id", params: map[string]string{"targetId": strings.Repeat("a", 101)}},
+		{name: "action", params: map[string]string{"action": "read"}},
+		{name: "target type", params: map[string]string{"targetType": "scan"}},
+		{name: "from", params: map[string]string{"from": "2025-12-01"}},
+		{name: "negative to", params: map[string]string{"to": "-1"}},
+		{name: "reversed window", params: map[string]string{"from": "1700086400", "to": "1700000000"}},
+		{name: "limit", params: map[string]string{"limit": "1001"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewAuditHandler(new(MockAuditService))
+
+			runner := test.NewTestRunner(h.HandleList)
+			for param, value := range tt.params {
+				runner = runner.WithQuery(param, value)
+			}
+			runner.Run(t).ExpectAPIError(http.StatusBadRequest)
+		})
+	}
+}
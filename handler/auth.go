@@ -4,7 +4,9 @@ import (
 	cortexContext "cortex/context"
 	"cortex/repository"
 	"cortex/service"
+	"errors"
 	"net/http"
+	"strings"
 )
 
 type AuthHandler struct {
@@ -20,6 +22,8 @@ func NewAuthHandler(authService service.AuthService) *AuthHandler {
 type usernamePasswordLoginRequestBody struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// RememberMe requests a token with a longer lifetime
+	RememberMe bool `json:"rememberMe"`
 }
 
 type tokenResponse struct {
@@ -30,19 +34,19 @@ type tokenResponse struct {
 func (h AuthHandler) HandleUsernamePasswordLogin(w http.ResponseWriter, r *http.Request) error {
 	var requestBody usernamePasswordLoginRequestBody
 	err := ValidateRequestBody(r, &requestBody,
-		Field(&requestBody.Username, Required(), Length(1, AnyLength)),
+		Field(&requestBody.Username, NotBlank(), Length(1, AnyLength)),
 		Field(&requestBody.Password, Required(), Length(1, AnyLength)),
 	)
 	if err != nil {
 		// always return 401 to not leak information for now
-		return APIError{
-			StatusCode: http.StatusUnauthorized,
-			Message:    "unauthorized",
-		}
+		return Unauthorized("invalid credentials")
 	}
 
 	// validate credentials
 	user, err := h.authService.CheckUsernamePassword(r.Context(), requestBody.Username, requestBody.Password)
+	if errors.Is(err, service.ErrUnauthenticated) {
+		return Unauthorized("invalid credentials")
+	}
 	if err != nil {
 		return WrapError(err)
 	}
@@ -54,9 +58,10 @@ func (h AuthHandler) HandleUsernamePasswordLogin(w http.ResponseWriter, r *http.
 	}
 
 	tokenOptions := service.CreateTokenOptions{
-		UserID:    user.ID,
-		UserAgent: r.UserAgent(),
-		SourceIP:  src,
+		UserID:     user.ID,
+		UserAgent:  r.UserAgent(),
+		SourceIP:   src,
+		RememberMe: requestBody.RememberMe,
 	}
 
 	_, tokenString, err := h.authService.CreateSessionToken(r.Context(), tokenOptions)
@@ -75,10 +80,50 @@ func (h AuthHandler) HandleUsernamePasswordLogin(w http.ResponseWriter, r *http.
 	return nil
 }
 
+// HandleRefreshToken replaces the session token of the request by a new one with a fresh expiry.
+func (h AuthHandler) HandleRefreshToken(w http.ResponseWriter, r *http.Request) error {
+	if _, err := cortexContext.UserInfo(r.Context()); err != nil {
+		// agents authenticate with tokens that are not user sessions
+		return Forbidden("only user sessions can be refreshed")
+	}
+	tokenString, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	src := r.RemoteAddr
+	if r.Header.Get("X-Forwarded-For") != "" {
+		src = r.Header.Get("X-Forwarded-For")
+	}
+
+	tokenOptions := service.CreateTokenOptions{
+		UserAgent: r.UserAgent(),
+		SourceIP:  src,
+	}
+
+	authToken, newTokenString, err := h.authService.RefreshToken(r.Context(), tokenString, tokenOptions)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	user, err := h.authService.GetUser(r.Context(), authToken.UserID)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	response := tokenResponse{
+		Token: newTokenString,
+		User:  user,
+	}
+
+	if err = RespondOne(w, r, response); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
 func (h AuthHandler) HandleValidateToken(w http.ResponseWriter, r *http.Request) error {
 	userInfo, err := cortexContext.UserInfo(r.Context())
 	if err != nil {
-		return WrapError(err)
+		// agents authenticate with tokens that are not user sessions
+		return Forbidden("only user sessions can be validated")
 	}
 
 	user, err := h.authService.GetUser(r.Context(), userInfo.UserID)
@@ -92,3 +137,21 @@ func (h AuthHandler) HandleValidateToken(w http.ResponseWriter, r *http.Request)
 
 	return nil
 }
+
+// HandleCheckToken is a lightweight alternative to HandleValidateToken for polling session validity. It responds with
+// an empty 200 or 401, does not require the authentication middleware and does not log the checked tokens.
+func (h AuthHandler) HandleCheckToken(w http.ResponseWriter, r *http.Request) error {
+	tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || tokenString == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
+
+	if err := h.authService.CheckToken(r.Context(), tokenString); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,250 @@
+package handler_test
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"cortex/test"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuthService struct {
+	mock.Mock
+}
+
+func (m *MockAuthService) ListUsers(ctx context.Context) ([]repository.User, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.User), args.Error(1)
+}
+
+func (m *MockAuthService) GetUser(ctx context.Context, id string) (*repository.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockAuthService) CreateUser(ctx context.Context, opts service.CreateUserOptions) (*repository.User, error) {
+	args := m.Called(ctx, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockAuthService) UpdateUser(ctx context.Context, id string, opts service.UpdateUserOptions) (*repository.User, error) {
+	args := m.Called(ctx, id, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockAuthService) ChangePassword(ctx context.Context, userID string, oldPassword string, newPassword string, revokeTokens bool) error {
+	args := m.Called(ctx, userID, oldPassword, newPassword, revokeTokens)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ResetPassword(ctx context.Context, userID string, newPassword string, revokeTokens bool) error {
+	args := m.Called(ctx, userID, newPassword, revokeTokens)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) CheckUsernamePassword(ctx context.Context, username string, password string) (*repository.User, error) {
+	args := m.Called(ctx, username, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockAuthService) LoginExternalUser(ctx context.Context, identity service.ExternalIdentity) (*repository.User, error) {
+	args := m.Called(ctx, identity)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.User), args.Error(1)
+}
+
+func (m *MockAuthService) ValidateToken(ctx context.Context, tokenString string) (*repository.User, string, error) {
+	args := m.Called(ctx, tokenString)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).(*repository.User), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) CheckToken(ctx context.Context, tokenString string) error {
+	args := m.Called(ctx, tokenString)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) CreateSessionToken(ctx context.Context, opt service.CreateTokenOptions) (*repository.AuthToken, string, error) {
+	args := m.Called(ctx, opt)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).(*repository.AuthToken), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) RevokeToken(ctx context.Context, tokenString string) error {
+	args := m.Called(ctx, tokenString)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) RefreshToken(ctx context.Context, tokenString string, opt service.CreateTokenOptions) (*repository.AuthToken, string, error) {
+	args := m.Called(ctx, tokenString, opt)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).(*repository.AuthToken), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) ListUserTokens(ctx context.Context, userID string) ([]repository.AuthToken, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.AuthToken), args.Error(1)
+}
+
+func (m *MockAuthService) RevokeUserToken(ctx context.Context, userID string, tokenID string) error {
+	args := m.Called(ctx, userID, tokenID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ValidateAgentToken(ctx context.Context, tokenString string) (*repository.Agent, error) {
+	args := m.Called(ctx, tokenString)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.Agent), args.Error(1)
+}
+
+func TestCheckToken_Valid(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewAuthHandler(mockService)
+
+	mockService.On("CheckToken", mock.Anything, "a1b2c3d4.secret").Return(nil)
+
+	runner := test.NewTestRunner(h.HandleCheckToken)
+	res := runner.WithHeader("Authorization", "Bearer a1b2c3d4.secret").
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+
+	assert.Empty(t, res.RR.Body.String())
+}
+
+func TestCheckToken_Invalid(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewAuthHandler(mockService)
+
+	mockService.On("CheckToken", mock.Anything, "a1b2c3d4.wrong").Return(service.ErrUnauthenticated)
+
+	runner := test.NewTestRunner(h.HandleCheckToken)
+	res := runner.WithHeader("Authorization", "Bearer a1b2c3d4.wrong").
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusUnauthorized)
+
+	assert.Empty(t, res.RR.Body.String())
+}
+
+func TestCheckToken_Missing(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewAuthHandler(mockService)
+
+	runner := test.NewTestRunner(h.HandleCheckToken)
+	runner.Run(t).ExpectNoError().ExpectStatusCode(http.StatusUnauthorized)
+
+	mockService.AssertNotCalled(t, "CheckToken", mock.Anything, mock.Anything)
+}
+
+func TestRefreshToken(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewAuthHandler(mockService)
+
+	user := &repository.User{ID: "user", Username: "jdoe"}
+	mockService.On("RefreshToken", mock.Anything, "a1b2c3d4.secret", mock.Anything).
+		Return(&repository.AuthToken{ID: "e5f6a7b8", UserID: "user"}, "e5f6a7b8.newsecret", nil)
+	mockService.On("GetUser", mock.Anything, "user").Return(user, nil)
+
+	runner := test.NewTestRunner(h.HandleRefreshToken)
+	res := runner.WithHeader("Authorization", "Bearer a1b2c3d4.secret").
+		WithContextValue(cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user", TokenID: "a1b2c3d4"}).
+		Run(t).ExpectNoError()
+
+	assert.Contains(t, res.RR.Body.String(), `"token":"e5f6a7b8.newsecret"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestRefreshToken_Revoked(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewAuthHandler(mockService)
+
+	mockService.On("RefreshToken", mock.Anything, "a1b2c3d4.secret", mock.Anything).
+		Return(nil, "", service.ErrUnauthenticated)
+
+	runner := test.NewTestRunner(h.HandleRefreshToken)
+	runner.WithHeader("Authorization", "Bearer a1b2c3d4.secret").
+		WithContextValue(cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user", TokenID: "a1b2c3d4"}).
+		Run(t).ExpectAPIError(http.StatusUnauthorized)
+}
+
+func TestRefreshToken_Agent(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewAuthHandler(mockService)
+
+	runner := test.NewTestRunner(h.HandleRefreshToken)
+	runner.WithHeader("X-Agent-Token", "a1b2c3d4.secret").
+		WithContextValue(cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "agent"}).
+		Run(t).ExpectAPIError(http.StatusForbidden)
+
+	mockService.AssertNotCalled(t, "RefreshToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLogin_RememberMe(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		rememberMe bool
+	}{
+		{name: "default", body: `{"username":"jdoe","password":"secret"}`},
+		{name: "remember me", body: `{"username":"jdoe","password":"secret","rememberMe":true}`, rememberMe: true},
+		{name: "not remembered", body: `{"username":"jdoe","password":"secret","rememberMe":false}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			h := handler.NewAuthHandler(mockService)
+
+			user := &repository.User{ID: "user", Username: "jdoe"}
+			mockService.On("CheckUsernamePassword", mock.Anything, "jdoe", "secret").Return(user, nil)
+			mockService.On("CreateSessionToken", mock.Anything, mock.MatchedBy(func(opt service.CreateTokenOptions) bool {
+				return opt.UserID == "user" && opt.RememberMe == tt.rememberMe
+			})).Return(&repository.AuthToken{ID: "a1b2c3d4"}, "a1b2c3d4.secret", nil)
+
+			runner := test.NewTestRunner(h.HandleUsernamePasswordLogin)
+			res := runner.WithBodyString(tt.body).Run(t).ExpectNoError()
+
+			assert.Contains(t, res.RR.Body.String(), `"token":"a1b2c3d4.secret"`)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogin_RememberMeNotBoolean(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewAuthHandler(mockService)
+
+	runner := test.NewTestRunner(h.HandleUsernamePasswordLogin)
+	runner.WithBodyString(`{"username":"jdoe","password":"secret","rememberMe":"yes"}`).
+		Run(t).ExpectAPIError(http.StatusUnauthorized)
+
+	mockService.AssertNotCalled(t, "CheckUsernamePassword", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	formatQueryParam = "format"
+	formatJSON       = "json"
+	formatCSV        = "csv"
+)
+
+// columnsQueryParam selects, orders and names the columns of CSV exports, e.g. ?columns=severity,endpoint:Host. A
+// column is renamed in the header row by appending a colon and the header name.
+const columnsQueryParam = "columns"
+
+// csvColumn is a column a CSV export supports.
+type csvColumn[T any] struct {
+	name  string
+	value func(T) string
+}
+
+// requestedColumns returns the header row and the row function of the columns selected by the columns query
+// parameter. Without the parameter, all columns are exported in their default order.
+func requestedColumns[T any](r *http.Request, columns []csvColumn[T]) ([]string, func(T) []string, error) {
+	selected := columns
+	header := make([]string, 0, len(columns))
+	if param := r.URL.Query().Get(columnsQueryParam); param == "" {
+		for _, column := range columns {
+			header = append(header, column.name)
+		}
+	} else {
+		selected = nil
+		for _, entry := range strings.Split(param, ",") {
+			name, headerName, renamed := strings.Cut(strings.TrimSpace(entry), ":")
+			index := slices.IndexFunc(columns, func(column csvColumn[T]) bool { return column.name == name })
+			if index < 0 {
+				return nil, nil, NewStructValidationError(map[string]error{
+					columnsQueryParam: NewValidationError(fmt.Sprintf("unknown column %q", name)),
+				})
+			}
+			if !renamed {
+				headerName = name
+			} else if headerName = strings.TrimSpace(headerName); headerName == "" {
+				return nil, nil, NewStructValidationError(map[string]error{
+					columnsQueryParam: NewValidationError(fmt.Sprintf("column %q: header name must not be empty", name)),
+				})
+			}
+			selected = append(selected, columns[index])
+			header = append(header, headerName)
+		}
+	}
+
+	row := func(item T) []string {
+		cells := make([]string, 0, len(selected))
+		for _, column := range selected {
+			cells = append(cells, column.value(item))
+		}
+		return cells
+	}
+	return header, row, nil
+}
+
+// requestedFormat returns the response format selected by the format query parameter, JSON by default.
+func requestedFormat(r *http.Request) (string, error) {
+	format, err := ValidateString(r.URL.Query().Get(formatQueryParam), In("", formatJSON, formatCSV)).Validate()
+	if err != nil {
+		return "", err
+	}
+	if format == "" {
+		return formatJSON, nil
+	}
+	return format, nil
+}
+
+// CSVResponse streams a CSV attachment. Rows are sent whenever the write buffer fills, so large exports are never held
+// in memory.
+type CSVResponse struct {
+	writer *csv.Writer
+}
+
+// NewCSVResponse sends the headers of a CSV attachment with the given filename and writes the header row. Header
+// names may be chosen by the client, so they are escaped like cells.
+func NewCSVResponse(w http.ResponseWriter, filename string, header []string) (*CSVResponse, error) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	response := &CSVResponse{writer: csv.NewWriter(w)}
+	if err := response.writer.Write(csvCells(slices.Clone(header))); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Write writes a row, cells that would be evaluated as formulas are escaped.
+func (c *CSVResponse) Write(cells []string) error {
+	return c.writer.Write(csvCells(cells))
+}
+
+// Close flushes the rows written so far and returns the first write error.
+func (c *CSVResponse) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// RespondCSV writes a CSV attachment with a header row and a row per item, rows are written as they are encoded.
+func RespondCSV[T any](w http.ResponseWriter, filename string, header []string, items []T, row func(T) []string) error {
+	response, err := NewCSVResponse(w, filename, header)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err = response.Write(row(item)); err != nil {
+			return err
+		}
+	}
+	return response.Close()
+}
+
+// csvCells prefixes cells that spreadsheet applications would evaluate as formulas, so exported data cannot run
+// formulas when opened.
+func csvCells(cells []string) []string {
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cells[i] = "'" + cell
+		}
+	}
+	return cells
+}
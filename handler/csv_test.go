@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVCellsEscapeFormulas(t *testing.T) {
+	assert.Equal(t,
+		[]string{"'=1+1", "'+1", "'-1", "'@SUM(A1)", "example.com", ""},
+		csvCells([]string{"=1+1", "+1", "-1", "@SUM(A1)", "example.com", ""}))
+}
+
+func TestCSVHeaderEscapesFormulas(t *testing.T) {
+	columns := []csvColumn[string]{
+		{name: "type", value: func(item string) string { return item }},
+	}
+	r := httptest.NewRequest(http.MethodGet, `/findings/export?columns=type:=HYPERLINK("http://evil")`, nil)
+	header, row, err := requestedColumns(r, columns)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, RespondCSV(w, "findings.csv", header, []string{"=1+1"}, row))
+	assert.Equal(t, "\"'=HYPERLINK(\"\"http://evil\"\")\"\n'=1+1\n", w.Body.String())
+	// the requested header names are not modified
+	assert.Equal(t, []string{`=HYPERLINK("http://evil")`}, header)
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// fieldsQueryParam selects the top-level fields of the response data, e.g. ?fields=id,status.
+const fieldsQueryParam = "fields"
+
+type fieldSet = map[string]json.RawMessage
+
+// requestedFields returns the fields selected by the request or nil if the full objects should be returned.
+func requestedFields(r *http.Request) []string {
+	var fields []string
+	for _, field := range strings.Split(r.URL.Query().Get(fieldsQueryParam), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// selectFields reduces each item to the requested fields. Fields are validated against the fields the type serializes.
+func selectFields[T any](items []T, fields []string) ([]fieldSet, error) {
+	known := zeroFields[T]()
+	sets := make([]fieldSet, 0, len(items))
+	for _, item := range items {
+		set, err := toFieldSet(item)
+		if err != nil {
+			return nil, err
+		}
+		for name := range set {
+			known[name] = struct{}{}
+		}
+		sets = append(sets, set)
+	}
+
+	for _, field := range fields {
+		if _, ok := known[field]; !ok {
+			return nil, NewValidationError(fmt.Sprintf("unknown field %s", field))
+		}
+	}
+
+	selected := make([]fieldSet, 0, len(sets))
+	for _, set := range sets {
+		reduced := make(fieldSet, len(fields))
+		for _, field := range fields {
+			if value, ok := set[field]; ok {
+				reduced[field] = value
+			}
+		}
+		selected = append(selected, reduced)
+	}
+	return selected, nil
+}
+
+func toFieldSet(v any) (fieldSet, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var set fieldSet
+	if err = json.Unmarshal(raw, &set); err != nil || set == nil {
+		return nil, NewValidationError("field selection is not supported for this resource")
+	}
+	return set, nil
+}
+
+// zeroFields returns the fields serialized for the zero value of T, so fields can be validated without any data.
+func zeroFields[T any]() map[string]struct{} {
+	t := reflect.TypeFor[T]()
+	zero := reflect.New(t).Elem()
+	if t.Kind() == reflect.Pointer {
+		zero = reflect.New(t.Elem())
+	}
+
+	known := make(map[string]struct{})
+	set, err := toFieldSet(zero.Interface())
+	if err != nil {
+		return known
+	}
+	for name := range set {
+		known[name] = struct{}{}
+	}
+	return known
+}
@@ -0,0 +1,67 @@
+package handler_test
+
+import (
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/test"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespondOne_Fields(t *testing.T) {
+	scan := repository.ScanExecution{ID: testScanID, Status: repository.ScanStatusRunning}
+	h := func(w http.ResponseWriter, r *http.Request) error {
+		return handler.RespondOne(w, r, scan)
+	}
+
+	res := test.NewTestRunner(h).WithQuery("fields", "id,status").Run(t).ExpectNoError()
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(res.RR.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{"id": testScanID, "status": "running"}, body.Data)
+}
+
+func TestRespondMany_Fields(t *testing.T) {
+	agents := []repository.Agent{{ID: "a1b2c3d4", Name: "first"}, {ID: "e5f6a7b8", Name: "second"}}
+	h := func(w http.ResponseWriter, r *http.Request) error {
+		return handler.RespondMany(w, r, agents)
+	}
+
+	res := test.NewTestRunner(h).WithQuery("fields", "name").Run(t).ExpectNoError()
+
+	var body struct {
+		Data struct {
+			Items []map[string]any `json:"items"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(res.RR.Body.Bytes(), &body))
+	assert.Equal(t, []map[string]any{{"name": "first"}, {"name": "second"}}, body.Data.Items)
+}
+
+func TestRespond_UnknownField(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) error {
+		return handler.RespondMany(w, r, []repository.Agent{})
+	}
+
+	res := test.NewTestRunner(h).WithQuery("fields", "id,tokenHash").Run(t).ExpectAPIError(http.StatusBadRequest)
+	assert.Contains(t, res.Error.Error(), "tokenHash")
+	assert.Empty(t, res.RR.Body.String())
+}
+
+func TestRespond_UnknownFieldThroughMake(t *testing.T) {
+	ready := handler.NewReadinessHandler(fakeDatabase{}, fakeScanner{})
+	for name, h := range map[string]handler.APIFunc{"health": handler.HandleHealth, "ready": ready.HandleReady} {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.Make(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+name+"?fields=x", nil))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
@@ -1,18 +1,186 @@
 package handler
 
 import (
+	"cortex/repository"
 	"cortex/service"
+	"fmt"
 	"net/http"
+	"time"
 )
 
+func severityValues() []string {
+	values := make([]string, 0, len(repository.Severities))
+	for _, severity := range repository.Severities {
+		values = append(values, string(severity))
+	}
+	return values
+}
+
 type FindingHandler struct {
-	service service.FindingService
+	service     service.FindingService
+	scanService service.ScanService
 }
 
-func NewFindingHandler(service service.FindingService) *FindingHandler {
+func NewFindingHandler(service service.FindingService, scanService service.ScanService) *FindingHandler {
 	return &FindingHandler{
-		service: service,
+		service:     service,
+		scanService: scanService,
+	}
+}
+
+// parseFindingFilter parses the filters of finding lists: ?assetId=, ?type=, ?severity=, ?triageStatus=, ?limit= and
+// ?offset=.
+func parseFindingFilter(r *http.Request) (repository.FindingFilter, error) {
+	query := r.URL.Query()
+
+	assetID, err := validateQueryUUID(r, "assetId")
+	if err != nil {
+		return repository.FindingFilter{}, err
+	}
+
+	findingType, err := ValidateString(query.Get("type"), In(append(service.FindingTypeNames(), "")...)).Validate()
+	if err != nil {
+		return repository.FindingFilter{}, err
+	}
+
+	severity, err := ValidateString(query.Get("severity"), In(append(severityValues(), "")...)).Validate()
+	if err != nil {
+		return repository.FindingFilter{}, err
+	}
+	// only vulnerabilities have a severity
+	if severity != "" && findingType != "" && findingType != string(repository.FindingTypeVulnerability) {
+		return repository.FindingFilter{}, NewStructValidationError(map[string]error{
+			"severity": NewValidationError("is only supported for vulnerability findings"),
+		})
+	}
+
+	triageStatuses, err := parseTriageStatusFilter(query.Get("triageStatus"))
+	if err != nil {
+		return repository.FindingFilter{}, err
+	}
+
+	limit, err := ValidateQueryInt(r, "limit", 0, Range(1, maxPageSize))
+	if err != nil {
+		return repository.FindingFilter{}, err
 	}
+
+	offset, err := ValidateQueryInt(r, "offset", 0, Min(0))
+	if err != nil {
+		return repository.FindingFilter{}, err
+	}
+
+	return repository.FindingFilter{
+		TriageStatuses: triageStatuses,
+		AssetID:        assetID,
+		Type:           repository.FindingType(findingType),
+		Severity:       repository.Severity(severity),
+		Limit:          limit,
+		Offset:         offset,
+	}, nil
+}
+
+// HandleList lists the findings of all assets matching the filters of parseFindingFilter.
+func (h FindingHandler) HandleList(w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseFindingFilter(r)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	page := Page{Limit: filter.Limit, Offset: filter.Offset}
+	filter.Limit = page.fetchLimit()
+	findings, err := h.scanService.ListFindings(r.Context(), filter)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondPage(w, r, findings, page); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+// findingCSVColumns are the columns of the CSV export of findings, data fields the finding does not have are left
+// empty.
+var findingCSVColumns = []csvColumn[repository.AssetFindingWithAsset]{
+	{name: "endpoint", value: func(f repository.AssetFindingWithAsset) string { return f.Asset.Endpoint }},
+	{name: "type", value: func(f repository.AssetFindingWithAsset) string { return string(f.Type) }},
+	{name: "port", value: func(f repository.AssetFindingWithAsset) string { return findingDataString(f.Data, "port") }},
+	{name: "protocol", value: func(f repository.AssetFindingWithAsset) string {
+		return findingDataString(f.Data, "protocol")
+	}},
+	{name: "templateId", value: func(f repository.AssetFindingWithAsset) string {
+		return findingDataString(f.Data, "template-id")
+	}},
+	{name: "severity", value: findingSeverity},
+	{name: "firstSeen", value: func(f repository.AssetFindingWithAsset) string {
+		return f.FirstSeen.UTC().Format(time.RFC3339)
+	}},
+	{name: "lastSeen", value: func(f repository.AssetFindingWithAsset) string {
+		return f.LastSeen.UTC().Format(time.RFC3339)
+	}},
+}
+
+// findingSeverity returns the severity of a vulnerability finding, which nuclei reports in its info block.
+func findingSeverity(finding repository.AssetFindingWithAsset) string {
+	severity := findingDataString(finding.Data, "severity")
+	if info, ok := finding.Data["info"].(map[string]any); ok && severity == "" {
+		severity = findingDataString(info, "severity")
+	}
+	return severity
+}
+
+// findingDataString formats a field of the data of a finding, empty if the field is missing.
+func findingDataString(data map[string]any, field string) string {
+	value, ok := data[field]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// HandleExport exports the findings matching the filters of parseFindingFilter as CSV attachment with the columns of
+// requestedColumns. Findings are written as they are read from the database.
+func (h FindingHandler) HandleExport(w http.ResponseWriter, r *http.Request) error {
+	// CSV is the only export format
+	if _, err := ValidateString(r.URL.Query().Get(formatQueryParam), In("", formatCSV)).Validate(); err != nil {
+		return WrapError(err)
+	}
+
+	filter, err := parseFindingFilter(r)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	header, row, err := requestedColumns(r, findingCSVColumns)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	var response *CSVResponse
+	err = h.scanService.EachFinding(r.Context(), filter, func(finding repository.AssetFindingWithAsset) error {
+		if response == nil {
+			var headerErr error
+			if response, headerErr = NewCSVResponse(w, "findings.csv", header); headerErr != nil {
+				return headerErr
+			}
+		}
+		return response.Write(row(finding))
+	})
+	if err != nil {
+		if response == nil {
+			return WrapError(err)
+		}
+		// the attachment has been started and the error cannot be sent anymore, abort the response so the client does
+		// not take the truncated export for a complete one
+		panic(http.ErrAbortHandler)
+	}
+
+	if response == nil {
+		if response, err = NewCSVResponse(w, "findings.csv", header); err != nil {
+			return err
+		}
+	}
+	return response.Close()
 }
 
 func (h FindingHandler) HandleGet(w http.ResponseWriter, r *http.Request) error {
@@ -21,7 +189,31 @@ func (h FindingHandler) HandleGet(w http.ResponseWriter, r *http.Request) error
 		return WrapError(err)
 	}
 
-	finding, err := h.service.GetFinding(r.Context(), id)
+	// ?expand=asset embeds the asset the finding belongs to
+	expand, err := ValidateString(r.URL.Query().Get("expand"), In("", "asset")).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	withDeleted, err := includeDeleted(r)
+	if err != nil {
+		return WrapError(err)
+	}
+	opts := service.GetFindingOptions{IncludeDeleted: withDeleted}
+
+	if expand == "asset" {
+		findingWithAsset, err := h.service.GetFindingWithAsset(r.Context(), id, opts)
+		if err != nil {
+			return WrapError(err)
+		}
+
+		if err = RespondOne(w, r, findingWithAsset); err != nil {
+			return WrapError(err)
+		}
+		return nil
+	}
+
+	finding, err := h.service.GetFinding(r.Context(), id, opts)
 	if err != nil {
 		return WrapError(err)
 	}
@@ -31,3 +223,17 @@ func (h FindingHandler) HandleGet(w http.ResponseWriter, r *http.Request) error
 	}
 	return nil
 }
+
+// HandleRecomputeHashes recalculates the hashes of all stored findings, e.g. after the hashing has changed, and merges
+// findings that turn out to be duplicates.
+func (h FindingHandler) HandleRecomputeHashes(w http.ResponseWriter, r *http.Request) error {
+	result, err := h.service.RecomputeFindingHashes(r.Context())
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, result); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
@@ -2,15 +2,20 @@ package handler_test
 
 import (
 	"context"
+	cortexContext "cortex/context"
 	"cortex/handler"
 	"cortex/repository"
 	"cortex/service"
 	"cortex/test"
+	"encoding/csv"
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockFindingService struct {
@@ -25,17 +30,41 @@ func (m *MockFindingService) CreateFinding(ctx context.Context, opts service.Cre
 	return args.Get(0).(*repository.AssetFinding), args.Error(1)
 }
 
-func (m *MockFindingService) GetFinding(ctx context.Context, id string) (*repository.AssetFinding, error) {
-	args := m.Called(ctx, id)
+func (m *MockFindingService) GetFinding(ctx context.Context, id string, opts service.GetFindingOptions) (*repository.AssetFinding, error) {
+	args := m.Called(ctx, id, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*repository.AssetFinding), args.Error(1)
 }
 
+func (m *MockFindingService) GetFindingWithAsset(ctx context.Context, id string, opts service.GetFindingOptions) (*repository.AssetFindingWithAsset, error) {
+	args := m.Called(ctx, id, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.AssetFindingWithAsset), args.Error(1)
+}
+
+func (m *MockFindingService) UpdateFindingTriage(ctx context.Context, assetID string, findingID string, status repository.TriageStatus) (*repository.AssetFinding, error) {
+	args := m.Called(ctx, assetID, findingID, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.AssetFinding), args.Error(1)
+}
+
+func (m *MockFindingService) RecomputeFindingHashes(ctx context.Context) (*service.FindingRehashResult, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.FindingRehashResult), args.Error(1)
+}
+
 func TestGetFinding_Success(t *testing.T) {
 	mockService := new(MockFindingService)
-	h := handler.NewFindingHandler(mockService)
+	h := handler.NewFindingHandler(mockService, new(MockScanService))
 
 	testID := "5a7bdb69-d7d6-482f-a653-2ab01480999f"
 	finding := &repository.AssetFinding{
@@ -44,7 +73,7 @@ func TestGetFinding_Success(t *testing.T) {
 		Type:    repository.FindingTypePort,
 	}
 
-	mockService.On("GetFinding", mock.Anything, testID).Return(finding, nil)
+	mockService.On("GetFinding", mock.Anything, testID, service.GetFindingOptions{}).Return(finding, nil)
 
 	runner := test.NewTestRunner(h.HandleGet)
 	runner.WithPath("id", testID).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
@@ -52,9 +81,9 @@ func TestGetFinding_Success(t *testing.T) {
 
 func TestGetFinding_NotFound(t *testing.T) {
 	mockService := new(MockFindingService)
-	h := handler.NewFindingHandler(mockService)
+	h := handler.NewFindingHandler(mockService, new(MockScanService))
 
-	mockService.On("GetFinding", mock.Anything, "missing-id").Return(nil, errors.New("not found"))
+	mockService.On("GetFinding", mock.Anything, "missing-id", service.GetFindingOptions{}).Return(nil, errors.New("not found"))
 
 	runner := test.NewTestRunner(h.HandleGet)
 	res := runner.WithPath("id", "missing-id").Run(t)
@@ -62,3 +91,258 @@ func TestGetFinding_NotFound(t *testing.T) {
 		t.Error("expected error")
 	}
 }
+
+func TestGetFinding_Default(t *testing.T) {
+	mockService := new(MockFindingService)
+	h := handler.NewFindingHandler(mockService, new(MockScanService))
+
+	testID := "5a7bdb69-d7d6-482f-a653-2ab01480999f"
+	finding := &repository.AssetFinding{ID: testID, AssetID: "7761259c-e6dd-4930-946b-ee9975fde3e4"}
+	mockService.On("GetFinding", mock.Anything, testID, service.GetFindingOptions{}).Return(finding, nil)
+
+	runner := test.NewTestRunner(h.HandleGet)
+	res := runner.WithPath("id", testID).Run(t).ExpectNoError()
+
+	assert.NotContains(t, res.RR.Body.String(), `"asset":`)
+	mockService.AssertNotCalled(t, "GetFindingWithAsset", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetFinding_ExpandAsset(t *testing.T) {
+	mockService := new(MockFindingService)
+	h := handler.NewFindingHandler(mockService, new(MockScanService))
+
+	testID := "5a7bdb69-d7d6-482f-a653-2ab01480999f"
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	mockService.On("GetFindingWithAsset", mock.Anything, testID, service.GetFindingOptions{}).Return(&repository.AssetFindingWithAsset{
+		AssetFinding: repository.AssetFinding{ID: testID, AssetID: assetID, Type: repository.FindingTypePort},
+		Asset:        repository.ScanAsset{ID: assetID, Endpoint: "example.com"},
+	}, nil)
+
+	runner := test.NewTestRunner(h.HandleGet)
+	res := runner.WithPath("id", testID).WithQuery("expand", "asset").Run(t).ExpectNoError()
+
+	body := res.RR.Body.String()
+	assert.Contains(t, body, `"asset":{"id":"`+assetID+`","endpoint":"example.com"}`)
+	assert.Contains(t, body, `"type":"port"`)
+	mockService.AssertNotCalled(t, "GetFinding", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetFinding_IncludeDeleted(t *testing.T) {
+	mockService := new(MockFindingService)
+	h := handler.NewFindingHandler(mockService, new(MockScanService))
+
+	testID := "5a7bdb69-d7d6-482f-a653-2ab01480999f"
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	mockService.On("GetFindingWithAsset", mock.Anything, testID, service.GetFindingOptions{IncludeDeleted: true}).
+		Return(&repository.AssetFindingWithAsset{
+			AssetFinding: repository.AssetFinding{ID: testID, AssetID: assetID, Type: repository.FindingTypePort},
+			Asset:        repository.ScanAsset{ID: assetID, Endpoint: "example.com", Deleted: true},
+		}, nil)
+
+	admin := cortexContext.UserInfoData{UserID: "admin", Role: string(repository.UserRoleAdmin)}
+	res := test.NewTestRunner(h.HandleGet).WithPath("id", testID).WithQuery("expand", "asset").
+		WithQuery("includeDeleted", "true").WithContextValue(cortexContext.KeyUserInfo, admin).
+		Run(t).ExpectNoError()
+	assert.Contains(t, res.RR.Body.String(), `"asset":{"id":"`+assetID+`","endpoint":"example.com","deleted":true}`)
+
+	test.NewTestRunner(h.HandleGet).WithPath("id", testID).WithQuery("includeDeleted", "true").
+		WithContextValue(cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"}).
+		Run(t).ExpectAPIError(http.StatusForbidden)
+}
+
+func TestGetFinding_UnknownExpand(t *testing.T) {
+	mockService := new(MockFindingService)
+	h := handler.NewFindingHandler(mockService, new(MockScanService))
+
+	runner := test.NewTestRunner(h.HandleGet)
+	runner.WithPath("id", "5a7bdb69-d7d6-482f-a653-2ab01480999f").WithQuery("expand", "agent").
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestRecomputeFindingHashes(t *testing.T) {
+	mockService := new(MockFindingService)
+	h := handler.NewFindingHandler(mockService, new(MockScanService))
+
+	mockService.On("RecomputeFindingHashes", mock.Anything).
+		Return(&service.FindingRehashResult{Assets: 3, Updated: 5, Merged: 2}, nil)
+
+	res := test.NewTestRunner(h.HandleRecomputeHashes).Run(t).ExpectNoError()
+	assert.Contains(t, res.RR.Body.String(), `"data":{"assets":3,"updated":5,"merged":2}`)
+}
+
+func TestListFindings_Filter(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewFindingHandler(new(MockFindingService), scanService)
+
+	assetID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	scanService.On("ListFindings", mock.Anything, repository.FindingFilter{
+		AssetID:  assetID,
+		Type:     repository.FindingTypeVulnerability,
+		Severity: repository.SeverityCritical,
+		Limit:    51,
+		Offset:   100,
+	}).Return([]repository.AssetFinding{
+		{ID: "5a7bdb69-d7d6-482f-a653-2ab01480999f", AssetID: assetID, Type: repository.FindingTypeVulnerability},
+	}, nil)
+
+	res := test.NewTestRunner(h.HandleList).
+		WithQuery("assetId", assetID).
+		WithQuery("type", "vulnerability").
+		WithQuery("severity", "critical").
+		WithQuery("limit", "50").
+		WithQuery("offset", "100").
+		Run(t).ExpectNoError()
+
+	assert.Contains(t, res.RR.Body.String(), `"type":"vulnerability"`)
+	scanService.AssertExpectations(t)
+}
+
+func TestListFindings_TriageStatus(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewFindingHandler(new(MockFindingService), scanService)
+
+	scanService.On("ListFindings", mock.Anything, repository.FindingFilter{
+		TriageStatuses: repository.TriageStatuses,
+	}).Return([]repository.AssetFinding{}, nil)
+
+	test.NewTestRunner(h.HandleList).WithQuery("triageStatus", "all").Run(t).ExpectNoError()
+	scanService.AssertExpectations(t)
+}
+
+func TestListFindings_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]string
+	}{
+		{name: "asset id", params: map[string]string{"assetId": "example.com"}},
+		{name: "type", params: map[string]string{"type": "secret"}},
+		{name: "severity", params: map[string]string{"severity": "severe"}},
+		{name: "severity of ports", params: map[string]string{"type": "port", "severity": "high"}},
+		{name: "triage status", params: map[string]string{"triageStatus": "closed"}},
+		{name: "limit", params: map[string]string{"limit": "0"}},
+		{name: "offset", params: map[string]string{"offset": "-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewFindingHandler(new(MockFindingService), new(MockScanService))
+
+			runner := test.NewTestRunner(h.HandleList)
+			for param, value := range tt.params {
+				runner = runner.WithQuery(param, value)
+			}
+			runner.Run(t).ExpectAPIError(http.StatusBadRequest)
+		})
+	}
+}
+
+func TestExportFindings_CSV(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewFindingHandler(new(MockFindingService), scanService)
+
+	firstSeen := time.Date(2025, 12, 1, 8, 0, 0, 0, time.UTC)
+	lastSeen := time.Date(2025, 12, 10, 10, 0, 0, 0, time.UTC)
+	asset := repository.ScanAsset{ID: "7761259c-e6dd-4930-946b-ee9975fde3e4", Endpoint: "example.com"}
+	scanService.On("EachFinding", mock.Anything, repository.FindingFilter{Type: repository.FindingTypeVulnerability}).
+		Return([]repository.AssetFindingWithAsset{
+			{AssetFinding: repository.AssetFinding{Type: repository.FindingTypeVulnerability, FirstSeen: firstSeen,
+				LastSeen: lastSeen, Data: map[string]any{"template-id": "CVE-2021-44228", "severity": "critical",
+					"port": float64(8080)}}, Asset: asset},
+			// nuclei reports the severity within the template info
+			{AssetFinding: repository.AssetFinding{Type: repository.FindingTypeVulnerability, FirstSeen: firstSeen,
+				LastSeen: lastSeen, Data: map[string]any{"template-id": "=cmd", "info": map[string]any{"severity": "low"},
+					"port": float64(443)}}, Asset: asset},
+		}, nil)
+
+	res := test.NewTestRunner(h.HandleExport).
+		WithQuery("format", "csv").
+		WithQuery("type", "vulnerability").
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Equal(t, "text/csv; charset=utf-8", res.RR.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="findings.csv"`, res.RR.Header().Get("Content-Disposition"))
+
+	records, err := csv.NewReader(res.RR.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"endpoint", "type", "port", "protocol", "templateId", "severity", "firstSeen", "lastSeen"},
+		{"example.com", "vulnerability", "8080", "", "CVE-2021-44228", "critical", "2025-12-01T08:00:00Z", "2025-12-10T10:00:00Z"},
+		{"example.com", "vulnerability", "443", "", "'=cmd", "low", "2025-12-01T08:00:00Z", "2025-12-10T10:00:00Z"},
+	}, records)
+}
+
+func TestExportFindings_Columns(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewFindingHandler(new(MockFindingService), scanService)
+
+	asset := repository.ScanAsset{ID: "7761259c-e6dd-4930-946b-ee9975fde3e4", Endpoint: "example.com"}
+	scanService.On("EachFinding", mock.Anything, repository.FindingFilter{}).
+		Return([]repository.AssetFindingWithAsset{
+			{AssetFinding: repository.AssetFinding{Type: repository.FindingTypeVulnerability,
+				Data: map[string]any{"template-id": "CVE-2021-44228", "severity": "critical"}}, Asset: asset},
+		}, nil)
+
+	res := test.NewTestRunner(h.HandleExport).
+		WithQuery("columns", "severity:Risk, endpoint:Host,templateId").
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+
+	records, err := csv.NewReader(res.RR.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Risk", "Host", "templateId"},
+		{"critical", "example.com", "CVE-2021-44228"},
+	}, records)
+}
+
+func TestExportFindings_InvalidColumns(t *testing.T) {
+	for _, columns := range []string{"endpoint,password", "endpoint:", ","} {
+		t.Run(columns, func(t *testing.T) {
+			scanService := new(MockScanService)
+			h := handler.NewFindingHandler(new(MockFindingService), scanService)
+
+			test.NewTestRunner(h.HandleExport).WithQuery("columns", columns).
+				Run(t).ExpectAPIError(http.StatusBadRequest)
+			scanService.AssertNotCalled(t, "EachFinding", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestExportFindings_Empty(t *testing.T) {
+	scanService := new(MockScanService)
+	h := handler.NewFindingHandler(new(MockFindingService), scanService)
+
+	scanService.On("EachFinding", mock.Anything, repository.FindingFilter{}).
+		Return([]repository.AssetFindingWithAsset{}, nil)
+
+	res := test.NewTestRunner(h.HandleExport).Run(t).ExpectNoError()
+	assert.Equal(t, "endpoint,type,port,protocol,templateId,severity,firstSeen,lastSeen\n", res.RR.Body.String())
+}
+
+func TestExportFindings_Errors(t *testing.T) {
+	t.Run("json format", func(t *testing.T) {
+		h := handler.NewFindingHandler(new(MockFindingService), new(MockScanService))
+		test.NewTestRunner(h.HandleExport).WithQuery("format", "json").Run(t).ExpectAPIError(http.StatusBadRequest)
+	})
+
+	t.Run("before the first row", func(t *testing.T) {
+		scanService := new(MockScanService)
+		h := handler.NewFindingHandler(new(MockFindingService), scanService)
+		scanService.On("EachFinding", mock.Anything, mock.Anything).Return(nil, service.ErrDatabaseUnavailable)
+
+		res := test.NewTestRunner(h.HandleExport).Run(t)
+		assert.Error(t, res.Error)
+		assert.Empty(t, res.RR.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("after the first row", func(t *testing.T) {
+		scanService := new(MockScanService)
+		h := handler.NewFindingHandler(new(MockFindingService), scanService)
+		scanService.On("EachFinding", mock.Anything, mock.Anything).Return([]repository.AssetFindingWithAsset{
+			{AssetFinding: repository.AssetFinding{Type: repository.FindingTypePort}},
+		}, errors.New("connection reset"))
+
+		// the export is aborted rather than ended like a complete one
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			test.NewTestRunner(h.HandleExport).Run(t)
+		})
+	})
+}
@@ -1,7 +1,68 @@
 package handler
 
-import "net/http"
+import (
+	"context"
+	"cortex/service"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// readinessTimeout is the maximum time the readiness check waits for the database.
+const readinessTimeout = 2 * time.Second
+
+// Database runs queries, implemented by *pgxpool.Pool.
+type Database interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// ScannerHealthSource summarizes the scanner status reported by the agents, implemented by service.AgentService.
+type ScannerHealthSource interface {
+	ScannerHealth(ctx context.Context) (*service.ScannerHealth, error)
+}
+
+type readinessResponse struct {
+	Status  string                 `json:"status"`
+	Scanner *service.ScannerHealth `json:"scanner"`
+}
 
 func HandleHealth(w http.ResponseWriter, r *http.Request) error {
 	return RespondOne(w, r, "OK")
 }
+
+type ReadinessHandler struct {
+	db      Database
+	scanner ScannerHealthSource
+}
+
+func NewReadinessHandler(db Database, scanner ScannerHealthSource) *ReadinessHandler {
+	return &ReadinessHandler{
+		db:      db,
+		scanner: scanner,
+	}
+}
+
+// HandleReady responds with 503 while the database cannot be queried. Unlike HandleHealth, it reports whether the
+// server can handle requests rather than whether it is alive. The response includes the scanner health of the agents,
+// which does not affect the status code, as the API stays usable without agents. The scanner status is reported as
+// unknown when it cannot be read.
+func (h ReadinessHandler) HandleReady(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	var one int
+	if err := h.db.QueryRow(ctx, "SELECT 1").Scan(&one); err != nil {
+		apiErr := ServiceUnavailable("database is not reachable", databaseRetryAfter)
+		apiErr.Err = err
+		return apiErr
+	}
+
+	// the service logs the error, the probe does not fail as the API stays usable without agents
+	scanner, err := h.scanner.ScannerHealth(ctx)
+	if err != nil {
+		scanner = &service.ScannerHealth{Status: service.ScannerHealthUnknown}
+	}
+
+	return RespondOne(w, r, readinessResponse{Status: "OK", Scanner: scanner})
+}
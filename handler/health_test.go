@@ -1,10 +1,19 @@
 package handler_test
 
 import (
+	"context"
 	"cortex/handler"
+	"cortex/service"
 	"cortex/test"
+	"errors"
+	"net"
 	"net/http"
 	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHealthy(t *testing.T) {
@@ -12,3 +21,86 @@ func TestHealthy(t *testing.T) {
 	res := runner.Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
 	test.AssertSingleAPIResponse(res, "OK")
 }
+
+// fakeDatabase answers every query with a single row holding 1, or with err.
+type fakeDatabase struct {
+	err error
+}
+
+func (d fakeDatabase) QueryRow(context.Context, string, ...any) pgx.Row {
+	return fakeRow(d)
+}
+
+type fakeRow struct {
+	err error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*int) = 1
+	return nil
+}
+
+// fakeScanner reports a fixed scanner health.
+type fakeScanner struct {
+	health service.ScannerHealth
+	err    error
+}
+
+func (s fakeScanner) ScannerHealth(context.Context) (*service.ScannerHealth, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &s.health, nil
+}
+
+func TestReady(t *testing.T) {
+	h := handler.NewReadinessHandler(fakeDatabase{}, fakeScanner{})
+	res := test.NewTestRunner(h.HandleReady).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"status":"OK"`)
+}
+
+func TestReady_ScannerHealth(t *testing.T) {
+	// a connect-only agent and one whose runner failed to initialize, the server stays ready without working agents
+	scanner := fakeScanner{health: service.ScannerHealth{
+		Status:       service.ScannerHealthDegraded,
+		OnlineAgents: 2,
+		ConnectOnly:  1,
+		Failing:      1,
+	}}
+	h := handler.NewReadinessHandler(fakeDatabase{}, scanner)
+	res := test.NewTestRunner(h.HandleReady).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(),
+		`"scanner":{"status":"degraded","onlineAgents":2,"synCapable":0,"connectOnly":1,"failing":1,"unreported":0}`)
+}
+
+func TestReady_ScannerHealthUnknown(t *testing.T) {
+	h := handler.NewReadinessHandler(fakeDatabase{}, fakeScanner{err: errors.New("statement timeout")})
+	res := test.NewTestRunner(h.HandleReady).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"status":"OK"`)
+	assert.Contains(t, res.RR.Body.String(), `"scanner":{"status":"unknown"`)
+}
+
+func TestReady_DatabaseDown(t *testing.T) {
+	h := handler.NewReadinessHandler(fakeDatabase{err: errors.New("connection refused")}, fakeScanner{})
+	test.NewTestRunner(h.HandleReady).Run(t).ExpectAPIError(http.StatusServiceUnavailable)
+}
+
+func TestReady_PoolUnreachable(t *testing.T) {
+	// reserve a port and close it again, so connections are refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	pool, err := pgxpool.New(context.Background(), "postgres://cortex@"+address+"/cortex?sslmode=disable")
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	h := handler.NewReadinessHandler(pool, fakeScanner{})
+	res := test.NewTestRunner(h.HandleReady).Run(t)
+	res.ExpectAPIError(http.StatusServiceUnavailable)
+	assert.Contains(t, res.Error.Error(), "database is not reachable")
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"cortex/service"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+)
+
+// Cookies keeping the values of a login with the OpenID Connect provider between redirect and callback.
+const (
+	oidcStateCookie    = "cortex_oidc_state"
+	oidcNonceCookie    = "cortex_oidc_nonce"
+	oidcVerifierCookie = "cortex_oidc_verifier"
+	oidcCookiePath     = "/auth/oidc"
+	// oidcLoginTimeout is the time users have to log in at the provider.
+	oidcLoginTimeout = 10 * time.Minute
+)
+
+// OIDCHandler logs users in with an OpenID Connect provider using the authorization code flow.
+type OIDCHandler struct {
+	provider    service.OIDCProvider
+	authService service.AuthService
+}
+
+func NewOIDCHandler(provider service.OIDCProvider, authService service.AuthService) *OIDCHandler {
+	return &OIDCHandler{
+		provider:    provider,
+		authService: authService,
+	}
+}
+
+// HandleLogin redirects to the login of the provider.
+func (h OIDCHandler) HandleLogin(w http.ResponseWriter, r *http.Request) error {
+	state := randomValue()
+	nonce := randomValue()
+	verifier := randomValue()
+
+	setOIDCCookie(w, r, oidcStateCookie, state, int(oidcLoginTimeout.Seconds()))
+	setOIDCCookie(w, r, oidcNonceCookie, nonce, int(oidcLoginTimeout.Seconds()))
+	setOIDCCookie(w, r, oidcVerifierCookie, verifier, int(oidcLoginTimeout.Seconds()))
+
+	http.Redirect(w, r, h.provider.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+	return nil
+}
+
+// HandleCallback completes the login at the provider and responds with a session token like the username and
+// password login. Users logging in for the first time are created.
+func (h OIDCHandler) HandleCallback(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		return Unauthorized("login at identity provider failed")
+	}
+
+	state, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state.Value), []byte(query.Get("state"))) != 1 {
+		return Unauthorized("invalid login state")
+	}
+	nonce, err := r.Cookie(oidcNonceCookie)
+	if err != nil {
+		return Unauthorized("invalid login state")
+	}
+	verifier, err := r.Cookie(oidcVerifierCookie)
+	if err != nil {
+		return Unauthorized("invalid login state")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return NewValidationError("code is required")
+	}
+
+	// the values are single use
+	for _, name := range []string{oidcStateCookie, oidcNonceCookie, oidcVerifierCookie} {
+		setOIDCCookie(w, r, name, "", -1)
+	}
+
+	identity, err := h.provider.Exchange(r.Context(), code, nonce.Value, verifier.Value)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	user, err := h.authService.LoginExternalUser(r.Context(), *identity)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	src := r.RemoteAddr
+	if r.Header.Get("X-Forwarded-For") != "" {
+		src = r.Header.Get("X-Forwarded-For")
+	}
+
+	tokenOptions := service.CreateTokenOptions{
+		UserID:    user.ID,
+		UserAgent: r.UserAgent(),
+		SourceIP:  src,
+	}
+
+	_, tokenString, err := h.authService.CreateSessionToken(r.Context(), tokenOptions)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	response := tokenResponse{
+		Token: tokenString,
+		User:  user,
+	}
+
+	if err = RespondOne(w, r, response); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func setOIDCCookie(w http.ResponseWriter, r *http.Request, name string, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		// the callback is a top-level navigation from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// randomValue returns 256 random bits, URL safe encoded. It is long enough to be used as PKCE verifier.
+func randomValue() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,125 @@
+package handler_test
+
+import (
+	"context"
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockOIDCProvider struct {
+	mock.Mock
+}
+
+func (m *MockOIDCProvider) AuthCodeURL(state string, nonce string, verifier string) string {
+	args := m.Called(state, nonce, verifier)
+	return args.String(0)
+}
+
+func (m *MockOIDCProvider) Exchange(ctx context.Context, code string, nonce string, verifier string) (*service.ExternalIdentity, error) {
+	args := m.Called(ctx, code, nonce, verifier)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ExternalIdentity), args.Error(1)
+}
+
+// oidcCallbackRequest returns a callback request with the cookies set by the login redirect.
+func oidcCallbackRequest(query string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query, nil)
+	req.AddCookie(&http.Cookie{Name: "cortex_oidc_state", Value: "state"})
+	req.AddCookie(&http.Cookie{Name: "cortex_oidc_nonce", Value: "nonce"})
+	req.AddCookie(&http.Cookie{Name: "cortex_oidc_verifier", Value: "verifier"})
+	return req
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := new(MockOIDCProvider)
+	h := handler.NewOIDCHandler(provider, new(MockAuthService))
+
+	provider.On("AuthCodeURL", mock.Anything, mock.Anything, mock.Anything).
+		Return("https://idp.example.com/authorize?state=state")
+
+	rr := httptest.NewRecorder()
+	handler.Make(h.HandleLogin).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://idp.example.com/authorize?state=state", rr.Header().Get("Location"))
+
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range rr.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, "/auth/oidc", cookie.Path)
+	}
+	require.Len(t, cookies, 3)
+	call := provider.Calls[0]
+	assert.Equal(t, cookies["cortex_oidc_state"].Value, call.Arguments.String(0))
+	assert.Equal(t, cookies["cortex_oidc_nonce"].Value, call.Arguments.String(1))
+	assert.Equal(t, cookies["cortex_oidc_verifier"].Value, call.Arguments.String(2))
+	assert.GreaterOrEqual(t, len(call.Arguments.String(2)), 43)
+}
+
+func TestOIDCCallback(t *testing.T) {
+	provider := new(MockOIDCProvider)
+	authService := new(MockAuthService)
+	h := handler.NewOIDCHandler(provider, authService)
+
+	identity := &service.ExternalIdentity{Provider: repository.UserProviderOIDC, Subject: "sub", Username: "jdoe"}
+	user := &repository.User{ID: "user", Provider: repository.UserProviderOIDC, Username: "jdoe"}
+	provider.On("Exchange", mock.Anything, "code", "nonce", "verifier").Return(identity, nil)
+	authService.On("LoginExternalUser", mock.Anything, *identity).Return(user, nil)
+	authService.On("CreateSessionToken", mock.Anything, mock.MatchedBy(func(opt service.CreateTokenOptions) bool {
+		return opt.UserID == "user"
+	})).Return(&repository.AuthToken{ID: "a1b2c3d4"}, "a1b2c3d4.secret", nil)
+
+	rr := httptest.NewRecorder()
+	handler.Make(h.HandleCallback).ServeHTTP(rr, oidcCallbackRequest("state=state&code=code"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"token":"a1b2c3d4.secret"`)
+	assert.Contains(t, rr.Body.String(), `"provider":"oidc"`)
+	for _, cookie := range rr.Result().Cookies() {
+		assert.Negative(t, cookie.MaxAge, cookie.Name)
+	}
+	authService.AssertExpectations(t)
+}
+
+func TestOIDCCallback_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(provider *MockOIDCProvider)
+	}{
+		{name: "state mismatch", req: oidcCallbackRequest("state=other&code=code")},
+		{name: "no cookies", req: httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?state=state&code=code", nil)},
+		{name: "provider error", req: oidcCallbackRequest("state=state&error=access_denied")},
+		{name: "invalid code", req: oidcCallbackRequest("state=state&code=invalid"), check: func(p *MockOIDCProvider) {
+			p.On("Exchange", mock.Anything, "invalid", "nonce", "verifier").Return(nil, service.ErrUnauthenticated)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := new(MockOIDCProvider)
+			authService := new(MockAuthService)
+			if tt.check != nil {
+				tt.check(provider)
+			}
+			h := handler.NewOIDCHandler(provider, authService)
+
+			rr := httptest.NewRecorder()
+			handler.Make(h.HandleCallback).ServeHTTP(rr, tt.req)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			authService.AssertNotCalled(t, "CreateSessionToken", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,812 @@
+package handler
+
+import (
+	"cortex/service"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// openAPIObject is an object of the OpenAPI document.
+type openAPIObject = map[string]any
+
+// openAPIParameter is a query parameter of an operation.
+type openAPIParameter struct {
+	name        string
+	description string
+	schema      openAPIObject
+}
+
+// openAPIOperation describes a route of the server.
+type openAPIOperation struct {
+	method  string
+	path    string
+	tag     string
+	summary string
+	query   []openAPIParameter
+	// request is the component schema of the request body, empty if there is none.
+	request string
+	// response is the schema of the response data, nil if the response has no body.
+	response openAPIObject
+	// list responds with an array of response items.
+	list bool
+	// mediaType is the media type of responses that are not wrapped in a data response, e.g. CSV exports. The
+	// response schema describes the body as it is.
+	mediaType string
+	// status is the status of successful responses, 200 if not set.
+	status int
+	// public operations do not require authentication.
+	public bool
+	// admin operations require the admin role.
+	admin bool
+}
+
+// openAPIOperations are the documented routes, they have to be kept in line with the routes of the server.
+var openAPIOperations = []openAPIOperation{
+	// probes
+	{method: http.MethodGet, path: "/health", tag: "health", summary: "Check whether the server is alive",
+		response: stringSchema(), public: true},
+	{method: http.MethodGet, path: "/ready", tag: "health",
+		summary: "Check whether the server can handle requests", response: refSchema("Readiness"), public: true},
+	{method: http.MethodGet, path: "/openapi.json", tag: "health", summary: "Get the OpenAPI document of the API",
+		response: freeFormSchema(), mediaType: "application/json", public: true},
+
+	// auth
+	{method: http.MethodPost, path: "/auth", tag: "auth", summary: "Log in with username and password",
+		request: "LoginRequest", response: refSchema("Token"), public: true},
+	{method: http.MethodGet, path: "/auth", tag: "auth", summary: "Get the user of the session token",
+		response: refSchema("User")},
+	{method: http.MethodGet, path: "/auth/check", tag: "auth", summary: "Check whether the session token is valid",
+		public: true},
+	{method: http.MethodPost, path: "/auth/refresh", tag: "auth",
+		summary: "Replace the session token with a new one", response: refSchema("Token")},
+	{method: http.MethodGet, path: "/auth/oidc/login", tag: "auth",
+		summary: "Redirect to the OpenID Connect provider for login, if one is configured", status: http.StatusFound,
+		public: true},
+	{method: http.MethodGet, path: "/auth/oidc/callback", tag: "auth",
+		summary: "Complete the login at the OpenID Connect provider",
+		query: []openAPIParameter{
+			{name: "code", description: "Authorization code issued by the provider", schema: stringSchema()},
+			{name: "state", description: "State of the login started with /auth/oidc/login", schema: stringSchema()},
+			{name: "error", description: "Error reported by the provider", schema: stringSchema()},
+		},
+		response: refSchema("Token"), public: true},
+
+	// users
+	{method: http.MethodGet, path: "/users", tag: "users", summary: "List users",
+		query: []openAPIParameter{fieldsParameter}, response: refSchema("User"), list: true, admin: true},
+	{method: http.MethodPost, path: "/users", tag: "users", summary: "Create a user",
+		request: "CreateUserRequest", response: refSchema("User"), status: http.StatusCreated, admin: true},
+	{method: http.MethodGet, path: "/users/{id}", tag: "users", summary: "Get a user",
+		query: []openAPIParameter{fieldsParameter}, response: refSchema("User"), admin: true},
+	{method: http.MethodPatch, path: "/users/{id}", tag: "users", summary: "Update a user",
+		request: "UpdateUserRequest", response: refSchema("User"), admin: true},
+	{method: http.MethodPost, path: "/users/{id}/password", tag: "users",
+		summary: "Change the password of a user, admins reset the password of other users",
+		request: "ChangePasswordRequest", status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/users/{id}/tokens", tag: "users",
+		summary: "List the session tokens of a user", query: []openAPIParameter{fieldsParameter},
+		response: refSchema("SessionToken"), list: true},
+	{method: http.MethodDelete, path: "/users/{id}/tokens/{tokenId}", tag: "users",
+		summary: "Revoke a session token of a user", status: http.StatusNoContent},
+
+	// assets
+	{method: http.MethodGet, path: "/assets", tag: "assets", summary: "List assets",
+		query:    []openAPIParameter{statsParameter, tagParameter, formatParameter, columnsParameter, fieldsParameter},
+		response: assetOrStatsSchema(), list: true},
+	{method: http.MethodPost, path: "/assets", tag: "assets", summary: "Create an asset",
+		request: "CreateAssetRequest", response: refSchema("Asset"), status: http.StatusCreated},
+	{method: http.MethodPost, path: "/assets/bulk", tag: "assets", summary: "Create multiple assets",
+		request: "CreateAssetsRequest", response: refSchema("CreateAssetsResult"), status: http.StatusCreated},
+	{method: http.MethodGet, path: "/assets/{id}", tag: "assets", summary: "Get an asset",
+		query:    []openAPIParameter{statsParameter, includeDeletedParameter, fieldsParameter},
+		response: assetOrStatsSchema()},
+	{method: http.MethodPut, path: "/assets/{id}", tag: "assets", summary: "Update an asset",
+		request: "UpdateAssetRequest", response: refSchema("Asset")},
+	{method: http.MethodPatch, path: "/assets/{id}/tags", tag: "assets", summary: "Replace the tags of an asset",
+		request: "UpdateAssetTagsRequest", response: refSchema("Asset")},
+	{method: http.MethodDelete, path: "/assets/{id}", tag: "assets", summary: "Delete an asset",
+		query: []openAPIParameter{{name: "hard", schema: booleanSchema(),
+			description: "Remove the asset with its findings and history instead of marking it as deleted, admins only"}},
+		response: refSchema("Asset")},
+	{method: http.MethodPost, path: "/assets/{id}/restore", tag: "assets", summary: "Restore a deleted asset",
+		response: refSchema("Asset")},
+	{method: http.MethodGet, path: "/assets/{id}/findings", tag: "assets", summary: "List the findings of an asset",
+		query: []openAPIParameter{triageStatusParameter, fieldsParameter}, response: refSchema("Finding"),
+		list: true},
+	{method: http.MethodPost, path: "/assets/{id}/findings", tag: "assets", summary: "Report a finding of an asset",
+		request: "CreateFindingRequest", response: refSchema("Finding"), status: http.StatusCreated},
+	{method: http.MethodPatch, path: "/assets/{id}/findings/{findingId}", tag: "assets",
+		summary: "Triage a finding", request: "UpdateFindingRequest", response: refSchema("Finding")},
+	{method: http.MethodGet, path: "/assets/{id}/history", tag: "assets", summary: "List the history of an asset",
+		query:    []openAPIParameter{includeDeletedParameter, fieldsParameter},
+		response: refSchema("AssetHistoryEntry"), list: true},
+
+	// scan configurations
+	{method: http.MethodGet, path: "/scan-configs", tag: "scan-configs", summary: "List scan configurations",
+		query: []openAPIParameter{fieldsParameter}, response: refSchema("ScanConfiguration"), list: true},
+	{method: http.MethodPost, path: "/scan-configs", tag: "scan-configs", summary: "Create a scan configuration",
+		request: "CreateScanConfigRequest", response: refSchema("ScanConfiguration"), status: http.StatusCreated},
+	{method: http.MethodGet, path: "/scan-configs/{id}", tag: "scan-configs", summary: "Get a scan configuration",
+		query: []openAPIParameter{fieldsParameter}, response: refSchema("ScanConfiguration")},
+	{method: http.MethodPut, path: "/scan-configs/{id}", tag: "scan-configs", summary: "Update a scan configuration",
+		query: []openAPIParameter{{name: "force", schema: booleanSchema(),
+			description: "Change the engine although scans of the configuration are queued or running"}},
+		request: "UpdateScanConfigRequest", response: refSchema("ScanConfiguration")},
+	{method: http.MethodDelete, path: "/scan-configs/{id}", tag: "scan-configs",
+		summary: "Delete a scan configuration", response: refSchema("ScanConfiguration")},
+	{method: http.MethodPatch, path: "/scan-configs/{id}/assets", tag: "scan-configs",
+		summary: "Replace the default assets of a scan configuration", request: "UpdateScanConfigAssetsRequest",
+		response: refSchema("Asset"), list: true},
+	{method: http.MethodGet, path: "/scan-configs/{id}/scans", tag: "scan-configs",
+		summary: "List the scans of a scan configuration", query: []openAPIParameter{includeCountsParameter},
+		response: refSchema("Scan"), list: true},
+	{method: http.MethodGet, path: "/scan-engines", tag: "scan-configs", summary: "List the scan engines",
+		response: refSchema("ScanEngine"), list: true},
+
+	// scans
+	{method: http.MethodGet, path: "/scans", tag: "scans", summary: "List scans",
+		query: []openAPIParameter{includeCountsParameter, fieldsParameter}, response: refSchema("Scan"), list: true},
+	{method: http.MethodPost, path: "/scans", tag: "scans", summary: "Queue a scan",
+		request: "RunScanRequest", response: refSchema("Scan")},
+	{method: http.MethodPost, path: "/scans/preview-targets", tag: "scans",
+		summary: "Preview the assets a scan would scan", request: "RunScanRequest",
+		response: refSchema("ScanTargets")},
+	{method: http.MethodPost, path: "/scans/bulk-status", tag: "scans", summary: "Change the status of multiple scans",
+		request: "BulkScanStatusRequest", response: refSchema("ScanStatusResult"), list: true,
+		status: http.StatusMultiStatus, admin: true},
+	{method: http.MethodGet, path: "/scans/{id}", tag: "scans", summary: "Get a scan",
+		query: []openAPIParameter{fieldsParameter}, response: refSchema("Scan")},
+	{method: http.MethodPatch, path: "/scans/{id}", tag: "scans", summary: "Report the progress of a scan",
+		request: "UpdateScanRequest", response: refSchema("Scan")},
+	{method: http.MethodDelete, path: "/scans/{id}", tag: "scans", summary: "Delete a scan",
+		response: refSchema("Scan")},
+	{method: http.MethodGet, path: "/scans/{id}/assets", tag: "scans", summary: "List the assets of a scan",
+		query: []openAPIParameter{limitParameter, offsetParameter}, response: refSchema("Asset"), list: true},
+	{method: http.MethodGet, path: "/scans/{id}/events", tag: "scans",
+		summary: "Stream the state of a scan as server-sent events until it has finished", response: stringSchema(),
+		mediaType: "text/event-stream"},
+
+	// scan schedules
+	{method: http.MethodGet, path: "/scan-schedules", tag: "scan-schedules", summary: "List scan schedules",
+		query: []openAPIParameter{fieldsParameter}, response: refSchema("ScanSchedule"), list: true},
+	{method: http.MethodPost, path: "/scan-schedules", tag: "scan-schedules", summary: "Create a scan schedule",
+		request: "ScanScheduleRequest", response: refSchema("ScanSchedule"), status: http.StatusCreated},
+	{method: http.MethodGet, path: "/scan-schedules/{id}", tag: "scan-schedules", summary: "Get a scan schedule",
+		query: []openAPIParameter{fieldsParameter}, response: refSchema("ScanSchedule")},
+	{method: http.MethodPut, path: "/scan-schedules/{id}", tag: "scan-schedules", summary: "Update a scan schedule",
+		request: "ScanScheduleRequest", response: refSchema("ScanSchedule")},
+	{method: http.MethodDelete, path: "/scan-schedules/{id}", tag: "scan-schedules",
+		summary: "Delete a scan schedule", response: refSchema("ScanSchedule")},
+
+	// findings
+	{method: http.MethodGet, path: "/findings", tag: "findings", summary: "List the findings of all assets",
+		query: append(slices.Clone(findingFilterParameters), fieldsParameter), response: refSchema("Finding"),
+		list: true},
+	{method: http.MethodGet, path: "/findings/export", tag: "findings",
+		summary: "Export the findings of all assets as CSV attachment",
+		query: append(slices.Clone(findingFilterParameters),
+			openAPIParameter{name: formatQueryParam, schema: stringSchema(formatCSV),
+				description: "Format of the export, CSV is the only one"},
+			columnsParameter),
+		response: stringSchema(), mediaType: "text/csv"},
+	{method: http.MethodGet, path: "/findings/{id}", tag: "findings", summary: "Get a finding",
+		query: []openAPIParameter{
+			{name: "expand", description: "Embed the asset of the finding", schema: stringSchema("asset")},
+			includeDeletedParameter, fieldsParameter,
+		},
+		response: openAPIObject{"oneOf": []openAPIObject{refSchema("Finding"), refSchema("FindingWithAsset")}}},
+	{method: http.MethodPost, path: "/findings/rehash", tag: "findings",
+		summary: "Recompute the hashes of all findings and merge duplicates", response: refSchema("FindingRehashResult"),
+		admin: true},
+
+	// agents
+	{method: http.MethodGet, path: "/agents", tag: "agents", summary: "List agents",
+		query: []openAPIParameter{
+			{name: "search", description: "Match agents by name", schema: stringSchema()},
+			{name: "active", description: "Match agents that are online or offline", schema: booleanSchema()},
+			limitParameter, offsetParameter, fieldsParameter,
+		},
+		response: refSchema("Agent"), list: true},
+	{method: http.MethodPost, path: "/agents", tag: "agents", summary: "Create an agent",
+		request: "CreateAgentRequest", response: refSchema("AgentToken"), status: http.StatusCreated, admin: true},
+	{method: http.MethodGet, path: "/agents/{id}", tag: "agents", summary: "Get an agent",
+		query: []openAPIParameter{fieldsParameter}, response: refSchema("Agent")},
+	{method: http.MethodPatch, path: "/agents/{id}", tag: "agents", summary: "Update an agent",
+		request: "UpdateAgentRequest", response: refSchema("Agent"), admin: true},
+	{method: http.MethodDelete, path: "/agents/{id}", tag: "agents", summary: "Delete an agent",
+		response: refSchema("Agent"), admin: true},
+	{method: http.MethodPost, path: "/agents/{id}/rotate-token", tag: "agents",
+		summary: "Replace the token of an agent", response: refSchema("AgentToken"), admin: true},
+	{method: http.MethodPost, path: "/agents/heartbeat", tag: "agents", summary: "Report that an agent is online",
+		request: "HeartbeatRequest", response: refSchema("Heartbeat")},
+
+	// audit
+	{method: http.MethodGet, path: "/audit", tag: "audit", summary: "List the audit log",
+		query: []openAPIParameter{
+			{name: "actor", description: "Match entries of the user", schema: uuidSchema()},
+			{name: "action", description: "Match entries of the action", schema: stringSchema(auditActions...)},
+			{name: "targetType", description: "Match entries changing resources of the type",
+				schema: stringSchema(auditTargetTypes...)},
+			{name: "targetId", description: "Match entries changing the resource", schema: stringSchema()},
+			{name: "from", description: "Match entries created at or after the Unix time", schema: unixTimeSchema()},
+			{name: "to", description: "Match entries created at or before the Unix time", schema: unixTimeSchema()},
+			limitParameter, offsetParameter, fieldsParameter,
+		},
+		response: refSchema("AuditEntry"), list: true, admin: true},
+}
+
+var (
+	fieldsParameter = openAPIParameter{name: fieldsQueryParam, schema: stringSchema(),
+		description: "Comma separated fields to include in the response"}
+	formatParameter = openAPIParameter{name: formatQueryParam, schema: stringSchema(formatJSON, formatCSV),
+		description: "Respond with a CSV attachment instead of JSON"}
+	columnsParameter = openAPIParameter{name: columnsQueryParam, schema: stringSchema(),
+		description: "Comma separated columns of the CSV attachment, each optionally renamed with column:header"}
+	statsParameter = openAPIParameter{name: "stats", schema: booleanSchema(),
+		description: "Include the statistics of the assets"}
+	includeDeletedParameter = openAPIParameter{name: includeDeletedQueryParam, schema: booleanSchema(),
+		description: "Resolve deleted assets, which are flagged as deleted, admins only"}
+	tagParameter = openAPIParameter{name: "tag", schema: arraySchema(stringSchema()),
+		description: "Match assets having all the tags, each given as key:value"}
+	triageStatusParameter = openAPIParameter{name: "triageStatus",
+		schema:      stringSchema(append(triageStatusValues(), triageStatusAll)...),
+		description: "Match findings of the triage status, without it findings needing no attention are left out"}
+	includeCountsParameter = openAPIParameter{name: "includeCounts", schema: booleanSchema(),
+		description: "Include the finding counts of the scans"}
+	limitParameter  = openAPIParameter{name: "limit", schema: integerSchema(), description: "Maximum number of items"}
+	offsetParameter = openAPIParameter{name: "offset", schema: integerSchema(), description: "Number of items to skip"}
+	// findingFilterParameters are the filters of parseFindingFilter.
+	findingFilterParameters = []openAPIParameter{
+		{name: "assetId", description: "Match findings of the asset", schema: uuidSchema()},
+		{name: "type", description: "Match findings of the type", schema: stringSchema(service.FindingTypeNames()...)},
+		{name: "severity", description: "Match vulnerabilities of the severity",
+			schema: stringSchema(severityValues()...)},
+		triageStatusParameter, limitParameter, offsetParameter,
+	}
+)
+
+// openAPISchemas are the component schemas of requests and responses.
+var openAPISchemas = map[string]openAPIObject{
+	"ErrorResponse": objectSchema(openAPIObject{
+		"id":         stringSchema(),
+		"apiVersion": integerSchema(),
+		"error": objectSchema(openAPIObject{
+			"code":      integerSchema(),
+			"errorCode": stringSchema(),
+			"message":   stringSchema(),
+			"errors": arraySchema(objectSchema(openAPIObject{
+				"field":   stringSchema(),
+				"message": stringSchema(),
+				"reason":  stringSchema(),
+			}, "message", "reason")),
+		}, "code", "errorCode", "message", "errors"),
+	}, "id", "apiVersion", "error"),
+
+	// auth
+	"LoginRequest": objectSchema(openAPIObject{
+		"username":   stringSchema(),
+		"password":   stringSchema(),
+		"rememberMe": booleanSchema(),
+	}, "username", "password"),
+	"User": objectSchema(openAPIObject{
+		"id":          uuidSchema(),
+		"provider":    stringSchema("local", "oidc"),
+		"username":    stringSchema(),
+		"email":       stringSchema(),
+		"displayName": stringSchema(),
+		"createdAt":   unixTimeSchema(),
+		"role":        stringSchema("admin", "user"),
+	}, "id", "provider", "username", "email", "displayName", "createdAt", "role"),
+	"Token": objectSchema(openAPIObject{
+		"token": stringSchema(),
+		"user":  refSchema("User"),
+	}, "token", "user"),
+
+	// users
+	"CreateUserRequest": objectSchema(openAPIObject{
+		"username":    stringSchema(),
+		"password":    stringSchema(),
+		"email":       stringSchema(),
+		"displayName": stringSchema(),
+		"role":        stringSchema("admin", "user"),
+	}, "username", "password", "email"),
+	"UpdateUserRequest": objectSchema(openAPIObject{
+		"email":       stringSchema(),
+		"displayName": stringSchema(),
+		"role":        stringSchema("admin", "user"),
+	}, "email"),
+	"ChangePasswordRequest": objectSchema(openAPIObject{
+		"oldPassword":  stringSchema(),
+		"newPassword":  stringSchema(),
+		"revokeTokens": booleanSchema(),
+	}, "newPassword"),
+	"SessionToken": objectSchema(openAPIObject{
+		"id":        stringSchema(),
+		"userId":    uuidSchema(),
+		"userAgent": stringSchema(),
+		"ip":        stringSchema(),
+		"revoked":   booleanSchema(),
+		"createdAt": unixTimeSchema(),
+		"expiresAt": unixTimeSchema(),
+	}, "id", "userId", "userAgent", "ip", "revoked", "createdAt", "expiresAt"),
+
+	// assets
+	"Asset": objectSchema(openAPIObject{
+		"id":       uuidSchema(),
+		"endpoint": stringSchema(),
+		"tags":     mapSchema(stringSchema()),
+		"deleted":  booleanSchema(),
+	}, "id", "endpoint"),
+	"AssetStats": objectSchema(openAPIObject{
+		"discoveredPortsCount":         integerSchema(),
+		"lastDiscovery":                unixTimeSchema(),
+		"highestVulnerabilitySeverity": severitySchema(),
+		"vulnerabilityCount":           integerSchema(),
+		"vulnerabilitySeverities": objectSchema(openAPIObject{
+			"critical": integerSchema(),
+			"high":     integerSchema(),
+			"medium":   integerSchema(),
+			"low":      integerSchema(),
+			"info":     integerSchema(),
+		}, "critical", "high", "medium", "low", "info"),
+		"highestCvssScore": numberSchema(),
+		"lastFindingSeen":  unixTimeSchema(),
+	}, "discoveredPortsCount", "lastDiscovery", "highestVulnerabilitySeverity", "vulnerabilityCount",
+		"vulnerabilitySeverities"),
+	"AssetWithStats": objectSchema(openAPIObject{
+		"id":       uuidSchema(),
+		"endpoint": stringSchema(),
+		"tags":     mapSchema(stringSchema()),
+		"stats":    refSchema("AssetStats"),
+	}, "id", "endpoint", "stats"),
+	"CreateAssetRequest": objectSchema(openAPIObject{
+		"endpoint": stringSchema(),
+	}, "endpoint"),
+	"CreateAssetsRequest": objectSchema(openAPIObject{
+		"endpoints": arraySchema(stringSchema()),
+	}, "endpoints"),
+	"CreateAssetsResult": objectSchema(openAPIObject{
+		"created": arraySchema(refSchema("Asset")),
+		"skipped": arraySchema(stringSchema()),
+	}, "created", "skipped"),
+	"UpdateAssetRequest": objectSchema(openAPIObject{
+		"id":       uuidSchema(),
+		"endpoint": stringSchema(),
+	}, "id", "endpoint"),
+	"UpdateAssetTagsRequest": objectSchema(openAPIObject{
+		"tags": mapSchema(stringSchema()),
+	}, "tags"),
+	"Finding": objectSchema(openAPIObject{
+		"id":           uuidSchema(),
+		"assetId":      uuidSchema(),
+		"createdAt":    unixTimeSchema(),
+		"type":         stringSchema("port", "vulnerability"),
+		"data":         freeFormSchema(),
+		"findingHash":  stringSchema(),
+		"agentId":      stringSchema(),
+		"firstSeen":    unixTimeSchema(),
+		"lastSeen":     unixTimeSchema(),
+		"triageStatus": stringSchema(triageStatusValues()...),
+	}, "id", "assetId", "createdAt", "type", "data", "findingHash", "agentId", "firstSeen", "lastSeen",
+		"triageStatus"),
+	"CreateFindingRequest": objectSchema(openAPIObject{
+		"type": stringSchema("port", "vulnerability"),
+		"data": freeFormSchema(),
+	}, "type", "data"),
+	"UpdateFindingRequest": objectSchema(openAPIObject{
+		"triageStatus": stringSchema(triageStatusValues()...),
+	}, "triageStatus"),
+	"FindingWithAsset": objectSchema(openAPIObject{
+		"id":           uuidSchema(),
+		"assetId":      uuidSchema(),
+		"createdAt":    unixTimeSchema(),
+		"type":         stringSchema("port", "vulnerability"),
+		"data":         freeFormSchema(),
+		"findingHash":  stringSchema(),
+		"agentId":      stringSchema(),
+		"firstSeen":    unixTimeSchema(),
+		"lastSeen":     unixTimeSchema(),
+		"triageStatus": stringSchema(triageStatusValues()...),
+		"asset":        refSchema("Asset"),
+	}, "id", "assetId", "createdAt", "type", "data", "findingHash", "agentId", "firstSeen", "lastSeen",
+		"triageStatus", "asset"),
+	"FindingRehashResult": objectSchema(openAPIObject{
+		"assets":  integerSchema(),
+		"updated": integerSchema(),
+		"merged":  integerSchema(),
+	}, "assets", "updated", "merged"),
+	"AssetHistoryEntry": objectSchema(openAPIObject{
+		"id":        uuidSchema(),
+		"assetId":   uuidSchema(),
+		"userId":    stringSchema(),
+		"timestamp": unixTimeSchema(),
+		"eventType": stringSchema("created", "updated", "deleted", "restored", "scan_finished"),
+		"eventData": freeFormSchema(),
+	}, "id", "assetId", "userId", "timestamp", "eventType", "eventData"),
+
+	// scan configurations
+	"ScanConfiguration": objectSchema(openAPIObject{
+		"id":      uuidSchema(),
+		"name":    stringSchema(),
+		"type":    scanTypeSchema(),
+		"engine":  stringSchema(),
+		"options": freeFormSchema(),
+	}, "id", "name", "type", "engine", "options"),
+	"CreateScanConfigRequest": objectSchema(openAPIObject{
+		"name":    stringSchema(),
+		"engine":  stringSchema(),
+		"options": freeFormSchema(),
+	}, "name", "engine"),
+	"UpdateScanConfigRequest": objectSchema(openAPIObject{
+		"id":      uuidSchema(),
+		"name":    stringSchema(),
+		"engine":  stringSchema(),
+		"options": freeFormSchema(),
+	}, "id", "name", "engine"),
+	"UpdateScanConfigAssetsRequest": objectSchema(openAPIObject{
+		"assetIds": arraySchema(uuidSchema()),
+	}, "assetIds"),
+	"ScanEngine": objectSchema(openAPIObject{
+		"name": stringSchema(),
+		"type": scanTypeSchema(),
+		"options": arraySchema(objectSchema(openAPIObject{
+			"name":        stringSchema(),
+			"type":        stringSchema("string", "integer", "boolean"),
+			"description": stringSchema(),
+			"min":         integerSchema(),
+			"max":         integerSchema(),
+			"values":      arraySchema(stringSchema()),
+			"pattern":     stringSchema(),
+		}, "name", "type", "description")),
+	}, "name", "type", "options"),
+
+	// scans
+	"Scan": objectSchema(openAPIObject{
+		"id":                  uuidSchema(),
+		"scanConfigurationId": uuidSchema(),
+		"status":              scanStatusSchema(),
+		"startTime":           unixTimeSchema(),
+		"endTime":             unixTimeSchema(),
+		"assets":              arraySchema(refSchema("Asset")),
+		"assetCount":          integerSchema(),
+		"error":               stringSchema(),
+		"updatedAt":           unixTimeSchema(),
+		"findingCounts": objectSchema(openAPIObject{
+			"findings":    integerSchema(),
+			"newFindings": integerSchema(),
+		}, "findings", "newFindings"),
+		"queuePosition": integerSchema(),
+	}, "id", "scanConfigurationId", "status", "startTime", "endTime", "assets", "assetCount", "updatedAt"),
+	"RunScanRequest": objectSchema(openAPIObject{
+		"configId":     uuidSchema(),
+		"assetIds":     arraySchema(uuidSchema()),
+		"endpoints":    arraySchema(stringSchema()),
+		"createAssets": booleanSchema(),
+	}, "configId"),
+	"ScanTargets": objectSchema(openAPIObject{
+		"scanConfigId": uuidSchema(),
+		"targets":      arraySchema(refSchema("Asset")),
+		"newEndpoints": arraySchema(stringSchema()),
+		"excluded": arraySchema(objectSchema(openAPIObject{
+			"assetId":  stringSchema(),
+			"endpoint": stringSchema(),
+			"reason":   stringSchema("unresolvable", "duplicate"),
+		}, "assetId", "reason")),
+	}, "scanConfigId", "targets", "newEndpoints", "excluded"),
+	"UpdateScanRequest": objectSchema(openAPIObject{
+		"status":    scanStatusSchema(),
+		"startTime": unixTimeSchema(),
+		"endTime":   unixTimeSchema(),
+		"error":     stringSchema(),
+	}),
+	"BulkScanStatusRequest": objectSchema(openAPIObject{
+		"ids":    arraySchema(uuidSchema()),
+		"status": scanStatusSchema(),
+		"error":  stringSchema(),
+	}, "ids", "status"),
+	"ScanStatusResult": objectSchema(openAPIObject{
+		"id":         uuidSchema(),
+		"statusCode": integerSchema(),
+		"message":    stringSchema(),
+		"scan":       refSchema("Scan"),
+	}, "id", "statusCode"),
+
+	// scan schedules
+	"ScanSchedule": objectSchema(openAPIObject{
+		"id":                  uuidSchema(),
+		"scanConfigurationId": uuidSchema(),
+		"assetIds":            arraySchema(uuidSchema()),
+		"cron":                stringSchema(),
+		"nextRunAt":           unixTimeSchema(),
+		"lastRunAt":           unixTimeSchema(),
+		"createdAt":           unixTimeSchema(),
+	}, "id", "scanConfigurationId", "assetIds", "cron", "nextRunAt", "createdAt"),
+	"ScanScheduleRequest": objectSchema(openAPIObject{
+		"scanConfigurationId": uuidSchema(),
+		"assetIds":            arraySchema(uuidSchema()),
+		"cron":                stringSchema(),
+	}, "scanConfigurationId", "cron"),
+
+	// agents
+	"Agent": objectSchema(openAPIObject{
+		"id":         uuidSchema(),
+		"name":       stringSchema(),
+		"tokenId":    stringSchema(),
+		"createdAt":  unixTimeSchema(),
+		"lastSeenAt": unixTimeSchema(),
+		"online":     booleanSchema(),
+		"scanner":    refSchema("ScannerStatus"),
+	}, "id", "name", "tokenId", "createdAt", "lastSeenAt", "online", "scanner"),
+	"ScannerStatus": objectSchema(openAPIObject{
+		"capability": scannerCapabilitySchema(),
+		"error":      stringSchema(),
+	}, "capability", "error"),
+	"AgentToken": objectSchema(openAPIObject{
+		"agent": refSchema("Agent"),
+		"token": stringSchema(),
+	}, "agent", "token"),
+	"CreateAgentRequest": objectSchema(openAPIObject{
+		"name": stringSchema(),
+	}, "name"),
+	"UpdateAgentRequest": objectSchema(openAPIObject{
+		"name": stringSchema(),
+	}, "name"),
+	"HeartbeatRequest": objectSchema(openAPIObject{
+		"scannerCapability": scannerCapabilitySchema(),
+		"scannerError":      stringSchema(),
+	}),
+	"Heartbeat": objectSchema(openAPIObject{
+		"serverTime": unixTimeSchema(),
+	}, "serverTime"),
+
+	// audit
+	"AuditEntry": objectSchema(openAPIObject{
+		"id":         uuidSchema(),
+		"actorId":    uuidSchema(),
+		"action":     stringSchema(auditActions...),
+		"targetType": stringSchema(auditTargetTypes...),
+		"targetId":   stringSchema(),
+		"requestId":  stringSchema(),
+		"createdAt":  unixTimeSchema(),
+	}, "id", "action", "targetType", "createdAt"),
+
+	// probes
+	"Readiness": objectSchema(openAPIObject{
+		"status":  stringSchema("OK"),
+		"scanner": refSchema("ScannerHealth"),
+	}, "status", "scanner"),
+	"ScannerHealth": objectSchema(openAPIObject{
+		"status": stringSchema(string(service.ScannerHealthOK), string(service.ScannerHealthDegraded),
+			string(service.ScannerHealthUnavailable), string(service.ScannerHealthUnknown)),
+		"onlineAgents": integerSchema(),
+		"synCapable":   integerSchema(),
+		"connectOnly":  integerSchema(),
+		"failing":      integerSchema(),
+		"unreported":   integerSchema(),
+	}, "status", "onlineAgents", "synCapable", "connectOnly", "failing", "unreported"),
+}
+
+func refSchema(name string) openAPIObject {
+	return openAPIObject{"$ref": "#/components/schemas/" + name}
+}
+
+func objectSchema(properties openAPIObject, required ...string) openAPIObject {
+	schema := openAPIObject{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func arraySchema(items openAPIObject) openAPIObject {
+	return openAPIObject{"type": "array", "items": items}
+}
+
+func mapSchema(values openAPIObject) openAPIObject {
+	return openAPIObject{"type": "object", "additionalProperties": values}
+}
+
+func freeFormSchema() openAPIObject {
+	return openAPIObject{"type": "object", "additionalProperties": true}
+}
+
+func stringSchema(enum ...string) openAPIObject {
+	schema := openAPIObject{"type": "string"}
+	if len(enum) > 0 {
+		schema["enum"] = enum
+	}
+	return schema
+}
+
+func uuidSchema() openAPIObject {
+	return openAPIObject{"type": "string", "format": "uuid"}
+}
+
+func integerSchema() openAPIObject {
+	return openAPIObject{"type": "integer"}
+}
+
+func numberSchema() openAPIObject {
+	return openAPIObject{"type": "number"}
+}
+
+func booleanSchema() openAPIObject {
+	return openAPIObject{"type": "boolean"}
+}
+
+// unixTimeSchema is a time in seconds since the epoch, 0 if the time is not set.
+func unixTimeSchema() openAPIObject {
+	return openAPIObject{"type": "integer", "format": "int64", "description": "Unix time in seconds"}
+}
+
+func severitySchema() openAPIObject {
+	return stringSchema("", "info", "low", "medium", "high", "critical")
+}
+
+func scanTypeSchema() openAPIObject {
+	return stringSchema("discovery", "vuln", "discovery+vuln")
+}
+
+func scanStatusSchema() openAPIObject {
+	return stringSchema("queued", "running", "complete", "failed", "cancelled")
+}
+
+// scannerCapabilitySchema is a capability reported by agents, empty if it is unknown.
+func scannerCapabilitySchema() openAPIObject {
+	return stringSchema(append([]string{""}, scannerCapabilityValues()...)...)
+}
+
+// assetOrStatsSchema is an asset, with statistics if requested by ?stats=true.
+func assetOrStatsSchema() openAPIObject {
+	return openAPIObject{"oneOf": []openAPIObject{refSchema("Asset"), refSchema("AssetWithStats")}}
+}
+
+// dataResponseSchema is the SingleDataResponse envelope of data.
+func dataResponseSchema(data openAPIObject) openAPIObject {
+	return objectSchema(openAPIObject{
+		"id":         stringSchema(),
+		"apiVersion": integerSchema(),
+		"data":       data,
+	}, "id", "apiVersion", "data")
+}
+
+// arrayResponseSchema is the ArrayDataResponse envelope of items.
+func arrayResponseSchema(items openAPIObject) openAPIObject {
+	return dataResponseSchema(objectSchema(openAPIObject{
+		"currentItemCount": integerSchema(),
+		"startIndex":       integerSchema(),
+		"totalItems":       integerSchema(),
+		"items":            arraySchema(items),
+		"next":             stringSchema(),
+		"prev":             stringSchema(),
+	}, "currentItemCount", "startIndex", "items"))
+}
+
+var pathParameterPattern = regexp.MustCompile(`\{([^}]+)}`)
+
+// pathParameterSchemas are the schemas of path parameters that are not UUIDs.
+var pathParameterSchemas = map[string]openAPIObject{
+	"tokenId": stringSchema(),
+}
+
+func (o openAPIOperation) document() openAPIObject {
+	var parameters []openAPIObject
+	for _, match := range pathParameterPattern.FindAllStringSubmatch(o.path, -1) {
+		schema, ok := pathParameterSchemas[match[1]]
+		if !ok {
+			schema = uuidSchema()
+		}
+		parameters = append(parameters, openAPIObject{"name": match[1], "in": "path", "required": true,
+			"schema": schema})
+	}
+	for _, param := range o.query {
+		parameters = append(parameters, openAPIObject{"name": param.name, "in": "query",
+			"description": param.description, "schema": param.schema})
+	}
+
+	status := o.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := openAPIObject{"description": http.StatusText(status)}
+	switch {
+	case o.response != nil && o.mediaType != "":
+		success["content"] = openAPIObject{o.mediaType: openAPIObject{"schema": o.response}}
+	case o.response != nil:
+		schema := dataResponseSchema(o.response)
+		if o.list {
+			schema = arrayResponseSchema(o.response)
+		}
+		success["content"] = openAPIObject{"application/json": openAPIObject{"schema": schema}}
+	}
+
+	operation := openAPIObject{
+		"tags":        []string{o.tag},
+		"summary":     o.summary,
+		"operationId": operationID(o.method, o.path),
+		"responses": openAPIObject{
+			strconv.Itoa(status): success,
+			"default": openAPIObject{
+				"description": "Error",
+				"content":     openAPIObject{"application/json": openAPIObject{"schema": refSchema("ErrorResponse")}},
+			},
+		},
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if o.request != "" {
+		operation["requestBody"] = openAPIObject{
+			"required": true,
+			"content":  openAPIObject{"application/json": openAPIObject{"schema": refSchema(o.request)}},
+		}
+	}
+	if o.public {
+		operation["security"] = []openAPIObject{}
+	}
+	if o.admin {
+		operation["description"] = "Requires the admin role."
+	}
+	return operation
+}
+
+// operationID derives a unique id of an operation from its route, e.g. patchScanConfigsIdAssets.
+func operationID(method string, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return strings.ContainsRune("/-{}", r) }) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
+
+// openAPIDocument returns the OpenAPI 3 document of the API.
+func openAPIDocument() openAPIObject {
+	paths := openAPIObject{}
+	for _, operation := range openAPIOperations {
+		item, ok := paths[operation.path].(openAPIObject)
+		if !ok {
+			item = openAPIObject{}
+			paths[operation.path] = item
+		}
+		item[strings.ToLower(operation.method)] = operation.document()
+	}
+
+	return openAPIObject{
+		"openapi": "3.0.3",
+		"info": openAPIObject{
+			"title":   "Cortex API",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": openAPIObject{
+			"schemas": openAPISchemas,
+			"securitySchemes": openAPIObject{
+				"bearerAuth": openAPIObject{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []openAPIObject{{"bearerAuth": []string{}}},
+	}
+}
+
+var openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(openAPIDocument())
+})
+
+// HandleOpenAPI responds with the OpenAPI document of the API. Unlike other responses it is not wrapped in a
+// SingleDataResponse, so it can be consumed by OpenAPI tooling.
+func HandleOpenAPI(w http.ResponseWriter, r *http.Request) error {
+	body, err := openAPIJSON()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(body)
+	return err
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"cortex/repository"
+	"cortex/service"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleOpenAPI(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Make(HandleOpenAPI).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var document struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &document))
+	assert.Equal(t, "3.0.3", document.OpenAPI)
+
+	for path, methods := range map[string][]string{
+		"/auth":                     {"get", "post"},
+		"/assets":                   {"get", "post"},
+		"/assets/{id}":              {"get", "put", "delete"},
+		"/scan-configs":             {"get", "post"},
+		"/scan-configs/{id}":        {"get", "put", "delete"},
+		"/scans":                    {"get", "post"},
+		"/scans/{id}":               {"get", "patch", "delete"},
+		"/agents":                   {"get", "post"},
+		"/agents/{id}":              {"get", "patch", "delete"},
+		"/agents/{id}/rotate-token": {"post"},
+	} {
+		require.Contains(t, document.Paths, path)
+		for _, method := range methods {
+			assert.Contains(t, document.Paths[path], method, path)
+		}
+	}
+
+	// operation ids are unique and all references resolve
+	operationIDs := make(map[string]string)
+	for path, methods := range document.Paths {
+		for method, operation := range methods {
+			id := operation["operationId"].(string)
+			assert.NotContains(t, operationIDs, id, "%s %s", method, path)
+			operationIDs[id] = path
+		}
+	}
+	for _, ref := range schemaRefs(rr.Body.String()) {
+		assert.Contains(t, document.Components.Schemas, strings.TrimPrefix(ref, "#/components/schemas/"))
+	}
+}
+
+// schemaRefs returns the values of all $ref keys of a JSON document.
+func schemaRefs(document string) []string {
+	var refs []string
+	for _, part := range strings.Split(document, `"$ref":"`)[1:] {
+		ref, _, _ := strings.Cut(part, `"`)
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func TestOpenAPISchemasMatchSerialization(t *testing.T) {
+	scan := repository.ScanExecution{Error: "failed", FindingCounts: &repository.ScanFindingCounts{}, QueuePosition: 1}
+	tags := map[string]string{"env": "prod"}
+
+	// samples set all fields that are left out when empty
+	samples := map[string]any{
+		"ErrorResponse": newErrorResponse("id", http.StatusBadRequest, ErrorCodeValidationFailed, "message", nil),
+
+		"LoginRequest": usernamePasswordLoginRequestBody{},
+		"User":         repository.User{},
+		"Token":        tokenResponse{},
+
+		"CreateUserRequest":     createUserRequestBody{},
+		"UpdateUserRequest":     updateUserRequestBody{},
+		"ChangePasswordRequest": changePasswordRequestBody{},
+		"SessionToken":          repository.AuthToken{},
+
+		"Asset": repository.ScanAsset{Tags: tags, Deleted: true},
+		"AssetStats": repository.ScanAssetStats{
+			HighestCVSSScore: pgtype.Float8{Valid: true},
+			LastFindingSeen:  pgtype.Timestamp{Valid: true},
+		},
+		"AssetWithStats":         repository.ScanAssetWithStats{Tags: tags},
+		"CreateAssetRequest":     createAssetRequestBody{},
+		"CreateAssetsRequest":    createAssetsRequestBody{},
+		"CreateAssetsResult":     service.CreateAssetsResult{},
+		"UpdateAssetRequest":     updateAssetRequestBody{},
+		"UpdateAssetTagsRequest": updateAssetTagsRequestBody{},
+		"Finding":                repository.AssetFinding{},
+		"CreateFindingRequest":   createAssetFindingBody{},
+		"UpdateFindingRequest":   updateAssetFindingBody{},
+		"AssetHistoryEntry":      repository.AssetHistoryEntry{},
+		"FindingWithAsset":       repository.AssetFindingWithAsset{},
+		"FindingRehashResult":    service.FindingRehashResult{},
+
+		"ScanConfiguration":             repository.ScanConfiguration{},
+		"CreateScanConfigRequest":       createConfigRequestBody{},
+		"UpdateScanConfigRequest":       updateConfigRequestBody{},
+		"UpdateScanConfigAssetsRequest": updateConfigAssetsRequestBody{},
+		"ScanEngine":                    service.ScanEngine{},
+
+		"Scan":                  scan,
+		"RunScanRequest":        runScanRequestBody{},
+		"ScanTargets":           service.ScanTargets{},
+		"UpdateScanRequest":     updateScanRequestBody{},
+		"BulkScanStatusRequest": bulkScanStatusRequestBody{},
+		"ScanStatusResult":      scanStatusResult{Message: "message", Scan: &scan},
+
+		"ScanSchedule":        repository.ScanSchedule{LastRunAt: pgtype.Timestamp{Valid: true}},
+		"ScanScheduleRequest": scanScheduleRequestBody{},
+
+		"Agent":              repository.Agent{},
+		"AgentToken":         agentTokenResponse{},
+		"CreateAgentRequest": createAgentRequestBody{},
+		"UpdateAgentRequest": updateAgentRequestBody{},
+		"ScannerStatus":      repository.ScannerStatus{},
+		"HeartbeatRequest":   heartbeatRequestBody{},
+		"Heartbeat":          heartbeatResponse{},
+
+		"AuditEntry": repository.AuditEntry{ActorID: pgtype.UUID{Valid: true}, TargetID: "id", RequestID: "id"},
+
+		"Readiness":     readinessResponse{},
+		"ScannerHealth": service.ScannerHealth{},
+	}
+	assert.ElementsMatch(t, slices.Collect(maps.Keys(openAPISchemas)), slices.Collect(maps.Keys(samples)),
+		"every schema needs a sample")
+
+	for name, sample := range samples {
+		schema, ok := openAPISchemas[name]
+		if !ok {
+			continue
+		}
+		fields, err := toFieldSet(sample)
+		require.NoError(t, err, name)
+		properties := schema["properties"].(openAPIObject)
+		assert.ElementsMatch(t, slices.Collect(maps.Keys(fields)), slices.Collect(maps.Keys(properties)), name)
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	assert.Equal(t, "patchScanConfigsIdAssets", operationID(http.MethodPatch, "/scan-configs/{id}/assets"))
+	assert.Equal(t, "getAuth", operationID(http.MethodGet, "/auth"))
+}
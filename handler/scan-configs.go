@@ -1,18 +1,29 @@
 package handler
 
 import (
+	"cortex/repository"
 	"cortex/service"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 )
 
 type createConfigRequestBody struct {
-	Name   string `json:"name"`
-	Engine string `json:"engine"`
+	Name    string         `json:"name"`
+	Engine  string         `json:"engine"`
+	Options map[string]any `json:"options"`
+}
+
+type updateConfigAssetsRequestBody struct {
+	AssetIDs []string `json:"assetIds"`
 }
 
 type updateConfigRequestBody struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Engine  string         `json:"engine"`
+	Options map[string]any `json:"options"`
 }
 
 type ScanConfigHandler struct {
@@ -54,18 +65,58 @@ func (h ScanConfigHandler) HandleGet(w http.ResponseWriter, r *http.Request) err
 	return nil
 }
 
+// HandleListScans lists the scans of a scan configuration.
+func (h ScanConfigHandler) HandleListScans(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	includeCounts, err := ValidateString(r.URL.Query().Get("includeCounts"), In("", "true", "false")).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	scans, err := h.scanService.ListScansByConfig(r.Context(), id, service.ListScansOptions{
+		IncludeCounts: includeCounts == "true",
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound("scan configuration", id)
+	}
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondMany(w, r, scans); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
 func (h ScanConfigHandler) HandleCreate(w http.ResponseWriter, r *http.Request) error {
 	var requestBody createConfigRequestBody
 	err := ValidateRequestBody(r, &requestBody,
-		Field(&requestBody.Name, Required(), Length(1, 1000)),
-		Field(&requestBody.Engine, Required(), In("naabu")),
+		Field(&requestBody.Name, NameRules()...),
+		Field(&requestBody.Engine, Required(), In(service.ScanEngineNames()...)),
 	)
 	if err != nil {
 		return WrapError(err)
 	}
 
-	config, err := h.scanService.CreateScanConfig(r.Context(), requestBody.Name)
+	engine, _ := service.GetScanEngine(requestBody.Engine)
+	if err = validateEngineOptions(engine, requestBody.Options); err != nil {
+		return WrapError(err)
+	}
+
+	config, err := h.scanService.CreateScanConfig(r.Context(), service.CreateScanConfigOptions{
+		Name:    requestBody.Name,
+		Engine:  requestBody.Engine,
+		Options: requestBody.Options,
+	})
 	if err != nil {
+		if errors.Is(err, service.ErrLimitExceeded) {
+			return Forbidden(err.Error())
+		}
 		return WrapError(err)
 	}
 
@@ -84,13 +135,46 @@ func (h ScanConfigHandler) HandleUpdate(w http.ResponseWriter, r *http.Request)
 	var requestBody updateConfigRequestBody
 	err = ValidateRequestBody(r, &requestBody,
 		Field(&requestBody.ID, Required(), UUID()),
-		Field(&requestBody.Name, Required(), Length(1, 1000)),
+		Field(&requestBody.Name, NameRules()...),
 	)
 	if err != nil {
 		return WrapError(err)
 	}
 
-	config, err := h.scanService.UpdateScanConfig(r.Context(), id, requestBody.Name)
+	if requestBody.Engine != "" {
+		if err = In(service.ScanEngineNames()...)(requestBody.Engine); err != nil {
+			return WrapError(NewStructValidationError(map[string]error{"engine": err}))
+		}
+	}
+
+	if requestBody.Options != nil {
+		// options are validated against the engine the config will use after the update
+		engineName := requestBody.Engine
+		if engineName == "" {
+			existing, err := h.scanService.GetScanConfig(r.Context(), id)
+			if err != nil {
+				return WrapError(err)
+			}
+			engineName = existing.Engine
+		}
+		engine, ok := service.GetScanEngine(engineName)
+		if !ok {
+			return WrapError(fmt.Errorf("scan config %s uses unsupported engine %s", id, engineName))
+		}
+		if err = validateEngineOptions(engine, requestBody.Options); err != nil {
+			return WrapError(err)
+		}
+	}
+
+	config, err := h.scanService.UpdateScanConfig(r.Context(), id, service.UpdateScanConfigOptions{
+		Name:    requestBody.Name,
+		Engine:  requestBody.Engine,
+		Options: requestBody.Options,
+		Force:   r.URL.Query().Get("force") == "true",
+	})
+	if errors.Is(err, service.ErrScanConfigInUse) {
+		return Conflict(err.Error() + ", use force=true to change the engine anyway")
+	}
 	if err != nil {
 		return WrapError(err)
 	}
@@ -117,3 +201,99 @@ func (h ScanConfigHandler) HandleDelete(w http.ResponseWriter, r *http.Request)
 	}
 	return nil
 }
+
+func (h ScanConfigHandler) HandleUpdateAssets(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	// an empty list removes all default assets
+	var requestBody updateConfigAssetsRequestBody
+	err = ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.AssetIDs, Each(UUID())),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	assets, err := h.scanService.UpdateScanConfigAssets(r.Context(), id, requestBody.AssetIDs)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondMany(w, r, assets); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h ScanConfigHandler) HandleListEngines(w http.ResponseWriter, r *http.Request) error {
+	if err := RespondMany(w, r, service.ScanEngines()); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+// validateEngineOptions validates options against the option schema of engine. Errors are reported per option.
+func validateEngineOptions(engine service.ScanEngine, options map[string]any) error {
+	var fields []FieldRules
+	for key, value := range options {
+		fieldName := "options." + key
+
+		option, ok := engine.Option(key)
+		if !ok {
+			fields = append(fields, FieldRules{FieldName: fieldName, Value: value, Rules: []ValidationRule{func(any) error {
+				return NewValidationError(fmt.Sprintf("unknown option for engine %s", engine.Name))
+			}}})
+			continue
+		}
+
+		fields = append(fields, FieldRules{FieldName: fieldName, Value: value, Rules: engineOptionRules(option)})
+	}
+
+	return ValidateStruct(fields...)
+}
+
+func engineOptionRules(option service.ScanEngineOption) []ValidationRule {
+	switch option.Type {
+	case service.ScanEngineOptionTypeString:
+		rules := []ValidationRule{optionType[string]("a string")}
+		if len(option.Values) > 0 {
+			rules = append(rules, In(option.Values...))
+		}
+		if option.Pattern != "" {
+			rules = append(rules, Regex(option.Pattern))
+		}
+		return rules
+	case service.ScanEngineOptionTypeInteger:
+		// JSON numbers are decoded as float64
+		rules := []ValidationRule{optionType[float64]("an integer"), func(value any) error {
+			if v := value.(float64); v != math.Trunc(v) {
+				return NewValidationError("must be an integer")
+			}
+			return nil
+		}}
+		if option.Min != nil {
+			rules = append(rules, Min(float64(*option.Min)))
+		}
+		if option.Max != nil {
+			rules = append(rules, Max(float64(*option.Max)))
+		}
+		return rules
+	case service.ScanEngineOptionTypeBoolean:
+		return []ValidationRule{optionType[bool]("a boolean")}
+	}
+	return []ValidationRule{func(any) error {
+		return NewValidationError(fmt.Sprintf("unsupported option type %s", option.Type))
+	}}
+}
+
+func optionType[T any](description string) ValidationRule {
+	return func(value any) error {
+		if _, ok := value.(T); !ok {
+			return NewValidationError("must be " + description)
+		}
+		return nil
+	}
+}
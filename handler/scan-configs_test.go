@@ -0,0 +1,514 @@
+package handler_test
+
+import (
+	"context"
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"cortex/test"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScanService struct {
+	mock.Mock
+}
+
+func (m *MockScanService) ListScanConfigs(ctx context.Context) ([]repository.ScanConfiguration, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.ScanConfiguration), args.Error(1)
+}
+
+func (m *MockScanService) GetScanConfig(ctx context.Context, id string) (*repository.ScanConfiguration, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanConfiguration), args.Error(1)
+}
+
+func (m *MockScanService) CreateScanConfig(ctx context.Context, opts service.CreateScanConfigOptions) (*repository.ScanConfiguration, error) {
+	args := m.Called(ctx, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanConfiguration), args.Error(1)
+}
+
+func (m *MockScanService) UpdateScanConfig(ctx context.Context, id string, opts service.UpdateScanConfigOptions) (*repository.ScanConfiguration, error) {
+	args := m.Called(ctx, id, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanConfiguration), args.Error(1)
+}
+
+func (m *MockScanService) DeleteScanConfig(ctx context.Context, id string) (*repository.ScanConfiguration, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanConfiguration), args.Error(1)
+}
+
+func (m *MockScanService) UpdateScanConfigAssets(ctx context.Context, id string, assetIds []string) ([]repository.ScanAsset, error) {
+	args := m.Called(ctx, id, assetIds)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) ListAssets(ctx context.Context, opts service.ListAssetsOptions) ([]repository.ScanAsset, error) {
+	args := m.Called(ctx, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) ListAssetsWithStats(ctx context.Context, opts service.ListAssetsOptions) ([]repository.ScanAssetWithStats, error) {
+	args := m.Called(ctx, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.ScanAssetWithStats), args.Error(1)
+}
+
+func (m *MockScanService) EachAssetWithStats(ctx context.Context, opts service.ListAssetsOptions, fn func(repository.ScanAssetWithStats) error) error {
+	args := m.Called(ctx, opts)
+	if assets, ok := args.Get(0).([]repository.ScanAssetWithStats); ok {
+		for _, asset := range assets {
+			if err := fn(asset); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockScanService) GetAsset(ctx context.Context, id string, opts service.GetAssetOptions) (*repository.ScanAsset, error) {
+	args := m.Called(ctx, id, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) GetAssetWithStats(ctx context.Context, id string) (*repository.ScanAssetWithStats, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanAssetWithStats), args.Error(1)
+}
+
+func (m *MockScanService) CreateAsset(ctx context.Context, endpoint string) (*repository.ScanAsset, error) {
+	args := m.Called(ctx, endpoint)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) CreateAssets(ctx context.Context, endpoints []string) (*service.CreateAssetsResult, error) {
+	args := m.Called(ctx, endpoints)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.CreateAssetsResult), args.Error(1)
+}
+
+func (m *MockScanService) DeleteAsset(ctx context.Context, id string, opts service.DeleteAssetOptions) (*repository.ScanAsset, error) {
+	args := m.Called(ctx, id, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) RestoreAsset(ctx context.Context, id string) (*repository.ScanAsset, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) UpdateAsset(ctx context.Context, id string, newEndpoint string) (*repository.ScanAsset, error) {
+	args := m.Called(ctx, id, newEndpoint)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) SetAssetTags(ctx context.Context, id string, tags map[string]string) (*repository.ScanAsset, error) {
+	args := m.Called(ctx, id, tags)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) ListFindings(ctx context.Context, filter repository.FindingFilter) ([]repository.AssetFinding, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.AssetFinding), args.Error(1)
+}
+
+// EachFinding calls fn with the findings the mock returns for filter.
+func (m *MockScanService) EachFinding(ctx context.Context, filter repository.FindingFilter, fn func(repository.AssetFindingWithAsset) error) error {
+	args := m.Called(ctx, filter)
+	if findings, ok := args.Get(0).([]repository.AssetFindingWithAsset); ok {
+		for _, finding := range findings {
+			if err := fn(finding); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockScanService) ListAssetFindings(ctx context.Context, assetID string, opts service.ListFindingsOptions) ([]repository.AssetFinding, error) {
+	args := m.Called(ctx, assetID, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.AssetFinding), args.Error(1)
+}
+
+func (m *MockScanService) ListAssetHistory(ctx context.Context, assetID string) ([]repository.AssetHistoryEntry, error) {
+	args := m.Called(ctx, assetID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.AssetHistoryEntry), args.Error(1)
+}
+
+func (m *MockScanService) RunScan(ctx context.Context, configID string, assetIds []string, opts service.RunScanOptions) (*repository.ScanExecution, error) {
+	args := m.Called(ctx, configID, assetIds, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanExecution), args.Error(1)
+}
+
+func (m *MockScanService) PreviewScanTargets(ctx context.Context, configID string, assetIds []string, opts service.RunScanOptions) (*service.ScanTargets, error) {
+	args := m.Called(ctx, configID, assetIds, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ScanTargets), args.Error(1)
+}
+
+func (m *MockScanService) ListScans(ctx context.Context, opts service.ListScansOptions) ([]repository.ScanExecution, error) {
+	args := m.Called(ctx, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.ScanExecution), args.Error(1)
+}
+
+func (m *MockScanService) ListScansByConfig(ctx context.Context, configID string, opts service.ListScansOptions) ([]repository.ScanExecution, error) {
+	args := m.Called(ctx, configID, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.ScanExecution), args.Error(1)
+}
+
+func (m *MockScanService) UpdateScanStatuses(ctx context.Context, scanIDs []string, status repository.ScanStatus, errorMessage string) ([]service.ScanStatusResult, error) {
+	args := m.Called(ctx, scanIDs, status, errorMessage)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]service.ScanStatusResult), args.Error(1)
+}
+
+func (m *MockScanService) GetScan(ctx context.Context, id string) (*repository.ScanExecution, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanExecution), args.Error(1)
+}
+
+func (m *MockScanService) ListAssetsOfScan(ctx context.Context, scanID string, limit int, offset int) ([]repository.ScanAsset, error) {
+	args := m.Called(ctx, scanID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.ScanAsset), args.Error(1)
+}
+
+func (m *MockScanService) UpdateScan(ctx context.Context, scanID string, update service.ScanUpdateOptions) (*repository.ScanExecution, error) {
+	args := m.Called(ctx, scanID, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanExecution), args.Error(1)
+}
+
+func (m *MockScanService) SubscribeScan(ctx context.Context, scanID string) (*service.ScanSubscription, error) {
+	args := m.Called(ctx, scanID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ScanSubscription), args.Error(1)
+}
+
+func (m *MockScanService) DeleteScan(ctx context.Context, id string) (*repository.ScanExecution, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanExecution), args.Error(1)
+}
+
+func (m *MockScanService) NotifyFindings(scanID string, count int) {
+	m.Called(scanID, count)
+}
+
+func (m *MockScanService) FailOverdueScans(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockScanService) WatchOverdueScans(ctx context.Context, interval time.Duration) {
+	m.Called(ctx, interval)
+}
+
+func (m *MockScanService) RecoverStaleScans(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func TestCreateScanConfig_Engines(t *testing.T) {
+	for _, engine := range []string{"naabu", "nmap"} {
+		t.Run(engine, func(t *testing.T) {
+			mockService := new(MockScanService)
+			h := handler.NewScanConfigHandler(mockService)
+
+			config := &repository.ScanConfiguration{
+				ID:     "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602",
+				Name:   "test",
+				Type:   repository.ScanTypeDiscovery,
+				Engine: engine,
+			}
+			mockService.On("CreateScanConfig", mock.Anything, service.CreateScanConfigOptions{
+				Name:   "test",
+				Engine: engine,
+			}).Return(config, nil)
+
+			runner := test.NewTestRunner(h.HandleCreate)
+			runner.WithBody(map[string]string{"name": "test", "engine": engine}).
+				Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateScanConfig_UnknownEngine(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	runner := test.NewTestRunner(h.HandleCreate)
+	runner.WithBody(map[string]string{"name": "test", "engine": "masscan"}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+	mockService.AssertNotCalled(t, "CreateScanConfig", mock.Anything, mock.Anything)
+}
+
+func TestCreateScanConfig_ValidOptions(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	options := map[string]any{
+		"ports": "22,80,8000-8100", "rate": float64(1000), "top-ports": "100", "timeout": float64(500), "scan-type": "c",
+	}
+	mockService.On("CreateScanConfig", mock.Anything, service.CreateScanConfigOptions{
+		Name:    "test",
+		Engine:  "naabu",
+		Options: options,
+	}).Return(&repository.ScanConfiguration{ID: "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"}, nil)
+
+	runner := test.NewTestRunner(h.HandleCreate)
+	runner.WithBody(map[string]any{"name": "test", "engine": "naabu", "options": options}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateScanConfig_InvalidOptions(t *testing.T) {
+	tests := map[string]struct {
+		options       map[string]any
+		expectedField string
+	}{
+		"unknown key":      {map[string]any{"ports": "80", "threads": 10}, "options.threads"},
+		"wrong type":       {map[string]any{"rate": "fast"}, "options.rate"},
+		"not an integer":   {map[string]any{"rate": 1.5}, "options.rate"},
+		"out of range":     {map[string]any{"retries": 100}, "options.retries"},
+		"not allowed enum": {map[string]any{"top-ports": "5"}, "options.top-ports"},
+		"zero timeout":     {map[string]any{"timeout": 0}, "options.timeout"},
+		"unknown scan":     {map[string]any{"scan-type": "x"}, "options.scan-type"},
+		"malformed ports":  {map[string]any{"ports": "22;80"}, "options.ports"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			h := handler.NewScanConfigHandler(mockService)
+
+			runner := test.NewTestRunner(h.HandleCreate)
+			res := runner.WithBody(map[string]any{"name": "test", "engine": "naabu", "options": tt.options}).
+				Run(t).ExpectAPIError(http.StatusBadRequest)
+			assert.Contains(t, res.Error.Error(), tt.expectedField)
+			mockService.AssertNotCalled(t, "CreateScanConfig", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateScanConfig_OptionsValidatedAgainstEngine(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	id := "9a95d1de-b839-4e09-9837-921075e0c8bd"
+	mockService.On("GetScanConfig", mock.Anything, id).
+		Return(&repository.ScanConfiguration{ID: id, Engine: "nuclei"}, nil)
+
+	// rate is a naabu option, nuclei uses rate-limit
+	runner := test.NewTestRunner(h.HandleUpdate)
+	res := runner.WithPath("id", id).
+		WithBody(map[string]any{"id": id, "name": "test", "options": map[string]any{"rate": 10}}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+	assert.Contains(t, res.Error.Error(), "options.rate")
+	mockService.AssertNotCalled(t, "UpdateScanConfig", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListScanEngines(t *testing.T) {
+	h := handler.NewScanConfigHandler(new(MockScanService))
+
+	runner := test.NewTestRunner(h.HandleListEngines)
+	res := runner.Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"name":"nuclei"`)
+	assert.Contains(t, res.RR.Body.String(), `"name":"rate-limit"`)
+}
+
+func TestUpdateScanConfig_NameOnly(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	id := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	mockService.On("UpdateScanConfig", mock.Anything, id, service.UpdateScanConfigOptions{Name: "renamed"}).
+		Return(&repository.ScanConfiguration{ID: id, Name: "renamed"}, nil)
+
+	runner := test.NewTestRunner(h.HandleUpdate)
+	runner.WithPath("id", id).
+		WithBody(map[string]any{"id": id, "name": "renamed"}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateScanConfig_EngineWithActiveScan(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	id := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	mockService.On("UpdateScanConfig", mock.Anything, id,
+		service.UpdateScanConfigOptions{Name: "test", Engine: "nmap"}).
+		Return(nil, fmt.Errorf("%w: 1 scan(s) queued or running", service.ErrScanConfigInUse))
+
+	runner := test.NewTestRunner(h.HandleUpdate)
+	runner.WithPath("id", id).
+		WithBody(map[string]any{"id": id, "name": "test", "engine": "nmap"}).
+		Run(t).ExpectAPIError(http.StatusConflict)
+}
+
+func TestUpdateScanConfig_EngineForced(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	id := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	mockService.On("UpdateScanConfig", mock.Anything, id,
+		service.UpdateScanConfigOptions{Name: "test", Engine: "nmap", Force: true}).
+		Return(&repository.ScanConfiguration{ID: id, Name: "test", Engine: "nmap"}, nil)
+
+	runner := test.NewTestRunner(h.HandleUpdate)
+	runner.WithPath("id", id).WithQuery("force", "true").
+		WithBody(map[string]any{"id": id, "name": "test", "engine": "nmap"}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateScanConfigAssets(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	id := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	assetIDs := []string{"f4ef30f1-6315-4187-8d10-39c6f2372d31", "63ad2a18-ccd0-4c70-a6a2-b3f6c46a85cf"}
+	mockService.On("UpdateScanConfigAssets", mock.Anything, id, assetIDs).Return([]repository.ScanAsset{
+		{ID: assetIDs[0], Endpoint: "example.com"},
+		{ID: assetIDs[1], Endpoint: "example.org"},
+	}, nil)
+
+	runner := test.NewTestRunner(h.HandleUpdateAssets)
+	res := runner.WithPath("id", id).
+		WithBody(map[string]any{"assetIds": assetIDs}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+
+	assert.Contains(t, res.RR.Body.String(), `"endpoint":"example.org"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateScanConfigAssets_InvalidID(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	runner := test.NewTestRunner(h.HandleUpdateAssets)
+	runner.WithPath("id", "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602").
+		WithBody(map[string]any{"assetIds": []string{"not-a-uuid"}}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+
+	mockService.AssertNotCalled(t, "UpdateScanConfigAssets", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListScanConfigScans(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	configID := "59c690c2-b0da-49a8-9472-b1a217a02989"
+	mockService.On("ListScansByConfig", mock.Anything, configID, service.ListScansOptions{IncludeCounts: true}).
+		Return([]repository.ScanExecution{{
+			ID:                  "b39d419f-f053-442c-9ca7-e4e570b78b65",
+			ScanConfigurationID: configID,
+			Status:              repository.ScanStatusComplete,
+			FindingCounts:       &repository.ScanFindingCounts{Findings: 3},
+		}}, nil)
+
+	res := test.NewTestRunner(h.HandleListScans).WithPath("id", configID).WithQuery("includeCounts", "true").
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"id":"b39d419f-f053-442c-9ca7-e4e570b78b65"`)
+	assert.Contains(t, res.RR.Body.String(), `"findingCounts":{"findings":3,"newFindings":0}`)
+	mockService.AssertExpectations(t)
+}
+
+func TestListScanConfigScans_UnknownConfig(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanConfigHandler(mockService)
+
+	mockService.On("ListScansByConfig", mock.Anything, mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+
+	test.NewTestRunner(h.HandleListScans).WithPath("id", "59c690c2-b0da-49a8-9472-b1a217a02989").
+		Run(t).ExpectAPIError(http.StatusNotFound)
+	test.NewTestRunner(h.HandleListScans).WithPath("id", "59c690c2-b0da-49a8-9472-b1a217a02989").
+		WithQuery("includeCounts", "yes").Run(t).ExpectAPIError(http.StatusBadRequest)
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"cortex/repository"
+	"cortex/service"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// maxCronLength is the maximum length of the cron expression of a schedule.
+const maxCronLength = 100
+
+type scanScheduleRequestBody struct {
+	ScanConfigurationID string   `json:"scanConfigurationId"`
+	AssetIDs            []string `json:"assetIds"`
+	Cron                string   `json:"cron"`
+}
+
+// cronExpression validates that a string is a cron expression accepted by service.ParseCron.
+func cronExpression() ValidationRule {
+	return func(value any) error {
+		if _, err := service.ParseCron(value.(string)); err != nil {
+			return NewValidationError(strings.TrimPrefix(err.Error(), service.ErrInvalidCron.Error()+": "))
+		}
+		return nil
+	}
+}
+
+type ScanScheduleHandler struct {
+	scheduleService service.ScheduleService
+}
+
+func NewScanScheduleHandler(scheduleService service.ScheduleService) *ScanScheduleHandler {
+	return &ScanScheduleHandler{
+		scheduleService: scheduleService,
+	}
+}
+
+// validateScheduleBody validates the body of create and update requests.
+func validateScheduleBody(r *http.Request, requestBody *scanScheduleRequestBody) error {
+	return ValidateRequestBody(r, requestBody,
+		Field(&requestBody.ScanConfigurationID, Required(), UUID()),
+		// without assets the default assets of the scan configuration are scanned
+		Field(&requestBody.AssetIDs, Each(UUID())),
+		Field(&requestBody.Cron, Required(), MaxLength(maxCronLength), cronExpression()),
+	)
+}
+
+// scheduleError maps the errors of the schedule service for the schedule with the given id to API errors.
+func scheduleError(err error, id string) APIError {
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound("scan schedule", id)
+	}
+	return invalidScheduleError(err)
+}
+
+// invalidScheduleError responds with 400 for schedules referencing scan configurations or assets that do not exist.
+func invalidScheduleError(err error) APIError {
+	if errors.Is(err, service.ErrInvalidScanSchedule) {
+		return APIError{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+	}
+	return WrapError(err)
+}
+
+func (h ScanScheduleHandler) HandleList(w http.ResponseWriter, r *http.Request) error {
+	schedules, err := h.scheduleService.ListSchedules(r.Context())
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondMany(w, r, schedules); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h ScanScheduleHandler) HandleGet(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	schedule, err := h.scheduleService.GetSchedule(r.Context(), id)
+	if err != nil {
+		return scheduleError(err, id)
+	}
+
+	if err = RespondOne(w, r, schedule); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h ScanScheduleHandler) HandleCreate(w http.ResponseWriter, r *http.Request) error {
+	var requestBody scanScheduleRequestBody
+	if err := validateScheduleBody(r, &requestBody); err != nil {
+		return WrapError(err)
+	}
+
+	schedule, err := h.scheduleService.CreateSchedule(r.Context(), service.ScanScheduleOptions{
+		ScanConfigurationID: requestBody.ScanConfigurationID,
+		AssetIDs:            requestBody.AssetIDs,
+		Cron:                requestBody.Cron,
+	})
+	if err != nil {
+		return invalidScheduleError(err)
+	}
+
+	if err = RespondOneCreated(w, r, schedule); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h ScanScheduleHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	var requestBody scanScheduleRequestBody
+	if err = validateScheduleBody(r, &requestBody); err != nil {
+		return WrapError(err)
+	}
+
+	schedule, err := h.scheduleService.UpdateSchedule(r.Context(), id, service.ScanScheduleOptions{
+		ScanConfigurationID: requestBody.ScanConfigurationID,
+		AssetIDs:            requestBody.AssetIDs,
+		Cron:                requestBody.Cron,
+	})
+	if err != nil {
+		return scheduleError(err, id)
+	}
+
+	if err = RespondOne(w, r, schedule); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h ScanScheduleHandler) HandleDelete(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	schedule, err := h.scheduleService.DeleteSchedule(r.Context(), id)
+	if err != nil {
+		return scheduleError(err, id)
+	}
+
+	if err = RespondOne(w, r, schedule); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package handler_test
+
+import (
+	"context"
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"cortex/test"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScheduleService struct {
+	mock.Mock
+}
+
+func (m *MockScheduleService) ListSchedules(ctx context.Context) ([]repository.ScanSchedule, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]repository.ScanSchedule), args.Error(1)
+}
+
+func (m *MockScheduleService) GetSchedule(ctx context.Context, id string) (*repository.ScanSchedule, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanSchedule), args.Error(1)
+}
+
+func (m *MockScheduleService) CreateSchedule(ctx context.Context, opts service.ScanScheduleOptions) (*repository.ScanSchedule, error) {
+	args := m.Called(ctx, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanSchedule), args.Error(1)
+}
+
+func (m *MockScheduleService) UpdateSchedule(ctx context.Context, id string, opts service.ScanScheduleOptions) (*repository.ScanSchedule, error) {
+	args := m.Called(ctx, id, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanSchedule), args.Error(1)
+}
+
+func (m *MockScheduleService) DeleteSchedule(ctx context.Context, id string) (*repository.ScanSchedule, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*repository.ScanSchedule), args.Error(1)
+}
+
+func (m *MockScheduleService) RunDueSchedules(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockScheduleService) Run(ctx context.Context, interval time.Duration) {
+	m.Called(ctx, interval)
+}
+
+func TestCreateSchedule(t *testing.T) {
+	scheduleService := new(MockScheduleService)
+	h := handler.NewScanScheduleHandler(scheduleService)
+
+	configID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	nextRunAt := time.Date(2025, 12, 11, 3, 0, 0, 0, time.UTC)
+	scheduleService.On("CreateSchedule", mock.Anything, service.ScanScheduleOptions{
+		ScanConfigurationID: configID,
+		Cron:                "0 3 * * *",
+	}).Return(&repository.ScanSchedule{
+		ID:                  "5a7bdb69-d7d6-482f-a653-2ab01480999f",
+		ScanConfigurationID: configID,
+		Cron:                "0 3 * * *",
+		NextRunAt:           nextRunAt,
+	}, nil)
+
+	res := test.NewTestRunner(h.HandleCreate).
+		WithBody(map[string]any{"scanConfigurationId": configID, "cron": "0 3 * * *"}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+	assert.Contains(t, res.RR.Body.String(), fmt.Sprintf(`"nextRunAt":%d`, nextRunAt.Unix()))
+	assert.Contains(t, res.RR.Body.String(), `"assetIds":[]`)
+	scheduleService.AssertExpectations(t)
+}
+
+func TestCreateSchedule_Invalid(t *testing.T) {
+	configID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+
+	for name, body := range map[string]map[string]any{
+		"missing cron":      {"scanConfigurationId": configID},
+		"invalid cron":      {"scanConfigurationId": configID, "cron": "0 25 * * *"},
+		"missing config":    {"cron": "@daily"},
+		"invalid asset IDs": {"scanConfigurationId": configID, "cron": "@daily", "assetIds": []string{"asset"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			scheduleService := new(MockScheduleService)
+			h := handler.NewScanScheduleHandler(scheduleService)
+			test.NewTestRunner(h.HandleCreate).WithBody(body).Run(t).ExpectAPIError(http.StatusBadRequest)
+			scheduleService.AssertNotCalled(t, "CreateSchedule", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateSchedule_UnknownConfig(t *testing.T) {
+	scheduleService := new(MockScheduleService)
+	h := handler.NewScanScheduleHandler(scheduleService)
+	scheduleService.On("CreateSchedule", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("%w: scan configuration not found", service.ErrInvalidScanSchedule))
+
+	test.NewTestRunner(h.HandleCreate).
+		WithBody(map[string]any{"scanConfigurationId": "7761259c-e6dd-4930-946b-ee9975fde3e4", "cron": "@daily"}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestUpdateSchedule_NotFound(t *testing.T) {
+	scheduleService := new(MockScheduleService)
+	h := handler.NewScanScheduleHandler(scheduleService)
+	scheduleService.On("UpdateSchedule", mock.Anything, mock.Anything, mock.Anything).Return(nil, repository.ErrNotFound)
+
+	test.NewTestRunner(h.HandleUpdate).WithPath("id", "5a7bdb69-d7d6-482f-a653-2ab01480999f").
+		WithBody(map[string]any{"scanConfigurationId": "7761259c-e6dd-4930-946b-ee9975fde3e4", "cron": "@daily"}).
+		Run(t).ExpectAPIError(http.StatusNotFound)
+}
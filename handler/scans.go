@@ -1,22 +1,61 @@
 package handler
 
 import (
+	"cortex/repository"
 	"cortex/service"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"time"
 )
 
 type runScanRequestBody struct {
 	ScanConfigId string   `json:"configId"`
 	AssetIDs     []string `json:"assetIds"`
+	// Endpoints are scanned in addition to AssetIDs, the assets with these endpoints must exist unless CreateAssets
+	// is set
+	Endpoints    []string `json:"endpoints"`
+	CreateAssets bool     `json:"createAssets"`
 }
 
 type updateScanRequestBody struct {
 	Status         string `json:"status"`
 	StartTimestamp int    `json:"startTime"`
 	EndTimestamp   int    `json:"endTime"`
+	// Error describes why the scan failed and is only accepted with status failed
+	Error string `json:"error"`
 }
 
+type bulkScanStatusRequestBody struct {
+	IDs    []string `json:"ids"`
+	Status string   `json:"status"`
+	// Error is stored for scans moved to failed
+	Error string `json:"error"`
+}
+
+// scanStatusResult reports the outcome of a bulk status transition for a single scan.
+type scanStatusResult struct {
+	ID         string                    `json:"id"`
+	StatusCode int                       `json:"statusCode"`
+	Message    string                    `json:"message,omitempty"`
+	Scan       *repository.ScanExecution `json:"scan,omitempty"`
+}
+
+// maxBulkScans is the maximum number of scans updated with a single bulk request.
+const maxBulkScans = 1000
+
+// scanCapacityRetryAfter is the time agents are asked to wait before starting a scan again once the maximum number
+// of scans is running.
+const scanCapacityRetryAfter = 30 * time.Second
+
+// maxScanClockSkew is how far in the future agents may report the start or end of a scan.
+const maxScanClockSkew = time.Hour
+
+// defaultScanAssetsPageSize is the number of assets listed by /scans/{id}/assets without ?limit=.
+const defaultScanAssetsPageSize = 100
+
 type ScanHandler struct {
 	scanService service.ScanService
 }
@@ -28,7 +67,14 @@ func NewScanHandler(scanService service.ScanService) *ScanHandler {
 }
 
 func (h ScanHandler) HandleList(w http.ResponseWriter, r *http.Request) error {
-	scans, err := h.scanService.ListScans(r.Context())
+	includeCounts, err := ValidateString(r.URL.Query().Get("includeCounts"), In("", "true", "false")).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+
+	scans, err := h.scanService.ListScans(r.Context(), service.ListScansOptions{
+		IncludeCounts: includeCounts == "true",
+	})
 	if err != nil {
 		return WrapError(err)
 	}
@@ -50,23 +96,68 @@ func (h ScanHandler) HandleGet(w http.ResponseWriter, r *http.Request) error {
 		return WrapError(err)
 	}
 
+	// the queue position of a queued scan changes without the scan being updated, so it is always sent in full
+	if scan.Status != repository.ScanStatusQueued && notModifiedSince(w, r, scan.UpdatedAt) {
+		return nil
+	}
 	if err = RespondOne(w, r, scan); err != nil {
 		return WrapError(err)
 	}
 	return nil
 }
 
+// HandleListAssets lists the assets of a scan, paged with ?limit= and ?offset=.
+func (h ScanHandler) HandleListAssets(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	limit, err := ValidateQueryInt(r, "limit", defaultScanAssetsPageSize, Range(1, maxPageSize))
+	if err != nil {
+		return WrapError(err)
+	}
+
+	offset, err := ValidateQueryInt(r, "offset", 0, Min(0))
+	if err != nil {
+		return WrapError(err)
+	}
+
+	page := Page{Limit: limit, Offset: offset}
+	assets, err := h.scanService.ListAssetsOfScan(r.Context(), id, page.fetchLimit(), offset)
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound("scan", id)
+	}
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondPage(w, r, assets, page); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
 func (h ScanHandler) HandleRun(w http.ResponseWriter, r *http.Request) error {
 	var requestBody runScanRequestBody
 	err := ValidateRequestBody(r, &requestBody,
 		Field(&requestBody.ScanConfigId, Required(), UUID()),
-		Field(&requestBody.AssetIDs, Required(), MinItems(1), Each(UUID())),
+		// without assets the default assets of the scan configuration are scanned
+		Field(&requestBody.AssetIDs, Each(UUID())),
+		Field(&requestBody.Endpoints, MaxItems(maxBulkAssets), Each(endpointRules()...)),
 	)
 	if err != nil {
 		return WrapError(err)
 	}
 
-	scan, err := h.scanService.RunScan(r.Context(), requestBody.ScanConfigId, requestBody.AssetIDs)
+	scan, err := h.scanService.RunScan(r.Context(), requestBody.ScanConfigId, requestBody.AssetIDs,
+		service.RunScanOptions{Endpoints: requestBody.Endpoints, CreateAssets: requestBody.CreateAssets})
+	if errors.Is(err, service.ErrNoScanAssets) {
+		return noScanAssetsError(requestBody.ScanConfigId)
+	}
+	if errors.Is(err, service.ErrLimitExceeded) {
+		return Forbidden(err.Error())
+	}
 	if err != nil {
 		return WrapError(err)
 	}
@@ -77,6 +168,57 @@ func (h ScanHandler) HandleRun(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+func (h ScanHandler) HandlePreviewTargets(w http.ResponseWriter, r *http.Request) error {
+	var requestBody runScanRequestBody
+	err := ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.ScanConfigId, Required(), UUID()),
+		Field(&requestBody.AssetIDs, Each(UUID())),
+		Field(&requestBody.Endpoints, MaxItems(maxBulkAssets), Each(endpointRules()...)),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	targets, err := h.scanService.PreviewScanTargets(r.Context(), requestBody.ScanConfigId, requestBody.AssetIDs,
+		service.RunScanOptions{Endpoints: requestBody.Endpoints, CreateAssets: requestBody.CreateAssets})
+	if errors.Is(err, service.ErrNoScanAssets) {
+		return noScanAssetsError(requestBody.ScanConfigId)
+	}
+	if errors.Is(err, service.ErrLimitExceeded) {
+		return Forbidden(err.Error())
+	}
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, targets); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func noScanAssetsError(configID string) APIError {
+	return APIError{
+		StatusCode: http.StatusBadRequest,
+		Message:    fmt.Sprintf("no assets given and scan configuration %s has no default assets", configID),
+	}
+}
+
+// validateScanUpdate checks the constraints between the fields of a scan update.
+func validateScanUpdate(body *updateScanRequestBody) error {
+	errs := map[string]error{}
+	if body.StartTimestamp != 0 && body.EndTimestamp != 0 && body.EndTimestamp < body.StartTimestamp {
+		errs["endTime"] = NewValidationError("must not be before startTime")
+	}
+	if body.Error != "" && body.Status != string(repository.ScanStatusFailed) {
+		errs["error"] = NewValidationError("error is only allowed with status failed")
+	}
+	if len(errs) > 0 {
+		return NewStructValidationError(errs)
+	}
+	return nil
+}
+
 func (h ScanHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) error {
 	id, err := ValidateParam(r, "id")
 	if err != nil {
@@ -86,8 +228,11 @@ func (h ScanHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) error
 	var requestBody updateScanRequestBody
 	err = ValidateRequestBody(r, &requestBody,
 		Field(&requestBody.Status, In("queued", "running", "complete", "failed", "cancelled")),
-		Field(&requestBody.StartTimestamp, Min(0)),
-		Field(&requestBody.EndTimestamp, Min(0)),
+		// zero leaves the time unchanged
+		Field(&requestBody.StartTimestamp, UnixTimestamp(time.Unix(0, 0), time.Now().Add(maxScanClockSkew))),
+		Field(&requestBody.EndTimestamp, UnixTimestamp(time.Unix(0, 0), time.Now().Add(maxScanClockSkew))),
+		Field(&requestBody.Error, MaxLength(service.MaxScanErrorLength)),
+		Check(validateScanUpdate),
 	)
 	if err != nil {
 		return WrapError(err)
@@ -98,8 +243,15 @@ func (h ScanHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) error
 	update.Status = requestBody.Status
 	update.StartTime = time.Unix(int64(requestBody.StartTimestamp), 0)
 	update.EndTime = time.Unix(int64(requestBody.EndTimestamp), 0)
+	update.Error = requestBody.Error
 
 	scan, err := h.scanService.UpdateScan(r.Context(), id, update)
+	if errors.Is(err, service.ErrLimitExceeded) {
+		// the scan stays queued until another one has ended
+		apiErr := TooManyRequests(err.Error())
+		apiErr.RetryAfter = scanCapacityRetryAfter
+		return apiErr
+	}
 	if err != nil {
 		return WrapError(err)
 	}
@@ -110,3 +262,147 @@ func (h ScanHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) error
 
 	return nil
 }
+
+// HandleBulkStatus moves multiple scans to a new status and responds with the result for each scan.
+func (h ScanHandler) HandleBulkStatus(w http.ResponseWriter, r *http.Request) error {
+	var requestBody bulkScanStatusRequestBody
+	err := ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.IDs, MinItems(1), MaxItems(maxBulkScans), Each(UUID())),
+		Field(&requestBody.Status, Required(), In("queued", "running", "complete", "failed", "cancelled")),
+		Field(&requestBody.Error, MaxLength(service.MaxScanErrorLength)),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	// keep the order of the request but update each scan once
+	ids := make([]string, 0, len(requestBody.IDs))
+	for _, id := range requestBody.IDs {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	results, err := h.scanService.UpdateScanStatuses(r.Context(), ids, repository.ScanStatus(requestBody.Status),
+		requestBody.Error)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	response := make([]scanStatusResult, 0, len(results))
+	for _, result := range results {
+		item := scanStatusResult{ID: result.ScanID, StatusCode: http.StatusOK, Scan: result.Scan}
+		switch {
+		case errors.Is(result.Err, repository.ErrNotFound):
+			item.StatusCode = http.StatusNotFound
+			item.Message = fmt.Sprintf("scan with id %s not found", result.ScanID)
+		case errors.Is(result.Err, service.ErrInvalidScanTransition):
+			item.StatusCode = http.StatusConflict
+			item.Message = result.Err.Error()
+		case errors.Is(result.Err, service.ErrLimitExceeded):
+			item.StatusCode = http.StatusTooManyRequests
+			item.Message = result.Err.Error()
+		case result.Err != nil:
+			item.StatusCode = http.StatusInternalServerError
+			item.Message = result.Err.Error()
+		}
+		response = append(response, item)
+	}
+
+	if err = respondManyWithStatus(w, r, http.StatusMultiStatus, response); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h ScanHandler) HandleDelete(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	scan, err := h.scanService.DeleteScan(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return NotFound("scan", id)
+	}
+	if errors.Is(err, service.ErrScanRunning) {
+		return Conflict(fmt.Sprintf("scan %s is running and cannot be deleted", id))
+	}
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, scan); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+// HandleEvents streams the state of a scan as server-sent events until the scan reaches a terminal status or the
+// client disconnects.
+func (h ScanHandler) HandleEvents(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	subscription, err := h.scanService.SubscribeScan(r.Context(), id)
+	if err != nil {
+		return WrapError(err)
+	}
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+
+	// send current state first, finished scans have nothing more to stream
+	if err = writeScanEvent(w, rc, *subscription.Scan); err != nil {
+		return err
+	}
+	if subscription.Scan.Status.IsTerminal() {
+		return nil
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return nil
+			}
+			if event.Type == service.ScanEventTypeFindings {
+				if err = writeEvent(w, rc, string(event.Type), event.Findings); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = writeScanEvent(w, rc, event.Scan); err != nil {
+				return err
+			}
+			if event.Scan.Status.IsTerminal() {
+				return nil
+			}
+		}
+	}
+}
+
+func writeScanEvent(w http.ResponseWriter, rc *http.ResponseController, scan repository.ScanExecution) error {
+	return writeEvent(w, rc, string(service.ScanEventTypeScan), scan)
+}
+
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, event string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
@@ -0,0 +1,566 @@
+package handler_test
+
+import (
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"cortex/test"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testScanID = "b39d419f-f053-442c-9ca7-e4e570b78b65"
+
+func TestScanEvents_FinishedScan(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	scan := &repository.ScanExecution{ID: testScanID, Status: repository.ScanStatusComplete}
+	mockService.On("SubscribeScan", mock.Anything, testScanID).
+		Return(&service.ScanSubscription{Scan: scan, Events: make(chan service.ScanEvent)}, nil)
+
+	runner := test.NewTestRunner(h.HandleEvents)
+	res := runner.WithPath("id", testScanID).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+
+	assert.Equal(t, "text/event-stream", res.RR.Header().Get("Content-Type"))
+	assert.Equal(t, 1, strings.Count(res.RR.Body.String(), "event: scan\n"))
+	assert.Contains(t, res.RR.Body.String(), `"status":"complete"`)
+}
+
+func TestScanEvents_StreamsUntilTerminal(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	events := make(chan service.ScanEvent, 3)
+	events <- scanEvent(repository.ScanStatusRunning)
+	events <- scanEvent(repository.ScanStatusFailed)
+	// never delivered, the stream ends with the terminal state
+	events <- scanEvent(repository.ScanStatusRunning)
+
+	scan := &repository.ScanExecution{ID: testScanID, Status: repository.ScanStatusQueued}
+	mockService.On("SubscribeScan", mock.Anything, testScanID).
+		Return(&service.ScanSubscription{Scan: scan, Events: events}, nil)
+
+	runner := test.NewTestRunner(h.HandleEvents)
+	res := runner.WithPath("id", testScanID).Run(t).ExpectNoError()
+
+	body := res.RR.Body.String()
+	assert.Equal(t, 3, strings.Count(body, "event: scan\n"))
+	assert.Less(t, strings.Index(body, `"status":"queued"`), strings.Index(body, `"status":"running"`))
+	assert.Contains(t, body[strings.LastIndex(body, "event: scan\n"):], `"status":"failed"`)
+}
+
+func scanEvent(status repository.ScanStatus) service.ScanEvent {
+	return service.ScanEvent{
+		Type: service.ScanEventTypeScan,
+		Scan: repository.ScanExecution{ID: testScanID, Status: status},
+	}
+}
+
+func TestScanEvents_FindingSummaries(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	events := make(chan service.ScanEvent, 3)
+	events <- service.ScanEvent{Type: service.ScanEventTypeFindings,
+		Findings: service.FindingSummary{ScanID: testScanID, New: 120, Total: 120}}
+	events <- service.ScanEvent{Type: service.ScanEventTypeFindings,
+		Findings: service.FindingSummary{ScanID: testScanID, New: 3, Total: 123, Complete: true}}
+	events <- scanEvent(repository.ScanStatusComplete)
+
+	scan := &repository.ScanExecution{ID: testScanID, Status: repository.ScanStatusRunning}
+	mockService.On("SubscribeScan", mock.Anything, testScanID).
+		Return(&service.ScanSubscription{Scan: scan, Events: events}, nil)
+
+	runner := test.NewTestRunner(h.HandleEvents)
+	res := runner.WithPath("id", testScanID).Run(t).ExpectNoError()
+
+	body := res.RR.Body.String()
+	assert.Equal(t, 2, strings.Count(body, "event: findings\n"))
+	assert.Contains(t, body, `"new":3,"total":123,"complete":true`)
+	assert.Less(t, strings.LastIndex(body, "event: findings\n"), strings.LastIndex(body, "event: scan\n"))
+}
+
+func TestDeleteScan_Success(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	scan := &repository.ScanExecution{ID: testScanID, Status: repository.ScanStatusComplete}
+	mockService.On("DeleteScan", mock.Anything, testScanID).Return(scan, nil)
+
+	runner := test.NewTestRunner(h.HandleDelete)
+	runner.WithPath("id", testScanID).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+}
+
+func TestDeleteScan_NotFound(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	mockService.On("DeleteScan", mock.Anything, testScanID).Return(nil, repository.ErrNotFound)
+
+	runner := test.NewTestRunner(h.HandleDelete)
+	runner.WithPath("id", testScanID).Run(t).ExpectAPIError(http.StatusNotFound)
+}
+
+func TestDeleteScan_Running(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	mockService.On("DeleteScan", mock.Anything, testScanID).Return(nil, service.ErrScanRunning)
+
+	runner := test.NewTestRunner(h.HandleDelete)
+	runner.WithPath("id", testScanID).Run(t).ExpectAPIError(http.StatusConflict)
+}
+
+func TestRunScan_DefaultAssets(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	configID := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	mockService.On("RunScan", mock.Anything, configID, []string(nil), service.RunScanOptions{}).
+		Return(&repository.ScanExecution{ID: testScanID, ScanConfigurationID: configID}, nil)
+
+	runner := test.NewTestRunner(h.HandleRun)
+	runner.WithBody(map[string]any{"configId": configID}).Run(t).ExpectNoError()
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRunScan_NoAssets(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	configID := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	mockService.On("RunScan", mock.Anything, configID, []string(nil), service.RunScanOptions{}).
+		Return(nil, service.ErrNoScanAssets)
+
+	runner := test.NewTestRunner(h.HandleRun)
+	runner.WithBody(map[string]any{"configId": configID}).Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestRunScan_Endpoints(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	configID := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	endpoints := []string{"example.com", "10.0.0.0/24"}
+	mockService.On("RunScan", mock.Anything, configID, []string(nil),
+		service.RunScanOptions{Endpoints: endpoints, CreateAssets: true}).
+		Return(&repository.ScanExecution{ID: testScanID, ScanConfigurationID: configID}, nil)
+
+	test.NewTestRunner(h.HandleRun).
+		WithBody(map[string]any{"configId": configID, "endpoints": endpoints, "createAssets": true}).
+		Run(t).ExpectNoError()
+	mockService.AssertExpectations(t)
+}
+
+func TestRunScan_InvalidEndpoints(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	configID := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	res := test.NewTestRunner(h.HandleRun).
+		WithBody(map[string]any{"configId": configID, "endpoints": []string{"example.com", "http://example.com"}}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+	assert.Contains(t, res.Error.Error(), "endpoints")
+	mockService.AssertNotCalled(t, "RunScan", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRunScan_UnknownEndpoint(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	configID := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	mockService.On("RunScan", mock.Anything, configID, []string(nil), mock.Anything).
+		Return(nil, fmt.Errorf("%w: asset with endpoint example.com", repository.ErrNotFound))
+
+	test.NewTestRunner(h.HandleRun).
+		WithBody(map[string]any{"configId": configID, "endpoints": []string{"example.com"}}).
+		Run(t).ExpectAPIError(http.StatusNotFound)
+}
+
+func TestPreviewScanTargets(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	configID := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	assetIDs := []string{"2b9d3bd6-3b0b-4a3c-9a43-1f0a3c0e8a52", "0d8b3f3c-8a1e-4d55-9f57-7a3b2a6c1d90"}
+	mockService.On("PreviewScanTargets", mock.Anything, configID, assetIDs, service.RunScanOptions{}).Return(&service.ScanTargets{
+		ScanConfigurationID: configID,
+		Targets:             []repository.ScanAsset{{ID: assetIDs[0], Endpoint: "10.0.0.1"}},
+		Excluded:            []service.ScanTargetExclusion{{AssetID: assetIDs[1], Reason: service.ScanTargetUnresolvable}},
+	}, nil)
+
+	res := test.NewTestRunner(h.HandlePreviewTargets).
+		WithBody(map[string]any{"configId": configID, "assetIds": assetIDs}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"reason":"unresolvable"`)
+	mockService.AssertNotCalled(t, "RunScan", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPreviewScanTargets_Endpoints(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	configID := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	opts := service.RunScanOptions{Endpoints: []string{"example.com", "10.0.0.0/24"}, CreateAssets: true}
+	mockService.On("PreviewScanTargets", mock.Anything, configID, []string(nil), opts).Return(&service.ScanTargets{
+		ScanConfigurationID: configID,
+		Targets:             []repository.ScanAsset{{ID: "2b9d3bd6-3b0b-4a3c-9a43-1f0a3c0e8a52", Endpoint: "example.com"}},
+		NewEndpoints:        []string{"10.0.0.0/24"},
+		Excluded:            []service.ScanTargetExclusion{},
+	}, nil)
+
+	res := test.NewTestRunner(h.HandlePreviewTargets).
+		WithBody(map[string]any{"configId": configID, "endpoints": opts.Endpoints, "createAssets": true}).
+		Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"newEndpoints":["10.0.0.0/24"]`)
+	mockService.AssertExpectations(t)
+}
+
+func TestPreviewScanTargets_InvalidEndpoint(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	test.NewTestRunner(h.HandlePreviewTargets).
+		WithBody(map[string]any{"configId": "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602", "endpoints": []string{"not an endpoint"}}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+	mockService.AssertNotCalled(t, "PreviewScanTargets", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPreviewScanTargets_NoAssets(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	configID := "5ce58a6d-1c85-4e6f-9dda-2464fb5dc602"
+	mockService.On("PreviewScanTargets", mock.Anything, configID, []string(nil), service.RunScanOptions{}).
+		Return(nil, service.ErrNoScanAssets)
+
+	runner := test.NewTestRunner(h.HandlePreviewTargets)
+	runner.WithBody(map[string]any{"configId": configID}).Run(t).ExpectAPIError(http.StatusBadRequest)
+}
+
+func TestListScans_Counts(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	mockService.On("ListScans", mock.Anything, service.ListScansOptions{IncludeCounts: false}).
+		Return([]repository.ScanExecution{{ID: testScanID, Status: repository.ScanStatusComplete}}, nil)
+	mockService.On("ListScans", mock.Anything, service.ListScansOptions{IncludeCounts: true}).
+		Return([]repository.ScanExecution{{
+			ID:            testScanID,
+			Status:        repository.ScanStatusComplete,
+			FindingCounts: &repository.ScanFindingCounts{Findings: 7, NewFindings: 2},
+		}}, nil)
+
+	res := test.NewTestRunner(h.HandleList).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.NotContains(t, res.RR.Body.String(), "findingCounts")
+
+	res = test.NewTestRunner(h.HandleList).WithQuery("includeCounts", "true").Run(t).
+		ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"findingCounts":{"findings":7,"newFindings":2}`)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestListScans_InvalidIncludeCounts(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	test.NewTestRunner(h.HandleList).WithQuery("includeCounts", "yes").Run(t).ExpectAPIError(http.StatusBadRequest)
+	mockService.AssertNotCalled(t, "ListScans", mock.Anything, mock.Anything)
+}
+
+func TestUpdateScan_Error(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	scan := &repository.ScanExecution{ID: testScanID, Status: repository.ScanStatusFailed, Error: "nmap: exit status 1"}
+	mockService.On("UpdateScan", mock.Anything, testScanID, mock.MatchedBy(func(update service.ScanUpdateOptions) bool {
+		return update.Status == "failed" && update.Error == "nmap: exit status 1"
+	})).Return(scan, nil)
+
+	res := test.NewTestRunner(h.HandleUpdate).WithPath("id", testScanID).WithBody(map[string]any{
+		"status": "failed",
+		"error":  "nmap: exit status 1",
+	}).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	assert.Contains(t, res.RR.Body.String(), `"error":"nmap: exit status 1"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateScan_ErrorWithoutFailedStatus(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	test.NewTestRunner(h.HandleUpdate).WithPath("id", testScanID).WithBody(map[string]any{
+		"status": "running",
+		"error":  "nmap: exit status 1",
+	}).Run(t).ExpectAPIError(http.StatusBadRequest)
+	mockService.AssertNotCalled(t, "UpdateScan", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateScan_MaxConcurrentScans(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	mockService.On("UpdateScan", mock.Anything, testScanID, mock.Anything).
+		Return(nil, fmt.Errorf("%w: at most 2 running scans are allowed", service.ErrLimitExceeded))
+
+	res := test.NewTestRunner(h.HandleUpdate).WithPath("id", testScanID).WithBody(map[string]any{
+		"status": "running",
+	}).Run(t).ExpectAPIError(http.StatusTooManyRequests)
+
+	var apiErr handler.APIError
+	require.ErrorAs(t, res.Error, &apiErr)
+	assert.Equal(t, 30*time.Second, apiErr.RetryAfter)
+}
+
+func TestUpdateScan_Times(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	start := time.Date(2025, 12, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Minute)
+	mockService.On("UpdateScan", mock.Anything, testScanID, mock.MatchedBy(func(update service.ScanUpdateOptions) bool {
+		return update.StartTime.Equal(start) && update.EndTime.Equal(end)
+	})).Return(&repository.ScanExecution{ID: testScanID}, nil)
+
+	test.NewTestRunner(h.HandleUpdate).WithPath("id", testScanID).WithBody(map[string]any{
+		"status":    "complete",
+		"startTime": start.Unix(),
+		"endTime":   end.Unix(),
+	}).Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateScan_InvalidTimes(t *testing.T) {
+	start := time.Date(2025, 12, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		body  map[string]any
+		field string
+	}{
+		{name: "negative", body: map[string]any{"startTime": -1}, field: "startTime"},
+		{name: "far future", body: map[string]any{"endTime": time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
+			field: "endTime"},
+		{name: "end before start", body: map[string]any{"startTime": start.Unix(), "endTime": start.Add(-time.Second).Unix()},
+			field: "endTime"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			h := handler.NewScanHandler(mockService)
+
+			tt.body["status"] = "running"
+			res := test.NewTestRunner(h.HandleUpdate).WithPath("id", testScanID).WithBody(tt.body).
+				Run(t).ExpectAPIError(http.StatusBadRequest)
+			var validationErr handler.StructValidationError
+			require.ErrorAs(t, res.Error, &validationErr)
+			assert.Contains(t, validationErr.Errors, tt.field)
+			mockService.AssertNotCalled(t, "UpdateScan", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestBulkScanStatus(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	const (
+		runningID  = "0f0c1b9e-55a4-4a43-9c8e-0d1c2b3a4f5e"
+		completeID = "6a1d2c3b-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+		unknownID  = "c7d8e9f0-1a2b-4c3d-8e4f-5a6b7c8d9e0f"
+	)
+	results := []service.ScanStatusResult{
+		{ScanID: runningID, Scan: &repository.ScanExecution{ID: runningID, Status: repository.ScanStatusCancelled}},
+		{ScanID: completeID, Scan: &repository.ScanExecution{ID: completeID, Status: repository.ScanStatusComplete},
+			Err: fmt.Errorf("%w: scan is complete", service.ErrInvalidScanTransition)},
+		{ScanID: unknownID, Err: repository.ErrNotFound},
+	}
+	mockService.On("UpdateScanStatuses", mock.Anything, []string{runningID, completeID, unknownID},
+		repository.ScanStatusCancelled, "").Return(results, nil)
+
+	res := test.NewTestRunner(h.HandleBulkStatus).WithBody(map[string]any{
+		// duplicates are only updated once
+		"ids":    []string{runningID, completeID, runningID, unknownID},
+		"status": "cancelled",
+	}).Run(t).ExpectNoError().ExpectStatusCode(http.StatusMultiStatus)
+
+	var response struct {
+		Data struct {
+			Items []struct {
+				ID         string `json:"id"`
+				StatusCode int    `json:"statusCode"`
+				Message    string `json:"message"`
+			} `json:"items"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(res.RR.Body.Bytes(), &response))
+	items := response.Data.Items
+	if assert.Len(t, items, 3) {
+		assert.Equal(t, http.StatusOK, items[0].StatusCode)
+		assert.Equal(t, http.StatusConflict, items[1].StatusCode)
+		assert.Contains(t, items[1].Message, "scan is complete")
+		assert.Equal(t, http.StatusNotFound, items[2].StatusCode)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestBulkScanStatus_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body map[string]any
+	}{
+		{"no ids", map[string]any{"ids": []string{}, "status": "failed"}},
+		{"invalid id", map[string]any{"ids": []string{"not-a-uuid"}, "status": "failed"}},
+		{"unknown status", map[string]any{"ids": []string{testScanID}, "status": "done"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			h := handler.NewScanHandler(mockService)
+
+			test.NewTestRunner(h.HandleBulkStatus).WithBody(tt.body).Run(t).ExpectAPIError(http.StatusBadRequest)
+			mockService.AssertNotCalled(t, "UpdateScanStatuses", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestGetScan_LastModified(t *testing.T) {
+	updatedAt := time.Date(2025, 12, 15, 9, 30, 15, 500, time.UTC)
+	lastModified := "Mon, 15 Dec 2025 09:30:15 GMT"
+
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		status      int
+		expectEmpty bool
+	}{
+		{name: "no condition", status: http.StatusOK},
+		{name: "not modified", header: "If-Modified-Since", value: lastModified, status: http.StatusNotModified, expectEmpty: true},
+		{name: "not modified later", header: "If-Modified-Since", value: "Tue, 16 Dec 2025 00:00:00 GMT", status: http.StatusNotModified, expectEmpty: true},
+		{name: "modified", header: "If-Modified-Since", value: "Mon, 15 Dec 2025 09:30:14 GMT", status: http.StatusOK},
+		{name: "invalid date", header: "If-Modified-Since", value: "yesterday", status: http.StatusOK},
+		// If-None-Match takes precedence over If-Modified-Since
+		{name: "etag mismatch", header: "If-None-Match", value: `W/"other"`, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			h := handler.NewScanHandler(mockService)
+			mockService.On("GetScan", mock.Anything, testScanID).Return(&repository.ScanExecution{
+				ID: testScanID, Status: repository.ScanStatusRunning, UpdatedAt: updatedAt,
+			}, nil)
+
+			runner := test.NewTestRunner(h.HandleGet).WithPath("id", testScanID)
+			if tt.header != "" {
+				runner = runner.WithHeader(tt.header, tt.value)
+			}
+			if tt.header == "If-None-Match" {
+				runner = runner.WithHeader("If-Modified-Since", lastModified)
+			}
+			res := runner.Run(t).ExpectNoError()
+
+			assert.Equal(t, tt.status, res.RR.Code)
+			assert.Equal(t, lastModified, res.RR.Header().Get("Last-Modified"))
+			if tt.expectEmpty {
+				assert.Empty(t, res.RR.Body.String())
+			} else {
+				assert.Contains(t, res.RR.Body.String(), testScanID)
+			}
+		})
+	}
+}
+
+func TestGetScan_QueuedNotCached(t *testing.T) {
+	updatedAt := time.Date(2025, 12, 15, 9, 30, 15, 0, time.UTC)
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+	// the scans ahead are picked up, the queued scan itself does not change
+	mockService.On("GetScan", mock.Anything, testScanID).Return(&repository.ScanExecution{
+		ID: testScanID, Status: repository.ScanStatusQueued, UpdatedAt: updatedAt, QueuePosition: 3,
+	}, nil).Once()
+	mockService.On("GetScan", mock.Anything, testScanID).Return(&repository.ScanExecution{
+		ID: testScanID, Status: repository.ScanStatusQueued, UpdatedAt: updatedAt, QueuePosition: 1,
+	}, nil).Once()
+
+	for _, position := range []int{3, 1} {
+		res := test.NewTestRunner(h.HandleGet).WithPath("id", testScanID).
+			WithHeader("If-Modified-Since", "Tue, 16 Dec 2025 00:00:00 GMT").
+			Run(t).ExpectNoError().ExpectStatusCode(http.StatusOK)
+		assert.Empty(t, res.RR.Header().Get("Last-Modified"))
+
+		var body struct {
+			Data struct {
+				QueuePosition int `json:"queuePosition"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(res.RR.Body.Bytes(), &body))
+		assert.Equal(t, position, body.Data.QueuePosition)
+	}
+}
+
+func TestListScanAssets_Pages(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  map[string]string
+		limit  int
+		offset int
+	}{
+		{name: "default page", query: map[string]string{}, limit: 100, offset: 0},
+		{name: "second page", query: map[string]string{"limit": "50", "offset": "50"}, limit: 50, offset: 50},
+		{name: "largest page", query: map[string]string{"limit": "1000"}, limit: 1000, offset: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScanService)
+			h := handler.NewScanHandler(mockService)
+
+			page := []repository.ScanAsset{{ID: "a", Endpoint: "example.com"}}
+			// one asset more than the page holds is fetched to tell whether there is a next page
+			mockService.On("ListAssetsOfScan", mock.Anything, testScanID, tt.limit+1, tt.offset).Return(page, nil)
+
+			runner := test.NewTestRunner(h.HandleListAssets).WithPath("id", testScanID)
+			for param, value := range tt.query {
+				runner = runner.WithQuery(param, value)
+			}
+			res := runner.Run(t).ExpectNoError()
+
+			assert.Contains(t, res.RR.Body.String(), `"endpoint":"example.com"`)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListScanAssets_InvalidPage(t *testing.T) {
+	for _, query := range []map[string]string{{"limit": "0"}, {"limit": "1001"}, {"offset": "-1"}} {
+		h := handler.NewScanHandler(new(MockScanService))
+
+		runner := test.NewTestRunner(h.HandleListAssets).WithPath("id", testScanID)
+		for param, value := range query {
+			runner = runner.WithQuery(param, value)
+		}
+		runner.Run(t).ExpectAPIError(http.StatusBadRequest)
+	}
+}
+
+func TestListScanAssets_UnknownScan(t *testing.T) {
+	mockService := new(MockScanService)
+	h := handler.NewScanHandler(mockService)
+
+	mockService.On("ListAssetsOfScan", mock.Anything, testScanID, 101, 0).Return(nil, repository.ErrNotFound)
+
+	test.NewTestRunner(h.HandleListAssets).WithPath("id", testScanID).Run(t).ExpectAPIError(http.StatusNotFound)
+}
@@ -1,10 +1,44 @@
 package handler
 
 import (
+	cortexContext "cortex/context"
+	"cortex/repository"
 	"cortex/service"
+	"errors"
+	"fmt"
 	"net/http"
 )
 
+// minPasswordLength is the minimum length of passwords of local users.
+const minPasswordLength = 12
+
+// maxPasswordLength limits passwords to a size that is cheap to hash.
+const maxPasswordLength = 255
+
+// tokenIDPattern matches the public id part of session tokens.
+const tokenIDPattern = `^[0-9a-f]{8}$`
+
+type updateUserRequestBody struct {
+	Email       string `json:"email"`
+	DisplayName string `json:"displayName"`
+	Role        string `json:"role"`
+}
+
+type changePasswordRequestBody struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+	// RevokeTokens revokes all other sessions of the user
+	RevokeTokens bool `json:"revokeTokens"`
+}
+
+type createUserRequestBody struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Email       string `json:"email"`
+	DisplayName string `json:"displayName"`
+	Role        string `json:"role"`
+}
+
 type UserHandler struct {
 	authService service.AuthService
 }
@@ -39,3 +73,180 @@ func (h UserHandler) HandleGetUser(w http.ResponseWriter, r *http.Request) error
 	}
 	return nil
 }
+
+func (h UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) error {
+	var requestBody createUserRequestBody
+	err := ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.Username, NotBlank(), Length(3, MaxNameLength), Regex(`^[a-zA-Z0-9._@-]+$`)),
+		Field(&requestBody.Password, StrongPassword(minPasswordLength), MaxLength(maxPasswordLength)),
+		Field(&requestBody.Email, Required(), Email()),
+		Field(&requestBody.DisplayName, Trimmed(), MaxLength(MaxNameLength)),
+		Field(&requestBody.Role, In("", string(repository.UserRoleUser), string(repository.UserRoleAdmin))),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	user, err := h.authService.CreateUser(r.Context(), service.CreateUserOptions{
+		Username:    requestBody.Username,
+		Password:    requestBody.Password,
+		Email:       requestBody.Email,
+		DisplayName: requestBody.DisplayName,
+		Role:        repository.UserRole(requestBody.Role),
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrUniqueViolation) {
+			return Conflict(fmt.Sprintf("user %s already exists", requestBody.Username))
+		}
+		return WrapError(err)
+	}
+
+	if err = RespondOneCreated(w, r, user); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h UserHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	var requestBody updateUserRequestBody
+	err = ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.Email, Required(), Email()),
+		Field(&requestBody.DisplayName, Trimmed(), MaxLength(MaxNameLength)),
+		Field(&requestBody.Role, In("", string(repository.UserRoleUser), string(repository.UserRoleAdmin))),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	user, err := h.authService.UpdateUser(r.Context(), id, service.UpdateUserOptions{
+		Email:       requestBody.Email,
+		DisplayName: requestBody.DisplayName,
+		Role:        repository.UserRole(requestBody.Role),
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFound("user", id)
+		}
+		return WrapError(err)
+	}
+
+	if err = RespondOne(w, r, user); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h UserHandler) HandleChangePassword(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+
+	// admins can reset the password of other users without knowing the current one
+	userInfo, err := cortexContext.UserInfo(r.Context())
+	if err != nil {
+		return Forbidden("users can only change their own password")
+	}
+	reset := userInfo.UserID != id
+	if reset && userInfo.Role != string(repository.UserRoleAdmin) {
+		return Forbidden("users can only change their own password")
+	}
+
+	var oldPasswordRules []ValidationRule
+	if !reset {
+		oldPasswordRules = append(oldPasswordRules, Required())
+	}
+	var requestBody changePasswordRequestBody
+	err = ValidateRequestBody(r, &requestBody,
+		Field(&requestBody.OldPassword, oldPasswordRules...),
+		Field(&requestBody.NewPassword, StrongPassword(minPasswordLength), MaxLength(maxPasswordLength)),
+	)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if reset {
+		err = h.authService.ResetPassword(r.Context(), id, requestBody.NewPassword, requestBody.RevokeTokens)
+	} else {
+		err = h.authService.ChangePassword(r.Context(), id, requestBody.OldPassword, requestBody.NewPassword,
+			requestBody.RevokeTokens)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFound("user", id)
+		}
+		if errors.Is(err, service.ErrInvalidPassword) {
+			message := "current password is incorrect"
+			if reset {
+				message = "user has no local password"
+			}
+			return APIError{
+				StatusCode: http.StatusBadRequest,
+				Message:    message,
+			}
+		}
+		return WrapError(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h UserHandler) HandleListTokens(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+	if err = authorizeSelfOrAdmin(r, id); err != nil {
+		return err
+	}
+
+	tokens, err := h.authService.ListUserTokens(r.Context(), id)
+	if err != nil {
+		return WrapError(err)
+	}
+
+	if err = RespondMany(w, r, tokens); err != nil {
+		return WrapError(err)
+	}
+	return nil
+}
+
+func (h UserHandler) HandleRevokeToken(w http.ResponseWriter, r *http.Request) error {
+	id, err := ValidateParam(r, "id")
+	if err != nil {
+		return WrapError(err)
+	}
+	tokenID, err := ValidateString(r.PathValue("tokenId"), Regex(tokenIDPattern)).Validate()
+	if err != nil {
+		return WrapError(err)
+	}
+	if err = authorizeSelfOrAdmin(r, id); err != nil {
+		return err
+	}
+
+	err = h.authService.RevokeUserToken(r.Context(), id, tokenID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NotFound("token", tokenID)
+		}
+		return WrapError(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// authorizeSelfOrAdmin allows requests of the user with the given id and of admins.
+func authorizeSelfOrAdmin(r *http.Request, userID string) error {
+	userInfo, err := cortexContext.UserInfo(r.Context())
+	if err != nil || (userInfo.UserID != userID && userInfo.Role != string(repository.UserRoleAdmin)) {
+		return Forbidden("users can only manage their own sessions")
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package handler_test
+
+import (
+	cortexContext "cortex/context"
+	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
+	"cortex/test"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateUser_Success(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewUserHandler(mockService)
+
+	opts := service.CreateUserOptions{
+		Username:    "jdoe",
+		Password:    "Correct-Horse-7",
+		Email:       "jdoe@example.com",
+		DisplayName: "John Doe",
+		Role:        repository.UserRoleAdmin,
+	}
+	user := &repository.User{
+		ID:          "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c",
+		Provider:    repository.UserProviderLocal,
+		Username:    opts.Username,
+		Password:    "$argon2id$v=19$m=65536,t=2,p=4$c2FsdA$aGFzaA",
+		Email:       opts.Email,
+		DisplayName: opts.DisplayName,
+		CreatedAt:   time.Unix(1700000000, 0),
+		Role:        opts.Role,
+	}
+	mockService.On("CreateUser", mock.Anything, opts).Return(user, nil)
+
+	res := test.NewTestRunner(h.HandleCreateUser).WithBody(map[string]any{
+		"username":    opts.Username,
+		"password":    opts.Password,
+		"email":       opts.Email,
+		"displayName": opts.DisplayName,
+		"role":        "admin",
+	}).Run(t).ExpectNoError().ExpectStatusCode(http.StatusCreated)
+
+	body := res.RR.Body.String()
+	assert.Contains(t, body, `"username":"jdoe"`)
+	assert.Contains(t, body, `"provider":"local"`)
+	assert.Contains(t, body, `"role":"admin"`)
+	assert.NotContains(t, body, "password")
+	assert.NotContains(t, body, "argon2")
+}
+
+func TestCreateUser_Conflict(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewUserHandler(mockService)
+
+	mockService.On("CreateUser", mock.Anything, mock.Anything).Return(nil, repository.ErrUniqueViolation)
+
+	test.NewTestRunner(h.HandleCreateUser).WithBody(map[string]any{
+		"username": "admin",
+		"password": "Correct-Horse-7",
+		"email":    "admin@example.com",
+	}).Run(t).ExpectAPIError(http.StatusConflict)
+}
+
+func TestCreateUser_Invalid(t *testing.T) {
+	valid := map[string]any{
+		"username": "jdoe",
+		"password": "Correct-Horse-7",
+		"email":    "jdoe@example.com",
+	}
+	tests := []struct {
+		name  string
+		field string
+		value any
+	}{
+		{name: "blank username", field: "username", value: "  "},
+		{name: "username with spaces", field: "username", value: "john doe"},
+		{name: "weak password", field: "password", value: "password1234"},
+		{name: "short password", field: "password", value: "Sh0rt!"},
+		{name: "missing email", field: "email", value: ""},
+		{name: "invalid email", field: "email", value: "jdoe"},
+		{name: "untrimmed display name", field: "displayName", value: " John "},
+		{name: "unknown role", field: "role", value: "root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			h := handler.NewUserHandler(mockService)
+
+			body := map[string]any{}
+			for key, value := range valid {
+				body[key] = value
+			}
+			body[tt.field] = tt.value
+
+			test.NewTestRunner(h.HandleCreateUser).WithBody(body).Run(t).ExpectAPIError(http.StatusBadRequest)
+			mockService.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateUser_Success(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewUserHandler(mockService)
+
+	userID := "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c"
+	opts := service.UpdateUserOptions{Email: "new@example.com", DisplayName: "New Name"}
+	user := &repository.User{ID: userID, Username: "jdoe", Email: opts.Email, DisplayName: opts.DisplayName}
+	mockService.On("UpdateUser", mock.Anything, userID, opts).Return(user, nil)
+
+	res := test.NewTestRunner(h.HandleUpdateUser).
+		WithPath("id", userID).
+		WithBody(map[string]any{"email": opts.Email, "displayName": opts.DisplayName}).
+		Run(t).ExpectNoError()
+
+	assert.Contains(t, res.RR.Body.String(), `"email":"new@example.com"`)
+	assert.Contains(t, res.RR.Body.String(), `"displayName":"New Name"`)
+}
+
+func TestUpdateUser_NotFound(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewUserHandler(mockService)
+
+	userID := "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c"
+	mockService.On("UpdateUser", mock.Anything, userID, mock.Anything).Return(nil, repository.ErrNotFound)
+
+	test.NewTestRunner(h.HandleUpdateUser).
+		WithPath("id", userID).
+		WithBody(map[string]any{"email": "new@example.com"}).
+		Run(t).ExpectAPIError(http.StatusNotFound)
+}
+
+func TestChangePassword(t *testing.T) {
+	userID := "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c"
+	self := cortexContext.UserInfoData{UserID: userID, Username: "jdoe", TokenID: "abc"}
+	body := map[string]any{"oldPassword": "old", "newPassword": "Correct-Horse-7", "revokeTokens": true}
+
+	tests := []struct {
+		name       string
+		userInfo   any
+		body       map[string]any
+		serviceErr error
+		callsSvc   bool
+		status     int
+	}{
+		{name: "own password", userInfo: self, body: body, callsSvc: true, status: http.StatusNoContent},
+		{name: "wrong current password", userInfo: self, body: body, serviceErr: service.ErrInvalidPassword,
+			callsSvc: true, status: http.StatusBadRequest},
+		{name: "other user", userInfo: cortexContext.UserInfoData{UserID: "1f0c1f2a-2f8e-4d1a-9d51-6f0c1f2a3b4c",
+			Role: string(repository.UserRoleUser)}, body: body, status: http.StatusForbidden},
+		{name: "agent", userInfo: nil, body: body, status: http.StatusForbidden},
+		{name: "weak password", userInfo: self,
+			body: map[string]any{"oldPassword": "old", "newPassword": "weak"}, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			h := handler.NewUserHandler(mockService)
+			mockService.On("ChangePassword", mock.Anything, userID, "old", "Correct-Horse-7", true).Return(tt.serviceErr)
+
+			runner := test.NewTestRunner(h.HandleChangePassword).WithPath("id", userID).WithBody(tt.body)
+			if tt.userInfo != nil {
+				runner = runner.WithContextValue(cortexContext.KeyUserInfo, tt.userInfo)
+			}
+			res := runner.Run(t)
+
+			if tt.status == http.StatusNoContent {
+				res.ExpectNoError().ExpectStatusCode(http.StatusNoContent)
+			} else {
+				res.ExpectAPIError(tt.status)
+			}
+			if tt.callsSvc {
+				mockService.AssertExpectations(t)
+			} else {
+				mockService.AssertNotCalled(t, "ChangePassword",
+					mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	userID := "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c"
+	admin := cortexContext.UserInfoData{UserID: "1f0c1f2a-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "admin",
+		Role: string(repository.UserRoleAdmin)}
+
+	t.Run("admin resets password of other user", func(t *testing.T) {
+		mockService := new(MockAuthService)
+		h := handler.NewUserHandler(mockService)
+		mockService.On("ResetPassword", mock.Anything, userID, "Correct-Horse-7", true).Return(nil)
+
+		// the current password of the user is not needed
+		test.NewTestRunner(h.HandleChangePassword).
+			WithPath("id", userID).
+			WithContextValue(cortexContext.KeyUserInfo, admin).
+			WithBody(map[string]any{"newPassword": "Correct-Horse-7", "revokeTokens": true}).
+			Run(t).ExpectNoError().ExpectStatusCode(http.StatusNoContent)
+
+		mockService.AssertExpectations(t)
+		mockService.AssertNotCalled(t, "ChangePassword",
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("admin changes own password", func(t *testing.T) {
+		mockService := new(MockAuthService)
+		h := handler.NewUserHandler(mockService)
+		mockService.On("ChangePassword", mock.Anything, admin.UserID, "old", "Correct-Horse-7", false).Return(nil)
+
+		test.NewTestRunner(h.HandleChangePassword).
+			WithPath("id", admin.UserID).
+			WithContextValue(cortexContext.KeyUserInfo, admin).
+			WithBody(map[string]any{"oldPassword": "old", "newPassword": "Correct-Horse-7"}).
+			Run(t).ExpectNoError().ExpectStatusCode(http.StatusNoContent)
+
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("user cannot reset password of other user", func(t *testing.T) {
+		mockService := new(MockAuthService)
+		h := handler.NewUserHandler(mockService)
+
+		test.NewTestRunner(h.HandleChangePassword).
+			WithPath("id", userID).
+			WithContextValue(cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: admin.UserID,
+				Role: string(repository.UserRoleUser)}).
+			WithBody(map[string]any{"newPassword": "Correct-Horse-7"}).
+			Run(t).ExpectAPIError(http.StatusForbidden)
+
+		mockService.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestListTokens(t *testing.T) {
+	const userID = "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c"
+	tokens := []repository.AuthToken{{
+		ID:        "3f1c2b7a",
+		Hash:      "$argon2id$v=19$m=65536,t=2,p=4$c2FsdA$aGFzaA",
+		UserID:    userID,
+		UserAgent: "curl/8.0",
+		SourceIP:  "192.0.2.1",
+		CreatedAt: time.Unix(1700000000, 0),
+		ExpiresAt: time.Unix(1700086400, 0),
+	}}
+
+	tests := []struct {
+		name     string
+		userInfo cortexContext.UserInfoData
+		status   int
+	}{
+		{"own sessions", cortexContext.UserInfoData{UserID: userID, Role: "user"}, http.StatusOK},
+		{"admin", cortexContext.UserInfoData{UserID: "admin", Role: "admin"}, http.StatusOK},
+		{"other user", cortexContext.UserInfoData{UserID: "other", Role: "user"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			h := handler.NewUserHandler(mockService)
+			mockService.On("ListUserTokens", mock.Anything, userID).Return(tokens, nil)
+
+			res := test.NewTestRunner(h.HandleListTokens).WithPath("id", userID).
+				WithContextValue(cortexContext.KeyUserInfo, tt.userInfo).Run(t)
+
+			if tt.status != http.StatusOK {
+				res.ExpectAPIError(tt.status)
+				mockService.AssertNotCalled(t, "ListUserTokens", mock.Anything, mock.Anything)
+				return
+			}
+			res.ExpectNoError().ExpectStatusCode(http.StatusOK)
+			body := res.RR.Body.String()
+			assert.Contains(t, body, `"id":"3f1c2b7a"`)
+			assert.Contains(t, body, `"ip":"192.0.2.1"`)
+			assert.NotContains(t, body, "argon2id")
+		})
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	const userID = "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c"
+
+	tests := []struct {
+		name     string
+		userInfo cortexContext.UserInfoData
+		svcErr   error
+		status   int
+		callsSvc bool
+	}{
+		{"own session", cortexContext.UserInfoData{UserID: userID, Role: "user"}, nil, http.StatusNoContent, true},
+		{"admin", cortexContext.UserInfoData{UserID: "admin", Role: "admin"}, nil, http.StatusNoContent, true},
+		{"other user", cortexContext.UserInfoData{UserID: "other", Role: "user"}, nil, http.StatusForbidden, false},
+		{"unknown token", cortexContext.UserInfoData{UserID: userID, Role: "user"}, repository.ErrNotFound, http.StatusNotFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAuthService)
+			h := handler.NewUserHandler(mockService)
+			mockService.On("RevokeUserToken", mock.Anything, userID, "3f1c2b7a").Return(tt.svcErr)
+
+			res := test.NewTestRunner(h.HandleRevokeToken).WithPath("id", userID).WithPath("tokenId", "3f1c2b7a").
+				WithContextValue(cortexContext.KeyUserInfo, tt.userInfo).Run(t)
+
+			if tt.status == http.StatusNoContent {
+				res.ExpectNoError().ExpectStatusCode(http.StatusNoContent)
+			} else {
+				res.ExpectAPIError(tt.status)
+			}
+			if tt.callsSvc {
+				mockService.AssertExpectations(t)
+			} else {
+				mockService.AssertNotCalled(t, "RevokeUserToken", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestRevokeToken_InvalidTokenID(t *testing.T) {
+	mockService := new(MockAuthService)
+	h := handler.NewUserHandler(mockService)
+
+	test.NewTestRunner(h.HandleRevokeToken).WithPath("id", "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c").
+		WithPath("tokenId", "not-a-token").
+		WithContextValue(cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "admin", Role: "admin"}).
+		Run(t).ExpectAPIError(http.StatusBadRequest)
+	mockService.AssertNotCalled(t, "RevokeUserToken", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -1,11 +1,20 @@
 package handler
 
 import (
+	"context"
 	cortexContext "cortex/context"
+	"cortex/repository"
+	"cortex/service"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 /********** Responses **********/
@@ -16,22 +25,18 @@ type ArrayDataResponse[T any] struct {
 	Data       APIComponentArray[T] `json:"data"`
 }
 
-//nolint:unused // will be used in the future
-func newArrayDataResponse[T any](id string, data []T) ArrayDataResponse[T] {
+func newAPIComponentArray[T any](data []T) APIComponentArray[T] {
 	dataList := data
 	if dataList == nil {
 		dataList = []T{}
 	}
 
-	return ArrayDataResponse[T]{
-		ID:         id,
-		APIVersion: 1,
-		Data: APIComponentArray[T]{
-			TotalItems:       len(data),
-			Items:            dataList,
-			StartIndex:       0,
-			CurrentItemCount: len(data),
-		},
+	total := len(data)
+	return APIComponentArray[T]{
+		TotalItems:       &total,
+		Items:            dataList,
+		StartIndex:       0,
+		CurrentItemCount: len(data),
 	}
 }
 
@@ -56,24 +61,33 @@ type ErrorResponse struct {
 }
 
 type ErrorResponseValue struct {
-	Code    int                  `json:"code"`
-	Message string               `json:"message"`
-	Errors  []ErrorResponseStack `json:"errors"`
+	// Code is the HTTP status code.
+	Code int `json:"code"`
+	// ErrorCode identifies the kind of error for clients.
+	ErrorCode ErrorCode            `json:"errorCode"`
+	Message   string               `json:"message"`
+	Errors    []ErrorResponseStack `json:"errors"`
 }
 
 type ErrorResponseStack struct {
+	// Field is the request field the error refers to, if any.
+	Field   string `json:"field,omitempty"`
 	Message string `json:"message"`
 	Reason  string `json:"reason"`
 }
 
-func newErrorResponse(id string, code int, message string, errors []error) ErrorResponse {
+// errorReasonValidation is the reason of errors caused by invalid request fields.
+const errorReasonValidation = "validation"
+
+func newErrorResponse(id string, code int, errorCode ErrorCode, message string, errors []error) ErrorResponse {
 	resp := ErrorResponse{
 		ID:         id,
 		APIVersion: 1,
 		Error: ErrorResponseValue{
-			Code:    code,
-			Message: message,
-			Errors:  []ErrorResponseStack{},
+			Code:      code,
+			ErrorCode: errorCode,
+			Message:   message,
+			Errors:    []ErrorResponseStack{},
 		},
 	}
 
@@ -91,28 +105,177 @@ func newErrorResponse(id string, code int, message string, errors []error) Error
 type APIComponentArray[T any] struct {
 	CurrentItemCount int `json:"currentItemCount"`
 	StartIndex       int `json:"startIndex"`
-	TotalItems       int `json:"totalItems"`
-	Items            []T `json:"items"`
+	// TotalItems is the number of items of the whole list. It is left out of paginated lists, whose total is unknown.
+	TotalItems *int `json:"totalItems,omitempty"`
+	Items      []T  `json:"items"`
+	// Next and Prev link to the adjacent pages of paginated lists, they are empty if there is no such page.
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// Page is the part of a list requested with ?limit= and ?offset=. A Limit of zero means the list is not paginated.
+// Handlers fetch fetchLimit items, one more than the page holds, so RespondPage can tell whether there is a next page.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// fetchLimit is the number of items to fetch for the page, zero if the list is not paginated.
+func (p Page) fetchLimit() int {
+	if p.Limit <= 0 {
+		return 0
+	}
+	return p.Limit + 1
+}
+
+// paginate sets the start index and the links to the adjacent pages of array, which holds the items of page. More
+// tells whether items follow the page, only then the next page is linked.
+func paginate[T any](r *http.Request, array APIComponentArray[T], page Page, more bool) APIComponentArray[T] {
+	if page.Limit <= 0 {
+		return array
+	}
+
+	array.StartIndex = page.Offset
+	array.TotalItems = nil
+	if page.Offset > 0 {
+		array.Prev = pageLink(r, page.Limit, max(page.Offset-page.Limit, 0))
+	}
+	if more {
+		array.Next = pageLink(r, page.Limit, page.Offset+page.Limit)
+	}
+	return array
+}
+
+// pageLink returns the URL of the request with the limit and offset of another page.
+func pageLink(r *http.Request, limit int, offset int) string {
+	query := r.URL.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	return r.URL.Path + "?" + query.Encode()
 }
 
 /********** API Errors **********/
 
+// ErrorCode is a machine-readable identifier of the kind of an error, sent along with the HTTP status code.
+type ErrorCode string
+
+const (
+	ErrorCodeValidationFailed   ErrorCode = "validation_failed"
+	ErrorCodeUnauthorized       ErrorCode = "unauthorized"
+	ErrorCodeForbidden          ErrorCode = "forbidden"
+	ErrorCodeNotFound           ErrorCode = "not_found"
+	ErrorCodeMethodNotAllowed   ErrorCode = "method_not_allowed"
+	ErrorCodeConflict           ErrorCode = "conflict"
+	ErrorCodeAlreadyExists      ErrorCode = "already_exists"
+	ErrorCodeUnprocessable      ErrorCode = "unprocessable_entity"
+	ErrorCodeTooManyRequests    ErrorCode = "too_many_requests"
+	ErrorCodeInternal           ErrorCode = "internal_error"
+	ErrorCodeServiceUnavailable ErrorCode = "service_unavailable"
+	ErrorCodeTimeout            ErrorCode = "timeout"
+)
+
+// statusErrorCodes are the codes of errors that do not set one.
+var statusErrorCodes = map[int]ErrorCode{
+	http.StatusBadRequest:          ErrorCodeValidationFailed,
+	http.StatusUnauthorized:        ErrorCodeUnauthorized,
+	http.StatusForbidden:           ErrorCodeForbidden,
+	http.StatusNotFound:            ErrorCodeNotFound,
+	http.StatusMethodNotAllowed:    ErrorCodeMethodNotAllowed,
+	http.StatusConflict:            ErrorCodeConflict,
+	http.StatusUnprocessableEntity: ErrorCodeUnprocessable,
+	http.StatusTooManyRequests:     ErrorCodeTooManyRequests,
+	http.StatusServiceUnavailable:  ErrorCodeServiceUnavailable,
+	http.StatusInternalServerError: ErrorCodeInternal,
+}
+
+// notFoundCode returns the code of a missing resource of objectType, e.g. asset_not_found.
+func notFoundCode(objectType string) ErrorCode {
+	return ErrorCode(strings.ReplaceAll(objectType, " ", "_") + "_" + string(ErrorCodeNotFound))
+}
+
+// databaseRetryAfter is suggested to clients if no database connection is available.
+const databaseRetryAfter = 5 * time.Second
+
 type APIError struct {
 	StatusCode int
-	Message    string
+	// Code identifies the kind of error, the code of StatusCode is used if empty.
+	Code    ErrorCode
+	Message string
+	// Err is the error the APIError has been created from, if any.
+	Err error
+	// RetryAfter is sent as Retry-After header if set.
+	RetryAfter time.Duration
 }
 
 func (e APIError) Error() string {
 	return fmt.Sprintf("API error: %s", e.Message)
 }
 
+func (e APIError) Unwrap() error {
+	return e.Err
+}
+
 func NotFound(objectType string, objectID string) APIError {
 	return APIError{
 		StatusCode: http.StatusNotFound,
+		Code:       notFoundCode(objectType),
 		Message:    fmt.Sprintf("%s with id %s not found", objectType, objectID),
 	}
 }
 
+func Conflict(message string) APIError {
+	return APIError{
+		StatusCode: http.StatusConflict,
+		Message:    fmt.Sprintf("conflict: %s", message),
+	}
+}
+
+// ConflictError returns a conflict for err, usually a repository.ErrUniqueViolation of a created or updated resource.
+func ConflictError(err error) APIError {
+	return APIError{
+		StatusCode: http.StatusConflict,
+		Code:       ErrorCodeAlreadyExists,
+		Message:    "conflict: resource already exists",
+		Err:        err,
+	}
+}
+
+func UnprocessableEntity(message string) APIError {
+	return APIError{
+		StatusCode: http.StatusUnprocessableEntity,
+		Message:    fmt.Sprintf("unprocessable entity: %s", message),
+	}
+}
+
+func Unauthorized(message string) APIError {
+	return APIError{
+		StatusCode: http.StatusUnauthorized,
+		Message:    fmt.Sprintf("unauthorized: %s", message),
+	}
+}
+
+func Forbidden(message string) APIError {
+	return APIError{
+		StatusCode: http.StatusForbidden,
+		Message:    fmt.Sprintf("forbidden: %s", message),
+	}
+}
+
+func TooManyRequests(message string) APIError {
+	return APIError{
+		StatusCode: http.StatusTooManyRequests,
+		Message:    fmt.Sprintf("too many requests: %s", message),
+	}
+}
+
+func ServiceUnavailable(message string, retryAfter time.Duration) APIError {
+	return APIError{
+		StatusCode: http.StatusServiceUnavailable,
+		Message:    fmt.Sprintf("service unavailable: %s", message),
+		RetryAfter: retryAfter,
+	}
+}
+
 func OtherError(err error) APIError {
 	return APIError{
 		StatusCode: http.StatusInternalServerError,
@@ -130,6 +293,9 @@ func Make(f APIFunc) http.HandlerFunc {
 			var apiErr APIError
 			if errors.As(err, &apiErr) {
 				RespondError(w, r, apiErr.StatusCode, err)
+			} else if errors.Is(err, service.ErrDatabaseUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+				apiErr = WrapError(err)
+				RespondError(w, r, apiErr.StatusCode, apiErr)
 			} else {
 				// unknown error type, respond with internal server error
 				RespondError(w, r, http.StatusInternalServerError, err)
@@ -142,8 +308,20 @@ func Make(f APIFunc) http.HandlerFunc {
 
 func RespondError(w http.ResponseWriter, r *http.Request, status int, err error) {
 	w.Header().Set("Content-Type", "application/json")
+	var apiErr APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
 	w.WriteHeader(status)
-	errorReply := newErrorResponse(cortexContext.RequestID(r.Context()), status, err.Error(), nil)
+	code := statusErrorCodes[status]
+	if errors.As(err, &apiErr) && apiErr.Code != "" {
+		code = apiErr.Code
+	}
+	errorReply := newErrorResponse(cortexContext.RequestID(r.Context()), status, code, err.Error(), nil)
+	var structValidationErr StructValidationError
+	if errors.As(err, &structValidationErr) {
+		errorReply.Error.Errors = structValidationErr.responseStack()
+	}
 	e := json.NewEncoder(w).Encode(errorReply)
 	if e != nil {
 		panic(err)
@@ -160,20 +338,114 @@ func RespondOneCreated[T any](w http.ResponseWriter, r *http.Request, data T) er
 }
 
 func RespondMany[T any](w http.ResponseWriter, r *http.Request, data []T) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	response := newArrayDataResponse(cortexContext.RequestID(r.Context()), data)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		return err
+	return respondManyWithStatus(w, r, http.StatusOK, data)
+}
+
+// RespondPage responds like RespondMany with data being the items of page, including links to the adjacent pages. Data
+// is fetched with the fetchLimit of page, an item beyond the page is left out and only links the next page.
+func RespondPage[T any](w http.ResponseWriter, r *http.Request, data []T, page Page) error {
+	return respondPageWithStatus(w, r, http.StatusOK, data, page)
+}
+
+func respondManyWithStatus[T any](w http.ResponseWriter, r *http.Request, status int, data []T) error {
+	return respondPageWithStatus(w, r, status, data, Page{})
+}
+
+func respondPageWithStatus[T any](w http.ResponseWriter, r *http.Request, status int, data []T, page Page) error {
+	// the item fetched beyond the page only tells that there is a next page
+	more := page.Limit > 0 && len(data) > page.Limit
+	if more {
+		data = data[:page.Limit]
 	}
 
-	return nil
+	if fields := requestedFields(r); fields != nil {
+		selected, err := selectFields(data, fields)
+		if err != nil {
+			// handlers may return the error of a respond function as it is, it has to be an APIError
+			return WrapError(err)
+		}
+		return writeDataResponse(w, r, status, paginate(r, newAPIComponentArray(selected), page, more))
+	}
+
+	return writeDataResponse(w, r, status, paginate(r, newAPIComponentArray(data), page, more))
 }
 
 func respondOneWithStatus[T any](w http.ResponseWriter, r *http.Request, status int, data T) error {
+	if fields := requestedFields(r); fields != nil {
+		selected, err := selectFields([]T{data}, fields)
+		if err != nil {
+			// handlers may return the error of a respond function as it is, it has to be an APIError
+			return WrapError(err)
+		}
+		return writeDataResponse(w, r, status, selected[0])
+	}
+
+	return writeDataResponse(w, r, status, data)
+}
+
+// writeDataResponse writes data in a SingleDataResponse with an ETag of the serialized data. The response id differs
+// per request and is not part of the ETag. GET and HEAD requests with a matching If-None-Match header are answered
+// with 304 Not Modified without body.
+func writeDataResponse(w http.ResponseWriter, r *http.Request, status int, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	etag := weakETag(body)
+	w.Header().Set("ETag", etag)
+	if status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+		etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	return writeResponse(w, status, NewSingleDataResponse(cortexContext.RequestID(r.Context()), json.RawMessage(body)))
+}
+
+// weakETag returns a weak entity tag of body.
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag using the weak comparison of RFC 9110.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince sets the Last-Modified header to modified and answers GET and HEAD requests with 304 Not Modified
+// if the resource has not been modified since their If-Modified-Since header. If-Modified-Since is ignored if the
+// request has an If-None-Match header. It reports whether the response has been written.
+func notModifiedSince(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+	// HTTP dates have a resolution of seconds
+	modified = modified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func writeResponse(w http.ResponseWriter, status int, response any) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	response := NewSingleDataResponse(cortexContext.RequestID(r.Context()), data)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		return err
 	}
@@ -185,6 +457,41 @@ func ValidateParam(r *http.Request, param string) (string, error) {
 	return ValidateString(r.PathValue(param), UUID()).Validate()
 }
 
+// maxPageSize is the maximum number of items a client can request with ?limit=.
+const maxPageSize = 1000
+
+// ValidateQueryInt parses the query parameter param as integer and validates it. If the parameter is not set,
+// fallback is returned without validation.
+func ValidateQueryInt(r *http.Request, param string, fallback int, rules ...ValidationRule) (int, error) {
+	raw := r.URL.Query().Get(param)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, NewStructValidationError(map[string]error{param: NewValidationError("must be an integer")})
+	}
+	for _, rule := range rules {
+		if err = rule(value); err != nil {
+			return 0, NewStructValidationError(map[string]error{param: err})
+		}
+	}
+	return value, nil
+}
+
+// validateQueryUUID validates the query parameter param as UUID if it is set.
+func validateQueryUUID(r *http.Request, param string) (string, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return "", nil
+	}
+	if err := UUID()(value); err != nil {
+		return "", NewStructValidationError(map[string]error{param: err})
+	}
+	return value, nil
+}
+
 func WrapError(err error) APIError {
 	var apiErr APIError
 	if errors.As(err, &apiErr) {
@@ -197,7 +504,44 @@ func WrapError(err error) APIError {
 			Message:    validationErr.Error(),
 		}
 	}
+	var structValidationErr StructValidationError
+	if errors.As(err, &structValidationErr) {
+		return APIError{
+			StatusCode: http.StatusBadRequest,
+			Message:    structValidationErr.Error(),
+			Err:        structValidationErr,
+		}
+	}
+
+	if errors.Is(err, service.ErrDatabaseUnavailable) {
+		apiErr = ServiceUnavailable("database is busy, retry later", databaseRetryAfter)
+		apiErr.Err = err
+		return apiErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		apiErr = ServiceUnavailable("request timed out", 0)
+		apiErr.Code = ErrorCodeTimeout
+		apiErr.Err = err
+		return apiErr
+	}
+	if errors.Is(err, service.ErrUnauthenticated) {
+		apiErr = Unauthorized("invalid credentials")
+		apiErr.Err = err
+		return apiErr
+	}
+
+	// handlers knowing the resource respond with NotFound or Conflict, these are the fallbacks
+	if errors.Is(err, repository.ErrUniqueViolation) {
+		return ConflictError(err)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return APIError{
+			StatusCode: http.StatusNotFound,
+			Code:       ErrorCodeNotFound,
+			Message:    "resource not found",
+			Err:        err,
+		}
+	}
 
-	// TODO: handle other cases like not found, unique violation, etc.
 	return OtherError(err)
 }
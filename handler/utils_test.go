@@ -1,14 +1,23 @@
 package handler_test
 
 import (
+	"context"
+	cortexContext "cortex/context"
 	"cortex/handler"
+	"cortex/repository"
+	"cortex/service"
 	"cortex/test"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNotFound(t *testing.T) {
@@ -16,11 +25,67 @@ func TestNotFound(t *testing.T) {
 	assert.Equal(t, err.StatusCode, http.StatusNotFound)
 }
 
+func TestConflict(t *testing.T) {
+	err := handler.Conflict("scan is running")
+	assert.Equal(t, http.StatusConflict, err.StatusCode)
+	assert.Equal(t, "conflict: scan is running", err.Message)
+}
+
+func TestConflictError(t *testing.T) {
+	err := handler.ConflictError(repository.ErrUniqueViolation)
+	assert.Equal(t, http.StatusConflict, err.StatusCode)
+	assert.Equal(t, "conflict: resource already exists", err.Message)
+	assert.ErrorIs(t, err, repository.ErrUniqueViolation)
+}
+
+func TestUnauthorized(t *testing.T) {
+	err := handler.Unauthorized("invalid credentials")
+	assert.Equal(t, http.StatusUnauthorized, err.StatusCode)
+	assert.Equal(t, "unauthorized: invalid credentials", err.Message)
+}
+
+func TestForbidden(t *testing.T) {
+	err := handler.Forbidden("admin role required")
+	assert.Equal(t, http.StatusForbidden, err.StatusCode)
+	assert.Equal(t, "forbidden: admin role required", err.Message)
+}
+
+func TestTooManyRequests(t *testing.T) {
+	err := handler.TooManyRequests("retry later")
+	assert.Equal(t, http.StatusTooManyRequests, err.StatusCode)
+	assert.Equal(t, "too many requests: retry later", err.Message)
+}
+
+func TestServiceUnavailable(t *testing.T) {
+	err := handler.ServiceUnavailable("database is busy", 2*time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, err.StatusCode)
+	assert.Equal(t, "service unavailable: database is busy", err.Message)
+	assert.Equal(t, 2*time.Second, err.RetryAfter)
+}
+
 func TestOtherError(t *testing.T) {
 	err := handler.OtherError(errors.New("test"))
 	assert.Equal(t, err.StatusCode, http.StatusInternalServerError)
 }
 
+func TestWrapErrorRepositoryErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("create agent: %w", repository.ErrUniqueViolation), http.StatusConflict},
+		{fmt.Errorf("get scan: %w", repository.ErrNotFound), http.StatusNotFound},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+		// errors of handlers knowing the resource are kept
+		{handler.NotFound("asset", "a"), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.status, handler.WrapError(tt.err).StatusCode)
+		})
+	}
+}
+
 func TestRespondError(t *testing.T) {
 	testErr := errors.New("test")
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -30,9 +95,10 @@ func TestRespondError(t *testing.T) {
 		ID:         "",
 		APIVersion: 1,
 		Error: handler.ErrorResponseValue{
-			Code:    http.StatusBadRequest,
-			Message: "test",
-			Errors:  make([]handler.ErrorResponseStack, 0),
+			Code:      http.StatusBadRequest,
+			ErrorCode: handler.ErrorCodeValidationFailed,
+			Message:   "test",
+			Errors:    make([]handler.ErrorResponseStack, 0),
 		},
 	}
 
@@ -42,6 +108,39 @@ func TestRespondError(t *testing.T) {
 	test.AssertJSON(t, rr.Body.String(), expectedResponse)
 }
 
+func TestRespondErrorFieldErrors(t *testing.T) {
+	type requestBody struct {
+		Name     string `json:"name"`
+		Endpoint string `json:"endpoint"`
+	}
+
+	h := handler.Make(func(w http.ResponseWriter, r *http.Request) error {
+		var body requestBody
+		err := handler.ValidateRequestBody(r, &body,
+			handler.Field(&body.Name, handler.Required()),
+			handler.Field(&body.Endpoint, handler.Length(3, handler.AnyLength), handler.Host()).AllErrors(),
+		)
+		return handler.WrapError(err)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"","endpoint":"a_"}`))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	var response handler.ErrorResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, []handler.ErrorResponseStack{
+		{
+			Field:   "endpoint",
+			Message: "must be at least 3 characters long; must be a valid hostname or IP address",
+			Reason:  "validation",
+		},
+		{Field: "name", Message: "is required", Reason: "validation"},
+	}, response.Error.Errors)
+	assert.Contains(t, rr.Body.String(), `"field":"name"`)
+}
+
 func TestRespondOneSimple(t *testing.T) {
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -104,11 +203,12 @@ func TestRespondMany(t *testing.T) {
 	data := []string{"test1", "test2"}
 	err := handler.RespondMany(rr, req, data)
 
+	total := 2
 	expectedResponse := handler.ArrayDataResponse[string]{
 		ID:         "",
 		APIVersion: 1,
 		Data: handler.APIComponentArray[string]{
-			TotalItems:       2,
+			TotalItems:       &total,
 			Items:            data,
 			StartIndex:       0,
 			CurrentItemCount: 2,
@@ -120,6 +220,113 @@ func TestRespondMany(t *testing.T) {
 	test.AssertJSON(t, rr.Body.String(), expectedResponse)
 }
 
+func TestRespondPage(t *testing.T) {
+	respond := func(target string, data []string, page handler.Page) handler.APIComponentArray[string] {
+		rr := httptest.NewRecorder()
+		require.NoError(t, handler.RespondPage(rr, httptest.NewRequest(http.MethodGet, target, nil), data, page))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var response handler.ArrayDataResponse[string]
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response.Data
+	}
+
+	t.Run("first page", func(t *testing.T) {
+		// the item fetched beyond the page is not sent, it tells that there is a next page
+		data := respond("/findings?severity=high&limit=2", []string{"a", "b", "c"}, handler.Page{Limit: 2})
+
+		assert.Equal(t, 0, data.StartIndex)
+		assert.Equal(t, "/findings?limit=2&offset=2&severity=high", data.Next)
+		assert.Empty(t, data.Prev)
+		// the total of a paginated list is unknown, the page size is no substitute
+		assert.Nil(t, data.TotalItems)
+		assert.Equal(t, 2, data.CurrentItemCount)
+		assert.Equal(t, []string{"a", "b"}, data.Items)
+	})
+
+	t.Run("middle page", func(t *testing.T) {
+		data := respond("/findings?severity=high&limit=2&offset=3", []string{"d", "e", "f"},
+			handler.Page{Limit: 2, Offset: 3})
+
+		assert.Equal(t, 3, data.StartIndex)
+		assert.Equal(t, "/findings?limit=2&offset=5&severity=high", data.Next)
+		assert.Equal(t, "/findings?limit=2&offset=1&severity=high", data.Prev)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		data := respond("/findings?limit=2&offset=4", []string{"f"}, handler.Page{Limit: 2, Offset: 4})
+
+		assert.Equal(t, 4, data.StartIndex)
+		assert.Empty(t, data.Next)
+		assert.Equal(t, "/findings?limit=2&offset=2", data.Prev)
+	})
+
+	t.Run("full last page", func(t *testing.T) {
+		data := respond("/findings?limit=2&offset=4", []string{"e", "f"}, handler.Page{Limit: 2, Offset: 4})
+
+		assert.Equal(t, 2, data.CurrentItemCount)
+		// no item follows the page, so there is no link to an empty one
+		assert.Empty(t, data.Next)
+		assert.Equal(t, "/findings?limit=2&offset=2", data.Prev)
+	})
+
+	t.Run("not paginated", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		require.NoError(t, handler.RespondPage(rr, httptest.NewRequest(http.MethodGet, "/agents", nil),
+			[]string{"a"}, handler.Page{}))
+
+		assert.NotContains(t, rr.Body.String(), `"next"`)
+		assert.NotContains(t, rr.Body.String(), `"prev"`)
+		assert.Contains(t, rr.Body.String(), `"totalItems":1`)
+	})
+}
+
+func TestRespondETag(t *testing.T) {
+	data := []string{"test1", "test2"}
+	respond := func(method string, requestID string, ifNoneMatch string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), cortexContext.KeyRequestID, requestID))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		require.NoError(t, handler.RespondMany(rr, req, data))
+		return rr
+	}
+
+	first := respond(http.MethodGet, "req-1", "")
+	etag := first.Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Regexp(t, `^W/"[0-9a-f]{32}"$`, etag)
+	// the ETag covers the data, not the response id
+	assert.Equal(t, etag, respond(http.MethodGet, "req-2", "").Header().Get("ETag"))
+
+	for _, ifNoneMatch := range []string{etag, `"other", ` + etag, strings.TrimPrefix(etag, "W/"), "*"} {
+		rr := respond(http.MethodGet, "req-3", ifNoneMatch)
+		assert.Equal(t, http.StatusNotModified, rr.Code, ifNoneMatch)
+		assert.Empty(t, rr.Body.String())
+		assert.Equal(t, etag, rr.Header().Get("ETag"))
+	}
+
+	rr := respond(http.MethodGet, "req-4", `W/"other"`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+	assert.Contains(t, rr.Body.String(), `"items":["test1","test2"]`)
+
+	// changes are not conditional
+	assert.Equal(t, http.StatusOK, respond(http.MethodPatch, "req-5", etag).Code)
+}
+
+func TestRespondETagChangesWithData(t *testing.T) {
+	etag := func(data string) string {
+		rr := httptest.NewRecorder()
+		require.NoError(t, handler.RespondOne(rr, httptest.NewRequest(http.MethodGet, "/", nil), data))
+		return rr.Header().Get("ETag")
+	}
+	assert.Equal(t, etag("a"), etag("a"))
+	assert.NotEqual(t, etag("a"), etag("b"))
+}
+
 func TestMakeGenericError(t *testing.T) {
 	testHandler := func(w http.ResponseWriter, r *http.Request) error {
 		return errors.New("test")
@@ -129,9 +336,10 @@ func TestMakeGenericError(t *testing.T) {
 		ID:         "",
 		APIVersion: 1,
 		Error: handler.ErrorResponseValue{
-			Code:    http.StatusInternalServerError,
-			Message: "test",
-			Errors:  make([]handler.ErrorResponseStack, 0),
+			Code:      http.StatusInternalServerError,
+			ErrorCode: handler.ErrorCodeInternal,
+			Message:   "test",
+			Errors:    make([]handler.ErrorResponseStack, 0),
 		},
 	}
 
@@ -153,9 +361,10 @@ func TestMakeAPIError(t *testing.T) {
 		ID:         "",
 		APIVersion: 1,
 		Error: handler.ErrorResponseValue{
-			Code:    http.StatusNotFound,
-			Message: "API error: test with id 1 not found",
-			Errors:  make([]handler.ErrorResponseStack, 0),
+			Code:      http.StatusNotFound,
+			ErrorCode: "test_not_found",
+			Message:   "API error: test with id 1 not found",
+			Errors:    make([]handler.ErrorResponseStack, 0),
 		},
 	}
 
@@ -169,3 +378,66 @@ func TestMakeAPIError(t *testing.T) {
 
 	test.AssertJSON(t, rr.Body.String(), expectedResponse)
 }
+
+func TestMakeDatabaseUnavailable(t *testing.T) {
+	dbErr := fmt.Errorf("%w: waited 5s, 1 of 1 connections in use", service.ErrDatabaseUnavailable)
+
+	for name, testHandler := range map[string]handler.APIFunc{
+		"raw": func(w http.ResponseWriter, r *http.Request) error {
+			return dbErr
+		},
+		"wrapped": func(w http.ResponseWriter, r *http.Request) error {
+			return handler.WrapError(dbErr)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			handler.Make(testHandler).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+			assert.Equal(t, "5", rr.Header().Get("Retry-After"))
+
+			var response handler.ErrorResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, "API error: service unavailable: database is busy, retry later", response.Error.Message)
+		})
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   handler.ErrorCode
+	}{
+		{"not found", fmt.Errorf("asset: %w", repository.ErrNotFound), http.StatusNotFound, handler.ErrorCodeNotFound},
+		{"resource not found", handler.NotFound("scan configuration", "1"), http.StatusNotFound, "scan_configuration_not_found"},
+		{"unique violation", repository.ErrUniqueViolation, http.StatusConflict, handler.ErrorCodeAlreadyExists},
+		{"conflict", handler.Conflict("scan is running"), http.StatusConflict, handler.ErrorCodeConflict},
+		{"unauthenticated", service.ErrUnauthenticated, http.StatusUnauthorized, handler.ErrorCodeUnauthorized},
+		{"forbidden", handler.Forbidden("admins only"), http.StatusForbidden, handler.ErrorCodeForbidden},
+		{"struct validation", handler.NewStructValidationError(map[string]error{"name": errors.New("required")}),
+			http.StatusBadRequest, handler.ErrorCodeValidationFailed},
+		{"validation", handler.NewValidationError("invalid"), http.StatusBadRequest, handler.ErrorCodeValidationFailed},
+		{"database unavailable", service.ErrDatabaseUnavailable, http.StatusServiceUnavailable, handler.ErrorCodeServiceUnavailable},
+		{"timeout", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, handler.ErrorCodeTimeout},
+		{"other", errors.New("boom"), http.StatusInternalServerError, handler.ErrorCodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.Make(func(http.ResponseWriter, *http.Request) error {
+				return handler.WrapError(tt.err)
+			}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			var response handler.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.status, response.Error.Code)
+			assert.Equal(t, tt.code, response.Error.ErrorCode)
+		})
+	}
+}
@@ -31,6 +31,29 @@
 //	    Field("Password", req.Password, Required(), Length(8, AnyLength)),
 //	)
 //
+// Validation stops at the first failing rule of a field. Use AllErrors to report every failing rule of a field,
+// the messages are joined in a FieldErrors:
+//
+//	Field(&req.Password, Length(8, AnyLength), Regex("[0-9]")).AllErrors()
+//
+// # Cross-Field Validation
+//
+// Rules validate a single field. Constraints spanning multiple fields are added to ValidateRequestBody with Check,
+// which runs after field validation and reports its errors keyed by JSON field name alongside the field errors:
+//
+//	err := ValidateRequestBody(r, &req,
+//	    Field(&req.StartTime, Min(0)),
+//	    Field(&req.EndTime, Min(0)),
+//	    Check(func(req *ScanRequest) error {
+//	        if req.EndTime < req.StartTime {
+//	            return NewStructValidationError(map[string]error{
+//	                "endTime": NewValidationError("must not be before startTime"),
+//	            })
+//	        }
+//	        return nil
+//	    }),
+//	)
+//
 // # Single Value Validation
 //
 // ValidateString validates individual string values:
@@ -41,15 +64,28 @@
 //
 // String rules:
 // - Required(): validates non-empty values
+// - NotBlank(): validates values that are not empty or whitespace only
+// - Trimmed(): validates values without leading or trailing whitespace
 // - Length(min, max): validates string length (use AnyLength for no limit)
+// - MaxLength(max): validates the maximum string length
 // - Regex(pattern): validates against regex pattern
 // - UUID(): validates UUID format
+// - Email(): validates email addresses
+// - Host(): validates hostnames and IP addresses
+// - CIDR(): validates networks in CIDR notation
+// - URL(): validates absolute http and https URLs
 // - In(values...): validates value is in allowed list
+// - HasUpper(), HasLower(), HasDigit(), HasSpecial(): validate the string contains a character of the class
+// - StrongPassword(minLen): validates length and all character classes above
+//
+// Generic rules:
+// - OneOf(values...): validates a value of any comparable type, e.g. an int or a typed enum, is in allowed list
 //
 // Numeric rules:
 // - Min(min): validates minimum value for int, int64, float64
 // - Max(max): validates maximum value for int, int64, float64
 // - Range(min, max): validates value is within range
+// - UnixTimestamp(minTime, maxTime): validates a Unix timestamp in seconds is within a time window
 //
 // Slice/Array rules:
 // - MinItems(min): validates minimum number of elements
@@ -65,11 +101,20 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"reflect"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 // ValidationError represents a validation error for a single field or value
@@ -104,13 +149,46 @@ func (e StructValidationError) Error() string {
 	return fmt.Sprintf("validation failed: %s", strings.Join(messages, "; "))
 }
 
+// responseStack returns one error response entry per field, sorted by field name.
+func (e StructValidationError) responseStack() []ErrorResponseStack {
+	stack := make([]ErrorResponseStack, 0, len(e.Errors))
+	for _, field := range slices.Sorted(maps.Keys(e.Errors)) {
+		stack = append(stack, ErrorResponseStack{
+			Field:   field,
+			Message: validationMessage(e.Errors[field]),
+			Reason:  errorReasonValidation,
+		})
+	}
+	return stack
+}
+
+// validationMessage returns the message of a rule error without the "validation error" prefix.
+func validationMessage(err error) string {
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		messages := make([]string, 0, len(fieldErrs))
+		for _, fieldErr := range fieldErrs {
+			messages = append(messages, validationMessage(fieldErr))
+		}
+		return strings.Join(messages, "; ")
+	}
+	var validationErr ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Message
+	}
+	return err.Error()
+}
+
 type ValidationRule func(any) error
 
 const AnyLength int = 0
 
 func Length(min int, max int) ValidationRule {
 	return func(value any) error {
-		valueStr := value.(string)
+		valueStr, ok := value.(string)
+		if !ok {
+			return NewValidationError("Length validator only supports strings")
+		}
 		if min != AnyLength {
 			if len(valueStr) < min {
 				return NewValidationError(fmt.Sprintf("must be at least %d characters long", min))
@@ -125,10 +203,32 @@ func Length(min int, max int) ValidationRule {
 	}
 }
 
+// MaxLength validates that a string is at most max characters long.
+func MaxLength(max int) ValidationRule {
+	return Length(AnyLength, max)
+}
+
+// Maximum lengths of text fields, within the limits of the columns they are stored in.
+const (
+	// MaxNameLength applies to the names of agents and scan configurations, usernames and display names.
+	MaxNameLength = 255
+	// MaxEndpointLength applies to asset endpoints.
+	MaxEndpointLength = 2048
+)
+
+// NameRules returns the rules of resource names: not blank, without surrounding whitespace and at most MaxNameLength
+// characters long.
+func NameRules() []ValidationRule {
+	return []ValidationRule{NotBlank(), Trimmed(), MaxLength(MaxNameLength)}
+}
+
 func Regex(regex string) ValidationRule {
 	regexCompiled := regexp.MustCompile(regex)
 	return func(value any) error {
-		valueStr := value.(string)
+		valueStr, ok := value.(string)
+		if !ok {
+			return NewValidationError("Regex validator only supports strings")
+		}
 		if !regexCompiled.MatchString(valueStr) {
 			return NewValidationError(fmt.Sprintf("must match regex %s", regex))
 		}
@@ -136,6 +236,106 @@ func Regex(regex string) ValidationRule {
 	}
 }
 
+// emailRegex matches addresses with a local part, a domain and an alphabetic top level domain.
+var emailRegex = regexp.MustCompile(
+	"^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@" +
+		`[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*` +
+		`\.[a-zA-Z]{2,63}$`)
+
+// Email validates that a string is an email address. Surrounding whitespace is not accepted.
+func Email() ValidationRule {
+	return func(value any) error {
+		str, ok := value.(string)
+		if !ok {
+			return NewValidationError("Email validator only supports strings")
+		}
+		if len(str) > 254 || !emailRegex.MatchString(str) {
+			return NewValidationError("must be a valid email address")
+		}
+		return nil
+	}
+}
+
+// hostnameLabelRegex matches a single DNS label as defined by RFC 1123.
+var hostnameLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// isHostname reports whether host is a DNS hostname. A single trailing dot is allowed for fully qualified names.
+func isHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if !hostnameLabelRegex.MatchString(label) {
+			return false
+		}
+	}
+	// an all numeric top level label is a malformed IPv4 address like 10.0..1 or 10.0.1
+	_, err := strconv.Atoi(labels[len(labels)-1])
+	return err != nil
+}
+
+// isIP reports whether host is an IPv4 or IPv6 address. IPv6 addresses may be enclosed in brackets.
+func isIP(host string) bool {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		addr, err := netip.ParseAddr(host[1 : len(host)-1])
+		return err == nil && addr.Is6() && addr.Zone() == ""
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.Zone() == ""
+}
+
+// Host validates that a string is a hostname or an IP address. Ports are not accepted.
+func Host() ValidationRule {
+	return func(value any) error {
+		str, ok := value.(string)
+		if !ok {
+			return NewValidationError("Host validator only supports strings")
+		}
+		if !isIP(str) && !isHostname(str) {
+			return NewValidationError("must be a valid hostname or IP address")
+		}
+		return nil
+	}
+}
+
+// CIDR validates that a string is an IPv4 or IPv6 network in CIDR notation, e.g. 10.0.0.0/24.
+func CIDR() ValidationRule {
+	return func(value any) error {
+		str, ok := value.(string)
+		if !ok {
+			return NewValidationError("CIDR validator only supports strings")
+		}
+		if _, err := netip.ParsePrefix(str); err != nil {
+			return NewValidationError("must be a valid CIDR range")
+		}
+		return nil
+	}
+}
+
+// URL validates that a string is an absolute http or https URL with a valid host.
+func URL() ValidationRule {
+	return func(value any) error {
+		str, ok := value.(string)
+		if !ok {
+			return NewValidationError("URL validator only supports strings")
+		}
+		u, err := url.Parse(str)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return NewValidationError("must be a valid http or https URL")
+		}
+		host := u.Hostname()
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if !isIP(host) && !isHostname(host) {
+			return NewValidationError("must be a valid http or https URL")
+		}
+		return nil
+	}
+}
+
 func UUID() ValidationRule {
 	return Regex("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
 }
@@ -176,16 +376,123 @@ func Required() ValidationRule {
 	}
 }
 
-// In validates that a value is in a list of allowed values
-func In(allowed ...string) ValidationRule {
+// NotBlank validates that a string is not empty after trimming whitespace, so values like "   " or "\t\n" are rejected.
+func NotBlank() ValidationRule {
 	return func(value any) error {
-		valueStr := value.(string)
-		for _, v := range allowed {
-			if valueStr == v {
-				return nil
+		str, ok := value.(string)
+		if !ok {
+			return NewValidationError("NotBlank validator only supports strings")
+		}
+		if strings.TrimSpace(str) == "" {
+			return NewValidationError("must not be blank")
+		}
+		return nil
+	}
+}
+
+// Trimmed validates that a string has no leading or trailing whitespace. Values are not trimmed implicitly, this rule
+// documents that the caller is expected to send them trimmed.
+func Trimmed() ValidationRule {
+	return func(value any) error {
+		str, ok := value.(string)
+		if !ok {
+			return NewValidationError("Trimmed validator only supports strings")
+		}
+		if strings.TrimSpace(str) != str {
+			return NewValidationError("must not have leading or trailing whitespace")
+		}
+		return nil
+	}
+}
+
+// containsRune returns a rule validating that a string contains at least one rune matching predicate.
+func containsRune(validator string, message string, predicate func(rune) bool) ValidationRule {
+	return func(value any) error {
+		str, ok := value.(string)
+		if !ok {
+			return NewValidationError(fmt.Sprintf("%s validator only supports strings", validator))
+		}
+		if !strings.ContainsFunc(str, predicate) {
+			return NewValidationError(message)
+		}
+		return nil
+	}
+}
+
+// HasUpper validates that a string contains at least one uppercase letter.
+func HasUpper() ValidationRule {
+	return containsRune("HasUpper", "must contain an uppercase letter", unicode.IsUpper)
+}
+
+// HasLower validates that a string contains at least one lowercase letter.
+func HasLower() ValidationRule {
+	return containsRune("HasLower", "must contain a lowercase letter", unicode.IsLower)
+}
+
+// HasDigit validates that a string contains at least one digit.
+func HasDigit() ValidationRule {
+	return containsRune("HasDigit", "must contain a digit", unicode.IsDigit)
+}
+
+// HasSpecial validates that a string contains at least one character that is neither a letter, a digit nor whitespace.
+func HasSpecial() ValidationRule {
+	return containsRune("HasSpecial", "must contain a special character", func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+	})
+}
+
+// StrongPassword validates that a password has at least minLen characters and contains an uppercase letter, a
+// lowercase letter, a digit and a special character. All unmet requirements are reported as FieldErrors.
+func StrongPassword(minLen int) ValidationRule {
+	rules := []ValidationRule{Length(minLen, AnyLength), HasUpper(), HasLower(), HasDigit(), HasSpecial()}
+	return func(value any) error {
+		if _, ok := value.(string); !ok {
+			return NewValidationError("StrongPassword validator only supports strings")
+		}
+
+		var errs FieldErrors
+		for _, rule := range rules {
+			if err := rule(value); err != nil {
+				errs = append(errs, err)
 			}
 		}
-		return NewValidationError(fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", ")))
+		switch len(errs) {
+		case 0:
+			return nil
+		case 1:
+			return errs[0]
+		default:
+			return errs
+		}
+	}
+}
+
+// In validates that a string value is in a list of allowed values
+func In(allowed ...string) ValidationRule {
+	oneOf := OneOf(allowed...)
+	return func(value any) error {
+		if _, ok := value.(string); !ok {
+			return NewValidationError("In validator only supports strings")
+		}
+		return oneOf(value)
+	}
+}
+
+// OneOf validates that a value of type T is in a list of allowed values. Values of another type fail validation.
+func OneOf[T comparable](allowed ...T) ValidationRule {
+	return func(value any) error {
+		v, ok := value.(T)
+		if !ok {
+			return NewValidationError(fmt.Sprintf("OneOf validator expects %T values, got %T", *new(T), value))
+		}
+		if slices.Contains(allowed, v) {
+			return nil
+		}
+		names := make([]string, len(allowed))
+		for i, a := range allowed {
+			names[i] = fmt.Sprint(a)
+		}
+		return NewValidationError(fmt.Sprintf("must be one of: %s", strings.Join(names, ", ")))
 	}
 }
 
@@ -261,6 +568,26 @@ func Range[T int | int64 | float64](min T, max T) ValidationRule {
 	}
 }
 
+// UnixTimestamp validates that an int or int64 Unix timestamp in seconds is between minTime and maxTime (inclusive).
+func UnixTimestamp(minTime time.Time, maxTime time.Time) ValidationRule {
+	return func(value any) error {
+		var seconds int64
+		switch v := value.(type) {
+		case int:
+			seconds = int64(v)
+		case int64:
+			seconds = v
+		default:
+			return NewValidationError("UnixTimestamp validator only supports int and int64 types")
+		}
+		if seconds < minTime.Unix() || seconds > maxTime.Unix() {
+			return NewValidationError(fmt.Sprintf("must be a Unix timestamp between %s and %s",
+				minTime.UTC().Format(time.RFC3339), maxTime.UTC().Format(time.RFC3339)))
+		}
+		return nil
+	}
+}
+
 // MinItems validates that a slice, array, or map has at least min elements.
 // Use AnyLength for no minimum limit.
 func MinItems(min int) ValidationRule {
@@ -391,17 +718,45 @@ func ValidateString(value string, rules ...ValidationRule) *ValidationContext[st
 	return &ValidationContext[string]{fieldValueRaw: value, rules: rules}
 }
 
+// FieldErrors aggregates the errors of all failed rules of a single field.
+type FieldErrors []error
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e FieldErrors) Unwrap() []error {
+	return e
+}
+
 // FieldRules represents validation rules for a struct field
 type FieldRules struct {
 	FieldName string
 	Value     any
 	Rules     []ValidationRule
+	// AllErrors runs every rule and reports all failures as FieldErrors instead of stopping at the first one
+	AllErrors bool
 }
 
 // FieldValidation represents a field pointer and its validation rules
 type FieldValidation struct {
-	FieldPtr any
-	Rules    []ValidationRule
+	FieldPtr  any
+	Rules     []ValidationRule
+	allErrors bool
+	// check validates the whole request body, see Check
+	check func(target any) error
+}
+
+// AllErrors makes the field report every failing rule instead of only the first one, e.g.
+//
+//	Field(&req.Password, Length(12, AnyLength), Regex("[0-9]")).AllErrors()
+func (f FieldValidation) AllErrors() FieldValidation {
+	f.allErrors = true
+	return f
 }
 
 // Field creates a FieldValidation for use with ValidateRequestBody
@@ -413,6 +768,19 @@ func Field(fieldPtr any, rules ...ValidationRule) FieldValidation {
 	}
 }
 
+// Check creates a FieldValidation running fn with the decoded request body after all fields were validated, for
+// constraints spanning multiple fields. fn returns nil or a StructValidationError keyed by JSON field names, its errors
+// are reported together with field errors. Fields that already failed validation keep their own error.
+func Check[T any](fn func(target *T) error) FieldValidation {
+	return FieldValidation{check: func(target any) error {
+		t, ok := target.(*T)
+		if !ok {
+			return fmt.Errorf("check expects a %T request body, got %T", t, target)
+		}
+		return fn(t)
+	}}
+}
+
 // fieldRulesCompat creates a FieldRules mapping for struct validation (backward compatibility)
 func fieldRulesCompat(fieldName string, value any, rules ...ValidationRule) FieldRules {
 	return FieldRules{
@@ -427,6 +795,19 @@ func ValidateStruct(fields ...FieldRules) error {
 	errors := make(map[string]error)
 
 	for _, field := range fields {
+		if field.AllErrors {
+			var fieldErrors FieldErrors
+			for _, rule := range field.Rules {
+				if err := rule(field.Value); err != nil {
+					fieldErrors = append(fieldErrors, err)
+				}
+			}
+			if len(fieldErrors) > 0 {
+				errors[field.FieldName] = fieldErrors
+			}
+			continue
+		}
+
 		for _, rule := range field.Rules {
 			if err := rule(field.Value); err != nil {
 				errors[field.FieldName] = err
@@ -500,15 +881,36 @@ func ValidateRequestBody[T any](r *http.Request, target *T, fields ...FieldValid
 	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
 		return NewValidationError("invalid JSON in request body")
 	}
+	return validateFields(target, fields...)
+}
 
+// ValidateOptionalRequestBody is ValidateRequestBody for requests whose body may be left out. An empty body leaves the
+// target unchanged and validates it as it is. The body is checked itself rather than the Content-Length header, which
+// is unknown for chunked requests.
+func ValidateOptionalRequestBody[T any](r *http.Request, target *T, fields ...FieldValidation) error {
+	err := json.NewDecoder(r.Body).Decode(target)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return NewValidationError("invalid JSON in request body")
+	}
+	return validateFields(target, fields...)
+}
+
+// validateFields validates the fields of a decoded request body.
+func validateFields[T any](target *T, fields ...FieldValidation) error {
 	// Convert FieldValidation to FieldRules using reflection
 	var fieldRules []FieldRules
-	errors := make(map[string]error)
+	var checks []func(target any) error
+	validationErrors := make(map[string]error)
 
 	for _, field := range fields {
+		if field.check != nil {
+			checks = append(checks, field.check)
+			continue
+		}
+
 		fieldName, err := getJSONFieldName(target, field.FieldPtr)
 		if err != nil {
-			errors[fmt.Sprintf("%v", field.FieldPtr)] = err
+			validationErrors[fmt.Sprintf("%v", field.FieldPtr)] = err
 			continue
 		}
 
@@ -519,14 +921,43 @@ func ValidateRequestBody[T any](r *http.Request, target *T, fields ...FieldValid
 			FieldName: fieldName,
 			Value:     fieldValue,
 			Rules:     field.Rules,
+			AllErrors: field.allErrors,
 		})
 	}
 
 	// Check for errors during field name extraction
-	if len(errors) > 0 {
-		return NewStructValidationError(errors)
+	if len(validationErrors) > 0 {
+		return NewStructValidationError(validationErrors)
 	}
 
 	// Validate using existing ValidateStruct
-	return ValidateStruct(fieldRules...)
+	err := ValidateStruct(fieldRules...)
+	if len(checks) == 0 {
+		return err
+	}
+
+	var structErr StructValidationError
+	if errors.As(err, &structErr) {
+		validationErrors = structErr.Errors
+	}
+	for _, check := range checks {
+		checkErr := check(target)
+		if checkErr == nil {
+			continue
+		}
+		var checkStructErr StructValidationError
+		if !errors.As(checkErr, &checkStructErr) {
+			return checkErr
+		}
+		for field, fieldErr := range checkStructErr.Errors {
+			if _, ok := validationErrors[field]; !ok {
+				validationErrors[field] = fieldErr
+			}
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		return NewStructValidationError(validationErrors)
+	}
+	return nil
 }
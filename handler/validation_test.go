@@ -1,12 +1,19 @@
 package handler
 
 import (
+	"bytes"
+	"cortex/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPathValidationFail(t *testing.T) {
@@ -115,6 +122,72 @@ func TestInValidator(t *testing.T) {
 	assert.Contains(t, err.Error(), "must be one of")
 }
 
+func TestOneOfValidator(t *testing.T) {
+	assert.NoError(t, OneOf(1, 2, 3)(2))
+	err := OneOf(1, 2, 3)(4)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must be one of: 1, 2, 3")
+
+	statuses := OneOf(repository.ScanStatusQueued, repository.ScanStatusRunning)
+	assert.NoError(t, statuses(repository.ScanStatusRunning))
+	err = statuses(repository.ScanStatusFailed)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must be one of: queued, running")
+}
+
+func TestOneOfValidatorTypeMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  ValidationRule
+		value any
+	}{
+		{name: "untyped string for typed enum", rule: OneOf(repository.ScanStatusQueued), value: "queued"},
+		{name: "typed enum for In", rule: In("queued"), value: repository.ScanStatusQueued},
+		{name: "int64 for int", rule: OneOf(1, 2), value: int64(1)},
+		{name: "nil", rule: In("a"), value: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			require.NotPanics(t, func() { err = tt.rule(tt.value) })
+			var validationErr ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
+
+func TestStringValidatorsTypeMismatch(t *testing.T) {
+	rules := map[string]ValidationRule{
+		"Length":         Length(1, 10),
+		"MaxLength":      MaxLength(10),
+		"Regex":          Regex("^a+$"),
+		"UUID":           UUID(),
+		"Email":          Email(),
+		"Host":           Host(),
+		"CIDR":           CIDR(),
+		"URL":            URL(),
+		"NotBlank":       NotBlank(),
+		"Trimmed":        Trimmed(),
+		"HasUpper":       HasUpper(),
+		"HasLower":       HasLower(),
+		"HasDigit":       HasDigit(),
+		"HasSpecial":     HasSpecial(),
+		"StrongPassword": StrongPassword(8),
+		"In":             In("a", "b"),
+	}
+	for name, rule := range rules {
+		for _, value := range []any{42, []string{"a"}, nil} {
+			t.Run(fmt.Sprintf("%s %T", name, value), func(t *testing.T) {
+				var err error
+				require.NotPanics(t, func() { err = rule(value) })
+				var validationErr ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Contains(t, validationErr.Message, "only supports strings")
+			})
+		}
+	}
+}
+
 func TestValidateStruct(t *testing.T) {
 	type User struct {
 		Username string
@@ -185,6 +258,41 @@ func TestValidateStructMultipleRulesStopAtFirst(t *testing.T) {
 	assert.Contains(t, structErr.Errors["Username"].Error(), "is required")
 }
 
+func TestValidateStructAllErrors(t *testing.T) {
+	field := fieldRulesCompat("Username", "a!", Length(3, 20), Regex("^[a-z]+$"), Required())
+	field.AllErrors = true
+
+	err := ValidateStruct(field)
+
+	assert.Error(t, err)
+
+	structErr, ok := err.(StructValidationError)
+	assert.True(t, ok)
+	assert.Len(t, structErr.Errors, 1)
+
+	fieldErrs, ok := structErr.Errors["Username"].(FieldErrors)
+	assert.True(t, ok)
+	assert.Len(t, fieldErrs, 2)
+
+	var validationErr ValidationError
+	assert.ErrorAs(t, structErr.Errors["Username"], &validationErr)
+	assert.Contains(t, structErr.Errors["Username"].Error(), "; ")
+}
+
+func TestValidateStructAllErrorsValid(t *testing.T) {
+	field := fieldRulesCompat("Username", "john", Length(3, 20), Regex("^[a-z]+$"))
+	field.AllErrors = true
+
+	assert.NoError(t, ValidateStruct(field))
+}
+
+func TestFieldValidationAllErrors(t *testing.T) {
+	var username string
+	field := Field(&username, Required())
+	assert.False(t, field.allErrors)
+	assert.True(t, field.AllErrors().allErrors)
+}
+
 func TestValidateStructPartialFailure(t *testing.T) {
 	type User struct {
 		Username string
@@ -285,6 +393,75 @@ func TestValidateRequestBodyValidationFailure(t *testing.T) {
 	assert.Contains(t, structErr.Errors, "password")
 }
 
+type rangeRequest struct {
+	Name string `json:"name"`
+	Min  int    `json:"min"`
+	Max  int    `json:"max"`
+}
+
+func checkRange(req *rangeRequest) error {
+	if req.Max < req.Min {
+		return NewStructValidationError(map[string]error{"max": NewValidationError("must not be less than min")})
+	}
+	return nil
+}
+
+func TestValidateRequestBodyCheck(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/ranges", strings.NewReader(`{"name":"ports","min":1,"max":10}`))
+
+	var result rangeRequest
+	err := ValidateRequestBody(req, &result,
+		Field(&result.Name, Required()),
+		Check(checkRange),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, rangeRequest{Name: "ports", Min: 1, Max: 10}, result)
+}
+
+func TestValidateRequestBodyCheckFailure(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/ranges", strings.NewReader(`{"min":10,"max":1}`))
+
+	var result rangeRequest
+	err := ValidateRequestBody(req, &result,
+		Field(&result.Name, Required()),
+		Check(checkRange),
+	)
+
+	var structErr StructValidationError
+	require.ErrorAs(t, err, &structErr)
+	assert.Len(t, structErr.Errors, 2)
+	assert.Contains(t, structErr.Errors["name"].Error(), "is required")
+	assert.Contains(t, structErr.Errors["max"].Error(), "must not be less than min")
+}
+
+func TestValidateRequestBodyCheckKeepsFieldErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/ranges", strings.NewReader(`{"name":"ports","min":10,"max":-1}`))
+
+	var result rangeRequest
+	err := ValidateRequestBody(req, &result,
+		Field(&result.Max, Min(0)),
+		Check(checkRange),
+	)
+
+	var structErr StructValidationError
+	require.ErrorAs(t, err, &structErr)
+	assert.Len(t, structErr.Errors, 1)
+	assert.Contains(t, structErr.Errors["max"].Error(), "must be at least 0")
+}
+
+func TestValidateRequestBodyCheckWrongType(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/ranges", strings.NewReader(`{"name":"ports"}`))
+
+	type otherRequest struct{}
+	var result rangeRequest
+	err := ValidateRequestBody(req, &result, Check(func(*otherRequest) error { return nil }))
+
+	assert.Error(t, err)
+	var structErr StructValidationError
+	assert.False(t, errors.As(err, &structErr))
+}
+
 func TestValidateRequestBodyInvalidJSON(t *testing.T) {
 	type LoginRequest struct {
 		Username string `json:"username"`
@@ -364,7 +541,7 @@ func TestValidateRequestBodyAutoDerivesJSONFieldNames(t *testing.T) {
 	var result CreateUserRequest
 	err := ValidateRequestBody(req, &result,
 		Field(&result.UserName, Required()),
-		Field(&result.Email, Required(), Regex(`^[^@]+@[^@]+\.[^@]+$`)),
+		Field(&result.Email, Required(), Email()),
 	)
 
 	assert.Error(t, err)
@@ -510,6 +687,21 @@ func TestRangeValidator(t *testing.T) {
 
 // Slice/Array validation tests
 
+func TestUnixTimestampValidator(t *testing.T) {
+	rule := UnixTimestamp(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, rule(int(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC).Unix())))
+	assert.NoError(t, rule(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Unix()))
+
+	err := rule(int(time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC).Unix()))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must be a Unix timestamp between 2020-01-01T00:00:00Z and 2030-01-01T00:00:00Z")
+	assert.Error(t, rule(int64(0)))
+
+	assert.Error(t, rule(1.5))
+	assert.Error(t, rule("1700000000"))
+}
+
 func TestRequiredWithSlices(t *testing.T) {
 	// Non-empty slice should pass
 	err := Required()([]string{"a", "b"})
@@ -814,3 +1006,248 @@ func TestValidateRequestBodyComplexExample(t *testing.T) {
 	assert.Len(t, result.Tags, 2)
 	assert.Len(t, result.Metadata, 2)
 }
+
+func TestNotBlankValidator(t *testing.T) {
+	assert.NoError(t, NotBlank()("test"))
+	assert.NoError(t, NotBlank()("  padded  "))
+
+	for _, blank := range []string{"", " ", "   ", "\t", "\n", " \t\r\n "} {
+		err := NotBlank()(blank)
+		assert.Error(t, err, "%q should be blank", blank)
+		assert.Contains(t, err.Error(), "must not be blank")
+	}
+
+	assert.Error(t, NotBlank()(42))
+}
+
+func TestTrimmedValidator(t *testing.T) {
+	assert.NoError(t, Trimmed()("example.com"))
+	assert.NoError(t, Trimmed()("two words"))
+
+	for _, untrimmed := range []string{" example.com", "example.com ", "\texample.com", "example.com\n"} {
+		err := Trimmed()(untrimmed)
+		assert.Error(t, err, "%q should be rejected", untrimmed)
+		assert.Contains(t, err.Error(), "leading or trailing whitespace")
+	}
+}
+
+func TestValidateRequestBodyRejectsBlankValues(t *testing.T) {
+	type body struct {
+		Endpoint string `json:"endpoint"`
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(`{"endpoint": " \t\n "}`))
+	var b body
+	err := ValidateRequestBody(req, &b, Field(&b.Endpoint, NotBlank(), Length(1, 2048)))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "endpoint: validation error: must not be blank")
+}
+
+func TestEmailValidator(t *testing.T) {
+	valid := []string{
+		"john@example.com",
+		"john.doe+scanner@mail.example.co.uk",
+		"j_o-h.n@sub-domain.example.org",
+	}
+	for _, email := range valid {
+		assert.NoError(t, Email()(email), email)
+	}
+
+	invalid := []string{
+		"",
+		"john.example.com",
+		"john@example",
+		"john@example.",
+		"@example.com",
+		"john@@example.com",
+		" john@example.com",
+		"john@example.com ",
+		"john@-example.com",
+	}
+	for _, email := range invalid {
+		err := Email()(email)
+		assert.Error(t, err, "%q should be invalid", email)
+		assert.Contains(t, err.Error(), "must be a valid email address")
+	}
+
+	assert.Error(t, Email()(42))
+}
+
+func TestEmailValidatorInStruct(t *testing.T) {
+	err := ValidateStruct(
+		fieldRulesCompat("email", "not-an-email", Email()),
+	)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "email: validation error: must be a valid email address")
+}
+
+func TestHostValidator(t *testing.T) {
+	valid := []string{
+		"example.com",
+		"example.com.",
+		"sub-domain.example.co.uk",
+		"localhost",
+		"192.168.0.1",
+		"2001:db8::1",
+		"[2001:db8::1]",
+	}
+	for _, host := range valid {
+		assert.NoError(t, Host()(host), host)
+	}
+
+	invalid := []string{
+		"",
+		"not a host",
+		"example.com:443",
+		"192.168.0.1:22",
+		"[2001:db8::1]:443",
+		"[192.168.0.1]",
+		"192.168..1",
+		"192.168.0",
+		"256.1.1.1",
+		"example..com",
+		"example.com..",
+		"-example.com",
+		"https://example.com",
+	}
+	for _, host := range invalid {
+		err := Host()(host)
+		assert.Error(t, err, "%q should be invalid", host)
+	}
+
+	assert.Error(t, Host()(42))
+}
+
+func TestCIDRValidator(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/8", "192.168.1.0/24", "10.0.0.1/32", "2001:db8::/32"} {
+		assert.NoError(t, CIDR()(cidr), cidr)
+	}
+	for _, cidr := range []string{"10.0.0.0", "10.0.0.0/33", "10.0..0/24", "example.com/24", "10.0.0.0/"} {
+		err := CIDR()(cidr)
+		assert.Error(t, err, "%q should be invalid", cidr)
+	}
+}
+
+func TestURLValidator(t *testing.T) {
+	for _, u := range []string{"https://example.com", "http://example.com:8080/path?q=1", "https://[2001:db8::1]:8443/"} {
+		assert.NoError(t, URL()(u), u)
+	}
+	for _, u := range []string{"example.com", "ftp://example.com", "https://", "https://exa mple.com", "https://192.168..1/"} {
+		err := URL()(u)
+		assert.Error(t, err, "%q should be invalid", u)
+	}
+}
+
+func TestCharacterClassValidators(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ValidationRule
+		valid   []string
+		invalid []string
+		message string
+	}{
+		{name: "upper", rule: HasUpper(), valid: []string{"A", "abcD", "Ä"}, invalid: []string{"", "abc1!"},
+			message: "must contain an uppercase letter"},
+		{name: "lower", rule: HasLower(), valid: []string{"a", "ABCd", "ß"}, invalid: []string{"", "ABC1!"},
+			message: "must contain a lowercase letter"},
+		{name: "digit", rule: HasDigit(), valid: []string{"1", "abc9"}, invalid: []string{"", "abc!"},
+			message: "must contain a digit"},
+		{name: "special", rule: HasSpecial(), valid: []string{"!", "abc#", "a-b", "€"},
+			invalid: []string{"", "abc123", "a b"}, message: "must contain a special character"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, value := range tt.valid {
+				assert.NoError(t, tt.rule(value), value)
+			}
+			for _, value := range tt.invalid {
+				err := tt.rule(value)
+				assert.Error(t, err, value)
+				assert.Contains(t, err.Error(), tt.message)
+			}
+			assert.Error(t, tt.rule(42))
+		})
+	}
+}
+
+func TestStrongPasswordValidator(t *testing.T) {
+	assert.NoError(t, StrongPassword(12)("Correct-Horse-7"))
+
+	err := StrongPassword(12)("Short-7a")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must be at least 12 characters long")
+
+	err = StrongPassword(8)("password")
+	var fieldErrs FieldErrors
+	assert.ErrorAs(t, err, &fieldErrs)
+	assert.Len(t, fieldErrs, 3)
+	assert.Contains(t, err.Error(), "must contain an uppercase letter")
+	assert.Contains(t, err.Error(), "must contain a digit")
+	assert.Contains(t, err.Error(), "must contain a special character")
+
+	assert.Error(t, StrongPassword(8)(42))
+}
+
+func TestNameRules(t *testing.T) {
+	valid := strings.Repeat("a", MaxNameLength)
+	_, err := ValidateString(valid, NameRules()...).Validate()
+	assert.NoError(t, err)
+
+	for _, invalid := range []string{"", "   ", "\t\n", " padded", valid + "a"} {
+		_, err = ValidateString(invalid, NameRules()...).Validate()
+		assert.Error(t, err, "%q should be rejected", invalid)
+	}
+}
+
+func TestTextFieldLimits(t *testing.T) {
+	// validation fails before the services are used
+	agents := NewAgentHandler(nil)
+	configs := NewScanConfigHandler(nil)
+	assets := NewAssetHandler(nil, nil)
+	const id = "7761259c-e6dd-4930-946b-ee9975fde3e4"
+
+	tests := []struct {
+		name      string
+		handle    func(http.ResponseWriter, *http.Request) error
+		body      func(value string) any
+		maxLength int
+	}{
+		{"create agent", agents.HandleCreateAgent,
+			func(v string) any { return map[string]any{"name": v} }, MaxNameLength},
+		{"update agent", agents.HandleUpdateAgent,
+			func(v string) any { return map[string]any{"name": v} }, MaxNameLength},
+		{"create scan config", configs.HandleCreate,
+			func(v string) any { return map[string]any{"name": v, "engine": "naabu"} }, MaxNameLength},
+		{"update scan config", configs.HandleUpdate,
+			func(v string) any { return map[string]any{"id": id, "name": v} }, MaxNameLength},
+		{"create asset", assets.HandleCreate,
+			func(v string) any { return map[string]any{"endpoint": v} }, MaxEndpointLength},
+		{"update asset", assets.HandleUpdate,
+			func(v string) any { return map[string]any{"endpoint": v} }, MaxEndpointLength},
+		{"bulk create assets", assets.HandleBulkCreate,
+			func(v string) any { return map[string]any{"endpoints": []string{v}} }, MaxEndpointLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for value, message := range map[string]string{
+				"   ":                               "must not be blank",
+				"\t\n":                              "must not be blank",
+				strings.Repeat("a", tt.maxLength+1): "must be at most",
+			} {
+				body, err := json.Marshal(tt.body(value))
+				require.NoError(t, err)
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+				req.SetPathValue("id", id)
+
+				err = tt.handle(httptest.NewRecorder(), req)
+				var apiErr APIError
+				require.True(t, errors.As(err, &apiErr), "expected API error, got %v", err)
+				assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+				assert.Contains(t, apiErr.Message, message)
+			}
+		})
+	}
+}
@@ -16,6 +16,9 @@ const (
 	FieldUsername     string = "username"
 	FieldTokenID      string = "tokenId"
 	FieldAgentID      string = "agentId"
+	FieldSourceIP     string = "sourceIp"
+	FieldScheduleID   string = "scheduleId"
+	FieldActorID      string = "actorId"
 )
 
 type ContextHandler struct {
@@ -3,8 +3,10 @@ package middleware
 import (
 	"context"
 	cortexContext "cortex/context"
+	"cortex/handler"
 	"cortex/logging"
 	"cortex/service"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -13,6 +15,9 @@ import (
 const userTokenHeader = "Authorization"
 const agentTokenHeader = "X-Agent-Token"
 
+// authChallenge is sent in the WWW-Authenticate header of 401 responses.
+const authChallenge = `Bearer realm="cortex"`
+
 type Authentication struct {
 	logger      *slog.Logger
 	authService service.AuthService
@@ -30,44 +35,80 @@ func (h *Authentication) OnRequest(next http.Handler) http.Handler {
 		h.logger.DebugContext(r.Context(), "authenticating request")
 
 		// Try user authentication first
-		ctx, userAuthSuccess := h.tryUserAuthentication(r)
+		ctx, userAuthSuccess, err := h.tryUserAuthentication(r)
+		if err != nil {
+			h.respondError(w, r, err)
+			return
+		}
 
 		// Try agent authentication if user auth failed
 		if !userAuthSuccess {
 			var agentAuthSuccess bool
-			ctx, agentAuthSuccess = h.tryAgentAuthentication(r)
+			ctx, agentAuthSuccess, err = h.tryAgentAuthentication(r)
+			if err != nil {
+				h.respondError(w, r, err)
+				return
+			}
 			if !agentAuthSuccess {
-				h.logger.DebugContext(r.Context(), "both user and agent authentication failed")
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				h.respondUnauthorized(w, r)
 				return
 			}
 		}
 
+		recordAuthenticated(ctx)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// tryUserAuthentication attempts to authenticate using user token and returns updated context and success status
-func (h *Authentication) tryUserAuthentication(r *http.Request) (context.Context, bool) {
+// respondUnauthorized rejects the request with the same response whether credentials are missing or invalid, only the
+// log tells them apart.
+func (h *Authentication) respondUnauthorized(w http.ResponseWriter, r *http.Request) {
+	challenge := authChallenge
+	if r.Header.Get(userTokenHeader) == "" && r.Header.Get(agentTokenHeader) == "" {
+		h.logger.DebugContext(r.Context(), "authentication required, no credentials presented")
+	} else {
+		h.logger.InfoContext(r.Context(), "authentication failed, invalid credentials presented")
+		challenge += `, error="invalid_token"`
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	apiErr := handler.Unauthorized("missing or invalid token")
+	handler.RespondError(w, r, apiErr.StatusCode, apiErr)
+}
+
+// respondError responds with err for failures that do not tell whether the credentials are valid. These must not be
+// answered with 401, clients would discard valid credentials.
+func (h *Authentication) respondError(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.WarnContext(r.Context(), "authentication not possible", logging.FieldError, err)
+	apiErr := handler.WrapError(err)
+	handler.RespondError(w, r, apiErr.StatusCode, apiErr)
+}
+
+// tryUserAuthentication attempts to authenticate using user token and returns updated context and success status. An
+// error is returned if the token could not be validated.
+func (h *Authentication) tryUserAuthentication(r *http.Request) (context.Context, bool, error) {
 	// check for user token header
 	authHeader := r.Header.Get(userTokenHeader)
 	if authHeader == "" {
 		h.logger.DebugContext(r.Context(), "no user token found")
-		return r.Context(), false
+		return r.Context(), false, nil
 	}
 
 	headerPrefix := "Bearer "
 	tokenString, formatOk := strings.CutPrefix(authHeader, headerPrefix)
 	if !formatOk {
 		h.logger.DebugContext(r.Context(), "invalid user token format, expected Bearer")
-		return r.Context(), false
+		return r.Context(), false, nil
 	}
 
 	// validate user token
 	user, tokenId, err := h.authService.ValidateToken(r.Context(), tokenString)
 	if err != nil {
+		if errors.Is(err, service.ErrDatabaseUnavailable) {
+			return r.Context(), false, err
+		}
 		h.logger.DebugContext(r.Context(), "failed to validate user token", logging.FieldError, err)
-		return r.Context(), false
+		return r.Context(), false, nil
 	}
 
 	h.logger.DebugContext(r.Context(), "authenticated user", logging.FieldUserID, user.ID,
@@ -77,26 +118,31 @@ func (h *Authentication) tryUserAuthentication(r *http.Request) (context.Context
 		UserID:   user.ID,
 		Username: user.Username,
 		TokenID:  tokenId,
+		Role:     string(user.Role),
 	}
 
 	ctx := context.WithValue(r.Context(), cortexContext.KeyUserInfo, info)
-	return ctx, true
+	return ctx, true, nil
 }
 
-// tryAgentAuthentication attempts to authenticate using agent token and returns updated context and success status
-func (h *Authentication) tryAgentAuthentication(r *http.Request) (context.Context, bool) {
+// tryAgentAuthentication attempts to authenticate using agent token and returns updated context and success status. An
+// error is returned if the token could not be validated.
+func (h *Authentication) tryAgentAuthentication(r *http.Request) (context.Context, bool, error) {
 	// check for agent token header
 	agentToken := r.Header.Get(agentTokenHeader)
 	if agentToken == "" {
 		h.logger.DebugContext(r.Context(), "no agent token found")
-		return r.Context(), false
+		return r.Context(), false, nil
 	}
 
 	// validate agent token
 	agent, err := h.authService.ValidateAgentToken(r.Context(), agentToken)
 	if err != nil {
+		if errors.Is(err, service.ErrDatabaseUnavailable) {
+			return r.Context(), false, err
+		}
 		h.logger.DebugContext(r.Context(), "failed to validate agent token", logging.FieldError, err)
-		return r.Context(), false
+		return r.Context(), false, nil
 	}
 
 	h.logger.DebugContext(r.Context(), "authenticated agent", logging.FieldAgentID, agent.ID)
@@ -106,5 +152,5 @@ func (h *Authentication) tryAgentAuthentication(r *http.Request) (context.Contex
 	}
 
 	ctx := context.WithValue(r.Context(), cortexContext.KeyAgentInfo, info)
-	return ctx, true
+	return ctx, true, nil
 }
@@ -0,0 +1,96 @@
+package middleware_test
+
+import (
+	"context"
+	"cortex/handler"
+	"cortex/middleware"
+	"cortex/service"
+	"cortex/test/fakepg"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticationUnauthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		value     string
+		challenge string
+	}{
+		{name: "no credentials", challenge: `Bearer realm="cortex"`},
+		{name: "invalid user token", header: "Authorization", value: "Bearer invalid",
+			challenge: `Bearer realm="cortex", error="invalid_token"`},
+		{name: "invalid user token format", header: "Authorization", value: "Basic invalid",
+			challenge: `Bearer realm="cortex", error="invalid_token"`},
+	}
+
+	authn := middleware.NewAuthenticationMiddleware(agentAuthService{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/scans", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			rr := httptest.NewRecorder()
+			authn.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Fatal("unauthenticated request must not be passed on")
+			})).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			assert.Equal(t, tt.challenge, rr.Header().Get("WWW-Authenticate"))
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+			var body handler.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, 1, body.APIVersion)
+			assert.Equal(t, http.StatusUnauthorized, body.Error.Code)
+			assert.Equal(t, handler.ErrorCodeUnauthorized, body.Error.ErrorCode)
+			assert.Equal(t, "API error: unauthorized: missing or invalid token", body.Error.Message)
+		})
+	}
+}
+
+func TestAuthenticationDatabaseUnavailable(t *testing.T) {
+	raw, _ := fakepg.NewPool(t, 1)
+	authService := service.NewAuthService(nil, nil, service.NewPool(raw, service.PoolOptions{
+		AcquireTimeout: 10 * time.Millisecond,
+	}), service.AuthServiceOptions{})
+	authn := middleware.NewAuthenticationMiddleware(authService)
+
+	// saturate the pool
+	conn, err := raw.Acquire(context.Background())
+	require.NoError(t, err)
+	defer conn.Release()
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+	}{
+		{name: "user token", header: "Authorization", value: "Bearer a1b2c3d4.00112233445566778899aabbccddeeff"},
+		{name: "agent token", header: "X-Agent-Token", value: "a1b2c3d4.00112233445566778899aabbccddeeff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/scans", nil)
+			req.Header.Set(tt.header, tt.value)
+
+			rr := httptest.NewRecorder()
+			authn.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Fatal("unauthenticated request must not be passed on")
+			})).ServeHTTP(rr, req)
+
+			// the token is not rejected, the client is asked to retry
+			assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+			assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+			assert.Empty(t, rr.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressionMinSize is the size of response bodies below which compression saves too little to be worth it.
+const DefaultCompressionMinSize = 1024
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// uncompressedContentTypes are not compressed. Event streams are written in small flushed chunks.
+var uncompressedContentTypes = []string{"text/event-stream"}
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// Compression compresses response bodies with gzip or deflate if the client accepts it.
+type Compression struct {
+	minSize int
+}
+
+// NewCompressionMiddleware compresses responses with bodies of at least minSize bytes.
+func NewCompressionMiddleware(minSize int) *Compression {
+	return &Compression{minSize: minSize}
+}
+
+func (m *Compression) OnRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressingResponseWriter{ResponseWriter: w, encoding: encoding, minSize: m.minSize}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding returns the encoding of an Accept-Encoding header to compress with, empty if neither gzip nor
+// deflate is accepted. gzip is preferred over deflate of the same quality.
+func acceptedEncoding(header string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range []string{encodingGzip, encodingDeflate} {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressingResponseWriter holds back the start of a response until it knows whether the body is large enough to be
+// compressed.
+type compressingResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	statusCode int
+	buf        []byte
+	// decided is set once the header has been sent, compressor is set if the body is compressed
+	decided    bool
+	compressor io.WriteCloser
+}
+
+func (w *compressingResponseWriter) WriteHeader(statusCode int) {
+	if w.decided || w.statusCode != 0 {
+		return
+	}
+	// informational responses are sent right away and followed by the final response
+	if statusCode >= 100 && statusCode < 200 {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.statusCode = statusCode
+	if !w.compressible() {
+		w.start(false)
+	}
+}
+
+func (w *compressingResponseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.compressor != nil {
+			return w.compressor.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends the response written so far. A body that is not compressed yet is sent uncompressed.
+func (w *compressingResponseWriter) Flush() {
+	if !w.decided {
+		if w.statusCode == 0 {
+			w.statusCode = http.StatusOK
+		}
+		_ = w.start(false)
+	}
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close completes the response, bodies smaller than the minimum size are sent uncompressed.
+func (w *compressingResponseWriter) Close() {
+	if !w.decided {
+		if w.statusCode == 0 {
+			// nothing has been written, leave the response to the server
+			return
+		}
+		_ = w.start(false)
+	}
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+		if gz, ok := w.compressor.(*gzip.Writer); ok {
+			gzipWriters.Put(gz)
+		}
+		w.compressor = nil
+	}
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *compressingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response can be compressed based on its status and header.
+func (w *compressingResponseWriter) compressible() bool {
+	if w.statusCode < http.StatusOK || w.statusCode == http.StatusNoContent ||
+		w.statusCode == http.StatusNotModified {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	for _, uncompressed := range uncompressedContentTypes {
+		if contentType == uncompressed {
+			return false
+		}
+	}
+	return true
+}
+
+// start sends the header and the buffered body, compressed if compress is set and the response is compressible.
+func (w *compressingResponseWriter) start(compress bool) error {
+	w.decided = true
+	if compress && w.compressible() {
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", w.encoding)
+		switch w.encoding {
+		case encodingGzip:
+			gz := gzipWriters.Get().(*gzip.Writer)
+			gz.Reset(w.ResponseWriter)
+			w.compressor = gz
+		case encodingDeflate:
+			w.compressor = zlib.NewWriter(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.compressor != nil {
+		_, err = w.compressor.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
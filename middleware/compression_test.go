@@ -0,0 +1,110 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"cortex/middleware"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	large := `{"items":[` + strings.Repeat(`{"endpoint":"example.com"},`, 100) + `{}]}`
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, body)
+		})
+	}
+
+	send := func(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/assets", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		middleware.NewCompressionMiddleware(middleware.DefaultCompressionMinSize).OnRequest(h).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("compresses large responses with gzip", func(t *testing.T) {
+		rr := send(respond(large), "deflate, gzip;q=1.0, br;q=0.5")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		assert.Less(t, rr.Body.Len(), len(large))
+
+		reader, err := gzip.NewReader(rr.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, large, string(body))
+	})
+
+	t.Run("compresses with deflate if preferred", func(t *testing.T) {
+		rr := send(respond(large), "gzip;q=0.5, deflate")
+
+		assert.Equal(t, "deflate", rr.Header().Get("Content-Encoding"))
+		reader, err := zlib.NewReader(rr.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, large, string(body))
+	})
+
+	t.Run("leaves responses alone if the client does not accept compression", func(t *testing.T) {
+		for _, acceptEncoding := range []string{"", "identity", "br", "gzip;q=0"} {
+			rr := send(respond(large), acceptEncoding)
+
+			assert.Empty(t, rr.Header().Get("Content-Encoding"), acceptEncoding)
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			assert.Equal(t, large, rr.Body.String())
+		}
+	})
+
+	t.Run("leaves small responses alone", func(t *testing.T) {
+		rr := send(respond(`{"id":"1"}`), "gzip")
+
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, `{"id":"1"}`, rr.Body.String())
+	})
+
+	t.Run("leaves event streams alone", func(t *testing.T) {
+		rr := send(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, "event: scan\ndata: {}\n\n")
+			require.NoError(t, http.NewResponseController(w).Flush())
+			_, _ = io.WriteString(w, "data: "+large+"\n\n")
+		}), "gzip")
+
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.True(t, rr.Flushed)
+		assert.Equal(t, "event: scan\ndata: {}\n\ndata: "+large+"\n\n", rr.Body.String())
+	})
+
+	t.Run("flushes compressed responses", func(t *testing.T) {
+		rr := send(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, large)
+			require.NoError(t, http.NewResponseController(w).Flush())
+			_, _ = io.WriteString(w, large)
+		}), "gzip")
+
+		assert.True(t, rr.Flushed)
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		reader, err := gzip.NewReader(rr.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, large+large, string(body))
+	})
+}
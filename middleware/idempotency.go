@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"bytes"
+	cortexContext "cortex/context"
+	"cortex/handler"
+	"cortex/logging"
+	"crypto/sha256"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader carries the key identifying retries of a request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a repeated idempotency key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength limits the size of stored keys.
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers stored with a response. Others, e.g. the request id, belong to the
+// request they were sent for.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Idempotency replays the response of a request for requests repeating its Idempotency-Key, so clients can safely
+// retry requests creating resources. Keys are scoped to the route and the authenticated user or agent and are kept
+// for a TTL. Requests without the header are not affected.
+type Idempotency struct {
+	logger      *slog.Logger
+	ttl         time.Duration
+	mu          sync.Mutex
+	responses   map[string]*idempotentResponse
+	lastCleanup time.Time
+}
+
+// idempotentResponse is the response of the first request with a key. It is pending until the response is complete.
+type idempotentResponse struct {
+	bodyHash  [sha256.Size]byte
+	pending   bool
+	status    int
+	header    http.Header
+	body      []byte
+	createdAt time.Time
+}
+
+// NewIdempotencyMiddleware keeps responses for ttl. A ttl of zero or less disables it.
+func NewIdempotencyMiddleware(ttl time.Duration) *Idempotency {
+	return &Idempotency{
+		logger:    logging.GetLogger(logging.API),
+		ttl:       ttl,
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+func (m *Idempotency) OnRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+		if m.ttl <= 0 || idempotencyKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			handler.RespondError(w, r, http.StatusBadRequest,
+				handler.NewValidationError("idempotency key must not be longer than 255 characters"))
+			return
+		}
+
+		identity := idempotencyIdentity(r)
+		if identity == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			handler.RespondError(w, r, http.StatusBadRequest, handler.NewValidationError("failed to read request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := r.Method + " " + r.URL.Path + " " + identity + " " + idempotencyKey
+		bodyHash := sha256.Sum256(body)
+
+		stored, found := m.reserve(key, bodyHash)
+		if found {
+			switch {
+			case stored.bodyHash != bodyHash:
+				apiErr := handler.UnprocessableEntity("idempotency key was used for a different request")
+				handler.RespondError(w, r, apiErr.StatusCode, apiErr)
+			case stored.pending:
+				apiErr := handler.Conflict("a request with the idempotency key is in progress")
+				handler.RespondError(w, r, apiErr.StatusCode, apiErr)
+			default:
+				m.logger.DebugContext(r.Context(), "replaying response of idempotent request")
+				for name, values := range stored.header {
+					w.Header()[name] = values
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.status)
+				_, _ = w.Write(stored.body)
+			}
+			return
+		}
+
+		recorder := &recordingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		completed := false
+		defer func() {
+			m.complete(key, recorder, completed)
+		}()
+		next.ServeHTTP(recorder, r)
+		completed = true
+	})
+}
+
+// reserve returns the stored response of key. If there is none, a pending response is stored for the caller to
+// complete.
+func (m *Idempotency) reserve(key string, bodyHash [sha256.Size]byte) (idempotentResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastCleanup) >= m.ttl {
+		m.cleanup(now)
+	}
+
+	if stored, ok := m.responses[key]; ok && now.Sub(stored.createdAt) < m.ttl {
+		return *stored, true
+	}
+	m.responses[key] = &idempotentResponse{bodyHash: bodyHash, pending: true, createdAt: now}
+	return idempotentResponse{}, false
+}
+
+// complete stores the recorded response of key. Server errors and panics are not stored, so the request can be
+// retried with the same key.
+func (m *Idempotency) complete(key string, recorder *recordingResponseWriter, completed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.responses[key]
+	if !ok {
+		return
+	}
+	if !completed || recorder.statusCode >= http.StatusInternalServerError {
+		delete(m.responses, key)
+		return
+	}
+	stored.pending = false
+	stored.status = recorder.statusCode
+	stored.header = make(http.Header)
+	for _, name := range replayedHeaders {
+		if values := recorder.Header().Values(name); len(values) > 0 {
+			stored.header[name] = values
+		}
+	}
+	stored.body = recorder.body.Bytes()
+}
+
+// cleanup removes all expired responses.
+func (m *Idempotency) cleanup(now time.Time) {
+	for key, stored := range m.responses {
+		if !stored.pending && now.Sub(stored.createdAt) >= m.ttl {
+			delete(m.responses, key)
+		}
+	}
+	m.lastCleanup = now
+}
+
+// idempotencyIdentity returns the authenticated user or agent of a request, empty if it is not authenticated.
+func idempotencyIdentity(r *http.Request) string {
+	if user, err := cortexContext.UserInfo(r.Context()); err == nil {
+		return "user:" + user.UserID
+	}
+	if agent, err := cortexContext.AgentInfo(r.Context()); err == nil {
+		return "agent:" + agent.AgentID
+	}
+	return ""
+}
+
+// recordingResponseWriter keeps a copy of the response written.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer, e.g. for flushing.
+func (w *recordingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,131 @@
+package middleware_test
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"cortex/middleware"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	// newCreateHandler returns a handler creating a resource per request and responding with its number
+	newCreateHandler := func() (http.Handler, *atomic.Int32) {
+		var created atomic.Int32
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if string(body) == "fail" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			n := created.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Request-ID", fmt.Sprintf("request-%d", n))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, `{"id":%d}`, n)
+		}), &created
+	}
+
+	send := func(h http.Handler, userID string, key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/scans", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		ctx := context.WithValue(req.Context(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: userID})
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	t.Run("replays the response of a repeated key", func(t *testing.T) {
+		next, created := newCreateHandler()
+		h := middleware.NewIdempotencyMiddleware(time.Minute).OnRequest(next)
+
+		first := send(h, "user", "key", `{"scanConfigId":"config"}`)
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get(middleware.IdempotentReplayedHeader))
+
+		retry := send(h, "user", "key", `{"scanConfigId":"config"}`)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, `{"id":1}`, retry.Body.String())
+		assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+		assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+		// headers of the request are not replayed
+		assert.Empty(t, retry.Header().Get("X-Request-ID"))
+		assert.Equal(t, int32(1), created.Load())
+	})
+
+	t.Run("rejects a repeated key with a different body", func(t *testing.T) {
+		next, created := newCreateHandler()
+		h := middleware.NewIdempotencyMiddleware(time.Minute).OnRequest(next)
+
+		assert.Equal(t, http.StatusCreated, send(h, "user", "key", `{"scanConfigId":"config"}`).Code)
+		rr := send(h, "user", "key", `{"scanConfigId":"other"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Contains(t, rr.Body.String(), "unprocessable_entity")
+		assert.Equal(t, int32(1), created.Load())
+	})
+
+	t.Run("scopes keys to the user", func(t *testing.T) {
+		next, created := newCreateHandler()
+		h := middleware.NewIdempotencyMiddleware(time.Minute).OnRequest(next)
+
+		assert.Equal(t, `{"id":1}`, send(h, "user", "key", "{}").Body.String())
+		assert.Equal(t, `{"id":2}`, send(h, "other", "key", "{}").Body.String())
+		assert.Equal(t, int32(2), created.Load())
+	})
+
+	t.Run("does not replay without key, after the ttl or for server errors", func(t *testing.T) {
+		next, created := newCreateHandler()
+		h := middleware.NewIdempotencyMiddleware(50 * time.Millisecond).OnRequest(next)
+
+		send(h, "user", "", "{}")
+		send(h, "user", "", "{}")
+		assert.Equal(t, int32(2), created.Load())
+
+		send(h, "user", "key", "{}")
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, `{"id":4}`, send(h, "user", "key", "{}").Body.String())
+
+		assert.Equal(t, http.StatusInternalServerError, send(h, "user", "failing", "fail").Code)
+		assert.Equal(t, http.StatusInternalServerError, send(h, "user", "failing", "fail").Code)
+		assert.Empty(t, send(h, "user", "failing", "fail").Header().Get(middleware.IdempotentReplayedHeader))
+	})
+
+	t.Run("rejects a repeated key while the first request is in progress", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		h := middleware.NewIdempotencyMiddleware(time.Minute).OnRequest(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+		done := make(chan int)
+		go func() {
+			done <- send(h, "user", "key", "{}").Code
+		}()
+		<-started
+		assert.Equal(t, http.StatusConflict, send(h, "user", "key", "{}").Code)
+		close(release)
+		assert.Equal(t, http.StatusCreated, <-done)
+		assert.Equal(t, http.StatusCreated, send(h, "user", "key", "{}").Code)
+	})
+
+	t.Run("rejects keys that are too long", func(t *testing.T) {
+		next, created := newCreateHandler()
+		h := middleware.NewIdempotencyMiddleware(time.Minute).OnRequest(next)
+
+		assert.Equal(t, http.StatusBadRequest, send(h, "user", strings.Repeat("k", 256), "{}").Code)
+		assert.Equal(t, int32(0), created.Load())
+	})
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	cortexContext "cortex/context"
+	"cortex/handler"
+	"cortex/logging"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// IPAllowlist rejects requests of agents from client IPs outside the allowed networks with 403. Requests of users
+// pass. It must run after the authentication middleware.
+type IPAllowlist struct {
+	logger   *slog.Logger
+	networks []netip.Prefix
+	// TrustedProxies are the networks of proxies whose X-Forwarded-For entries are used to determine the client IP.
+	// X-Forwarded-For is ignored for requests from other addresses.
+	TrustedProxies []netip.Prefix
+}
+
+// NewIPAllowlist allows agent requests from the networks in CIDR notation, single IPs are allowed as well. An empty
+// list allows all IPs.
+func NewIPAllowlist(cidrs []string) (*IPAllowlist, error) {
+	networks, err := ParseNetworks(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return &IPAllowlist{
+		logger:   logging.GetLogger(logging.Auth),
+		networks: networks,
+	}, nil
+}
+
+// ParseNetworks parses networks in CIDR notation or single IPs.
+func ParseNetworks(cidrs []string) ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		network, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", cidr, err)
+			}
+			network = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, nil
+}
+
+func (m *IPAllowlist) OnRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(m.networks) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := cortexContext.AgentInfo(r.Context()); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client, ok := clientIP(r, m.TrustedProxies)
+		if !ok || !containsAddr(m.networks, client) {
+			m.logger.WarnContext(r.Context(), "denied agent request from address outside allowlist",
+				logging.FieldSourceIP, client.String())
+			apiErr := handler.Forbidden("address not allowed")
+			handler.RespondError(w, r, apiErr.StatusCode, apiErr)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address the request has been sent from. If it has been forwarded by trusted proxies, it is the
+// last X-Forwarded-For entry not added by one of them.
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	client, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	client = client.Unmap()
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for _, entry := range slices.Backward(forwarded) {
+		if !containsAddr(trustedProxies, client) {
+			break
+		}
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			break
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		client = addr.Unmap()
+	}
+	return client, true
+}
+
+func containsAddr(networks []netip.Prefix, addr netip.Addr) bool {
+	return slices.ContainsFunc(networks, func(network netip.Prefix) bool {
+		return network.Contains(addr)
+	})
+}
@@ -0,0 +1,82 @@
+package middleware_test
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"cortex/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPAllowlist(t *testing.T) {
+	allowlist, err := middleware.NewIPAllowlist([]string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32"})
+	require.NoError(t, err)
+	allowlist.TrustedProxies, err = middleware.ParseNetworks([]string{"172.16.0.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		agent      bool
+		status     int
+	}{
+		{name: "in range", remoteAddr: "10.1.2.3:4321", agent: true, status: http.StatusOK},
+		{name: "single ip", remoteAddr: "192.168.1.10:4321", agent: true, status: http.StatusOK},
+		{name: "out of range", remoteAddr: "192.168.1.11:4321", agent: true, status: http.StatusForbidden},
+		{name: "ipv6 in range", remoteAddr: "[2001:db8::1]:4321", agent: true, status: http.StatusOK},
+		{name: "ipv6 out of range", remoteAddr: "[2001:db9::1]:4321", agent: true, status: http.StatusForbidden},
+		{name: "ipv4 mapped ipv6", remoteAddr: "[::ffff:10.0.0.1]:4321", agent: true, status: http.StatusOK},
+		{name: "users are not restricted", remoteAddr: "192.168.1.11:4321", status: http.StatusOK},
+		{name: "forwarded by trusted proxy", remoteAddr: "172.16.0.1:4321", forwarded: "10.0.0.5",
+			agent: true, status: http.StatusOK},
+		{name: "forwarded by trusted proxy out of range", remoteAddr: "172.16.0.1:4321", forwarded: "10.0.0.5, 8.8.8.8",
+			agent: true, status: http.StatusForbidden},
+		{name: "forwarded by untrusted client", remoteAddr: "8.8.8.8:4321", forwarded: "10.0.0.5",
+			agent: true, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/agents/heartbeat", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.agent {
+				req = req.WithContext(context.WithValue(req.Context(), cortexContext.KeyAgentInfo,
+					cortexContext.AgentInfoData{AgentID: "agent-1"}))
+			}
+
+			rr := httptest.NewRecorder()
+			allowlist.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			})).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+}
+
+func TestIPAllowlistEmpty(t *testing.T) {
+	allowlist, err := middleware.NewIPAllowlist(nil)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/agents/heartbeat", nil)
+	req = req.WithContext(context.WithValue(req.Context(), cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{}))
+	rr := httptest.NewRecorder()
+	allowlist.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	})).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestNewIPAllowlistInvalid(t *testing.T) {
+	_, err := middleware.NewIPAllowlist([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	_, err = middleware.NewIPAllowlist([]string{"example.com"})
+	assert.Error(t, err)
+}
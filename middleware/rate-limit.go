@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"cortex/handler"
+	"cortex/logging"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers describing the rate limit, set on every response governed by a RateLimit. X-RateLimit-Reset is the number
+// of seconds until the next request leaves the window.
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimit limits the number of requests per source IP using a sliding window.
+type RateLimit struct {
+	logger      *slog.Logger
+	limit       int
+	window      time.Duration
+	mu          sync.Mutex
+	requests    map[string][]time.Time
+	lastCleanup time.Time
+	// TrustedProxies are the networks of proxies whose X-Forwarded-For entries are used to determine the source IP.
+	// X-Forwarded-For is ignored for requests from other addresses, so clients cannot choose their own key.
+	TrustedProxies []netip.Prefix
+}
+
+// NewRateLimitMiddleware allows limit requests per source IP within window. A limit of zero or less disables it.
+func NewRateLimitMiddleware(limit int, window time.Duration) *RateLimit {
+	return &RateLimit{
+		logger:   logging.GetLogger(logging.API),
+		limit:    limit,
+		window:   window,
+		requests: make(map[string][]time.Time),
+	}
+}
+
+func (m *RateLimit) OnRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := m.sourceIP(r)
+		remaining, reset, allowed := m.allow(key)
+		w.Header().Set(RateLimitLimitHeader, strconv.Itoa(m.limit))
+		w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+		w.Header().Set(RateLimitResetHeader, seconds(reset))
+		if !allowed {
+			m.logger.WarnContext(r.Context(), "rate limit exceeded", logging.FieldSourceIP, key)
+			w.Header().Set("Retry-After", seconds(reset))
+			apiErr := handler.TooManyRequests("retry later")
+			handler.RespondError(w, r, apiErr.StatusCode, apiErr)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow records a request for key and returns the number of requests remaining in the window and the time until the
+// oldest request leaves it. If the limit is exceeded the request is not recorded.
+func (m *RateLimit) allow(key string) (int, time.Duration, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastCleanup) >= m.window {
+		m.cleanup(now)
+	}
+
+	requests := m.inWindow(m.requests[key], now)
+	allowed := len(requests) < m.limit
+	if allowed {
+		requests = append(requests, now)
+	}
+	m.requests[key] = requests
+	return m.limit - len(requests), requests[0].Add(m.window).Sub(now), allowed
+}
+
+// inWindow drops all requests that are older than the window.
+func (m *RateLimit) inWindow(requests []time.Time, now time.Time) []time.Time {
+	start := now.Add(-m.window)
+	for len(requests) > 0 && !requests[0].After(start) {
+		requests = requests[1:]
+	}
+	return requests
+}
+
+// cleanup removes all keys without requests in the current window.
+func (m *RateLimit) cleanup(now time.Time) {
+	for key, requests := range m.requests {
+		if len(m.inWindow(requests, now)) == 0 {
+			delete(m.requests, key)
+		}
+	}
+	m.lastCleanup = now
+}
+
+// seconds formats d as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// sourceIP returns the client IP of a request. Requests whose address cannot be determined are keyed by their remote
+// address.
+func (m *RateLimit) sourceIP(r *http.Request) string {
+	client, ok := clientIP(r, m.TrustedProxies)
+	if !ok {
+		return r.RemoteAddr
+	}
+	return client.String()
+}
@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"cortex/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	send := func(h http.Handler, remoteAddr string, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("rejects requests once the limit is exhausted", func(t *testing.T) {
+		h := middleware.NewRateLimitMiddleware(3, time.Minute).OnRequest(okHandler)
+
+		for i := 0; i < 3; i++ {
+			// source port changes between requests of the same client
+			rr := send(h, "192.0.2.1:"+strconv.Itoa(40000+i), "")
+			assert.Equal(t, http.StatusOK, rr.Code)
+		}
+
+		rr := send(h, "192.0.2.1:40003", "")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+		assert.NoError(t, err)
+		assert.InDelta(t, 60, retryAfter, 1)
+
+		// other clients are not affected
+		rr = send(h, "192.0.2.2:40000", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("keys by X-Forwarded-For of trusted proxies", func(t *testing.T) {
+		limit := middleware.NewRateLimitMiddleware(1, time.Minute)
+		var err error
+		limit.TrustedProxies, err = middleware.ParseNetworks([]string{"10.0.0.0/24"})
+		require.NoError(t, err)
+		h := limit.OnRequest(okHandler)
+
+		assert.Equal(t, http.StatusOK, send(h, "10.0.0.1:1234", "198.51.100.1, 203.0.113.7").Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(h, "10.0.0.2:1234", "203.0.113.7").Code)
+		assert.Equal(t, http.StatusOK, send(h, "10.0.0.1:1234", "203.0.113.8").Code)
+	})
+
+	t.Run("ignores X-Forwarded-For of other clients", func(t *testing.T) {
+		h := middleware.NewRateLimitMiddleware(1, time.Minute).OnRequest(okHandler)
+
+		// a client changing the header on every attempt is still limited by its address
+		assert.Equal(t, http.StatusOK, send(h, "192.0.2.1:1234", "203.0.113.7").Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(h, "192.0.2.1:1234", "203.0.113.8").Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(h, "192.0.2.1:1234", "").Code)
+	})
+
+	t.Run("sets rate limit headers on every response", func(t *testing.T) {
+		h := middleware.NewRateLimitMiddleware(3, time.Minute).OnRequest(okHandler)
+
+		for _, remaining := range []string{"2", "1", "0", "0"} {
+			rr := send(h, "192.0.2.1:1234", "")
+			assert.Equal(t, "3", rr.Header().Get(middleware.RateLimitLimitHeader))
+			assert.Equal(t, remaining, rr.Header().Get(middleware.RateLimitRemainingHeader))
+			reset, err := strconv.Atoi(rr.Header().Get(middleware.RateLimitResetHeader))
+			require.NoError(t, err)
+			assert.InDelta(t, 60, reset, 1)
+		}
+	})
+
+	t.Run("allows requests again after the window", func(t *testing.T) {
+		h := middleware.NewRateLimitMiddleware(1, 50*time.Millisecond).OnRequest(okHandler)
+
+		assert.Equal(t, http.StatusOK, send(h, "192.0.2.1:1234", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(h, "192.0.2.1:1234", "").Code)
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, http.StatusOK, send(h, "192.0.2.1:1234", "").Code)
+	})
+}
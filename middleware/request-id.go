@@ -4,13 +4,21 @@ import (
 	"context"
 	cortexContext "cortex/context"
 	"net/http"
+	"regexp"
 
 	"github.com/google/uuid"
 )
 
+// incomingRequestIDPattern restricts request ids set by clients, e.g. UUIDs or ids of upstream gateways, to a bounded
+// set of characters that is safe to log and to send back.
+var incomingRequestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
 type RequestIDMiddleware struct {
 	RequestIDHeader    string
 	RequestIDGenerator func() string
+	// TrustIncoming uses the request id sent by the client if it is valid, e.g. to trace requests across gateways.
+	// Otherwise, a new id is generated for every request.
+	TrustIncoming bool
 }
 
 func NewRequestIDMiddleware(generatorFunc func() string) *RequestIDMiddleware {
@@ -28,12 +36,18 @@ func NewUUIDv4RequestIDMiddleWare() *RequestIDMiddleware {
 
 func (h *RequestIDMiddleware) OnRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := r.Header.Get(h.RequestIDHeader)
+		requestID := ""
+		if h.TrustIncoming {
+			if incoming := r.Header.Get(h.RequestIDHeader); incomingRequestIDPattern.MatchString(incoming) {
+				requestID = incoming
+			}
+		}
 
 		if requestID == "" {
 			requestID = h.RequestIDGenerator()
 		}
 
+		w.Header().Set(h.RequestIDHeader, requestID)
 		ctx := context.WithValue(r.Context(), cortexContext.KeyRequestID, requestID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
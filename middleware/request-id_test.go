@@ -1,17 +1,21 @@
 package middleware_test
 
 import (
+	"bytes"
 	"cortex/context"
+	"cortex/logging"
 	"cortex/middleware"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRequestID(t *testing.T) {
-	t.Run("uses existing request ID from header", func(t *testing.T) {
+	t.Run("uses trusted request ID from header", func(t *testing.T) {
 		existingID := "test-request-id"
 		var capturedID string
 
@@ -19,6 +23,7 @@ func TestRequestID(t *testing.T) {
 			t.Error("generator shouldn't be called when ID exists")
 			return ""
 		})
+		reqID.TrustIncoming = true
 
 		testHandler := reqID.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			capturedID = r.Context().Value(context.KeyRequestID).(string)
@@ -31,6 +36,51 @@ func TestRequestID(t *testing.T) {
 		testHandler.ServeHTTP(rr, req)
 
 		assert.Equal(t, existingID, capturedID)
+		assert.Equal(t, existingID, rr.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("regenerates request ID when header is not trusted", func(t *testing.T) {
+		var capturedID string
+
+		reqID := middleware.NewRequestIDMiddleware(func() string {
+			return "generated-id"
+		})
+
+		testHandler := reqID.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			capturedID = r.Context().Value(context.KeyRequestID).(string)
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "test-request-id")
+		rr := httptest.NewRecorder()
+
+		testHandler.ServeHTTP(rr, req)
+
+		assert.Equal(t, "generated-id", capturedID)
+		assert.Equal(t, "generated-id", rr.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("regenerates request ID when trusted header is invalid", func(t *testing.T) {
+		for _, incoming := range []string{"id with spaces", "id\r\nX-Injected: 1", strings.Repeat("a", 129)} {
+			var capturedID string
+
+			reqID := middleware.NewRequestIDMiddleware(func() string {
+				return "generated-id"
+			})
+			reqID.TrustIncoming = true
+
+			testHandler := reqID.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				capturedID = r.Context().Value(context.KeyRequestID).(string)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Request-ID", incoming)
+			rr := httptest.NewRecorder()
+
+			testHandler.ServeHTTP(rr, req)
+
+			assert.Equal(t, "generated-id", capturedID, incoming)
+		}
 	})
 
 	t.Run("generates new request ID when header is missing", func(t *testing.T) {
@@ -54,6 +104,7 @@ func TestRequestID(t *testing.T) {
 
 		// Assert
 		assert.Equal(t, generatedID, capturedID)
+		assert.Equal(t, generatedID, rr.Header().Get("X-Request-ID"))
 	})
 
 	t.Run("uses custom header name", func(t *testing.T) {
@@ -67,6 +118,7 @@ func TestRequestID(t *testing.T) {
 			return ""
 		})
 		reqID.RequestIDHeader = customHeader
+		reqID.TrustIncoming = true
 
 		testHandler := reqID.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			capturedID = r.Context().Value(context.KeyRequestID).(string)
@@ -81,6 +133,27 @@ func TestRequestID(t *testing.T) {
 
 		// Assert
 		assert.Equal(t, existingID, capturedID)
+		assert.Equal(t, existingID, rr.Header().Get(customHeader))
+	})
+
+	t.Run("logs chosen request ID", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(logging.ContextHandler{Handler: slog.NewJSONHandler(&buf, nil)})
+
+		reqID := middleware.NewRequestIDMiddleware(func() string {
+			return "generated-id"
+		})
+		reqID.TrustIncoming = true
+
+		testHandler := reqID.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.InfoContext(r.Context(), "handled")
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "gateway-1234")
+		testHandler.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Contains(t, buf.String(), `"requestId":"gateway-1234"`)
 	})
 }
 
@@ -1,12 +1,18 @@
 package middleware
 
 import (
+	"context"
+	cortexContext "cortex/context"
 	"cortex/logging"
 	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 )
 
+// unloggedPaths are polled by health checks and would flood the log.
+var unloggedPaths = []string{"/health", "/ready"}
+
 type RequestLoggerMiddleware struct {
 	logger *slog.Logger
 }
@@ -19,7 +25,8 @@ func NewRequestLoggerMiddleware() *RequestLoggerMiddleware {
 
 type trackingResponseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int
 }
 
 func (w *trackingResponseWriter) WriteHeader(statusCode int) {
@@ -27,9 +34,43 @@ func (w *trackingResponseWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
+func (w *trackingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytesWritten += n
+	return n, err
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer, e.g. for flushing.
+func (w *trackingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// loggedRequestKey is the context key of the loggedRequest of a request.
+type loggedRequestKey struct{}
+
+// loggedRequest carries the context of a request after authentication back to the request logger, which only sees
+// the context the request arrived with.
+type loggedRequest struct {
+	ctx context.Context
+}
+
+// recordAuthenticated makes the identity in ctx available to the request logger.
+func recordAuthenticated(ctx context.Context) {
+	if logged, ok := ctx.Value(loggedRequestKey{}).(*loggedRequest); ok {
+		logged.ctx = ctx
+	}
+}
+
 func (h *RequestLoggerMiddleware) OnRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(unloggedPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		tracker := trackingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		logged := &loggedRequest{ctx: r.Context()}
+		r = r.WithContext(context.WithValue(r.Context(), loggedRequestKey{}, logged))
 
 		startTime := time.Now()
 		defer func() {
@@ -38,13 +79,22 @@ func (h *RequestLoggerMiddleware) OnRequest(next http.Handler) http.Handler {
 				src = r.Header.Get("X-Forwarded-For")
 			}
 
-			h.logger.InfoContext(r.Context(), "",
+			attrs := []any{
 				"src", src,
 				"status", tracker.statusCode,
 				"method", r.Method,
 				"path", r.URL.Path,
-				"time", time.Since(startTime),
-			)
+				"duration", time.Since(startTime),
+				"bytes", tracker.bytesWritten,
+				logging.FieldRequestID, cortexContext.RequestID(r.Context()),
+			}
+			if user, err := cortexContext.UserInfo(logged.ctx); err == nil {
+				attrs = append(attrs, logging.FieldUserID, user.UserID)
+			}
+			if agent, err := cortexContext.AgentInfo(logged.ctx); err == nil {
+				attrs = append(attrs, logging.FieldAgentID, agent.AgentID)
+			}
+			h.logger.InfoContext(r.Context(), "", attrs...)
 		}()
 		next.ServeHTTP(&tracker, r)
 	})
@@ -2,13 +2,20 @@ package middleware_test
 
 import (
 	"bytes"
+	"context"
+	cortexContext "cortex/context"
 	"cortex/middleware"
+	"cortex/repository"
+	"cortex/service"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRequestLogger(t *testing.T) {
@@ -50,3 +57,71 @@ func TestRequestLoggerXForwardedFor(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.Contains(t, logBuffer.String(), "\"src\":\"192.168.1.1\"")
 }
+
+// agentAuthService authenticates every agent token as agent. Methods not needed by the tests panic.
+type agentAuthService struct {
+	service.AuthService
+	agent repository.Agent
+}
+
+func (s agentAuthService) ValidateToken(context.Context, string) (*repository.User, string, error) {
+	return nil, "", errors.New("no user tokens")
+}
+
+func (s agentAuthService) ValidateAgentToken(context.Context, string) (*repository.Agent, error) {
+	return &s.agent, nil
+}
+
+func TestRequestLoggerFields(t *testing.T) {
+	var logBuffer bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	reqLogger := middleware.NewRequestLoggerMiddleware()
+	authn := middleware.NewAuthenticationMiddleware(agentAuthService{agent: repository.Agent{ID: "agent-1"}})
+
+	req := httptest.NewRequest(http.MethodPost, "/scans", nil)
+	req = req.WithContext(context.WithValue(req.Context(), cortexContext.KeyRequestID, "req-1"))
+	req.Header.Set("X-Agent-Token", "a1b2c3d4.00112233445566778899aabbccddeeff")
+
+	rr := httptest.NewRecorder()
+	reqLogger.OnRequest(authn.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("accepted"))
+	}))).ServeHTTP(rr, req)
+
+	var entry struct {
+		Request struct {
+			Status    int    `json:"status"`
+			Method    string `json:"method"`
+			Path      string `json:"path"`
+			Duration  int64  `json:"duration"`
+			Bytes     int    `json:"bytes"`
+			RequestID string `json:"requestId"`
+			AgentID   string `json:"agentId"`
+			UserID    string `json:"userId"`
+		} `json:"request"`
+	}
+	lines := bytes.Split(bytes.TrimSpace(logBuffer.Bytes()), []byte("\n"))
+	require.NoError(t, json.Unmarshal(lines[len(lines)-1], &entry))
+	assert.Equal(t, http.StatusAccepted, entry.Request.Status)
+	assert.Equal(t, http.MethodPost, entry.Request.Method)
+	assert.Equal(t, "/scans", entry.Request.Path)
+	assert.Positive(t, entry.Request.Duration)
+	assert.Equal(t, len("accepted"), entry.Request.Bytes)
+	assert.Equal(t, "req-1", entry.Request.RequestID)
+	assert.Equal(t, "agent-1", entry.Request.AgentID)
+	assert.Empty(t, entry.Request.UserID)
+}
+
+func TestRequestLoggerSkipsHealthChecks(t *testing.T) {
+	var logBuffer bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logBuffer, nil)))
+	reqLogger := middleware.NewRequestLoggerMiddleware()
+
+	for _, path := range []string{"/health", "/ready"} {
+		rr := httptest.NewRecorder()
+		reqLogger.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	assert.Empty(t, logBuffer.String())
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultRequestTimeout is the time requests may take unless configured otherwise.
+const DefaultRequestTimeout = 30 * time.Second
+
+// RequestTimeout cancels the context of a request once it takes longer than the timeout, which aborts its database
+// queries. Handlers respond with 503 to requests canceled this way.
+type RequestTimeout struct {
+	timeout time.Duration
+	// exempt are patterns of paths of long-running requests, matched with path.Match, optionally followed by query
+	// parameters the request must have, e.g. /assets?format=csv.
+	exempt []string
+}
+
+// NewRequestTimeoutMiddleware limits requests to timeout, except for those with a path matching one of the exempt
+// patterns, e.g. event streams. A timeout of zero or less disables it.
+func NewRequestTimeoutMiddleware(timeout time.Duration, exempt ...string) *RequestTimeout {
+	return &RequestTimeout{
+		timeout: timeout,
+		exempt:  exempt,
+	}
+}
+
+func (m *RequestTimeout) OnRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.timeout <= 0 || m.isExempt(r.URL) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), m.timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (m *RequestTimeout) isExempt(requestURL *url.URL) bool {
+	for _, pattern := range m.exempt {
+		pathPattern, queryPattern, _ := strings.Cut(pattern, "?")
+		if matched, _ := path.Match(pathPattern, requestURL.Path); matched && hasQuery(requestURL, queryPattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasQuery reports whether the URL has every parameter of the query with the same value.
+func hasQuery(requestURL *url.URL, query string) bool {
+	if query == "" {
+		return true
+	}
+	expected, err := url.ParseQuery(query)
+	if err != nil {
+		return false
+	}
+	actual := requestURL.Query()
+	for key := range expected {
+		if actual.Get(key) != expected.Get(key) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package middleware_test
+
+import (
+	"cortex/handler"
+	"cortex/middleware"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	// slowHandler waits for a query taking a second, like a handler passing the request context to the database
+	slowHandler := handler.Make(func(w http.ResponseWriter, r *http.Request) error {
+		select {
+		case <-time.After(time.Second):
+			return handler.RespondOne(w, r, "done")
+		case <-r.Context().Done():
+			return handler.WrapError(r.Context().Err())
+		}
+	})
+	timeout := middleware.NewRequestTimeoutMiddleware(20*time.Millisecond, "/scans/*/events", "/assets?format=csv")
+
+	t.Run("cancels requests at the deadline", func(t *testing.T) {
+		start := time.Now()
+		rr := httptest.NewRecorder()
+		timeout.OnRequest(slowHandler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/assets", nil))
+
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		var response handler.ErrorResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, handler.ErrorCodeTimeout, response.Error.ErrorCode)
+	})
+
+	t.Run("does not limit exempt requests", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		timeout.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline := r.Context().Deadline()
+			assert.False(t, hasDeadline)
+		})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/scans/0b6a1f0e-8d1e-4a43-9a1e-8f2b9c3d4e5f/events", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("does not limit exempt requests with query", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		timeout.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline := r.Context().Deadline()
+			assert.False(t, hasDeadline)
+		})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/assets?tag=env:prod&format=csv", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("limits requests without the exempt query", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		timeout.OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline := r.Context().Deadline()
+			assert.True(t, hasDeadline)
+		})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/assets?format=json", nil))
+	})
+
+	t.Run("does not limit requests if disabled", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		middleware.NewRequestTimeoutMiddleware(0).OnRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline := r.Context().Deadline()
+			assert.False(t, hasDeadline)
+		})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/assets", nil))
+	})
+}
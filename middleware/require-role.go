@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	cortexContext "cortex/context"
+	"cortex/handler"
+	"cortex/logging"
+	"fmt"
+	"net/http"
+)
+
+// RequireRole only passes requests of users with the given role. Requests of agents and users with other roles are
+// rejected with 403. It must run after the authentication middleware.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	logger := logging.GetLogger(logging.Auth)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userInfo, err := cortexContext.UserInfo(r.Context())
+			if err != nil || userInfo.Role != role {
+				logger.InfoContext(r.Context(), fmt.Sprintf("denied request to %s, role %s required", r.URL.Path, role))
+				apiErr := handler.Forbidden(fmt.Sprintf("role %s required", role))
+				handler.RespondError(w, r, apiErr.StatusCode, apiErr)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		userInfo any
+		status   int
+	}{
+		{name: "admin", userInfo: cortexContext.UserInfoData{UserID: "u1", Role: "admin"}, status: http.StatusOK},
+		{name: "user", userInfo: cortexContext.UserInfoData{UserID: "u2", Role: "user"}, status: http.StatusForbidden},
+		{name: "agent", userInfo: nil, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.userInfo != nil {
+				req = req.WithContext(context.WithValue(req.Context(), cortexContext.KeyUserInfo, tt.userInfo))
+			}
+			rr := httptest.NewRecorder()
+
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})
+			RequireRole("admin")(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.status == http.StatusOK, called)
+		})
+	}
+}
@@ -6,47 +6,152 @@ import (
 	"encoding/json"
 	"errors"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Agent struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	TokenHash string    `json:"-"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// TokenID identifies the token of the agent, it changes when the token is rotated.
+	TokenID    string           `json:"tokenId"`
+	TokenHash  string           `json:"-"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	LastSeenAt pgtype.Timestamp `json:"lastSeenAt"`
+	// Online is derived from LastSeenAt by the service layer and not persisted.
+	Online bool `json:"online"`
+	// Scanner is the state of the scan runner last reported by the agent.
+	Scanner ScannerStatus `json:"scanner"`
+}
+
+// ScannerCapability is the kind of port scan the scan runner of an agent can perform.
+type ScannerCapability string
+
+const (
+	// ScannerCapabilityUnknown is the capability of agents that have not reported one.
+	ScannerCapabilityUnknown ScannerCapability = ""
+	// ScannerCapabilitySYN runners have the privileges to send raw packets and perform SYN scans.
+	ScannerCapabilitySYN ScannerCapability = "syn"
+	// ScannerCapabilityConnect runners lack raw socket privileges and fall back to TCP connect scans.
+	ScannerCapabilityConnect ScannerCapability = "connect"
+)
+
+// ScannerCapabilities lists the capabilities agents can report.
+var ScannerCapabilities = []ScannerCapability{ScannerCapabilitySYN, ScannerCapabilityConnect}
+
+// ScannerStatus is the result of the self-check an agent runs on its scan runner.
+type ScannerStatus struct {
+	Capability ScannerCapability `json:"capability"`
+	// Error is the reason the runner failed to initialize, empty if it is functional.
+	Error string `json:"error"`
 }
 
 func (a Agent) MarshalJSON() ([]byte, error) {
+	lastSeenAt := int64(0)
+	if a.LastSeenAt.Valid {
+		lastSeenAt = a.LastSeenAt.Time.Unix()
+	}
+
 	return json.Marshal(struct {
-		ID        string `json:"id"`
-		Name      string `json:"name"`
-		CreatedAt int64  `json:"createdAt"`
+		ID         string        `json:"id"`
+		Name       string        `json:"name"`
+		TokenID    string        `json:"tokenId"`
+		CreatedAt  int64         `json:"createdAt"`
+		LastSeenAt int64         `json:"lastSeenAt"`
+		Online     bool          `json:"online"`
+		Scanner    ScannerStatus `json:"scanner"`
 	}{
-		ID:        a.ID,
-		Name:      a.Name,
-		CreatedAt: a.CreatedAt.Unix(),
+		ID:         a.ID,
+		Name:       a.Name,
+		TokenID:    a.TokenID,
+		CreatedAt:  a.CreatedAt.Unix(),
+		LastSeenAt: lastSeenAt,
+		Online:     a.Online,
+		Scanner:    a.Scanner,
 	})
 }
 
+// AgentFilter restricts the agents returned by ListAgents. Zero values do not restrict the result.
+type AgentFilter struct {
+	// Search matches agents whose name contains the value, ignoring case.
+	Search string
+	// Active matches agents that have (true) or have not (false) been seen since ActiveSince.
+	Active      *bool
+	ActiveSince time.Time
+	Limit       int
+	Offset      int
+}
+
 type AgentRepository interface {
-	ListAgents(ctx context.Context, tx pgx.Tx) ([]Agent, error)
+	ListAgents(ctx context.Context, tx pgx.Tx, filter AgentFilter) ([]Agent, error)
 	GetAgent(ctx context.Context, tx pgx.Tx, id string) (*Agent, error)
+	// GetAgentByTokenID returns the agent owning the token with the ID.
+	GetAgentByTokenID(ctx context.Context, tx pgx.Tx, tokenID string) (*Agent, error)
+	// CountAgents returns the number of agents. It locks until the end of tx, so concurrent callers creating agents
+	// based on the count wait for each other.
+	CountAgents(ctx context.Context, tx pgx.Tx) (int, error)
 	CreateAgent(ctx context.Context, tx pgx.Tx, agent Agent) error
 	UpdateAgent(ctx context.Context, tx pgx.Tx, agent Agent) error
+	// UpdateAgentToken replaces the token of an agent.
+	UpdateAgentToken(ctx context.Context, tx pgx.Tx, id string, tokenID string, tokenHash string) error
 	DeleteAgent(ctx context.Context, tx pgx.Tx, id string) error
+	// TouchAgent sets the last seen timestamp of an agent to the current time and returns it.
+	TouchAgent(ctx context.Context, tx pgx.Tx, id string) (time.Time, error)
+	// UpdateAgentScanner stores the scanner status reported by an agent.
+	UpdateAgentScanner(ctx context.Context, tx pgx.Tx, id string, status ScannerStatus) error
+	// CountScanners counts the agents seen since onlineSince by the scanner status they reported.
+	CountScanners(ctx context.Context, tx pgx.Tx, onlineSince time.Time) (ScannerCounts, error)
+}
+
+// ScannerCounts counts agents by their scanner status. Agents whose runner failed are counted as Failing only,
+// whatever capability they reported.
+type ScannerCounts struct {
+	Agents     int
+	SYN        int
+	Connect    int
+	Failing    int
+	Unreported int
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns so user input is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// agentColumns are the columns read by readAgent, in the order they are read.
+const agentColumns = "id, token_id, name, auth_token_hash, created_at, last_seen_at, scanner_capability, scanner_error"
+
+func readAgent(row pgx.Row) (Agent, error) {
+	var agent Agent
+	err := row.Scan(&agent.ID, &agent.TokenID, &agent.Name, &agent.TokenHash, &agent.CreatedAt, &agent.LastSeenAt,
+		&agent.Scanner.Capability, &agent.Scanner.Error)
+	return agent, err
 }
 
 type PostgresAgentRepository struct {
 	logger *slog.Logger
 }
 
-func (r PostgresAgentRepository) ListAgents(ctx context.Context, tx pgx.Tx) ([]Agent, error) {
+func (r PostgresAgentRepository) ListAgents(ctx context.Context, tx pgx.Tx, filter AgentFilter) ([]Agent, error) {
+	args := pgx.NamedArgs{
+		"search":       likeEscaper.Replace(filter.Search),
+		"active":       filter.Active,
+		"active_since": filter.ActiveSince,
+		"limit":        filter.Limit,
+		"offset":       filter.Offset,
+	}
+
 	rows, err := tx.Query(ctx, `
-		SELECT * 
-		FROM agents`)
+		SELECT `+agentColumns+` 
+		FROM agents 
+		WHERE (@search::text = '' OR name ILIKE '%' || @search::text || '%') 
+		  AND (@active::boolean IS NULL 
+		       OR (last_seen_at IS NOT NULL AND last_seen_at >= @active_since) = @active::boolean) 
+		`+orderBy(ResourceAgents)+` 
+		LIMIT NULLIF(@limit::integer, 0) 
+		OFFSET @offset::integer`, args)
 
 	if err != nil {
 		// return empty list if no agents are found
@@ -60,24 +165,26 @@ func (r PostgresAgentRepository) ListAgents(ctx context.Context, tx pgx.Tx) ([]A
 	var agents []Agent
 	for rows.Next() {
 		var agent Agent
-		err = rows.Scan(&agent.ID, &agent.Name, &agent.TokenHash, &agent.CreatedAt)
+		agent, err = readAgent(rows)
 		if err != nil {
 			return nil, err
 		}
 		agents = append(agents, agent)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return agents, nil
 }
 
 func (r PostgresAgentRepository) GetAgent(ctx context.Context, tx pgx.Tx, id string) (*Agent, error) {
 	row := tx.QueryRow(ctx, `
-		SELECT * 
+		SELECT `+agentColumns+` 
 		FROM agents 
 		WHERE id = $1`, id)
 
-	var agent Agent
-	err := row.Scan(&agent.ID, &agent.Name, &agent.TokenHash, &agent.CreatedAt)
+	agent, err := readAgent(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -87,17 +194,47 @@ func (r PostgresAgentRepository) GetAgent(ctx context.Context, tx pgx.Tx, id str
 	return &agent, nil
 }
 
+func (r PostgresAgentRepository) GetAgentByTokenID(ctx context.Context, tx pgx.Tx, tokenID string) (*Agent, error) {
+	row := tx.QueryRow(ctx, `
+		SELECT `+agentColumns+` 
+		FROM agents 
+		WHERE token_id = $1`, tokenID)
+
+	agent, err := readAgent(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// agentLimitLockID identifies the advisory lock serializing agent creation against the agent limit.
+const agentLimitLockID = 7261483924
+
+func (r PostgresAgentRepository) CountAgents(ctx context.Context, tx pgx.Tx) (int, error) {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", agentLimitLockID); err != nil {
+		return 0, err
+	}
+
+	var count int
+	err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM agents`).Scan(&count)
+	return count, err
+}
+
 func (r PostgresAgentRepository) CreateAgent(ctx context.Context, tx pgx.Tx, agent Agent) error {
 	args := pgx.NamedArgs{
 		"id":              agent.ID,
+		"token_id":        agent.TokenID,
 		"name":            agent.Name,
 		"auth_token_hash": agent.TokenHash,
 		"created_at":      agent.CreatedAt,
 	}
 
 	_, err := tx.Exec(ctx, `
-		INSERT INTO agents (id, name, auth_token_hash, created_at) 
-		VALUES(@id, @name, @auth_token_hash, @created_at)`, args)
+		INSERT INTO agents (id, token_id, name, auth_token_hash, created_at) 
+		VALUES(@id, @token_id, @name, @auth_token_hash, @created_at)`, args)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -120,10 +257,10 @@ func (r PostgresAgentRepository) UpdateAgent(ctx context.Context, tx pgx.Tx, age
 	row := tx.QueryRow(ctx, `
 		UPDATE agents 
 		SET name = @name
-		WHERE id = @id`, args)
+		WHERE id = @id 
+		RETURNING `+agentColumns, args)
 
-	var updatedAgent Agent
-	err := row.Scan(&updatedAgent.ID, &updatedAgent.Name, &updatedAgent.TokenHash, &updatedAgent.CreatedAt)
+	_, err := readAgent(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -138,6 +275,29 @@ func (r PostgresAgentRepository) UpdateAgent(ctx context.Context, tx pgx.Tx, age
 	return nil
 }
 
+func (r PostgresAgentRepository) UpdateAgentToken(ctx context.Context, tx pgx.Tx, id string, tokenID string, tokenHash string) error {
+	args := pgx.NamedArgs{
+		"id":              id,
+		"token_id":        tokenID,
+		"auth_token_hash": tokenHash,
+	}
+
+	row := tx.QueryRow(ctx, `
+		UPDATE agents 
+		SET token_id = @token_id, auth_token_hash = @auth_token_hash
+		WHERE id = @id 
+		RETURNING `+agentColumns, args)
+
+	_, err := readAgent(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
 func (r PostgresAgentRepository) DeleteAgent(ctx context.Context, tx pgx.Tx, id string) error {
 	args := pgx.NamedArgs{
 		"id": id,
@@ -146,10 +306,9 @@ func (r PostgresAgentRepository) DeleteAgent(ctx context.Context, tx pgx.Tx, id
 	row := tx.QueryRow(ctx, `
 		DELETE FROM agents 
 		WHERE id = @id 
-		RETURNING id, name, auth_token_hash, created_at`, args)
+		RETURNING `+agentColumns, args)
 
-	var agent Agent
-	err := row.Scan(&agent.ID, &agent.Name, &agent.TokenHash, &agent.CreatedAt)
+	_, err := readAgent(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -159,8 +318,70 @@ func (r PostgresAgentRepository) DeleteAgent(ctx context.Context, tx pgx.Tx, id
 	return nil
 }
 
+func (r PostgresAgentRepository) TouchAgent(ctx context.Context, tx pgx.Tx, id string) (time.Time, error) {
+	args := pgx.NamedArgs{
+		"id": id,
+	}
+
+	row := tx.QueryRow(ctx, `
+		UPDATE agents 
+		SET last_seen_at = now() 
+		WHERE id = @id 
+		RETURNING last_seen_at`, args)
+
+	var lastSeenAt time.Time
+	err := row.Scan(&lastSeenAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, ErrNotFound
+		}
+		return time.Time{}, err
+	}
+	return lastSeenAt, nil
+}
+
+func (r PostgresAgentRepository) UpdateAgentScanner(ctx context.Context, tx pgx.Tx, id string, status ScannerStatus) error {
+	args := pgx.NamedArgs{
+		"id":                 id,
+		"scanner_capability": status.Capability,
+		"scanner_error":      status.Error,
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE agents 
+		SET scanner_capability = @scanner_capability, scanner_error = @scanner_error 
+		WHERE id = @id`, args)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func NewPostgresAgentRepository() *PostgresAgentRepository {
 	return &PostgresAgentRepository{
 		logger: logging.GetLogger(logging.DataAccess),
 	}
 }
+
+func (r PostgresAgentRepository) CountScanners(ctx context.Context, tx pgx.Tx, onlineSince time.Time) (ScannerCounts, error) {
+	args := pgx.NamedArgs{
+		"online_since": onlineSince,
+		"syn":          ScannerCapabilitySYN,
+		"connect":      ScannerCapabilityConnect,
+	}
+
+	var counts ScannerCounts
+	err := tx.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE scanner_error = '' AND scanner_capability = @syn),
+		       COUNT(*) FILTER (WHERE scanner_error = '' AND scanner_capability = @connect),
+		       COUNT(*) FILTER (WHERE scanner_error <> ''),
+		       COUNT(*) FILTER (WHERE scanner_error = '' AND scanner_capability NOT IN (@syn, @connect))
+		FROM agents
+		WHERE last_seen_at >= @online_since`, args).
+		Scan(&counts.Agents, &counts.SYN, &counts.Connect, &counts.Failing, &counts.Unreported)
+	return counts, err
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"cortex/logging"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// AuditAction is the kind of change recorded by an audit entry.
+type AuditAction string
+
+const (
+	AuditActionCreate AuditAction = "create"
+	AuditActionUpdate AuditAction = "update"
+	AuditActionDelete AuditAction = "delete"
+	// AuditActionRestore undoes the deletion of a resource that is kept after deletion.
+	AuditActionRestore AuditAction = "restore"
+)
+
+// AuditTargetType is the type of resource changed by an audited action.
+type AuditTargetType string
+
+const (
+	AuditTargetScanConfig AuditTargetType = "scan-config"
+	AuditTargetAsset      AuditTargetType = "asset"
+	AuditTargetAgent      AuditTargetType = "agent"
+	AuditTargetUser       AuditTargetType = "user"
+	AuditTargetToken      AuditTargetType = "token"
+)
+
+// AuditEntry records who changed which resource and when.
+type AuditEntry struct {
+	ID string `json:"id"`
+	// ActorID is the user that performed the action, invalid for actions not performed by a user.
+	ActorID    pgtype.UUID     `json:"actorId"`
+	Action     AuditAction     `json:"action"`
+	TargetType AuditTargetType `json:"targetType"`
+	// TargetID is the ID of the changed resource, empty if the change is not about a single resource.
+	TargetID  string    `json:"targetId"`
+	RequestID string    `json:"requestId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (e AuditEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID         string          `json:"id"`
+		ActorID    *string         `json:"actorId,omitempty"`
+		Action     AuditAction     `json:"action"`
+		TargetType AuditTargetType `json:"targetType"`
+		TargetID   string          `json:"targetId,omitempty"`
+		RequestID  string          `json:"requestId,omitempty"`
+		CreatedAt  int64           `json:"createdAt"`
+	}{
+		ID:         e.ID,
+		ActorID:    uuidString(e.ActorID),
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		RequestID:  e.RequestID,
+		CreatedAt:  e.CreatedAt.Unix(),
+	})
+}
+
+// uuidString returns the string form of a UUID, nil if it is not valid.
+func uuidString(id pgtype.UUID) *string {
+	if !id.Valid {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
+
+// AuditFilter restricts the entries returned by ListAuditEntries. Zero values do not restrict the result.
+type AuditFilter struct {
+	ActorID    string
+	Action     AuditAction
+	TargetType AuditTargetType
+	TargetID   string
+	// From and To bound the creation time of the entries, both inclusive.
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+type AuditRepository interface {
+	// ListAuditEntries returns the entries matching filter, the most recent first.
+	ListAuditEntries(ctx context.Context, tx pgx.Tx, filter AuditFilter) ([]AuditEntry, error)
+	CreateAuditEntry(ctx context.Context, tx pgx.Tx, entry AuditEntry) error
+}
+
+// auditEntryColumns are the columns read by readAuditEntry, in the order they are read.
+const auditEntryColumns = "id, actor_id, action, target_type, target_id, request_id, created_at"
+
+func readAuditEntry(row pgx.Row) (AuditEntry, error) {
+	var entry AuditEntry
+	err := row.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID, &entry.RequestID,
+		&entry.CreatedAt)
+	return entry, err
+}
+
+type PostgresAuditRepository struct {
+	logger *slog.Logger
+}
+
+func (r PostgresAuditRepository) ListAuditEntries(ctx context.Context, tx pgx.Tx, filter AuditFilter) ([]AuditEntry, error) {
+	args := pgx.NamedArgs{
+		"actor_id":    filter.ActorID,
+		"action":      string(filter.Action),
+		"target_type": string(filter.TargetType),
+		"target_id":   filter.TargetID,
+		"from":        nullTime(filter.From),
+		"to":          nullTime(filter.To),
+		"limit":       filter.Limit,
+		"offset":      filter.Offset,
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+auditEntryColumns+`
+		FROM audit_log
+		WHERE (@actor_id::text = '' OR actor_id = NULLIF(@actor_id::text, '')::uuid)
+		  AND (@action::text = '' OR action = @action::text)
+		  AND (@target_type::text = '' OR target_type = @target_type::text)
+		  AND (@target_id::text = '' OR target_id = @target_id::text)
+		  AND (@from::timestamp IS NULL OR created_at >= @from::timestamp)
+		  AND (@to::timestamp IS NULL OR created_at <= @to::timestamp)
+		`+orderBy(ResourceAudit)+`
+		LIMIT NULLIF(@limit::integer, 0)
+		OFFSET @offset::integer`, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		entry, err = readAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r PostgresAuditRepository) CreateAuditEntry(ctx context.Context, tx pgx.Tx, entry AuditEntry) error {
+	args := pgx.NamedArgs{
+		"id":          entry.ID,
+		"actor_id":    entry.ActorID,
+		"action":      entry.Action,
+		"target_type": entry.TargetType,
+		"target_id":   entry.TargetID,
+		"request_id":  entry.RequestID,
+		"created_at":  entry.CreatedAt,
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO audit_log (id, actor_id, action, target_type, target_id, request_id, created_at)
+		VALUES(@id, @actor_id, @action, @target_type, @target_id, @request_id, @created_at)`, args)
+	return err
+}
+
+// nullTime returns nil for the zero time, so it can be passed as a NULL query argument.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func NewPostgresAuditRepository() *PostgresAuditRepository {
+	return &PostgresAuditRepository{
+		logger: logging.GetLogger(logging.DataAccess),
+	}
+}
@@ -12,6 +12,15 @@ type UserProvider string
 
 const (
 	UserProviderLocal UserProvider = "local"
+	// UserProviderOIDC users are authenticated by an OpenID Connect provider and have no local password.
+	UserProviderOIDC UserProvider = "oidc"
+)
+
+type UserRole string
+
+const (
+	UserRoleAdmin UserRole = "admin"
+	UserRoleUser  UserRole = "user"
 )
 
 type User struct {
@@ -22,6 +31,9 @@ type User struct {
 	Email       string       `json:"email"`
 	DisplayName string       `json:"displayName"`
 	CreatedAt   time.Time    `json:"createdAt"`
+	Role        UserRole     `json:"role"`
+	// ExternalID is the subject of the user at the external identity provider, empty for local users.
+	ExternalID string `json:"externalId"`
 }
 
 func (u User) MarshalJSON() ([]byte, error) {
@@ -32,6 +44,7 @@ func (u User) MarshalJSON() ([]byte, error) {
 		Email       string       `json:"email"`
 		DisplayName string       `json:"displayName"`
 		CreatedAt   int64        `json:"createdAt"`
+		Role        UserRole     `json:"role"`
 	}{
 		ID:          u.ID,
 		Provider:    u.Provider,
@@ -39,6 +52,7 @@ func (u User) MarshalJSON() ([]byte, error) {
 		Email:       u.Email,
 		DisplayName: u.DisplayName,
 		CreatedAt:   u.CreatedAt.Unix(),
+		Role:        u.Role,
 	})
 }
 
@@ -63,6 +77,7 @@ func (s AuthToken) MarshalJSON() ([]byte, error) {
 		CreatedAt int64  `json:"createdAt"`
 		ExpiresAt int64  `json:"expiresAt"`
 	}{
+		ID:        s.ID,
 		UserID:    s.UserID,
 		UserAgent: s.UserAgent,
 		SourceIP:  s.SourceIP,
@@ -76,12 +91,24 @@ type UserRepository interface {
 	ListUsers(ctx context.Context, tx pgx.Tx) ([]User, error)
 	GetUser(ctx context.Context, tx pgx.Tx, id string) (*User, error)
 	GetUserByUsername(ctx context.Context, tx pgx.Tx, username string) (*User, error)
+	// GetUserByExternalID returns the user with the subject externalID at an external identity provider.
+	GetUserByExternalID(ctx context.Context, tx pgx.Tx, provider UserProvider, externalID string) (*User, error)
+	// CreateUser stores a new user. ErrUniqueViolation is returned if the username is already taken.
+	CreateUser(ctx context.Context, tx pgx.Tx, user User) error
+	// UpdateUser stores email, display name, password and role of an existing user.
+	UpdateUser(ctx context.Context, tx pgx.Tx, user User) error
 }
 
 type TokenRepository interface {
 	StoreToken(ctx context.Context, tx pgx.Tx, token *AuthToken) error
 	GetToken(ctx context.Context, tx pgx.Tx, id string) (*AuthToken, error)
+	// DeleteToken revokes a token. ErrNotFound is returned if no token with the id exists or it has already been
+	// revoked, so only one of concurrent callers revokes it.
 	DeleteToken(ctx context.Context, tx pgx.Tx, tokenId string) error
+	// ListTokensByUser returns all tokens of a user that are neither revoked nor expired.
+	ListTokensByUser(ctx context.Context, tx pgx.Tx, userID string) ([]AuthToken, error)
+	// RevokeUserTokens revokes all tokens of a user except exceptTokenID and returns the number of revoked tokens.
+	RevokeUserTokens(ctx context.Context, tx pgx.Tx, userID string, exceptTokenID string) (int64, error)
 }
 
 type AuthRepository interface {
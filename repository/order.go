@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"strings"
+	"sync"
+)
+
+// Resource identifies a list of entities that has a default ordering.
+type Resource string
+
+const (
+	ResourceAssets       Resource = "assets"
+	ResourceAssetHistory Resource = "assetHistory"
+	ResourceScanConfigs  Resource = "scanConfigs"
+	ResourceScans        Resource = "scans"
+	ResourceFindings     Resource = "findings"
+	ResourceAgents       Resource = "agents"
+	ResourceUsers        Resource = "users"
+	ResourceTokens       Resource = "tokens"
+	ResourceSchedules    Resource = "schedules"
+	ResourceAudit        Resource = "audit"
+)
+
+// Order sorts a list by a single column.
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+var (
+	defaultOrdersMu sync.RWMutex
+	defaultOrders   = map[Resource][]Order{
+		ResourceAssets:       {{Column: "endpoint"}},
+		ResourceAssetHistory: {{Column: "timestamp"}},
+		ResourceScanConfigs:  {{Column: "name"}},
+		ResourceScans:        {{Column: "scan_start_time", Desc: true}},
+		ResourceFindings:     {{Column: "last_seen", Desc: true}},
+		ResourceAgents:       {{Column: "created_at"}},
+		ResourceUsers:        {{Column: "username"}},
+		ResourceTokens:       {{Column: "created_at", Desc: true}},
+		ResourceSchedules:    {{Column: "created_at"}},
+		ResourceAudit:        {{Column: "created_at", Desc: true}},
+	}
+)
+
+// DefaultOrder returns the ordering lists of resource are returned in.
+func DefaultOrder(resource Resource) []Order {
+	defaultOrdersMu.RLock()
+	defer defaultOrdersMu.RUnlock()
+	return append([]Order(nil), defaultOrders[resource]...)
+}
+
+// SetDefaultOrder overrides the default ordering of resource. Columns are inserted into queries as they are and must
+// never come from user input.
+func SetDefaultOrder(resource Resource, orders ...Order) {
+	defaultOrdersMu.Lock()
+	defer defaultOrdersMu.Unlock()
+	defaultOrders[resource] = append([]Order(nil), orders...)
+}
+
+// orderBy returns the ORDER BY clause for the default ordering of resource. The id column is always appended, so rows
+// with equal sort columns are returned in a deterministic order.
+func orderBy(resource Resource) string {
+	var columns []string
+	for _, order := range DefaultOrder(resource) {
+		if order.Column == "id" {
+			continue
+		}
+		if order.Desc {
+			columns = append(columns, order.Column+" DESC")
+		} else {
+			columns = append(columns, order.Column)
+		}
+	}
+	columns = append(columns, "id")
+	return "ORDER BY " + strings.Join(columns, ", ")
+}
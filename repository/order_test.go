@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultOrderBy(t *testing.T) {
+	tests := []struct {
+		resource Resource
+		expected string
+	}{
+		{ResourceAssets, "ORDER BY endpoint, id"},
+		{ResourceAssetHistory, "ORDER BY timestamp, id"},
+		{ResourceScanConfigs, "ORDER BY name, id"},
+		{ResourceScans, "ORDER BY scan_start_time DESC, id"},
+		{ResourceFindings, "ORDER BY last_seen DESC, id"},
+		{ResourceAgents, "ORDER BY created_at, id"},
+		{ResourceUsers, "ORDER BY username, id"},
+		{ResourceTokens, "ORDER BY created_at DESC, id"},
+		{ResourceSchedules, "ORDER BY created_at, id"},
+		{ResourceAudit, "ORDER BY created_at DESC, id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.resource), func(t *testing.T) {
+			assert.Equal(t, tt.expected, orderBy(tt.resource))
+		})
+	}
+}
+
+func TestSetDefaultOrder(t *testing.T) {
+	previous := DefaultOrder(ResourceAssets)
+	t.Cleanup(func() {
+		SetDefaultOrder(ResourceAssets, previous...)
+	})
+
+	SetDefaultOrder(ResourceAssets, Order{Column: "endpoint", Desc: true}, Order{Column: "id", Desc: true})
+	// id is always the last tie breaker
+	assert.Equal(t, "ORDER BY endpoint DESC, id", orderBy(ResourceAssets))
+
+	SetDefaultOrder(ResourceAssets)
+	assert.Equal(t, "ORDER BY id", orderBy(ResourceAssets))
+}
@@ -7,8 +7,32 @@ import (
 	"log/slog"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Column lists name the columns read by the read function of the respective table, in the order they are read.
+const (
+	tokenColumns = "id, hash, user_id, created_at, expires_at, source_ip, revoked, user_agent"
+	userColumns  = "id, provider, username, email, display_name, password, created_at, role, external_id"
+)
+
+func readToken(row pgx.Row) (AuthToken, error) {
+	var token AuthToken
+	err := row.Scan(&token.ID, &token.Hash, &token.UserID, &token.CreatedAt, &token.ExpiresAt, &token.SourceIP,
+		&token.Revoked, &token.UserAgent)
+	return token, err
+}
+
+func readUser(row pgx.Row) (User, error) {
+	var user User
+	var externalID pgtype.Text
+	err := row.Scan(&user.ID, &user.Provider, &user.Username, &user.Email, &user.DisplayName,
+		&user.Password, &user.CreatedAt, &user.Role, &externalID)
+	user.ExternalID = externalID.String
+	return user, err
+}
+
 type PostgresAuthRepository struct {
 	logger *slog.Logger
 }
@@ -32,10 +56,9 @@ func (p PostgresAuthRepository) StoreToken(ctx context.Context, tx pgx.Tx, token
 }
 
 func (p PostgresAuthRepository) GetToken(ctx context.Context, tx pgx.Tx, tokenId string) (*AuthToken, error) {
-	row := tx.QueryRow(ctx, "SELECT * FROM tokens WHERE id = $1", tokenId)
+	row := tx.QueryRow(ctx, "SELECT "+tokenColumns+" FROM tokens WHERE id = $1", tokenId)
 
-	var token AuthToken
-	err := row.Scan(&token.ID, &token.Hash, &token.UserID, &token.CreatedAt, &token.ExpiresAt, &token.SourceIP, &token.Revoked, &token.UserAgent)
+	token, err := readToken(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -50,22 +73,57 @@ func (p PostgresAuthRepository) DeleteToken(ctx context.Context, tx pgx.Tx, toke
 		"id": tokenId,
 	}
 
-	row := tx.QueryRow(ctx, `UPDATE tokens SET revoked=true WHERE id=@id`, args)
-	var token AuthToken
-	err := row.Scan(&token.ID, &token.Hash, &token.UserID, &token.CreatedAt, &token.ExpiresAt, &token.SourceIP, &token.Revoked, &token.UserAgent)
+	tag, err := tx.Exec(ctx, `UPDATE tokens SET revoked=true WHERE id=@id AND revoked=false`, args)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return ErrNotFound
-		}
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
+func (p PostgresAuthRepository) ListTokensByUser(ctx context.Context, tx pgx.Tx, userID string) ([]AuthToken, error) {
+	args := pgx.NamedArgs{
+		"user_id": userID,
+	}
+
+	rows, err := tx.Query(ctx, `SELECT `+tokenColumns+` FROM tokens 
+								WHERE user_id=@user_id AND revoked=false AND expires_at > now() `+orderBy(ResourceTokens), args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []AuthToken{}
+	for rows.Next() {
+		var token AuthToken
+		token, err = readToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (p PostgresAuthRepository) RevokeUserTokens(ctx context.Context, tx pgx.Tx, userID string, exceptTokenID string) (int64, error) {
+	args := pgx.NamedArgs{
+		"user_id":   userID,
+		"except_id": exceptTokenID,
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE tokens SET revoked=true 
+								WHERE user_id=@user_id AND id<>@except_id AND revoked=false`, args)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
 func (p PostgresAuthRepository) ListUsers(ctx context.Context, tx pgx.Tx) ([]User, error) {
 	rows, err := tx.Query(ctx, `
-		SELECT * FROM users
-	`)
+		SELECT `+userColumns+` FROM users `+orderBy(ResourceUsers))
 	if err != nil {
 		// return empty list if no identities are found
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -78,21 +136,23 @@ func (p PostgresAuthRepository) ListUsers(ctx context.Context, tx pgx.Tx) ([]Use
 	var users []User
 	for rows.Next() {
 		var user User
-		err = rows.Scan(&user.ID, &user.Provider, &user.Username, &user.Email, &user.DisplayName, &user.Password, &user.CreatedAt)
+		user, err = readUser(rows)
 		if err != nil {
 			return nil, err
 		}
 		users = append(users, user)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return users, nil
 }
 
 func (p PostgresAuthRepository) GetUser(ctx context.Context, tx pgx.Tx, id string) (*User, error) {
-	row := tx.QueryRow(ctx, "SELECT * FROM users WHERE id = $1", id)
+	row := tx.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
 
-	var user User
-	err := row.Scan(&user.ID, &user.Provider, &user.Username, &user.Email, &user.DisplayName, &user.Password, &user.CreatedAt)
+	user, err := readUser(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -103,10 +163,9 @@ func (p PostgresAuthRepository) GetUser(ctx context.Context, tx pgx.Tx, id strin
 }
 
 func (p PostgresAuthRepository) GetUserByUsername(ctx context.Context, tx pgx.Tx, username string) (*User, error) {
-	row := tx.QueryRow(ctx, "SELECT * FROM users WHERE username = $1", username)
+	row := tx.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE username = $1", username)
 
-	var user User
-	err := row.Scan(&user.ID, &user.Provider, &user.Username, &user.Email, &user.DisplayName, &user.Password, &user.CreatedAt)
+	user, err := readUser(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -116,6 +175,68 @@ func (p PostgresAuthRepository) GetUserByUsername(ctx context.Context, tx pgx.Tx
 	return &user, nil
 }
 
+func (p PostgresAuthRepository) GetUserByExternalID(ctx context.Context, tx pgx.Tx, provider UserProvider,
+	externalID string) (*User, error) {
+	row := tx.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE provider = $1 AND external_id = $2",
+		provider, externalID)
+
+	user, err := readUser(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (p PostgresAuthRepository) CreateUser(ctx context.Context, tx pgx.Tx, user User) error {
+	args := pgx.NamedArgs{
+		"id":           user.ID,
+		"provider":     user.Provider,
+		"username":     user.Username,
+		"email":        user.Email,
+		"display_name": user.DisplayName,
+		"password":     user.Password,
+		"created_at":   user.CreatedAt,
+		"role":         user.Role,
+		"external_id":  pgtype.Text{String: user.ExternalID, Valid: user.ExternalID != ""},
+	}
+
+	_, err := tx.Exec(ctx, `INSERT INTO users (id, provider, username, email, display_name, password, created_at, role,
+									external_id)
+								VALUES(@id, @provider, @username, @email, @display_name, @password, @created_at, @role,
+									@external_id)`, args)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == PgErrorCodeUniqueViolation {
+			return ErrUniqueViolation
+		}
+		return err
+	}
+	return nil
+}
+
+func (p PostgresAuthRepository) UpdateUser(ctx context.Context, tx pgx.Tx, user User) error {
+	args := pgx.NamedArgs{
+		"id":           user.ID,
+		"email":        user.Email,
+		"display_name": user.DisplayName,
+		"password":     user.Password,
+		"role":         user.Role,
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE users SET email=@email, display_name=@display_name, password=@password, role=@role 
+								WHERE id=@id`, args)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func NewPostgresAuthRepository() *PostgresAuthRepository {
 	return &PostgresAuthRepository{
 		logger: logging.GetLogger(logging.DataAccess),
@@ -19,15 +19,92 @@ const (
 var ErrUniqueViolation = errors.New("unique violation")
 var ErrNotFound = errors.New("not found")
 
+// Column lists name the columns read by the read function of the respective table, in the order they are read.
+const (
+	assetColumns        = "id, endpoint"
+	scanConfigColumns   = "id, name, type, engine, options"
+	scanColumns         = "id, scan_config_id, scan_start_time, scan_end_time, status, error, updated_at"
+	assetFindingColumns = "id, asset_id, created_at, type, data, finding_hash, agent_id, first_seen, last_seen, triage_status"
+	assetHistoryColumns = "id, asset_id, event_type, user_id, timestamp, event_data"
+)
+
+func readAsset(row pgx.Row) (ScanAsset, error) {
+	var asset ScanAsset
+	err := row.Scan(&asset.ID, &asset.Endpoint)
+	return asset, err
+}
+
+func readScanConfiguration(row pgx.Row) (ScanConfiguration, error) {
+	var config ScanConfiguration
+	err := row.Scan(&config.ID, &config.Name, &config.Type, &config.Engine, &config.Options)
+	return config, err
+}
+
+func readScan(row pgx.Row) (ScanExecution, error) {
+	var scan ScanExecution
+	err := row.Scan(&scan.ID, &scan.ScanConfigurationID, &scan.StartTime, &scan.EndTime, &scan.Status, &scan.Error,
+		&scan.UpdatedAt)
+	return scan, err
+}
+
+func readAssetFinding(row pgx.Row) (AssetFinding, error) {
+	var finding AssetFinding
+	err := row.Scan(&finding.ID, &finding.AssetID, &finding.CreatedAt,
+		&finding.Type, &finding.Data, &finding.FindingHash, &finding.AgentID, &finding.FirstSeen, &finding.LastSeen,
+		&finding.TriageStatus)
+	return finding, err
+}
+
+// extraColumnsRow scans the columns following those read by a read function into extra.
+type extraColumnsRow struct {
+	pgx.Row
+	extra []any
+}
+
+func (r extraColumnsRow) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.extra...)...)
+}
+
+func readAssetHistoryEntry(row pgx.Row) (AssetHistoryEntry, error) {
+	var entry AssetHistoryEntry
+	err := row.Scan(&entry.ID, &entry.AssetID, &entry.Type, &entry.UserID, &entry.Time, &entry.Data)
+	return entry, err
+}
+
 type PostgresScanRepository struct {
 	logger *slog.Logger
 }
 
-func (p PostgresScanRepository) ListScanAssets(ctx context.Context, tx pgx.Tx) ([]ScanAsset, error) {
+// assetTagFilterCondition matches the assets having all tags of the filter, bound by assetTagFilterArgs. An asset matches
+// if it has as many of the filter tags as there are filter tags.
+const assetTagFilterCondition = `cardinality(@tag_keys::text[]) = (
+			SELECT COUNT(*) 
+			FROM asset_tags 
+			JOIN unnest(@tag_keys::text[], @tag_values::text[]) AS filter(key, value) 
+				ON asset_tags.key = filter.key AND asset_tags.value = filter.value 
+			WHERE asset_tags.asset_id = assets.id
+		)`
+
+func assetTagFilterArgs(filter AssetFilter) pgx.NamedArgs {
+	keys := make([]string, 0, len(filter.Tags))
+	values := make([]string, 0, len(filter.Tags))
+	for key, value := range filter.Tags {
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return pgx.NamedArgs{
+		"tag_keys":   keys,
+		"tag_values": values,
+	}
+}
+
+func (p PostgresScanRepository) ListScanAssets(ctx context.Context, tx pgx.Tx, filter AssetFilter) ([]ScanAsset, error) {
 	rows, err := tx.Query(ctx, `
-		SELECT * 
-		FROM assets
-	`)
+		SELECT `+assetColumns+` 
+		FROM assets 
+		WHERE deleted_at IS NULL 
+		AND `+assetTagFilterCondition+` 
+		`+orderBy(ResourceAssets), assetTagFilterArgs(filter))
 	if err != nil {
 		// return empty list if no identities are found
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -41,24 +118,62 @@ func (p PostgresScanRepository) ListScanAssets(ctx context.Context, tx pgx.Tx) (
 	var assets []ScanAsset
 	for rows.Next() {
 		var asset ScanAsset
-		err = rows.Scan(&asset.ID, &asset.Endpoint)
+		asset, err = readAsset(rows)
 		if err != nil {
 			return nil, err
 		}
 		assets = append(assets, asset)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return assets, nil
 }
 
 func (p PostgresScanRepository) GetScanAsset(ctx context.Context, tx pgx.Tx, id string) (*ScanAsset, error) {
 	row := tx.QueryRow(ctx, `
-		SELECT * 
+		SELECT `+assetColumns+` 
+		FROM assets 
+		WHERE id = $1 
+		AND deleted_at IS NULL`, id)
+
+	asset, err := readAsset(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &asset, nil
+}
+
+func (p PostgresScanRepository) GetScanAssetIncludingDeleted(ctx context.Context, tx pgx.Tx, id string) (*ScanAsset, error) {
+	row := tx.QueryRow(ctx, `
+		SELECT `+assetColumns+`, deleted_at IS NOT NULL 
 		FROM assets 
 		WHERE id = $1`, id)
 
-	var asset ScanAsset
-	err := row.Scan(&asset.ID, &asset.Endpoint)
+	var deleted bool
+	asset, err := readAsset(extraColumnsRow{Row: row, extra: []any{&deleted}})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	asset.Deleted = deleted
+	return &asset, nil
+}
+
+func (p PostgresScanRepository) GetScanAssetByEndpoint(ctx context.Context, tx pgx.Tx, endpoint string) (*ScanAsset, error) {
+	row := tx.QueryRow(ctx, `
+		SELECT `+assetColumns+` 
+		FROM assets 
+		WHERE endpoint = $1 
+		AND deleted_at IS NULL`, endpoint)
+
+	asset, err := readAsset(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -78,10 +193,13 @@ func (p PostgresScanRepository) CreateScanAsset(ctx context.Context, tx pgx.Tx,
 		INSERT INTO assets (id, endpoint) 
 		VALUES(@id, @endpoint)`, args)
 
-	var pgErr *pgconn.PgError
-	if err != nil && errors.As(err, &pgErr) && pgErr.Code == PgErrorCodeUniqueViolation {
-		p.logger.DebugContext(ctx, "asset endpoint already exists", logging.FieldError, err)
-		return ErrUniqueViolation
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == PgErrorCodeUniqueViolation {
+			p.logger.DebugContext(ctx, "asset endpoint already exists", logging.FieldError, err)
+			return ErrUniqueViolation
+		}
+		return err
 	}
 
 	return nil
@@ -97,10 +215,10 @@ func (p PostgresScanRepository) UpdateScanAsset(ctx context.Context, tx pgx.Tx,
 		UPDATE assets 
 		SET endpoint = @endpoint 
 		WHERE id = @id 
-		RETURNING *`, args)
+		AND deleted_at IS NULL 
+		RETURNING `+assetColumns, args)
 
-	var asset ScanAsset
-	err := row.Scan(&asset.ID, &asset.Endpoint)
+	_, err := readAsset(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -115,18 +233,82 @@ func (p PostgresScanRepository) UpdateScanAsset(ctx context.Context, tx pgx.Tx,
 	return nil
 }
 
+func (p PostgresScanRepository) GetAssetTags(ctx context.Context, tx pgx.Tx, assetIDs []string) (map[string]map[string]string, error) {
+	args := pgx.NamedArgs{
+		"asset_ids": assetIDs,
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT asset_id, key, value 
+		FROM asset_tags 
+		WHERE asset_id = ANY(@asset_ids::uuid[])`, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := map[string]map[string]string{}
+	for rows.Next() {
+		var assetID, key, value string
+		if err = rows.Scan(&assetID, &key, &value); err != nil {
+			return nil, err
+		}
+		if tags[assetID] == nil {
+			tags[assetID] = map[string]string{}
+		}
+		tags[assetID][key] = value
+	}
+	return tags, rows.Err()
+}
+
+func (p PostgresScanRepository) SetAssetTags(ctx context.Context, tx pgx.Tx, assetID string, tags map[string]string) error {
+	_, err := tx.Exec(ctx, `DELETE FROM asset_tags WHERE asset_id = $1`, assetID)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range tags {
+		args := pgx.NamedArgs{
+			"asset_id": assetID,
+			"key":      key,
+			"value":    value,
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO asset_tags (asset_id, key, value) 
+			VALUES(@asset_id, @key, @value)`, args)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assetLimitLockID identifies the advisory lock serializing asset creation against the asset limit.
+const assetLimitLockID = 7261483922
+
+func (p PostgresScanRepository) CountScanAssets(ctx context.Context, tx pgx.Tx) (int, error) {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", assetLimitLockID); err != nil {
+		return 0, err
+	}
+
+	var count int
+	err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM assets WHERE deleted_at IS NULL`).Scan(&count)
+	return count, err
+}
+
 func (p PostgresScanRepository) DeleteScanAsset(ctx context.Context, tx pgx.Tx, id string) error {
 	args := pgx.NamedArgs{
 		"id": id,
 	}
 
 	row := tx.QueryRow(ctx, `
-		DELETE FROM assets 
+		UPDATE assets 
+		SET deleted_at = now() 
 		WHERE id = @id 
-		RETURNING *`, args)
+		AND deleted_at IS NULL 
+		RETURNING `+assetColumns, args)
 
-	var asset ScanAsset
-	err := row.Scan(&asset.ID, &asset.Endpoint)
+	_, err := readAsset(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -136,11 +318,79 @@ func (p PostgresScanRepository) DeleteScanAsset(ctx context.Context, tx pgx.Tx,
 	return nil
 }
 
+func (p PostgresScanRepository) PurgeScanAsset(ctx context.Context, tx pgx.Tx, id string) (*ScanAsset, error) {
+	args := pgx.NamedArgs{
+		"id": id,
+	}
+
+	// scans keep their other assets, findings, history and tags are removed with the asset
+	_, err := tx.Exec(ctx, `
+		DELETE FROM scan_asset_map 
+		WHERE asset_id = @id`, args)
+	if err != nil {
+		return nil, err
+	}
+
+	row := tx.QueryRow(ctx, `
+		DELETE FROM assets 
+		WHERE id = @id 
+		RETURNING `+assetColumns, args)
+
+	asset, err := readAsset(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &asset, nil
+}
+
+func (p PostgresScanRepository) RestoreScanAsset(ctx context.Context, tx pgx.Tx, id string) (*ScanAsset, error) {
+	args := pgx.NamedArgs{
+		"id": id,
+	}
+
+	row := tx.QueryRow(ctx, `
+		UPDATE assets 
+		SET deleted_at = NULL 
+		WHERE id = @id 
+		AND deleted_at IS NOT NULL 
+		RETURNING `+assetColumns, args)
+
+	asset, err := readAsset(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == PgErrorCodeUniqueViolation {
+			p.logger.DebugContext(ctx, "asset endpoint already exists", logging.FieldError, err)
+			return nil, ErrUniqueViolation
+		}
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// scanConfigLimitLockID identifies the advisory lock serializing scan configuration creation against the limit.
+const scanConfigLimitLockID = 7261483923
+
+func (p PostgresScanRepository) CountScanConfigurations(ctx context.Context, tx pgx.Tx) (int, error) {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", scanConfigLimitLockID); err != nil {
+		return 0, err
+	}
+
+	var count int
+	err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM scan_configs`).Scan(&count)
+	return count, err
+}
+
 func (p PostgresScanRepository) ListScanConfigurations(ctx context.Context, tx pgx.Tx) ([]ScanConfiguration, error) {
 	rows, err := tx.Query(ctx, `
-		SELECT * 
-		FROM scan_configs;
-	`)
+		SELECT `+scanConfigColumns+` 
+		FROM scan_configs 
+		`+orderBy(ResourceScanConfigs))
 
 	if err != nil {
 		// return empty list if no identities are found
@@ -155,25 +405,27 @@ func (p PostgresScanRepository) ListScanConfigurations(ctx context.Context, tx p
 	var scans []ScanConfiguration
 	for rows.Next() {
 		var scan ScanConfiguration
-		err = rows.Scan(&scan.ID, &scan.Name, &scan.Type, &scan.Engine)
+		scan, err = readScanConfiguration(rows)
 		if err != nil {
 			return nil, err
 		}
 		scans = append(scans, scan)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return scans, nil
 }
 
 func (p PostgresScanRepository) GetScanConfiguration(ctx context.Context, tx pgx.Tx, id string) (*ScanConfiguration, error) {
 	row := tx.QueryRow(ctx, `
-		SELECT * 
+		SELECT `+scanConfigColumns+` 
 		FROM scan_configs 
 		WHERE scan_configs.id = $1;
 	`, id)
 
-	var scan ScanConfiguration
-	err := row.Scan(&scan.ID, &scan.Name, &scan.Type, &scan.Engine)
+	scan, err := readScanConfiguration(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -187,15 +439,16 @@ func (p PostgresScanRepository) GetScanConfiguration(ctx context.Context, tx pgx
 func (p PostgresScanRepository) CreateScanConfiguration(ctx context.Context, tx pgx.Tx, scanConfiguration ScanConfiguration) error {
 	// create scan config first, then in the same transaction associate all assets
 	args := pgx.NamedArgs{
-		"id":     scanConfiguration.ID,
-		"name":   scanConfiguration.Name,
-		"type":   scanConfiguration.Type,
-		"engine": scanConfiguration.Engine,
+		"id":      scanConfiguration.ID,
+		"name":    scanConfiguration.Name,
+		"type":    scanConfiguration.Type,
+		"engine":  scanConfiguration.Engine,
+		"options": scanConfiguration.Options,
 	}
 
 	_, err := tx.Exec(ctx, `
-		INSERT INTO scan_configs (id, name, type, engine) 
-		VALUES(@id, @name, @type, @engine)`, args)
+		INSERT INTO scan_configs (id, name, type, engine, options) 
+		VALUES(@id, @name, @type, @engine, @options)`, args)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -212,20 +465,20 @@ func (p PostgresScanRepository) CreateScanConfiguration(ctx context.Context, tx
 // UpdateScanConfiguration updates an existing scan configuration in the database with the provided details.
 func (p PostgresScanRepository) UpdateScanConfiguration(ctx context.Context, tx pgx.Tx, scanConfiguration ScanConfiguration) error {
 	args := pgx.NamedArgs{
-		"id":     scanConfiguration.ID,
-		"name":   scanConfiguration.Name,
-		"type":   scanConfiguration.Type,
-		"engine": scanConfiguration.Engine,
+		"id":      scanConfiguration.ID,
+		"name":    scanConfiguration.Name,
+		"type":    scanConfiguration.Type,
+		"engine":  scanConfiguration.Engine,
+		"options": scanConfiguration.Options,
 	}
 
 	row := tx.QueryRow(ctx, `
 		UPDATE scan_configs 
-		SET name = @name, type = @type, engine = @engine 
+		SET name = @name, type = @type, engine = @engine, options = @options 
 		WHERE id = @id 
-		RETURNING *`, args)
+		RETURNING `+scanConfigColumns, args)
 
-	var config ScanConfiguration
-	err := row.Scan(&config.ID, &config.Name)
+	_, err := readScanConfiguration(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -235,6 +488,7 @@ func (p PostgresScanRepository) UpdateScanConfiguration(ctx context.Context, tx
 			p.logger.DebugContext(ctx, "scan config name already exists", logging.FieldError, err)
 			return ErrUniqueViolation
 		}
+		return err
 	}
 	return nil
 }
@@ -247,10 +501,9 @@ func (p PostgresScanRepository) DeleteScanConfiguration(ctx context.Context, tx
 	row := tx.QueryRow(ctx, `
 		DELETE FROM scan_configs 
 		WHERE id = @id 
-		RETURNING *`, args)
+		RETURNING `+scanConfigColumns, args)
 
-	var config ScanConfiguration
-	err := row.Scan(&config.ID, &config.Name)
+	_, err := readScanConfiguration(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -261,10 +514,79 @@ func (p PostgresScanRepository) DeleteScanConfiguration(ctx context.Context, tx
 	return err
 }
 
+func (p PostgresScanRepository) GetScanConfigurationAssets(ctx context.Context, tx pgx.Tx, id string) ([]ScanAsset, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT `+assetColumns+`
+		FROM assets
+		INNER JOIN scan_config_asset_map scam on assets.id = scam.asset_id
+		WHERE scam.scan_config_id = $1
+		AND assets.deleted_at IS NULL`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assets := []ScanAsset{}
+	for rows.Next() {
+		var asset ScanAsset
+		asset, err = readAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, rows.Err()
+}
+
+func (p PostgresScanRepository) SetScanConfigurationAssets(ctx context.Context, tx pgx.Tx, id string, assetIDs []string) error {
+	_, err := tx.Exec(ctx, `
+		DELETE FROM scan_config_asset_map 
+		WHERE scan_config_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	for _, assetID := range assetIDs {
+		args := pgx.NamedArgs{
+			"scan_config_id": id,
+			"asset_id":       assetID,
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO scan_config_asset_map (scan_config_id, asset_id) 
+			VALUES(@scan_config_id, @asset_id)
+			ON CONFLICT DO NOTHING`, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (p PostgresScanRepository) ListScans(ctx context.Context, tx pgx.Tx) ([]ScanExecution, error) {
+	return p.listScans(ctx, tx, "")
+}
+
+func (p PostgresScanRepository) ListScansByConfig(ctx context.Context, tx pgx.Tx, configID string) ([]ScanExecution, error) {
+	return p.listScans(ctx, tx, "WHERE scan_config_id = $1", configID)
+}
+
+func (p PostgresScanRepository) ListScansByStatus(ctx context.Context, tx pgx.Tx, statuses ...ScanStatus) ([]ScanExecution, error) {
+	values := make([]string, len(statuses))
+	for i, status := range statuses {
+		values[i] = string(status)
+	}
+	return p.listScans(ctx, tx, "WHERE status = ANY($1)", values)
+}
+
+// listScans returns the scans matching the where clause with their assets.
+func (p PostgresScanRepository) listScans(ctx context.Context, tx pgx.Tx, where string, args ...any) ([]ScanExecution, error) {
 	rows, err := tx.Query(ctx, `
-		SELECT * 
-		FROM scans;`)
+		SELECT `+scanColumns+` 
+		FROM scans 
+		`+where+`
+		`+orderBy(ResourceScans), args...)
 
 	if err != nil {
 		// return empty list if no identities are found
@@ -279,41 +601,29 @@ func (p PostgresScanRepository) ListScans(ctx context.Context, tx pgx.Tx) ([]Sca
 	var scans []ScanExecution
 	for rows.Next() {
 		var scan ScanExecution
-		err = rows.Scan(&scan.ID, &scan.ScanConfigurationID, &scan.StartTime, &scan.EndTime, &scan.Status)
+		scan, err = readScan(rows)
 		if err != nil {
 			return nil, err
 		}
 
 		scans = append(scans, scan)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
 	// get assets associated with scans
 	// we cannot do this in the loop above because the connection is busy until all rows are read
 	for index, scan := range scans {
-		rows, err = tx.Query(ctx, `
-			SELECT *
-			FROM assets
-			INNER JOIN public.scan_asset_map sam on assets.id = sam.asset_id
-			WHERE sam.scan_id = $1;
-		`, scan.ID)
-
-		if err != nil {
+		// each scan takes a query, stop early once the request is canceled
+		if err = ctx.Err(); err != nil {
 			return nil, err
 		}
-		defer rows.Close()
 
-		var assets []ScanAsset
-		for rows.Next() {
-			var asset ScanAsset
-			var dontCare any
-			err = rows.Scan(&asset.ID, &asset.Endpoint, &dontCare, &dontCare)
-			if err != nil {
-				return nil, err
-			}
-			assets = append(assets, asset)
+		scans[index].Assets, scans[index].AssetCount, err = p.getEmbeddedScanAssets(ctx, tx, scan.ID)
+		if err != nil {
+			return nil, err
 		}
-
-		scans[index].Assets = assets
 	}
 
 	return scans, nil
@@ -321,13 +631,11 @@ func (p PostgresScanRepository) ListScans(ctx context.Context, tx pgx.Tx) ([]Sca
 
 func (p PostgresScanRepository) GetScan(ctx context.Context, tx pgx.Tx, id string) (*ScanExecution, error) {
 	row := tx.QueryRow(ctx, `
-		SELECT * 
+		SELECT `+scanColumns+` 
 		FROM scans 
 		WHERE id = $1`, id)
 
-	var scan ScanExecution
-	err := row.Scan(&scan.ID, &scan.ScanConfigurationID, &scan.StartTime, &scan.EndTime, &scan.Status)
-
+	scan, err := readScan(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -336,24 +644,96 @@ func (p PostgresScanRepository) GetScan(ctx context.Context, tx pgx.Tx, id strin
 	}
 
 	// get assets associated with scan
-	var assets []ScanAsset
-	row = tx.QueryRow(ctx, `
-		SELECT id, endpoint
+	scan.Assets, scan.AssetCount, err = p.getEmbeddedScanAssets(ctx, tx, scan.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scan, nil
+}
+
+func (p PostgresScanRepository) ListAssetsOfScan(ctx context.Context, tx pgx.Tx, scanID string, limit int, offset int) ([]ScanAsset, error) {
+	var exists bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM scans WHERE id = $1)`, scanID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return p.getScanAssets(ctx, tx, scanID, limit, offset)
+}
+
+// getEmbeddedScanAssets returns the first MaxEmbeddedScanAssets assets of a scan, ordered like ListScanAssets, and the
+// number of all its assets with a single query.
+func (p PostgresScanRepository) getEmbeddedScanAssets(ctx context.Context, tx pgx.Tx, scanID string) ([]ScanAsset, int, error) {
+	args := pgx.NamedArgs{
+		"scan_id": scanID,
+		"limit":   MaxEmbeddedScanAssets,
+	}
+
+	// the count is computed before the limit is applied
+	rows, err := tx.Query(ctx, `
+		SELECT `+assetColumns+`, COUNT(*) OVER ()
 		FROM assets
-		INNER JOIN public.scan_asset_map sam on assets.id = sam.asset_id
-		WHERE sam.scan_id = $1;
-	`, scan.ID)
+		INNER JOIN scan_asset_map sam on assets.id = sam.asset_id
+		WHERE sam.scan_id = @scan_id
+		`+orderBy(ResourceAssets)+`
+		LIMIT @limit::integer`, args)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
 
-	var asset ScanAsset
-	err = row.Scan(&asset.ID, &asset.Endpoint)
+	assets := []ScanAsset{}
+	count := 0
+	for rows.Next() {
+		var asset ScanAsset
+		asset, err = readAsset(extraColumnsRow{Row: rows, extra: []any{&count}})
+		if err != nil {
+			return nil, 0, err
+		}
+		assets = append(assets, asset)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return assets, count, nil
+}
+
+// getScanAssets returns the assets registered for a scan, ordered like ListScanAssets. A limit of zero returns all
+// assets.
+func (p PostgresScanRepository) getScanAssets(ctx context.Context, tx pgx.Tx, scanID string, limit int, offset int) ([]ScanAsset, error) {
+	args := pgx.NamedArgs{
+		"scan_id": scanID,
+		"limit":   limit,
+		"offset":  offset,
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+assetColumns+`
+		FROM assets
+		INNER JOIN public.scan_asset_map sam on assets.id = sam.asset_id
+		WHERE sam.scan_id = @scan_id
+		`+orderBy(ResourceAssets)+`
+		LIMIT NULLIF(@limit::integer, 0)
+		OFFSET @offset::integer`, args)
 	if err != nil {
 		return nil, err
 	}
-	assets = append(assets, asset)
+	defer rows.Close()
 
-	scan.Assets = assets
+	var assets []ScanAsset
+	for rows.Next() {
+		var asset ScanAsset
+		asset, err = readAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
 
-	return &scan, nil
+	return assets, rows.Err()
 }
 
 func (p PostgresScanRepository) CreateScan(ctx context.Context, tx pgx.Tx, scanRun ScanExecution) error {
@@ -363,11 +743,15 @@ func (p PostgresScanRepository) CreateScan(ctx context.Context, tx pgx.Tx, scanR
 		"scan_start_time": scanRun.StartTime,
 		"scan_end_time":   scanRun.EndTime,
 		"status":          scanRun.Status,
+		"error":           scanRun.Error,
 	}
 
 	_, err := tx.Exec(ctx, `
-		INSERT INTO scans (id, scan_config_id, scan_start_time, scan_end_time, status) 
-		VALUES(@id, @scan_config_id, @scan_start_time, @scan_end_time, @status)`, args)
+		INSERT INTO scans (id, scan_config_id, scan_start_time, scan_end_time, status, error, updated_at) 
+		VALUES(@id, @scan_config_id, @scan_start_time, @scan_end_time, @status, @error, now())`, args)
+	if err != nil {
+		return err
+	}
 
 	// register assets
 	for _, asset := range scanRun.Assets {
@@ -394,16 +778,46 @@ func (p PostgresScanRepository) UpdateScan(ctx context.Context, tx pgx.Tx, scanR
 		"scan_start_time": scanRun.StartTime.Time,
 		"scan_end_time":   scanRun.EndTime.Time,
 		"status":          scanRun.Status,
+		"error":           scanRun.Error,
 	}
 
 	row := tx.QueryRow(ctx, `
 		UPDATE scans 
-		SET scan_config_id = @scan_config_id, scan_start_time = @scan_start_time, scan_end_time = @scan_end_time, status = @status 
+		SET scan_config_id = @scan_config_id, scan_start_time = @scan_start_time, scan_end_time = @scan_end_time, status = @status, 
+		    error = @error, updated_at = now() 
 		WHERE id = @id 
-		RETURNING *`, args)
+		RETURNING `+scanColumns, args)
 
-	var scan ScanExecution
-	err := row.Scan(&scan.ID, &scan.ScanConfigurationID, &scan.StartTime, &scan.EndTime, &scan.Status)
+	_, err := readScan(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (p PostgresScanRepository) DeleteScan(ctx context.Context, tx pgx.Tx, id string) error {
+	args := pgx.NamedArgs{
+		"id": id,
+	}
+
+	_, err := tx.Exec(ctx, `
+		DELETE FROM scan_asset_map 
+		WHERE scan_id = @id`, args)
+	if err != nil {
+		return err
+	}
+
+	row := tx.QueryRow(ctx, `
+		DELETE FROM scans 
+		WHERE id = @id 
+		RETURNING id`, args)
+
+	var deletedID string
+	err = row.Scan(&deletedID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrNotFound
@@ -414,7 +828,116 @@ func (p PostgresScanRepository) UpdateScan(ctx context.Context, tx pgx.Tx, scanR
 	return nil
 }
 
-func (p PostgresScanRepository) PutAssetFinding(ctx context.Context, tx pgx.Tx, result AssetFinding) error {
+func (p PostgresScanRepository) CountActiveScans(ctx context.Context, tx pgx.Tx, scanConfigID string) (int, error) {
+	row := tx.QueryRow(ctx, `
+		SELECT COUNT(*) 
+		FROM scans 
+		WHERE scan_config_id = $1 
+		AND status IN ($2, $3)`, scanConfigID, ScanStatusQueued, ScanStatusRunning)
+
+	var count int
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// scanStartLockID identifies the advisory lock serializing scans moving to running.
+const scanStartLockID = 7261483921
+
+func (p PostgresScanRepository) CountRunningScans(ctx context.Context, tx pgx.Tx) (int, error) {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", scanStartLockID); err != nil {
+		return 0, err
+	}
+
+	row := tx.QueryRow(ctx, "SELECT COUNT(*) FROM scans WHERE status = $1", ScanStatusRunning)
+
+	var count int
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p PostgresScanRepository) GetScanQueuePosition(ctx context.Context, tx pgx.Tx, id string) (int, error) {
+	// queued scans are not changed until an agent picks them up, the last change is the time they were queued. There is
+	// no dispatcher following this order, agents may pick up any queued scan.
+	row := tx.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM scans q
+		INNER JOIN scans s ON s.id = $1 AND s.status = $2
+		WHERE q.status = $2
+		AND (q.updated_at, q.id) <= (s.updated_at, s.id)`, id, ScanStatusQueued)
+
+	var position int
+	err := row.Scan(&position)
+	if err != nil {
+		return 0, err
+	}
+	if position == 0 {
+		return 0, ErrNotFound
+	}
+	return position, nil
+}
+
+func (p PostgresScanRepository) CountScanFindings(ctx context.Context, tx pgx.Tx, scanIDs []string) (map[string]ScanFindingCounts, error) {
+	args := pgx.NamedArgs{
+		"scan_ids": scanIDs,
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT sf.scan_id,
+		       count(f.id),
+		       count(f.id) FILTER (WHERE f.first_seen >= s.scan_start_time)
+		FROM scan_findings sf
+		INNER JOIN scans s on s.id = sf.scan_id
+		INNER JOIN asset_findings f on f.id = sf.finding_id
+		WHERE sf.scan_id = ANY(@scan_ids::uuid[])
+		GROUP BY sf.scan_id`, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]ScanFindingCounts)
+	for rows.Next() {
+		var scanID string
+		var count ScanFindingCounts
+		if err = rows.Scan(&scanID, &count.Findings, &count.NewFindings); err != nil {
+			return nil, err
+		}
+		counts[scanID] = count
+	}
+
+	return counts, rows.Err()
+}
+
+func (p PostgresScanRepository) ListOverdueScanIDs(ctx context.Context, tx pgx.Tx, startedBefore time.Time) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id
+		FROM scans
+		WHERE status = $1
+		AND scan_start_time < $2`, ScanStatusRunning, startedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scanIDs []string
+	for rows.Next() {
+		var scanID string
+		if err = rows.Scan(&scanID); err != nil {
+			return nil, err
+		}
+		scanIDs = append(scanIDs, scanID)
+	}
+
+	return scanIDs, rows.Err()
+}
+
+func (p PostgresScanRepository) PutAssetFinding(ctx context.Context, tx pgx.Tx, result AssetFinding) (*AssetFinding, []string, error) {
 	args := pgx.NamedArgs{
 		"id":           result.ID,
 		"asset_id":     result.AssetID,
@@ -423,28 +946,56 @@ func (p PostgresScanRepository) PutAssetFinding(ctx context.Context, tx pgx.Tx,
 		"data":         result.Data,
 		"finding_hash": result.FindingHash,
 		"agent_id":     result.AgentID,
+		"first_seen":   result.FirstSeen,
+		"last_seen":    result.LastSeen,
+		"queued":       ScanStatusQueued,
+		"running":      ScanStatusRunning,
 	}
-	// insert
-	_, err := tx.Exec(ctx, `
-			INSERT INTO asset_findings (id, asset_id, created_at, type, data, finding_hash, agent_id)   
-			VALUES(@id, @asset_id, @created_at, @type, @data, @finding_hash, @agent_id)`, args)
-
+	// insert or refresh an existing finding with the same hash on the asset and record that the running scans of the
+	// asset have seen it, returning the active scans of the asset along with it if the finding has been inserted.
+	// xmax is only set on rows updated by the upsert.
+	row := tx.QueryRow(ctx, `
+			WITH finding AS (
+				INSERT INTO asset_findings (id, asset_id, created_at, type, data, finding_hash, agent_id, first_seen, last_seen)   
+				VALUES(@id, @asset_id, @created_at, @type, @data, @finding_hash, @agent_id, @first_seen, @last_seen)
+				ON CONFLICT (asset_id, finding_hash) DO UPDATE 
+				SET last_seen = excluded.last_seen, data = excluded.data, agent_id = excluded.agent_id
+				RETURNING `+assetFindingColumns+`, xmax = 0 AS inserted
+			), observed AS (
+				INSERT INTO scan_findings (scan_id, finding_id) 
+				SELECT s.id, finding.id 
+				FROM finding 
+				INNER JOIN scan_asset_map sam on sam.asset_id = finding.asset_id 
+				INNER JOIN scans s on s.id = sam.scan_id 
+				WHERE s.status = @running 
+				ON CONFLICT (scan_id, finding_id) DO NOTHING
+			)
+			SELECT `+assetFindingColumns+`, ARRAY(
+				SELECT s.id::text 
+				FROM scans s 
+				INNER JOIN scan_asset_map sam on s.id = sam.scan_id 
+				WHERE finding.inserted 
+				AND sam.asset_id = finding.asset_id 
+				AND s.status IN (@queued, @running)) 
+			FROM finding`, args)
+
+	var scanIDs []string
+	finding, err := readAssetFinding(extraColumnsRow{Row: row, extra: []any{&scanIDs}})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	return nil
+	return &finding, scanIDs, nil
 }
 
 func (p PostgresScanRepository) GetAssetFinding(ctx context.Context, tx pgx.Tx, id string) (*AssetFinding, error) {
 	row := tx.QueryRow(ctx, `
-		SELECT * 
+		SELECT `+assetFindingColumns+` 
 		FROM asset_findings 
-		WHERE id = $1`, id)
+		WHERE id = $1 
+		AND asset_id IN (SELECT id FROM assets WHERE deleted_at IS NULL)`, id)
 
-	var finding AssetFinding
-	err := row.Scan(&finding.ID, &finding.AssetID, &finding.CreatedAt,
-		&finding.Type, &finding.Data, &finding.FindingHash, &finding.AgentID)
+	finding, err := readAssetFinding(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNotFound
@@ -455,115 +1006,386 @@ func (p PostgresScanRepository) GetAssetFinding(ctx context.Context, tx pgx.Tx,
 	return &finding, nil
 }
 
-func (p PostgresScanRepository) ListAssetFindings(ctx context.Context, tx pgx.Tx, assetID string) ([]AssetFinding, error) {
-	rows, err := tx.Query(ctx, `
-		SELECT * 
+func (p PostgresScanRepository) GetAssetFindingIncludingDeleted(ctx context.Context, tx pgx.Tx, id string) (*AssetFinding, error) {
+	row := tx.QueryRow(ctx, `
+		SELECT `+assetFindingColumns+` 
 		FROM asset_findings 
-		WHERE asset_id = $1`, assetID)
+		WHERE id = $1`, id)
 
+	finding, err := readAssetFinding(row)
 	if err != nil {
-		// return empty list if no identities are found
 		if errors.Is(err, pgx.ErrNoRows) {
-			// reset error to not trigger rollback
-			return []AssetFinding{}, nil
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
+
+	return &finding, nil
+}
+
+// findingFilterCondition is the WHERE condition of asset_findings rows matching the findingFilterArgs of a
+// FindingFilter.
+const findingFilterCondition = `(@asset_id::text = '' OR asset_id = NULLIF(@asset_id::text, '')::uuid) 
+		AND (@type::text = '' OR type = @type::text) 
+		AND (@severity::text = '' OR COALESCE(data->>'severity', data->'info'->>'severity') = @severity::text) 
+		AND (cardinality(@triage_statuses::text[]) = 0 OR triage_status = ANY(@triage_statuses::text[])) 
+		AND asset_id IN (SELECT id FROM assets WHERE deleted_at IS NULL)`
+
+func findingFilterArgs(filter FindingFilter) pgx.NamedArgs {
+	return pgx.NamedArgs{
+		"asset_id":        filter.AssetID,
+		"type":            string(filter.Type),
+		"severity":        string(filter.Severity),
+		"triage_statuses": triageStatusStrings(filter.TriageStatuses),
+		"limit":           filter.Limit,
+		"offset":          filter.Offset,
+	}
+}
+
+func (p PostgresScanRepository) ListFindings(ctx context.Context, tx pgx.Tx, filter FindingFilter) ([]AssetFinding, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT `+assetFindingColumns+` 
+		FROM asset_findings 
+		WHERE `+findingFilterCondition+` 
+		`+orderBy(ResourceFindings)+` 
+		LIMIT NULLIF(@limit::integer, 0) 
+		OFFSET @offset::integer`, findingFilterArgs(filter))
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var discoveryResults []AssetFinding
+	findings := []AssetFinding{}
 	for rows.Next() {
-		var discoveryResult AssetFinding
-		err = rows.Scan(&discoveryResult.ID, &discoveryResult.AssetID, &discoveryResult.CreatedAt,
-			&discoveryResult.Type, &discoveryResult.Data, &discoveryResult.FindingHash, &discoveryResult.AgentID)
+		var finding AssetFinding
+		finding, err = readAssetFinding(rows)
 		if err != nil {
 			return nil, err
 		}
-		discoveryResults = append(discoveryResults, discoveryResult)
+		findings = append(findings, finding)
 	}
-
-	return discoveryResults, nil
+	return findings, rows.Err()
 }
 
-func (p PostgresScanRepository) GetAssetStats(ctx context.Context, tx pgx.Tx, assetID string) (*ScanAssetStats, error) {
-	// get number of discovered ports
-	row := tx.QueryRow(ctx, `
-		SELECT COUNT(DISTINCT finding_hash) 
+func (p PostgresScanRepository) EachFinding(ctx context.Context, tx pgx.Tx, filter FindingFilter, fn func(AssetFindingWithAsset) error) error {
+	rows, err := tx.Query(ctx, `
+		SELECT `+assetFindingColumns+`, 
+		       (SELECT endpoint FROM assets WHERE assets.id = asset_findings.asset_id) 
 		FROM asset_findings 
-		WHERE asset_id = $1
-		AND type = $2`, assetID, FindingTypePort)
+		WHERE `+findingFilterCondition+` 
+		`+orderBy(ResourceFindings)+` 
+		LIMIT NULLIF(@limit::integer, 0) 
+		OFFSET @offset::integer`, findingFilterArgs(filter))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var finding AssetFindingWithAsset
+		finding.AssetFinding, err = readAssetFinding(extraColumnsRow{Row: rows, extra: []any{&finding.Asset.Endpoint}})
+		if err != nil {
+			return err
+		}
+		finding.Asset.ID = finding.AssetID
+		if err = fn(finding); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (p PostgresScanRepository) ListAssetFindings(ctx context.Context, tx pgx.Tx, assetID string, filter FindingFilter) ([]AssetFinding, error) {
+	filter.AssetID = assetID
+	return p.ListFindings(ctx, tx, filter)
+}
+
+// triageStatusStrings converts statuses to a non-nil slice, so it is sent as empty array rather than NULL.
+func triageStatusStrings(statuses []TriageStatus) []string {
+	values := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		values = append(values, string(status))
+	}
+	return values
+}
+
+func (p PostgresScanRepository) UpdateAssetFindingTriage(ctx context.Context, tx pgx.Tx, id string, status TriageStatus) error {
+	args := pgx.NamedArgs{
+		"id":            id,
+		"triage_status": status,
+	}
 
-	var portCount int
-	err := row.Scan(&portCount)
+	tag, err := tx.Exec(ctx, `
+		UPDATE asset_findings 
+		SET triage_status = @triage_status 
+		WHERE id = @id`, args)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
 	}
+	return nil
+}
 
-	// find timestamp of last discovery scan
-	row = tx.QueryRow(ctx, `
-		SELECT s.scan_end_time
-		FROM
-			scans s
-		INNER JOIN public.scan_asset_map sam on s.id = sam.scan_id
-		WHERE sam.asset_id = $1
-		AND s.scan_end_time IS NOT NULL
-		ORDER BY s.scan_end_time DESC
-		LIMIT 1;
-    `, assetID)
+func (p PostgresScanRepository) ListFindingAssetIDs(ctx context.Context, tx pgx.Tx, afterID string, limit int) ([]string, error) {
+	args := pgx.NamedArgs{
+		"after_id": afterID,
+		"limit":    limit,
+	}
 
-	var lastDiscoveryTime pgtype.Timestamp
-	err = row.Scan(&lastDiscoveryTime)
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT asset_id::text 
+		FROM asset_findings 
+		WHERE asset_id::text > @after_id 
+		ORDER BY asset_id::text 
+		LIMIT @limit`, args)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			lastDiscoveryTime = pgtype.Timestamp{Time: time.Time{}}
-		} else {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assetIDs []string
+	for rows.Next() {
+		var assetID string
+		if err = rows.Scan(&assetID); err != nil {
 			return nil, err
 		}
+		assetIDs = append(assetIDs, assetID)
+	}
+
+	return assetIDs, rows.Err()
+}
+
+func (p PostgresScanRepository) UpdateAssetFindings(ctx context.Context, tx pgx.Tx, findings []AssetFinding) error {
+	ids := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		ids = append(ids, finding.ID)
 	}
 
-	// find highest vulnerability severity
-	row = tx.QueryRow(ctx, `
-		SELECT data->'info'->>'severity'
-		FROM asset_findings
-		WHERE asset_id = $1
-		AND type = $2
-		AND data->'info'->>'severity' IS NOT NULL
-		ORDER BY 
-			CASE data->'info'->>'severity'
-				WHEN 'critical' THEN 5
-				WHEN 'high' THEN 4
-				WHEN 'medium' THEN 3
-				WHEN 'low' THEN 2
-				WHEN 'info' THEN 1
-				ELSE 0
-			END DESC
-		LIMIT 1;
-	`, assetID, FindingTypeVulnerability)
-
-	var highestSeverity string
-	err = row.Scan(&highestSeverity)
+	// the unique index on (asset_id, finding_hash) is checked per row, so the hashes are released first to allow
+	// findings to take over each other's hashes
+	_, err := tx.Exec(ctx, `
+		UPDATE asset_findings 
+		SET finding_hash = id::text 
+		WHERE id = ANY(@ids::uuid[])`, pgx.NamedArgs{"ids": ids})
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			highestSeverity = string(SeverityInfo)
-		} else {
+		return err
+	}
+
+	for _, finding := range findings {
+		args := pgx.NamedArgs{
+			"id":            finding.ID,
+			"data":          finding.Data,
+			"finding_hash":  finding.FindingHash,
+			"agent_id":      finding.AgentID,
+			"first_seen":    finding.FirstSeen,
+			"last_seen":     finding.LastSeen,
+			"triage_status": finding.TriageStatus,
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE asset_findings 
+			SET data = @data, finding_hash = @finding_hash, agent_id = @agent_id, 
+			    first_seen = @first_seen, last_seen = @last_seen, triage_status = @triage_status 
+			WHERE id = @id`, args)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == PgErrorCodeUniqueViolation {
+				return ErrUniqueViolation
+			}
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+	}
+
+	return nil
+}
+
+func (p PostgresScanRepository) MergeAssetFindings(ctx context.Context, tx pgx.Tx, merged map[string]string) error {
+	ids := make([]string, 0, len(merged))
+	intoIDs := make([]string, 0, len(merged))
+	for id, intoID := range merged {
+		ids = append(ids, id)
+		intoIDs = append(intoIDs, intoID)
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO scan_findings (scan_id, finding_id) 
+		SELECT DISTINCT sf.scan_id, m.into_id 
+		FROM scan_findings sf 
+		INNER JOIN unnest(@ids::uuid[], @into_ids::uuid[]) AS m(id, into_id) on m.id = sf.finding_id 
+		ON CONFLICT (scan_id, finding_id) DO NOTHING`, pgx.NamedArgs{"ids": ids, "into_ids": intoIDs})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM asset_findings 
+		WHERE id = ANY(@ids::uuid[])`, pgx.NamedArgs{"ids": ids})
+	return err
+}
+
+func (p PostgresScanRepository) GetAssetStats(ctx context.Context, tx pgx.Tx, assetID string) (*ScanAssetStats, error) {
+	stats, err := p.ListAssetStats(ctx, tx, []string{assetID})
+	if err != nil {
+		return nil, err
+	}
+
+	assetStats := stats[assetID]
+	return &assetStats, nil
+}
+
+// assetStatsQuery returns a query for the stats of the assets returned by the selected query, which must select their id
+// column. Each row has the given columns of the selected assets, available as a, followed by the stats as read by
+// scanAssetStats. The query is bound by assetStatsArgs.
+func assetStatsQuery(selected, columns string) string {
+	// ports are counted once per finding hash, the last discovery is the end of the asset's most recent scan and the
+	// vulnerability stats ignore findings that need no attention. The CVSS score is read from the data reported by
+	// agents or the classification of nuclei results.
+	return `
+		WITH selected AS (` + selected + `) 
+		SELECT ` + columns + `, COALESCE(f.port_count, 0), d.last_discovery, 
+			COALESCE(v.severity, @default_severity), f.last_finding_seen, 
+			COALESCE(c.vulnerability_count, 0), COALESCE(c.critical_count, 0), COALESCE(c.high_count, 0), 
+			COALESCE(c.medium_count, 0), COALESCE(c.low_count, 0), COALESCE(c.info_count, 0), 
+			c.highest_cvss_score 
+		FROM selected a 
+		LEFT JOIN (
+			SELECT asset_id, 
+				COUNT(DISTINCT finding_hash) FILTER (WHERE type = @port_type) AS port_count, 
+				MAX(last_seen) AS last_finding_seen 
+			FROM asset_findings 
+			WHERE asset_id IN (SELECT id FROM selected) 
+			GROUP BY asset_id
+		) f ON f.asset_id = a.id 
+		LEFT JOIN (
+			SELECT sam.asset_id, MAX(s.scan_end_time) AS last_discovery 
+			FROM scans s 
+			INNER JOIN scan_asset_map sam ON s.id = sam.scan_id 
+			WHERE sam.asset_id IN (SELECT id FROM selected) 
+			AND s.scan_end_time IS NOT NULL 
+			GROUP BY sam.asset_id
+		) d ON d.asset_id = a.id 
+		LEFT JOIN (
+			SELECT DISTINCT ON (asset_id) asset_id, 
+				COALESCE(data->>'severity', data->'info'->>'severity') AS severity 
+			FROM asset_findings 
+			WHERE asset_id IN (SELECT id FROM selected) 
+			AND type = @vulnerability 
+			AND triage_status = ANY(@triage_statuses::text[]) 
+			AND COALESCE(data->>'severity', data->'info'->>'severity') IS NOT NULL 
+			ORDER BY asset_id, 
+				CASE COALESCE(data->>'severity', data->'info'->>'severity') 
+					WHEN 'critical' THEN 5 
+					WHEN 'high' THEN 4 
+					WHEN 'medium' THEN 3 
+					WHEN 'low' THEN 2 
+					WHEN 'info' THEN 1 
+					ELSE 0 
+				END DESC
+		) v ON v.asset_id = a.id 
+		LEFT JOIN (
+			SELECT asset_id, 
+				COUNT(DISTINCT finding_hash) AS vulnerability_count, 
+				COUNT(DISTINCT finding_hash) FILTER (WHERE severity = 'critical') AS critical_count, 
+				COUNT(DISTINCT finding_hash) FILTER (WHERE severity = 'high') AS high_count, 
+				COUNT(DISTINCT finding_hash) FILTER (WHERE severity = 'medium') AS medium_count, 
+				COUNT(DISTINCT finding_hash) FILTER (WHERE severity = 'low') AS low_count, 
+				COUNT(DISTINCT finding_hash) FILTER (WHERE severity = 'info') AS info_count, 
+				MAX(CASE WHEN jsonb_typeof(cvss_score) = 'number' THEN cvss_score::text::float8 END) 
+					AS highest_cvss_score 
+			FROM (
+				SELECT asset_id, finding_hash, 
+					COALESCE(data->>'severity', data->'info'->>'severity') AS severity, 
+					COALESCE(data->'cvss-score', data->'info'->'classification'->'cvss-score') AS cvss_score 
+				FROM asset_findings 
+				WHERE asset_id IN (SELECT id FROM selected) 
+				AND type = @vulnerability 
+				AND triage_status = ANY(@triage_statuses::text[])
+			) vf 
+			GROUP BY asset_id
+		) c ON c.asset_id = a.id`
+}
+
+func assetStatsArgs(args pgx.NamedArgs) pgx.NamedArgs {
+	args["port_type"] = FindingTypePort
+	args["vulnerability"] = FindingTypeVulnerability
+	args["triage_statuses"] = triageStatusStrings(VisibleTriageStatuses)
+	args["default_severity"] = SeverityInfo
+	return args
+}
+
+// scanAssetStats reads the stats columns of assetStatsQuery following the given destinations.
+func scanAssetStats(row pgx.Row, assetStats *ScanAssetStats, dest ...any) error {
+	var lastDiscovery pgtype.Timestamp
+	severities := &assetStats.VulnerabilitySeverities
+	err := row.Scan(append(dest, &assetStats.DiscoveredPortsCount, &lastDiscovery,
+		&assetStats.HighestVulnerabilitySeverity, &assetStats.LastFindingSeen, &assetStats.VulnerabilityCount,
+		&severities.Critical, &severities.High, &severities.Medium, &severities.Low, &severities.Info,
+		&assetStats.HighestCVSSScore)...)
+	if err != nil {
+		return err
+	}
+	assetStats.LastDiscovery = lastDiscovery.Time
+	return nil
+}
+
+func (p PostgresScanRepository) ListAssetStats(ctx context.Context, tx pgx.Tx, assetIDs []string) (map[string]ScanAssetStats, error) {
+	rows, err := tx.Query(ctx, assetStatsQuery(`
+			SELECT id FROM assets 
+			WHERE id = ANY(@asset_ids::uuid[]) 
+			AND deleted_at IS NULL`, "a.id"), assetStatsArgs(pgx.NamedArgs{"asset_ids": assetIDs}))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]ScanAssetStats, len(assetIDs))
+	for rows.Next() {
+		var assetID string
+		var assetStats ScanAssetStats
+		if err = scanAssetStats(rows, &assetStats, &assetID); err != nil {
 			return nil, err
 		}
+		stats[assetID] = assetStats
 	}
+	return stats, rows.Err()
+}
+
+func (p PostgresScanRepository) EachAssetWithStats(ctx context.Context, tx pgx.Tx, filter AssetFilter, fn func(ScanAssetWithStats) error) error {
+	// the selected assets are those of ListScanAssets, their tags are aggregated into an object
+	rows, err := tx.Query(ctx, assetStatsQuery(`
+			SELECT assets.*, 
+				(SELECT jsonb_object_agg(key, value) FROM asset_tags WHERE asset_id = assets.id) AS tags 
+			FROM assets 
+			WHERE deleted_at IS NULL 
+			AND `+assetTagFilterCondition, "a.id, a.endpoint, a.tags")+` 
+		`+orderBy(ResourceAssets), assetStatsArgs(assetTagFilterArgs(filter)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-	stats := ScanAssetStats{
-		DiscoveredPortsCount:         portCount,
-		LastDiscovery:                lastDiscoveryTime.Time,
-		HighestVulnerabilitySeverity: Severity(highestSeverity),
+	for rows.Next() {
+		var asset ScanAssetWithStats
+		if err = scanAssetStats(rows, &asset.Stats, &asset.ID, &asset.Endpoint, &asset.Tags); err != nil {
+			return err
+		}
+		if err = fn(asset); err != nil {
+			return err
+		}
 	}
-	return &stats, nil
+	return rows.Err()
 }
 
 func (p PostgresScanRepository) GetAssetHistory(ctx context.Context, tx pgx.Tx, assetID string) ([]AssetHistoryEntry, error) {
 	rows, err := tx.Query(ctx, `
-		SELECT * 
-		FROM asset_history
-		WHERE asset_id = $1;
-	`, assetID)
+		SELECT `+assetHistoryColumns+` 
+		FROM asset_history 
+		WHERE asset_id = $1 
+		`+orderBy(ResourceAssetHistory), assetID)
 
 	if err != nil {
 		// return empty list if no identities are found
@@ -578,12 +1400,15 @@ func (p PostgresScanRepository) GetAssetHistory(ctx context.Context, tx pgx.Tx,
 	var entries []AssetHistoryEntry
 	for rows.Next() {
 		var entry AssetHistoryEntry
-		err = rows.Scan(&entry.ID, &entry.AssetID, &entry.Type, &entry.UserID, &entry.Time, &entry.Data)
+		entry, err = readAssetHistoryEntry(rows)
 		if err != nil {
 			return nil, err
 		}
 		entries = append(entries, entry)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return entries, nil
 }
@@ -0,0 +1,560 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTx answers queries with canned results, one result per Query or QueryRow call in order. Methods not needed by
+// the tests panic.
+type fakeTx struct {
+	pgx.Tx
+	results [][][]any
+	// queries is the number of Query and QueryRow calls
+	queries int
+	// statements and args are the SQL and arguments of the Query and QueryRow calls
+	statements []string
+	args       [][]any
+	// execs are the SQL statements of the Exec calls
+	execs []string
+	// rowsErr is the error of the rows returned by Query once they are read
+	rowsErr error
+	// onScan is called for each row scanned from the results of Query
+	onScan func()
+}
+
+func (f *fakeTx) next(sql string, args []any) [][]any {
+	f.queries++
+	f.statements = append(f.statements, sql)
+	f.args = append(f.args, args)
+	if len(f.results) == 0 {
+		return nil
+	}
+	result := f.results[0]
+	f.results = f.results[1:]
+	return result
+}
+
+func (f *fakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return &fakeRows{rows: f.next(sql, args), index: -1, ctx: ctx, err: f.rowsErr, onScan: f.onScan}, nil
+}
+
+func (f *fakeTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	f.execs = append(f.execs, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (f *fakeTx) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	return &fakeRows{rows: f.next(sql, args), index: -1, single: true}
+}
+
+// fakeRows iterates over canned rows. Scan requires exactly one destination per column. Like pgx, iteration stops
+// once the context of the query is canceled, and Err reports why it stopped.
+type fakeRows struct {
+	pgx.Rows
+	rows   [][]any
+	index  int
+	single bool
+	ctx    context.Context
+	err    error
+	onScan func()
+}
+
+func (r *fakeRows) Next() bool {
+	if r.ctx != nil && r.ctx.Err() != nil {
+		return false
+	}
+	r.index++
+	return r.index < len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	if r.single && !r.Next() {
+		return pgx.ErrNoRows
+	}
+	row := r.rows[r.index]
+	if len(dest) != len(row) {
+		return fmt.Errorf("number of field descriptions must equal number of destinations, got %d and %d",
+			len(row), len(dest))
+	}
+	for i, value := range row {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+	if r.onScan != nil {
+		r.onScan()
+	}
+	return nil
+}
+
+func (r *fakeRows) Err() error {
+	if r.ctx != nil && r.ctx.Err() != nil {
+		return r.ctx.Err()
+	}
+	if r.index >= len(r.rows) {
+		return r.err
+	}
+	return nil
+}
+
+func (r *fakeRows) Close() {}
+
+func TestGetScanEmbeddedAssets(t *testing.T) {
+	updatedAt := time.Date(2025, 12, 15, 9, 0, 0, 0, time.UTC)
+	tx := &fakeTx{results: [][][]any{
+		{{"scan", "config", pgtype.Timestamp{}, pgtype.Timestamp{}, ScanStatusRunning, "", updatedAt}},
+		{{"a", "10.0.0.1", 250}, {"b", "10.0.0.2", 250}, {"c", "example.com", 250}},
+	}}
+
+	scan, err := PostgresScanRepository{}.GetScan(context.Background(), tx, "scan")
+	require.NoError(t, err)
+	assert.Equal(t, []ScanAsset{
+		{ID: "a", Endpoint: "10.0.0.1"},
+		{ID: "b", Endpoint: "10.0.0.2"},
+		{ID: "c", Endpoint: "example.com"},
+	}, scan.Assets)
+	// the count covers all assets of the scan, not only the embedded ones
+	assert.Equal(t, 250, scan.AssetCount)
+	assert.Equal(t, updatedAt, scan.UpdatedAt)
+	assert.Equal(t, MaxEmbeddedScanAssets, tx.args[1][0].(pgx.NamedArgs)["limit"])
+}
+
+func TestGetScanWithoutAssets(t *testing.T) {
+	tx := &fakeTx{results: [][][]any{
+		{{"scan", "config", pgtype.Timestamp{}, pgtype.Timestamp{}, ScanStatusQueued, "", time.Time{}}},
+		{},
+	}}
+
+	scan, err := PostgresScanRepository{}.GetScan(context.Background(), tx, "scan")
+	require.NoError(t, err)
+	assert.Equal(t, []ScanAsset{}, scan.Assets)
+	assert.Zero(t, scan.AssetCount)
+}
+
+func TestListScansCanceled(t *testing.T) {
+	results := func() [][][]any {
+		return [][][]any{
+			{
+				{"a", "config", pgtype.Timestamp{}, pgtype.Timestamp{}, ScanStatusComplete, "", time.Time{}},
+				{"b", "config", pgtype.Timestamp{}, pgtype.Timestamp{}, ScanStatusRunning, "", time.Time{}},
+			},
+			{{"asset", "10.0.0.1", 1}},
+			{},
+		}
+	}
+
+	tx := &fakeTx{results: results()}
+	scans, err := PostgresScanRepository{}.ListScans(context.Background(), tx)
+	require.NoError(t, err)
+	assert.Len(t, scans, 2)
+	assert.Equal(t, 3, tx.queries)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tx = &fakeTx{results: results()}
+	_, err = PostgresScanRepository{}.ListScans(ctx, tx)
+	assert.ErrorIs(t, err, context.Canceled)
+	// the assets of the scans are not queried
+	assert.Equal(t, 1, tx.queries)
+
+	// canceled while the scans are read, after the first one
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	tx = &fakeTx{results: results(), onScan: cancel}
+	scans, err = PostgresScanRepository{}.ListScans(ctx, tx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, scans)
+	assert.Equal(t, 1, tx.queries)
+}
+
+func TestListRowsError(t *testing.T) {
+	errConnection := errors.New("connection reset by peer")
+	lists := map[string]func(tx pgx.Tx) (any, error){
+		"assets": func(tx pgx.Tx) (any, error) {
+			return PostgresScanRepository{}.ListScanAssets(context.Background(), tx, AssetFilter{})
+		},
+		"scan configurations": func(tx pgx.Tx) (any, error) {
+			return PostgresScanRepository{}.ListScanConfigurations(context.Background(), tx)
+		},
+		"scans": func(tx pgx.Tx) (any, error) {
+			return PostgresScanRepository{}.ListScans(context.Background(), tx)
+		},
+		"asset history": func(tx pgx.Tx) (any, error) {
+			return PostgresScanRepository{}.GetAssetHistory(context.Background(), tx, "asset")
+		},
+		"agents": func(tx pgx.Tx) (any, error) {
+			return PostgresAgentRepository{}.ListAgents(context.Background(), tx, AgentFilter{})
+		},
+		"users": func(tx pgx.Tx) (any, error) {
+			return PostgresAuthRepository{}.ListUsers(context.Background(), tx)
+		},
+	}
+	for name, list := range lists {
+		t.Run(name, func(t *testing.T) {
+			// the connection breaks before the first row, which looks like an empty result unless rows.Err is checked
+			tx := &fakeTx{results: [][][]any{{}}, rowsErr: errConnection}
+			_, err := list(tx)
+			assert.ErrorIs(t, err, errConnection)
+		})
+	}
+}
+
+func TestListScansByConfig(t *testing.T) {
+	tx := &fakeTx{results: [][][]any{
+		{{"scan", "config", pgtype.Timestamp{}, pgtype.Timestamp{}, ScanStatusComplete, "", time.Time{}}},
+		{{"a", "10.0.0.1", 1}},
+	}}
+
+	scans, err := PostgresScanRepository{}.ListScansByConfig(context.Background(), tx, "config")
+	require.NoError(t, err)
+	require.Len(t, scans, 1)
+	assert.Equal(t, "config", scans[0].ScanConfigurationID)
+	assert.Equal(t, []ScanAsset{{ID: "a", Endpoint: "10.0.0.1"}}, scans[0].Assets)
+}
+
+func TestListScansByStatus(t *testing.T) {
+	tx := &fakeTx{results: [][][]any{
+		{{"scan", "config", pgtype.Timestamp{}, pgtype.Timestamp{}, ScanStatusRunning, "", time.Time{}}},
+		{{"a", "10.0.0.1", 1}},
+	}}
+
+	scans, err := PostgresScanRepository{}.ListScansByStatus(context.Background(), tx, ScanStatusQueued, ScanStatusRunning)
+	require.NoError(t, err)
+	require.Len(t, scans, 1)
+	assert.Equal(t, ScanStatusRunning, scans[0].Status)
+	assert.Equal(t, 1, scans[0].AssetCount)
+}
+
+func TestListAssetsOfScan(t *testing.T) {
+	tx := &fakeTx{results: [][][]any{
+		{{true}},
+		{{"b", "10.0.0.2"}, {"c", "example.com"}},
+	}}
+
+	assets, err := PostgresScanRepository{}.ListAssetsOfScan(context.Background(), tx, "scan", 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []ScanAsset{{ID: "b", Endpoint: "10.0.0.2"}, {ID: "c", Endpoint: "example.com"}}, assets)
+
+	_, err = PostgresScanRepository{}.ListAssetsOfScan(context.Background(), &fakeTx{results: [][][]any{{{false}}}},
+		"unknown", 2, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestEachFinding(t *testing.T) {
+	seen := time.Date(2025, 12, 1, 8, 0, 0, 0, time.UTC)
+	finding := func(id, assetID, endpoint string) []any {
+		return []any{id, assetID, seen, FindingTypePort, map[string]any{"port": float64(22)}, "hash", "agent", seen,
+			seen, TriageStatusOpen, endpoint}
+	}
+	tx := &fakeTx{results: [][][]any{
+		{finding("f1", "a", "10.0.0.1"), finding("f2", "b", "example.com")},
+	}}
+
+	var endpoints []string
+	err := PostgresScanRepository{}.EachFinding(context.Background(), tx, FindingFilter{},
+		func(finding AssetFindingWithAsset) error {
+			assert.Equal(t, finding.AssetID, finding.Asset.ID)
+			endpoints = append(endpoints, finding.Asset.Endpoint)
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "example.com"}, endpoints)
+
+	// an error of fn stops the iteration
+	tx = &fakeTx{results: [][][]any{
+		{finding("f1", "a", "10.0.0.1"), finding("f2", "b", "example.com")},
+	}}
+	calls := 0
+	errStop := errors.New("stop")
+	err = PostgresScanRepository{}.EachFinding(context.Background(), tx, FindingFilter{},
+		func(AssetFindingWithAsset) error {
+			calls++
+			return errStop
+		})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestPutAssetFindingUpserts(t *testing.T) {
+	seen := time.Date(2025, 12, 1, 8, 0, 0, 0, time.UTC)
+	tx := &fakeTx{results: [][][]any{
+		{{"f1", "a", seen, FindingTypePort, map[string]any{"port": float64(22)}, "hash", "agent", seen, seen,
+			TriageStatusOpen, []string{"s1"}}},
+	}}
+	stored, scanIDs, err := PostgresScanRepository{}.PutAssetFinding(context.Background(), tx, AssetFinding{
+		ID: "f2", AssetID: "a", Type: FindingTypePort, FindingHash: "hash", FirstSeen: seen.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	// the finding with the same hash keeps its ID and first sighting
+	assert.Equal(t, "f1", stored.ID)
+	assert.Equal(t, seen, stored.FirstSeen)
+	assert.Contains(t, tx.statements[0], "ON CONFLICT (asset_id, finding_hash) DO UPDATE")
+	// the running scans of the asset record that they have seen the finding
+	assert.Contains(t, tx.statements[0], "INSERT INTO scan_findings (scan_id, finding_id)")
+	// the active scans of the asset are returned by the same statement, only for inserted findings
+	assert.Contains(t, tx.statements[0], "xmax = 0 AS inserted")
+	assert.Equal(t, []string{"s1"}, scanIDs)
+	assert.Equal(t, 1, tx.queries)
+}
+
+func TestMergeAssetFindings(t *testing.T) {
+	tx := &fakeTx{}
+	err := PostgresScanRepository{}.MergeAssetFindings(context.Background(), tx, map[string]string{"f1": "f2"})
+	require.NoError(t, err)
+	// the scans that have seen a merged finding keep counting the finding it is merged into
+	require.Len(t, tx.execs, 2)
+	assert.Contains(t, tx.execs[0], "INSERT INTO scan_findings (scan_id, finding_id)")
+	assert.Contains(t, tx.execs[1], "DELETE FROM asset_findings")
+}
+
+func TestGetAssetFindingOfDeletedAsset(t *testing.T) {
+	seen := time.Date(2025, 12, 1, 8, 0, 0, 0, time.UTC)
+	tx := &fakeTx{results: [][][]any{
+		{{"f1", "a", seen, FindingTypePort, map[string]any{"port": float64(22)}, "hash", "agent", seen, seen,
+			TriageStatusOpen}},
+	}}
+	finding, err := PostgresScanRepository{}.GetAssetFinding(context.Background(), tx, "f1")
+	require.NoError(t, err)
+	assert.Equal(t, "a", finding.AssetID)
+	// like the lists, the query leaves out findings of deleted assets
+	assert.Contains(t, tx.statements[0], "asset_id IN (SELECT id FROM assets WHERE deleted_at IS NULL)")
+
+	// no row matches the finding of a deleted asset
+	_, err = PostgresScanRepository{}.GetAssetFinding(context.Background(), &fakeTx{}, "f1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGetScanAssetIncludingDeleted(t *testing.T) {
+	tx := &fakeTx{results: [][][]any{{{"a", "example.com", true}}}}
+	asset, err := PostgresScanRepository{}.GetScanAssetIncludingDeleted(context.Background(), tx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, ScanAsset{ID: "a", Endpoint: "example.com", Deleted: true}, *asset)
+	assert.NotContains(t, tx.statements[0], "deleted_at IS NULL")
+
+	_, err = PostgresScanRepository{}.GetScanAssetIncludingDeleted(context.Background(), &fakeTx{}, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCountScanFindings(t *testing.T) {
+	// findings of several scans, one row per scan with findings
+	tx := &fakeTx{results: [][][]any{{{"s1", 3, 1}, {"s2", 2, 0}}}}
+	counts, err := PostgresScanRepository{}.CountScanFindings(context.Background(), tx, []string{"s1", "s2", "s3"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ScanFindingCounts{
+		"s1": {Findings: 3, NewFindings: 1},
+		"s2": {Findings: 2},
+	}, counts)
+
+	// findings are counted by the scans that have seen them, not by the time they have last been seen
+	assert.Contains(t, tx.statements[0], "FROM scan_findings sf")
+	assert.NotContains(t, tx.statements[0], "last_seen")
+	// only the requested scans are aggregated
+	assert.Contains(t, tx.statements[0], "sf.scan_id = ANY(@scan_ids::uuid[])")
+	assert.Equal(t, pgx.NamedArgs{"scan_ids": []string{"s1", "s2", "s3"}}, tx.args[0][0])
+}
+
+func TestLimitCountsLock(t *testing.T) {
+	counts := map[string]func(tx pgx.Tx) (int, error){
+		"assets": func(tx pgx.Tx) (int, error) {
+			return PostgresScanRepository{}.CountScanAssets(context.Background(), tx)
+		},
+		"scan configurations": func(tx pgx.Tx) (int, error) {
+			return PostgresScanRepository{}.CountScanConfigurations(context.Background(), tx)
+		},
+		"agents": func(tx pgx.Tx) (int, error) {
+			return PostgresAgentRepository{}.CountAgents(context.Background(), tx)
+		},
+	}
+	for name, count := range counts {
+		t.Run(name, func(t *testing.T) {
+			tx := &fakeTx{results: [][][]any{{{2}}}}
+			n, err := count(tx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+			// concurrent creations wait for the lock instead of both seeing room for one more
+			assert.Equal(t, []string{"SELECT pg_advisory_xact_lock($1)"}, tx.execs)
+		})
+	}
+}
+
+func TestCountScanners(t *testing.T) {
+	onlineSince := time.Date(2025, 12, 15, 9, 0, 0, 0, time.UTC)
+	tx := &fakeTx{results: [][][]any{{{5, 2, 1, 1, 1}}}}
+	counts, err := PostgresAgentRepository{}.CountScanners(context.Background(), tx, onlineSince)
+	require.NoError(t, err)
+	assert.Equal(t, ScannerCounts{Agents: 5, SYN: 2, Connect: 1, Failing: 1, Unreported: 1}, counts)
+
+	// the agents are aggregated by the database rather than read one by one
+	assert.Equal(t, 1, tx.queries)
+	assert.Contains(t, tx.statements[0], "FILTER")
+	assert.Equal(t, onlineSince, tx.args[0][0].(pgx.NamedArgs)["online_since"])
+}
+
+func TestGetScanNotFound(t *testing.T) {
+	_, err := PostgresScanRepository{}.GetScan(context.Background(), &fakeTx{}, "scan")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGetScanQueuePosition(t *testing.T) {
+	position, err := PostgresScanRepository{}.GetScanQueuePosition(context.Background(),
+		&fakeTx{results: [][][]any{{{3}}}}, "scan")
+	require.NoError(t, err)
+	assert.Equal(t, 3, position)
+
+	// no row matches scans that are not queued
+	_, err = PostgresScanRepository{}.GetScanQueuePosition(context.Background(),
+		&fakeTx{results: [][][]any{{{0}}}}, "scan")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAssetStatsQueryCount(t *testing.T) {
+	for _, count := range []int{1, 10, 100} {
+		ids := make([]string, 0, count)
+		rows := make([][]any, 0, count)
+		for i := range count {
+			id := fmt.Sprintf("asset-%d", i)
+			ids = append(ids, id)
+			rows = append(rows, []any{id, i, pgtype.Timestamp{}, SeverityInfo, pgtype.Timestamp{}, 0, 0, 0, 0, 0, 0,
+				pgtype.Float8{}})
+		}
+		tx := &fakeTx{results: [][][]any{rows}}
+
+		stats, err := PostgresScanRepository{}.ListAssetStats(context.Background(), tx, ids)
+		require.NoError(t, err)
+		assert.Len(t, stats, count)
+		assert.Equal(t, count-1, stats[ids[count-1]].DiscoveredPortsCount)
+		// the stats of all assets are aggregated by a single query
+		assert.Equal(t, 1, tx.queries, "assets: %d", count)
+	}
+
+	tx := &fakeTx{results: [][][]any{{{"asset", 2, pgtype.Timestamp{}, SeverityHigh, pgtype.Timestamp{}, 0, 0, 0, 0, 0,
+		0, pgtype.Float8{}}}}}
+	stats, err := PostgresScanRepository{}.GetAssetStats(context.Background(), tx, "asset")
+	require.NoError(t, err)
+	assert.Equal(t, ScanAssetStats{DiscoveredPortsCount: 2, HighestVulnerabilitySeverity: SeverityHigh}, *stats)
+	assert.Equal(t, 1, tx.queries)
+}
+
+func TestEachAssetWithStats(t *testing.T) {
+	discovery := time.Date(2025, 12, 10, 10, 0, 0, 0, time.UTC)
+	tx := &fakeTx{results: [][][]any{{
+		{"a", "a.example.com", map[string]string{"env": "prod"}, 3, pgtype.Timestamp{Time: discovery, Valid: true},
+			SeverityHigh, pgtype.Timestamp{}, 1, 0, 1, 0, 0, 0, pgtype.Float8{}},
+		{"b", "b.example.com", map[string]string(nil), 0, pgtype.Timestamp{}, SeverityInfo, pgtype.Timestamp{}, 0, 0,
+			0, 0, 0, 0, pgtype.Float8{}},
+	}}}
+
+	var assets []ScanAssetWithStats
+	err := PostgresScanRepository{}.EachAssetWithStats(context.Background(), tx, AssetFilter{},
+		func(asset ScanAssetWithStats) error {
+			assets = append(assets, asset)
+			return nil
+		})
+	require.NoError(t, err)
+	require.Len(t, assets, 2)
+	assert.Equal(t, map[string]string{"env": "prod"}, assets[0].Tags)
+	assert.Equal(t, 3, assets[0].Stats.DiscoveredPortsCount)
+	assert.Equal(t, discovery, assets[0].Stats.LastDiscovery)
+	assert.Equal(t, 1, assets[0].Stats.VulnerabilitySeverities.High)
+	assert.Equal(t, "b.example.com", assets[1].Endpoint)
+	// assets, tags and stats are read by a single query
+	assert.Equal(t, 1, tx.queries)
+
+	// an error of fn stops the iteration
+	tx = &fakeTx{results: [][][]any{{
+		{"a", "a.example.com", map[string]string(nil), 0, pgtype.Timestamp{}, SeverityInfo, pgtype.Timestamp{}, 0, 0,
+			0, 0, 0, 0, pgtype.Float8{}},
+		{"b", "b.example.com", map[string]string(nil), 0, pgtype.Timestamp{}, SeverityInfo, pgtype.Timestamp{}, 0, 0,
+			0, 0, 0, 0, pgtype.Float8{}},
+	}}}
+	calls := 0
+	errStop := errors.New("stop")
+	err = PostgresScanRepository{}.EachAssetWithStats(context.Background(), tx, AssetFilter{},
+		func(ScanAssetWithStats) error {
+			calls++
+			return errStop
+		})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestAssetStatsVulnerabilities(t *testing.T) {
+	// one asset with findings of mixed severities, one without vulnerabilities
+	tx := &fakeTx{results: [][][]any{{
+		{"asset-1", 1, pgtype.Timestamp{}, SeverityCritical, pgtype.Timestamp{}, 7, 1, 2, 0, 3, 1,
+			pgtype.Float8{Float64: 9.8, Valid: true}},
+		{"asset-2", 3, pgtype.Timestamp{}, SeverityInfo, pgtype.Timestamp{}, 0, 0, 0, 0, 0, 0, pgtype.Float8{}},
+	}}}
+
+	stats, err := PostgresScanRepository{}.ListAssetStats(context.Background(), tx, []string{"asset-1", "asset-2"})
+	require.NoError(t, err)
+	assert.Equal(t, 7, stats["asset-1"].VulnerabilityCount)
+	assert.Equal(t, SeverityCounts{Critical: 1, High: 2, Medium: 0, Low: 3, Info: 1},
+		stats["asset-1"].VulnerabilitySeverities)
+	assert.Equal(t, SeverityCritical, stats["asset-1"].HighestVulnerabilitySeverity)
+	assert.Equal(t, pgtype.Float8{Float64: 9.8, Valid: true}, stats["asset-1"].HighestCVSSScore)
+	assert.Zero(t, stats["asset-2"].VulnerabilityCount)
+
+	encoded, err := json.Marshal(stats["asset-1"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"discoveredPortsCount": 1,
+		"lastDiscovery": -62135596800,
+		"highestVulnerabilitySeverity": "critical",
+		"vulnerabilityCount": 7,
+		"vulnerabilitySeverities": {"critical": 1, "high": 2, "medium": 0, "low": 3, "info": 1},
+		"highestCvssScore": 9.8
+	}`, string(encoded))
+
+	encoded, err = json.Marshal(stats["asset-2"])
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "highestCvssScore")
+}
+
+// countingRow records the number of destinations passed to Scan.
+type countingRow struct {
+	destinations int
+}
+
+func (r *countingRow) Scan(dest ...any) error {
+	r.destinations = len(dest)
+	return nil
+}
+
+// countDestinations returns the number of columns read reads from a row.
+func countDestinations[T any](t *testing.T, read func(pgx.Row) (T, error)) int {
+	t.Helper()
+
+	row := &countingRow{}
+	_, err := read(row)
+	require.NoError(t, err)
+	return row.destinations
+}
+
+func TestColumnsMatchReadFunctions(t *testing.T) {
+	for columns, destinations := range map[string]int{
+		assetColumns:        countDestinations(t, readAsset),
+		scanConfigColumns:   countDestinations(t, readScanConfiguration),
+		scanColumns:         countDestinations(t, readScan),
+		assetFindingColumns: countDestinations(t, readAssetFinding),
+		assetHistoryColumns: countDestinations(t, readAssetHistoryEntry),
+		tokenColumns:        countDestinations(t, readToken),
+		userColumns:         countDestinations(t, readUser),
+		agentColumns:        countDestinations(t, readAgent),
+		scanScheduleColumns: countDestinations(t, readScanSchedule),
+		auditEntryColumns:   countDestinations(t, readAuditEntry),
+	} {
+		assert.Len(t, strings.Split(columns, ","), destinations, columns)
+	}
+}
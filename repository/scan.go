@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -13,30 +14,75 @@ import (
 type ScanAsset struct {
 	ID       string `json:"id"`
 	Endpoint string `json:"endpoint"`
+	// Tags are the labels of the asset by key. They are only loaded when listing or getting assets.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Deleted is set for deleted assets, which are only returned by GetScanAssetIncludingDeleted.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// AssetFilter restricts the assets returned by ListScanAssets.
+type AssetFilter struct {
+	// Tags matches assets having all the tags, empty matches all assets.
+	Tags map[string]string
+}
+
+// SeverityCounts are the numbers of vulnerability findings per severity.
+type SeverityCounts struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Info     int `json:"info"`
 }
 
 type ScanAssetStats struct {
 	DiscoveredPortsCount         int       `json:"discoveredPortsCount"`
 	LastDiscovery                time.Time `json:"lastDiscovery"`
 	HighestVulnerabilitySeverity Severity  `json:"highestVulnerabilitySeverity"`
+	// VulnerabilityCount and VulnerabilitySeverities count the vulnerability findings that need attention
+	VulnerabilityCount      int            `json:"vulnerabilityCount"`
+	VulnerabilitySeverities SeverityCounts `json:"vulnerabilitySeverities"`
+	// HighestCVSSScore is the highest CVSS score of these findings, invalid if none has a score
+	HighestCVSSScore pgtype.Float8 `json:"highestCvssScore"`
+	// LastFindingSeen is the most recent LastSeen of the asset's findings, invalid if there are none
+	LastFindingSeen pgtype.Timestamp `json:"lastFindingSeen"`
 }
 
 func (s ScanAssetStats) MarshalJSON() ([]byte, error) {
+	var lastFindingSeen *int64
+	if s.LastFindingSeen.Valid {
+		unix := s.LastFindingSeen.Time.Unix()
+		lastFindingSeen = &unix
+	}
+	var highestCVSSScore *float64
+	if s.HighestCVSSScore.Valid {
+		highestCVSSScore = &s.HighestCVSSScore.Float64
+	}
+
 	return json.Marshal(struct {
-		DiscoveredPortsCount         int      `json:"discoveredPortsCount"`
-		LastDiscovery                int64    `json:"lastDiscovery"`
-		HighestVulnerabilitySeverity Severity `json:"highestVulnerabilitySeverity"`
+		DiscoveredPortsCount         int            `json:"discoveredPortsCount"`
+		LastDiscovery                int64          `json:"lastDiscovery"`
+		HighestVulnerabilitySeverity Severity       `json:"highestVulnerabilitySeverity"`
+		VulnerabilityCount           int            `json:"vulnerabilityCount"`
+		VulnerabilitySeverities      SeverityCounts `json:"vulnerabilitySeverities"`
+		HighestCVSSScore             *float64       `json:"highestCvssScore,omitempty"`
+		LastFindingSeen              *int64         `json:"lastFindingSeen,omitempty"`
 	}{
 		DiscoveredPortsCount:         s.DiscoveredPortsCount,
 		LastDiscovery:                s.LastDiscovery.Unix(),
 		HighestVulnerabilitySeverity: s.HighestVulnerabilitySeverity,
+		VulnerabilityCount:           s.VulnerabilityCount,
+		VulnerabilitySeverities:      s.VulnerabilitySeverities,
+		HighestCVSSScore:             highestCVSSScore,
+		LastFindingSeen:              lastFindingSeen,
 	})
 }
 
 type ScanAssetWithStats struct {
-	ID       string         `json:"id"`
-	Endpoint string         `json:"endpoint"`
-	Stats    ScanAssetStats `json:"stats"`
+	ID       string            `json:"id"`
+	Endpoint string            `json:"endpoint"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Stats    ScanAssetStats    `json:"stats"`
 }
 
 type ScanAssetEventType string
@@ -44,6 +90,8 @@ type ScanAssetEventType string
 const (
 	ScanAssetEventTypeCreated   ScanAssetEventType = "created"
 	ScanAssetEventTypeUpdated   ScanAssetEventType = "updated"
+	ScanAssetEventTypeDeleted   ScanAssetEventType = "deleted"
+	ScanAssetEventTypeRestored  ScanAssetEventType = "restored"
 	ScanAssetEventTypeScanEnded ScanAssetEventType = "scan_finished"
 )
 
@@ -93,6 +141,41 @@ const (
 	SeverityCritical Severity = "critical"
 )
 
+// Severities lists all severities, from the lowest to the highest.
+var Severities = []Severity{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// TriageStatus records the decision of an analyst about a finding.
+type TriageStatus string
+
+const (
+	TriageStatusOpen          TriageStatus = "open"
+	TriageStatusAcknowledged  TriageStatus = "acknowledged"
+	TriageStatusFalsePositive TriageStatus = "false-positive"
+	TriageStatusWontFix       TriageStatus = "wont-fix"
+)
+
+// TriageStatuses lists all triage statuses.
+var TriageStatuses = []TriageStatus{
+	TriageStatusOpen, TriageStatusAcknowledged, TriageStatusFalsePositive, TriageStatusWontFix,
+}
+
+// VisibleTriageStatuses lists the triage statuses of findings that are shown and counted by default. Acknowledged and
+// false positive findings need no further attention.
+var VisibleTriageStatuses = []TriageStatus{TriageStatusOpen, TriageStatusWontFix}
+
+// FindingFilter restricts the findings returned by ListFindings and ListAssetFindings. Zero values do not restrict the
+// result.
+type FindingFilter struct {
+	// TriageStatuses matches findings with any of the statuses, empty matches all findings.
+	TriageStatuses []TriageStatus
+	AssetID        string
+	Type           FindingType
+	// Severity matches vulnerability findings of the severity.
+	Severity Severity
+	Limit    int
+	Offset   int
+}
+
 type AssetFinding struct {
 	ID          string         `json:"id"`
 	AssetID     string         `json:"assetId"`
@@ -101,26 +184,61 @@ type AssetFinding struct {
 	Data        map[string]any `json:"data"`
 	FindingHash string         `json:"findingHash"`
 	AgentID     string         `json:"agentId"`
+	FirstSeen   time.Time      `json:"firstSeen"`
+	LastSeen    time.Time      `json:"lastSeen"`
+	// TriageStatus is kept when the finding is reported again.
+	TriageStatus TriageStatus `json:"triageStatus"`
 }
 
 func (f AssetFinding) MarshalJSON() ([]byte, error) {
 	// marshal with time.Time to unix
 	data := struct {
-		ID          string         `json:"id"`
-		AssetID     string         `json:"assetId"`
-		CreatedAt   int64          `json:"createdAt"`
-		Type        FindingType    `json:"type"`
-		Data        map[string]any `json:"data"`
-		FindingHash string         `json:"findingHash"`
-		AgentID     string         `json:"agentId"`
+		ID           string         `json:"id"`
+		AssetID      string         `json:"assetId"`
+		CreatedAt    int64          `json:"createdAt"`
+		Type         FindingType    `json:"type"`
+		Data         map[string]any `json:"data"`
+		FindingHash  string         `json:"findingHash"`
+		AgentID      string         `json:"agentId"`
+		FirstSeen    int64          `json:"firstSeen"`
+		LastSeen     int64          `json:"lastSeen"`
+		TriageStatus TriageStatus   `json:"triageStatus"`
 	}{
-		ID:          f.ID,
-		AssetID:     f.AssetID,
-		CreatedAt:   f.CreatedAt.Unix(),
-		Type:        f.Type,
-		Data:        f.Data,
-		FindingHash: f.FindingHash,
-		AgentID:     f.AgentID,
+		ID:           f.ID,
+		AssetID:      f.AssetID,
+		CreatedAt:    f.CreatedAt.Unix(),
+		Type:         f.Type,
+		Data:         f.Data,
+		FindingHash:  f.FindingHash,
+		AgentID:      f.AgentID,
+		FirstSeen:    f.FirstSeen.Unix(),
+		LastSeen:     f.LastSeen.Unix(),
+		TriageStatus: f.TriageStatus,
+	}
+
+	return json.Marshal(data)
+}
+
+// AssetFindingWithAsset is an AssetFinding with the asset it was found on.
+type AssetFindingWithAsset struct {
+	AssetFinding
+	Asset ScanAsset `json:"asset"`
+}
+
+func (f AssetFindingWithAsset) MarshalJSON() ([]byte, error) {
+	// the embedded finding has its own MarshalJSON, extend its output with the asset
+	finding, err := json.Marshal(f.AssetFinding)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]json.RawMessage
+	if err = json.Unmarshal(finding, &data); err != nil {
+		return nil, err
+	}
+	data["asset"], err = json.Marshal(f.Asset)
+	if err != nil {
+		return nil, err
 	}
 
 	return json.Marshal(data)
@@ -128,10 +246,11 @@ func (f AssetFinding) MarshalJSON() ([]byte, error) {
 
 // ScanConfiguration defines a scan configuration applied to a scan
 type ScanConfiguration struct {
-	ID     string   `json:"id"`
-	Name   string   `json:"name"`
-	Type   ScanType `json:"type"`
-	Engine string   `json:"engine"`
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Type    ScanType       `json:"type"`
+	Engine  string         `json:"engine"`
+	Options map[string]any `json:"options"`
 }
 
 type ScanStatus string
@@ -144,6 +263,26 @@ const (
 	ScanStatusCancelled ScanStatus = "cancelled"
 )
 
+// IsTerminal reports whether a scan with this status has finished.
+func (s ScanStatus) IsTerminal() bool {
+	switch s {
+	case ScanStatusComplete, ScanStatusFailed, ScanStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// scanStatusTransitions lists the statuses a scan may move to from each non-terminal status.
+var scanStatusTransitions = map[ScanStatus][]ScanStatus{
+	ScanStatusQueued:  {ScanStatusRunning, ScanStatusFailed, ScanStatusCancelled},
+	ScanStatusRunning: {ScanStatusComplete, ScanStatusFailed, ScanStatusCancelled},
+}
+
+// CanTransitionTo reports whether a scan with this status may move to next. Terminal statuses are final.
+func (s ScanStatus) CanTransitionTo(next ScanStatus) bool {
+	return slices.Contains(scanStatusTransitions[s], next)
+}
+
 type ScanType string
 
 const (
@@ -152,6 +291,10 @@ const (
 	ScanTypeCombined      ScanType = "discovery+vuln"
 )
 
+// MaxEmbeddedScanAssets is the maximum number of assets embedded in the scans returned by GetScan and the scan lists,
+// all assets are listed with ListAssetsOfScan.
+const MaxEmbeddedScanAssets = 100
+
 // ScanExecution represents metadata and status details for a single scan execution.
 type ScanExecution struct {
 	ID                  string           `json:"id"`
@@ -160,6 +303,27 @@ type ScanExecution struct {
 	StartTime           pgtype.Timestamp `json:"startTime"`
 	EndTime             pgtype.Timestamp `json:"endTime"`
 	Assets              []ScanAsset      `json:"assets"`
+	// AssetCount is the number of assets of the scan. Scans read from the repository embed only the first
+	// MaxEmbeddedScanAssets of them in Assets.
+	AssetCount int `json:"assetCount"`
+	// Error describes why the scan failed, it is empty unless Status is ScanStatusFailed.
+	Error string `json:"error"`
+	// UpdatedAt is the time the scan was last changed.
+	UpdatedAt time.Time `json:"updatedAt"`
+	// FindingCounts is only set if requested when listing scans.
+	FindingCounts *ScanFindingCounts `json:"findingCounts,omitempty"`
+	// QueuePosition is the estimated 1-based position of a queued scan among all queued scans, 0 once it is no longer
+	// queued. It is only set when getting a single scan.
+	QueuePosition int `json:"queuePosition,omitempty"`
+}
+
+// ScanFindingCounts summarizes the findings of a scan. Findings are attributed to a scan if they have been reported
+// for one of its assets while the scan was running. Later scans reporting the same finding do not change the counts.
+type ScanFindingCounts struct {
+	// Findings is the number of findings seen during the scan.
+	Findings int `json:"findings"`
+	// NewFindings is the number of findings first seen during the scan.
+	NewFindings int `json:"newFindings"`
 }
 
 func (s ScanExecution) MarshalJSON() ([]byte, error) {
@@ -173,13 +337,23 @@ func (s ScanExecution) MarshalJSON() ([]byte, error) {
 		endTime = s.EndTime.Time.Unix()
 	}
 
+	updatedAt := int64(0)
+	if !s.UpdatedAt.IsZero() {
+		updatedAt = s.UpdatedAt.Unix()
+	}
+
 	data := struct {
-		ID                  string      `json:"id"`
-		ScanConfigurationID string      `json:"scanConfigurationId"`
-		Status              ScanStatus  `json:"status"`
-		StartTime           int64       `json:"startTime"`
-		EndTime             int64       `json:"endTime"`
-		Assets              []ScanAsset `json:"assets"`
+		ID                  string             `json:"id"`
+		ScanConfigurationID string             `json:"scanConfigurationId"`
+		Status              ScanStatus         `json:"status"`
+		StartTime           int64              `json:"startTime"`
+		EndTime             int64              `json:"endTime"`
+		Assets              []ScanAsset        `json:"assets"`
+		AssetCount          int                `json:"assetCount"`
+		Error               string             `json:"error,omitempty"`
+		UpdatedAt           int64              `json:"updatedAt"`
+		FindingCounts       *ScanFindingCounts `json:"findingCounts,omitempty"`
+		QueuePosition       int                `json:"queuePosition,omitempty"`
 	}{
 		ID:                  s.ID,
 		ScanConfigurationID: s.ScanConfigurationID,
@@ -187,6 +361,11 @@ func (s ScanExecution) MarshalJSON() ([]byte, error) {
 		StartTime:           startTime,
 		EndTime:             endTime,
 		Assets:              s.Assets,
+		AssetCount:          s.AssetCount,
+		Error:               s.Error,
+		UpdatedAt:           updatedAt,
+		FindingCounts:       s.FindingCounts,
+		QueuePosition:       s.QueuePosition,
 	}
 
 	return json.Marshal(data)
@@ -194,22 +373,65 @@ func (s ScanExecution) MarshalJSON() ([]byte, error) {
 
 // ScanAssetRepository defines an interface for managing and interacting with scan asset data in a repository.
 type ScanAssetRepository interface {
-	// ListScanAssets retrieves all scan assets from the repository.
-	ListScanAssets(ctx context.Context, tx pgx.Tx) ([]ScanAsset, error)
+	// ListScanAssets retrieves the scan assets matching filter from the repository.
+	ListScanAssets(ctx context.Context, tx pgx.Tx, filter AssetFilter) ([]ScanAsset, error)
 	// GetScanAsset fetches a specific scan asset given its unique identifier.
 	GetScanAsset(ctx context.Context, tx pgx.Tx, id string) (*ScanAsset, error)
+	// GetScanAssetIncludingDeleted is GetScanAsset that also returns deleted assets, flagged with ScanAsset.Deleted.
+	GetScanAssetIncludingDeleted(ctx context.Context, tx pgx.Tx, id string) (*ScanAsset, error)
+	// GetScanAssetByEndpoint retrieves the scan asset with the given endpoint.
+	GetScanAssetByEndpoint(ctx context.Context, tx pgx.Tx, endpoint string) (*ScanAsset, error)
+	// CountScanAssets returns the number of scan assets. It locks until the end of tx, so concurrent callers creating
+	// assets based on the count wait for each other.
+	CountScanAssets(ctx context.Context, tx pgx.Tx) (int, error)
 	// CreateScanAsset adds a new scan asset to the repository.
 	CreateScanAsset(ctx context.Context, tx pgx.Tx, scanAsset ScanAsset) error
 	// UpdateScanAsset modifies an existing scan asset in the repository.
 	UpdateScanAsset(ctx context.Context, tx pgx.Tx, scanAsset ScanAsset) error
-	// DeleteScanAsset removes a scan asset from the repository using its unique identifier.
+	// DeleteScanAsset marks a scan asset as deleted. Deleted assets are left out by all other methods except
+	// PurgeScanAsset and RestoreScanAsset, their endpoints can be used by new assets.
 	DeleteScanAsset(ctx context.Context, tx pgx.Tx, id string) error
-
-	PutAssetFinding(ctx context.Context, tx pgx.Tx, result AssetFinding) error
+	// PurgeScanAsset permanently removes a scan asset, deleted or not, with its findings, history and tags.
+	PurgeScanAsset(ctx context.Context, tx pgx.Tx, id string) (*ScanAsset, error)
+	// RestoreScanAsset undoes DeleteScanAsset. ErrNotFound is returned if the asset is not deleted and
+	// ErrUniqueViolation if its endpoint has been used by another asset meanwhile.
+	RestoreScanAsset(ctx context.Context, tx pgx.Tx, id string) (*ScanAsset, error)
+	// GetAssetTags returns the tags of the given assets by asset ID. Assets without tags are left out.
+	GetAssetTags(ctx context.Context, tx pgx.Tx, assetIDs []string) (map[string]map[string]string, error)
+	// SetAssetTags replaces the tags of an asset.
+	SetAssetTags(ctx context.Context, tx pgx.Tx, assetID string, tags map[string]string) error
+
+	// PutAssetFinding stores a finding. If the asset already has a finding with the same hash, that finding is updated
+	// instead and keeps its ID, CreatedAt and FirstSeen. The finding is recorded as seen by the running scans of the
+	// asset. If the finding is new, the IDs of the queued and running scans of the asset are returned with it.
+	PutAssetFinding(ctx context.Context, tx pgx.Tx, result AssetFinding) (*AssetFinding, []string, error)
+	// GetAssetFinding returns a finding by its ID. Findings of deleted assets are not found.
 	GetAssetFinding(ctx context.Context, tx pgx.Tx, id string) (*AssetFinding, error)
-	ListAssetFindings(ctx context.Context, tx pgx.Tx, assetID string) ([]AssetFinding, error)
+	// GetAssetFindingIncludingDeleted is GetAssetFinding that also returns findings of deleted assets.
+	GetAssetFindingIncludingDeleted(ctx context.Context, tx pgx.Tx, id string) (*AssetFinding, error)
+	// ListFindings returns the findings of all assets matching filter, the most recently seen first.
+	ListFindings(ctx context.Context, tx pgx.Tx, filter FindingFilter) ([]AssetFinding, error)
+	// EachFinding calls fn for each finding matching filter, in the order of ListFindings, as the rows are read. The
+	// asset of a finding only has its ID and endpoint set. Iteration stops at the first error returned by fn.
+	EachFinding(ctx context.Context, tx pgx.Tx, filter FindingFilter, fn func(AssetFindingWithAsset) error) error
+	ListAssetFindings(ctx context.Context, tx pgx.Tx, assetID string, filter FindingFilter) ([]AssetFinding, error)
+	// UpdateAssetFindingTriage sets the triage status of a finding.
+	UpdateAssetFindingTriage(ctx context.Context, tx pgx.Tx, id string, status TriageStatus) error
+	// ListFindingAssetIDs returns up to limit IDs of assets with findings, ordered by ID and starting after afterID.
+	ListFindingAssetIDs(ctx context.Context, tx pgx.Tx, afterID string, limit int) ([]string, error)
+	// UpdateAssetFindings stores hash, data, agent, seen times and triage status of existing findings. Findings may swap
+	// their hashes.
+	UpdateAssetFindings(ctx context.Context, tx pgx.Tx, findings []AssetFinding) error
+	// MergeAssetFindings deletes the findings with the IDs of the keys of merged. The scans that have seen them are
+	// recorded as having seen the finding of the value instead.
+	MergeAssetFindings(ctx context.Context, tx pgx.Tx, merged map[string]string) error
 
 	GetAssetStats(ctx context.Context, tx pgx.Tx, assetID string) (*ScanAssetStats, error)
+	// ListAssetStats returns the stats of the given assets by asset ID with a single query.
+	ListAssetStats(ctx context.Context, tx pgx.Tx, assetIDs []string) (map[string]ScanAssetStats, error)
+	// EachAssetWithStats calls fn for each asset matching filter with its tags and stats, in the order of
+	// ListScanAssets, as the rows are read. Iteration stops at the first error returned by fn.
+	EachAssetWithStats(ctx context.Context, tx pgx.Tx, filter AssetFilter, fn func(ScanAssetWithStats) error) error
 
 	GetAssetHistory(ctx context.Context, tx pgx.Tx, assetID string) ([]AssetHistoryEntry, error)
 	AddAssetHistoryEntry(ctx context.Context, tx pgx.Tx, entry AssetHistoryEntry) error
@@ -221,24 +443,55 @@ type ScanConfigurationRepository interface {
 	ListScanConfigurations(ctx context.Context, tx pgx.Tx) ([]ScanConfiguration, error)
 	// GetScanConfiguration fetches a scan configuration by its unique identifier.
 	GetScanConfiguration(ctx context.Context, tx pgx.Tx, id string) (*ScanConfiguration, error)
+	// CountScanConfigurations returns the number of scan configurations. It locks until the end of tx, so concurrent
+	// callers creating scan configurations based on the count wait for each other.
+	CountScanConfigurations(ctx context.Context, tx pgx.Tx) (int, error)
 	// CreateScanConfiguration adds a new scan configuration to the repository.
 	CreateScanConfiguration(ctx context.Context, tx pgx.Tx, scanConfiguration ScanConfiguration) error
 	// UpdateScanConfiguration updates an existing scan configuration. Does not update the assets associated with the scan configuration.
 	UpdateScanConfiguration(ctx context.Context, tx pgx.Tx, scanConfiguration ScanConfiguration) error
 	// DeleteScanConfiguration removes a scan configuration using its unique identifier.
 	DeleteScanConfiguration(ctx context.Context, tx pgx.Tx, id string) error
+	// GetScanConfigurationAssets retrieves the default assets scanned by a scan configuration.
+	GetScanConfigurationAssets(ctx context.Context, tx pgx.Tx, id string) ([]ScanAsset, error)
+	// SetScanConfigurationAssets replaces the default assets scanned by a scan configuration.
+	SetScanConfigurationAssets(ctx context.Context, tx pgx.Tx, id string, assetIDs []string) error
 }
 
 // ScanExecutionRepository defines methods for managing scan executions and their metadata in a repository.
 type ScanExecutionRepository interface {
 	// ListScans retrieves all scan executions from the repository.
 	ListScans(ctx context.Context, tx pgx.Tx) ([]ScanExecution, error)
+	// ListScansByConfig retrieves the scan executions of a scan configuration, ordered like ListScans.
+	ListScansByConfig(ctx context.Context, tx pgx.Tx, configID string) ([]ScanExecution, error)
+	// ListScansByStatus retrieves the scan executions having one of the statuses, ordered like ListScans.
+	ListScansByStatus(ctx context.Context, tx pgx.Tx, statuses ...ScanStatus) ([]ScanExecution, error)
 	// GetScan fetches a specific scan execution given its unique identifier.
 	GetScan(ctx context.Context, tx pgx.Tx, id string) (*ScanExecution, error)
+	// ListAssetsOfScan returns a page of the assets of a scan, ordered like ListScanAssets. A limit of zero returns all
+	// assets. ErrNotFound is returned if the scan does not exist.
+	ListAssetsOfScan(ctx context.Context, tx pgx.Tx, scanID string, limit int, offset int) ([]ScanAsset, error)
+	// CountScanFindings returns the counts of the findings seen by the scans with scanIDs by scan id. Scans without
+	// findings are omitted.
+	CountScanFindings(ctx context.Context, tx pgx.Tx, scanIDs []string) (map[string]ScanFindingCounts, error)
 	// CreateScan adds a new scan execution to the repository.
 	CreateScan(ctx context.Context, tx pgx.Tx, scanRun ScanExecution) error
 	// UpdateScan modifies an existing scan execution in the repository.
 	UpdateScan(ctx context.Context, tx pgx.Tx, scanRun ScanExecution) error
+	// DeleteScan removes a scan execution and its asset associations from the repository.
+	DeleteScan(ctx context.Context, tx pgx.Tx, id string) error
+	// CountActiveScans returns the number of queued or running scans of a scan configuration.
+	CountActiveScans(ctx context.Context, tx pgx.Tx, scanConfigID string) (int, error)
+	// CountRunningScans returns the number of running scans. It locks until the end of tx, so concurrent callers
+	// starting scans based on the count wait for each other.
+	CountRunningScans(ctx context.Context, tx pgx.Tx) (int, error)
+	// GetScanQueuePosition returns the 1-based position of a queued scan among all queued scans, ordered by the time
+	// they were last changed. Agents pick up queued scans in no defined order, so the position is an estimate.
+	// ErrNotFound is returned if the scan does not exist or is not queued.
+	GetScanQueuePosition(ctx context.Context, tx pgx.Tx, id string) (int, error)
+	// ListOverdueScanIDs returns the IDs of running scans started before startedBefore. Running scans without a start
+	// time are not returned.
+	ListOverdueScanIDs(ctx context.Context, tx pgx.Tx, startedBefore time.Time) ([]string, error)
 }
 
 // ScanRepository combines functionality for managing scan asset data and scan configurations in a repository.
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanStatusCanTransitionTo(t *testing.T) {
+	assert.True(t, ScanStatusQueued.CanTransitionTo(ScanStatusRunning))
+	assert.True(t, ScanStatusQueued.CanTransitionTo(ScanStatusCancelled))
+	assert.True(t, ScanStatusRunning.CanTransitionTo(ScanStatusComplete))
+	assert.True(t, ScanStatusRunning.CanTransitionTo(ScanStatusFailed))
+
+	assert.False(t, ScanStatusQueued.CanTransitionTo(ScanStatusQueued))
+	assert.False(t, ScanStatusRunning.CanTransitionTo(ScanStatusQueued))
+	for _, terminal := range []ScanStatus{ScanStatusComplete, ScanStatusFailed, ScanStatusCancelled} {
+		assert.False(t, terminal.CanTransitionTo(ScanStatusRunning), terminal)
+		assert.False(t, terminal.CanTransitionTo(ScanStatusFailed), terminal)
+	}
+}
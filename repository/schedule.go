@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"cortex/logging"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ScanSchedule runs a scan configuration whenever its cron expression matches.
+type ScanSchedule struct {
+	ID                  string `json:"id"`
+	ScanConfigurationID string `json:"scanConfigurationId"`
+	// AssetIDs are the assets scanned, empty scans the default assets of the scan configuration.
+	AssetIDs  []string         `json:"assetIds"`
+	Cron      string           `json:"cron"`
+	NextRunAt time.Time        `json:"nextRunAt"`
+	LastRunAt pgtype.Timestamp `json:"lastRunAt"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+func (s ScanSchedule) MarshalJSON() ([]byte, error) {
+	var lastRunAt *int64
+	if s.LastRunAt.Valid {
+		unix := s.LastRunAt.Time.Unix()
+		lastRunAt = &unix
+	}
+	assetIDs := s.AssetIDs
+	if assetIDs == nil {
+		assetIDs = []string{}
+	}
+
+	return json.Marshal(struct {
+		ID                  string   `json:"id"`
+		ScanConfigurationID string   `json:"scanConfigurationId"`
+		AssetIDs            []string `json:"assetIds"`
+		Cron                string   `json:"cron"`
+		NextRunAt           int64    `json:"nextRunAt"`
+		LastRunAt           *int64   `json:"lastRunAt,omitempty"`
+		CreatedAt           int64    `json:"createdAt"`
+	}{
+		ID:                  s.ID,
+		ScanConfigurationID: s.ScanConfigurationID,
+		AssetIDs:            assetIDs,
+		Cron:                s.Cron,
+		NextRunAt:           s.NextRunAt.Unix(),
+		LastRunAt:           lastRunAt,
+		CreatedAt:           s.CreatedAt.Unix(),
+	})
+}
+
+type ScanScheduleRepository interface {
+	ListScanSchedules(ctx context.Context, tx pgx.Tx) ([]ScanSchedule, error)
+	GetScanSchedule(ctx context.Context, tx pgx.Tx, id string) (*ScanSchedule, error)
+	CreateScanSchedule(ctx context.Context, tx pgx.Tx, schedule ScanSchedule) error
+	// UpdateScanSchedule stores the scan configuration, assets, cron expression and next run of a schedule.
+	UpdateScanSchedule(ctx context.Context, tx pgx.Tx, schedule ScanSchedule) error
+	DeleteScanSchedule(ctx context.Context, tx pgx.Tx, id string) error
+	// ClaimDueScanSchedules locks and returns up to limit schedules whose next run is not after now. Schedules locked
+	// by another transaction are skipped, so concurrent evaluations never claim the same schedule.
+	ClaimDueScanSchedules(ctx context.Context, tx pgx.Tx, now time.Time, limit int) ([]ScanSchedule, error)
+	// SetScanScheduleRun records that a schedule ran at runAt and sets its next run.
+	SetScanScheduleRun(ctx context.Context, tx pgx.Tx, id string, runAt time.Time, nextRunAt time.Time) error
+}
+
+// scanScheduleColumns are the columns read by readScanSchedule, in the order they are read.
+const scanScheduleColumns = "id, scan_config_id, asset_ids, cron, next_run_at, last_run_at, created_at"
+
+func readScanSchedule(row pgx.Row) (ScanSchedule, error) {
+	var schedule ScanSchedule
+	err := row.Scan(&schedule.ID, &schedule.ScanConfigurationID, &schedule.AssetIDs, &schedule.Cron,
+		&schedule.NextRunAt, &schedule.LastRunAt, &schedule.CreatedAt)
+	return schedule, err
+}
+
+type PostgresScanScheduleRepository struct {
+	logger *slog.Logger
+}
+
+func (r PostgresScanScheduleRepository) ListScanSchedules(ctx context.Context, tx pgx.Tx) ([]ScanSchedule, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT `+scanScheduleColumns+`
+		FROM scan_schedules
+		`+orderBy(ResourceSchedules))
+	if err != nil {
+		return nil, err
+	}
+	return readScanSchedules(rows)
+}
+
+func (r PostgresScanScheduleRepository) GetScanSchedule(ctx context.Context, tx pgx.Tx, id string) (*ScanSchedule, error) {
+	row := tx.QueryRow(ctx, `
+		SELECT `+scanScheduleColumns+`
+		FROM scan_schedules
+		WHERE id = $1`, id)
+
+	schedule, err := readScanSchedule(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r PostgresScanScheduleRepository) CreateScanSchedule(ctx context.Context, tx pgx.Tx, schedule ScanSchedule) error {
+	args := pgx.NamedArgs{
+		"id":             schedule.ID,
+		"scan_config_id": schedule.ScanConfigurationID,
+		"asset_ids":      scheduleAssetIDs(schedule),
+		"cron":           schedule.Cron,
+		"next_run_at":    schedule.NextRunAt,
+		"created_at":     schedule.CreatedAt,
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO scan_schedules (id, scan_config_id, asset_ids, cron, next_run_at, created_at)
+		VALUES(@id, @scan_config_id, @asset_ids::uuid[], @cron, @next_run_at, @created_at)`, args)
+	return err
+}
+
+func (r PostgresScanScheduleRepository) UpdateScanSchedule(ctx context.Context, tx pgx.Tx, schedule ScanSchedule) error {
+	args := pgx.NamedArgs{
+		"id":             schedule.ID,
+		"scan_config_id": schedule.ScanConfigurationID,
+		"asset_ids":      scheduleAssetIDs(schedule),
+		"cron":           schedule.Cron,
+		"next_run_at":    schedule.NextRunAt,
+	}
+
+	row := tx.QueryRow(ctx, `
+		UPDATE scan_schedules
+		SET scan_config_id = @scan_config_id, asset_ids = @asset_ids::uuid[], cron = @cron,
+		    next_run_at = @next_run_at
+		WHERE id = @id
+		RETURNING `+scanScheduleColumns, args)
+
+	_, err := readScanSchedule(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r PostgresScanScheduleRepository) DeleteScanSchedule(ctx context.Context, tx pgx.Tx, id string) error {
+	row := tx.QueryRow(ctx, `
+		DELETE FROM scan_schedules
+		WHERE id = $1
+		RETURNING `+scanScheduleColumns, id)
+
+	_, err := readScanSchedule(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r PostgresScanScheduleRepository) ClaimDueScanSchedules(ctx context.Context, tx pgx.Tx, now time.Time, limit int) ([]ScanSchedule, error) {
+	args := pgx.NamedArgs{
+		"now":   now,
+		"limit": limit,
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+scanScheduleColumns+`
+		FROM scan_schedules
+		WHERE next_run_at <= @now
+		ORDER BY next_run_at, id
+		LIMIT @limit
+		FOR UPDATE SKIP LOCKED`, args)
+	if err != nil {
+		return nil, err
+	}
+	return readScanSchedules(rows)
+}
+
+func (r PostgresScanScheduleRepository) SetScanScheduleRun(ctx context.Context, tx pgx.Tx, id string, runAt time.Time, nextRunAt time.Time) error {
+	args := pgx.NamedArgs{
+		"id":          id,
+		"last_run_at": runAt,
+		"next_run_at": nextRunAt,
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE scan_schedules
+		SET last_run_at = @last_run_at, next_run_at = @next_run_at
+		WHERE id = @id`, args)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func readScanSchedules(rows pgx.Rows) ([]ScanSchedule, error) {
+	defer rows.Close()
+
+	schedules := []ScanSchedule{}
+	for rows.Next() {
+		schedule, err := readScanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// scheduleAssetIDs returns the asset IDs of a schedule, never nil as the column is not nullable.
+func scheduleAssetIDs(schedule ScanSchedule) []string {
+	if schedule.AssetIDs == nil {
+		return []string{}
+	}
+	return schedule.AssetIDs
+}
+
+func NewPostgresScanScheduleRepository() *PostgresScanScheduleRepository {
+	return &PostgresScanScheduleRepository{
+		logger: logging.GetLogger(logging.DataAccess),
+	}
+}
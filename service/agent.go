@@ -10,27 +10,94 @@ import (
 	"log/slog"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
 )
 
 type AgentService interface {
-	ListAgents(ctx context.Context) ([]repository.Agent, error)
+	ListAgents(ctx context.Context, opts ListAgentsOptions) ([]repository.Agent, error)
 	GetAgent(ctx context.Context, id string) (*repository.Agent, error)
 	CreateAgent(ctx context.Context, name string) (*repository.Agent, string, error)
 	CreateAgentWithToken(ctx context.Context, tokenPlain string, name string) (*repository.Agent, error)
 	UpdateAgent(ctx context.Context, id string, name string) (*repository.Agent, error)
 	DeleteAgent(ctx context.Context, id string) (*repository.Agent, error)
+	// RotateToken replaces the token of an agent and returns the new token. The agent keeps its ID, the previous token
+	// is rejected from now on.
+	RotateToken(ctx context.Context, id string) (*repository.Agent, string, error)
+	// Heartbeat records that the agent is alive and returns the server time. A non-nil scanner replaces the scanner
+	// status stored for the agent.
+	Heartbeat(ctx context.Context, id string, scanner *repository.ScannerStatus) (time.Time, error)
+	// ScannerHealth summarizes the scanner status of the online agents.
+	ScannerHealth(ctx context.Context) (*ScannerHealth, error)
+}
+
+// ScannerHealthStatus rates whether scans can be executed by the online agents.
+type ScannerHealthStatus string
+
+const (
+	// ScannerHealthOK means no online agent reported a failing scan runner.
+	ScannerHealthOK ScannerHealthStatus = "ok"
+	// ScannerHealthDegraded means some, but not all, online agents failed to initialize their scan runner.
+	ScannerHealthDegraded ScannerHealthStatus = "degraded"
+	// ScannerHealthUnavailable means no agent is online or all online agents reported a failing scan runner.
+	ScannerHealthUnavailable ScannerHealthStatus = "unavailable"
+	// ScannerHealthUnknown means the scanner status of the agents could not be read.
+	ScannerHealthUnknown ScannerHealthStatus = "unknown"
+)
+
+// ScannerHealth counts the online agents by the scanner status they reported with their last heartbeat.
+type ScannerHealth struct {
+	Status       ScannerHealthStatus `json:"status"`
+	OnlineAgents int                 `json:"onlineAgents"`
+	// SYNCapable agents can perform SYN scans.
+	SYNCapable int `json:"synCapable"`
+	// ConnectOnly agents lack the privileges for SYN scans and fall back to connect scans.
+	ConnectOnly int `json:"connectOnly"`
+	// Failing agents reported that their scan runner failed to initialize.
+	Failing int `json:"failing"`
+	// Unreported agents have not reported a scanner status yet.
+	Unreported int `json:"unreported"`
+}
+
+type AgentServiceOptions struct {
+	// OnlineWindow is the time since the last request of an agent during which it is considered online.
+	OnlineWindow time.Duration
+	// MaxAgents limits the number of agents that can be created through the API. Zero means unlimited.
+	MaxAgents int
+	// TokenFormat is the format of generated agent tokens and the largest format accepted. The zero value means
+	// DefaultTokenFormat.
+	TokenFormat TokenFormat
+	// Audit records the changes made through the service, nil does not record them.
+	Audit AuditService
+}
+
+// ListAgentsOptions filters and pages the agents returned by ListAgents.
+type ListAgentsOptions struct {
+	// Search matches agents whose name contains the value, ignoring case.
+	Search string
+	// Active matches agents that are (true) or are not (false) online, nil matches all agents.
+	Active *bool
+	// Limit is the maximum number of agents returned, zero returns all agents.
+	Limit  int
+	Offset int
 }
 
 type agentService struct {
-	logger *slog.Logger
-	repo   repository.AgentRepository
-	pool   *pgxpool.Pool
+	logger       *slog.Logger
+	repo         repository.AgentRepository
+	pool         *Pool
+	onlineWindow time.Duration
+	maxAgents    int
+	tokenFormat  TokenFormat
+	audit        AuditService
+}
+
+func (s agentService) withOnline(agent *repository.Agent) {
+	agent.Online = agent.LastSeenAt.Valid && time.Since(agent.LastSeenAt.Time) <= s.onlineWindow
 }
 
 func (s agentService) CreateAgentWithToken(ctx context.Context, tokenPlain string, name string) (*repository.Agent, error) {
 	// Parse the token to extract the secret part for hashing
-	tokenComponents, err := parseTokenString(tokenPlain)
+	tokenComponents, err := parseTokenString(tokenPlain, s.tokenFormat)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to parse token string", logging.FieldError, err)
 		return nil, fmt.Errorf("invalid token format: %w", err)
@@ -38,7 +105,7 @@ func (s agentService) CreateAgentWithToken(ctx context.Context, tokenPlain strin
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("creating agent with token id %s", tokenComponents.id))
 
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +119,7 @@ func (s agentService) CreateAgentWithToken(ctx context.Context, tokenPlain strin
 	}()
 
 	// Check if agent with this token ID already exists
-	existingAgent, err := s.repo.GetAgent(ctx, tx, tokenComponents.id)
+	existingAgent, err := s.repo.GetAgentByTokenID(ctx, tx, tokenComponents.id)
 	if err != nil && !errors.Is(err, repository.ErrNotFound) {
 		s.logger.ErrorContext(ctx, "failed to check for existing agent", logging.FieldError, err)
 		return nil, err
@@ -60,7 +127,7 @@ func (s agentService) CreateAgentWithToken(ctx context.Context, tokenPlain strin
 
 	// If agent exists, return it
 	if existingAgent != nil {
-		s.logger.DebugContext(ctx, fmt.Sprintf("agent with id %s already exists, returning existing agent", tokenComponents.id))
+		s.logger.DebugContext(ctx, fmt.Sprintf("agent with token id %s already exists, returning existing agent", tokenComponents.id))
 		return existingAgent, nil
 	}
 
@@ -73,7 +140,8 @@ func (s agentService) CreateAgentWithToken(ctx context.Context, tokenPlain strin
 
 	// Create new agent
 	agent := repository.Agent{
-		ID:        tokenComponents.id,
+		ID:        uuid.New().String(),
+		TokenID:   tokenComponents.id,
 		Name:      name,
 		TokenHash: hash,
 		CreatedAt: time.Now(),
@@ -85,12 +153,21 @@ func (s agentService) CreateAgentWithToken(ctx context.Context, tokenPlain strin
 		return nil, err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionCreate,
+		TargetType: repository.AuditTargetAgent,
+		TargetID:   agent.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s.logger.InfoContext(ctx, fmt.Sprintf("created agent %s with id %s", name, agent.ID))
 	return &agent, nil
 }
 
-func (s agentService) ListAgents(ctx context.Context) ([]repository.Agent, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s agentService) ListAgents(ctx context.Context, opts ListAgentsOptions) ([]repository.Agent, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -103,16 +180,25 @@ func (s agentService) ListAgents(ctx context.Context) ([]repository.Agent, error
 		}
 	}()
 
-	agents, err := s.repo.ListAgents(ctx, tx)
+	agents, err := s.repo.ListAgents(ctx, tx, repository.AgentFilter{
+		Search:      opts.Search,
+		Active:      opts.Active,
+		ActiveSince: time.Now().Add(-s.onlineWindow),
+		Limit:       opts.Limit,
+		Offset:      opts.Offset,
+	})
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to list agents", logging.FieldError, err)
 		return nil, err
 	}
+	for i := range agents {
+		s.withOnline(&agents[i])
+	}
 	return agents, nil
 }
 
 func (s agentService) GetAgent(ctx context.Context, id string) (*repository.Agent, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -131,13 +217,14 @@ func (s agentService) GetAgent(ctx context.Context, id string) (*repository.Agen
 			logging.FieldError, err)
 		return nil, err
 	}
+	s.withOnline(agent)
 	return agent, nil
 }
 
 func (s agentService) CreateAgent(ctx context.Context, name string) (*repository.Agent, string, error) {
 	s.logger.DebugContext(ctx, fmt.Sprintf("creating agent with name %s", name))
 
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, "", err
 	}
@@ -150,8 +237,21 @@ func (s agentService) CreateAgent(ctx context.Context, name string) (*repository
 		}
 	}()
 
+	if s.maxAgents > 0 {
+		var count int
+		count, err = s.repo.CountAgents(ctx, tx)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to count agents", logging.FieldError, err)
+			return nil, "", err
+		}
+		if err = checkLimit("agents", s.maxAgents, count); err != nil {
+			s.logger.WarnContext(ctx, "rejected agent", logging.FieldError, err)
+			return nil, "", err
+		}
+	}
+
 	// Generate token components for agent
-	tokenComponents := newToken()
+	tokenComponents := newToken(s.tokenFormat)
 
 	// Hash the token secret
 	hash, err := crypto.CalculateArgonHash(tokenComponents.secret)
@@ -160,7 +260,8 @@ func (s agentService) CreateAgent(ctx context.Context, name string) (*repository
 	}
 
 	agent := repository.Agent{
-		ID:        tokenComponents.id,
+		ID:        uuid.New().String(),
+		TokenID:   tokenComponents.id,
 		Name:      name,
 		TokenHash: hash,
 		CreatedAt: time.Now(),
@@ -172,12 +273,21 @@ func (s agentService) CreateAgent(ctx context.Context, name string) (*repository
 		return nil, "", err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionCreate,
+		TargetType: repository.AuditTargetAgent,
+		TargetID:   agent.ID,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
 	s.logger.InfoContext(ctx, fmt.Sprintf("created agent %s with id %s", name, agent.ID))
 	return &agent, tokenComponents.ToTokenString(), nil
 }
 
 func (s agentService) UpdateAgent(ctx context.Context, id string, name string) (*repository.Agent, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -205,13 +315,22 @@ func (s agentService) UpdateAgent(ctx context.Context, id string, name string) (
 		return nil, err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionUpdate,
+		TargetType: repository.AuditTargetAgent,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s.logger.InfoContext(ctx, fmt.Sprintf("updated agent %s", id))
 
 	return agent, nil
 }
 
 func (s agentService) DeleteAgent(ctx context.Context, id string) (*repository.Agent, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -238,15 +357,142 @@ func (s agentService) DeleteAgent(ctx context.Context, id string) (*repository.A
 		return nil, err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionDelete,
+		TargetType: repository.AuditTargetAgent,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s.logger.InfoContext(ctx, fmt.Sprintf("deleted agent %s", id))
 
 	return agent, nil
 }
 
-func NewAgentService(agentRepo repository.AgentRepository, pool *pgxpool.Pool) AgentService {
+func (s agentService) RotateToken(ctx context.Context, id string) (*repository.Agent, string, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	agent, err := s.repo.GetAgent(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get agent for token rotation",
+			logging.FieldAgentID, id, logging.FieldError, err)
+		return nil, "", err
+	}
+
+	tokenComponents := newToken(s.tokenFormat)
+	hash, err := crypto.CalculateArgonHash(tokenComponents.secret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.repo.UpdateAgentToken(ctx, tx, id, tokenComponents.id, hash)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to update agent token",
+			logging.FieldAgentID, id, logging.FieldError, err)
+		return nil, "", err
+	}
+	agent.TokenID = tokenComponents.id
+	agent.TokenHash = hash
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionUpdate,
+		TargetType: repository.AuditTargetAgent,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("rotated token of agent %s", id))
+	s.withOnline(agent)
+	return agent, tokenComponents.ToTokenString(), nil
+}
+
+func (s agentService) Heartbeat(ctx context.Context, id string, scanner *repository.ScannerStatus) (time.Time, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	lastSeenAt, err := s.repo.TouchAgent(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to record agent heartbeat",
+			logging.FieldAgentID, id, logging.FieldError, err)
+		return time.Time{}, err
+	}
+
+	if scanner != nil {
+		err = s.repo.UpdateAgentScanner(ctx, tx, id, *scanner)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to store agent scanner status",
+				logging.FieldAgentID, id, logging.FieldError, err)
+			return time.Time{}, err
+		}
+	}
+
+	return lastSeenAt, nil
+}
+
+func (s agentService) ScannerHealth(ctx context.Context) (*ScannerHealth, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	counts, err := s.repo.CountScanners(ctx, tx, time.Now().Add(-s.onlineWindow))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to count agent scanners", logging.FieldError, err)
+		return nil, err
+	}
+
+	health := ScannerHealth{
+		OnlineAgents: counts.Agents,
+		SYNCapable:   counts.SYN,
+		ConnectOnly:  counts.Connect,
+		Failing:      counts.Failing,
+		Unreported:   counts.Unreported,
+	}
+	switch {
+	case health.Failing == health.OnlineAgents:
+		health.Status = ScannerHealthUnavailable
+	case health.Failing > 0:
+		health.Status = ScannerHealthDegraded
+	default:
+		health.Status = ScannerHealthOK
+	}
+	return &health, nil
+}
+
+func NewAgentService(agentRepo repository.AgentRepository, pool *Pool, opts AgentServiceOptions) AgentService {
 	return &agentService{
-		repo:   agentRepo,
-		logger: logging.GetLogger(logging.Agent),
-		pool:   pool,
+		repo:         agentRepo,
+		logger:       logging.GetLogger(logging.Agent),
+		pool:         pool,
+		onlineWindow: opts.OnlineWindow,
+		maxAgents:    opts.MaxAgents,
+		tokenFormat:  opts.TokenFormat.orDefault(),
+		audit:        opts.Audit,
 	}
 }
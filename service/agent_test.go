@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"cortex/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScannerHealth(t *testing.T) {
+	agentRepo := &memoryAgentRepository{agents: map[string]repository.Agent{
+		"syn":     {ID: "syn"},
+		"connect": {ID: "connect"},
+		"failing": {ID: "failing"},
+		"legacy":  {ID: "legacy"},
+		"offline": {ID: "offline"},
+	}}
+	agentSvc := NewAgentService(agentRepo, fakePool(t, 1), AgentServiceOptions{OnlineWindow: time.Minute})
+	ctx := context.Background()
+
+	// fake engines report the result of their runner self-check with their heartbeat
+	for id, scanner := range map[string]*repository.ScannerStatus{
+		"syn":     {Capability: repository.ScannerCapabilitySYN},
+		"connect": {Capability: repository.ScannerCapabilityConnect},
+		"failing": {Error: "operation not permitted"},
+		"legacy":  nil,
+	} {
+		_, err := agentSvc.Heartbeat(ctx, id, scanner)
+		require.NoError(t, err)
+	}
+
+	health, err := agentSvc.ScannerHealth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &ScannerHealth{
+		Status:       ScannerHealthDegraded,
+		OnlineAgents: 4,
+		SYNCapable:   1,
+		ConnectOnly:  1,
+		Failing:      1,
+		Unreported:   1,
+	}, health)
+
+	// a heartbeat without a scanner status keeps the reported one
+	_, err = agentSvc.Heartbeat(ctx, "connect", nil)
+	require.NoError(t, err)
+	assert.Equal(t, repository.ScannerCapabilityConnect, agentRepo.agents["connect"].Scanner.Capability)
+}
+
+func TestScannerHealthUnavailable(t *testing.T) {
+	agentRepo := &memoryAgentRepository{agents: map[string]repository.Agent{"failing": {ID: "failing"}}}
+	agentSvc := NewAgentService(agentRepo, fakePool(t, 1), AgentServiceOptions{OnlineWindow: time.Minute})
+	ctx := context.Background()
+
+	health, err := agentSvc.ScannerHealth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ScannerHealthUnavailable, health.Status, "no agent is online")
+
+	_, err = agentSvc.Heartbeat(ctx, "failing", &repository.ScannerStatus{Error: "operation not permitted"})
+	require.NoError(t, err)
+	health, err = agentSvc.ScannerHealth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ScannerHealthUnavailable, health.Status)
+	assert.Equal(t, 1, health.Failing)
+}
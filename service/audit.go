@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"cortex/logging"
+	"cortex/repository"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type AuditService interface {
+	// ListAuditEntries returns the audit entries matching opts, the most recent first.
+	ListAuditEntries(ctx context.Context, opts ListAuditEntriesOptions) ([]repository.AuditEntry, error)
+	// Record adds an entry for a change within tx, so the entry is only kept if the change is committed. The request
+	// id is taken from ctx.
+	Record(ctx context.Context, tx pgx.Tx, record AuditRecord) error
+}
+
+// AuditRecord describes a change recorded by AuditService.Record.
+type AuditRecord struct {
+	Action     repository.AuditAction
+	TargetType repository.AuditTargetType
+	TargetID   string
+	// ActorID is the user performing the change, empty uses the user of ctx. Changes without a user, e.g. during
+	// startup, are recorded without actor.
+	ActorID string
+}
+
+// ListAuditEntriesOptions filters and pages the entries returned by ListAuditEntries. Zero values do not restrict the
+// result.
+type ListAuditEntriesOptions struct {
+	ActorID    string
+	Action     repository.AuditAction
+	TargetType repository.AuditTargetType
+	TargetID   string
+	// From and To bound the time of the entries, both inclusive.
+	From time.Time
+	To   time.Time
+	// Limit is the maximum number of entries returned, zero returns all entries.
+	Limit  int
+	Offset int
+}
+
+type auditService struct {
+	logger *slog.Logger
+	repo   repository.AuditRepository
+	pool   *Pool
+}
+
+func (s auditService) ListAuditEntries(ctx context.Context, opts ListAuditEntriesOptions) ([]repository.AuditEntry, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	entries, err := s.repo.ListAuditEntries(ctx, tx, repository.AuditFilter{
+		ActorID:    opts.ActorID,
+		Action:     opts.Action,
+		TargetType: opts.TargetType,
+		TargetID:   opts.TargetID,
+		From:       opts.From.UTC(),
+		To:         opts.To.UTC(),
+		Limit:      opts.Limit,
+		Offset:     opts.Offset,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list audit entries", logging.FieldError, err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s auditService) Record(ctx context.Context, tx pgx.Tx, record AuditRecord) error {
+	actorID := record.ActorID
+	if actorID == "" {
+		if userInfo, err := cortexContext.UserInfo(ctx); err == nil {
+			actorID = userInfo.UserID
+		}
+	}
+
+	entry := repository.AuditEntry{
+		ID:         uuid.New().String(),
+		Action:     record.Action,
+		TargetType: record.TargetType,
+		TargetID:   record.TargetID,
+		RequestID:  cortexContext.RequestID(ctx),
+		CreatedAt:  time.Now().UTC(),
+	}
+	if actorID != "" {
+		if err := entry.ActorID.Scan(actorID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.CreateAuditEntry(ctx, tx, entry); err != nil {
+		s.logger.ErrorContext(ctx, "failed to record audit entry", logging.FieldError, err)
+		return err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("%s %s %s", record.Action, record.TargetType, record.TargetID),
+		logging.FieldActorID, actorID)
+	return nil
+}
+
+// recordAudit records a change with audit, changes are not recorded without an AuditService.
+func recordAudit(ctx context.Context, tx pgx.Tx, audit AuditService, record AuditRecord) error {
+	if audit == nil {
+		return nil
+	}
+	return audit.Record(ctx, tx, record)
+}
+
+func NewAuditService(repo repository.AuditRepository, pool *Pool) AuditService {
+	return auditService{
+		logger: logging.GetLogger(logging.Audit),
+		repo:   repo,
+		pool:   pool,
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"cortex/repository"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAuditRepository keeps audit entries in memory.
+type memoryAuditRepository struct {
+	entries []repository.AuditEntry
+}
+
+func (m *memoryAuditRepository) ListAuditEntries(context.Context, pgx.Tx, repository.AuditFilter) ([]repository.AuditEntry, error) {
+	return m.entries, nil
+}
+
+func (m *memoryAuditRepository) CreateAuditEntry(_ context.Context, _ pgx.Tx, entry repository.AuditEntry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func TestRecordAudit(t *testing.T) {
+	repo := &memoryAuditRepository{}
+	svc := NewAuditService(repo, nil)
+
+	userID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: userID})
+	ctx = context.WithValue(ctx, cortexContext.KeyRequestID, "req-1")
+
+	require.NoError(t, svc.Record(ctx, nil, AuditRecord{
+		Action:     repository.AuditActionDelete,
+		TargetType: repository.AuditTargetToken,
+		TargetID:   "a1b2c3d4",
+	}))
+	// changes made without a request, e.g. during startup, have no actor
+	require.NoError(t, svc.Record(context.Background(), nil, AuditRecord{
+		Action:     repository.AuditActionCreate,
+		TargetType: repository.AuditTargetAgent,
+		TargetID:   "e5f6a7b8",
+	}))
+
+	require.Len(t, repo.entries, 2)
+	entry := repo.entries[0]
+	assert.NotEmpty(t, entry.ID)
+	assert.Equal(t, userID, entry.ActorID.String())
+	assert.Equal(t, repository.AuditActionDelete, entry.Action)
+	assert.Equal(t, repository.AuditTargetToken, entry.TargetType)
+	assert.Equal(t, "a1b2c3d4", entry.TargetID)
+	assert.Equal(t, "req-1", entry.RequestID)
+	assert.False(t, entry.CreatedAt.IsZero())
+	assert.False(t, repo.entries[1].ActorID.Valid)
+	assert.Empty(t, repo.entries[1].RequestID)
+}
+
+func TestCreateAssetsRecordsAudit(t *testing.T) {
+	auditRepo := &memoryAuditRepository{}
+	repo := &memoryScanRepository{assets: map[string]repository.ScanAsset{
+		"existing": {ID: "existing", Endpoint: "example.com"},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{Audit: NewAuditService(auditRepo, nil)})
+	userID := "7761259c-e6dd-4930-946b-ee9975fde3e4"
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: userID})
+
+	result, err := svc.CreateAssets(ctx, []string{"example.com", "example.org"})
+	require.NoError(t, err)
+	require.Len(t, result.Created, 1)
+
+	// skipped endpoints are not changed and not recorded
+	require.Len(t, auditRepo.entries, 1)
+	assert.Equal(t, repository.AuditActionCreate, auditRepo.entries[0].Action)
+	assert.Equal(t, repository.AuditTargetAsset, auditRepo.entries[0].TargetType)
+	assert.Equal(t, result.Created[0].ID, auditRepo.entries[0].TargetID)
+	assert.Equal(t, userID, auditRepo.entries[0].ActorID.String())
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	cortexContext "cortex/context"
 	"cortex/crypto"
 	"cortex/logging"
 	"cortex/repository"
@@ -10,45 +11,116 @@ import (
 	"log/slog"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 var ErrUnauthenticated = errors.New("unauthenticated")
 
+// ErrInvalidPassword is returned when the current password given for a password change does not match.
+var ErrInvalidPassword = errors.New("invalid password")
+
+// DefaultSessionTTL and DefaultRememberMeTTL are the lifetimes of session tokens if AuthServiceOptions does not set
+// them.
+const (
+	DefaultSessionTTL    = 7 * 24 * time.Hour
+	DefaultRememberMeTTL = 30 * 24 * time.Hour
+)
+
 type CreateTokenOptions struct {
 	UserID    string
 	UserAgent string
 	SourceIP  string
+	// RememberMe creates a token with the longer remember me lifetime instead of the session lifetime.
+	RememberMe bool
+}
+
+// CreateUserOptions describes a new local user. Password is the plain text password, only its hash is stored.
+type CreateUserOptions struct {
+	Username    string
+	Password    string
+	Email       string
+	DisplayName string
+	// Role defaults to repository.UserRoleUser
+	Role repository.UserRole
+}
+
+// UpdateUserOptions describes the modifiable attributes of a user.
+type UpdateUserOptions struct {
+	Email       string
+	DisplayName string
+	// Role keeps the current role if empty
+	Role repository.UserRole
 }
 
 type AuthService interface {
 	ListUsers(ctx context.Context) ([]repository.User, error)
 	GetUser(ctx context.Context, id string) (*repository.User, error)
+	CreateUser(ctx context.Context, opts CreateUserOptions) (*repository.User, error)
+	UpdateUser(ctx context.Context, id string, opts UpdateUserOptions) (*repository.User, error)
+	// ChangePassword sets a new password for a local user after verifying the current one. If revokeTokens is set,
+	// all sessions of the user except the one of the current request are revoked.
+	ChangePassword(ctx context.Context, userID string, oldPassword string, newPassword string, revokeTokens bool) error
+	// ResetPassword sets a new password for a local user without verifying the current one. It is meant for admins
+	// resetting the password of another user. If revokeTokens is set, all sessions of the user are revoked.
+	ResetPassword(ctx context.Context, userID string, newPassword string, revokeTokens bool) error
 
 	CheckUsernamePassword(ctx context.Context, username string, password string) (*repository.User, error)
+	// LoginExternalUser returns the user of an identity authenticated by an external provider. The user is created
+	// on the first login and its email and display name are updated from the identity on later logins.
+	LoginExternalUser(ctx context.Context, identity ExternalIdentity) (*repository.User, error)
 	ValidateToken(ctx context.Context, tokenString string) (*repository.User, string, error)
+	// CheckToken returns ErrUnauthenticated if ValidateToken would reject the token. It logs nothing, so clients may
+	// poll it.
+	CheckToken(ctx context.Context, tokenString string) error
 	CreateSessionToken(ctx context.Context, opt CreateTokenOptions) (*repository.AuthToken, string, error)
 	RevokeToken(ctx context.Context, tokenString string) error
+	// RefreshToken replaces a valid session token by a new one with a fresh expiry and revokes it.
+	// ErrUnauthenticated is returned if the token is expired, revoked or invalid.
+	RefreshToken(ctx context.Context, tokenString string, opt CreateTokenOptions) (*repository.AuthToken, string, error)
+	// ListUserTokens returns the active sessions of a user.
+	ListUserTokens(ctx context.Context, userID string) ([]repository.AuthToken, error)
+	// RevokeUserToken revokes a session of a user by its id. repository.ErrNotFound is returned if the user has no
+	// session with this id.
+	RevokeUserToken(ctx context.Context, userID string, tokenID string) error
 
 	ValidateAgentToken(ctx context.Context, tokenString string) (*repository.Agent, error)
 }
 
+// AuthServiceOptions configures the behaviour of an AuthService.
+type AuthServiceOptions struct {
+	// AgentTokenFormat is the largest format of accepted agent tokens. The zero value means DefaultTokenFormat.
+	AgentTokenFormat TokenFormat
+	// SessionTTL is the lifetime of session tokens. Zero means DefaultSessionTTL.
+	SessionTTL time.Duration
+	// RememberMeTTL is the lifetime of session tokens created with CreateTokenOptions.RememberMe. Zero means
+	// DefaultRememberMeTTL.
+	RememberMeTTL time.Duration
+	// Audit records the changes made through the service, nil does not record them.
+	Audit AuditService
+}
+
 type authService struct {
-	logger         *slog.Logger
-	authRepository repository.AuthRepository
-	agentRepo      repository.AgentRepository
-	pool           *pgxpool.Pool
+	logger           *slog.Logger
+	authRepository   repository.AuthRepository
+	agentRepo        repository.AgentRepository
+	pool             *Pool
+	agentTokenFormat TokenFormat
+	sessionTTL       time.Duration
+	rememberMeTTL    time.Duration
+	audit            AuditService
 }
 
 func (s authService) ValidateAgentToken(ctx context.Context, tokenString string) (*repository.Agent, error) {
-	components, err := parseTokenString(tokenString)
+	components, err := parseTokenString(tokenString, s.agentTokenFormat)
 	if err != nil {
 		return nil, err
 	}
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("validating agent token %s", components.id))
 
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +133,7 @@ func (s authService) ValidateAgentToken(ctx context.Context, tokenString string)
 		}
 	}()
 
-	agent, err := s.agentRepo.GetAgent(ctx, tx, components.id)
+	agent, err := s.agentRepo.GetAgentByTokenID(ctx, tx, components.id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			s.logger.WarnContext(ctx, fmt.Sprintf("unknown agent token %s", components.id))
@@ -77,17 +149,25 @@ func (s authService) ValidateAgentToken(ctx context.Context, tokenString string)
 		return nil, ErrUnauthenticated
 	}
 	if !match {
-		s.logger.DebugContext(ctx, fmt.Sprintf("agent token %s failed validation", agent.ID))
+		s.logger.DebugContext(ctx, fmt.Sprintf("agent token %s failed validation", components.id))
 		return nil, ErrUnauthenticated
 	}
 
+	lastSeenAt, err := s.agentRepo.TouchAgent(ctx, tx, agent.ID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to update agent last seen timestamp",
+			logging.FieldAgentID, agent.ID, logging.FieldError, err)
+		return nil, err
+	}
+	agent.LastSeenAt = pgtype.Timestamp{Time: lastSeenAt, Valid: true}
+
 	s.logger.DebugContext(ctx, fmt.Sprintf("authentication request for agent %s (%s) using id %s is valid",
 		agent.ID, agent.Name, agent.ID))
 	return agent, nil
 }
 
 func (s authService) CheckUsernamePassword(ctx context.Context, username string, password string) (*repository.User, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -109,6 +189,10 @@ func (s authService) CheckUsernamePassword(ctx context.Context, username string,
 		}
 		return nil, err
 	}
+	if user.Provider != repository.UserProviderLocal {
+		s.logger.InfoContext(ctx, fmt.Sprintf("authentication request for user %s failed: not a local user", username))
+		return nil, ErrUnauthenticated
+	}
 
 	match, err := crypto.ValidatePasswordWithArgonHash(password, user.Password)
 	if err != nil {
@@ -122,15 +206,90 @@ func (s authService) CheckUsernamePassword(ctx context.Context, username string,
 	return user, nil
 }
 
+func (s authService) LoginExternalUser(ctx context.Context, identity ExternalIdentity) (*repository.User, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	user, err := s.authRepository.GetUserByExternalID(ctx, tx, identity.Provider, identity.Subject)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		user = &repository.User{
+			ID:          uuid.New().String(),
+			Provider:    identity.Provider,
+			Username:    identity.Username,
+			Email:       identity.Email,
+			DisplayName: identity.DisplayName,
+			CreatedAt:   time.Now(),
+			Role:        repository.UserRoleUser,
+			ExternalID:  identity.Subject,
+		}
+		err = s.authRepository.CreateUser(ctx, tx, *user)
+		if err != nil {
+			if errors.Is(err, repository.ErrUniqueViolation) {
+				s.logger.InfoContext(ctx, fmt.Sprintf("username %s of %s user already exists", identity.Username,
+					identity.Provider))
+				return nil, err
+			}
+			s.logger.ErrorContext(ctx, "failed to create user", logging.FieldError, err)
+			return nil, err
+		}
+
+		err = recordAudit(ctx, tx, s.audit, AuditRecord{
+			Action:     repository.AuditActionCreate,
+			TargetType: repository.AuditTargetUser,
+			TargetID:   user.ID,
+			ActorID:    user.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.logger.InfoContext(ctx, fmt.Sprintf("created %s user %s with id %s", user.Provider, user.Username, user.ID))
+	case err != nil:
+		s.logger.ErrorContext(ctx, "failed to get user", logging.FieldError, err)
+		return nil, err
+	case user.Email != identity.Email || user.DisplayName != identity.DisplayName:
+		// the profile is managed by the provider, the role locally
+		user.Email = identity.Email
+		user.DisplayName = identity.DisplayName
+		err = s.authRepository.UpdateUser(ctx, tx, *user)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to update user", logging.FieldUserID, user.ID, logging.FieldError, err)
+			return nil, err
+		}
+
+		err = recordAudit(ctx, tx, s.audit, AuditRecord{
+			Action:     repository.AuditActionUpdate,
+			TargetType: repository.AuditTargetUser,
+			TargetID:   user.ID,
+			ActorID:    user.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
 func (s authService) ValidateToken(ctx context.Context, tokenString string) (*repository.User, string, error) {
-	components, err := parseTokenString(tokenString)
+	components, err := parseTokenString(tokenString, DefaultTokenFormat)
 	if err != nil {
 		return nil, "", err
 	}
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("validating token %s", components.id))
 
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, "", err
 	}
@@ -143,50 +302,73 @@ func (s authService) ValidateToken(ctx context.Context, tokenString string) (*re
 		}
 	}()
 
+	user, _, err := s.validateToken(ctx, tx, components)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, components.id, nil
+}
+
+func (s authService) CheckToken(ctx context.Context, tokenString string) error {
+	// the check is public and polled, logging every probe would flood the logs
+	quiet := s
+	quiet.logger = slog.New(slog.DiscardHandler)
+	_, _, err := quiet.ValidateToken(ctx, tokenString)
+	return err
+}
+
+// validateToken checks the token with the components within tx and returns its user and the stored token.
+func (s authService) validateToken(ctx context.Context, tx pgx.Tx, components token) (*repository.User,
+	*repository.AuthToken, error) {
 	authToken, err := s.authRepository.GetToken(ctx, tx, components.id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			s.logger.WarnContext(ctx, fmt.Sprintf("unknown token %s", components.id))
-			return nil, "", ErrUnauthenticated
+			return nil, nil, ErrUnauthenticated
 		}
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// check if authToken is expired
 	if authToken.ExpiresAt.Before(time.Now()) {
 		s.logger.DebugContext(ctx, fmt.Sprintf("token %s expired", authToken.ID))
-		return nil, "", ErrUnauthenticated
+		return nil, nil, ErrUnauthenticated
+	}
+
+	if authToken.Revoked {
+		s.logger.DebugContext(ctx, fmt.Sprintf("token %s has been revoked", authToken.ID))
+		return nil, nil, ErrUnauthenticated
 	}
 
 	// validate hash
 	match, err := crypto.ValidatePasswordWithArgonHash(components.secret, authToken.Hash)
 	if err != nil {
 		s.logger.DebugContext(ctx, "failed to validate token", logging.FieldError, err)
-		return nil, "", ErrUnauthenticated
+		return nil, nil, ErrUnauthenticated
 	}
 	if !match {
 		s.logger.DebugContext(ctx, fmt.Sprintf("token %s failed validation", authToken.ID))
-		return nil, "", ErrUnauthenticated
+		return nil, nil, ErrUnauthenticated
 	}
 
 	user, err := s.authRepository.GetUser(ctx, tx, authToken.UserID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			s.logger.WarnContext(ctx, fmt.Sprintf("unknown user %s for token", authToken.UserID))
-			return nil, "", ErrUnauthenticated
+			return nil, nil, ErrUnauthenticated
 		}
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("authentication request for user %s (%s) using id %s is valid",
 		user.ID, user.Username, authToken.ID))
-	return user, components.id, nil
+	return user, authToken, nil
 }
 
 func (s authService) CreateSessionToken(ctx context.Context, opt CreateTokenOptions) (*repository.AuthToken, string, error) {
 	s.logger.DebugContext(ctx, fmt.Sprintf("creating session token for user %s", opt.UserID))
 
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, "", err
 	}
@@ -199,8 +381,18 @@ func (s authService) CreateSessionToken(ctx context.Context, opt CreateTokenOpti
 		}
 	}()
 
+	authToken, tokenString, err := s.createSessionToken(ctx, tx, opt)
+	if err != nil {
+		return nil, "", err
+	}
+	return authToken, tokenString, nil
+}
+
+// createSessionToken stores a new session token within tx.
+func (s authService) createSessionToken(ctx context.Context, tx pgx.Tx, opt CreateTokenOptions) (*repository.AuthToken,
+	string, error) {
 	// check if user exists first
-	_, err = s.authRepository.GetUser(ctx, tx, opt.UserID)
+	_, err := s.authRepository.GetUser(ctx, tx, opt.UserID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			s.logger.WarnContext(ctx, fmt.Sprintf("requested to create token for unknown user id %s", opt.UserID))
@@ -208,10 +400,13 @@ func (s authService) CreateSessionToken(ctx context.Context, opt CreateTokenOpti
 		return nil, "", err
 	}
 
-	// TODO: make token expiration configurable
-	expiration := time.Now().Add(time.Hour * 24 * 7)
+	ttl := s.sessionTTL
+	if opt.RememberMe {
+		ttl = s.rememberMeTTL
+	}
+	expiration := time.Now().Add(ttl)
 
-	tokenComponents := newToken()
+	tokenComponents := newToken(DefaultTokenFormat)
 
 	hash, err := crypto.CalculateArgonHash(tokenComponents.secret)
 	if err != nil {
@@ -235,19 +430,141 @@ func (s authService) CreateSessionToken(ctx context.Context, opt CreateTokenOpti
 		return nil, "", err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionCreate,
+		TargetType: repository.AuditTargetToken,
+		TargetID:   authToken.ID,
+		ActorID:    opt.UserID,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
 	s.logger.InfoContext(ctx, fmt.Sprintf("created token for user %s with id %s", opt.UserID, authToken.ID))
 	return &authToken, tokenComponents.ToTokenString(), nil
 }
 
 func (s authService) RevokeToken(ctx context.Context, tokenString string) error {
-	components, err := parseTokenString(tokenString)
+	components, err := parseTokenString(tokenString, DefaultTokenFormat)
 	if err != nil {
 		return err
 	}
 
 	s.logger.DebugContext(ctx, fmt.Sprintf("invalidating token with token %s", components.id))
 
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	err = s.revokeToken(ctx, tx, components.id)
+	return err
+}
+
+// revokeToken revokes the token with the id within tx. repository.ErrNotFound is returned if the token does not
+// exist or has already been revoked.
+func (s authService) revokeToken(ctx context.Context, tx pgx.Tx, id string) error {
+	err := s.authRepository.DeleteToken(ctx, tx, id)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			s.logger.ErrorContext(ctx, "failed to delete token", logging.FieldError, err)
+		}
+		return err
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionDelete,
+		TargetType: repository.AuditTargetToken,
+		TargetID:   id,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("deleted token %s", id))
+	return nil
+}
+
+func (s authService) RefreshToken(ctx context.Context, tokenString string, opt CreateTokenOptions) (*repository.AuthToken, string, error) {
+	components, err := parseTokenString(tokenString, DefaultTokenFormat)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// validate, revoke and create in one transaction, a failed refresh must leave the old token usable
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	user, oldToken, err := s.validateToken(ctx, tx, components)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the revoke only succeeds for the first of concurrent refreshes of the token
+	err = s.revokeToken(ctx, tx, oldToken.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			s.logger.DebugContext(ctx, fmt.Sprintf("token %s has been revoked concurrently", oldToken.ID))
+			err = ErrUnauthenticated
+		}
+		return nil, "", err
+	}
+
+	opt.UserID = user.ID
+	// keep the lifetime of tokens created with remember me
+	opt.RememberMe = oldToken.ExpiresAt.Sub(oldToken.CreatedAt) > s.sessionTTL
+	authToken, newTokenString, err := s.createSessionToken(ctx, tx, opt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("refreshed token %s with token %s", oldToken.ID, authToken.ID),
+		logging.FieldUserID, user.ID)
+	return authToken, newTokenString, nil
+}
+
+func (s authService) ListUserTokens(ctx context.Context, userID string) ([]repository.AuthToken, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	tokens, err := s.authRepository.ListTokensByUser(ctx, tx, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list tokens", logging.FieldUserID, userID, logging.FieldError, err)
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s authService) RevokeUserToken(ctx context.Context, userID string, tokenID string) error {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return err
 	}
@@ -260,18 +577,40 @@ func (s authService) RevokeToken(ctx context.Context, tokenString string) error
 		}
 	}()
 
-	err = s.authRepository.DeleteToken(ctx, tx, components.id)
+	token, err := s.authRepository.GetToken(ctx, tx, tokenID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			s.logger.ErrorContext(ctx, "failed to get token", logging.FieldUserID, userID, logging.FieldError, err)
+		}
+		return err
+	}
+	if token.UserID != userID {
+		// do not reveal sessions of other users
+		err = repository.ErrNotFound
+		return err
+	}
+
+	err = s.authRepository.DeleteToken(ctx, tx, tokenID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to revoke token", logging.FieldUserID, userID, logging.FieldError, err)
+		return err
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionDelete,
+		TargetType: repository.AuditTargetToken,
+		TargetID:   tokenID,
+	})
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to delete token", logging.FieldError, err)
 		return err
 	}
 
-	s.logger.InfoContext(ctx, fmt.Sprintf("deleted token %s", components.id))
+	s.logger.InfoContext(ctx, fmt.Sprintf("revoked token %s", tokenID), logging.FieldUserID, userID)
 	return nil
 }
 
 func (s authService) ListUsers(ctx context.Context) ([]repository.User, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -293,7 +632,7 @@ func (s authService) ListUsers(ctx context.Context) ([]repository.User, error) {
 }
 
 func (s authService) GetUser(ctx context.Context, id string) (*repository.User, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -316,11 +655,211 @@ func (s authService) GetUser(ctx context.Context, id string) (*repository.User,
 	return user, nil
 }
 
-func NewAuthService(authRepo repository.AuthRepository, agentRepo repository.AgentRepository, pool *pgxpool.Pool) AuthService {
+func (s authService) CreateUser(ctx context.Context, opts CreateUserOptions) (*repository.User, error) {
+	s.logger.DebugContext(ctx, fmt.Sprintf("creating user %s", opts.Username))
+
+	hash, err := crypto.CalculateArgonHash(opts.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := repository.User{
+		ID:          uuid.New().String(),
+		Provider:    repository.UserProviderLocal,
+		Username:    opts.Username,
+		Password:    hash,
+		Email:       opts.Email,
+		DisplayName: opts.DisplayName,
+		CreatedAt:   time.Now(),
+		Role:        opts.Role,
+	}
+	if user.Role == "" {
+		user.Role = repository.UserRoleUser
+	}
+
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	err = s.authRepository.CreateUser(ctx, tx, user)
+	if err != nil {
+		if errors.Is(err, repository.ErrUniqueViolation) {
+			s.logger.InfoContext(ctx, fmt.Sprintf("user %s already exists", opts.Username))
+			return nil, err
+		}
+		s.logger.ErrorContext(ctx, "failed to create user", logging.FieldError, err)
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionCreate,
+		TargetType: repository.AuditTargetUser,
+		TargetID:   user.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("created user %s with id %s", user.Username, user.ID))
+	return &user, nil
+}
+
+func (s authService) UpdateUser(ctx context.Context, id string, opts UpdateUserOptions) (*repository.User, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	user, err := s.authRepository.GetUser(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get user", logging.FieldUserID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	user.Email = opts.Email
+	user.DisplayName = opts.DisplayName
+	if opts.Role != "" {
+		user.Role = opts.Role
+	}
+	err = s.authRepository.UpdateUser(ctx, tx, *user)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to update user", logging.FieldUserID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionUpdate,
+		TargetType: repository.AuditTargetUser,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("updated user %s", user.Username), logging.FieldUserID, id)
+	return user, nil
+}
+
+func (s authService) ChangePassword(ctx context.Context, userID string, oldPassword string, newPassword string, revokeTokens bool) error {
+	return s.setPassword(ctx, userID, &oldPassword, newPassword, revokeTokens)
+}
+
+func (s authService) ResetPassword(ctx context.Context, userID string, newPassword string, revokeTokens bool) error {
+	return s.setPassword(ctx, userID, nil, newPassword, revokeTokens)
+}
+
+// setPassword sets a new password for a local user. The current password is verified unless oldPassword is nil.
+func (s authService) setPassword(ctx context.Context, userID string, oldPassword *string, newPassword string,
+	revokeTokens bool) error {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	user, err := s.authRepository.GetUser(ctx, tx, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get user", logging.FieldUserID, userID, logging.FieldError, err)
+		return err
+	}
+	if user.Provider != repository.UserProviderLocal {
+		err = fmt.Errorf("%w: user %s is not a local user", ErrInvalidPassword, user.Username)
+		return err
+	}
+
+	if oldPassword != nil {
+		var match bool
+		match, err = crypto.ValidatePasswordWithArgonHash(*oldPassword, user.Password)
+		if err != nil {
+			return err
+		}
+		if !match {
+			s.logger.InfoContext(ctx, fmt.Sprintf("password change for user %s failed: password does not match",
+				user.Username))
+			err = ErrInvalidPassword
+			return err
+		}
+	}
+
+	user.Password, err = crypto.CalculateArgonHash(newPassword)
+	if err != nil {
+		return err
+	}
+	err = s.authRepository.UpdateUser(ctx, tx, *user)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to update password", logging.FieldUserID, userID, logging.FieldError, err)
+		return err
+	}
+
+	if revokeTokens {
+		// keep the session the password has been changed with
+		currentTokenID := ""
+		if userInfo, infoErr := cortexContext.UserInfo(ctx); infoErr == nil {
+			currentTokenID = userInfo.TokenID
+		}
+
+		var revoked int64
+		revoked, err = s.authRepository.RevokeUserTokens(ctx, tx, userID, currentTokenID)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to revoke tokens", logging.FieldUserID, userID, logging.FieldError, err)
+			return err
+		}
+		s.logger.InfoContext(ctx, fmt.Sprintf("revoked %d tokens of user %s", revoked, user.Username))
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionUpdate,
+		TargetType: repository.AuditTargetUser,
+		TargetID:   userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("changed password of user %s", user.Username), logging.FieldUserID, userID)
+	return nil
+}
+
+func NewAuthService(authRepo repository.AuthRepository, agentRepo repository.AgentRepository, pool *Pool,
+	opts AuthServiceOptions) AuthService {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = DefaultSessionTTL
+	}
+	if opts.RememberMeTTL <= 0 {
+		opts.RememberMeTTL = DefaultRememberMeTTL
+	}
 	return authService{
-		authRepository: authRepo,
-		agentRepo:      agentRepo,
-		logger:         logging.GetLogger(logging.Auth),
-		pool:           pool,
+		authRepository:   authRepo,
+		agentRepo:        agentRepo,
+		logger:           logging.GetLogger(logging.Auth),
+		pool:             pool,
+		agentTokenFormat: opts.AgentTokenFormat.orDefault(),
+		sessionTTL:       opts.SessionTTL,
+		rememberMeTTL:    opts.RememberMeTTL,
+		audit:            opts.Audit,
 	}
 }
@@ -0,0 +1,595 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"cortex/crypto"
+	"cortex/repository"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAuthRepository keeps users and tokens in memory. Methods not needed by the tests panic.
+type memoryAuthRepository struct {
+	repository.AuthRepository
+	mu     sync.Mutex
+	users  map[string]repository.User
+	tokens map[string]repository.AuthToken
+	// afterGetToken is called with the id of a token after GetToken has read it.
+	afterGetToken func(id string)
+}
+
+func newMemoryAuthRepository(users ...repository.User) *memoryAuthRepository {
+	repo := &memoryAuthRepository{
+		users:  make(map[string]repository.User),
+		tokens: make(map[string]repository.AuthToken),
+	}
+	for _, user := range users {
+		repo.users[user.ID] = user
+	}
+	return repo
+}
+
+func (m *memoryAuthRepository) GetUser(_ context.Context, _ pgx.Tx, id string) (*repository.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (m *memoryAuthRepository) StoreToken(_ context.Context, _ pgx.Tx, token *repository.AuthToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[token.ID] = *token
+	return nil
+}
+
+func (m *memoryAuthRepository) GetToken(_ context.Context, _ pgx.Tx, id string) (*repository.AuthToken, error) {
+	m.mu.Lock()
+	token, ok := m.tokens[id]
+	afterGetToken := m.afterGetToken
+	m.mu.Unlock()
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	if afterGetToken != nil {
+		afterGetToken(id)
+	}
+	return &token, nil
+}
+
+func (m *memoryAuthRepository) DeleteToken(_ context.Context, _ pgx.Tx, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[id]
+	if !ok || token.Revoked {
+		return repository.ErrNotFound
+	}
+	token.Revoked = true
+	m.tokens[id] = token
+	return nil
+}
+
+func (m *memoryAuthRepository) GetUserByUsername(_ context.Context, _ pgx.Tx, username string) (*repository.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, user := range m.users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memoryAuthRepository) GetUserByExternalID(_ context.Context, _ pgx.Tx, provider repository.UserProvider,
+	externalID string) (*repository.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, user := range m.users {
+		if user.Provider == provider && user.ExternalID == externalID {
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memoryAuthRepository) CreateUser(_ context.Context, _ pgx.Tx, user repository.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.users {
+		if existing.Username == user.Username {
+			return repository.ErrUniqueViolation
+		}
+	}
+	m.users[user.ID] = user
+	return nil
+}
+
+func (m *memoryAuthRepository) UpdateUser(_ context.Context, _ pgx.Tx, user repository.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[user.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	m.users[user.ID] = user
+	return nil
+}
+
+func TestValidateTokenRevoked(t *testing.T) {
+	user := repository.User{
+		ID:        "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c",
+		Provider:  repository.UserProviderLocal,
+		Username:  "jdoe",
+		CreatedAt: time.Now(),
+		Role:      repository.UserRoleUser,
+	}
+	repo := newMemoryAuthRepository(user)
+	svc := NewAuthService(repo, nil, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	authToken, tokenString, err := svc.CreateSessionToken(ctx, CreateTokenOptions{UserID: user.ID})
+	require.NoError(t, err)
+	require.True(t, authToken.ExpiresAt.After(time.Now()))
+
+	validated, tokenID, err := svc.ValidateToken(ctx, tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	assert.Equal(t, authToken.ID, tokenID)
+
+	require.NoError(t, svc.RevokeToken(ctx, tokenString))
+
+	_, _, err = svc.ValidateToken(ctx, tokenString)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestCheckTokenLogsNothing(t *testing.T) {
+	user := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Provider: repository.UserProviderLocal,
+		Username: "jdoe", Role: repository.UserRoleUser}
+	var logs bytes.Buffer
+	svc := NewAuthService(newMemoryAuthRepository(user), nil, fakePool(t, 1), AuthServiceOptions{}).(authService)
+	svc.logger = slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx := context.Background()
+
+	_, tokenString, err := svc.CreateSessionToken(ctx, CreateTokenOptions{UserID: user.ID})
+	require.NoError(t, err)
+	revoked, revokedString, err := svc.CreateSessionToken(ctx, CreateTokenOptions{UserID: user.ID})
+	require.NoError(t, err)
+	require.NoError(t, svc.RevokeToken(ctx, revokedString))
+	logs.Reset()
+
+	assert.NoError(t, svc.CheckToken(ctx, tokenString))
+	assert.ErrorIs(t, svc.CheckToken(ctx, revokedString), ErrUnauthenticated)
+	assert.Empty(t, logs.String())
+
+	// ValidateToken still logs with the logger of the service
+	_, _, err = svc.ValidateToken(ctx, revokedString)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.Contains(t, logs.String(), "token "+revoked.ID+" has been revoked")
+}
+
+func TestRefreshToken(t *testing.T) {
+	user := repository.User{
+		ID:        "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c",
+		Provider:  repository.UserProviderLocal,
+		Username:  "jdoe",
+		CreatedAt: time.Now(),
+		Role:      repository.UserRoleUser,
+	}
+	repo := newMemoryAuthRepository(user)
+	svc := NewAuthService(repo, nil, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	oldToken, oldTokenString, err := svc.CreateSessionToken(ctx, CreateTokenOptions{UserID: user.ID})
+	require.NoError(t, err)
+
+	newToken, newTokenString, err := svc.RefreshToken(ctx, oldTokenString, CreateTokenOptions{UserAgent: "client"})
+	require.NoError(t, err)
+	assert.NotEqual(t, oldToken.ID, newToken.ID)
+	assert.Equal(t, user.ID, newToken.UserID)
+	assert.Equal(t, "client", newToken.UserAgent)
+	assert.False(t, newToken.ExpiresAt.Before(oldToken.ExpiresAt))
+
+	_, _, err = svc.ValidateToken(ctx, oldTokenString)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	validated, tokenID, err := svc.ValidateToken(ctx, newTokenString)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	assert.Equal(t, newToken.ID, tokenID)
+
+	// a revoked token cannot be refreshed again
+	_, _, err = svc.RefreshToken(ctx, oldTokenString, CreateTokenOptions{})
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestRefreshTokenConcurrently(t *testing.T) {
+	user := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "jdoe"}
+	repo := newMemoryAuthRepository(user)
+	const refreshes = 5
+	svc := NewAuthService(repo, nil, fakePool(t, refreshes), AuthServiceOptions{})
+	ctx := context.Background()
+
+	_, tokenString, err := svc.CreateSessionToken(ctx, CreateTokenOptions{UserID: user.ID})
+	require.NoError(t, err)
+
+	errs := make(chan error, refreshes)
+	var wg sync.WaitGroup
+	for range refreshes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := svc.RefreshToken(ctx, tokenString, CreateTokenOptions{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// the token is rotated once, all other refreshes of it are rejected
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	}
+	assert.Equal(t, 1, succeeded)
+	assert.Len(t, repo.tokens, 2)
+}
+
+func TestRefreshTokenRevokedBetweenValidationAndRevoke(t *testing.T) {
+	user := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "jdoe"}
+	repo := newMemoryAuthRepository(user)
+	svc := NewAuthService(repo, nil, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	authToken, tokenString, err := svc.CreateSessionToken(ctx, CreateTokenOptions{UserID: user.ID})
+	require.NoError(t, err)
+
+	// another refresh revokes the token after this one has validated it
+	repo.afterGetToken = func(id string) {
+		repo.afterGetToken = nil
+		require.NoError(t, repo.DeleteToken(ctx, nil, id))
+	}
+
+	_, _, err = svc.RefreshToken(ctx, tokenString, CreateTokenOptions{})
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.Len(t, repo.tokens, 1)
+	assert.True(t, repo.tokens[authToken.ID].Revoked)
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+	user := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "jdoe"}
+	repo := newMemoryAuthRepository(user)
+	svc := NewAuthService(repo, nil, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	authToken, tokenString, err := svc.CreateSessionToken(ctx, CreateTokenOptions{UserID: user.ID})
+	require.NoError(t, err)
+	authToken.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, repo.StoreToken(ctx, nil, authToken))
+
+	_, _, err = svc.RefreshToken(ctx, tokenString, CreateTokenOptions{})
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.Len(t, repo.tokens, 1)
+}
+
+func TestCreateSessionTokenLifetime(t *testing.T) {
+	user := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "jdoe"}
+	tests := []struct {
+		name       string
+		opts       AuthServiceOptions
+		rememberMe bool
+		ttl        time.Duration
+	}{
+		{name: "default session", ttl: DefaultSessionTTL},
+		{name: "default remember me", rememberMe: true, ttl: DefaultRememberMeTTL},
+		{name: "configured session", opts: AuthServiceOptions{SessionTTL: time.Hour}, ttl: time.Hour},
+		{name: "configured remember me", opts: AuthServiceOptions{SessionTTL: time.Hour, RememberMeTTL: 48 * time.Hour},
+			rememberMe: true, ttl: 48 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewAuthService(newMemoryAuthRepository(user), nil, fakePool(t, 1), tt.opts)
+
+			authToken, _, err := svc.CreateSessionToken(context.Background(),
+				CreateTokenOptions{UserID: user.ID, RememberMe: tt.rememberMe})
+			require.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(tt.ttl), authToken.ExpiresAt, time.Minute)
+		})
+	}
+}
+
+func TestRefreshTokenRememberMe(t *testing.T) {
+	user := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "jdoe"}
+	svc := NewAuthService(newMemoryAuthRepository(user), nil, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	_, tokenString, err := svc.CreateSessionToken(ctx, CreateTokenOptions{UserID: user.ID, RememberMe: true})
+	require.NoError(t, err)
+
+	refreshed, _, err := svc.RefreshToken(ctx, tokenString, CreateTokenOptions{})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(DefaultRememberMeTTL), refreshed.ExpiresAt, time.Minute)
+}
+
+func TestChangePasswordRequiresCurrentPassword(t *testing.T) {
+	hash, err := crypto.CalculateArgonHash("current")
+	require.NoError(t, err)
+	user := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "jdoe", Password: hash,
+		Provider: repository.UserProviderLocal}
+	repo := newMemoryAuthRepository(user)
+	svc := NewAuthService(repo, nil, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	err = svc.ChangePassword(ctx, user.ID, "wrong", "Correct-Horse-7", false)
+	assert.ErrorIs(t, err, ErrInvalidPassword)
+	assert.Equal(t, hash, repo.users[user.ID].Password)
+
+	require.NoError(t, svc.ChangePassword(ctx, user.ID, "current", "Correct-Horse-7", false))
+	match, err := crypto.ValidatePasswordWithArgonHash("Correct-Horse-7", repo.users[user.ID].Password)
+	require.NoError(t, err)
+	assert.True(t, match)
+}
+
+func TestResetPassword(t *testing.T) {
+	hash, err := crypto.CalculateArgonHash("current")
+	require.NoError(t, err)
+	user := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "jdoe", Password: hash,
+		Provider: repository.UserProviderLocal}
+	external := repository.User{ID: "1f0c1f2a-2f8e-4d1a-9d51-6f0c1f2a3b4c", Username: "oidc",
+		Provider: repository.UserProviderOIDC}
+	repo := newMemoryAuthRepository(user, external)
+	svc := NewAuthService(repo, nil, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	// the current password is not needed
+	require.NoError(t, svc.ResetPassword(ctx, user.ID, "Correct-Horse-7", false))
+	match, err := crypto.ValidatePasswordWithArgonHash("Correct-Horse-7", repo.users[user.ID].Password)
+	require.NoError(t, err)
+	assert.True(t, match)
+
+	// users of other providers have no password
+	assert.ErrorIs(t, svc.ResetPassword(ctx, external.ID, "Correct-Horse-7", false), ErrInvalidPassword)
+	assert.ErrorIs(t, svc.ResetPassword(ctx, "missing", "Correct-Horse-7", false), repository.ErrNotFound)
+}
+
+// memoryAgentRepository keeps agents in memory. Methods not needed by the tests panic.
+type memoryAgentRepository struct {
+	repository.AgentRepository
+	mu     sync.Mutex
+	agents map[string]repository.Agent
+}
+
+func (m *memoryAgentRepository) GetAgent(_ context.Context, _ pgx.Tx, id string) (*repository.Agent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	agent, ok := m.agents[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &agent, nil
+}
+
+func (m *memoryAgentRepository) TouchAgent(_ context.Context, _ pgx.Tx, id string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	agent, ok := m.agents[id]
+	if !ok {
+		return time.Time{}, repository.ErrNotFound
+	}
+	now := time.Now()
+	agent.LastSeenAt.Time, agent.LastSeenAt.Valid = now, true
+	m.agents[id] = agent
+	return now, nil
+}
+
+func (m *memoryAgentRepository) ListAgents(_ context.Context, _ pgx.Tx, filter repository.AgentFilter) ([]repository.Agent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var agents []repository.Agent
+	for _, agent := range m.agents {
+		active := agent.LastSeenAt.Valid && !agent.LastSeenAt.Time.Before(filter.ActiveSince)
+		if filter.Active == nil || *filter.Active == active {
+			agents = append(agents, agent)
+		}
+	}
+	return agents, nil
+}
+
+func (m *memoryAgentRepository) CountScanners(_ context.Context, _ pgx.Tx, onlineSince time.Time) (repository.ScannerCounts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var counts repository.ScannerCounts
+	for _, agent := range m.agents {
+		if !agent.LastSeenAt.Valid || agent.LastSeenAt.Time.Before(onlineSince) {
+			continue
+		}
+		counts.Agents++
+		switch {
+		case agent.Scanner.Error != "":
+			counts.Failing++
+		case agent.Scanner.Capability == repository.ScannerCapabilitySYN:
+			counts.SYN++
+		case agent.Scanner.Capability == repository.ScannerCapabilityConnect:
+			counts.Connect++
+		default:
+			counts.Unreported++
+		}
+	}
+	return counts, nil
+}
+
+func (m *memoryAgentRepository) UpdateAgentScanner(_ context.Context, _ pgx.Tx, id string, status repository.ScannerStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	agent, ok := m.agents[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	agent.Scanner = status
+	m.agents[id] = agent
+	return nil
+}
+
+func (m *memoryAgentRepository) GetAgentByTokenID(_ context.Context, _ pgx.Tx, tokenID string) (*repository.Agent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, agent := range m.agents {
+		if agent.TokenID == tokenID {
+			return &agent, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memoryAgentRepository) UpdateAgentToken(_ context.Context, _ pgx.Tx, id string, tokenID string, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	agent, ok := m.agents[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	agent.TokenID = tokenID
+	agent.TokenHash = tokenHash
+	m.agents[id] = agent
+	return nil
+}
+
+func (m *memoryAgentRepository) CreateAgent(_ context.Context, _ pgx.Tx, agent repository.Agent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.agents[agent.ID] = agent
+	return nil
+}
+
+func TestCreateAgentWithToken(t *testing.T) {
+	const tokenString = "a1b2c3d4.00112233445566778899aabbccddeeff"
+	agentRepo := &memoryAgentRepository{agents: map[string]repository.Agent{}}
+	pool := fakePool(t, 1)
+	agentSvc := NewAgentService(agentRepo, pool, AgentServiceOptions{})
+	ctx := context.Background()
+
+	agent, err := agentSvc.CreateAgentWithToken(ctx, tokenString, "Default")
+	require.NoError(t, err)
+	assert.NoError(t, uuid.Validate(agent.ID))
+	assert.Equal(t, "a1b2c3d4", agent.TokenID)
+
+	// the agent is created once and found by its token on every startup
+	again, err := agentSvc.CreateAgentWithToken(ctx, tokenString, "Default")
+	require.NoError(t, err)
+	assert.Equal(t, agent.ID, again.ID)
+	assert.Len(t, agentRepo.agents, 1)
+
+	validated, err := NewAuthService(newMemoryAuthRepository(), agentRepo, pool, AuthServiceOptions{}).
+		ValidateAgentToken(ctx, tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, agent.ID, validated.ID)
+}
+
+func TestRotateAgentToken(t *testing.T) {
+	const agentID = "4f2a8c1e-7b3d-4e9a-a5c6-1d2e3f4a5b6c"
+	const tokenID = "a1b2c3d4"
+	const secret = "00112233445566778899aabbccddeeff"
+	hash, err := crypto.CalculateArgonHash(secret)
+	require.NoError(t, err)
+
+	agentRepo := &memoryAgentRepository{agents: map[string]repository.Agent{
+		agentID: {ID: agentID, TokenID: tokenID, Name: "scanner-1", TokenHash: hash, CreatedAt: time.Now()},
+	}}
+	pool := fakePool(t, 1)
+	agentSvc := NewAgentService(agentRepo, pool, AgentServiceOptions{})
+	authSvc := NewAuthService(newMemoryAuthRepository(), agentRepo, pool, AuthServiceOptions{})
+	ctx := context.Background()
+
+	agent, tokenString, err := agentSvc.RotateToken(ctx, agentID)
+	require.NoError(t, err)
+	assert.Equal(t, agentID, agent.ID)
+	assert.NotEqual(t, tokenID, agent.TokenID)
+	assert.Equal(t, agent.TokenID+".", tokenString[:len(agent.TokenID)+1])
+
+	validated, err := authSvc.ValidateAgentToken(ctx, tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, agentID, validated.ID)
+
+	// the previous token is rejected right away
+	_, err = authSvc.ValidateAgentToken(ctx, tokenID+"."+secret)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	_, _, err = agentSvc.RotateToken(ctx, "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestValidateAgentToken(t *testing.T) {
+	const agentID = "4f2a8c1e-7b3d-4e9a-a5c6-1d2e3f4a5b6c"
+	const tokenID = "a1b2c3d4"
+	const secret = "00112233445566778899aabbccddeeff"
+	hash, err := crypto.CalculateArgonHash(secret)
+	require.NoError(t, err)
+
+	agentRepo := &memoryAgentRepository{agents: map[string]repository.Agent{
+		agentID: {ID: agentID, TokenID: tokenID, Name: "scanner-1", TokenHash: hash, CreatedAt: time.Now()},
+	}}
+	svc := NewAuthService(newMemoryAuthRepository(), agentRepo, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	agent, err := svc.ValidateAgentToken(ctx, tokenID+"."+secret)
+	require.NoError(t, err)
+	assert.Equal(t, agentID, agent.ID)
+	assert.True(t, agent.LastSeenAt.Valid, "last seen timestamp should be updated")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong secret", tokenID + ".ffeeddccbbaa99887766554433221100"},
+		{"unknown agent", "deadbeef." + secret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.ValidateAgentToken(ctx, tt.token)
+			assert.ErrorIs(t, err, ErrUnauthenticated)
+		})
+	}
+
+	_, err = svc.ValidateAgentToken(ctx, "malformed")
+	assert.Error(t, err)
+}
+
+func TestValidateAgentTokenLargerFormat(t *testing.T) {
+	format := TokenFormat{IDBytes: 8, SecretBytes: 32}
+	large := newToken(format)
+	small := newToken(DefaultTokenFormat)
+
+	agentRepo := &memoryAgentRepository{agents: map[string]repository.Agent{}}
+	for _, generated := range []token{large, small} {
+		hash, err := crypto.CalculateArgonHash(generated.secret)
+		require.NoError(t, err)
+		agentRepo.agents[generated.id] = repository.Agent{ID: generated.id, TokenID: generated.id, TokenHash: hash}
+	}
+	ctx := context.Background()
+
+	svc := NewAuthService(newMemoryAuthRepository(), agentRepo, fakePool(t, 1), AuthServiceOptions{AgentTokenFormat: format})
+	for _, generated := range []token{large, small} {
+		agent, err := svc.ValidateAgentToken(ctx, generated.ToTokenString())
+		require.NoError(t, err)
+		assert.Equal(t, generated.id, agent.ID)
+	}
+
+	// the default format does not accept the larger token
+	svc = NewAuthService(newMemoryAuthRepository(), agentRepo, fakePool(t, 1), AuthServiceOptions{})
+	_, err := svc.ValidateAgentToken(ctx, large.ToTokenString())
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// canonicalJSON encodes value as JSON with a single representation for equal values: object keys are sorted, there
+// is no insignificant whitespace and numbers are normalized, so 443, 443.0, int64(443) and json.Number("4.43e2") are
+// all encoded as 443.
+func canonicalJSON(value any) ([]byte, error) {
+	// round trip through JSON to reduce Go types, e.g. structs or typed slices, to the JSON data model
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var decoded any
+	if err = decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = writeCanonicalJSON(&buf, decoded); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(encoded)
+	case json.Number:
+		number, err := canonicalNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(number)
+	case []any:
+		buf.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, element); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		buf.WriteByte('{')
+		for i, key := range slices.Sorted(maps.Keys(v)) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unexpected JSON value of type %T", value)
+	}
+	return nil
+}
+
+// canonicalNumber formats integral numbers as integers and others in the shortest representation.
+func canonicalNumber(number json.Number) (string, error) {
+	if !strings.ContainsAny(number.String(), ".eE") {
+		// integer literals keep their digits, they may exceed the precision of float64
+		integer, err := strconv.ParseInt(number.String(), 10, 64)
+		if err != nil {
+			return number.String(), nil
+		}
+		return strconv.FormatInt(integer, 10), nil
+	}
+
+	f, err := number.Float64()
+	if err != nil {
+		return "", err
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return strconv.FormatInt(int64(f), 10), nil
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
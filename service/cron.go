@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCron is returned for cron expressions ParseCron does not accept.
+var ErrInvalidCron = errors.New("invalid cron expression")
+
+// cronSearchYears limits the search for the next run of a cron schedule.
+const cronSearchYears = 5
+
+// cronMacros are the shorthands accepted in place of the five fields.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+// cronFields are the fields of a cron expression in order. Day of week 7 is an alias of 0, both being Sunday.
+var cronFields = [...]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// CronSchedule is a parsed cron expression. Times are evaluated in UTC.
+type CronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	// if both day of month and day of week are restricted, a day matches if either matches, as in the classic cron
+	dayOfMonthAny, dayOfWeekAny bool
+}
+
+// ParseCron parses a cron expression with the five standard fields minute, hour, day of month, month and day of week.
+// Fields accept *, numbers, ranges a-b, lists a,b and steps */n, a-b/n or a/n. The macros @hourly, @daily, @weekly,
+// @monthly and @yearly are accepted as well. Expressions that never match, e.g. 0 0 30 2 *, are rejected.
+func ParseCron(expr string) (CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 1 {
+		if macro, ok := cronMacros[fields[0]]; ok {
+			fields = strings.Fields(macro)
+		}
+	}
+	if len(fields) != len(cronFields) {
+		return CronSchedule{}, fmt.Errorf("%w: expected %d fields, got %d", ErrInvalidCron, len(cronFields),
+			len(fields))
+	}
+
+	var bits [len(cronFields)]uint64
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFields[i])
+		if err != nil {
+			return CronSchedule{}, err
+		}
+	}
+
+	dayOfWeek := bits[4]
+	if dayOfWeek&(1<<7) != 0 {
+		dayOfWeek = dayOfWeek&^(1<<7) | 1
+	}
+	schedule := CronSchedule{
+		minute:        bits[0],
+		hour:          bits[1],
+		dayOfMonth:    bits[2],
+		month:         bits[3],
+		dayOfWeek:     dayOfWeek,
+		dayOfMonthAny: strings.HasPrefix(fields[2], "*"),
+		dayOfWeekAny:  strings.HasPrefix(fields[4], "*"),
+	}
+	if schedule.Next(time.Unix(0, 0)).IsZero() {
+		return CronSchedule{}, fmt.Errorf("%w: never matches", ErrInvalidCron)
+	}
+	return schedule, nil
+}
+
+// parseCronField returns the values matched by a field as a bitset.
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		from, to := spec.min, spec.max
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = parseCronValue(first, spec); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if to, err = parseCronValue(last, spec); err != nil {
+					return 0, err
+				}
+				if to < from {
+					return 0, fmt.Errorf("%w: %s range %s is reversed", ErrInvalidCron, spec.name, rangePart)
+				}
+			case !hasStep:
+				to = from
+			}
+		}
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("%w: invalid %s step %q", ErrInvalidCron, spec.name, stepPart)
+			}
+		}
+
+		for value := from; value <= to; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, spec cronField) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < spec.min || n > spec.max {
+		return 0, fmt.Errorf("%w: %s must be a number from %d to %d, got %q", ErrInvalidCron, spec.name, spec.min,
+			spec.max, value)
+	}
+	return n, nil
+}
+
+// Next returns the first time after t matched by the schedule, or the zero time if there is none within the next
+// years.
+func (c CronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.dayOfMonthAny || c.dayOfWeekAny {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	// a wednesday
+	from := time.Date(2025, 12, 10, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2025, 12, 10, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 12, 10, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, 12, 11, 3, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 12, 11, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2025, 12, 11, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 12, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"5,10 8-9/1 * * *", time.Date(2025, 12, 11, 8, 5, 0, 0, time.UTC)},
+		// restricted day of month and day of week match either
+		{"0 0 13 * 5", time.Date(2025, 12, 12, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.next, schedule.Next(from), tt.expr)
+	}
+}
+
+func TestCronNextUsesUTC(t *testing.T) {
+	schedule, err := ParseCron("0 3 * * *")
+	require.NoError(t, err)
+
+	from := time.Date(2025, 12, 10, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, time.Date(2025, 12, 11, 3, 0, 0, 0, time.UTC), schedule.Next(from))
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@often",
+		"0 0 30 2 *",
+	} {
+		_, err := ParseCron(expr)
+		assert.ErrorIs(t, err, ErrInvalidCron, expr)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrDatabaseUnavailable is returned when no database connection could be acquired in time, e.g. because all
+// connections of the pool are in use.
+var ErrDatabaseUnavailable = errors.New("no database connection available")
+
+// PoolOptions configures how the services use the database connection pool.
+type PoolOptions struct {
+	// AcquireTimeout is the maximum time to wait for a free connection when starting a transaction. Zero or less waits
+	// until the context of the operation is done.
+	AcquireTimeout time.Duration
+}
+
+// Pool is the database connection pool used by the services.
+type Pool struct {
+	pool           *pgxpool.Pool
+	acquireTimeout time.Duration
+}
+
+// NewPool wraps pool for use by the services.
+func NewPool(pool *pgxpool.Pool, opts PoolOptions) *Pool {
+	return &Pool{
+		pool:           pool,
+		acquireTimeout: opts.AcquireTimeout,
+	}
+}
+
+// beginTx starts a transaction on a connection of the pool. If no connection can be acquired within the acquire
+// timeout, ErrDatabaseUnavailable is returned.
+func (p *Pool) beginTx(ctx context.Context) (pgx.Tx, error) {
+	if p.acquireTimeout <= 0 {
+		return p.pool.Begin(ctx)
+	}
+
+	// the context only bounds acquiring the connection and BEGIN, the transaction itself is not bound to it
+	acquireCtx, cancel := context.WithTimeout(ctx, p.acquireTimeout)
+	defer cancel()
+
+	tx, err := p.pool.Begin(acquireCtx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			stat := p.pool.Stat()
+			return nil, fmt.Errorf("%w: waited %s, %d of %d connections in use", ErrDatabaseUnavailable,
+				p.acquireTimeout, stat.AcquiredConns(), stat.MaxConns())
+		}
+		return nil, err
+	}
+	return tx, nil
+}
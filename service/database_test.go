@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"cortex/test/fakepg"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePool returns a pool of at most maxConns connections to a fake database.
+func fakePool(t *testing.T, maxConns int32) *Pool {
+	t.Helper()
+
+	pool, _ := recordingFakePool(t, maxConns)
+	return pool
+}
+
+// recordingFakePool is like fakePool but also returns the statements received by the database.
+func recordingFakePool(t *testing.T, maxConns int32) (*Pool, *fakepg.Queries) {
+	t.Helper()
+
+	pool, queries := fakepg.NewPool(t, maxConns)
+	return NewPool(pool, PoolOptions{}), queries
+}
+
+func TestBeginTxPoolExhausted(t *testing.T) {
+	raw, _ := fakepg.NewPool(t, 1)
+	pool := NewPool(raw, PoolOptions{AcquireTimeout: 50 * time.Millisecond})
+
+	ctx := context.Background()
+
+	// hold the only connection
+	tx, err := pool.beginTx(ctx)
+	require.NoError(t, err)
+
+	const requests = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			other, err := pool.beginTx(ctx)
+			if err == nil {
+				_ = other.Rollback(ctx)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.ErrorIs(t, err, ErrDatabaseUnavailable)
+		assert.False(t, errors.Is(err, context.DeadlineExceeded), "timeout should not leak as deadline error")
+	}
+
+	// once the connection is released, transactions can be started again
+	require.NoError(t, tx.Rollback(ctx))
+	tx, err = pool.beginTx(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, tx.Rollback(ctx))
+}
+
+func TestBeginTxCanceledRequest(t *testing.T) {
+	raw, _ := fakepg.NewPool(t, 1)
+	pool := NewPool(raw, PoolOptions{AcquireTimeout: 5 * time.Second})
+
+	tx, err := pool.beginTx(context.Background())
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	// a request that is canceled while waiting is not reported as an unavailable database
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.beginTx(ctx)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrDatabaseUnavailable)
+}
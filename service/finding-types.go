@@ -0,0 +1,230 @@
+package service
+
+import (
+	"cortex/repository"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrFindingTypeExists is returned when registering a finding type whose name is already registered.
+var ErrFindingTypeExists = errors.New("finding type already registered")
+
+// FindingFieldKind is the JSON type of a data field of a finding.
+type FindingFieldKind string
+
+const (
+	FindingFieldString  FindingFieldKind = "string"
+	FindingFieldInteger FindingFieldKind = "integer"
+)
+
+// matches reports whether a decoded JSON value is of the kind.
+func (k FindingFieldKind) matches(value any) bool {
+	switch k {
+	case FindingFieldString:
+		_, ok := value.(string)
+		return ok
+	case FindingFieldInteger:
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		}
+		return false
+	}
+	return true
+}
+
+// mismatch returns the error message for values that are not of the kind.
+func (k FindingFieldKind) mismatch() string {
+	if k == FindingFieldInteger {
+		return "must be an integer"
+	}
+	return "must be a " + string(k)
+}
+
+// FindingFieldError describes an invalid field of the data of a finding.
+type FindingFieldError struct {
+	// Path is the path of the field within the finding, e.g. data.port.
+	Path    string
+	Message string
+}
+
+func (e FindingFieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// findingDataPath returns the path of a data field of a finding.
+func findingDataPath(field string) string {
+	return "data." + field
+}
+
+// FindingDataErrors are all invalid fields of the data of a finding.
+type FindingDataErrors []FindingFieldError
+
+func (e FindingDataErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// FindingFieldErrors returns the invalid fields reported by an ErrInvalidFinding error, nil if the error does not
+// refer to fields.
+func FindingFieldErrors(err error) []FindingFieldError {
+	var dataErrs FindingDataErrors
+	if errors.As(err, &dataErrs) {
+		return dataErrs
+	}
+	var fieldErr FindingFieldError
+	if errors.As(err, &fieldErr) {
+		return []FindingFieldError{fieldErr}
+	}
+	return nil
+}
+
+// FindingTypeDefinition describes a finding type agents can report.
+type FindingTypeDefinition struct {
+	Type repository.FindingType
+	// IdentityFields are the data fields identifying a finding, in hashing order. Findings of an asset with equal
+	// values are the same finding.
+	IdentityFields []string
+	// RequiredFields are the data fields every finding of the type must provide.
+	RequiredFields []string
+	// FieldKinds are the JSON types of data fields. Fields without kind accept any value.
+	FieldKinds map[string]FindingFieldKind
+	// Validate checks the data of a finding after the required fields and field kinds, nil accepts any data.
+	// Returned errors are reported to the agent wrapped in ErrInvalidFinding, a FindingFieldError or
+	// FindingDataErrors point it to the invalid fields.
+	Validate func(data map[string]any) error
+}
+
+var (
+	findingTypesMu sync.RWMutex
+	findingTypes   = map[repository.FindingType]FindingTypeDefinition{}
+)
+
+func init() {
+	for _, definition := range []FindingTypeDefinition{
+		{
+			Type:           repository.FindingTypePort,
+			IdentityFields: []string{"port", "protocol"},
+			RequiredFields: []string{"port", "protocol"},
+			FieldKinds:     map[string]FindingFieldKind{"port": FindingFieldInteger, "protocol": FindingFieldString},
+			Validate:       validatePortFinding,
+		},
+		{
+			Type:           repository.FindingTypeVulnerability,
+			IdentityFields: []string{"template-id", "port"},
+			RequiredFields: []string{"template-id", "severity", "port"},
+			FieldKinds: map[string]FindingFieldKind{
+				"template-id": FindingFieldString,
+				"severity":    FindingFieldString,
+				"port":        FindingFieldInteger,
+			},
+			Validate: validateSeverity,
+		},
+	} {
+		if err := RegisterFindingType(definition); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterFindingType adds a finding type. Types are usually registered during startup, before findings are created.
+func RegisterFindingType(definition FindingTypeDefinition) error {
+	if definition.Type == "" {
+		return errors.New("finding type without name")
+	}
+	if len(definition.IdentityFields) == 0 {
+		return fmt.Errorf("finding type %s without identity fields", definition.Type)
+	}
+
+	findingTypesMu.Lock()
+	defer findingTypesMu.Unlock()
+
+	if _, ok := findingTypes[definition.Type]; ok {
+		return fmt.Errorf("%w: %s", ErrFindingTypeExists, definition.Type)
+	}
+	findingTypes[definition.Type] = definition
+	return nil
+}
+
+// GetFindingType returns the registered finding type with the given name.
+func GetFindingType(findingType repository.FindingType) (FindingTypeDefinition, bool) {
+	findingTypesMu.RLock()
+	defer findingTypesMu.RUnlock()
+
+	definition, ok := findingTypes[findingType]
+	return definition, ok
+}
+
+// FindingTypeNames returns the sorted names of all registered finding types.
+func FindingTypeNames() []string {
+	findingTypesMu.RLock()
+	defer findingTypesMu.RUnlock()
+
+	names := make([]string, 0, len(findingTypes))
+	for findingType := range findingTypes {
+		names = append(names, string(findingType))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// portProtocols are the transport protocols of port findings.
+var portProtocols = []string{"tcp", "udp"}
+
+// validatePortFinding checks that the port is a valid port number and the protocol is one of portProtocols.
+func validatePortFinding(data map[string]any) error {
+	var fieldErrs FindingDataErrors
+	if port := integerValue(data["port"]); port < 1 || port > math.MaxUint16 {
+		fieldErrs = append(fieldErrs, FindingFieldError{
+			Path:    findingDataPath("port"),
+			Message: fmt.Sprintf("must be between 1 and %d", math.MaxUint16),
+		})
+	}
+	if protocol, _ := data["protocol"].(string); !slices.Contains(portProtocols, protocol) {
+		fieldErrs = append(fieldErrs, FindingFieldError{
+			Path:    findingDataPath("protocol"),
+			Message: "must be one of " + strings.Join(portProtocols, ", "),
+		})
+	}
+	if len(fieldErrs) > 0 {
+		return fieldErrs
+	}
+	return nil
+}
+
+// integerValue returns a value of kind FindingFieldInteger as int64.
+func integerValue(value any) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+func validateSeverity(data map[string]any) error {
+	severity, _ := data["severity"].(string)
+	if !slices.Contains(repository.Severities, repository.Severity(severity)) {
+		values := make([]string, 0, len(repository.Severities))
+		for _, value := range repository.Severities {
+			values = append(values, string(value))
+		}
+		return FindingFieldError{
+			Path:    findingDataPath("severity"),
+			Message: "must be one of " + strings.Join(values, ", "),
+		}
+	}
+	return nil
+}
@@ -7,35 +7,73 @@ import (
 	"cortex/repository"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
-	"hash"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 )
 
+// ErrInvalidFinding is returned when the data of a finding does not match its type.
+var ErrInvalidFinding = errors.New("invalid finding")
+
 type CreateFindingOptions struct {
 	AssetID string
 	Type    repository.FindingType
 	Data    map[string]any
 }
 
+// GetFindingOptions controls which findings GetFinding and GetFindingWithAsset return.
+type GetFindingOptions struct {
+	// IncludeDeleted also returns the findings of deleted assets. Their asset is flagged with
+	// repository.ScanAsset.Deleted.
+	IncludeDeleted bool
+}
+
 type FindingService interface {
 	CreateFinding(ctx context.Context, opts CreateFindingOptions) (*repository.AssetFinding, error)
-	GetFinding(ctx context.Context, id string) (*repository.AssetFinding, error)
+	GetFinding(ctx context.Context, id string, opts GetFindingOptions) (*repository.AssetFinding, error)
+	// GetFindingWithAsset returns a finding together with the asset it was found on.
+	GetFindingWithAsset(ctx context.Context, id string, opts GetFindingOptions) (*repository.AssetFindingWithAsset, error)
+	// UpdateFindingTriage sets the triage status of a finding of an asset. repository.ErrNotFound is returned if the
+	// asset has no finding with this id.
+	UpdateFindingTriage(ctx context.Context, assetID string, findingID string, status repository.TriageStatus) (*repository.AssetFinding, error)
+	// RecomputeFindingHashes recalculates the hashes of all stored findings and merges findings that turn out to be
+	// duplicates. Assets are processed in batches, each in its own transaction.
+	RecomputeFindingHashes(ctx context.Context) (*FindingRehashResult, error)
+}
+
+// FindingRehashResult summarizes a run of RecomputeFindingHashes.
+type FindingRehashResult struct {
+	// Assets is the number of assets whose findings have been processed.
+	Assets int `json:"assets"`
+	// Updated is the number of findings whose hash or seen times changed.
+	Updated int `json:"updated"`
+	// Merged is the number of duplicate findings merged into another finding and removed.
+	Merged int `json:"merged"`
+}
+
+// findingRehashBatchSize is the number of assets whose findings are recomputed in one transaction.
+const findingRehashBatchSize = 100
+
+// FindingNotifier is informed about new findings reported for the assets of active scans.
+type FindingNotifier interface {
+	NotifyFindings(scanID string, count int)
 }
 
 type findingService struct {
-	repo   repository.ScanRepository
-	logger *slog.Logger
-	pool   *pgxpool.Pool
+	repo     repository.ScanRepository
+	logger   *slog.Logger
+	pool     *Pool
+	notifier FindingNotifier
 }
 
-func (s findingService) GetFinding(ctx context.Context, id string) (*repository.AssetFinding, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s findingService) GetFinding(ctx context.Context, id string, opts GetFindingOptions) (*repository.AssetFinding, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +81,7 @@ func (s findingService) GetFinding(ctx context.Context, id string) (*repository.
 		_ = tx.Rollback(ctx)
 	}()
 
-	finding, err := s.repo.GetAssetFinding(ctx, tx, id)
+	finding, err := s.getFinding(ctx, tx, id, opts)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "unable to get finding", logging.FieldError, err)
 		return nil, err
@@ -52,7 +90,54 @@ func (s findingService) GetFinding(ctx context.Context, id string) (*repository.
 	return finding, nil
 }
 
+func (s findingService) GetFindingWithAsset(ctx context.Context, id string, opts GetFindingOptions) (*repository.AssetFindingWithAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	finding, err := s.getFinding(ctx, tx, id, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "unable to get finding", logging.FieldError, err)
+		return nil, err
+	}
+
+	var asset *repository.ScanAsset
+	if opts.IncludeDeleted {
+		asset, err = s.repo.GetScanAssetIncludingDeleted(ctx, tx, finding.AssetID)
+	} else {
+		asset, err = s.repo.GetScanAsset(ctx, tx, finding.AssetID)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "unable to get asset of finding",
+			logging.FieldAssetID, finding.AssetID, logging.FieldError, err)
+		return nil, err
+	}
+
+	return &repository.AssetFindingWithAsset{
+		AssetFinding: *finding,
+		Asset:        *asset,
+	}, nil
+}
+
+// getFinding returns a finding, including findings of deleted assets if opts.IncludeDeleted is set.
+func (s findingService) getFinding(ctx context.Context, tx pgx.Tx, id string, opts GetFindingOptions) (*repository.AssetFinding, error) {
+	if opts.IncludeDeleted {
+		return s.repo.GetAssetFindingIncludingDeleted(ctx, tx, id)
+	}
+	return s.repo.GetAssetFinding(ctx, tx, id)
+}
+
 func (s findingService) CreateFinding(ctx context.Context, opts CreateFindingOptions) (*repository.AssetFinding, error) {
+	err := validateFindingData(opts.Type, opts.Data)
+	if err != nil {
+		s.logger.WarnContext(ctx, "rejected finding", logging.FieldAssetID, opts.AssetID, logging.FieldError, err)
+		return nil, err
+	}
+
 	findingHash, err := s.calculateFindingHash(opts.Type, opts.Data)
 	if err != nil {
 		s.logger.Error("unable to calculate finding hash", logging.FieldError, err)
@@ -65,20 +150,41 @@ func (s findingService) CreateFinding(ctx context.Context, opts CreateFindingOpt
 		return nil, err
 	}
 
+	now := time.Now()
 	finding := repository.AssetFinding{
 		ID:          uuid.New().String(),
 		AssetID:     opts.AssetID,
-		CreatedAt:   time.Now(),
+		CreatedAt:   now,
+		FirstSeen:   now,
+		LastSeen:    now,
 		Type:        opts.Type,
 		Data:        opts.Data,
 		FindingHash: findingHash,
 		AgentID:     agentInfo.AgentID,
 	}
 
-	tx, err := s.pool.Begin(ctx)
+	stored, scanIDs, err := s.putFinding(ctx, finding)
 	if err != nil {
 		return nil, err
 	}
+
+	// notify once the transaction has been committed
+	if s.notifier != nil {
+		for _, scanID := range scanIDs {
+			s.notifier.NotifyFindings(scanID, 1)
+		}
+	}
+
+	return stored, nil
+}
+
+// putFinding stores a finding in its own transaction and returns the stored finding with the IDs of the active scans
+// of its asset if the finding is new. Commit errors are returned.
+func (s findingService) putFinding(ctx context.Context, finding repository.AssetFinding) (stored *repository.AssetFinding, scanIDs []string, err error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer func() {
 		switch err {
 		case nil:
@@ -88,66 +194,289 @@ func (s findingService) CreateFinding(ctx context.Context, opts CreateFindingOpt
 		}
 	}()
 
-	err = s.repo.PutAssetFinding(ctx, tx, finding)
+	stored, scanIDs, err = s.repo.PutAssetFinding(ctx, tx, finding)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "unable to store finding in database", logging.FieldError, err)
+		return nil, nil, err
+	}
+
+	return stored, scanIDs, nil
+}
+
+func (s findingService) UpdateFindingTriage(ctx context.Context, assetID string, findingID string, status repository.TriageStatus) (*repository.AssetFinding, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	finding, err := s.repo.GetAssetFinding(ctx, tx, findingID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			s.logger.ErrorContext(ctx, "unable to get finding", logging.FieldError, err)
+		}
+		return nil, err
+	}
+	if finding.AssetID != assetID {
+		err = repository.ErrNotFound
+		return nil, err
+	}
+
+	err = s.repo.UpdateAssetFindingTriage(ctx, tx, findingID, status)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "unable to update triage status of finding",
+			logging.FieldAssetID, assetID, logging.FieldError, err)
+		return nil, err
+	}
+	finding.TriageStatus = status
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("set triage status of finding %s to %s", findingID, status),
+		logging.FieldAssetID, assetID)
+	return finding, nil
+}
+
+func (s findingService) RecomputeFindingHashes(ctx context.Context) (*FindingRehashResult, error) {
+	result := &FindingRehashResult{}
+	afterID := ""
+	for {
+		assetIDs, batch, err := s.recomputeFindingHashBatch(ctx, afterID)
+		if err != nil {
+			return nil, err
+		}
+		result.Assets += batch.Assets
+		result.Updated += batch.Updated
+		result.Merged += batch.Merged
+
+		if len(assetIDs) < findingRehashBatchSize {
+			break
+		}
+		afterID = assetIDs[len(assetIDs)-1]
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("recomputed finding hashes of %d assets, %d updated, %d merged",
+		result.Assets, result.Updated, result.Merged))
+	return result, nil
+}
+
+// recomputeFindingHashBatch recomputes the finding hashes of the next batch of assets after afterID and returns the
+// processed asset IDs.
+func (s findingService) recomputeFindingHashBatch(ctx context.Context, afterID string) ([]string, *FindingRehashResult, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	assetIDs, err := s.repo.ListFindingAssetIDs(ctx, tx, afterID, findingRehashBatchSize)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "unable to list assets with findings", logging.FieldError, err)
+		return nil, nil, err
+	}
+
+	batch := FindingRehashResult{}
+	for _, assetID := range assetIDs {
+		var findings []repository.AssetFinding
+		findings, err = s.repo.ListAssetFindings(ctx, tx, assetID, repository.FindingFilter{})
+		if err != nil {
+			s.logger.ErrorContext(ctx, "unable to list findings", logging.FieldAssetID, assetID, logging.FieldError, err)
+			return nil, nil, err
+		}
 
-	return &finding, nil
+		var updated []repository.AssetFinding
+		var merged map[string]string
+		updated, merged, err = s.rehashFindings(findings)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "unable to recompute finding hashes",
+				logging.FieldAssetID, assetID, logging.FieldError, err)
+			return nil, nil, err
+		}
+
+		if len(merged) > 0 {
+			if err = s.repo.MergeAssetFindings(ctx, tx, merged); err != nil {
+				s.logger.ErrorContext(ctx, "unable to delete duplicate findings",
+					logging.FieldAssetID, assetID, logging.FieldError, err)
+				return nil, nil, err
+			}
+		}
+		if len(updated) > 0 {
+			if err = s.repo.UpdateAssetFindings(ctx, tx, updated); err != nil {
+				s.logger.ErrorContext(ctx, "unable to update finding hashes",
+					logging.FieldAssetID, assetID, logging.FieldError, err)
+				return nil, nil, err
+			}
+		}
+
+		batch.Assets++
+		batch.Updated += len(updated)
+		batch.Merged += len(merged)
+	}
+
+	return assetIDs, &batch, nil
+}
+
+// rehashFindings recalculates the hashes of the findings of a single asset. Findings sharing a hash are merged into
+// the one seen most recently, which takes over the earliest first seen time and, if it is open, the triage status of
+// the most recently seen duplicate that is not. It returns the findings whose stored
+// state changed and the IDs of the findings that have been merged into others, mapped to the ID of the finding they
+// have been merged into.
+func (s findingService) rehashFindings(findings []repository.AssetFinding) ([]repository.AssetFinding, map[string]string, error) {
+	type rehashed struct {
+		finding repository.AssetFinding
+		changed bool
+		merged  []string
+		// triaged is the most recently seen merged finding whose triage status is not open
+		triaged *repository.AssetFinding
+	}
+
+	byHash := make(map[string]*rehashed)
+	var hashes []string
+	for _, finding := range findings {
+		findingHash, err := s.calculateFindingHash(finding.Type, finding.Data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("finding %s: %w", finding.ID, err)
+		}
+
+		changed := findingHash != finding.FindingHash
+		finding.FindingHash = findingHash
+
+		kept, ok := byHash[findingHash]
+		if !ok {
+			byHash[findingHash] = &rehashed{finding: finding, changed: changed}
+			hashes = append(hashes, findingHash)
+			continue
+		}
+
+		firstSeen := kept.finding.FirstSeen
+		merged := finding
+		if finding.LastSeen.After(kept.finding.LastSeen) {
+			merged = kept.finding
+			kept.merged = append(kept.merged, kept.finding.ID)
+			kept.finding, kept.changed = finding, changed
+		} else {
+			kept.merged = append(kept.merged, finding.ID)
+			firstSeen = finding.FirstSeen
+		}
+		if firstSeen.Before(kept.finding.FirstSeen) {
+			kept.finding.FirstSeen = firstSeen
+			kept.changed = true
+		}
+		if merged.TriageStatus != repository.TriageStatusOpen &&
+			(kept.triaged == nil || merged.LastSeen.After(kept.triaged.LastSeen)) {
+			kept.triaged = &merged
+		}
+	}
+
+	var updated []repository.AssetFinding
+	merged := make(map[string]string)
+	for _, findingHash := range hashes {
+		kept := byHash[findingHash]
+		// an open finding takes over the triage decision about its duplicates
+		if kept.finding.TriageStatus == repository.TriageStatusOpen && kept.triaged != nil {
+			kept.finding.TriageStatus = kept.triaged.TriageStatus
+			kept.changed = true
+		}
+		if kept.changed {
+			updated = append(updated, kept.finding)
+		}
+		for _, id := range kept.merged {
+			merged[id] = kept.finding.ID
+		}
+	}
+	return updated, merged, nil
 }
 
 func (s findingService) calculateFindingHash(findingType repository.FindingType, findingData map[string]any) (string, error) {
+	definition, ok := GetFindingType(findingType)
+	if !ok {
+		return "", fmt.Errorf("unsupported finding type %s", findingType)
+	}
+
 	calculator := newFindingHashCalculator(findingData)
-	switch findingType {
-	case repository.FindingTypePort:
-		return calculator.addField("port").addField("protocol").calculateHash()
-	case repository.FindingTypeVulnerability:
-		return calculator.addField("template-id").addField("port").calculateHash()
+	for _, field := range definition.IdentityFields {
+		calculator.addField(field)
+	}
+	return calculator.calculateHash()
+}
+
+func validateFindingData(findingType repository.FindingType, findingData map[string]any) error {
+	definition, ok := GetFindingType(findingType)
+	if !ok {
+		return fmt.Errorf("%w: unsupported finding type %s", ErrInvalidFinding, findingType)
+	}
+	var fieldErrs FindingDataErrors
+	for _, field := range definition.RequiredFields {
+		if _, ok := findingData[field]; !ok {
+			fieldErrs = append(fieldErrs, FindingFieldError{Path: findingDataPath(field), Message: "is required"})
+		}
+	}
+	for _, field := range slices.Sorted(maps.Keys(definition.FieldKinds)) {
+		kind := definition.FieldKinds[field]
+		if value, ok := findingData[field]; ok && !kind.matches(value) {
+			fieldErrs = append(fieldErrs, FindingFieldError{Path: findingDataPath(field), Message: kind.mismatch()})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidFinding, fieldErrs)
+	}
 
+	if definition.Validate != nil {
+		if err := definition.Validate(findingData); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidFinding, err)
+		}
 	}
-	return "", errors.New("unsupported finding type")
+
+	return nil
 }
 
-func NewFindingService(repo repository.ScanRepository, pool *pgxpool.Pool) FindingService {
+// NewFindingService creates a FindingService. notifier may be nil if nobody is interested in findings of active
+// scans.
+func NewFindingService(repo repository.ScanRepository, pool *Pool, notifier FindingNotifier) FindingService {
 	return &findingService{
-		repo:   repo,
-		pool:   pool,
-		logger: logging.GetLogger(logging.Scan),
+		repo:     repo,
+		pool:     pool,
+		logger:   logging.GetLogger(logging.Scan),
+		notifier: notifier,
 	}
 }
 
+// findingHashCalculator hashes the canonical JSON array of the added fields, so equal values produce equal hashes
+// regardless of their Go types and values of different fields cannot run into each other.
 type findingHashCalculator struct {
-	data     map[string]any
-	errors   []error
-	hashFunc hash.Hash
+	data   map[string]any
+	values []any
 }
 
 func (c *findingHashCalculator) addField(field string) *findingHashCalculator {
-	fieldValue, err := json.Marshal(c.data[field])
-	if err != nil {
-		c.errors = append(c.errors, err)
-		return c
-	}
-	c.hashFunc.Write(fieldValue)
+	c.values = append(c.values, c.data[field])
 	return c
 }
 
 func (c *findingHashCalculator) calculateHash() (string, error) {
-	if len(c.errors) > 0 {
-		errorString := ""
-		for _, err := range c.errors {
-			errorString += err.Error() + ","
-		}
-		return "", errors.New("unable to calculate finding hash: " + errorString)
+	canonical, err := canonicalJSON(c.values)
+	if err != nil {
+		return "", fmt.Errorf("unable to calculate finding hash: %w", err)
 	}
 
-	return hex.EncodeToString(c.hashFunc.Sum(nil)), nil
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 func newFindingHashCalculator(data map[string]any) *findingHashCalculator {
 	return &findingHashCalculator{
-		data:     data,
-		hashFunc: sha256.New(),
+		data: data,
 	}
 }
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"cortex/repository"
+	"cortex/test/fakepg"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFindingData(t *testing.T) {
+	tests := []struct {
+		name        string
+		findingType repository.FindingType
+		data        map[string]any
+		valid       bool
+	}{
+		{"port", repository.FindingTypePort, map[string]any{"port": 443, "protocol": "tcp"}, true},
+		{"port without protocol", repository.FindingTypePort, map[string]any{"port": 443}, false},
+		{"udp port", repository.FindingTypePort, map[string]any{"port": float64(53), "protocol": "udp"}, true},
+		{"highest port", repository.FindingTypePort, map[string]any{"port": 65535, "protocol": "tcp"}, true},
+		{"port zero", repository.FindingTypePort, map[string]any{"port": 0, "protocol": "tcp"}, false},
+		{"port out of range", repository.FindingTypePort, map[string]any{"port": 65536, "protocol": "tcp"}, false},
+		{"unknown protocol", repository.FindingTypePort, map[string]any{"port": 443, "protocol": "icmp"}, false},
+		{"vulnerability without severity", repository.FindingTypeVulnerability,
+			map[string]any{"template-id": "CVE-2021-44228", "port": 8080}, false},
+		{"vulnerability", repository.FindingTypeVulnerability,
+			map[string]any{"template-id": "CVE-2021-44228", "severity": "critical", "port": 8080}, true},
+		{"vulnerability without template", repository.FindingTypeVulnerability,
+			map[string]any{"severity": "high", "port": 8080}, false},
+		{"vulnerability with unknown severity", repository.FindingTypeVulnerability,
+			map[string]any{"template-id": "CVE-2021-44228", "severity": "severe", "port": 8080}, false},
+		{"unknown type", repository.FindingType("secret"), map[string]any{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFindingData(tt.findingType, tt.data)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidFinding)
+			}
+		})
+	}
+}
+
+func TestValidateFindingDataFieldErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		findingType repository.FindingType
+		data        map[string]any
+		expected    []FindingFieldError
+	}{
+		{"missing key", repository.FindingTypePort, map[string]any{"port": 443},
+			[]FindingFieldError{{Path: "data.protocol", Message: "is required"}}},
+		{"wrong type", repository.FindingTypePort, map[string]any{"port": "443", "protocol": "tcp"},
+			[]FindingFieldError{{Path: "data.port", Message: "must be an integer"}}},
+		{"fraction", repository.FindingTypePort, map[string]any{"port": 443.5, "protocol": "tcp"},
+			[]FindingFieldError{{Path: "data.port", Message: "must be an integer"}}},
+		{"decoded integer", repository.FindingTypePort, map[string]any{"port": float64(443), "protocol": "tcp"}, nil},
+		{"all invalid fields", repository.FindingTypeVulnerability, map[string]any{"port": true, "severity": 5},
+			[]FindingFieldError{
+				{Path: "data.template-id", Message: "is required"},
+				{Path: "data.port", Message: "must be an integer"},
+				{Path: "data.severity", Message: "must be a string"},
+			}},
+		{"invalid port and protocol", repository.FindingTypePort, map[string]any{"port": -1, "protocol": "sctp"},
+			[]FindingFieldError{
+				{Path: "data.port", Message: "must be between 1 and 65535"},
+				{Path: "data.protocol", Message: "must be one of tcp, udp"},
+			}},
+		{"unknown severity", repository.FindingTypeVulnerability,
+			map[string]any{"template-id": "CVE-2021-44228", "severity": "severe", "port": 8080},
+			[]FindingFieldError{{Path: "data.severity", Message: "must be one of info, low, medium, high, critical"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFindingData(tt.findingType, tt.data)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidFinding)
+			assert.Equal(t, tt.expected, FindingFieldErrors(err))
+		})
+	}
+}
+
+func TestCalculateFindingHashNormalizesTypes(t *testing.T) {
+	s := findingService{}
+
+	expected, err := s.calculateFindingHash(repository.FindingTypePort, map[string]any{"port": 443, "protocol": "tcp"})
+	require.NoError(t, err)
+
+	equivalent := []map[string]any{
+		{"port": float64(443), "protocol": "tcp"},
+		{"port": int64(443), "protocol": "tcp"},
+		{"port": uint16(443), "protocol": "tcp"},
+		{"port": float32(443), "protocol": "tcp"},
+		{"port": json.Number("443"), "protocol": "tcp"},
+		{"port": json.Number("443.0"), "protocol": "tcp"},
+		{"port": json.Number("4.43e2"), "protocol": "tcp"},
+	}
+	for _, data := range equivalent {
+		actual, err := s.calculateFindingHash(repository.FindingTypePort, data)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, "%T %v", data["port"], data["port"])
+	}
+
+	// data decoded from agent requests hashes like the same data built in Go
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{"protocol":"tcp","port":443.0}`), &decoded))
+	actual, err := s.calculateFindingHash(repository.FindingTypePort, decoded)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	different, err := s.calculateFindingHash(repository.FindingTypePort, map[string]any{"port": 443.5, "protocol": "tcp"})
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, different)
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "sorted keys", value: map[string]any{"b": 1, "a": map[string]any{"d": true, "c": nil}},
+			expected: `{"a":{"c":null,"d":true},"b":1}`},
+		{name: "integral float", value: []any{1.0, float32(2), json.Number("3.00"), json.Number("-0")},
+			expected: `[1,2,3,0]`},
+		{name: "fractions", value: []any{0.5, json.Number("2.50"), 1e300}, expected: `[0.5,2.5,1e+300]`},
+		{name: "large integer", value: json.Number("123456789012345678901234567890"),
+			expected: `123456789012345678901234567890`},
+		{name: "typed slice", value: []int{80, 443}, expected: `[80,443]`},
+		{name: "values do not run into each other", value: []any{"a,", "b"}, expected: `["a,","b"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := canonicalJSON(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(actual))
+		})
+	}
+}
+
+func TestCalculateFindingHashIdentifiesDuplicates(t *testing.T) {
+	s := findingService{}
+
+	first, err := s.calculateFindingHash(repository.FindingTypePort, map[string]any{"port": 443, "protocol": "tcp"})
+	assert.NoError(t, err)
+	// findings are deduplicated on the hash, details outside the identifying fields do not matter
+	second, err := s.calculateFindingHash(repository.FindingTypePort,
+		map[string]any{"protocol": "tcp", "port": 443, "service": "https"})
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	other, err := s.calculateFindingHash(repository.FindingTypePort, map[string]any{"port": 80, "protocol": "tcp"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, other)
+}
+
+func TestCreateFindingDeduplicates(t *testing.T) {
+	repo := &memoryScanRepository{findings: map[string]repository.AssetFinding{}}
+	s := NewFindingService(repo, fakePool(t, 2), nil)
+	ctx := context.WithValue(context.Background(), cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "agent"})
+	opts := CreateFindingOptions{
+		AssetID: "asset",
+		Type:    repository.FindingTypePort,
+		Data:    map[string]any{"port": 443, "protocol": "tcp"},
+	}
+
+	first, err := s.CreateFinding(ctx, opts)
+	require.NoError(t, err)
+	second, err := s.CreateFinding(ctx, opts)
+	require.NoError(t, err)
+
+	// the second report updates the stored finding instead of adding another one
+	assert.Len(t, repo.findings, 1)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, first.FirstSeen, second.FirstSeen)
+	assert.False(t, second.LastSeen.Before(first.LastSeen))
+}
+
+// findingNotifications records the findings reported to a FindingNotifier by scan ID.
+type findingNotifications map[string]int
+
+func (n findingNotifications) NotifyFindings(scanID string, count int) {
+	n[scanID] += count
+}
+
+func TestCreateFindingNotifiesActiveScans(t *testing.T) {
+	repo := &memoryScanRepository{
+		findings:      map[string]repository.AssetFinding{},
+		activeScanIDs: map[string][]string{"asset": {"queued", "running"}},
+	}
+	notifications := findingNotifications{}
+	s := NewFindingService(repo, fakePool(t, 2), notifications)
+	ctx := context.WithValue(context.Background(), cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "agent"})
+
+	// the second report of port 22 refreshes the stored finding and is not counted again
+	for _, port := range []int{22, 443, 22} {
+		_, err := s.CreateFinding(ctx, CreateFindingOptions{
+			AssetID: "asset",
+			Type:    repository.FindingTypePort,
+			Data:    map[string]any{"port": port, "protocol": "tcp"},
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, findingNotifications{"queued": 2, "running": 2}, notifications)
+}
+
+func TestCreateFindingNotifiesAfterCommit(t *testing.T) {
+	repo := &memoryScanRepository{
+		findings:      map[string]repository.AssetFinding{},
+		activeScanIDs: map[string][]string{"asset": {"running"}},
+	}
+	raw, _ := fakepg.NewFailingPool(t, 1, func(query string) bool { return query == "commit" })
+	notifications := findingNotifications{}
+	s := NewFindingService(repo, NewPool(raw, PoolOptions{}), notifications)
+	ctx := context.WithValue(context.Background(), cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "agent"})
+
+	_, err := s.CreateFinding(ctx, CreateFindingOptions{
+		AssetID: "asset",
+		Type:    repository.FindingTypePort,
+		Data:    map[string]any{"port": 22, "protocol": "tcp"},
+	})
+	assert.Error(t, err)
+	assert.Empty(t, notifications)
+}
+
+func TestRehashFindingsMergesDuplicates(t *testing.T) {
+	s := findingService{}
+	portHash, err := s.calculateFindingHash(repository.FindingTypePort, map[string]any{"port": 443, "protocol": "tcp"})
+	assert.NoError(t, err)
+	current, err := s.calculateFindingHash(repository.FindingTypePort, map[string]any{"port": 22, "protocol": "tcp"})
+	assert.NoError(t, err)
+
+	day := func(d int) time.Time {
+		return time.Date(2025, 12, d, 0, 0, 0, 0, time.UTC)
+	}
+	findings := []repository.AssetFinding{
+		// stale hashes of the same port, the second one has been seen most recently
+		{ID: "f1", Type: repository.FindingTypePort, Data: map[string]any{"port": 443, "protocol": "tcp"},
+			FindingHash: "stale-1", FirstSeen: day(1), LastSeen: day(3)},
+		{ID: "f2", Type: repository.FindingTypePort, Data: map[string]any{"port": 443, "protocol": "tcp", "service": "https"},
+			FindingHash: "stale-2", FirstSeen: day(2), LastSeen: day(5)},
+		// already up to date
+		{ID: "f3", Type: repository.FindingTypePort, Data: map[string]any{"port": 22, "protocol": "tcp"},
+			FindingHash: current, FirstSeen: day(1), LastSeen: day(1)},
+	}
+
+	updated, merged, err := s.rehashFindings(findings)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"f1": "f2"}, merged)
+	assert.Len(t, updated, 1)
+	assert.Equal(t, "f2", updated[0].ID)
+	assert.Equal(t, portHash, updated[0].FindingHash)
+	assert.Equal(t, day(1), updated[0].FirstSeen)
+	assert.Equal(t, day(5), updated[0].LastSeen)
+	assert.Equal(t, "https", updated[0].Data["service"])
+}
+
+func TestRehashFindingsKeepsTriageStatus(t *testing.T) {
+	s := findingService{}
+	day := func(d int) time.Time {
+		return time.Date(2025, 12, d, 0, 0, 0, 0, time.UTC)
+	}
+	// duplicates of the same port with stale hashes, seen last on the given days
+	finding := func(id string, lastSeen int, status repository.TriageStatus) repository.AssetFinding {
+		return repository.AssetFinding{ID: id, Type: repository.FindingTypePort,
+			Data: map[string]any{"port": 443, "protocol": "tcp"}, FindingHash: "stale-" + id, FirstSeen: day(1),
+			LastSeen: day(lastSeen), TriageStatus: status}
+	}
+
+	tests := []struct {
+		name     string
+		findings []repository.AssetFinding
+		expected repository.TriageStatus
+	}{
+		{
+			name: "triaged duplicate merged into open finding",
+			findings: []repository.AssetFinding{
+				finding("f1", 3, repository.TriageStatusFalsePositive),
+				finding("f2", 5, repository.TriageStatusOpen),
+			},
+			expected: repository.TriageStatusFalsePositive,
+		},
+		{
+			name: "triaged finding keeps its status",
+			findings: []repository.AssetFinding{
+				finding("f1", 3, repository.TriageStatusOpen),
+				finding("f2", 5, repository.TriageStatusAcknowledged),
+			},
+			expected: repository.TriageStatusAcknowledged,
+		},
+		{
+			name: "most recently seen triaged duplicate",
+			findings: []repository.AssetFinding{
+				finding("f1", 2, repository.TriageStatusWontFix),
+				finding("f2", 5, repository.TriageStatusOpen),
+				finding("f3", 4, repository.TriageStatusFalsePositive),
+			},
+			expected: repository.TriageStatusFalsePositive,
+		},
+		{
+			name: "all open",
+			findings: []repository.AssetFinding{
+				finding("f1", 3, repository.TriageStatusOpen),
+				finding("f2", 5, repository.TriageStatusOpen),
+			},
+			expected: repository.TriageStatusOpen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the result does not depend on the order the findings are listed in
+			reversed := slices.Clone(tt.findings)
+			slices.Reverse(reversed)
+			for _, findings := range [][]repository.AssetFinding{tt.findings, reversed} {
+				updated, merged, err := s.rehashFindings(findings)
+				require.NoError(t, err)
+				require.Len(t, updated, 1)
+				assert.Len(t, merged, len(tt.findings)-1)
+				assert.Equal(t, tt.expected, updated[0].TriageStatus)
+			}
+		})
+	}
+}
+
+func TestRecomputeFindingHashes(t *testing.T) {
+	s := findingService{}
+	https, err := s.calculateFindingHash(repository.FindingTypePort, map[string]any{"port": 443, "protocol": "tcp"})
+	require.NoError(t, err)
+	ssh, err := s.calculateFindingHash(repository.FindingTypePort, map[string]any{"port": 22, "protocol": "tcp"})
+	require.NoError(t, err)
+
+	day := func(d int) time.Time {
+		return time.Date(2025, 12, d, 0, 0, 0, 0, time.UTC)
+	}
+	port := func(port int) map[string]any {
+		return map[string]any{"port": port, "protocol": "tcp"}
+	}
+	repo := &memoryScanRepository{findings: map[string]repository.AssetFinding{
+		// duplicates stored under stale hashes
+		"a1": {ID: "a1", AssetID: "a", Type: repository.FindingTypePort, Data: port(443), FindingHash: "stale-1",
+			FirstSeen: day(1), LastSeen: day(2)},
+		"a2": {ID: "a2", AssetID: "a", Type: repository.FindingTypePort, Data: port(443), FindingHash: "stale-2",
+			FirstSeen: day(3), LastSeen: day(4)},
+		"a3": {ID: "a3", AssetID: "a", Type: repository.FindingTypePort, Data: port(22), FindingHash: ssh,
+			FirstSeen: day(1), LastSeen: day(1)},
+		// the same port on another asset is no duplicate
+		"b1": {ID: "b1", AssetID: "b", Type: repository.FindingTypePort, Data: port(443), FindingHash: "stale-1",
+			FirstSeen: day(2), LastSeen: day(2)},
+	}}
+	svc := NewFindingService(repo, fakePool(t, 1), nil)
+
+	result, err := svc.RecomputeFindingHashes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &FindingRehashResult{Assets: 2, Updated: 2, Merged: 1}, result)
+
+	require.Len(t, repo.findings, 3)
+	assert.NotContains(t, repo.findings, "a1")
+	assert.Equal(t, https, repo.findings["a2"].FindingHash)
+	assert.Equal(t, day(1), repo.findings["a2"].FirstSeen)
+	assert.Equal(t, day(4), repo.findings["a2"].LastSeen)
+	assert.Equal(t, ssh, repo.findings["a3"].FindingHash)
+	assert.Equal(t, https, repo.findings["b1"].FindingHash)
+}
+
+func TestRehashFindingsUpToDate(t *testing.T) {
+	s := findingService{}
+	data := map[string]any{"template-id": "CVE-2021-44228", "severity": "critical", "port": 8080}
+	findingHash, err := s.calculateFindingHash(repository.FindingTypeVulnerability, data)
+	assert.NoError(t, err)
+
+	updated, merged, err := s.rehashFindings([]repository.AssetFinding{
+		{ID: "f1", Type: repository.FindingTypeVulnerability, Data: data, FindingHash: findingHash},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, updated)
+	assert.Empty(t, merged)
+}
+
+func TestRegisterFindingType(t *testing.T) {
+	certificate := FindingTypeDefinition{
+		Type:           "certificate",
+		IdentityFields: []string{"port", "fingerprint"},
+		RequiredFields: []string{"port", "fingerprint", "expires"},
+		Validate: func(data map[string]any) error {
+			if _, ok := data["expires"].(string); !ok {
+				return errors.New("expires must be a string")
+			}
+			return nil
+		},
+	}
+	require.NoError(t, RegisterFindingType(certificate))
+	t.Cleanup(func() {
+		findingTypesMu.Lock()
+		delete(findingTypes, certificate.Type)
+		findingTypesMu.Unlock()
+	})
+
+	assert.ErrorIs(t, RegisterFindingType(certificate), ErrFindingTypeExists)
+	assert.Error(t, RegisterFindingType(FindingTypeDefinition{Type: "service"}))
+	assert.Equal(t, []string{"certificate", "port", "vulnerability"}, FindingTypeNames())
+
+	repo := &memoryScanRepository{findings: map[string]repository.AssetFinding{}}
+	s := NewFindingService(repo, fakePool(t, 1), nil)
+	ctx := context.WithValue(context.Background(), cortexContext.KeyAgentInfo, cortexContext.AgentInfoData{AgentID: "agent"})
+
+	_, err := s.CreateFinding(ctx, CreateFindingOptions{
+		AssetID: "asset",
+		Type:    certificate.Type,
+		Data:    map[string]any{"port": 443, "fingerprint": "ab:cd", "expires": 2026},
+	})
+	assert.ErrorIs(t, err, ErrInvalidFinding)
+
+	first, err := s.CreateFinding(ctx, CreateFindingOptions{
+		AssetID: "asset",
+		Type:    certificate.Type,
+		Data:    map[string]any{"port": 443, "fingerprint": "ab:cd", "expires": "2026-01-01"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, certificate.Type, first.Type)
+
+	// data outside the identity fields does not make a new finding
+	second, err := s.CreateFinding(ctx, CreateFindingOptions{
+		AssetID: "asset",
+		Type:    certificate.Type,
+		Data:    map[string]any{"port": 443, "fingerprint": "ab:cd", "expires": "2027-01-01"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Len(t, repo.findings, 1)
+}
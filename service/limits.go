@@ -0,0 +1,18 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is returned when creating a resource would exceed the configured maximum number of resources.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// checkLimit returns ErrLimitExceeded if another resource cannot be created in addition to count existing ones. A limit
+// of zero or less means unlimited.
+func checkLimit(resource string, limit int, count int) error {
+	if limit <= 0 || count < limit {
+		return nil
+	}
+	return fmt.Errorf("%w: at most %d %s are allowed", ErrLimitExceeded, limit, resource)
+}
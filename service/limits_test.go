@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLimitAssets(t *testing.T) {
+	const limit = 3
+
+	// creating assets up to the limit is allowed
+	for count := 0; count < limit; count++ {
+		assert.NoError(t, checkLimit("assets", limit, count))
+	}
+
+	// the next asset exceeds it
+	err := checkLimit("assets", limit, limit)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.EqualError(t, err, "limit exceeded: at most 3 assets are allowed")
+	assert.ErrorIs(t, checkLimit("assets", limit, limit+1), ErrLimitExceeded)
+}
+
+func TestCheckLimitUnlimited(t *testing.T) {
+	assert.NoError(t, checkLimit("assets", 0, 1000000))
+	assert.NoError(t, checkLimit("assets", -1, 1000000))
+}
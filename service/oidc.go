@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"cortex/repository"
+	"errors"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ExternalIdentity is a user authenticated by an external identity provider.
+type ExternalIdentity struct {
+	Provider repository.UserProvider
+	// Subject identifies the user at the provider and never changes.
+	Subject     string
+	Username    string
+	Email       string
+	DisplayName string
+}
+
+// OIDCOptions configures the client of an OpenID Connect provider.
+type OIDCOptions struct {
+	// Issuer is the URL the provider configuration is discovered from.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL registered at the provider.
+	RedirectURL string
+}
+
+// OIDCProvider runs the authorization code flow with an OpenID Connect provider.
+type OIDCProvider interface {
+	// AuthCodeURL returns the URL of the provider users are redirected to for login. The verifier is sent as PKCE
+	// challenge.
+	AuthCodeURL(state string, nonce string, verifier string) string
+	// Exchange redeems the code of a login callback and returns the identity of the verified ID token.
+	// ErrUnauthenticated is returned if the code or ID token is invalid.
+	Exchange(ctx context.Context, code string, nonce string, verifier string) (*ExternalIdentity, error)
+}
+
+type oidcProvider struct {
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// idTokenClaims are the claims of the ID token the identity is built from.
+type idTokenClaims struct {
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	Name              string `json:"name"`
+}
+
+func (p oidcProvider) AuthCodeURL(state string, nonce string, verifier string) string {
+	return p.config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+func (p oidcProvider) Exchange(ctx context.Context, code string, nonce string, verifier string) (*ExternalIdentity, error) {
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			return nil, fmt.Errorf("%w: %s", ErrUnauthenticated, retrieveErr.ErrorCode)
+		}
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: no id token in token response", ErrUnauthenticated)
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("%w: id token nonce does not match", ErrUnauthenticated)
+	}
+
+	var claims idTokenClaims
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	identity := ExternalIdentity{
+		Provider:    repository.UserProviderOIDC,
+		Subject:     idToken.Subject,
+		Username:    claims.PreferredUsername,
+		Email:       claims.Email,
+		DisplayName: claims.Name,
+	}
+	if identity.Username == "" {
+		identity.Username = claims.Email
+	}
+	if identity.Username == "" {
+		identity.Username = idToken.Subject
+	}
+	return &identity, nil
+}
+
+// NewOIDCProvider discovers the configuration of the provider at the issuer URL.
+func NewOIDCProvider(ctx context.Context, opts OIDCOptions) (OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, opts.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider %s: %w", opts.Issuer, err)
+	}
+
+	return oidcProvider{
+		config: oauth2.Config{
+			ClientID:     opts.ClientID,
+			ClientSecret: opts.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  opts.RedirectURL,
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: opts.ClientID}),
+	}, nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"cortex/repository"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIssuer is an OpenID Connect provider issuing ID tokens with claims for the code "code".
+type fakeIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := &fakeIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                issuer.URL,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "key", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code" || r.FormValue("code_verifier") != "verifier" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+			(&jose.SignerOptions{}).WithHeader("kid", "key"))
+		require.NoError(t, err)
+		payload, err := json.Marshal(issuer.claims)
+		require.NoError(t, err)
+		signed, err := signer.Sign(payload)
+		require.NoError(t, err)
+		idToken, err := signed.CompactSerialize()
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	issuer.claims = map[string]any{
+		"iss":                issuer.URL,
+		"aud":                "cortex",
+		"sub":                "subject",
+		"nonce":              "nonce",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"preferred_username": "jdoe",
+		"email":              "jdoe@example.com",
+		"name":               "John Doe",
+	}
+	return issuer
+}
+
+func TestOIDCProvider(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	ctx := context.Background()
+
+	provider, err := NewOIDCProvider(ctx, OIDCOptions{
+		Issuer:      issuer.URL,
+		ClientID:    "cortex",
+		RedirectURL: "https://cortex.example.com/auth/oidc/callback",
+	})
+	require.NoError(t, err)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state", "nonce", "verifier"))
+	require.NoError(t, err)
+	assert.Equal(t, issuer.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	assert.Equal(t, "state", authURL.Query().Get("state"))
+	assert.Equal(t, "nonce", authURL.Query().Get("nonce"))
+	assert.Equal(t, "S256", authURL.Query().Get("code_challenge_method"))
+	assert.Contains(t, authURL.Query().Get("scope"), "openid")
+
+	identity, err := provider.Exchange(ctx, "code", "nonce", "verifier")
+	require.NoError(t, err)
+	assert.Equal(t, ExternalIdentity{
+		Provider:    repository.UserProviderOIDC,
+		Subject:     "subject",
+		Username:    "jdoe",
+		Email:       "jdoe@example.com",
+		DisplayName: "John Doe",
+	}, *identity)
+
+	_, err = provider.Exchange(ctx, "code", "other", "verifier")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = provider.Exchange(ctx, "invalid", "nonce", "verifier")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	issuer.claims["aud"] = "other"
+	_, err = provider.Exchange(ctx, "code", "nonce", "verifier")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestLoginExternalUser(t *testing.T) {
+	local := repository.User{ID: "9b0e3f5c-2f8e-4d1a-9d51-6f0c1f2a3b4c", Provider: repository.UserProviderLocal,
+		Username: "admin"}
+	repo := newMemoryAuthRepository(local)
+	svc := NewAuthService(repo, nil, fakePool(t, 1), AuthServiceOptions{})
+	ctx := context.Background()
+
+	identity := ExternalIdentity{
+		Provider:    repository.UserProviderOIDC,
+		Subject:     "subject",
+		Username:    "jdoe",
+		Email:       "jdoe@example.com",
+		DisplayName: "John Doe",
+	}
+	user, err := svc.LoginExternalUser(ctx, identity)
+	require.NoError(t, err)
+	assert.Equal(t, repository.UserProviderOIDC, user.Provider)
+	assert.Equal(t, "subject", user.ExternalID)
+	assert.Equal(t, "jdoe", user.Username)
+	assert.Equal(t, repository.UserRoleUser, user.Role)
+	assert.Empty(t, user.Password)
+	assert.Len(t, repo.users, 2)
+
+	// later logins return the same user with the profile of the provider
+	identity.Email = "john.doe@example.com"
+	again, err := svc.LoginExternalUser(ctx, identity)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+	assert.Equal(t, "john.doe@example.com", repo.users[user.ID].Email)
+	assert.Len(t, repo.users, 2)
+
+	// external users cannot log in with a password
+	_, err = svc.CheckUsernamePassword(ctx, "jdoe", "")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	// usernames are shared with local users
+	_, err = svc.LoginExternalUser(ctx, ExternalIdentity{Provider: repository.UserProviderOIDC, Subject: "other",
+		Username: "admin"})
+	assert.ErrorIs(t, err, repository.ErrUniqueViolation)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"cortex/repository"
+)
+
+// ScanEngineOptionType is the JSON type of a scan engine option value.
+type ScanEngineOptionType string
+
+const (
+	ScanEngineOptionTypeString  ScanEngineOptionType = "string"
+	ScanEngineOptionTypeInteger ScanEngineOptionType = "integer"
+	ScanEngineOptionTypeBoolean ScanEngineOptionType = "boolean"
+)
+
+// ScanEngineOption describes a single option accepted by a scan engine.
+type ScanEngineOption struct {
+	Name        string               `json:"name"`
+	Type        ScanEngineOptionType `json:"type"`
+	Description string               `json:"description"`
+	// Min and Max bound integer options, nil means unbounded.
+	Min *int `json:"min,omitempty"`
+	Max *int `json:"max,omitempty"`
+	// Values lists the allowed values of a string option, empty means any value.
+	Values []string `json:"values,omitempty"`
+	// Pattern is a regular expression string option values must match, empty means any value.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// ScanEngine describes a scan engine the agents can run.
+type ScanEngine struct {
+	Name    string              `json:"name"`
+	Type    repository.ScanType `json:"type"`
+	Options []ScanEngineOption  `json:"options"`
+}
+
+// Option returns the option with the given name.
+func (e ScanEngine) Option(name string) (ScanEngineOption, bool) {
+	for _, option := range e.Options {
+		if option.Name == name {
+			return option, true
+		}
+	}
+	return ScanEngineOption{}, false
+}
+
+// portsPattern matches comma separated ports and port ranges like 22,80,8000-8100.
+const portsPattern = `^[0-9]{1,5}(-[0-9]{1,5})?(,[0-9]{1,5}(-[0-9]{1,5})?)*$`
+
+func intPtr(i int) *int {
+	return &i
+}
+
+var scanEngines = []ScanEngine{
+	{
+		Name: "naabu",
+		Type: repository.ScanTypeDiscovery,
+		Options: []ScanEngineOption{
+			{Name: "ports", Type: ScanEngineOptionTypeString, Description: "ports or port ranges to scan, e.g. 22,80,8000-8100",
+				Pattern: portsPattern},
+			{Name: "top-ports", Type: ScanEngineOptionTypeString, Description: "scan the most common ports",
+				Values: []string{"100", "1000", "full"}},
+			{Name: "rate", Type: ScanEngineOptionTypeInteger, Description: "packets per second",
+				Min: intPtr(1), Max: intPtr(100000)},
+			{Name: "retries", Type: ScanEngineOptionTypeInteger, Description: "number of retries per port",
+				Min: intPtr(0), Max: intPtr(10)},
+			{Name: "timeout", Type: ScanEngineOptionTypeInteger, Description: "milliseconds to wait for a response per port",
+				Min: intPtr(1), Max: intPtr(60000)},
+			{Name: "scan-type", Type: ScanEngineOptionTypeString, Description: "SYN (s) or CONNECT (c) scan",
+				Values: []string{"s", "c"}},
+		},
+	},
+	{
+		Name: "nmap",
+		Type: repository.ScanTypeDiscovery,
+		Options: []ScanEngineOption{
+			{Name: "ports", Type: ScanEngineOptionTypeString, Description: "ports or port ranges to scan, e.g. 22,80,8000-8100",
+				Pattern: portsPattern},
+			{Name: "timing", Type: ScanEngineOptionTypeInteger, Description: "timing template (-T)",
+				Min: intPtr(0), Max: intPtr(5)},
+			{Name: "service-detection", Type: ScanEngineOptionTypeBoolean, Description: "probe open ports for service info (-sV)"},
+		},
+	},
+	{
+		Name: "nuclei",
+		Type: repository.ScanTypeVulnerability,
+		Options: []ScanEngineOption{
+			{Name: "templates", Type: ScanEngineOptionTypeString, Description: "comma separated template paths or tags"},
+			{Name: "severity", Type: ScanEngineOptionTypeString, Description: "minimum severity of templates to run",
+				Values: []string{
+					string(repository.SeverityInfo), string(repository.SeverityLow), string(repository.SeverityMedium),
+					string(repository.SeverityHigh), string(repository.SeverityCritical),
+				}},
+			{Name: "rate-limit", Type: ScanEngineOptionTypeInteger, Description: "maximum requests per second",
+				Min: intPtr(1), Max: intPtr(10000)},
+		},
+	},
+}
+
+// ScanEngines returns all supported scan engines.
+func ScanEngines() []ScanEngine {
+	return scanEngines
+}
+
+// GetScanEngine returns the scan engine with the given name.
+func GetScanEngine(name string) (ScanEngine, bool) {
+	for _, engine := range scanEngines {
+		if engine.Name == name {
+			return engine, true
+		}
+	}
+	return ScanEngine{}, false
+}
+
+// ScanEngineNames returns the names of all supported scan engines.
+func ScanEngineNames() []string {
+	names := make([]string, 0, len(scanEngines))
+	for _, engine := range scanEngines {
+		names = append(names, engine.Name)
+	}
+	return names
+}
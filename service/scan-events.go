@@ -0,0 +1,245 @@
+package service
+
+import (
+	"cortex/repository"
+	"sync"
+	"time"
+)
+
+// scanEventBufferSize is the number of events buffered per subscriber before further events are dropped.
+const scanEventBufferSize = 16
+
+// ScanEventType distinguishes the events delivered to subscribers of a scan.
+type ScanEventType string
+
+const (
+	// ScanEventTypeScan carries the state of the scan after an update.
+	ScanEventTypeScan ScanEventType = "scan"
+	// ScanEventTypeFindings summarizes the findings reported for the scan's assets.
+	ScanEventTypeFindings ScanEventType = "findings"
+)
+
+// FindingSummary aggregates the findings reported while a scan is active.
+type FindingSummary struct {
+	ScanID string `json:"scanId"`
+	// New is the number of findings reported since the previous summary.
+	New int `json:"new"`
+	// Total is the number of findings reported since the first finding of the scan.
+	Total int `json:"total"`
+	// Complete marks the final summary sent once the scan has reached a terminal status.
+	Complete bool `json:"complete"`
+}
+
+// ScanEvent is delivered to subscribers of a scan. Scan is set for ScanEventTypeScan, Findings for
+// ScanEventTypeFindings.
+type ScanEvent struct {
+	Type     ScanEventType
+	Scan     repository.ScanExecution
+	Findings FindingSummary
+}
+
+// ScanSubscription delivers state changes of a single scan execution.
+type ScanSubscription struct {
+	// Scan is the state of the scan at the time of subscribing.
+	Scan *repository.ScanExecution
+	// Events receives the scan state after each update and periodic finding summaries. It is closed once the scan
+	// reaches a terminal status.
+	Events <-chan ScanEvent
+	cancel func()
+}
+
+// Close stops the delivery of events. It is safe to call Close multiple times.
+func (s ScanSubscription) Close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// scanEventBroker fans out scan updates to subscribers of the respective scan. Findings are not forwarded one by
+// one but aggregated into at most one summary per finding window.
+type scanEventBroker struct {
+	mu            sync.Mutex
+	subscribers   map[string]map[chan ScanEvent]struct{}
+	findings      map[string]*findingCounter
+	findingWindow time.Duration
+}
+
+type findingCounter struct {
+	pending int
+	total   int
+	timer   *time.Timer
+}
+
+func newScanEventBroker(findingWindow time.Duration) *scanEventBroker {
+	return &scanEventBroker{
+		subscribers:   make(map[string]map[chan ScanEvent]struct{}),
+		findings:      make(map[string]*findingCounter),
+		findingWindow: findingWindow,
+	}
+}
+
+// Subscribe registers a new subscriber for scanID. The returned function removes the subscriber again.
+func (b *scanEventBroker) Subscribe(scanID string) (<-chan ScanEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan ScanEvent, scanEventBufferSize)
+	if b.subscribers[scanID] == nil {
+		b.subscribers[scanID] = make(map[chan ScanEvent]struct{})
+	}
+	b.subscribers[scanID][ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.unsubscribe(scanID, ch)
+		})
+	}
+}
+
+// Publish sends scan to all of its subscribers without blocking. Once the scan has reached a terminal status, the
+// final finding summary and the scan are delivered and subscribers are released.
+func (b *scanEventBroker) Publish(scan repository.ScanExecution) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := ScanEvent{Type: ScanEventTypeScan, Scan: scan}
+	if !scan.Status.IsTerminal() {
+		b.send(scan.ID, event)
+		return
+	}
+
+	summary := FindingSummary{ScanID: scan.ID, Complete: true}
+	if counter, ok := b.dropFindings(scan.ID); ok {
+		summary.New = counter.pending
+		summary.Total = counter.total
+	}
+
+	for ch := range b.subscribers[scan.ID] {
+		deliver(ch, ScanEvent{Type: ScanEventTypeFindings, Findings: summary})
+		deliver(ch, event)
+		close(ch)
+	}
+	delete(b.subscribers, scan.ID)
+}
+
+// Remove drops the finding counter of a scan and releases its subscribers without a final event, e.g. once the scan
+// has been deleted.
+func (b *scanEventBroker) Remove(scanID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dropFindings(scanID)
+	for ch := range b.subscribers[scanID] {
+		close(ch)
+	}
+	delete(b.subscribers, scanID)
+}
+
+// dropFindings removes the finding counter of a scan and stops its pending summary.
+func (b *scanEventBroker) dropFindings(scanID string) (*findingCounter, bool) {
+	counter, ok := b.findings[scanID]
+	if !ok {
+		return nil, false
+	}
+	if counter.timer != nil {
+		counter.timer.Stop()
+	}
+	delete(b.findings, scanID)
+	return counter, true
+}
+
+// AddFindings records count findings reported for an active scan. Subscribers receive a summary once the finding
+// window has passed. A window of zero or less sends a summary for every call.
+func (b *scanEventBroker) AddFindings(scanID string, count int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	counter, ok := b.findings[scanID]
+	if !ok {
+		counter = &findingCounter{}
+		b.findings[scanID] = counter
+	}
+	counter.pending += count
+	counter.total += count
+
+	if b.findingWindow <= 0 {
+		b.sendFindings(scanID, counter)
+		return
+	}
+	if counter.timer == nil {
+		counter.timer = time.AfterFunc(b.findingWindow, func() {
+			b.flushFindings(scanID)
+		})
+	}
+}
+
+func (b *scanEventBroker) flushFindings(scanID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	counter, ok := b.findings[scanID]
+	if !ok {
+		// scan finished in the meantime, the final summary has been sent
+		return
+	}
+	counter.timer = nil
+	b.sendFindings(scanID, counter)
+}
+
+func (b *scanEventBroker) sendFindings(scanID string, counter *findingCounter) {
+	if counter.pending == 0 {
+		return
+	}
+	b.send(scanID, ScanEvent{Type: ScanEventTypeFindings, Findings: FindingSummary{
+		ScanID: scanID,
+		New:    counter.pending,
+		Total:  counter.total,
+	}})
+	counter.pending = 0
+}
+
+// send delivers event to all subscribers of scanID, dropping it for subscribers that are not keeping up.
+func (b *scanEventBroker) send(scanID string, event ScanEvent) {
+	for ch := range b.subscribers[scanID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// deliver sends event to ch, dropping the oldest buffered events if necessary so the event is never lost.
+func deliver(ch chan ScanEvent, event ScanEvent) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+func (b *scanEventBroker) unsubscribe(scanID string, ch chan ScanEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscribers, ok := b.subscribers[scanID]
+	if !ok {
+		return
+	}
+	if _, ok = subscribers[ch]; !ok {
+		// already closed by a terminal event
+		return
+	}
+
+	delete(subscribers, ch)
+	close(ch)
+	if len(subscribers) == 0 {
+		delete(b.subscribers, scanID)
+	}
+}
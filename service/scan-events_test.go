@@ -0,0 +1,121 @@
+package service
+
+import (
+	"cortex/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanEventBrokerDeliversUntilTerminal(t *testing.T) {
+	broker := newScanEventBroker(time.Hour)
+	events, cancel := broker.Subscribe("scan")
+	defer cancel()
+
+	broker.Publish(repository.ScanExecution{ID: "other", Status: repository.ScanStatusRunning})
+	broker.Publish(repository.ScanExecution{ID: "scan", Status: repository.ScanStatusRunning})
+	broker.Publish(repository.ScanExecution{ID: "scan", Status: repository.ScanStatusComplete})
+
+	var received []repository.ScanStatus
+	for event := range events {
+		if event.Type == ScanEventTypeScan {
+			received = append(received, event.Scan.Status)
+		}
+	}
+	assert.Equal(t, []repository.ScanStatus{repository.ScanStatusRunning, repository.ScanStatusComplete}, received)
+}
+
+func TestScanEventBrokerKeepsTerminalEventForSlowSubscriber(t *testing.T) {
+	broker := newScanEventBroker(time.Hour)
+	events, cancel := broker.Subscribe("scan")
+	defer cancel()
+
+	for i := 0; i < scanEventBufferSize*2; i++ {
+		broker.Publish(repository.ScanExecution{ID: "scan", Status: repository.ScanStatusRunning})
+	}
+	broker.Publish(repository.ScanExecution{ID: "scan", Status: repository.ScanStatusCancelled})
+
+	var last ScanEvent
+	for event := range events {
+		last = event
+	}
+	assert.Equal(t, repository.ScanStatusCancelled, last.Scan.Status)
+}
+
+func TestScanEventBrokerUnsubscribe(t *testing.T) {
+	broker := newScanEventBroker(time.Hour)
+	events, cancel := broker.Subscribe("scan")
+
+	cancel()
+	cancel()
+
+	_, open := <-events
+	assert.False(t, open)
+	assert.Empty(t, broker.subscribers)
+}
+
+func TestScanEventBrokerThrottlesFindings(t *testing.T) {
+	broker := newScanEventBroker(50 * time.Millisecond)
+	events, cancel := broker.Subscribe("scan")
+	defer cancel()
+
+	for i := 0; i < 500; i++ {
+		broker.AddFindings("scan", 1)
+	}
+	// summaries are sent per window, not per finding
+	time.Sleep(80 * time.Millisecond)
+	for i := 0; i < 500; i++ {
+		broker.AddFindings("scan", 1)
+	}
+	broker.Publish(repository.ScanExecution{ID: "scan", Status: repository.ScanStatusComplete})
+
+	var summaries []FindingSummary
+	for event := range events {
+		if event.Type == ScanEventTypeFindings {
+			summaries = append(summaries, event.Findings)
+		}
+	}
+
+	assert.LessOrEqual(t, len(summaries), 3)
+	final := summaries[len(summaries)-1]
+	assert.True(t, final.Complete)
+	assert.Equal(t, 1000, final.Total)
+
+	reported := 0
+	for _, summary := range summaries {
+		reported += summary.New
+	}
+	assert.Equal(t, 1000, reported)
+}
+
+func TestScanEventBrokerCompletionWithoutFindings(t *testing.T) {
+	broker := newScanEventBroker(time.Hour)
+	events, cancel := broker.Subscribe("scan")
+	defer cancel()
+
+	broker.Publish(repository.ScanExecution{ID: "scan", Status: repository.ScanStatusComplete})
+
+	event := <-events
+	assert.Equal(t, ScanEventTypeFindings, event.Type)
+	assert.Equal(t, FindingSummary{ScanID: "scan", Complete: true}, event.Findings)
+	event = <-events
+	assert.Equal(t, ScanEventTypeScan, event.Type)
+	_, open := <-events
+	assert.False(t, open)
+	assert.Empty(t, broker.findings)
+}
+
+func TestScanEventBrokerRemove(t *testing.T) {
+	broker := newScanEventBroker(time.Hour)
+	events, cancel := broker.Subscribe("scan")
+	defer cancel()
+	broker.AddFindings("scan", 3)
+
+	// a deleted scan never reaches a terminal status, its counter is dropped nonetheless
+	broker.Remove("scan")
+	_, open := <-events
+	assert.False(t, open)
+	assert.Empty(t, broker.findings)
+	assert.Empty(t, broker.subscribers)
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"cortex/logging"
+	"cortex/repository"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// scanUpdateWriteFunc persists a scan update.
+type scanUpdateWriteFunc func(ctx context.Context, scanID string, update ScanUpdateOptions) error
+
+// scanUpdateBatcher coalesces scan updates to limit database writes during long-running scans.
+// Status transitions are written immediately, other updates are merged and written at most once per interval.
+type scanUpdateBatcher struct {
+	mu       sync.Mutex
+	interval time.Duration
+	write    scanUpdateWriteFunc
+	scans    map[string]*batchedScanState
+	logger   *slog.Logger
+	// lastEviction is the time idle scans have last been evicted
+	lastEviction time.Time
+	// now and afterFunc are the clock and timers of the batcher, replaced in tests
+	now       func() time.Time
+	afterFunc func(d time.Duration, f func()) batchTimer
+}
+
+// batchTimer is the part of *time.Timer used to write held back updates.
+type batchTimer interface {
+	Stop() bool
+}
+
+type batchedScanState struct {
+	status    string
+	lastWrite time.Time
+	pending   *ScanUpdateOptions
+	timer     batchTimer
+}
+
+func newScanUpdateBatcher(interval time.Duration, write scanUpdateWriteFunc) *scanUpdateBatcher {
+	return &scanUpdateBatcher{
+		interval: interval,
+		write:    write,
+		scans:    make(map[string]*batchedScanState),
+		logger:   logging.GetLogger(logging.Scan),
+		now:      time.Now,
+		afterFunc: func(d time.Duration, f func()) batchTimer {
+			return time.AfterFunc(d, f)
+		},
+	}
+}
+
+// Add merges update into the pending update of a scan. If the merged update must be written now it is returned
+// together with true and the caller is responsible for writing it. Otherwise, the update is held back and written
+// once the interval since the last write has passed.
+func (b *scanUpdateBatcher) Add(ctx context.Context, scanID string, update ScanUpdateOptions) (ScanUpdateOptions, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.evictIdle(now)
+
+	state, ok := b.scans[scanID]
+	if !ok {
+		state = &batchedScanState{}
+		b.scans[scanID] = state
+	}
+
+	merged := update
+	if state.pending != nil {
+		merged = mergeScanUpdates(*state.pending, update)
+	}
+
+	statusChanged := update.Status != "" && update.Status != state.status
+	if b.interval <= 0 || statusChanged || now.Sub(state.lastWrite) >= b.interval {
+		if state.timer != nil {
+			state.timer.Stop()
+			state.timer = nil
+		}
+		state.pending = nil
+		state.lastWrite = now
+		if merged.Status != "" {
+			state.status = merged.Status
+		}
+		if repository.ScanStatus(state.status).IsTerminal() {
+			delete(b.scans, scanID)
+		}
+		return merged, true
+	}
+
+	state.pending = &merged
+	if state.timer == nil {
+		// detach from the request so the deferred write is not cancelled with it
+		flushCtx := context.WithoutCancel(ctx)
+		state.timer = b.afterFunc(state.lastWrite.Add(b.interval).Sub(now), func() {
+			b.flush(flushCtx, scanID)
+		})
+	}
+
+	return merged, false
+}
+
+// evictIdle drops the state of scans without a held back update that have not been written for an interval, e.g. of
+// scans that never reach a terminal status. Their next update is written immediately with or without the state. Scans
+// are checked at most once per interval.
+func (b *scanUpdateBatcher) evictIdle(now time.Time) {
+	if now.Sub(b.lastEviction) < b.interval {
+		return
+	}
+	b.lastEviction = now
+
+	for scanID, state := range b.scans {
+		if state.pending == nil && now.Sub(state.lastWrite) >= b.interval {
+			delete(b.scans, scanID)
+		}
+	}
+}
+
+// Pending returns the update held back for a scan, if any.
+func (b *scanUpdateBatcher) Pending(scanID string) (ScanUpdateOptions, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.scans[scanID]
+	if !ok || state.pending == nil {
+		return ScanUpdateOptions{}, false
+	}
+	return *state.pending, true
+}
+
+// Discard drops all batching state of a scan, including a held back update.
+func (b *scanUpdateBatcher) Discard(scanID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if state, ok := b.scans[scanID]; ok && state.timer != nil {
+		state.timer.Stop()
+	}
+	delete(b.scans, scanID)
+}
+
+func (b *scanUpdateBatcher) flush(ctx context.Context, scanID string) {
+	b.mu.Lock()
+	state, ok := b.scans[scanID]
+	if !ok || state.pending == nil {
+		b.mu.Unlock()
+		return
+	}
+	update := *state.pending
+	state.pending = nil
+	state.timer = nil
+	state.lastWrite = b.now()
+	b.mu.Unlock()
+
+	if err := b.write(ctx, scanID, update); err != nil {
+		b.logger.ErrorContext(ctx, "failed to write batched scan update",
+			logging.FieldScanID, scanID, logging.FieldError, err)
+	}
+}
+
+func mergeScanUpdates(current ScanUpdateOptions, next ScanUpdateOptions) ScanUpdateOptions {
+	if isScanTimeSet(next.StartTime) {
+		current.StartTime = next.StartTime
+	}
+	if isScanTimeSet(next.EndTime) {
+		current.EndTime = next.EndTime
+	}
+	if next.Status != "" {
+		current.Status = next.Status
+		if repository.ScanStatus(next.Status) != repository.ScanStatusFailed {
+			current.Error = ""
+		}
+	}
+	if next.Error != "" {
+		current.Error = next.Error
+	}
+	return current
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"cortex/repository"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type writeCounter struct {
+	mu      sync.Mutex
+	updates []ScanUpdateOptions
+}
+
+func (c *writeCounter) write(_ context.Context, _ string, update ScanUpdateOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updates = append(c.updates, update)
+	return nil
+}
+
+func (c *writeCounter) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.updates)
+}
+
+// addAndWrite mimics the scan service: updates returned for immediate writing are written by the caller.
+func addAndWrite(b *scanUpdateBatcher, c *writeCounter, scanID string, update ScanUpdateOptions) {
+	if merged, writeNow := b.Add(context.Background(), scanID, update); writeNow {
+		_ = c.write(context.Background(), scanID, merged)
+	}
+}
+
+// fakeBatchClock replaces the clock and timers of a scanUpdateBatcher. Timers fire when the clock is advanced past
+// their time.
+type fakeBatchClock struct {
+	now    time.Time
+	timers []*fakeBatchTimer
+}
+
+type fakeBatchTimer struct {
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func (t *fakeBatchTimer) Stop() bool {
+	active := !t.stopped
+	t.stopped = true
+	return active
+}
+
+func newFakeBatchClock(b *scanUpdateBatcher) *fakeBatchClock {
+	c := &fakeBatchClock{now: time.Date(2025, 12, 1, 8, 0, 0, 0, time.UTC)}
+	b.now = func() time.Time { return c.now }
+	b.afterFunc = func(d time.Duration, f func()) batchTimer {
+		timer := &fakeBatchTimer{at: c.now.Add(d), f: f}
+		c.timers = append(c.timers, timer)
+		return timer
+	}
+	return c
+}
+
+// advance moves the clock forward by d and fires the timers due until then.
+func (c *fakeBatchClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+	timers := c.timers
+	c.timers = nil
+	for _, timer := range timers {
+		switch {
+		case timer.stopped:
+		case timer.at.After(c.now):
+			c.timers = append(c.timers, timer)
+		default:
+			timer.stopped = true
+			timer.f()
+		}
+	}
+}
+
+func TestScanUpdateBatcherBoundsWrites(t *testing.T) {
+	counter := &writeCounter{}
+	interval := 50 * time.Millisecond
+	batcher := newScanUpdateBatcher(interval, counter.write)
+	clock := newFakeBatchClock(batcher)
+
+	addAndWrite(batcher, counter, "scan", ScanUpdateOptions{Status: string(repository.ScanStatusRunning)})
+	// 1000 updates over two intervals
+	for i := 0; i < 1000; i++ {
+		addAndWrite(batcher, counter, "scan", ScanUpdateOptions{
+			Status:    string(repository.ScanStatusRunning),
+			StartTime: time.Unix(int64(1700000000+i), 0),
+		})
+		if i%100 == 99 {
+			clock.advance(interval / 5)
+		}
+	}
+
+	// status transition plus one coalesced write per elapsed interval
+	assert.Equal(t, 3, counter.count())
+	_, pending := batcher.Pending("scan")
+	assert.False(t, pending)
+	// the last write carries the latest progress
+	assert.Equal(t, time.Unix(1700000999, 0), counter.updates[2].StartTime)
+}
+
+func TestScanUpdateBatcherEvictsIdleScans(t *testing.T) {
+	counter := &writeCounter{}
+	interval := time.Minute
+	batcher := newScanUpdateBatcher(interval, counter.write)
+	clock := newFakeBatchClock(batcher)
+
+	// scans that never reach a terminal status
+	for _, scanID := range []string{"a", "b", "c"} {
+		addAndWrite(batcher, counter, scanID, ScanUpdateOptions{Status: string(repository.ScanStatusRunning)})
+	}
+	addAndWrite(batcher, counter, "c", ScanUpdateOptions{StartTime: time.Unix(1700000000, 0)})
+	assert.Len(t, batcher.scans, 3)
+
+	// the held back update of c is written when the interval has passed, then c is idle as well
+	clock.advance(interval)
+	assert.Equal(t, 4, counter.count())
+	addAndWrite(batcher, counter, "d", ScanUpdateOptions{Status: string(repository.ScanStatusRunning)})
+	assert.Equal(t, []string{"c", "d"}, slices.Sorted(maps.Keys(batcher.scans)))
+
+	clock.advance(interval)
+	addAndWrite(batcher, counter, "d", ScanUpdateOptions{StartTime: time.Unix(1700000000, 0)})
+	assert.Equal(t, []string{"d"}, slices.Sorted(maps.Keys(batcher.scans)))
+
+	// updates of evicted scans are written right away like before the eviction
+	addAndWrite(batcher, counter, "a", ScanUpdateOptions{StartTime: time.Unix(1700000000, 0)})
+	assert.Equal(t, 7, counter.count())
+}
+
+func TestScanUpdateBatcherFlushesTerminalImmediately(t *testing.T) {
+	counter := &writeCounter{}
+	batcher := newScanUpdateBatcher(time.Hour, counter.write)
+
+	addAndWrite(batcher, counter, "scan", ScanUpdateOptions{Status: string(repository.ScanStatusRunning)})
+	addAndWrite(batcher, counter, "scan", ScanUpdateOptions{StartTime: time.Unix(1700000000, 0)})
+	assert.Equal(t, 1, counter.count())
+
+	addAndWrite(batcher, counter, "scan", ScanUpdateOptions{
+		Status:  string(repository.ScanStatusComplete),
+		EndTime: time.Unix(1700000100, 0),
+	})
+	assert.Equal(t, 2, counter.count())
+
+	// the terminal write carries the held back progress
+	last := counter.updates[1]
+	assert.Equal(t, string(repository.ScanStatusComplete), last.Status)
+	assert.Equal(t, time.Unix(1700000000, 0), last.StartTime)
+	assert.Equal(t, time.Unix(1700000100, 0), last.EndTime)
+
+	_, pending := batcher.Pending("scan")
+	assert.False(t, pending)
+}
+
+func TestScanUpdateBatcherDisabled(t *testing.T) {
+	counter := &writeCounter{}
+	batcher := newScanUpdateBatcher(0, counter.write)
+
+	for i := 0; i < 10; i++ {
+		addAndWrite(batcher, counter, "scan", ScanUpdateOptions{StartTime: time.Unix(int64(1700000000+i), 0)})
+	}
+	assert.Equal(t, 10, counter.count())
+}
@@ -5,53 +5,253 @@ import (
 	cortexContext "cortex/context"
 	"cortex/logging"
 	"cortex/repository"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrScanRunning is returned when an operation is not permitted while a scan is running.
+var ErrScanRunning = errors.New("scan is running")
+
+// ErrNoScanAssets is returned when a scan is run without assets and its scan configuration has no default assets.
+var ErrNoScanAssets = errors.New("no assets to scan")
+
+// ErrScanConfigInUse is returned when a scan configuration cannot be changed because scans using it are active.
+var ErrScanConfigInUse = errors.New("scan configuration is in use")
+
+// ErrInvalidScanTransition is returned when a scan cannot move from its current to the requested status.
+var ErrInvalidScanTransition = errors.New("invalid scan status transition")
+
+type CreateScanConfigOptions struct {
+	Name    string
+	Engine  string
+	Options map[string]any
+}
+
+type UpdateScanConfigOptions struct {
+	Name string
+	// Engine changes the engine, and with it the scan type, if not empty.
+	Engine string
+	// Options replaces the engine options if not nil.
+	Options map[string]any
+	// Force applies an engine change even while scans of the configuration are queued or running.
+	Force bool
+}
+
 type ScanUpdateOptions struct {
 	StartTime time.Time
 	EndTime   time.Time
 	Status    string
+	// Error describes why a scan failed. It is sanitized before it is stored and cleared once the scan moves to any
+	// status other than failed.
+	Error string
 }
 
 type ScanService interface {
 	ListScanConfigs(ctx context.Context) ([]repository.ScanConfiguration, error)
 	GetScanConfig(ctx context.Context, id string) (*repository.ScanConfiguration, error)
-	CreateScanConfig(ctx context.Context, name string) (*repository.ScanConfiguration, error)
-	UpdateScanConfig(ctx context.Context, id string, newName string) (*repository.ScanConfiguration, error)
+	CreateScanConfig(ctx context.Context, opts CreateScanConfigOptions) (*repository.ScanConfiguration, error)
+	UpdateScanConfig(ctx context.Context, id string, opts UpdateScanConfigOptions) (*repository.ScanConfiguration, error)
 	DeleteScanConfig(ctx context.Context, id string) (*repository.ScanConfiguration, error)
-
-	ListAssets(ctx context.Context) ([]repository.ScanAsset, error)
-	ListAssetsWithStats(ctx context.Context) ([]repository.ScanAssetWithStats, error)
-	GetAsset(ctx context.Context, id string) (*repository.ScanAsset, error)
+	// UpdateScanConfigAssets replaces the assets scanned when a scan of the configuration is run without assets.
+	UpdateScanConfigAssets(ctx context.Context, id string, assetIds []string) ([]repository.ScanAsset, error)
+
+	ListAssets(ctx context.Context, opts ListAssetsOptions) ([]repository.ScanAsset, error)
+	ListAssetsWithStats(ctx context.Context, opts ListAssetsOptions) ([]repository.ScanAssetWithStats, error)
+	// EachAssetWithStats calls fn for each asset ListAssetsWithStats would return, without loading all assets into
+	// memory.
+	EachAssetWithStats(ctx context.Context, opts ListAssetsOptions, fn func(repository.ScanAssetWithStats) error) error
+	GetAsset(ctx context.Context, id string, opts GetAssetOptions) (*repository.ScanAsset, error)
 	GetAssetWithStats(ctx context.Context, id string) (*repository.ScanAssetWithStats, error)
 	CreateAsset(ctx context.Context, endpoint string) (*repository.ScanAsset, error)
-	DeleteAsset(ctx context.Context, id string) (*repository.ScanAsset, error)
+	// CreateAssets creates an asset per endpoint in a single transaction. Endpoints that already exist or are given
+	// more than once are skipped.
+	CreateAssets(ctx context.Context, endpoints []string) (*CreateAssetsResult, error)
+	// DeleteAsset deletes an asset, keeping it with its findings and history for RestoreAsset unless
+	// opts.Permanent is set.
+	DeleteAsset(ctx context.Context, id string, opts DeleteAssetOptions) (*repository.ScanAsset, error)
+	// RestoreAsset restores an asset deleted without DeleteAssetOptions.Permanent. repository.ErrNotFound is
+	// returned if the asset is not deleted, repository.ErrUniqueViolation if its endpoint is used by another asset
+	// and ErrLimitExceeded if there are as many assets as allowed.
+	RestoreAsset(ctx context.Context, id string) (*repository.ScanAsset, error)
 	UpdateAsset(ctx context.Context, id string, newEndpoint string) (*repository.ScanAsset, error)
-
-	ListAssetFindings(ctx context.Context, assetID string) ([]repository.AssetFinding, error)
+	// SetAssetTags replaces the tags of an asset.
+	SetAssetTags(ctx context.Context, id string, tags map[string]string) (*repository.ScanAsset, error)
+
+	// ListFindings returns the findings of all assets matching filter. Without triage statuses, findings with
+	// repository.VisibleTriageStatuses are returned.
+	ListFindings(ctx context.Context, filter repository.FindingFilter) ([]repository.AssetFinding, error)
+	// EachFinding calls fn for each finding ListFindings would return, without loading all findings into memory.
+	EachFinding(ctx context.Context, filter repository.FindingFilter, fn func(repository.AssetFindingWithAsset) error) error
+	ListAssetFindings(ctx context.Context, assetID string, opts ListFindingsOptions) ([]repository.AssetFinding, error)
 	ListAssetHistory(ctx context.Context, assetID string) ([]repository.AssetHistoryEntry, error)
 
-	RunScan(ctx context.Context, configID string, assetIds []string) (*repository.ScanExecution, error)
-	ListScans(ctx context.Context) ([]repository.ScanExecution, error)
+	// RunScan queues a scan of the assets with assetIds and opts.Endpoints. Without either, the default assets of the
+	// scan configuration are scanned.
+	RunScan(ctx context.Context, configID string, assetIds []string, opts RunScanOptions) (*repository.ScanExecution, error)
+	// PreviewScanTargets resolves the assets RunScan would scan without creating a scan or assets.
+	PreviewScanTargets(ctx context.Context, configID string, assetIds []string, opts RunScanOptions) (*ScanTargets, error)
+	ListScans(ctx context.Context, opts ListScansOptions) ([]repository.ScanExecution, error)
+	// ListScansByConfig returns the scans of a scan configuration like ListScans. repository.ErrNotFound is returned
+	// if the scan configuration does not exist.
+	ListScansByConfig(ctx context.Context, configID string, opts ListScansOptions) ([]repository.ScanExecution, error)
 	GetScan(ctx context.Context, id string) (*repository.ScanExecution, error)
+	// ListAssetsOfScan returns a page of the assets of a scan, a limit of zero returns all assets.
+	ListAssetsOfScan(ctx context.Context, scanID string, limit int, offset int) ([]repository.ScanAsset, error)
 	UpdateScan(ctx context.Context, scanID string, update ScanUpdateOptions) (*repository.ScanExecution, error)
+	// UpdateScanStatuses moves all given scans to status in a single transaction. Scans that do not exist or cannot
+	// move to status are skipped and reported in their result. errorMessage is stored for scans moved to failed.
+	UpdateScanStatuses(ctx context.Context, scanIDs []string, status repository.ScanStatus, errorMessage string) ([]ScanStatusResult, error)
+	SubscribeScan(ctx context.Context, scanID string) (*ScanSubscription, error)
+	DeleteScan(ctx context.Context, id string) (*repository.ScanExecution, error)
+	// NotifyFindings informs subscribers of a scan about count findings reported for its assets.
+	NotifyFindings(scanID string, count int)
+	// FailOverdueScans moves scans running for longer than the maximum run duration to failed. It returns the number
+	// of failed scans.
+	FailOverdueScans(ctx context.Context) (int, error)
+	// WatchOverdueScans calls FailOverdueScans every interval until ctx is done. It returns immediately if no maximum
+	// run duration is configured.
+	WatchOverdueScans(ctx context.Context, interval time.Duration)
+	// RecoverStaleScans fails or requeues queued and running scans that have not changed for the configured stale
+	// scan age. It returns the number of recovered scans.
+	RecoverStaleScans(ctx context.Context) (int, error)
+}
+
+// ScanStatusResult is the outcome of UpdateScanStatuses for a single scan.
+type ScanStatusResult struct {
+	ScanID string
+	// Scan is the scan after the update, or its unchanged state if the transition is not allowed.
+	Scan *repository.ScanExecution
+	// Err is repository.ErrNotFound or ErrInvalidScanTransition if the scan has not been updated.
+	Err error
+}
+
+// CreateAssetsResult reports the outcome of CreateAssets.
+type CreateAssetsResult struct {
+	Created []repository.ScanAsset `json:"created"`
+	// Skipped are the endpoints that already exist or have been given more than once.
+	Skipped []string `json:"skipped"`
+}
+
+// ScanTargetExclusionReason explains why an asset given for a scan is not scanned.
+type ScanTargetExclusionReason string
+
+const (
+	// ScanTargetUnresolvable marks asset IDs and endpoints that do not exist.
+	ScanTargetUnresolvable ScanTargetExclusionReason = "unresolvable"
+	// ScanTargetDuplicate marks assets whose endpoint is already scanned through another asset.
+	ScanTargetDuplicate ScanTargetExclusionReason = "duplicate"
+)
+
+// ScanTargetExclusion is an asset given for a scan that is not scanned.
+type ScanTargetExclusion struct {
+	AssetID  string                    `json:"assetId"`
+	Endpoint string                    `json:"endpoint,omitempty"`
+	Reason   ScanTargetExclusionReason `json:"reason"`
+}
+
+// ScanTargets are the assets a scan of a configuration scans.
+type ScanTargets struct {
+	ScanConfigurationID string                 `json:"scanConfigId"`
+	Targets             []repository.ScanAsset `json:"targets"`
+	// NewEndpoints are scanned in addition to Targets, RunScan creates assets for them.
+	NewEndpoints []string              `json:"newEndpoints"`
+	Excluded     []ScanTargetExclusion `json:"excluded"`
+}
+
+// ListAssetsOptions filters the assets returned by ListAssets, ListAssetsWithStats and EachAssetWithStats.
+type ListAssetsOptions struct {
+	// Tags matches assets having all the tags, empty matches all assets.
+	Tags map[string]string
+}
+
+// DeleteAssetOptions controls how DeleteAsset deletes an asset.
+type DeleteAssetOptions struct {
+	// Permanent removes the asset with its findings and history instead of marking it as deleted.
+	Permanent bool
+}
+
+// GetAssetOptions controls which assets GetAsset returns.
+type GetAssetOptions struct {
+	// IncludeDeleted also returns deleted assets, flagged with repository.ScanAsset.Deleted.
+	IncludeDeleted bool
+}
+
+// RunScanOptions controls the targets of RunScan.
+type RunScanOptions struct {
+	// Endpoints are scanned in addition to the assets given by id. Unknown endpoints fail the scan with
+	// repository.ErrNotFound unless CreateAssets is set.
+	Endpoints []string
+	// CreateAssets creates assets for Endpoints that do not exist yet.
+	CreateAssets bool
+}
+
+// ListFindingsOptions filters the findings returned by ListAssetFindings.
+type ListFindingsOptions struct {
+	// TriageStatuses matches findings with any of the statuses, nil matches repository.VisibleTriageStatuses.
+	TriageStatuses []repository.TriageStatus
+}
+
+// ListScansOptions controls the data returned by ListScans.
+type ListScansOptions struct {
+	// IncludeCounts sets the finding counts of each scan.
+	IncludeCounts bool
+}
+
+// ScanServiceOptions configures the behaviour of a ScanService.
+type ScanServiceOptions struct {
+	// UpdateInterval is the minimum time between two writes of a scan's progress. Status transitions are
+	// always written immediately. Zero disables batching.
+	UpdateInterval time.Duration
+	// FindingEventWindow is the time during which findings of a scan are aggregated into a single event for
+	// subscribers. Zero sends an event per finding.
+	FindingEventWindow time.Duration
+	// MaxAssets and MaxScanConfigs limit the number of assets and scan configurations. Zero means unlimited.
+	MaxAssets      int
+	MaxScanConfigs int
+	// MaxRunDuration is the time after which a running scan is considered stuck and failed by WatchOverdueScans. Zero
+	// never fails running scans.
+	MaxRunDuration time.Duration
+	// StaleScanAge is the time without changes after which RecoverStaleScans considers a queued or running scan
+	// abandoned, e.g. because the process updating it crashed. Zero disables the recovery.
+	StaleScanAge time.Duration
+	// RequeueStaleScans moves abandoned running scans back to queued instead of failing them. Abandoned queued scans
+	// are left queued.
+	RequeueStaleScans bool
+	// MaxConcurrentScans limits the number of running scans. Scans beyond the limit stay queued and cannot move to
+	// running until another scan has ended. Zero means unlimited.
+	MaxConcurrentScans int
+	// Audit records the changes made through the service, nil does not record them.
+	Audit AuditService
 }
 
 type scanService struct {
-	repo   repository.ScanRepository
-	logger *slog.Logger
-	pool   *pgxpool.Pool
+	repo           repository.ScanRepository
+	logger         *slog.Logger
+	pool           *Pool
+	updates        *scanUpdateBatcher
+	events         *scanEventBroker
+	maxAssets      int
+	maxScanConfigs int
+	maxRunDuration time.Duration
+	staleScanAge   time.Duration
+	requeueStale   bool
+	maxRunning     int
+	audit          AuditService
+	// now returns the current time, replaced in tests
+	now func() time.Time
 }
 
 func (s scanService) ListScanConfigs(ctx context.Context) ([]repository.ScanConfiguration, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +273,7 @@ func (s scanService) ListScanConfigs(ctx context.Context) ([]repository.ScanConf
 }
 
 func (s scanService) GetScanConfig(ctx context.Context, id string) (*repository.ScanConfiguration, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -96,8 +296,18 @@ func (s scanService) GetScanConfig(ctx context.Context, id string) (*repository.
 	return config, nil
 }
 
-func (s scanService) CreateScanConfig(ctx context.Context, name string) (*repository.ScanConfiguration, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) CreateScanConfig(ctx context.Context, opts CreateScanConfigOptions) (*repository.ScanConfiguration, error) {
+	engine, ok := GetScanEngine(opts.Engine)
+	if !ok {
+		return nil, fmt.Errorf("unsupported scan engine %s", opts.Engine)
+	}
+
+	options := opts.Options
+	if options == nil {
+		options = map[string]any{}
+	}
+
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -110,9 +320,25 @@ func (s scanService) CreateScanConfig(ctx context.Context, name string) (*reposi
 		}
 	}()
 
+	if s.maxScanConfigs > 0 {
+		var count int
+		count, err = s.repo.CountScanConfigurations(ctx, tx)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to count scan configurations", logging.FieldError, err)
+			return nil, err
+		}
+		if err = checkLimit("scan configurations", s.maxScanConfigs, count); err != nil {
+			s.logger.WarnContext(ctx, "rejected scan configuration", logging.FieldError, err)
+			return nil, err
+		}
+	}
+
 	config := repository.ScanConfiguration{
-		ID:   uuid.New().String(),
-		Name: name,
+		ID:      uuid.New().String(),
+		Name:    opts.Name,
+		Type:    engine.Type,
+		Engine:  engine.Name,
+		Options: options,
 	}
 
 	err = s.repo.CreateScanConfiguration(ctx, tx, config)
@@ -121,13 +347,22 @@ func (s scanService) CreateScanConfig(ctx context.Context, name string) (*reposi
 		return nil, err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionCreate,
+		TargetType: repository.AuditTargetScanConfig,
+		TargetID:   config.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s.logger.InfoContext(ctx, "scan configuration created", logging.FieldScanConfigID, config.ID)
 
 	return &config, nil
 }
 
-func (s scanService) UpdateScanConfig(ctx context.Context, id string, newName string) (*repository.ScanConfiguration, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) UpdateScanConfig(ctx context.Context, id string, opts UpdateScanConfigOptions) (*repository.ScanConfiguration, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +382,37 @@ func (s scanService) UpdateScanConfig(ctx context.Context, id string, newName st
 		return nil, err
 	}
 
-	config.Name = newName
+	config.Name = opts.Name
+	if opts.Engine != "" && opts.Engine != config.Engine {
+		engine, ok := GetScanEngine(opts.Engine)
+		if !ok {
+			err = fmt.Errorf("unsupported scan engine %s", opts.Engine)
+			return nil, err
+		}
+
+		activeScans := 0
+		if !opts.Force {
+			activeScans, err = s.repo.CountActiveScans(ctx, tx, id)
+			if err != nil {
+				s.logger.ErrorContext(ctx, "failed to count active scans",
+					logging.FieldScanConfigID, id, logging.FieldError, err)
+				return nil, err
+			}
+		}
+		if err = checkEngineChange(activeScans, opts.Force); err != nil {
+			s.logger.WarnContext(ctx, "refusing to change engine of scan configuration in use",
+				logging.FieldScanConfigID, id)
+			return nil, err
+		}
+
+		config.Engine = engine.Name
+		config.Type = engine.Type
+		// options of the previous engine do not apply to the new one
+		config.Options = map[string]any{}
+	}
+	if opts.Options != nil {
+		config.Options = opts.Options
+	}
 	err = s.repo.UpdateScanConfiguration(ctx, tx, *config)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to update scan configuration",
@@ -155,13 +420,31 @@ func (s scanService) UpdateScanConfig(ctx context.Context, id string, newName st
 		return nil, err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionUpdate,
+		TargetType: repository.AuditTargetScanConfig,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s.logger.InfoContext(ctx, "scan configuration updated", logging.FieldScanConfigID, id)
 
 	return config, nil
 }
 
+// checkEngineChange decides whether the engine of a scan configuration with activeScans queued or running scans may
+// be changed.
+func checkEngineChange(activeScans int, force bool) error {
+	if activeScans > 0 && !force {
+		return fmt.Errorf("%w: %d scan(s) queued or running", ErrScanConfigInUse, activeScans)
+	}
+	return nil
+}
+
 func (s scanService) DeleteScanConfig(ctx context.Context, id string) (*repository.ScanConfiguration, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -188,21 +471,108 @@ func (s scanService) DeleteScanConfig(ctx context.Context, id string) (*reposito
 		return nil, err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionDelete,
+		TargetType: repository.AuditTargetScanConfig,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s.logger.InfoContext(ctx, "scan configuration deleted", logging.FieldScanConfigID, id)
 
 	return config, nil
 }
 
-func (s scanService) listAssets(ctx context.Context, tx pgx.Tx) ([]repository.ScanAsset, error) {
-	assets, err := s.repo.ListScanAssets(ctx, tx)
+func (s scanService) UpdateScanConfigAssets(ctx context.Context, id string, assetIds []string) ([]repository.ScanAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	_, err = s.repo.GetScanConfiguration(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get scan configuration for asset update",
+			logging.FieldScanConfigID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	assets := []repository.ScanAsset{}
+	for _, assetId := range assetIds {
+		// check if the asset exists
+		var asset *repository.ScanAsset
+		asset, err = s.repo.GetScanAsset(ctx, tx, assetId)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to get scan asset",
+				logging.FieldAssetID, assetId, logging.FieldError, err)
+			return nil, err
+		}
+		assets = append(assets, *asset)
+	}
+
+	err = s.repo.SetScanConfigurationAssets(ctx, tx, id, assetIds)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to update scan configuration assets",
+			logging.FieldScanConfigID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionUpdate,
+		TargetType: repository.AuditTargetScanConfig,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "scan configuration assets updated", logging.FieldScanConfigID, id)
+
+	return assets, nil
+}
+
+func (s scanService) listAssets(ctx context.Context, tx pgx.Tx, opts ListAssetsOptions) ([]repository.ScanAsset, error) {
+	assets, err := s.repo.ListScanAssets(ctx, tx, repository.AssetFilter{Tags: opts.Tags})
 	if err != nil {
 		return nil, err
 	}
+	if err = s.loadAssetTags(ctx, tx, assets); err != nil {
+		return nil, err
+	}
 	return assets, nil
 }
 
-func (s scanService) ListAssets(ctx context.Context) ([]repository.ScanAsset, error) {
-	tx, err := s.pool.Begin(ctx)
+// loadAssetTags sets the tags of the assets with a single query.
+func (s scanService) loadAssetTags(ctx context.Context, tx pgx.Tx, assets []repository.ScanAsset) error {
+	if len(assets) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		ids = append(ids, asset.ID)
+	}
+	tags, err := s.repo.GetAssetTags(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range assets {
+		assets[i].Tags = tags[assets[i].ID]
+	}
+	return nil
+}
+
+func (s scanService) ListAssets(ctx context.Context, opts ListAssetsOptions) ([]repository.ScanAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -215,7 +585,7 @@ func (s scanService) ListAssets(ctx context.Context) ([]repository.ScanAsset, er
 		}
 	}()
 
-	assets, err := s.listAssets(ctx, tx)
+	assets, err := s.listAssets(ctx, tx, opts)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to list scan assets", logging.FieldError, err)
 		return nil, err
@@ -224,8 +594,8 @@ func (s scanService) ListAssets(ctx context.Context) ([]repository.ScanAsset, er
 	return assets, nil
 }
 
-func (s scanService) ListAssetsWithStats(ctx context.Context) ([]repository.ScanAssetWithStats, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) ListAssetsWithStats(ctx context.Context, opts ListAssetsOptions) ([]repository.ScanAssetWithStats, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -238,25 +608,30 @@ func (s scanService) ListAssetsWithStats(ctx context.Context) ([]repository.Scan
 		}
 	}()
 
-	assets, err := s.listAssets(ctx, tx)
+	assets, err := s.listAssets(ctx, tx, opts)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to list scan assets", logging.FieldError, err)
 		return nil, err
 	}
 
-	// augment asset with stats
-	var assetsWithStats []repository.ScanAssetWithStats
+	// augment asset with stats, aggregated in a single query
+	ids := make([]string, 0, len(assets))
 	for _, a := range assets {
-		assetStats, err := s.repo.GetAssetStats(ctx, tx, a.ID)
-		if err != nil {
-			s.logger.ErrorContext(ctx, "failed to get asset stats", logging.FieldError, err)
-			return nil, err
-		}
+		ids = append(ids, a.ID)
+	}
+	stats, err := s.repo.ListAssetStats(ctx, tx, ids)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get asset stats", logging.FieldError, err)
+		return nil, err
+	}
 
+	var assetsWithStats []repository.ScanAssetWithStats
+	for _, a := range assets {
 		stat := repository.ScanAssetWithStats{
 			ID:       a.ID,
 			Endpoint: a.Endpoint,
-			Stats:    *assetStats,
+			Tags:     a.Tags,
+			Stats:    stats[a.ID],
 		}
 
 		assetsWithStats = append(assetsWithStats, stat)
@@ -265,8 +640,25 @@ func (s scanService) ListAssetsWithStats(ctx context.Context) ([]repository.Scan
 	return assetsWithStats, nil
 }
 
-func (s scanService) GetAsset(ctx context.Context, id string) (*repository.ScanAsset, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) EachAssetWithStats(ctx context.Context, opts ListAssetsOptions, fn func(repository.ScanAssetWithStats) error) error {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	err = s.repo.EachAssetWithStats(ctx, tx, repository.AssetFilter{Tags: opts.Tags}, fn)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to read scan assets", logging.FieldError, err)
+		return err
+	}
+	return nil
+}
+
+func (s scanService) GetAsset(ctx context.Context, id string, opts GetAssetOptions) (*repository.ScanAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -279,17 +671,29 @@ func (s scanService) GetAsset(ctx context.Context, id string) (*repository.ScanA
 		}
 	}()
 
-	asset, err := s.repo.GetScanAsset(ctx, tx, id)
+	var asset *repository.ScanAsset
+	if opts.IncludeDeleted {
+		asset, err = s.repo.GetScanAssetIncludingDeleted(ctx, tx, id)
+	} else {
+		asset, err = s.repo.GetScanAsset(ctx, tx, id)
+	}
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to get scan asset",
 			logging.FieldAssetID, id, logging.FieldError, err)
 		return nil, err
 	}
-	return asset, nil
+
+	assets := []repository.ScanAsset{*asset}
+	if err = s.loadAssetTags(ctx, tx, assets); err != nil {
+		s.logger.ErrorContext(ctx, "failed to get asset tags",
+			logging.FieldAssetID, id, logging.FieldError, err)
+		return nil, err
+	}
+	return &assets[0], nil
 }
 
 func (s scanService) GetAssetWithStats(ctx context.Context, id string) (*repository.ScanAssetWithStats, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -309,6 +713,13 @@ func (s scanService) GetAssetWithStats(ctx context.Context, id string) (*reposit
 		return nil, err
 	}
 
+	assets := []repository.ScanAsset{*asset}
+	if err = s.loadAssetTags(ctx, tx, assets); err != nil {
+		s.logger.ErrorContext(ctx, "failed to get asset tags",
+			logging.FieldAssetID, id, logging.FieldError, err)
+		return nil, err
+	}
+
 	assetStats, err := s.repo.GetAssetStats(ctx, tx, asset.ID)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to get asset stats", logging.FieldError, err)
@@ -318,12 +729,13 @@ func (s scanService) GetAssetWithStats(ctx context.Context, id string) (*reposit
 	return &repository.ScanAssetWithStats{
 		ID:       asset.ID,
 		Endpoint: asset.Endpoint,
+		Tags:     assets[0].Tags,
 		Stats:    *assetStats,
 	}, nil
 }
 
 func (s scanService) CreateAsset(ctx context.Context, endpoint string) (*repository.ScanAsset, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -336,6 +748,19 @@ func (s scanService) CreateAsset(ctx context.Context, endpoint string) (*reposit
 		}
 	}()
 
+	if s.maxAssets > 0 {
+		var count int
+		count, err = s.repo.CountScanAssets(ctx, tx)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to count scan assets", logging.FieldError, err)
+			return nil, err
+		}
+		if err = checkLimit("assets", s.maxAssets, count); err != nil {
+			s.logger.WarnContext(ctx, "rejected scan asset", logging.FieldError, err)
+			return nil, err
+		}
+	}
+
 	asset := repository.ScanAsset{
 		ID:       uuid.New().String(),
 		Endpoint: endpoint,
@@ -369,13 +794,22 @@ func (s scanService) CreateAsset(ctx context.Context, endpoint string) (*reposit
 		return nil, err
 	}
 
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionCreate,
+		TargetType: repository.AuditTargetAsset,
+		TargetID:   asset.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s.logger.InfoContext(ctx, "scan asset created", logging.FieldAssetID, asset.ID)
 
 	return &asset, nil
 }
 
-func (s scanService) DeleteAsset(ctx context.Context, id string) (*repository.ScanAsset, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) CreateAssets(ctx context.Context, endpoints []string) (*CreateAssetsResult, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -388,27 +822,101 @@ func (s scanService) DeleteAsset(ctx context.Context, id string) (*repository.Sc
 		}
 	}()
 
-	asset, err := s.repo.GetScanAsset(ctx, tx, id)
+	userInfo, err := cortexContext.UserInfo(ctx)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to get scan asset for deletion",
-			logging.FieldAssetID, id, logging.FieldError, err)
+		s.logger.ErrorContext(ctx, "failed to get user info from context", logging.FieldError, err)
 		return nil, err
 	}
 
-	err = s.repo.DeleteScanAsset(ctx, tx, id)
-	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to delete scan asset",
-			logging.FieldAssetID, id, logging.FieldError, err)
-		return nil, err
+	var count int
+	if s.maxAssets > 0 {
+		count, err = s.repo.CountScanAssets(ctx, tx)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to count scan assets", logging.FieldError, err)
+			return nil, err
+		}
 	}
 
-	s.logger.InfoContext(ctx, "scan asset deleted", logging.FieldAssetID, id)
+	result := CreateAssetsResult{Created: []repository.ScanAsset{}, Skipped: []string{}}
+	seen := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		if seen[endpoint] {
+			result.Skipped = append(result.Skipped, endpoint)
+			continue
+		}
+		seen[endpoint] = true
 
-	return asset, nil
+		if err = checkLimit("assets", s.maxAssets, count+len(result.Created)); err != nil {
+			s.logger.WarnContext(ctx, "rejected scan assets", logging.FieldError, err)
+			return nil, err
+		}
+
+		asset := repository.ScanAsset{
+			ID:       uuid.New().String(),
+			Endpoint: endpoint,
+		}
+
+		var created bool
+		created, err = s.createAssetSavepoint(ctx, tx, asset)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to create scan asset", logging.FieldError, err)
+			return nil, err
+		}
+		if !created {
+			result.Skipped = append(result.Skipped, endpoint)
+			continue
+		}
+
+		event := repository.AssetHistoryEntry{
+			ID:      uuid.New().String(),
+			AssetID: asset.ID,
+			UserID:  userInfo.UserID,
+			Time:    time.Now(),
+			Type:    repository.ScanAssetEventTypeCreated,
+		}
+		err = s.repo.AddAssetHistoryEntry(ctx, tx, event)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to add asset history entry", logging.FieldError, err)
+			return nil, err
+		}
+		err = recordAudit(ctx, tx, s.audit, AuditRecord{
+			Action:     repository.AuditActionCreate,
+			TargetType: repository.AuditTargetAsset,
+			TargetID:   asset.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		result.Created = append(result.Created, asset)
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("created %d scan assets, skipped %d", len(result.Created), len(result.Skipped)))
+
+	return &result, nil
 }
 
-func (s scanService) UpdateAsset(ctx context.Context, id string, newEndpoint string) (*repository.ScanAsset, error) {
-	tx, err := s.pool.Begin(ctx)
+// createAssetSavepoint creates an asset within a savepoint, so an existing endpoint does not abort tx. It returns
+// false if the endpoint already exists.
+func (s scanService) createAssetSavepoint(ctx context.Context, tx pgx.Tx, asset repository.ScanAsset) (bool, error) {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.repo.CreateScanAsset(ctx, savepoint, asset)
+	if errors.Is(err, repository.ErrUniqueViolation) {
+		return false, savepoint.Rollback(ctx)
+	}
+	if err != nil {
+		_ = savepoint.Rollback(ctx)
+		return false, err
+	}
+	return true, savepoint.Commit(ctx)
+}
+
+func (s scanService) DeleteAsset(ctx context.Context, id string, opts DeleteAssetOptions) (*repository.ScanAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -421,51 +929,55 @@ func (s scanService) UpdateAsset(ctx context.Context, id string, newEndpoint str
 		}
 	}()
 
-	asset, err := s.repo.GetScanAsset(ctx, tx, id)
-	if err != nil {
-		s.logger.ErrorContext(ctx, "Failed to get scan asset for update",
-			logging.FieldAssetID, id, logging.FieldError, err)
-		return nil, err
-	}
-
-	asset.Endpoint = newEndpoint
-	err = s.repo.UpdateScanAsset(ctx, tx, *asset)
-	if err != nil {
-		s.logger.ErrorContext(ctx, "Failed to update scan asset",
-			logging.FieldAssetID, id, logging.FieldError, err)
-		return nil, err
-	}
+	var asset *repository.ScanAsset
+	if opts.Permanent {
+		asset, err = s.repo.PurgeScanAsset(ctx, tx, id)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to purge scan asset",
+				logging.FieldAssetID, id, logging.FieldError, err)
+			return nil, err
+		}
+	} else {
+		asset, err = s.repo.GetScanAsset(ctx, tx, id)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to get scan asset for deletion",
+				logging.FieldAssetID, id, logging.FieldError, err)
+			return nil, err
+		}
 
-	// create event
-	userInfo, err := cortexContext.UserInfo(ctx)
-	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to get user info from context", logging.FieldError, err)
-		return nil, err
-	}
+		err = s.repo.DeleteScanAsset(ctx, tx, id)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to delete scan asset",
+				logging.FieldAssetID, id, logging.FieldError, err)
+			return nil, err
+		}
 
-	event := repository.AssetHistoryEntry{
-		ID:      uuid.New().String(),
-		AssetID: asset.ID,
-		UserID:  userInfo.UserID,
-		Time:    time.Now(),
-		Type:    repository.ScanAssetEventTypeUpdated,
-		// TODO: get changed attributes
-		Data: nil,
+		err = s.addAssetEvent(ctx, tx, id, repository.ScanAssetEventTypeDeleted)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = s.repo.AddAssetHistoryEntry(ctx, tx, event)
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionDelete,
+		TargetType: repository.AuditTargetAsset,
+		TargetID:   id,
+	})
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to add asset history entry", logging.FieldError, err)
 		return nil, err
 	}
 
-	s.logger.InfoContext(ctx, "scan asset updated", logging.FieldAssetID, id)
+	if opts.Permanent {
+		s.logger.InfoContext(ctx, "scan asset deleted permanently", logging.FieldAssetID, id)
+	} else {
+		s.logger.InfoContext(ctx, "scan asset deleted", logging.FieldAssetID, id)
+	}
 
 	return asset, nil
 }
 
-func (s scanService) RunScan(ctx context.Context, configID string, assetIds []string) (*repository.ScanExecution, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) RestoreAsset(ctx context.Context, id string) (*repository.ScanAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -478,57 +990,660 @@ func (s scanService) RunScan(ctx context.Context, configID string, assetIds []st
 		}
 	}()
 
-	// check if scan config exists
-	config, err := s.repo.GetScanConfiguration(ctx, tx, configID)
+	// a restored asset counts against the limit like a new one
+	if s.maxAssets > 0 {
+		var count int
+		count, err = s.repo.CountScanAssets(ctx, tx)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to count scan assets", logging.FieldError, err)
+			return nil, err
+		}
+		if err = checkLimit("assets", s.maxAssets, count); err != nil {
+			s.logger.WarnContext(ctx, "rejected restoring scan asset", logging.FieldAssetID, id, logging.FieldError, err)
+			return nil, err
+		}
+	}
+
+	asset, err := s.repo.RestoreScanAsset(ctx, tx, id)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to get scan configuration",
+		if !errors.Is(err, repository.ErrNotFound) && !errors.Is(err, repository.ErrUniqueViolation) {
+			s.logger.ErrorContext(ctx, "failed to restore scan asset",
+				logging.FieldAssetID, id, logging.FieldError, err)
+		}
+		return nil, err
+	}
+
+	err = s.addAssetEvent(ctx, tx, id, repository.ScanAssetEventTypeRestored)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionRestore,
+		TargetType: repository.AuditTargetAsset,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "scan asset restored", logging.FieldAssetID, id)
+
+	return asset, nil
+}
+
+// addAssetEvent adds a history entry without data for a change of an asset by the user of ctx.
+func (s scanService) addAssetEvent(ctx context.Context, tx pgx.Tx, assetID string, eventType repository.ScanAssetEventType) error {
+	userInfo, err := cortexContext.UserInfo(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get user info from context", logging.FieldError, err)
+		return err
+	}
+
+	err = s.repo.AddAssetHistoryEntry(ctx, tx, repository.AssetHistoryEntry{
+		ID:      uuid.New().String(),
+		AssetID: assetID,
+		UserID:  userInfo.UserID,
+		Time:    time.Now(),
+		Type:    eventType,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to add asset history entry", logging.FieldError, err)
+		return err
+	}
+	return nil
+}
+
+func (s scanService) UpdateAsset(ctx context.Context, id string, newEndpoint string) (*repository.ScanAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	asset, err := s.repo.GetScanAsset(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to get scan asset for update",
+			logging.FieldAssetID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	changes := assetChanges(*asset, newEndpoint)
+	if len(changes) == 0 {
+		// nothing changed, no history entry is written
+		return asset, nil
+	}
+
+	asset.Endpoint = newEndpoint
+	err = s.repo.UpdateScanAsset(ctx, tx, *asset)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to update scan asset",
+			logging.FieldAssetID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	// create event
+	userInfo, err := cortexContext.UserInfo(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get user info from context", logging.FieldError, err)
+		return nil, err
+	}
+
+	event := repository.AssetHistoryEntry{
+		ID:      uuid.New().String(),
+		AssetID: asset.ID,
+		UserID:  userInfo.UserID,
+		Time:    time.Now(),
+		Type:    repository.ScanAssetEventTypeUpdated,
+		Data:    changes,
+	}
+
+	err = s.repo.AddAssetHistoryEntry(ctx, tx, event)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to add asset history entry", logging.FieldError, err)
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionUpdate,
+		TargetType: repository.AuditTargetAsset,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "scan asset updated", logging.FieldAssetID, id)
+
+	return asset, nil
+}
+
+// assetChanges returns the attributes of asset that differ from the new values, e.g.
+// {"endpoint": {"old": "a.example.com", "new": "b.example.com"}}.
+func assetChanges(asset repository.ScanAsset, newEndpoint string) map[string]any {
+	changes := map[string]any{}
+	if asset.Endpoint != newEndpoint {
+		changes["endpoint"] = map[string]any{"old": asset.Endpoint, "new": newEndpoint}
+	}
+	return changes
+}
+
+func (s scanService) SetAssetTags(ctx context.Context, id string, tags map[string]string) (*repository.ScanAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	asset, err := s.repo.GetScanAsset(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get scan asset",
+			logging.FieldAssetID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	err = s.repo.SetAssetTags(ctx, tx, id, tags)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to set asset tags",
+			logging.FieldAssetID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	userInfo, err := cortexContext.UserInfo(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get user info from context", logging.FieldError, err)
+		return nil, err
+	}
+
+	err = s.repo.AddAssetHistoryEntry(ctx, tx, repository.AssetHistoryEntry{
+		ID:      uuid.New().String(),
+		AssetID: asset.ID,
+		UserID:  userInfo.UserID,
+		Time:    time.Now(),
+		Type:    repository.ScanAssetEventTypeUpdated,
+		Data:    map[string]any{"tags": tags},
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to add asset history entry", logging.FieldError, err)
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, s.audit, AuditRecord{
+		Action:     repository.AuditActionUpdate,
+		TargetType: repository.AuditTargetAsset,
+		TargetID:   id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "scan asset tags updated", logging.FieldAssetID, id)
+
+	if len(tags) > 0 {
+		asset.Tags = tags
+	}
+	return asset, nil
+}
+
+func (s scanService) PreviewScanTargets(ctx context.Context, configID string, assetIds []string, opts RunScanOptions) (*ScanTargets, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var missing []string
+	if len(opts.Endpoints) > 0 {
+		var endpointAssetIDs []string
+		endpointAssetIDs, missing, err = s.resolveEndpoints(ctx, tx, opts, false)
+		if err != nil {
+			return nil, err
+		}
+		assetIds = append(slices.Clone(assetIds), endpointAssetIDs...)
+	}
+
+	targets, err := s.resolveScanTargets(ctx, tx, configID, assetIds, len(opts.Endpoints) == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	targets.NewEndpoints = []string{}
+	for _, endpoint := range missing {
+		if opts.CreateAssets {
+			targets.NewEndpoints = append(targets.NewEndpoints, endpoint)
+			continue
+		}
+		targets.Excluded = append(targets.Excluded, ScanTargetExclusion{
+			Endpoint: endpoint,
+			Reason:   ScanTargetUnresolvable,
+		})
+	}
+	return targets, nil
+}
+
+// resolveScanTargets determines the assets scanned by a scan of a configuration. Without asset IDs, the default assets
+// of the configuration are scanned if defaults is set. Unknown assets and assets repeating an endpoint are excluded.
+func (s scanService) resolveScanTargets(ctx context.Context, tx pgx.Tx, configID string, assetIds []string, defaults bool) (*ScanTargets, error) {
+	// check if scan config exists
+	config, err := s.repo.GetScanConfiguration(ctx, tx, configID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get scan configuration",
 			logging.FieldError, err)
 		return nil, err
 	}
 
-	now := time.Now()
-	scan := repository.ScanExecution{
-		ID:                  uuid.New().String(),
-		ScanConfigurationID: config.ID,
-		Status:              repository.ScanStatusQueued,
-		StartTime:           pgtype.Timestamp{Time: now},
+	// fall back to the default assets of the scan configuration
+	if len(assetIds) == 0 && defaults {
+		var defaultAssets []repository.ScanAsset
+		defaultAssets, err = s.repo.GetScanConfigurationAssets(ctx, tx, config.ID)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to get scan configuration assets",
+				logging.FieldScanConfigID, config.ID, logging.FieldError, err)
+			return nil, err
+		}
+		if len(defaultAssets) == 0 {
+			return nil, ErrNoScanAssets
+		}
+		for _, asset := range defaultAssets {
+			assetIds = append(assetIds, asset.ID)
+		}
+	}
+
+	targets := ScanTargets{
+		ScanConfigurationID: config.ID,
+		Targets:             []repository.ScanAsset{},
+		Excluded:            []ScanTargetExclusion{},
+	}
+	endpoints := make(map[string]bool)
+	for _, assetId := range assetIds {
+		// check if the asset exists
+		var asset *repository.ScanAsset
+		asset, err = s.repo.GetScanAsset(ctx, tx, assetId)
+		if errors.Is(err, repository.ErrNotFound) {
+			targets.Excluded = append(targets.Excluded, ScanTargetExclusion{
+				AssetID: assetId,
+				Reason:  ScanTargetUnresolvable,
+			})
+			continue
+		}
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to get scan asset",
+				logging.FieldAssetID, assetId, logging.FieldError, err)
+			return nil, err
+		}
+
+		endpoint := strings.ToLower(strings.TrimSpace(asset.Endpoint))
+		if endpoints[endpoint] {
+			targets.Excluded = append(targets.Excluded, ScanTargetExclusion{
+				AssetID:  asset.ID,
+				Endpoint: asset.Endpoint,
+				Reason:   ScanTargetDuplicate,
+			})
+			continue
+		}
+		endpoints[endpoint] = true
+		targets.Targets = append(targets.Targets, *asset)
+	}
+
+	return &targets, nil
+}
+
+// resolveEndpoints returns the ids of the assets with opts.Endpoints. If create is set, missing assets are created if
+// opts.CreateAssets is set and fail with repository.ErrNotFound otherwise. If create is not set, nothing is created and
+// the endpoints without asset are returned as well, after checking that creating them stays within the asset limit.
+func (s scanService) resolveEndpoints(ctx context.Context, tx pgx.Tx, opts RunScanOptions, create bool) ([]string, []string, error) {
+	var count int
+	var err error
+	if opts.CreateAssets && s.maxAssets > 0 {
+		count, err = s.repo.CountScanAssets(ctx, tx)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to count scan assets", logging.FieldError, err)
+			return nil, nil, err
+		}
+	}
+
+	assetIDs := make([]string, 0, len(opts.Endpoints))
+	var missing []string
+	for _, endpoint := range opts.Endpoints {
+		var asset *repository.ScanAsset
+		asset, err = s.repo.GetScanAssetByEndpoint(ctx, tx, endpoint)
+		if err == nil {
+			assetIDs = append(assetIDs, asset.ID)
+			continue
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			s.logger.ErrorContext(ctx, "failed to get scan asset by endpoint", logging.FieldError, err)
+			return nil, nil, err
+		}
+		if !create && slices.Contains(missing, endpoint) {
+			// repeated endpoints resolve to the asset created for the first one
+			continue
+		}
+		if !opts.CreateAssets {
+			if !create {
+				missing = append(missing, endpoint)
+				continue
+			}
+			return nil, nil, fmt.Errorf("%w: asset with endpoint %s", repository.ErrNotFound, endpoint)
+		}
+
+		if err = checkLimit("assets", s.maxAssets, count); err != nil {
+			s.logger.WarnContext(ctx, "rejected scan assets", logging.FieldError, err)
+			return nil, nil, err
+		}
+		count++
+		if !create {
+			missing = append(missing, endpoint)
+			continue
+		}
+
+		created := repository.ScanAsset{ID: uuid.New().String(), Endpoint: endpoint}
+		err = s.repo.CreateScanAsset(ctx, tx, created)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to create scan asset", logging.FieldError, err)
+			return nil, nil, err
+		}
+
+		err = s.addAssetEvent(ctx, tx, created.ID, repository.ScanAssetEventTypeCreated)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = recordAudit(ctx, tx, s.audit, AuditRecord{
+			Action:     repository.AuditActionCreate,
+			TargetType: repository.AuditTargetAsset,
+			TargetID:   created.ID,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s.logger.InfoContext(ctx, "scan asset created for scan", logging.FieldAssetID, created.ID)
+		assetIDs = append(assetIDs, created.ID)
+	}
+	return assetIDs, missing, nil
+}
+
+func (s scanService) RunScan(ctx context.Context, configID string, assetIds []string, opts RunScanOptions) (*repository.ScanExecution, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if len(opts.Endpoints) > 0 {
+		var endpointAssetIDs []string
+		endpointAssetIDs, _, err = s.resolveEndpoints(ctx, tx, opts, true)
+		if err != nil {
+			return nil, err
+		}
+		assetIds = append(slices.Clone(assetIds), endpointAssetIDs...)
+	}
+
+	targets, err := s.resolveScanTargets(ctx, tx, configID, assetIds, len(opts.Endpoints) == 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, excluded := range targets.Excluded {
+		if excluded.Reason == ScanTargetUnresolvable {
+			err = fmt.Errorf("%w: asset %s", repository.ErrNotFound, excluded.AssetID)
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	scan := repository.ScanExecution{
+		ID:                  uuid.New().String(),
+		ScanConfigurationID: targets.ScanConfigurationID,
+		Status:              repository.ScanStatusQueued,
+		StartTime:           pgtype.Timestamp{Time: now},
+		UpdatedAt:           now,
+		Assets:              targets.Targets,
+		AssetCount:          len(targets.Targets),
+	}
+
+	err = s.repo.CreateScan(ctx, tx, scan)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to create scan",
+			logging.FieldError, err)
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "queued scan execution",
+		logging.FieldScanConfigID, scan.ScanConfigurationID, logging.FieldScanID, scan.ID)
+
+	// like scans read from the repository, the scan embeds only the first assets
+	scan.Assets = scan.Assets[:min(len(scan.Assets), repository.MaxEmbeddedScanAssets)]
+
+	// commit before running scan so scanner can access the scan
+	err = tx.Commit(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to commit transaction when creating scan", logging.FieldError, err)
+		return nil, err
+	}
+
+	return &scan, nil
+}
+
+func (s scanService) ListScans(ctx context.Context, opts ListScansOptions) ([]repository.ScanExecution, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	scans, err := s.repo.ListScans(ctx, tx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list scans", logging.FieldError, err)
+		return nil, err
+	}
+
+	if opts.IncludeCounts {
+		err = s.setFindingCounts(ctx, tx, scans)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scans, nil
+}
+
+func (s scanService) ListScansByConfig(ctx context.Context, configID string, opts ListScansOptions) ([]repository.ScanExecution, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// distinguish a configuration without scans from an unknown configuration
+	_, err = s.repo.GetScanConfiguration(ctx, tx, configID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			s.logger.ErrorContext(ctx, "failed to get scan configuration",
+				logging.FieldScanConfigID, configID, logging.FieldError, err)
+		}
+		return nil, err
+	}
+
+	scans, err := s.repo.ListScansByConfig(ctx, tx, configID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list scans of scan configuration",
+			logging.FieldScanConfigID, configID, logging.FieldError, err)
+		return nil, err
+	}
+
+	if opts.IncludeCounts {
+		err = s.setFindingCounts(ctx, tx, scans)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scans, nil
+}
+
+// setFindingCounts sets the finding counts of scans.
+func (s scanService) setFindingCounts(ctx context.Context, tx pgx.Tx, scans []repository.ScanExecution) error {
+	if len(scans) == 0 {
+		return nil
+	}
+	scanIDs := make([]string, len(scans))
+	for i, scan := range scans {
+		scanIDs[i] = scan.ID
+	}
+
+	counts, err := s.repo.CountScanFindings(ctx, tx, scanIDs)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to count findings of scans", logging.FieldError, err)
+		return err
+	}
+	for i := range scans {
+		count := counts[scans[i].ID]
+		scans[i].FindingCounts = &count
+	}
+	return nil
+}
+
+func (s scanService) GetScan(ctx context.Context, id string) (*repository.ScanExecution, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	scan, err := s.repo.GetScan(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get scan", logging.FieldError, err)
+		return nil, err
+	}
+
+	// include progress that has not been written yet
+	if update, ok := s.updates.Pending(id); ok {
+		applyScanUpdate(scan, update)
+		// the time the held back update was received is not tracked, it is at the latest now
+		scan.UpdatedAt = s.now()
 	}
 
-	// add assets to scan
-	for _, assetId := range assetIds {
-		// check if the asset exists
-		asset, err := s.repo.GetScanAsset(ctx, tx, assetId)
+	if scan.Status == repository.ScanStatusQueued {
+		scan.QueuePosition, err = s.repo.GetScanQueuePosition(ctx, tx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			// picked up by an agent in the meantime
+			err = nil
+		}
 		if err != nil {
-			s.logger.ErrorContext(ctx, "failed to get scan asset",
-				logging.FieldAssetID, assetId, logging.FieldError, err)
+			s.logger.ErrorContext(ctx, "failed to get queue position of scan", logging.FieldScanID, id,
+				logging.FieldError, err)
 			return nil, err
 		}
+	}
+	return scan, nil
+}
 
-		scan.Assets = append(scan.Assets, *asset)
+func (s scanService) ListAssetsOfScan(ctx context.Context, scanID string, limit int, offset int) ([]repository.ScanAsset, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
 
-	err = s.repo.CreateScan(ctx, tx, scan)
+	assets, err := s.repo.ListAssetsOfScan(ctx, tx, scanID, limit, offset)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to create scan",
-			logging.FieldError, err)
+		s.logger.ErrorContext(ctx, "failed to list assets of scan", logging.FieldScanID, scanID, logging.FieldError, err)
 		return nil, err
 	}
+	return assets, nil
+}
 
-	s.logger.InfoContext(ctx, "queued scan execution",
-		logging.FieldScanConfigID, config.ID, logging.FieldScanID, scan.ID)
+func (s scanService) UpdateScan(ctx context.Context, scanID string, update ScanUpdateOptions) (*repository.ScanExecution, error) {
+	merged, writeNow := s.updates.Add(ctx, scanID, update)
+	if !writeNow {
+		// update is batched, respond with the state it will have once written
+		scan, err := s.GetScan(ctx, scanID)
+		if err != nil {
+			return nil, err
+		}
+		applyScanUpdate(scan, merged)
+		s.events.Publish(*scan)
 
-	// commit before running scan so scanner can access the scan
-	err = tx.Commit(ctx)
+		s.logger.DebugContext(ctx, "deferred scan update", logging.FieldScanID, scanID)
+		return scan, nil
+	}
+
+	scan, err := s.writeScanUpdate(ctx, scanID, merged)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to commit transaction when creating scan", logging.FieldError, err)
+		s.updates.Discard(scanID)
 		return nil, err
 	}
+	s.events.Publish(*scan)
+	return scan, nil
+}
 
-	return &scan, nil
+func (s scanService) SubscribeScan(ctx context.Context, scanID string) (*ScanSubscription, error) {
+	// subscribe before reading the current state so no update in between is missed
+	events, cancel := s.events.Subscribe(scanID)
+
+	scan, err := s.GetScan(ctx, scanID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s.logger.DebugContext(ctx, "subscribed to scan events", logging.FieldScanID, scanID)
+
+	return &ScanSubscription{
+		Scan:   scan,
+		Events: events,
+		cancel: cancel,
+	}, nil
+}
+
+func (s scanService) NotifyFindings(scanID string, count int) {
+	s.events.AddFindings(scanID, count)
 }
 
-func (s scanService) ListScans(ctx context.Context) ([]repository.ScanExecution, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) writeScanUpdate(ctx context.Context, scanID string, update ScanUpdateOptions) (*repository.ScanExecution, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -541,16 +1656,58 @@ func (s scanService) ListScans(ctx context.Context) ([]repository.ScanExecution,
 		}
 	}()
 
-	scans, err := s.repo.ListScans(ctx, tx)
+	// check if scan exists
+	scan, err := s.repo.GetScan(ctx, tx, scanID)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to list scans", logging.FieldError, err)
+		s.logger.ErrorContext(ctx, "failed to get scan",
+			logging.FieldError, err)
 		return nil, err
 	}
-	return scans, nil
+
+	if update.Status == string(repository.ScanStatusRunning) && scan.Status != repository.ScanStatusRunning {
+		if err = s.checkScanCapacity(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	applyScanUpdate(scan, update)
+	scan.UpdatedAt = s.now()
+
+	err = s.repo.UpdateScan(ctx, tx, *scan)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to update scan",
+			logging.FieldError, err)
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "updated scan", logging.FieldScanID, scan.ID)
+
+	return scan, nil
 }
 
-func (s scanService) GetScan(ctx context.Context, id string) (*repository.ScanExecution, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) UpdateScanStatuses(ctx context.Context, scanIDs []string, status repository.ScanStatus, errorMessage string) ([]ScanStatusResult, error) {
+	results, err := s.writeScanStatuses(ctx, scanIDs, status, errorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	// publish once the transaction has been committed
+	updated := 0
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		updated++
+		s.updates.Discard(result.ScanID)
+		s.events.Publish(*result.Scan)
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("moved %d of %d scans to status %s", updated, len(results), status))
+	return results, nil
+}
+
+func (s scanService) writeScanStatuses(ctx context.Context, scanIDs []string, status repository.ScanStatus, errorMessage string) ([]ScanStatusResult, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -563,16 +1720,192 @@ func (s scanService) GetScan(ctx context.Context, id string) (*repository.ScanEx
 		}
 	}()
 
-	scan, err := s.repo.GetScan(ctx, tx, id)
+	results := make([]ScanStatusResult, 0, len(scanIDs))
+	for _, id := range scanIDs {
+		var scan *repository.ScanExecution
+		scan, err = s.repo.GetScan(ctx, tx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			err = nil
+			results = append(results, ScanStatusResult{ScanID: id, Err: repository.ErrNotFound})
+			continue
+		}
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to get scan", logging.FieldScanID, id, logging.FieldError, err)
+			return nil, err
+		}
+
+		if !scan.Status.CanTransitionTo(status) {
+			results = append(results, ScanStatusResult{ScanID: id, Scan: scan,
+				Err: fmt.Errorf("%w: scan is %s", ErrInvalidScanTransition, scan.Status)})
+			continue
+		}
+		if status == repository.ScanStatusRunning {
+			capacityErr := s.checkScanCapacity(ctx, tx)
+			if errors.Is(capacityErr, ErrLimitExceeded) {
+				results = append(results, ScanStatusResult{ScanID: id, Scan: scan, Err: capacityErr})
+				continue
+			}
+			if capacityErr != nil {
+				err = capacityErr
+				return nil, err
+			}
+		}
+
+		applyScanUpdate(scan, ScanUpdateOptions{Status: string(status), Error: errorMessage})
+		if status.IsTerminal() && !scan.EndTime.Valid {
+			scan.EndTime = pgtype.Timestamp{Time: s.now(), Valid: true}
+		}
+		scan.UpdatedAt = s.now()
+		err = s.repo.UpdateScan(ctx, tx, *scan)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to update scan", logging.FieldScanID, id, logging.FieldError, err)
+			return nil, err
+		}
+		results = append(results, ScanStatusResult{ScanID: id, Scan: scan})
+	}
+
+	return results, nil
+}
+
+// checkScanCapacity returns ErrLimitExceeded if no further scan can start running.
+func (s scanService) checkScanCapacity(ctx context.Context, tx pgx.Tx) error {
+	if s.maxRunning <= 0 {
+		return nil
+	}
+
+	count, err := s.repo.CountRunningScans(ctx, tx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to count running scans", logging.FieldError, err)
+		return err
+	}
+	if err = checkLimit("running scans", s.maxRunning, count); err != nil {
+		s.logger.InfoContext(ctx, "scan stays queued", logging.FieldError, err)
+		return err
+	}
+	return nil
+}
+
+func (s scanService) FailOverdueScans(ctx context.Context) (int, error) {
+	if s.maxRunDuration <= 0 {
+		return 0, nil
+	}
+
+	scanIDs, err := s.listOverdueScanIDs(ctx, s.now().Add(-s.maxRunDuration))
+	if err != nil || len(scanIDs) == 0 {
+		return 0, err
+	}
+
+	// scans finishing in the meantime cannot move to failed anymore and are skipped
+	results, err := s.UpdateScanStatuses(ctx, scanIDs, repository.ScanStatusFailed,
+		fmt.Sprintf("scan timed out after running for more than %s", s.maxRunDuration))
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err == nil {
+			failed++
+			s.logger.WarnContext(ctx, "failed overdue scan", logging.FieldScanID, result.ScanID)
+		}
+	}
+	return failed, nil
+}
+
+func (s scanService) listOverdueScanIDs(ctx context.Context, startedBefore time.Time) ([]string, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to get scan", logging.FieldError, err)
 		return nil, err
 	}
-	return scan, nil
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	scanIDs, err := s.repo.ListOverdueScanIDs(ctx, tx, startedBefore.UTC())
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list overdue scans", logging.FieldError, err)
+		return nil, err
+	}
+	return scanIDs, nil
 }
 
-func (s scanService) UpdateScan(ctx context.Context, scanID string, update ScanUpdateOptions) (*repository.ScanExecution, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) RecoverStaleScans(ctx context.Context) (int, error) {
+	if s.staleScanAge <= 0 {
+		return 0, nil
+	}
+
+	statuses := []repository.ScanStatus{repository.ScanStatusQueued, repository.ScanStatusRunning}
+	if s.requeueStale {
+		statuses = []repository.ScanStatus{repository.ScanStatusRunning}
+	}
+	scanIDs, err := s.listStaleScanIDs(ctx, s.now().Add(-s.staleScanAge), statuses)
+	if err != nil || len(scanIDs) == 0 {
+		return 0, err
+	}
+
+	if s.requeueStale {
+		return s.requeueScans(ctx, scanIDs)
+	}
+
+	// scans that finished since they were listed are rejected by the status transition check
+	results, err := s.UpdateScanStatuses(ctx, scanIDs, repository.ScanStatusFailed,
+		fmt.Sprintf("scan abandoned after no change for more than %s", s.staleScanAge))
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err == nil {
+			failed++
+			s.logger.WarnContext(ctx, "failed stale scan", logging.FieldScanID, result.ScanID)
+		}
+	}
+	return failed, nil
+}
+
+func (s scanService) listStaleScanIDs(ctx context.Context, updatedBefore time.Time, statuses []repository.ScanStatus) ([]string, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	scans, err := s.repo.ListScansByStatus(ctx, tx, statuses...)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list scans by status", logging.FieldError, err)
+		return nil, err
+	}
+
+	var scanIDs []string
+	for _, scan := range scans {
+		if scan.UpdatedAt.Before(updatedBefore) {
+			scanIDs = append(scanIDs, scan.ID)
+		}
+	}
+	return scanIDs, nil
+}
+
+// requeueScans moves running scans back to queued so they are picked up again, it bypasses the status transitions.
+func (s scanService) requeueScans(ctx context.Context, scanIDs []string) (int, error) {
+	scans, err := s.writeRequeuedScans(ctx, scanIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	// publish once the transaction has been committed
+	for _, scan := range scans {
+		s.updates.Discard(scan.ID)
+		s.events.Publish(*scan)
+		s.logger.WarnContext(ctx, "requeued stale scan", logging.FieldScanID, scan.ID)
+	}
+	return len(scans), nil
+}
+
+func (s scanService) writeRequeuedScans(ctx context.Context, scanIDs []string) ([]*repository.ScanExecution, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -585,39 +1918,199 @@ func (s scanService) UpdateScan(ctx context.Context, scanID string, update ScanU
 		}
 	}()
 
-	// check if scan exists
-	scan, err := s.repo.GetScan(ctx, tx, scanID)
+	var scans []*repository.ScanExecution
+	for _, id := range scanIDs {
+		var scan *repository.ScanExecution
+		scan, err = s.repo.GetScan(ctx, tx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to get scan", logging.FieldScanID, id, logging.FieldError, err)
+			return nil, err
+		}
+		// the scan finished in the meantime
+		if scan.Status != repository.ScanStatusRunning {
+			continue
+		}
+
+		scan.Status = repository.ScanStatusQueued
+		scan.EndTime = pgtype.Timestamp{}
+		scan.Error = ""
+		scan.UpdatedAt = s.now()
+		err = s.repo.UpdateScan(ctx, tx, *scan)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to update scan", logging.FieldScanID, id, logging.FieldError, err)
+			return nil, err
+		}
+		scans = append(scans, scan)
+	}
+
+	return scans, nil
+}
+
+func (s scanService) WatchOverdueScans(ctx context.Context, interval time.Duration) {
+	if s.maxRunDuration <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.FailOverdueScans(ctx); err != nil {
+				s.logger.ErrorContext(ctx, "failed to fail overdue scans", logging.FieldError, err)
+			}
+		}
+	}
+}
+
+func (s scanService) DeleteScan(ctx context.Context, id string) (*repository.ScanExecution, error) {
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to get scan",
-			logging.FieldError, err)
 		return nil, err
 	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	scan, err := s.repo.GetScan(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get scan for deletion",
+			logging.FieldScanID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	// deleting a running scan would leave the agent reporting into the void
+	if scan.Status == repository.ScanStatusRunning {
+		err = ErrScanRunning
+		s.logger.WarnContext(ctx, "refusing to delete running scan", logging.FieldScanID, id)
+		return nil, err
+	}
+
+	err = s.repo.DeleteScan(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete scan",
+			logging.FieldScanID, id, logging.FieldError, err)
+		return nil, err
+	}
+	s.updates.Discard(id)
+	s.events.Remove(id)
+
+	s.logger.InfoContext(ctx, "scan deleted", logging.FieldScanID, id)
+
+	return scan, nil
+}
 
-	// apply updates
-	if !update.StartTime.Before(time.Date(1970, 1, 1, 2, 0, 0, 0, time.UTC)) {
+func applyScanUpdate(scan *repository.ScanExecution, update ScanUpdateOptions) {
+	if isScanTimeSet(update.StartTime) {
 		scan.StartTime.Time = update.StartTime
 	}
-	if !update.EndTime.Before(time.Date(1970, 1, 1, 2, 0, 0, 0, time.UTC)) {
+	if isScanTimeSet(update.EndTime) {
 		scan.EndTime.Time = update.EndTime
 	}
 	if update.Status != "" {
 		scan.Status = repository.ScanStatus(update.Status)
+		if scan.Status != repository.ScanStatusFailed {
+			scan.Error = ""
+		}
+	}
+	if update.Error != "" && scan.Status == repository.ScanStatusFailed {
+		scan.Error = sanitizeScanError(update.Error)
 	}
+}
 
-	err = s.repo.UpdateScan(ctx, tx, *scan)
+// MaxScanErrorLength is the maximum number of characters of a scan error. Longer errors are truncated when stored.
+const MaxScanErrorLength = 4096
+
+// sanitizeScanError reduces an error reported for a scan to a single line of printable characters of bounded length.
+func sanitizeScanError(message string) string {
+	message = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return ' '
+		}
+		if !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, message)
+	message = strings.Join(strings.Fields(message), " ")
+
+	if runes := []rune(message); len(runes) > MaxScanErrorLength {
+		message = string(runes[:MaxScanErrorLength-1]) + "…"
+	}
+	return message
+}
+
+// isScanTimeSet reports whether t holds an actual timestamp rather than an unset unix time.
+func isScanTimeSet(t time.Time) bool {
+	return !t.Before(time.Date(1970, 1, 1, 2, 0, 0, 0, time.UTC))
+}
+
+func (s scanService) ListFindings(ctx context.Context, filter repository.FindingFilter) ([]repository.AssetFinding, error) {
+	if filter.TriageStatuses == nil {
+		filter.TriageStatuses = repository.VisibleTriageStatuses
+	}
+
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to update scan",
-			logging.FieldError, err)
 		return nil, err
 	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
 
-	s.logger.InfoContext(ctx, "updated scan", logging.FieldScanID, scan.ID)
+	findings, err := s.repo.ListFindings(ctx, tx, filter)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list findings", logging.FieldError, err)
+		return nil, err
+	}
+	return findings, nil
+}
 
-	return scan, nil
+func (s scanService) EachFinding(ctx context.Context, filter repository.FindingFilter, fn func(repository.AssetFindingWithAsset) error) error {
+	if filter.TriageStatuses == nil {
+		filter.TriageStatuses = repository.VisibleTriageStatuses
+	}
+
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	err = s.repo.EachFinding(ctx, tx, filter, fn)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to read findings", logging.FieldError, err)
+		return err
+	}
+	return nil
 }
 
-func (s scanService) ListAssetFindings(ctx context.Context, assetID string) ([]repository.AssetFinding, error) {
-	tx, err := s.pool.Begin(ctx)
+func (s scanService) ListAssetFindings(ctx context.Context, assetID string, opts ListFindingsOptions) ([]repository.AssetFinding, error) {
+	filter := repository.FindingFilter{TriageStatuses: opts.TriageStatuses}
+	if filter.TriageStatuses == nil {
+		filter.TriageStatuses = repository.VisibleTriageStatuses
+	}
+
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -630,7 +2123,7 @@ func (s scanService) ListAssetFindings(ctx context.Context, assetID string) ([]r
 		}
 	}()
 
-	results, err := s.repo.ListAssetFindings(ctx, tx, assetID)
+	results, err := s.repo.ListAssetFindings(ctx, tx, assetID, filter)
 	if err != nil {
 		s.logger.ErrorContext(ctx, "failed to list asset discovery results",
 			logging.FieldAssetID, assetID, logging.FieldError, err)
@@ -640,7 +2133,7 @@ func (s scanService) ListAssetFindings(ctx context.Context, assetID string) ([]r
 }
 
 func (s scanService) ListAssetHistory(ctx context.Context, assetID string) ([]repository.AssetHistoryEntry, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.pool.beginTx(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -662,10 +2155,24 @@ func (s scanService) ListAssetHistory(ctx context.Context, assetID string) ([]re
 	return history, nil
 }
 
-func NewScanService(scanRepo repository.ScanRepository, pool *pgxpool.Pool) ScanService {
-	return scanService{
-		repo:   scanRepo,
-		logger: logging.GetLogger(logging.DataAccess),
-		pool:   pool,
+func NewScanService(scanRepo repository.ScanRepository, pool *Pool, opts ScanServiceOptions) ScanService {
+	s := scanService{
+		repo:           scanRepo,
+		logger:         logging.GetLogger(logging.DataAccess),
+		pool:           pool,
+		events:         newScanEventBroker(opts.FindingEventWindow),
+		maxAssets:      opts.MaxAssets,
+		maxScanConfigs: opts.MaxScanConfigs,
+		maxRunDuration: opts.MaxRunDuration,
+		staleScanAge:   opts.StaleScanAge,
+		requeueStale:   opts.RequeueStaleScans,
+		maxRunning:     opts.MaxConcurrentScans,
+		audit:          opts.Audit,
+		now:            time.Now,
 	}
+	s.updates = newScanUpdateBatcher(opts.UpdateInterval, func(ctx context.Context, scanID string, update ScanUpdateOptions) error {
+		_, err := s.writeScanUpdate(ctx, scanID, update)
+		return err
+	})
+	return s
 }
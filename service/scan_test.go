@@ -0,0 +1,1165 @@
+package service
+
+import (
+	"context"
+	cortexContext "cortex/context"
+	"cortex/repository"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckEngineChange(t *testing.T) {
+	assert.NoError(t, checkEngineChange(0, false))
+	assert.ErrorIs(t, checkEngineChange(2, false), ErrScanConfigInUse)
+	assert.NoError(t, checkEngineChange(2, true))
+}
+
+func TestApplyScanUpdateError(t *testing.T) {
+	scan := &repository.ScanExecution{Status: repository.ScanStatusRunning}
+
+	applyScanUpdate(scan, ScanUpdateOptions{Status: string(repository.ScanStatusFailed), Error: "nmap:\texit status 1\n"})
+	assert.Equal(t, repository.ScanStatusFailed, scan.Status)
+	assert.Equal(t, "nmap: exit status 1", scan.Error)
+
+	// errors are ignored unless the scan failed
+	applyScanUpdate(scan, ScanUpdateOptions{Status: string(repository.ScanStatusQueued)})
+	assert.Empty(t, scan.Error)
+	applyScanUpdate(scan, ScanUpdateOptions{Error: "ignored"})
+	assert.Empty(t, scan.Error)
+}
+
+func TestSanitizeScanError(t *testing.T) {
+	assert.Equal(t, "connection refused", sanitizeScanError("  connection\r\nrefused\x00 "))
+
+	long := sanitizeScanError(strings.Repeat("x", 2*MaxScanErrorLength))
+	assert.Len(t, []rune(long), MaxScanErrorLength)
+	assert.True(t, strings.HasSuffix(long, "…"))
+}
+
+func TestMergeScanUpdatesError(t *testing.T) {
+	merged := mergeScanUpdates(ScanUpdateOptions{}, ScanUpdateOptions{Status: "failed", Error: "timeout"})
+	assert.Equal(t, "timeout", merged.Error)
+
+	merged = mergeScanUpdates(merged, ScanUpdateOptions{Status: "queued"})
+	assert.Empty(t, merged.Error)
+}
+
+// memoryScanRepository keeps scans in memory. Methods not needed by the tests panic.
+type memoryScanRepository struct {
+	repository.ScanRepository
+	configs  map[string]repository.ScanConfiguration
+	assets   map[string]repository.ScanAsset
+	scans    map[string]repository.ScanExecution
+	findings map[string]repository.AssetFinding
+	history  []repository.AssetHistoryEntry
+	tags     map[string]map[string]string
+	stats    map[string]repository.ScanAssetStats
+	// deleted holds the assets removed by DeleteScanAsset
+	deleted map[string]repository.ScanAsset
+	// statsQueries is the number of ListAssetStats calls
+	statsQueries int
+	// findingFilter is the filter of the last ListFindings or ListAssetFindings call
+	findingFilter repository.FindingFilter
+	// findingCounts are the finding counts of the scans returned by CountScanFindings
+	findingCounts map[string]repository.ScanFindingCounts
+	// countedScanIDs are the scan IDs of the CountScanFindings calls
+	countedScanIDs []string
+	// activeScanIDs are the IDs of the active scans of an asset returned by PutAssetFinding
+	activeScanIDs map[string][]string
+	// configAssets are the IDs of the default assets of a scan configuration
+	configAssets map[string][]string
+}
+
+func (m *memoryScanRepository) GetScanConfigurationAssets(_ context.Context, _ pgx.Tx, id string) ([]repository.ScanAsset, error) {
+	var assets []repository.ScanAsset
+	for _, assetID := range m.configAssets[id] {
+		assets = append(assets, m.assets[assetID])
+	}
+	return assets, nil
+}
+
+func (m *memoryScanRepository) GetAssetFinding(_ context.Context, _ pgx.Tx, id string) (*repository.AssetFinding, error) {
+	finding, ok := m.findings[id]
+	if _, deleted := m.deleted[finding.AssetID]; !ok || deleted {
+		return nil, repository.ErrNotFound
+	}
+	return &finding, nil
+}
+
+func (m *memoryScanRepository) GetAssetFindingIncludingDeleted(_ context.Context, _ pgx.Tx, id string) (*repository.AssetFinding, error) {
+	finding, ok := m.findings[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &finding, nil
+}
+
+func (m *memoryScanRepository) ListFindings(_ context.Context, _ pgx.Tx, filter repository.FindingFilter) ([]repository.AssetFinding, error) {
+	m.findingFilter = filter
+	var findings []repository.AssetFinding
+	for _, finding := range m.findings {
+		if (filter.AssetID == "" || finding.AssetID == filter.AssetID) &&
+			(filter.Type == "" || finding.Type == filter.Type) &&
+			(filter.Severity == "" || finding.Data["severity"] == string(filter.Severity)) &&
+			(len(filter.TriageStatuses) == 0 || slices.Contains(filter.TriageStatuses, finding.TriageStatus)) {
+			findings = append(findings, finding)
+		}
+	}
+	slices.SortFunc(findings, func(a, b repository.AssetFinding) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return findings, nil
+}
+
+func (m *memoryScanRepository) ListAssetFindings(_ context.Context, _ pgx.Tx, assetID string, filter repository.FindingFilter) ([]repository.AssetFinding, error) {
+	m.findingFilter = filter
+	var findings []repository.AssetFinding
+	for _, finding := range m.findings {
+		if finding.AssetID == assetID &&
+			(len(filter.TriageStatuses) == 0 || slices.Contains(filter.TriageStatuses, finding.TriageStatus)) {
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+func (m *memoryScanRepository) UpdateAssetFindingTriage(_ context.Context, _ pgx.Tx, id string, status repository.TriageStatus) error {
+	finding, ok := m.findings[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	finding.TriageStatus = status
+	m.findings[id] = finding
+	return nil
+}
+
+func (m *memoryScanRepository) PutAssetFinding(_ context.Context, _ pgx.Tx, finding repository.AssetFinding) (*repository.AssetFinding, []string, error) {
+	for id, existing := range m.findings {
+		if existing.AssetID == finding.AssetID && existing.FindingHash == finding.FindingHash {
+			finding.ID, finding.FirstSeen = id, existing.FirstSeen
+			m.findings[id] = finding
+			return &finding, nil, nil
+		}
+	}
+	m.findings[finding.ID] = finding
+	return &finding, m.activeScanIDs[finding.AssetID], nil
+}
+
+func (m *memoryScanRepository) ListFindingAssetIDs(_ context.Context, _ pgx.Tx, afterID string, limit int) ([]string, error) {
+	var assetIDs []string
+	for _, finding := range m.findings {
+		if finding.AssetID > afterID && !slices.Contains(assetIDs, finding.AssetID) {
+			assetIDs = append(assetIDs, finding.AssetID)
+		}
+	}
+	slices.Sort(assetIDs)
+	return assetIDs[:min(limit, len(assetIDs))], nil
+}
+
+func (m *memoryScanRepository) UpdateAssetFindings(_ context.Context, _ pgx.Tx, findings []repository.AssetFinding) error {
+	for _, finding := range findings {
+		if _, ok := m.findings[finding.ID]; !ok {
+			return repository.ErrNotFound
+		}
+		m.findings[finding.ID] = finding
+	}
+	return nil
+}
+
+func (m *memoryScanRepository) MergeAssetFindings(_ context.Context, _ pgx.Tx, merged map[string]string) error {
+	for id := range merged {
+		delete(m.findings, id)
+	}
+	return nil
+}
+
+func (m *memoryScanRepository) ListOverdueScanIDs(_ context.Context, _ pgx.Tx, startedBefore time.Time) ([]string, error) {
+	var scanIDs []string
+	for id, scan := range m.scans {
+		if scan.Status == repository.ScanStatusRunning && scan.StartTime.Valid && scan.StartTime.Time.Before(startedBefore) {
+			scanIDs = append(scanIDs, id)
+		}
+	}
+	slices.Sort(scanIDs)
+	return scanIDs, nil
+}
+
+func (m *memoryScanRepository) GetScanConfiguration(_ context.Context, _ pgx.Tx, id string) (*repository.ScanConfiguration, error) {
+	config, ok := m.configs[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &config, nil
+}
+
+func (m *memoryScanRepository) GetScanAsset(_ context.Context, _ pgx.Tx, id string) (*repository.ScanAsset, error) {
+	asset, ok := m.assets[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &asset, nil
+}
+
+func (m *memoryScanRepository) GetScanAssetIncludingDeleted(_ context.Context, _ pgx.Tx, id string) (*repository.ScanAsset, error) {
+	if asset, ok := m.deleted[id]; ok {
+		asset.Deleted = true
+		return &asset, nil
+	}
+	return m.GetScanAsset(context.Background(), nil, id)
+}
+
+func (m *memoryScanRepository) GetAssetHistory(_ context.Context, _ pgx.Tx, assetID string) ([]repository.AssetHistoryEntry, error) {
+	var entries []repository.AssetHistoryEntry
+	for _, entry := range m.history {
+		if entry.AssetID == assetID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (m *memoryScanRepository) GetScanAssetByEndpoint(_ context.Context, _ pgx.Tx, endpoint string) (*repository.ScanAsset, error) {
+	for _, asset := range m.assets {
+		if asset.Endpoint == endpoint {
+			return &asset, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memoryScanRepository) ListScanAssets(_ context.Context, _ pgx.Tx, filter repository.AssetFilter) ([]repository.ScanAsset, error) {
+	var assets []repository.ScanAsset
+	for _, asset := range m.assets {
+		matches := true
+		for key, value := range filter.Tags {
+			if m.tags[asset.ID][key] != value {
+				matches = false
+			}
+		}
+		if matches {
+			assets = append(assets, asset)
+		}
+	}
+	slices.SortFunc(assets, func(a, b repository.ScanAsset) int { return strings.Compare(a.ID, b.ID) })
+	return assets, nil
+}
+
+func (m *memoryScanRepository) GetAssetTags(_ context.Context, _ pgx.Tx, assetIDs []string) (map[string]map[string]string, error) {
+	tags := map[string]map[string]string{}
+	for _, id := range assetIDs {
+		if m.tags[id] != nil {
+			tags[id] = m.tags[id]
+		}
+	}
+	return tags, nil
+}
+
+func (m *memoryScanRepository) SetAssetTags(_ context.Context, _ pgx.Tx, assetID string, tags map[string]string) error {
+	if len(tags) == 0 {
+		delete(m.tags, assetID)
+		return nil
+	}
+	m.tags[assetID] = tags
+	return nil
+}
+
+func (m *memoryScanRepository) ListAssetStats(_ context.Context, _ pgx.Tx, assetIDs []string) (map[string]repository.ScanAssetStats, error) {
+	m.statsQueries++
+	stats := map[string]repository.ScanAssetStats{}
+	for _, id := range assetIDs {
+		stats[id] = m.stats[id]
+	}
+	return stats, nil
+}
+
+func (m *memoryScanRepository) CountScanAssets(context.Context, pgx.Tx) (int, error) {
+	return len(m.assets), nil
+}
+
+func (m *memoryScanRepository) CreateScanAsset(_ context.Context, _ pgx.Tx, asset repository.ScanAsset) error {
+	for _, existing := range m.assets {
+		if existing.Endpoint == asset.Endpoint {
+			return repository.ErrUniqueViolation
+		}
+	}
+	m.assets[asset.ID] = asset
+	return nil
+}
+
+func (m *memoryScanRepository) DeleteScanAsset(_ context.Context, _ pgx.Tx, id string) error {
+	asset, ok := m.assets[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if m.deleted == nil {
+		m.deleted = map[string]repository.ScanAsset{}
+	}
+	m.deleted[id] = asset
+	delete(m.assets, id)
+	return nil
+}
+
+func (m *memoryScanRepository) PurgeScanAsset(_ context.Context, _ pgx.Tx, id string) (*repository.ScanAsset, error) {
+	asset, ok := m.assets[id]
+	if !ok {
+		if asset, ok = m.deleted[id]; !ok {
+			return nil, repository.ErrNotFound
+		}
+	}
+	delete(m.assets, id)
+	delete(m.deleted, id)
+	return &asset, nil
+}
+
+func (m *memoryScanRepository) RestoreScanAsset(_ context.Context, _ pgx.Tx, id string) (*repository.ScanAsset, error) {
+	asset, ok := m.deleted[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	for _, existing := range m.assets {
+		if existing.Endpoint == asset.Endpoint {
+			return nil, repository.ErrUniqueViolation
+		}
+	}
+	m.assets[id] = asset
+	delete(m.deleted, id)
+	return &asset, nil
+}
+
+func (m *memoryScanRepository) UpdateScanAsset(_ context.Context, _ pgx.Tx, asset repository.ScanAsset) error {
+	if _, ok := m.assets[asset.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	m.assets[asset.ID] = asset
+	return nil
+}
+
+func (m *memoryScanRepository) ListScansByConfig(_ context.Context, _ pgx.Tx, configID string) ([]repository.ScanExecution, error) {
+	var scans []repository.ScanExecution
+	for _, scan := range m.scans {
+		if scan.ScanConfigurationID == configID {
+			scans = append(scans, scan)
+		}
+	}
+	slices.SortFunc(scans, func(a, b repository.ScanExecution) int { return strings.Compare(a.ID, b.ID) })
+	return scans, nil
+}
+
+func (m *memoryScanRepository) ListScansByStatus(_ context.Context, _ pgx.Tx, statuses ...repository.ScanStatus) ([]repository.ScanExecution, error) {
+	var scans []repository.ScanExecution
+	for _, scan := range m.scans {
+		if slices.Contains(statuses, scan.Status) {
+			scans = append(scans, scan)
+		}
+	}
+	slices.SortFunc(scans, func(a, b repository.ScanExecution) int { return strings.Compare(a.ID, b.ID) })
+	return scans, nil
+}
+
+func (m *memoryScanRepository) CountScanFindings(_ context.Context, _ pgx.Tx, scanIDs []string) (map[string]repository.ScanFindingCounts, error) {
+	m.countedScanIDs = append(m.countedScanIDs, scanIDs...)
+	counts := map[string]repository.ScanFindingCounts{}
+	for _, id := range scanIDs {
+		if count, ok := m.findingCounts[id]; ok {
+			counts[id] = count
+		}
+	}
+	return counts, nil
+}
+
+func (m *memoryScanRepository) AddAssetHistoryEntry(_ context.Context, _ pgx.Tx, entry repository.AssetHistoryEntry) error {
+	m.history = append(m.history, entry)
+	return nil
+}
+
+func (m *memoryScanRepository) CreateScan(_ context.Context, _ pgx.Tx, scan repository.ScanExecution) error {
+	m.scans[scan.ID] = scan
+	return nil
+}
+
+func (m *memoryScanRepository) GetScan(_ context.Context, _ pgx.Tx, id string) (*repository.ScanExecution, error) {
+	scan, ok := m.scans[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &scan, nil
+}
+
+func (m *memoryScanRepository) UpdateScan(_ context.Context, _ pgx.Tx, scan repository.ScanExecution) error {
+	if _, ok := m.scans[scan.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	m.scans[scan.ID] = scan
+	return nil
+}
+
+func (m *memoryScanRepository) DeleteScan(_ context.Context, _ pgx.Tx, id string) error {
+	if _, ok := m.scans[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(m.scans, id)
+	return nil
+}
+
+func (m *memoryScanRepository) CountRunningScans(context.Context, pgx.Tx) (int, error) {
+	count := 0
+	for _, scan := range m.scans {
+		if scan.Status == repository.ScanStatusRunning {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryScanRepository) GetScanQueuePosition(_ context.Context, _ pgx.Tx, id string) (int, error) {
+	scan, ok := m.scans[id]
+	if !ok || scan.Status != repository.ScanStatusQueued {
+		return 0, repository.ErrNotFound
+	}
+	position := 0
+	for _, queued := range m.scans {
+		if queued.Status != repository.ScanStatusQueued {
+			continue
+		}
+		if queued.UpdatedAt.Before(scan.UpdatedAt) || queued.UpdatedAt.Equal(scan.UpdatedAt) && queued.ID <= scan.ID {
+			position++
+		}
+	}
+	return position, nil
+}
+
+func TestUpdateScanStatuses(t *testing.T) {
+	repo := &memoryScanRepository{scans: map[string]repository.ScanExecution{
+		"queued":   {ID: "queued", Status: repository.ScanStatusQueued},
+		"running":  {ID: "running", Status: repository.ScanStatusRunning},
+		"complete": {ID: "complete", Status: repository.ScanStatusComplete},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+
+	results, err := svc.UpdateScanStatuses(context.Background(), []string{"queued", "running", "complete", "unknown"},
+		repository.ScanStatusFailed, "agent lost")
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	for _, result := range results[:2] {
+		assert.NoError(t, result.Err, result.ScanID)
+		assert.Equal(t, repository.ScanStatusFailed, repo.scans[result.ScanID].Status)
+		assert.Equal(t, "agent lost", repo.scans[result.ScanID].Error)
+		assert.True(t, repo.scans[result.ScanID].EndTime.Valid)
+		assert.False(t, repo.scans[result.ScanID].UpdatedAt.IsZero())
+	}
+	assert.ErrorIs(t, results[2].Err, ErrInvalidScanTransition)
+	assert.Equal(t, repository.ScanStatusComplete, repo.scans["complete"].Status)
+	assert.True(t, repo.scans["complete"].UpdatedAt.IsZero())
+	assert.ErrorIs(t, results[3].Err, repository.ErrNotFound)
+}
+
+func TestMaxConcurrentScans(t *testing.T) {
+	const maxRunning = 2
+	repo := &memoryScanRepository{scans: map[string]repository.ScanExecution{}}
+	for i := range 6 {
+		id := fmt.Sprintf("scan-%d", i)
+		repo.scans[id] = repository.ScanExecution{ID: id, Status: repository.ScanStatusQueued}
+	}
+	// a single connection serializes the transactions like the lock taken by CountRunningScans
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{MaxConcurrentScans: maxRunning})
+	ctx := context.Background()
+
+	// agents try to start all scans at once
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Go(func() {
+			_, errs[i] = svc.UpdateScan(ctx, fmt.Sprintf("scan-%d", i), ScanUpdateOptions{Status: "running"})
+		})
+	}
+	wg.Wait()
+
+	started := 0
+	for _, err := range errs {
+		if err == nil {
+			started++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	}
+	assert.Equal(t, maxRunning, started)
+	assert.Equal(t, maxRunning, countScans(repo, repository.ScanStatusRunning))
+	assert.Equal(t, 4, countScans(repo, repository.ScanStatusQueued))
+
+	// a slot frees once a running scan has ended
+	running, err := repo.ListScansByStatus(ctx, nil, repository.ScanStatusRunning)
+	require.NoError(t, err)
+	_, err = svc.UpdateScan(ctx, running[0].ID, ScanUpdateOptions{Status: "complete"})
+	require.NoError(t, err)
+
+	queued, err := repo.ListScansByStatus(ctx, nil, repository.ScanStatusQueued)
+	require.NoError(t, err)
+	results, err := svc.UpdateScanStatuses(ctx, []string{queued[0].ID, queued[1].ID}, repository.ScanStatusRunning, "")
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrLimitExceeded)
+	assert.Equal(t, maxRunning, countScans(repo, repository.ScanStatusRunning))
+	assert.Equal(t, repository.ScanStatusQueued, repo.scans[queued[1].ID].Status)
+}
+
+func TestScanQueuePosition(t *testing.T) {
+	queuedAt := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	repo := &memoryScanRepository{scans: map[string]repository.ScanExecution{
+		"first":  {ID: "first", Status: repository.ScanStatusQueued, UpdatedAt: queuedAt},
+		"second": {ID: "second", Status: repository.ScanStatusQueued, UpdatedAt: queuedAt.Add(time.Second)},
+		"third":  {ID: "third", Status: repository.ScanStatusQueued, UpdatedAt: queuedAt.Add(2 * time.Second)},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+	ctx := context.Background()
+
+	positions := func() []int {
+		var result []int
+		for _, id := range []string{"first", "second", "third"} {
+			scan, err := svc.GetScan(ctx, id)
+			require.NoError(t, err)
+			result = append(result, scan.QueuePosition)
+		}
+		return result
+	}
+	assert.Equal(t, []int{1, 2, 3}, positions())
+
+	// scans move up as the ones ahead of them are picked up
+	_, err := svc.UpdateScan(ctx, "first", ScanUpdateOptions{Status: "running"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, positions())
+
+	_, err = svc.UpdateScan(ctx, "third", ScanUpdateOptions{Status: "running"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 0}, positions())
+}
+
+func countScans(repo *memoryScanRepository, status repository.ScanStatus) int {
+	count := 0
+	for _, scan := range repo.scans {
+		if scan.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRecoverStaleScans(t *testing.T) {
+	now := time.Date(2025, 12, 15, 10, 0, 0, 0, time.UTC)
+	newRepo := func() *memoryScanRepository {
+		return &memoryScanRepository{scans: map[string]repository.ScanExecution{
+			"stale-running": {ID: "stale-running", Status: repository.ScanStatusRunning, UpdatedAt: now.Add(-2 * time.Hour)},
+			"stale-queued":  {ID: "stale-queued", Status: repository.ScanStatusQueued, UpdatedAt: now.Add(-2 * time.Hour)},
+			"running":       {ID: "running", Status: repository.ScanStatusRunning, UpdatedAt: now.Add(-time.Minute)},
+			"complete":      {ID: "complete", Status: repository.ScanStatusComplete, UpdatedAt: now.Add(-2 * time.Hour)},
+		}}
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		repo := newRepo()
+		svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{StaleScanAge: time.Hour}).(scanService)
+		svc.now = func() time.Time { return now }
+
+		recovered, err := svc.RecoverStaleScans(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, recovered)
+
+		for _, id := range []string{"stale-running", "stale-queued"} {
+			assert.Equal(t, repository.ScanStatusFailed, repo.scans[id].Status, id)
+			assert.Equal(t, "scan abandoned after no change for more than 1h0m0s", repo.scans[id].Error)
+			assert.Equal(t, now, repo.scans[id].EndTime.Time)
+		}
+		assert.Equal(t, repository.ScanStatusRunning, repo.scans["running"].Status)
+		assert.Equal(t, repository.ScanStatusComplete, repo.scans["complete"].Status)
+	})
+
+	t.Run("requeue", func(t *testing.T) {
+		repo := newRepo()
+		svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{StaleScanAge: time.Hour, RequeueStaleScans: true}).(scanService)
+		svc.now = func() time.Time { return now }
+
+		recovered, err := svc.RecoverStaleScans(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, recovered)
+
+		assert.Equal(t, repository.ScanStatusQueued, repo.scans["stale-running"].Status)
+		assert.Equal(t, now, repo.scans["stale-running"].UpdatedAt)
+		assert.Equal(t, repository.ScanStatusQueued, repo.scans["stale-queued"].Status)
+		assert.Equal(t, repository.ScanStatusRunning, repo.scans["running"].Status)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		repo := newRepo()
+		svc := NewScanService(repo, nil, ScanServiceOptions{})
+
+		recovered, err := svc.RecoverStaleScans(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, recovered)
+		assert.Equal(t, repository.ScanStatusRunning, repo.scans["stale-running"].Status)
+	})
+}
+
+func TestFailOverdueScans(t *testing.T) {
+	now := time.Date(2025, 12, 10, 10, 0, 0, 0, time.UTC)
+	started := func(ago time.Duration) pgtype.Timestamp {
+		return pgtype.Timestamp{Time: now.Add(-ago), Valid: true}
+	}
+	repo := &memoryScanRepository{scans: map[string]repository.ScanExecution{
+		"overdue":  {ID: "overdue", Status: repository.ScanStatusRunning, StartTime: started(3 * time.Hour)},
+		"running":  {ID: "running", Status: repository.ScanStatusRunning, StartTime: started(time.Hour)},
+		"complete": {ID: "complete", Status: repository.ScanStatusComplete, StartTime: started(3 * time.Hour)},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{MaxRunDuration: 2 * time.Hour}).(scanService)
+	svc.now = func() time.Time { return now }
+
+	events, unsubscribe := svc.events.Subscribe("overdue")
+	defer unsubscribe()
+	svc.NotifyFindings("overdue", 2)
+
+	failed, err := svc.FailOverdueScans(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	// the finding counter of the timed out scan is cleared
+	assert.Empty(t, svc.events.findings)
+
+	overdue := repo.scans["overdue"]
+	assert.Equal(t, repository.ScanStatusFailed, overdue.Status)
+	assert.Equal(t, "scan timed out after running for more than 2h0m0s", overdue.Error)
+	assert.Equal(t, now, overdue.EndTime.Time)
+	assert.Equal(t, repository.ScanStatusRunning, repo.scans["running"].Status)
+	assert.Equal(t, repository.ScanStatusComplete, repo.scans["complete"].Status)
+
+	// subscribers of the failed scan receive its final state and are released
+	var published []repository.ScanStatus
+	for event := range events {
+		if event.Type == ScanEventTypeScan {
+			published = append(published, event.Scan.Status)
+		}
+	}
+	assert.Equal(t, []repository.ScanStatus{repository.ScanStatusFailed}, published)
+
+	// once the clock has moved on, the other scan is overdue as well
+	now = now.Add(time.Hour + time.Minute)
+	failed, err = svc.FailOverdueScans(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, repository.ScanStatusFailed, repo.scans["running"].Status)
+}
+
+func TestDeleteScanClearsEvents(t *testing.T) {
+	repo := &memoryScanRepository{scans: map[string]repository.ScanExecution{
+		"queued": {ID: "queued", Status: repository.ScanStatusQueued},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{FindingEventWindow: time.Hour}).(scanService)
+	events, unsubscribe := svc.events.Subscribe("queued")
+	defer unsubscribe()
+	svc.NotifyFindings("queued", 1)
+
+	_, err := svc.DeleteScan(context.Background(), "queued")
+	require.NoError(t, err)
+
+	// the deleted scan never reaches a terminal status, its counter and subscribers are released anyway
+	assert.Empty(t, svc.events.findings)
+	_, open := <-events
+	assert.False(t, open)
+}
+
+func TestRunScanRollsBackOnError(t *testing.T) {
+	repo := &memoryScanRepository{
+		configs: map[string]repository.ScanConfiguration{"config": {ID: "config"}},
+		assets:  map[string]repository.ScanAsset{"asset": {ID: "asset"}},
+		scans:   map[string]repository.ScanExecution{},
+	}
+	pool, queries := recordingFakePool(t, 1)
+	svc := NewScanService(repo, pool, ScanServiceOptions{})
+
+	// the second asset fails to load after the first one succeeded
+	_, err := svc.RunScan(context.Background(), "config", []string{"asset", "missing"}, RunScanOptions{})
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	assert.Empty(t, repo.scans)
+	assert.Contains(t, queries.List(), "rollback")
+	assert.NotContains(t, queries.List(), "commit")
+}
+
+func TestRunScanEndpoints(t *testing.T) {
+	repo := &memoryScanRepository{
+		configs: map[string]repository.ScanConfiguration{"config": {ID: "config"}},
+		assets:  map[string]repository.ScanAsset{"a": {ID: "a", Endpoint: "a.example.com"}},
+		scans:   map[string]repository.ScanExecution{},
+	}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+	endpoints := []string{"a.example.com", "10.0.0.0/24"}
+
+	// unknown endpoints fail the scan unless assets may be created
+	_, err := svc.RunScan(ctx, "config", nil, RunScanOptions{Endpoints: endpoints})
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	assert.Len(t, repo.assets, 1)
+	assert.Empty(t, repo.scans)
+
+	scan, err := svc.RunScan(ctx, "config", nil, RunScanOptions{Endpoints: endpoints, CreateAssets: true})
+	require.NoError(t, err)
+	require.Len(t, scan.Assets, 2)
+	assert.Equal(t, "a", scan.Assets[0].ID)
+	assert.Equal(t, "10.0.0.0/24", scan.Assets[1].Endpoint)
+	assert.Contains(t, repo.assets, scan.Assets[1].ID)
+	require.Len(t, repo.history, 1)
+	assert.Equal(t, repository.ScanAssetEventTypeCreated, repo.history[0].Type)
+	assert.Equal(t, scan.Assets[1].ID, repo.history[0].AssetID)
+}
+
+func TestRunScanEndpointsLimit(t *testing.T) {
+	repo := &memoryScanRepository{
+		configs: map[string]repository.ScanConfiguration{"config": {ID: "config"}},
+		assets:  map[string]repository.ScanAsset{"a": {ID: "a", Endpoint: "a.example.com"}},
+		scans:   map[string]repository.ScanExecution{},
+	}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{MaxAssets: 1})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	_, err := svc.RunScan(ctx, "config", nil, RunScanOptions{Endpoints: []string{"b.example.com"}, CreateAssets: true})
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Empty(t, repo.scans)
+}
+
+func TestPreviewScanTargetsExclusions(t *testing.T) {
+	repo := &memoryScanRepository{
+		configs: map[string]repository.ScanConfiguration{"config": {ID: "config"}},
+		assets: map[string]repository.ScanAsset{
+			"a": {ID: "a", Endpoint: "example.com"},
+			"b": {ID: "b", Endpoint: "10.0.0.1"},
+			"c": {ID: "c", Endpoint: " Example.com"},
+		},
+		scans: map[string]repository.ScanExecution{},
+	}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+
+	targets, err := svc.PreviewScanTargets(context.Background(), "config", []string{"a", "missing", "b", "c"},
+		RunScanOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []repository.ScanAsset{repo.assets["a"], repo.assets["b"]}, targets.Targets)
+	assert.Equal(t, []ScanTargetExclusion{
+		{AssetID: "missing", Reason: ScanTargetUnresolvable},
+		{AssetID: "c", Endpoint: " Example.com", Reason: ScanTargetDuplicate},
+	}, targets.Excluded)
+	assert.Empty(t, repo.scans)
+
+	_, err = svc.PreviewScanTargets(context.Background(), "unknown", []string{"a"}, RunScanOptions{})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestPreviewScanTargetsEndpoints(t *testing.T) {
+	repo := &memoryScanRepository{
+		configs: map[string]repository.ScanConfiguration{"config": {ID: "config"}},
+		assets: map[string]repository.ScanAsset{
+			"a": {ID: "a", Endpoint: "example.com"},
+			"b": {ID: "b", Endpoint: "10.0.0.1"},
+		},
+		configAssets: map[string][]string{"config": {"b"}},
+		scans:        map[string]repository.ScanExecution{},
+	}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{MaxAssets: 4})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+	endpoints := []string{"example.com", "10.0.0.0/24", "10.0.0.0/24"}
+
+	// like RunScan, existing endpoints are scanned and missing ones would be created
+	targets, err := svc.PreviewScanTargets(ctx, "config", nil, RunScanOptions{Endpoints: endpoints, CreateAssets: true})
+	require.NoError(t, err)
+	assert.Equal(t, []repository.ScanAsset{repo.assets["a"]}, targets.Targets)
+	assert.Equal(t, []string{"10.0.0.0/24"}, targets.NewEndpoints)
+	assert.Empty(t, targets.Excluded)
+	assert.Len(t, repo.assets, 2, "previews do not create assets")
+	assert.Empty(t, repo.history)
+
+	// RunScan fails for missing endpoints it may not create, the preview excludes them
+	targets, err = svc.PreviewScanTargets(ctx, "config", nil, RunScanOptions{Endpoints: endpoints})
+	require.NoError(t, err)
+	assert.Equal(t, []repository.ScanAsset{repo.assets["a"]}, targets.Targets)
+	assert.Empty(t, targets.NewEndpoints)
+	assert.Equal(t, []ScanTargetExclusion{{Endpoint: "10.0.0.0/24", Reason: ScanTargetUnresolvable}}, targets.Excluded)
+
+	// endpoints replace the default assets of the configuration even if none of them exists
+	targets, err = svc.PreviewScanTargets(ctx, "config", nil,
+		RunScanOptions{Endpoints: []string{"example.org"}, CreateAssets: true})
+	require.NoError(t, err)
+	assert.Empty(t, targets.Targets)
+	assert.Equal(t, []string{"example.org"}, targets.NewEndpoints)
+
+	// creating the assets would exceed the asset limit
+	_, err = svc.PreviewScanTargets(ctx, "config", nil,
+		RunScanOptions{Endpoints: []string{"a.example.org", "b.example.org", "c.example.org"}, CreateAssets: true})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestCreateAssetsSkipsDuplicates(t *testing.T) {
+	repo := &memoryScanRepository{assets: map[string]repository.ScanAsset{
+		"existing": {ID: "existing", Endpoint: "example.com"},
+	}}
+	pool, queries := recordingFakePool(t, 1)
+	svc := NewScanService(repo, pool, ScanServiceOptions{})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	result, err := svc.CreateAssets(ctx, []string{"example.com", "10.0.0.1", "example.org", "10.0.0.1"})
+	require.NoError(t, err)
+
+	var created []string
+	for _, asset := range result.Created {
+		created = append(created, asset.Endpoint)
+	}
+	assert.Equal(t, []string{"10.0.0.1", "example.org"}, created)
+	assert.Equal(t, []string{"example.com", "10.0.0.1"}, result.Skipped)
+	assert.Len(t, repo.assets, 3)
+
+	require.Len(t, repo.history, 2)
+	for i, entry := range repo.history {
+		assert.Equal(t, result.Created[i].ID, entry.AssetID)
+		assert.Equal(t, repository.ScanAssetEventTypeCreated, entry.Type)
+		assert.Equal(t, "user", entry.UserID)
+	}
+
+	// the duplicate only rolls back its savepoint, the batch is committed
+	assert.Contains(t, queries.List(), "rollback to savepoint sp_1")
+	assert.Equal(t, "commit", queries.List()[len(queries.List())-1])
+}
+
+func TestCreateAssetLimit(t *testing.T) {
+	repo := &memoryScanRepository{assets: map[string]repository.ScanAsset{}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{MaxAssets: 3})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	for _, endpoint := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		_, err := svc.CreateAsset(ctx, endpoint)
+		require.NoError(t, err)
+	}
+
+	_, err := svc.CreateAsset(ctx, "d.example.com")
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Len(t, repo.assets, 3)
+}
+
+func TestCreateAssetsLimit(t *testing.T) {
+	repo := &memoryScanRepository{assets: map[string]repository.ScanAsset{}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{MaxAssets: 1})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	_, err := svc.CreateAssets(ctx, []string{"example.com", "example.org"})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+}
+
+func TestListAssetFindingsTriageFilter(t *testing.T) {
+	repo := &memoryScanRepository{findings: map[string]repository.AssetFinding{
+		"open":  {ID: "open", AssetID: "asset", TriageStatus: repository.TriageStatusOpen},
+		"ack":   {ID: "ack", AssetID: "asset", TriageStatus: repository.TriageStatusAcknowledged},
+		"fp":    {ID: "fp", AssetID: "asset", TriageStatus: repository.TriageStatusFalsePositive},
+		"wfix":  {ID: "wfix", AssetID: "asset", TriageStatus: repository.TriageStatusWontFix},
+		"other": {ID: "other", AssetID: "other", TriageStatus: repository.TriageStatusOpen},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+	ctx := context.Background()
+
+	ids := func(findings []repository.AssetFinding) []string {
+		var ids []string
+		for _, finding := range findings {
+			ids = append(ids, finding.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	// acknowledged and false positive findings are hidden by default
+	findings, err := svc.ListAssetFindings(ctx, "asset", ListFindingsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"open", "wfix"}, ids(findings))
+	assert.Equal(t, repository.VisibleTriageStatuses, repo.findingFilter.TriageStatuses)
+
+	findings, err = svc.ListAssetFindings(ctx, "asset", ListFindingsOptions{
+		TriageStatuses: []repository.TriageStatus{repository.TriageStatusFalsePositive},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fp"}, ids(findings))
+
+	findings, err = svc.ListAssetFindings(ctx, "asset", ListFindingsOptions{TriageStatuses: repository.TriageStatuses})
+	require.NoError(t, err)
+	assert.Len(t, findings, 4)
+}
+
+func TestListFindings(t *testing.T) {
+	critical := map[string]any{"severity": "critical"}
+	repo := &memoryScanRepository{findings: map[string]repository.AssetFinding{
+		"port": {ID: "port", AssetID: "a", Type: repository.FindingTypePort,
+			TriageStatus: repository.TriageStatusOpen},
+		"vuln-a": {ID: "vuln-a", AssetID: "a", Type: repository.FindingTypeVulnerability, Data: critical,
+			TriageStatus: repository.TriageStatusOpen},
+		"vuln-b": {ID: "vuln-b", AssetID: "b", Type: repository.FindingTypeVulnerability, Data: critical,
+			TriageStatus: repository.TriageStatusOpen},
+		"vuln-fp": {ID: "vuln-fp", AssetID: "b", Type: repository.FindingTypeVulnerability, Data: critical,
+			TriageStatus: repository.TriageStatusFalsePositive},
+		"vuln-low": {ID: "vuln-low", AssetID: "b", Type: repository.FindingTypeVulnerability,
+			Data: map[string]any{"severity": "low"}, TriageStatus: repository.TriageStatusOpen},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+
+	findings, err := svc.ListFindings(context.Background(), repository.FindingFilter{
+		Type:     repository.FindingTypeVulnerability,
+		Severity: repository.SeverityCritical,
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	// findings of all assets are listed, those that need no attention are hidden by default
+	assert.Equal(t, "vuln-a", findings[0].ID)
+	assert.Equal(t, "vuln-b", findings[1].ID)
+	assert.Equal(t, repository.VisibleTriageStatuses, repo.findingFilter.TriageStatuses)
+	assert.Equal(t, 10, repo.findingFilter.Limit)
+}
+
+func TestUpdateFindingTriage(t *testing.T) {
+	repo := &memoryScanRepository{findings: map[string]repository.AssetFinding{
+		"finding": {ID: "finding", AssetID: "asset", TriageStatus: repository.TriageStatusOpen},
+	}}
+	svc := NewFindingService(repo, fakePool(t, 1), nil)
+	ctx := context.Background()
+
+	finding, err := svc.UpdateFindingTriage(ctx, "asset", "finding", repository.TriageStatusAcknowledged)
+	require.NoError(t, err)
+	assert.Equal(t, repository.TriageStatusAcknowledged, finding.TriageStatus)
+	assert.Equal(t, repository.TriageStatusAcknowledged, repo.findings["finding"].TriageStatus)
+
+	// findings of other assets are not found
+	_, err = svc.UpdateFindingTriage(ctx, "other", "finding", repository.TriageStatusOpen)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = svc.UpdateFindingTriage(ctx, "asset", "unknown", repository.TriageStatusOpen)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.Equal(t, repository.TriageStatusAcknowledged, repo.findings["finding"].TriageStatus)
+}
+
+func TestAssetTags(t *testing.T) {
+	repo := &memoryScanRepository{
+		assets: map[string]repository.ScanAsset{
+			"a": {ID: "a", Endpoint: "a.example.com"},
+			"b": {ID: "b", Endpoint: "b.example.com"},
+		},
+		tags: map[string]map[string]string{"b": {"env": "dev"}},
+	}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	asset, err := svc.SetAssetTags(ctx, "a", map[string]string{"env": "prod", "team": "platform"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "platform"}, asset.Tags)
+	require.Len(t, repo.history, 1)
+	assert.Equal(t, repository.ScanAssetEventTypeUpdated, repo.history[0].Type)
+
+	assets, err := svc.ListAssets(ctx, ListAssetsOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []repository.ScanAsset{
+		{ID: "a", Endpoint: "a.example.com", Tags: map[string]string{"env": "prod", "team": "platform"}},
+		{ID: "b", Endpoint: "b.example.com", Tags: map[string]string{"env": "dev"}},
+	}, assets)
+
+	assets, err = svc.ListAssets(ctx, ListAssetsOptions{Tags: map[string]string{"env": "prod"}})
+	require.NoError(t, err)
+	require.Len(t, assets, 1)
+	assert.Equal(t, "a", assets[0].ID)
+
+	_, err = svc.SetAssetTags(ctx, "a", map[string]string{})
+	require.NoError(t, err)
+	asset, err = svc.GetAsset(ctx, "a", GetAssetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, asset.Tags)
+
+	_, err = svc.SetAssetTags(ctx, "unknown", map[string]string{"env": "prod"})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestListAssetsWithStats(t *testing.T) {
+	repo := &memoryScanRepository{
+		assets: map[string]repository.ScanAsset{
+			"a": {ID: "a", Endpoint: "a.example.com"},
+			"b": {ID: "b", Endpoint: "b.example.com"},
+		},
+		stats: map[string]repository.ScanAssetStats{"a": {DiscoveredPortsCount: 3}},
+	}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+
+	assets, err := svc.ListAssetsWithStats(context.Background(), ListAssetsOptions{})
+	require.NoError(t, err)
+	require.Len(t, assets, 2)
+	assert.Equal(t, 3, assets[0].Stats.DiscoveredPortsCount)
+	assert.Equal(t, 0, assets[1].Stats.DiscoveredPortsCount)
+}
+
+func TestListAssetsWithStatsQueryCount(t *testing.T) {
+	repo := &memoryScanRepository{assets: map[string]repository.ScanAsset{}}
+	for i := range 100 {
+		id := fmt.Sprintf("asset-%03d", i)
+		repo.assets[id] = repository.ScanAsset{ID: id, Endpoint: id + ".example.com"}
+	}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+
+	// the stats are aggregated by one query instead of one per asset, GetAssetStats panics if called
+	assets, err := svc.ListAssetsWithStats(context.Background(), ListAssetsOptions{})
+	require.NoError(t, err)
+	assert.Len(t, assets, 100)
+	assert.Equal(t, 1, repo.statsQueries)
+}
+
+func TestDeleteAndRestoreAsset(t *testing.T) {
+	repo := &memoryScanRepository{assets: map[string]repository.ScanAsset{
+		"a": {ID: "a", Endpoint: "a.example.com"},
+		"b": {ID: "b", Endpoint: "b.example.com"},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	asset, err := svc.DeleteAsset(ctx, "a", DeleteAssetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "a.example.com", asset.Endpoint)
+	assert.NotContains(t, repo.assets, "a")
+	assert.Contains(t, repo.deleted, "a")
+
+	asset, err = svc.RestoreAsset(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a.example.com", asset.Endpoint)
+	assert.Contains(t, repo.assets, "a")
+
+	require.Len(t, repo.history, 2)
+	assert.Equal(t, repository.ScanAssetEventTypeDeleted, repo.history[0].Type)
+	assert.Equal(t, repository.ScanAssetEventTypeRestored, repo.history[1].Type)
+
+	// assets that are not deleted cannot be restored
+	_, err = svc.RestoreAsset(ctx, "a")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// the endpoint of a deleted asset can be taken by a new asset
+	_, err = svc.DeleteAsset(ctx, "b", DeleteAssetOptions{})
+	require.NoError(t, err)
+	repo.assets["c"] = repository.ScanAsset{ID: "c", Endpoint: "b.example.com"}
+	_, err = svc.RestoreAsset(ctx, "b")
+	assert.ErrorIs(t, err, repository.ErrUniqueViolation)
+
+	_, err = svc.DeleteAsset(ctx, "b", DeleteAssetOptions{Permanent: true})
+	require.NoError(t, err)
+	assert.NotContains(t, repo.deleted, "b")
+	_, err = svc.RestoreAsset(ctx, "b")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestRestoreAssetLimit(t *testing.T) {
+	repo := &memoryScanRepository{assets: map[string]repository.ScanAsset{
+		"a": {ID: "a", Endpoint: "a.example.com"},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{MaxAssets: 1})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	_, err := svc.DeleteAsset(ctx, "a", DeleteAssetOptions{})
+	require.NoError(t, err)
+	_, err = svc.CreateAsset(ctx, "b.example.com")
+	require.NoError(t, err)
+
+	// deleting and restoring does not bypass the limit
+	_, err = svc.RestoreAsset(ctx, "a")
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Contains(t, repo.deleted, "a")
+	assert.Len(t, repo.assets, 1)
+}
+
+func TestGetDeletedAsset(t *testing.T) {
+	repo := &memoryScanRepository{
+		assets:   map[string]repository.ScanAsset{"a": {ID: "a", Endpoint: "a.example.com"}},
+		findings: map[string]repository.AssetFinding{"f": {ID: "f", AssetID: "a"}},
+	}
+	svc := NewScanService(repo, fakePool(t, 5), ScanServiceOptions{})
+	findings := NewFindingService(repo, fakePool(t, 3), nil)
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	_, err := svc.DeleteAsset(ctx, "a", DeleteAssetOptions{})
+	require.NoError(t, err)
+
+	_, err = svc.GetAsset(ctx, "a", GetAssetOptions{})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = findings.GetFindingWithAsset(ctx, "f", GetFindingOptions{})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// the history still resolves the deleted asset, flagged as deleted
+	asset, err := svc.GetAsset(ctx, "a", GetAssetOptions{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, repository.ScanAsset{ID: "a", Endpoint: "a.example.com", Deleted: true}, *asset)
+	history, err := svc.ListAssetHistory(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, repository.ScanAssetEventTypeDeleted, history[0].Type)
+
+	finding, err := findings.GetFindingWithAsset(ctx, "f", GetFindingOptions{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, "f", finding.ID)
+	assert.True(t, finding.Asset.Deleted)
+}
+
+func TestUpdateAssetHistory(t *testing.T) {
+	repo := &memoryScanRepository{assets: map[string]repository.ScanAsset{
+		"a": {ID: "a", Endpoint: "a.example.com"},
+	}}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+	ctx := context.WithValue(context.Background(), cortexContext.KeyUserInfo, cortexContext.UserInfoData{UserID: "user"})
+
+	asset, err := svc.UpdateAsset(ctx, "a", "b.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "b.example.com", asset.Endpoint)
+	assert.Equal(t, "b.example.com", repo.assets["a"].Endpoint)
+	require.Len(t, repo.history, 1)
+	assert.Equal(t, repository.ScanAssetEventTypeUpdated, repo.history[0].Type)
+	assert.Equal(t, "user", repo.history[0].UserID)
+	assert.Equal(t, map[string]any{
+		"endpoint": map[string]any{"old": "a.example.com", "new": "b.example.com"},
+	}, repo.history[0].Data)
+
+	// updates without changes are not recorded
+	asset, err = svc.UpdateAsset(ctx, "a", "b.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "b.example.com", asset.Endpoint)
+	assert.Len(t, repo.history, 1)
+}
+
+func TestListScansByConfig(t *testing.T) {
+	repo := &memoryScanRepository{
+		configs: map[string]repository.ScanConfiguration{"config": {ID: "config"}, "unused": {ID: "unused"}},
+		scans: map[string]repository.ScanExecution{
+			"first":  {ID: "first", ScanConfigurationID: "config"},
+			"second": {ID: "second", ScanConfigurationID: "config"},
+			"other":  {ID: "other", ScanConfigurationID: "other"},
+		},
+		findingCounts: map[string]repository.ScanFindingCounts{
+			"first": {Findings: 2},
+			"other": {Findings: 5, NewFindings: 1},
+		},
+	}
+	svc := NewScanService(repo, fakePool(t, 1), ScanServiceOptions{})
+	ctx := context.Background()
+
+	scans, err := svc.ListScansByConfig(ctx, "config", ListScansOptions{IncludeCounts: true})
+	require.NoError(t, err)
+	require.Len(t, scans, 2)
+	assert.Equal(t, "first", scans[0].ID)
+	assert.Equal(t, &repository.ScanFindingCounts{Findings: 2}, scans[0].FindingCounts)
+	assert.Equal(t, &repository.ScanFindingCounts{}, scans[1].FindingCounts)
+	// only the listed scans are counted
+	assert.ElementsMatch(t, []string{"first", "second"}, repo.countedScanIDs)
+
+	scans, err = svc.ListScansByConfig(ctx, "unused", ListScansOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, scans)
+
+	_, err = svc.ListScansByConfig(ctx, "unknown", ListScansOptions{})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"cortex/logging"
+	"cortex/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrInvalidScanSchedule is returned when a schedule references a scan configuration or asset that does not exist.
+var ErrInvalidScanSchedule = errors.New("invalid scan schedule")
+
+// maxDueSchedules is the maximum number of schedules started by a single evaluation. Remaining due schedules are
+// started by the next evaluation.
+const maxDueSchedules = 100
+
+// invalidCronDelay is the time a schedule whose stored cron expression no longer parses is postponed by, so it is not
+// claimed and reported by every evaluation.
+const invalidCronDelay = 24 * time.Hour
+
+type ScheduleService interface {
+	ListSchedules(ctx context.Context) ([]repository.ScanSchedule, error)
+	GetSchedule(ctx context.Context, id string) (*repository.ScanSchedule, error)
+	CreateSchedule(ctx context.Context, opts ScanScheduleOptions) (*repository.ScanSchedule, error)
+	UpdateSchedule(ctx context.Context, id string, opts ScanScheduleOptions) (*repository.ScanSchedule, error)
+	DeleteSchedule(ctx context.Context, id string) (*repository.ScanSchedule, error)
+	// RunDueSchedules starts a scan for each schedule that is due and moves the schedule to its next run. It returns
+	// the number of started scans.
+	RunDueSchedules(ctx context.Context) (int, error)
+	// Run calls RunDueSchedules every interval until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// ScanScheduleOptions are the user defined attributes of a schedule.
+type ScanScheduleOptions struct {
+	ScanConfigurationID string
+	// AssetIDs are the assets scanned, empty scans the default assets of the scan configuration.
+	AssetIDs []string
+	// Cron is a cron expression accepted by ParseCron, evaluated in UTC.
+	Cron string
+}
+
+type scheduleService struct {
+	logger      *slog.Logger
+	repo        repository.ScanScheduleRepository
+	scanRepo    repository.ScanRepository
+	scanService ScanService
+	pool        *Pool
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+func (s scheduleService) ListSchedules(ctx context.Context) ([]repository.ScanSchedule, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	schedules, err := s.repo.ListScanSchedules(ctx, tx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list scan schedules", logging.FieldError, err)
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (s scheduleService) GetSchedule(ctx context.Context, id string) (*repository.ScanSchedule, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	schedule, err := s.repo.GetScanSchedule(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get scan schedule",
+			logging.FieldScheduleID, id, logging.FieldError, err)
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// checkScheduleReferences verifies that the scan configuration and assets of a schedule exist.
+func (s scheduleService) checkScheduleReferences(ctx context.Context, tx pgx.Tx, opts ScanScheduleOptions) error {
+	_, err := s.scanRepo.GetScanConfiguration(ctx, tx, opts.ScanConfigurationID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: scan configuration %s not found", ErrInvalidScanSchedule, opts.ScanConfigurationID)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, assetID := range opts.AssetIDs {
+		_, err = s.scanRepo.GetScanAsset(ctx, tx, assetID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("%w: asset %s not found", ErrInvalidScanSchedule, assetID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextRun returns the first time after now matched by a cron expression.
+func (s scheduleService) nextRun(expr string) (time.Time, error) {
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cron.Next(s.now()), nil
+}
+
+func (s scheduleService) CreateSchedule(ctx context.Context, opts ScanScheduleOptions) (*repository.ScanSchedule, error) {
+	nextRunAt, err := s.nextRun(opts.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = s.checkScheduleReferences(ctx, tx, opts); err != nil {
+		return nil, err
+	}
+
+	schedule := repository.ScanSchedule{
+		ID:                  uuid.New().String(),
+		ScanConfigurationID: opts.ScanConfigurationID,
+		AssetIDs:            opts.AssetIDs,
+		Cron:                opts.Cron,
+		NextRunAt:           nextRunAt,
+		CreatedAt:           s.now().UTC(),
+	}
+	err = s.repo.CreateScanSchedule(ctx, tx, schedule)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to create scan schedule", logging.FieldError, err)
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("scan schedule created, next run at %s", nextRunAt.Format(time.RFC3339)),
+		logging.FieldScheduleID, schedule.ID)
+
+	return &schedule, nil
+}
+
+func (s scheduleService) UpdateSchedule(ctx context.Context, id string, opts ScanScheduleOptions) (*repository.ScanSchedule, error) {
+	nextRunAt, err := s.nextRun(opts.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	schedule, err := s.repo.GetScanSchedule(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get scan schedule for update",
+			logging.FieldScheduleID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	if err = s.checkScheduleReferences(ctx, tx, opts); err != nil {
+		return nil, err
+	}
+
+	schedule.ScanConfigurationID = opts.ScanConfigurationID
+	schedule.AssetIDs = opts.AssetIDs
+	schedule.Cron = opts.Cron
+	schedule.NextRunAt = nextRunAt
+	err = s.repo.UpdateScanSchedule(ctx, tx, *schedule)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to update scan schedule",
+			logging.FieldScheduleID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, fmt.Sprintf("scan schedule updated, next run at %s", nextRunAt.Format(time.RFC3339)),
+		logging.FieldScheduleID, id)
+
+	return schedule, nil
+}
+
+func (s scheduleService) DeleteSchedule(ctx context.Context, id string) (*repository.ScanSchedule, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	schedule, err := s.repo.GetScanSchedule(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get scan schedule for deletion",
+			logging.FieldScheduleID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	err = s.repo.DeleteScanSchedule(ctx, tx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete scan schedule",
+			logging.FieldScheduleID, id, logging.FieldError, err)
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "scan schedule deleted", logging.FieldScheduleID, id)
+
+	return schedule, nil
+}
+
+// claimDueSchedules moves the due schedules to their next run and returns them. Claiming and moving happen in a single
+// transaction, so a schedule is claimed by one evaluation only, even across restarts and multiple instances.
+func (s scheduleService) claimDueSchedules(ctx context.Context) ([]repository.ScanSchedule, error) {
+	tx, err := s.pool.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit(ctx)
+		default:
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	now := s.now().UTC()
+	schedules, err := s.repo.ClaimDueScanSchedules(ctx, tx, now, maxDueSchedules)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to claim due scan schedules", logging.FieldError, err)
+		return nil, err
+	}
+
+	claimed := make([]repository.ScanSchedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		// runs missed while the server was down are not caught up, the schedule continues from now
+		nextRunAt, cronErr := s.nextRun(schedule.Cron)
+		if cronErr != nil {
+			// expressions are validated when stored, a schedule that no longer parses must not block the others and
+			// does not run until it is fixed
+			s.logger.ErrorContext(ctx, "failed to parse stored cron expression",
+				logging.FieldScheduleID, schedule.ID, logging.FieldError, cronErr)
+			schedule.NextRunAt = now.Add(invalidCronDelay)
+			if err = s.repo.UpdateScanSchedule(ctx, tx, schedule); err != nil {
+				s.logger.ErrorContext(ctx, "failed to postpone scan schedule",
+					logging.FieldScheduleID, schedule.ID, logging.FieldError, err)
+				return nil, err
+			}
+			continue
+		}
+		err = s.repo.SetScanScheduleRun(ctx, tx, schedule.ID, now, nextRunAt)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to set next run of scan schedule",
+				logging.FieldScheduleID, schedule.ID, logging.FieldError, err)
+			return nil, err
+		}
+		claimed = append(claimed, schedule)
+	}
+	return claimed, nil
+}
+
+func (s scheduleService) RunDueSchedules(ctx context.Context) (int, error) {
+	schedules, err := s.claimDueSchedules(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// a scan that fails to start is not retried before the next run of its schedule
+	started := 0
+	for _, schedule := range schedules {
+		var scan *repository.ScanExecution
+		scan, err = s.scanService.RunScan(ctx, schedule.ScanConfigurationID, schedule.AssetIDs, RunScanOptions{})
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to run scheduled scan",
+				logging.FieldScheduleID, schedule.ID, logging.FieldError, err)
+			continue
+		}
+		started++
+		s.logger.InfoContext(ctx, "scheduled scan started",
+			logging.FieldScheduleID, schedule.ID, logging.FieldScanID, scan.ID)
+	}
+	return started, nil
+}
+
+func (s scheduleService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.RunDueSchedules(ctx); err != nil {
+				s.logger.ErrorContext(ctx, "failed to run due scan schedules", logging.FieldError, err)
+			}
+		}
+	}
+}
+
+func NewScheduleService(scheduleRepo repository.ScanScheduleRepository, scanRepo repository.ScanRepository,
+	scanService ScanService, pool *Pool) ScheduleService {
+	return scheduleService{
+		logger:      logging.GetLogger(logging.Scan),
+		repo:        scheduleRepo,
+		scanRepo:    scanRepo,
+		scanService: scanService,
+		pool:        pool,
+		now:         time.Now,
+	}
+}
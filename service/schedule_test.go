@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"cortex/repository"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryScheduleRepository keeps schedules in memory. Methods not needed by the tests panic.
+type memoryScheduleRepository struct {
+	repository.ScanScheduleRepository
+	schedules map[string]repository.ScanSchedule
+}
+
+func (m *memoryScheduleRepository) CreateScanSchedule(_ context.Context, _ pgx.Tx, schedule repository.ScanSchedule) error {
+	m.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (m *memoryScheduleRepository) ClaimDueScanSchedules(_ context.Context, _ pgx.Tx, now time.Time, limit int) ([]repository.ScanSchedule, error) {
+	var due []repository.ScanSchedule
+	for _, schedule := range m.schedules {
+		if !schedule.NextRunAt.After(now) && len(due) < limit {
+			due = append(due, schedule)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryScheduleRepository) SetScanScheduleRun(_ context.Context, _ pgx.Tx, id string, runAt time.Time, nextRunAt time.Time) error {
+	schedule := m.schedules[id]
+	schedule.LastRunAt = pgtype.Timestamp{Time: runAt, Valid: true}
+	schedule.NextRunAt = nextRunAt
+	m.schedules[id] = schedule
+	return nil
+}
+
+func (m *memoryScheduleRepository) UpdateScanSchedule(_ context.Context, _ pgx.Tx, schedule repository.ScanSchedule) error {
+	if _, ok := m.schedules[schedule.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	m.schedules[schedule.ID] = schedule
+	return nil
+}
+
+// runRecordingScanService records the scan configurations RunScan is called with.
+type runRecordingScanService struct {
+	ScanService
+	runs []string
+	err  error
+}
+
+func (s *runRecordingScanService) RunScan(_ context.Context, configID string, _ []string, _ RunScanOptions) (*repository.ScanExecution, error) {
+	s.runs = append(s.runs, configID)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &repository.ScanExecution{ID: "scan", ScanConfigurationID: configID}, nil
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	now := time.Date(2025, 12, 10, 10, 0, 30, 0, time.UTC)
+	repo := &memoryScheduleRepository{schedules: map[string]repository.ScanSchedule{
+		"due":     {ID: "due", ScanConfigurationID: "config", Cron: "0 * * * *", NextRunAt: now.Add(-time.Hour)},
+		"pending": {ID: "pending", ScanConfigurationID: "other", Cron: "0 * * * *", NextRunAt: now.Add(time.Minute)},
+	}}
+	scans := &runRecordingScanService{}
+	svc := NewScheduleService(repo, nil, scans, fakePool(t, 1)).(scheduleService)
+	svc.now = func() time.Time { return now }
+
+	started, err := svc.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, started)
+	assert.Equal(t, []string{"config"}, scans.runs)
+
+	// a missed run is not caught up, the next run is computed from now
+	assert.Equal(t, time.Date(2025, 12, 10, 11, 0, 0, 0, time.UTC), repo.schedules["due"].NextRunAt)
+	assert.Equal(t, now, repo.schedules["due"].LastRunAt.Time)
+
+	// the schedule has moved on, a second evaluation does not fire it again
+	started, err = svc.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, started)
+	assert.Len(t, scans.runs, 1)
+}
+
+func TestRunDueSchedulesScanFails(t *testing.T) {
+	now := time.Date(2025, 12, 10, 10, 0, 30, 0, time.UTC)
+	repo := &memoryScheduleRepository{schedules: map[string]repository.ScanSchedule{
+		"due": {ID: "due", ScanConfigurationID: "config", Cron: "@daily", NextRunAt: now},
+	}}
+	scans := &runRecordingScanService{err: errors.New("no assets")}
+	svc := NewScheduleService(repo, nil, scans, fakePool(t, 1)).(scheduleService)
+	svc.now = func() time.Time { return now }
+
+	started, err := svc.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, started)
+	// the failed run is not retried before the next run
+	assert.Equal(t, time.Date(2025, 12, 11, 0, 0, 0, 0, time.UTC), repo.schedules["due"].NextRunAt)
+}
+
+func TestRunDueSchedulesInvalidCron(t *testing.T) {
+	now := time.Date(2025, 12, 10, 10, 0, 30, 0, time.UTC)
+	repo := &memoryScheduleRepository{schedules: map[string]repository.ScanSchedule{
+		"broken": {ID: "broken", ScanConfigurationID: "broken", Cron: "daily", NextRunAt: now.Add(-time.Hour)},
+		"due":    {ID: "due", ScanConfigurationID: "config", Cron: "@daily", NextRunAt: now},
+	}}
+	scans := &runRecordingScanService{}
+	svc := NewScheduleService(repo, nil, scans, fakePool(t, 1)).(scheduleService)
+	svc.now = func() time.Time { return now }
+
+	started, err := svc.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, started)
+	assert.Equal(t, []string{"config"}, scans.runs)
+
+	// the broken schedule does not run and is postponed instead of being claimed by every evaluation
+	assert.Equal(t, now.Add(invalidCronDelay), repo.schedules["broken"].NextRunAt)
+	assert.False(t, repo.schedules["broken"].LastRunAt.Valid)
+
+	started, err = svc.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, started)
+}
+
+func TestCreateSchedule(t *testing.T) {
+	now := time.Date(2025, 12, 10, 10, 0, 30, 0, time.UTC)
+	repo := &memoryScheduleRepository{schedules: map[string]repository.ScanSchedule{}}
+	scanRepo := &memoryScanRepository{
+		configs: map[string]repository.ScanConfiguration{"config": {ID: "config"}},
+		assets:  map[string]repository.ScanAsset{"asset": {ID: "asset"}},
+	}
+	svc := NewScheduleService(repo, scanRepo, nil, fakePool(t, 1)).(scheduleService)
+	svc.now = func() time.Time { return now }
+
+	schedule, err := svc.CreateSchedule(context.Background(), ScanScheduleOptions{
+		ScanConfigurationID: "config",
+		AssetIDs:            []string{"asset"},
+		Cron:                "*/15 * * * *",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 12, 10, 10, 15, 0, 0, time.UTC), schedule.NextRunAt)
+	assert.Contains(t, repo.schedules, schedule.ID)
+
+	_, err = svc.CreateSchedule(context.Background(), ScanScheduleOptions{ScanConfigurationID: "unknown", Cron: "@daily"})
+	assert.ErrorIs(t, err, ErrInvalidScanSchedule)
+
+	_, err = svc.CreateSchedule(context.Background(), ScanScheduleOptions{
+		ScanConfigurationID: "config", AssetIDs: []string{"unknown"}, Cron: "@daily",
+	})
+	assert.ErrorIs(t, err, ErrInvalidScanSchedule)
+
+	_, err = svc.CreateSchedule(context.Background(), ScanScheduleOptions{ScanConfigurationID: "config", Cron: "daily"})
+	assert.ErrorIs(t, err, ErrInvalidCron)
+}
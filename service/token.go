@@ -3,10 +3,51 @@ package service
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// ErrInvalidTokenFormat is returned when a token format is outside of the supported sizes.
+var ErrInvalidTokenFormat = errors.New("invalid token format")
+
+// maxTokenIDBytes is limited by the id columns of tokens and agents, which store the hex encoded id.
+const maxTokenIDBytes = 8
+
+// maxTokenSecretBytes is the maximum size of token secrets.
+const maxTokenSecretBytes = 64
+
+// TokenFormat is the number of random bytes of the id and the secret of generated tokens.
+type TokenFormat struct {
+	IDBytes     int
+	SecretBytes int
+}
+
+// DefaultTokenFormat is the format of user tokens and the default format of agent tokens.
+var DefaultTokenFormat = TokenFormat{IDBytes: 4, SecretBytes: 16}
+
+// Validate returns ErrInvalidTokenFormat unless the format is at least as large as DefaultTokenFormat and fits the
+// database.
+func (f TokenFormat) Validate() error {
+	if f.IDBytes < DefaultTokenFormat.IDBytes || f.IDBytes > maxTokenIDBytes {
+		return fmt.Errorf("%w: id must have %d to %d bytes", ErrInvalidTokenFormat,
+			DefaultTokenFormat.IDBytes, maxTokenIDBytes)
+	}
+	if f.SecretBytes < DefaultTokenFormat.SecretBytes || f.SecretBytes > maxTokenSecretBytes {
+		return fmt.Errorf("%w: secret must have %d to %d bytes", ErrInvalidTokenFormat,
+			DefaultTokenFormat.SecretBytes, maxTokenSecretBytes)
+	}
+	return nil
+}
+
+// orDefault returns DefaultTokenFormat for the zero value.
+func (f TokenFormat) orDefault() TokenFormat {
+	if f == (TokenFormat{}) {
+		return DefaultTokenFormat
+	}
+	return f
+}
+
 type token struct {
 	id     string
 	secret string
@@ -23,10 +64,10 @@ func randomString(byteNum int) string {
 	return hex.EncodeToString(id)
 }
 
-func newToken() token {
+func newToken(format TokenFormat) token {
 	return token{
-		id:     randomString(4),
-		secret: randomString(16),
+		id:     randomString(format.IDBytes),
+		secret: randomString(format.SecretBytes),
 	}
 }
 
@@ -34,13 +75,31 @@ func (t token) ToTokenString() string {
 	return fmt.Sprintf("%s.%s", t.id, t.secret)
 }
 
-func parseTokenString(tokenString string) (token, error) {
+// parseTokenString splits a token into id and secret. Both must be hex strings sized between DefaultTokenFormat and
+// format, so tokens generated before the format was enlarged stay valid.
+func parseTokenString(tokenString string, format TokenFormat) (token, error) {
 	parts := strings.Split(tokenString, ".")
 	if len(parts) != 2 {
 		return token{}, fmt.Errorf("invalid token string")
 	}
+	if !isHexOfSize(parts[0], DefaultTokenFormat.IDBytes, format.IDBytes) ||
+		!isHexOfSize(parts[1], DefaultTokenFormat.SecretBytes, format.SecretBytes) {
+		return token{}, fmt.Errorf("invalid token string")
+	}
 	return token{
 		id:     parts[0],
 		secret: parts[1],
 	}, nil
 }
+
+// isHexOfSize reports whether s is a lowercase hex string encoding minBytes to maxBytes bytes.
+func isHexOfSize(s string, minBytes int, maxBytes int) bool {
+	if len(s)%2 != 0 || len(s) < 2*minBytes || len(s) > 2*maxBytes {
+		return false
+	}
+	if strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenFormats(t *testing.T) {
+	for _, format := range []TokenFormat{DefaultTokenFormat, {IDBytes: 8, SecretBytes: 32}, {IDBytes: 6, SecretBytes: 64}} {
+		generated := newToken(format)
+		assert.Len(t, generated.id, 2*format.IDBytes)
+		assert.Len(t, generated.secret, 2*format.SecretBytes)
+
+		parsed, err := parseTokenString(generated.ToTokenString(), format)
+		require.NoError(t, err)
+		assert.Equal(t, generated, parsed)
+
+		// tokens of the default format stay valid after enlarging the format
+		_, err = parseTokenString(newToken(DefaultTokenFormat).ToTokenString(), format)
+		assert.NoError(t, err)
+	}
+}
+
+func TestParseTokenStringRejectsOtherSizes(t *testing.T) {
+	large := newToken(TokenFormat{IDBytes: 8, SecretBytes: 32}).ToTokenString()
+	_, err := parseTokenString(large, DefaultTokenFormat)
+	assert.Error(t, err)
+
+	for _, tokenString := range []string{
+		"a1b2c3.00112233445566778899aabbccddeeff",
+		"a1b2c3d4.00112233445566778899aabbccddee",
+		"a1b2c3d4e.00112233445566778899aabbccddeeff",
+		"A1B2C3D4.00112233445566778899aabbccddeeff",
+		"a1b2c3xx.00112233445566778899aabbccddeeff",
+		"a1b2c3d4." + strings.Repeat("0", 2*maxTokenSecretBytes+2),
+	} {
+		_, err = parseTokenString(tokenString, TokenFormat{IDBytes: 8, SecretBytes: maxTokenSecretBytes})
+		assert.Error(t, err, tokenString)
+	}
+}
+
+func TestTokenFormatValidate(t *testing.T) {
+	assert.NoError(t, DefaultTokenFormat.Validate())
+	assert.NoError(t, TokenFormat{IDBytes: maxTokenIDBytes, SecretBytes: maxTokenSecretBytes}.Validate())
+	for _, format := range []TokenFormat{{IDBytes: 2, SecretBytes: 16}, {IDBytes: 9, SecretBytes: 16},
+		{IDBytes: 4, SecretBytes: 8}, {IDBytes: 4, SecretBytes: 128}} {
+		assert.ErrorIs(t, format.Validate(), ErrInvalidTokenFormat, format)
+	}
+	assert.Equal(t, DefaultTokenFormat, TokenFormat{}.orDefault())
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"cortex/handler"
 	"encoding/json"
 	"io"
@@ -61,12 +62,22 @@ func NewTestRunner(handlerFunc handler.APIFunc) *APIRunner {
 
 func (r *APIRunner) WithBodyString(body string) *APIRunner {
 	r.req.Body = io.NopCloser(bytes.NewBufferString(body))
+	r.req.ContentLength = int64(len(body))
+	return r
+}
+
+// WithChunkedBodyString sets a body of unknown length, like the body of a chunked request.
+func (r *APIRunner) WithChunkedBodyString(body string) *APIRunner {
+	r.req.Body = io.NopCloser(bytes.NewBufferString(body))
+	r.req.ContentLength = -1
+	r.req.TransferEncoding = []string{"chunked"}
 	return r
 }
 
 func (r *APIRunner) WithBody(body any) *APIRunner {
 	jsonData, _ := json.Marshal(body)
 	r.req.Body = io.NopCloser(bytes.NewBuffer(jsonData))
+	r.req.ContentLength = int64(len(jsonData))
 	return r
 }
 
@@ -75,11 +86,23 @@ func (r *APIRunner) WithHeader(key, value string) *APIRunner {
 	return r
 }
 
+func (r *APIRunner) WithContextValue(key, value any) *APIRunner {
+	r.req = r.req.WithContext(context.WithValue(r.req.Context(), key, value))
+	return r
+}
+
 func (r *APIRunner) WithPath(key, value string) *APIRunner {
 	r.req.SetPathValue(key, value)
 	return r
 }
 
+func (r *APIRunner) WithQuery(key, value string) *APIRunner {
+	query := r.req.URL.Query()
+	query.Set(key, value)
+	r.req.URL.RawQuery = query.Encode()
+	return r
+}
+
 func (r *APIRunner) Run(t *testing.T) *Result {
 	err := r.handlerFunc(r.rr, r.req)
 	return &Result{
@@ -0,0 +1,114 @@
+// Package fakepg provides a postgres server answering just enough of the protocol to start and end transactions, so
+// services can be tested without a database.
+package fakepg
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+// Queries collects the statements received by a fake server.
+type Queries struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (q *Queries) add(query string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queries = append(q.queries, query)
+}
+
+// List returns the statements received so far.
+func (q *Queries) List() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.queries)
+}
+
+// NewPool returns a pool of at most maxConns connections to a fake server and the statements it receives. Server and
+// pool are closed when the test ends.
+func NewPool(t *testing.T, maxConns int32) (*pgxpool.Pool, *Queries) {
+	t.Helper()
+
+	return NewFailingPool(t, maxConns, nil)
+}
+
+// NewFailingPool is NewPool with a server answering the statements for which fail returns true with an error.
+func NewFailingPool(t *testing.T, maxConns int32, fail func(query string) bool) (*pgxpool.Pool, *Queries) {
+	t.Helper()
+
+	queries := &Queries{}
+	config, err := pgxpool.ParseConfig("postgres://cortex@" + listen(t, queries, fail) + "/cortex?sslmode=disable")
+	require.NoError(t, err)
+	config.MaxConns = maxConns
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool, queries
+}
+
+// listen accepts connections until the test ends and returns the address of the server.
+func listen(t *testing.T, queries *Queries, fail func(query string) bool) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, queries, fail)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serve(conn net.Conn, queries *Queries, fail func(query string) bool) {
+	defer conn.Close()
+
+	backend := pgproto3.NewBackend(conn, conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			queries.add(msg.String)
+			if fail != nil && fail(msg.String) {
+				backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "40001",
+					Message: "could not serialize access due to concurrent update"})
+			} else {
+				backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(msg.String)})
+			}
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if err = backend.Flush(); err != nil {
+				return
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}